@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sourceExts are the extensions of the files found in directories.
+var sourceExts = map[string]bool{
+	".js":  true,
+	".mjs": true,
+	".cjs": true,
+}
+
+// expandArgs returns the files named by the command line arguments. Each
+// argument may be a file, a directory to search for source files, or a glob
+// pattern matching either.
+func expandArgs(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		paths := []string{arg}
+		if _, err := os.Stat(arg); err != nil && strings.ContainsAny(arg, "*?[") {
+			if paths, err = filepath.Glob(arg); err != nil {
+				return nil, fmt.Errorf("bad pattern %q: %w", arg, err)
+			}
+			if len(paths) == 0 {
+				return nil, fmt.Errorf("no files match %q", arg)
+			}
+		}
+		for _, path := range paths {
+			found, err := sourceFiles(path)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, found...)
+		}
+	}
+	return files, nil
+}
+
+// sourceFiles returns path if it is not a directory, or else the source files
+// in it and its subdirectories.
+func sourceFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		// Missing files are reported when they fail to open.
+		return []string{path}, nil
+	}
+	var files []string
+	err = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && sourceExts[filepath.Ext(path)] {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/features"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+)
+
+// treeFormats are the output formats written from the parsed tree.
+var treeFormats = map[string]func(w io.Writer, n ast.Node) error{
+	"estree":   writeESTree,
+	"sexpr":    writeSExpr,
+	"dot":      writeDot,
+	"features": writeFeatures,
+}
+
+func writeESTree(w io.Writer, n ast.Node) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(n.ESTree())
+}
+
+// field is a member of a decoded ESTree object. Fields are kept in order so
+// that `type` comes first and the rest follow the ESTree definitions.
+type field struct {
+	key   string
+	value interface{}
+}
+
+// object is a decoded ESTree object.
+type object []field
+
+// typ returns the object's `type` field, if any.
+func (o object) typ() string {
+	for _, f := range o {
+		if f.key == "type" {
+			if s, ok := f.value.(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// tree returns the ESTree representation of n as objects, arrays and JSON
+// scalars, so the other formats do not need to know about every node type.
+func tree(n ast.Node) (interface{}, error) {
+	data, err := json.Marshal(n.ESTree())
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return decodeValue(d)
+}
+
+func decodeValue(d *json.Decoder) (interface{}, error) {
+	t, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case json.Delim('{'):
+		o := object{}
+		for d.More() {
+			k, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeValue(d)
+			if err != nil {
+				return nil, err
+			}
+			o = append(o, field{k.(string), v})
+		}
+		_, err = d.Token()
+		return o, err
+	case json.Delim('['):
+		a := []interface{}{}
+		for d.More() {
+			v, err := decodeValue(d)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		_, err = d.Token()
+		return a, err
+	}
+	return t, nil
+}
+
+// scalar formats a JSON scalar.
+func scalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	}
+	return fmt.Sprint(v)
+}
+
+// writeSExpr writes the tree as an s-expression, with each node as a list of
+// its type followed by its non-null fields as keyword arguments.
+func writeSExpr(w io.Writer, n ast.Node) error {
+	t, err := tree(n)
+	if err != nil {
+		return err
+	}
+	b := bufio.NewWriter(w)
+	sexpr(b, t, 0)
+	b.WriteString("\n")
+	return b.Flush()
+}
+
+func sexpr(b *bufio.Writer, v interface{}, depth int) {
+	indent := "\n" + strings.Repeat("  ", depth+1)
+	switch v := v.(type) {
+	case object:
+		b.WriteString("(" + v.typ())
+		first := v.typ() == ""
+		for _, f := range v {
+			if f.key == "type" || f.value == nil {
+				continue
+			}
+			switch f.value.(type) {
+			case object, []interface{}:
+				b.WriteString(indent)
+			default:
+				if !first {
+					b.WriteString(" ")
+				}
+			}
+			b.WriteString(":" + f.key + " ")
+			sexpr(b, f.value, depth+1)
+			first = false
+		}
+		b.WriteString(")")
+	case []interface{}:
+		b.WriteString("(")
+		for i, e := range v {
+			if i > 0 {
+				b.WriteString(indent)
+			}
+			sexpr(b, e, depth+1)
+		}
+		b.WriteString(")")
+	default:
+		b.WriteString(scalar(v))
+	}
+}
+
+// writeDot writes the tree as a Graphviz graph, with a vertex for each node
+// labelled with its type and scalar fields, and an edge to each child
+// labelled with the field it is in.
+func writeDot(w io.Writer, n ast.Node) error {
+	t, err := tree(n)
+	if err != nil {
+		return err
+	}
+	b := bufio.NewWriter(w)
+	b.WriteString("digraph ast {\n\tnode [shape=box];\n")
+	next := 0
+	var vertex func(o object) int
+	vertex = func(o object) int {
+		id := next
+		next++
+		var label []string
+		if typ := o.typ(); typ != "" {
+			label = append(label, typ)
+		}
+		var edges []string
+		for _, f := range o {
+			switch v := f.value.(type) {
+			case object:
+				edges = append(edges, fmt.Sprintf("\tn%d -> n%d [label=%s];\n", id, vertex(v), strconv.Quote(f.key)))
+			case []interface{}:
+				for i, e := range v {
+					if e, ok := e.(object); ok {
+						edges = append(edges, fmt.Sprintf("\tn%d -> n%d [label=%s];\n", id, vertex(e), strconv.Quote(fmt.Sprintf("%s[%d]", f.key, i))))
+					}
+				}
+			default:
+				if f.key != "type" && v != nil {
+					label = append(label, f.key+": "+scalar(v))
+				}
+			}
+		}
+		fmt.Fprintf(b, "\tn%d [label=%s];\n", id, strconv.Quote(strings.Join(label, "\n")))
+		for _, e := range edges {
+			b.WriteString(e)
+		}
+		return id
+	}
+	if o, ok := t.(object); ok {
+		vertex(o)
+	}
+	b.WriteString("}\n")
+	return b.Flush()
+}
+
+// writeFeatures writes the language features that the tree uses, one use per
+// line with its location and the edition that introduced it, and then the
+// oldest edition with all of them.
+func writeFeatures(w io.Writer, n ast.Node) error {
+	b := bufio.NewWriter(w)
+	uses := features.Find(n)
+	for _, u := range uses {
+		fmt.Fprintf(b, "%d:%d\t%s\tES%d\n", u.Span.Start.Row, u.Span.Start.Column, u.Feature.Name, u.Feature.Year)
+	}
+	if year := features.Year(uses); year == features.Baseline {
+		fmt.Fprintln(b, "requires ES5")
+	} else {
+		fmt.Fprintf(b, "requires ES%d\n", year)
+	}
+	return b.Flush()
+}
+
+// writeTokens writes the tokens of text, one per line, with their spans. The
+// lexer can not tell a regular expression from a division without the
+// parser, so whether a `/` begins a regular expression is guessed from the
+// token before it, unless raw is set.
+func writeTokens(w io.Writer, text string, uri *url.URL, raw bool) error {
+	l := lexer.NewLexer(lexer.NewScanner(strings.NewReader(text), uri))
+	b := bufio.NewWriter(w)
+	prev := lexer.TokenNone
+	for {
+		t, err := l.Lex()
+		if err == nil && !raw && prev.RegexAllowed() &&
+			(t.Type == lexer.TokenPunctuatorDiv || t.Type == lexer.TokenPunctuatorDivAssign) {
+			var re lexer.ReToken
+			re, err = l.ReLex()
+			t = re.Token
+		}
+		if err != nil {
+			b.Flush()
+			return err
+		}
+		if t.Type == lexer.TokenNone {
+			break
+		}
+		prev = t.Type
+		start, end := l.Start(), l.Location()
+		fmt.Fprintf(b, "%d:%d-%d:%d\t%s", start.Row, start.Column, end.Row, end.Column, t.Type)
+		if t.Literal != "" {
+			fmt.Fprintf(b, "\t%s", strconv.Quote(t.Literal))
+		}
+		b.WriteString("\n")
+	}
+	return b.Flush()
+}
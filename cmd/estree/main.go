@@ -1,63 +1,285 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
 	"encoding/json"
 	"flag"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
 	"github.com/jchv/cleansheets/ecmascript/lexer"
 	"github.com/jchv/cleansheets/ecmascript/parser"
 )
 
-func main() {
-	flag.Parse()
+var (
+	module       = flag.Bool("module", false, "parse input as a module (default for .mjs files)")
+	script       = flag.Bool("script", false, "parse input as a script (default)")
+	expr         = flag.Bool("expr", false, "parse input as a single expression")
+	format       = flag.String("format", "estree", "output format: estree, sexpr, dot, tokens or features")
+	check        = flag.Bool("check", false, "only report errors, without writing output")
+	ndjson       = flag.Bool("ndjson", false, "write a JSON object per file, with its file name and either its ESTree AST or its error, one per line")
+	raw          = flag.Bool("raw", false, "with -format tokens, write `/` as a division even where it begins a regular expression")
+	comment      = flag.Bool("comment", false, "with -format estree, include the comments as a `comments` array on the Program node")
+	experimental = flag.String("experimental", "", "comma-separated proposals to accept syntax from: module-blocks, source-phase-imports, decorators")
+	diags        = flag.String("diagnostics", "text", "diagnostics format: text, or json for a JSON object per diagnostic on stderr, one per line")
+	workers      = flag.Int("j", runtime.NumCPU(), "number of files to parse at once")
+)
+
+// parseMode returns the parse mode selected by the flags, and whether one
+// was selected at all.
+func parseMode() (parser.ParseMode, bool) {
+	mode, n := parser.ScriptMode, 0
+	if *script {
+		n++
+	}
+	if *module {
+		mode = parser.ModuleMode
+		n++
+	}
+	if *expr {
+		mode = parser.ExpressionMode
+		n++
+	}
+	if n > 1 {
+		log.Fatal("Only one of -module, -script and -expr may be given")
+	}
+	return mode, n == 1
+}
+
+// experiments returns the proposals selected by the -experimental flag.
+func experiments() parser.Experimental {
+	var exp parser.Experimental
+	for _, name := range strings.Split(*experimental, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "module-blocks":
+			exp.ModuleBlocks = true
+		case "source-phase-imports":
+			exp.SourcePhaseImports = true
+		case "decorators":
+			exp.Decorators = true
+		default:
+			log.Fatalf("Unknown proposal %q", name)
+		}
+	}
+	return exp
+}
+
+// job is the work of processing one file.
+type job struct {
+	filename string
+	mode     parser.ParseMode
+	exp      parser.Experimental
+	write    func(w io.Writer, n ast.Node) error
+
+	// output is what is written to stdout, and log what is written to
+	// stderr, once every file before this one is done.
+	output bytes.Buffer
+	log    bytes.Buffer
+	failed bool
+}
 
-	encoder := json.NewEncoder(os.Stdout)
+// record is a line of NDJSON output.
+type record struct {
+	File  string      `json:"file"`
+	AST   interface{} `json:"ast,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+// writeRecord writes the NDJSON output for the job.
+func (j *job) writeRecord(n ast.Node, err error) {
+	r := record{File: j.filename}
+	if err != nil {
+		r.Error = err.Error()
+	} else {
+		r.AST = n.ESTree()
+	}
+	encoder := json.NewEncoder(&j.output)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(r); err != nil {
+		log.New(&j.log, "", log.LstdFlags).Printf("Error while writing AST: %v", err)
+		j.output.Reset()
+		j.failed = true
+	}
+}
+
+// report records a diagnostic for the file. In JSON mode each diagnostic is
+// a line of its own, with the file name as given on the command line.
+func (j *job) report(d errs.Diagnostic, src *errs.Source) {
+	if *diags != "json" {
+		log.New(&j.log, "", log.LstdFlags).Print(d.Format(src))
+		return
+	}
+	encoder := json.NewEncoder(&j.log)
 	encoder.SetEscapeHTML(false)
-	encoder.SetIndent("", "  ")
+	encoder.Encode(struct {
+		File       string          `json:"file"`
+		Diagnostic errs.Diagnostic `json:"diagnostic"`
+	}{j.filename, d})
+}
+
+// run processes the file, recording the result in the job.
+func (j *job) run() {
+	logger := log.New(&j.log, "", log.LstdFlags)
+	if *diags == "json" {
+		// Only diagnostics are written, so that stderr can be decoded.
+		logger.SetOutput(ioutil.Discard)
+	}
+
+	// Read the whole file, so that errors can quote it.
+	data, err := ioutil.ReadFile(j.filename)
+	if err != nil {
+		logger.Printf("Could not open file for reading: %q", j.filename)
+		if *diags == "json" {
+			j.report(errs.Diagnostic{Code: errs.CodeReadError, Severity: errs.SeverityError, Message: err.Error()}, nil)
+		}
+		j.failed = true
+		if *ndjson && !*check {
+			j.writeRecord(nil, err)
+		}
+		return
+	}
+	text := string(data)
 
-	for i, filename := range flag.Args() {
-		// Write separator if multiple files.
-		if i != 0 {
-			os.Stdout.Write([]byte("\n---\n"))
+	// Try to calculate a file URL.
+	absname, err := filepath.Abs(j.filename)
+	if err != nil {
+		absname = j.filename
+	}
+	url := &url.URL{}
+	url.Scheme = "file"
+	url.Path = absname
+
+	// Dump tokens without parsing.
+	if j.write == nil {
+		if err := writeTokens(&j.output, text, url, *raw); err != nil {
+			j.report(errs.Diagnostics(err)[0], errs.NewSource(text))
+			logger.Printf("Could not lex ECMAscript file %q", j.filename)
+			j.failed = true
 		}
+		return
+	}
+
+	if !*check && !*ndjson {
+		logger.Printf("Parsing %q...", url)
+	}
 
-		// Open file for reading and create a buffered reader.
-		file, err := os.Open(filename)
-		if err != nil {
-			log.Fatalf("Could not open file for reading: %q", filename)
+	// Parse input.
+	p := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(text), url)))
+	node, err := p.Parse(parser.ParseOptions{Mode: j.mode, Comments: *comment, Experimental: j.exp})
+	src := errs.NewSource(text)
+	for _, d := range p.Warnings() {
+		j.report(d, src)
+	}
+	if err != nil {
+		for _, d := range errs.Diagnostics(err) {
+			j.report(d, src)
 		}
-		defer func(file *os.File) {
-			if err := file.Close(); err != nil {
-				log.Printf("Warning: Error closing file: %v", err)
-			}
-		}(file)
-		reader := bufio.NewReader(file)
+		logger.Printf("Could not parse ECMAscript file %q", j.filename)
+		j.failed = true
+		if *ndjson && !*check {
+			j.writeRecord(nil, err)
+		}
+		return
+	}
 
-		// Try to calculate a file URL.
-		absname, err := filepath.Abs(filename)
-		if err != nil {
-			absname = filename
+	// Output AST.
+	switch {
+	case *check:
+	case *ndjson:
+		j.writeRecord(node, err)
+	default:
+		if err := j.write(&j.output, node); err != nil {
+			logger.Printf("Error while writing AST: %v", err)
+			j.failed = true
 		}
-		url := &url.URL{}
-		url.Scheme = "file"
-		url.Path = absname
-		log.Printf("Parsing %q...", url)
+	}
+}
+
+func main() {
+	flag.Parse()
+	mode, explicit := parseMode()
+	exp := experiments()
 
-		// Parse script.
-		script, err := parser.NewParser(lexer.NewLexer(lexer.NewScanner(reader, url))).Parse(parser.ParseOptions{Mode: parser.ScriptMode})
-		if err != nil {
-			log.Fatalf("Could not parse ECMAscript file %q: %v", filename, err)
+	write, ok := treeFormats[*format]
+	switch {
+	case *format == "babel":
+		log.Fatal("The babel output format is not available yet")
+	case !ok && *format != "tokens":
+		log.Fatalf("Unknown output format %q", *format)
+	}
+	if *ndjson && *format != "estree" {
+		log.Fatal("-ndjson can only be used with the estree format")
+	}
+	if *diags != "text" && *diags != "json" {
+		log.Fatalf("Unknown diagnostics format %q", *diags)
+	}
+	if *workers < 1 {
+		log.Fatal("-j must be at least 1")
+	}
+
+	files, err := expandArgs(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	jobs := make([]*job, len(files))
+	done := make([]chan struct{}, len(files))
+	for i, filename := range files {
+		jobs[i] = &job{filename: filename, mode: mode, exp: exp, write: write}
+		if !explicit && filepath.Ext(filename) == ".mjs" {
+			jobs[i].mode = parser.ModuleMode
 		}
+		done[i] = make(chan struct{})
+	}
 
-		// Output ESTree AST.
-		err = encoder.Encode(script.ESTree())
-		if err != nil {
-			log.Fatalf("Error while encoding ESTree AST: %v", err)
+	// Parse across the workers, but write results in the order given.
+	next := make(chan int)
+	go func() {
+		for i := range jobs {
+			next <- i
 		}
+		close(next)
+	}()
+	for w := 0; w < *workers; w++ {
+		go func() {
+			for i := range next {
+				jobs[i].run()
+				close(done[i])
+			}
+		}()
+	}
+
+	failed, written := 0, 0
+	for i, j := range jobs {
+		<-done[i]
+		os.Stderr.Write(j.log.Bytes())
+		if j.failed {
+			failed++
+		}
+		if j.output.Len() > 0 {
+			// Write separator if multiple files.
+			if written > 0 && !*ndjson {
+				os.Stdout.Write([]byte("\n---\n"))
+			}
+			os.Stdout.Write(j.output.Bytes())
+			written++
+		}
+		jobs[i] = nil
+	}
+
+	if len(jobs) > 1 && *diags != "json" {
+		log.Printf("Processed %d files, %d failed", len(jobs), failed)
+	}
+	if failed > 0 {
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jchv/cleansheets/ecmascript/conformance"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+var (
+	ignore  = flag.String("ignore", "start,end,loc,range", "comma-separated properties to leave out of the comparison")
+	verbose = flag.Bool("v", false, "also list fixtures that match")
+)
+
+// fixture is a source file and the reference ESTree JSON next to it, which
+// has the same name with a .json extension.
+type fixture struct {
+	source    string
+	reference string
+	module    bool
+}
+
+// findFixtures returns the fixtures in dir. Sources ending in .mjs are
+// modules, and sources ending in .js are scripts.
+func findFixtures(dir string) ([]fixture, error) {
+	var fixtures []fixture
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := filepath.Ext(path)
+		if info.IsDir() || ext != ".js" && ext != ".mjs" {
+			return nil
+		}
+		fixtures = append(fixtures, fixture{
+			source:    path,
+			reference: strings.TrimSuffix(path, ext) + ".json",
+			module:    ext == ".mjs",
+		})
+		return nil
+	})
+	return fixtures, err
+}
+
+// compare parses the fixture source and compares the result with the
+// reference, returning the differences.
+func compare(f fixture, ignored map[string]bool) ([]conformance.Difference, error) {
+	reference, err := ioutil.ReadFile(f.reference)
+	if err != nil {
+		return nil, err
+	}
+	src, err := ioutil.ReadFile(f.source)
+	if err != nil {
+		return nil, err
+	}
+	mode := parser.ScriptMode
+	if f.module {
+		mode = parser.ModuleMode
+	}
+	n, err := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(string(src)), nil))).Parse(parser.ParseOptions{Mode: mode})
+	if err != nil {
+		return nil, err
+	}
+	ds, err := conformance.DiffESTree(reference, n, ignored)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.reference, err)
+	}
+	return ds, nil
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] fixture-dir...\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Compares the ESTree output for each .js and .mjs file with the reference JSON\nnext to it, such as ecmascript/conformance/testdata/estree.\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ignored := map[string]bool{}
+	for _, k := range strings.Split(*ignore, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			ignored[k] = true
+		}
+	}
+
+	var fixtures []fixture
+	for _, dir := range flag.Args() {
+		found, err := findFixtures(dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fixtures = append(fixtures, found...)
+	}
+
+	matched, differed, failed, missing := 0, 0, 0, 0
+	for _, f := range fixtures {
+		ds, err := compare(f, ignored)
+		switch {
+		case os.IsNotExist(err):
+			missing++
+			if *verbose {
+				fmt.Printf("SKIP %s: no reference\n", f.source)
+			}
+		case err != nil:
+			failed++
+			fmt.Printf("FAIL %s: %v\n", f.source, err)
+		case len(ds) > 0:
+			differed++
+			fmt.Printf("DIFF %s\n", f.source)
+			for _, d := range ds {
+				fmt.Printf("\t%s\n", d)
+			}
+		default:
+			matched++
+			if *verbose {
+				fmt.Printf("OK   %s\n", f.source)
+			}
+		}
+	}
+	fmt.Printf("%d fixtures: %d match, %d differ, %d failed, %d without a reference\n", len(fixtures), matched, differed, failed, missing)
+	if differed > 0 || failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+var (
+	iterations = flag.Int("n", 10, "number of times to process the corpus")
+	lexOnly    = flag.Bool("lex", false, "only lex the corpus, without parsing it")
+	module     = flag.Bool("module", false, "parse input as a module (default for .mjs files)")
+	script     = flag.Bool("script", false, "parse input as a script (default)")
+	cpuprofile = flag.String("cpuprofile", "", "write a CPU profile to `file`")
+	memprofile = flag.String("memprofile", "", "write a heap profile to `file` after the last iteration")
+)
+
+// sourceExts are the extensions of the files found in directories.
+var sourceExts = map[string]bool{
+	".js":  true,
+	".mjs": true,
+	".cjs": true,
+}
+
+// input is a file of the corpus.
+type input struct {
+	name string
+	text string
+	mode parser.ParseMode
+}
+
+// loadCorpus reads the files named by args, searching directories for source
+// files.
+func loadCorpus(args []string) ([]input, error) {
+	var corpus []input
+	for _, arg := range args {
+		err := filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || path != arg && !sourceExts[filepath.Ext(path)] {
+				return nil
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			in := input{name: path, text: string(data), mode: parser.ScriptMode}
+			if *module || !*script && filepath.Ext(path) == ".mjs" {
+				in.mode = parser.ModuleMode
+			}
+			corpus = append(corpus, in)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return corpus, nil
+}
+
+// lexFile lexes the whole of text, returning the number of tokens. Without a
+// parser, whether a `/` begins a regular expression is guessed from the token
+// before it.
+func lexFile(text string) (int, error) {
+	l := lexer.NewLexer(lexer.NewScanner(strings.NewReader(text), nil))
+	n := 0
+	prev := lexer.TokenNone
+	for {
+		t, err := l.Lex()
+		if err == nil && prev.RegexAllowed() &&
+			(t.Type == lexer.TokenPunctuatorDiv || t.Type == lexer.TokenPunctuatorDivAssign) {
+			var re lexer.ReToken
+			re, err = l.ReLex()
+			t = re.Token
+		}
+		if err != nil {
+			return n, err
+		}
+		if t.Type == lexer.TokenNone {
+			return n, nil
+		}
+		prev = t.Type
+		n++
+	}
+}
+
+func parseFile(in input) error {
+	p := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(in.text), nil)))
+	_, err := p.Parse(parser.ParseOptions{Mode: in.mode})
+	return err
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] file-or-directory...\n\nFlags:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *module && *script {
+		log.Fatal("Only one of -module and -script may be given")
+	}
+	if *iterations < 1 {
+		log.Fatal("-n must be at least 1")
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	corpus, err := loadCorpus(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Check the corpus first, so that a benchmark does not silently measure
+	// how fast an error is found.
+	bytes, tokens := 0, 0
+	for _, in := range corpus {
+		n, err := lexFile(in.text)
+		if err == nil && !*lexOnly {
+			err = parseFile(in)
+		}
+		if err != nil {
+			log.Fatalf("Could not process %q: %v", in.name, err)
+		}
+		bytes += len(in.text)
+		tokens += n
+	}
+
+	if *cpuprofile != "" {
+		f, err := os.Create(*cpuprofile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			log.Fatal(err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := 0; i < *iterations; i++ {
+		for _, in := range corpus {
+			if *lexOnly {
+				lexFile(in.text)
+			} else {
+				parseFile(in)
+			}
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	if *memprofile != "" {
+		f, err := os.Create(*memprofile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	what := "parse"
+	if *lexOnly {
+		what = "lex"
+	}
+	n := float64(*iterations)
+	seconds := elapsed.Seconds()
+	fmt.Printf("corpus: %d files, %.2f MB, %d tokens\n", len(corpus), float64(bytes)/1e6, tokens)
+	fmt.Printf("%s: %d iterations in %v, %v per iteration\n", what, *iterations, elapsed.Round(time.Millisecond), (elapsed / time.Duration(*iterations)).Round(time.Microsecond))
+	fmt.Printf("throughput: %.2f MB/s, %.0f tokens/s\n", float64(bytes)*n/1e6/seconds, float64(tokens)*n/seconds)
+	fmt.Printf("allocations: %.0f allocs, %.2f MB per iteration\n", float64(after.Mallocs-before.Mallocs)/n, float64(after.TotalAlloc-before.TotalAlloc)/1e6/n)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jchv/cleansheets/ecmascript/graph"
+	"github.com/jchv/cleansheets/ecmascript/resolve"
+)
+
+var (
+	format     = flag.String("format", "json", "output format: json or dot")
+	conditions = flag.String("conditions", "", "comma-separated package.json export conditions to match, in addition to import and default")
+	browser    = flag.Bool("browser", false, "resolve for browsers, following the package.json browser field")
+	aliases    = flag.String("alias", "", "comma-separated specifier=target aliases to apply before resolving, e.g. react=preact/compat")
+	tsconfig   = flag.String("tsconfig", "", "tsconfig.json whose baseUrl and paths options map bare specifiers to files")
+)
+
+// display returns path relative to the working directory, if it is inside it.
+func display(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
+
+type jsonImport struct {
+	Specifier string `json:"specifier"`
+	Path      string `json:"path,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Ignored   bool   `json:"ignored,omitempty"`
+}
+
+type jsonModule struct {
+	Path    string       `json:"path"`
+	Error   string       `json:"error,omitempty"`
+	Imports []jsonImport `json:"imports"`
+}
+
+type jsonGraph struct {
+	Entries []string     `json:"entries"`
+	Modules []jsonModule `json:"modules"`
+	Cycles  [][]string   `json:"cycles"`
+}
+
+func writeJSON(g *graph.Graph, cycles [][]*graph.Module) error {
+	out := jsonGraph{Entries: []string{}, Modules: []jsonModule{}, Cycles: [][]string{}}
+	for _, e := range g.Entries {
+		out.Entries = append(out.Entries, display(e))
+	}
+	for _, m := range g.Modules {
+		jm := jsonModule{Path: display(m.Path), Imports: []jsonImport{}}
+		if m.Err != nil {
+			jm.Error = m.Err.Error()
+		}
+		for _, imp := range m.Imports {
+			ji := jsonImport{Specifier: imp.Specifier, Ignored: imp.Ignored}
+			switch {
+			case imp.Err != nil:
+				ji.Error = imp.Err.Error()
+			case !imp.Ignored:
+				ji.Path = display(imp.Path)
+			}
+			jm.Imports = append(jm.Imports, ji)
+		}
+		out.Modules = append(out.Modules, jm)
+	}
+	for _, c := range cycles {
+		cycle := []string{}
+		for _, m := range c {
+			cycle = append(cycle, display(m.Path))
+		}
+		out.Cycles = append(out.Cycles, cycle)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+// writeDot writes the graph for Graphviz. Modules in a cycle and the imports
+// between them are red, and unresolved specifiers are dashed vertices.
+func writeDot(g *graph.Graph, cycles [][]*graph.Module) error {
+	cycle := map[*graph.Module]int{}
+	for i, c := range cycles {
+		for _, m := range c {
+			cycle[m] = i + 1
+		}
+	}
+	id := map[*graph.Module]int{}
+	for i, m := range g.Modules {
+		id[m] = i
+	}
+
+	b := bufio.NewWriter(os.Stdout)
+	b.WriteString("digraph modules {\n\tnode [shape=box];\n")
+	for _, m := range g.Modules {
+		attrs := "label=" + strconv.Quote(display(m.Path))
+		if cycle[m] != 0 {
+			attrs += ", color=red"
+		}
+		if m.Err != nil {
+			attrs += ", style=filled, fillcolor=lightgray"
+		}
+		fmt.Fprintf(b, "\tm%d [%s];\n", id[m], attrs)
+	}
+	unresolved := 0
+	for _, m := range g.Modules {
+		for _, imp := range m.Imports {
+			if imp.Err != nil {
+				fmt.Fprintf(b, "\tu%d [label=%s, style=dashed];\n", unresolved, strconv.Quote(imp.Specifier))
+				fmt.Fprintf(b, "\tm%d -> u%d [style=dashed];\n", id[m], unresolved)
+				unresolved++
+				continue
+			}
+			if imp.Ignored {
+				continue
+			}
+			n := g.Module(imp.Path)
+			attrs := ""
+			if cycle[m] != 0 && cycle[m] == cycle[n] {
+				attrs = " [color=red]"
+			}
+			fmt.Fprintf(b, "\tm%d -> m%d%s;\n", id[m], id[n], attrs)
+		}
+	}
+	b.WriteString("}\n")
+	return b.Flush()
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] entry...\n\nFlags:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var write func(g *graph.Graph, cycles [][]*graph.Module) error
+	switch *format {
+	case "json":
+		write = writeJSON
+	case "dot":
+		write = writeDot
+	default:
+		log.Fatalf("Unknown output format %q", *format)
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	r := resolve.NewResolver()
+	for _, c := range strings.Split(*conditions, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			r.Conditions = append(r.Conditions, c)
+		}
+	}
+	r.Browser = *browser
+	for _, a := range strings.Split(*aliases, ",") {
+		if a = strings.TrimSpace(a); a == "" {
+			continue
+		}
+		i := strings.Index(a, "=")
+		if i <= 0 {
+			log.Fatalf("Invalid alias %q, expected specifier=target", a)
+		}
+		if r.Aliases == nil {
+			r.Aliases = map[string]string{}
+		}
+		r.Aliases[a[:i]] = a[i+1:]
+	}
+	if *tsconfig != "" {
+		data, err := ioutil.ReadFile(*tsconfig)
+		if err != nil {
+			log.Fatalf("Error reading tsconfig: %v", err)
+		}
+		if r.TSConfig, err = resolve.ParseTSConfig(data, filepath.Dir(*tsconfig)); err != nil {
+			log.Fatalf("Error reading %s: %v", *tsconfig, err)
+		}
+	}
+
+	g := graph.Build(flag.Args(), r)
+	cycles := g.Cycles()
+
+	failed := false
+	for _, m := range g.Modules {
+		if m.Err != nil {
+			log.Printf("Could not load %s: %v", display(m.Path), m.Err)
+			failed = true
+		}
+		for _, imp := range m.Imports {
+			if imp.Err != nil {
+				reason := imp.Err.Error()
+				var rerr *resolve.Error
+				if errors.As(imp.Err, &rerr) {
+					reason = rerr.Reason
+				}
+				log.Printf("%s:%d:%d: unresolved import %q: %s", display(m.Path), imp.Span.Start.Row, imp.Span.Start.Column, imp.Specifier, reason)
+				failed = true
+			}
+		}
+	}
+	for _, c := range cycles {
+		names := []string{}
+		for _, m := range c {
+			names = append(names, display(m.Path))
+		}
+		log.Printf("Import cycle: %s", strings.Join(names, ", "))
+	}
+
+	if err := write(g, cycles); err != nil {
+		log.Fatalf("Error while writing graph: %v", err)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
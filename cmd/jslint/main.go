@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jchv/cleansheets/ecmascript/errs"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/lint"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+var (
+	module  = flag.Bool("module", false, "check input as a module (default for .mjs files)")
+	script  = flag.Bool("script", false, "check input as a script (default)")
+	enable  = flag.String("enable", "", "comma-separated rules to run, instead of every rule")
+	disable = flag.String("disable", "", "comma-separated rules not to run")
+	globals = flag.String("globals", "", "comma-separated names of globals provided by the environment")
+	diags   = flag.String("diagnostics", "text", "diagnostics format: text, or json for a JSON object per diagnostic, one per line")
+	fix     = flag.Bool("fix", false, "apply the fixes that rules suggest, rewriting the files in place")
+)
+
+// record is a line of JSON output.
+type record struct {
+	File       string          `json:"file"`
+	Rule       string          `json:"rule,omitempty"`
+	Diagnostic errs.Diagnostic `json:"diagnostic"`
+}
+
+// split splits a comma-separated flag value, ignoring empty entries.
+func split(s string) []string {
+	var r []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+// rules returns the rules selected by the flags.
+func rules() []*lint.Rule {
+	selected := lint.Rules
+	if *enable != "" {
+		selected = nil
+		for _, name := range split(*enable) {
+			r := lint.Lookup(name)
+			if r == nil {
+				log.Fatalf("Unknown rule %q", name)
+			}
+			selected = append(selected, r)
+		}
+	}
+	disabled := map[*lint.Rule]bool{}
+	for _, name := range split(*disable) {
+		r := lint.Lookup(name)
+		if r == nil {
+			log.Fatalf("Unknown rule %q", name)
+		}
+		disabled[r] = true
+	}
+	var result []*lint.Rule
+	for _, r := range selected {
+		if !disabled[r] {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// ruleName returns the name of the rule that reports diagnostics with code c,
+// or "" for diagnostics from the parser.
+func ruleName(c errs.Code) string {
+	for _, r := range lint.Rules {
+		if r.Code == c {
+			return r.Name
+		}
+	}
+	return ""
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] file...\n\nRules:\n", os.Args[0])
+	for _, r := range lint.Rules {
+		fmt.Fprintf(flag.CommandLine.Output(), "  %-12s %s\n", r.Name, r.Doc)
+	}
+	fmt.Fprintf(flag.CommandLine.Output(), "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if *module && *script {
+		log.Fatal("Only one of -module and -script may be given")
+	}
+	if *diags != "text" && *diags != "json" {
+		log.Fatalf("Unknown diagnostics format %q", *diags)
+	}
+	selected := rules()
+	opt := lint.Options{Globals: split(*globals)}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+
+	failed := false
+	for _, filename := range flag.Args() {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			failed = true
+			if *diags == "text" {
+				log.Printf("Could not open file for reading: %q", filename)
+				continue
+			}
+			d := errs.Diagnostic{Code: errs.CodeReadError, Severity: errs.SeverityError, Message: err.Error()}
+			if err := encoder.Encode(record{File: filename, Diagnostic: d}); err != nil {
+				log.Fatalf("Error while writing diagnostics: %v", err)
+			}
+			continue
+		}
+		text := string(data)
+
+		absname, err := filepath.Abs(filename)
+		if err != nil {
+			absname = filename
+		}
+		uri := &url.URL{Scheme: "file", Path: absname}
+
+		mode := parser.ScriptMode
+		if *module || !*script && filepath.Ext(filename) == ".mjs" {
+			mode = parser.ModuleMode
+		}
+
+		p := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(text), uri)))
+		node, err := p.Parse(parser.ParseOptions{Mode: mode, Comments: true})
+		var ds []errs.Diagnostic
+		if err != nil {
+			ds = errs.Diagnostics(err)
+		} else {
+			ds = lint.Run(node, selected, opt)
+		}
+		if *fix && err == nil {
+			if fixed, n := lint.Fix(text, ds); n > 0 {
+				if err := ioutil.WriteFile(filename, []byte(fixed), 0o644); err != nil {
+					log.Fatalf("Error while writing fixed file: %v", err)
+				}
+				if *diags == "text" {
+					log.Printf("%s: applied %d fixes", filename, n)
+				}
+			}
+		}
+
+		src := errs.NewSource(text)
+		for _, d := range ds {
+			if d.Severity == errs.SeverityError {
+				failed = true
+			}
+			if *diags == "text" {
+				fmt.Println(d.Format(src))
+				continue
+			}
+			err := encoder.Encode(record{File: filename, Rule: ruleName(d.Code), Diagnostic: d})
+			if err != nil {
+				log.Fatalf("Error while writing diagnostics: %v", err)
+			}
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jchv/cleansheets/ecmascript/conformance"
+)
+
+var (
+	expectations = flag.String("expected-failures", "", "`file` listing tests that are known to fail, one per line")
+	update       = flag.Bool("update", false, "rewrite the -expected-failures file with the tests that failed")
+	skipFeatures = flag.String("skip-features", "", "comma-separated test262 features; tests using any of them are skipped")
+	timeout      = flag.Duration("timeout", 10*time.Second, "time limit for parsing a single test")
+	verbose      = flag.Bool("v", false, "list every failing test, including expected failures")
+	workers      = flag.Int("j", runtime.NumCPU(), "number of tests to run at once")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] test262-checkout [path...]\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Runs the tests under the given paths of the checkout's test directory, or all\nof them, checking only that each test parses or fails to parse as expected.\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *update && *expectations == "" {
+		log.Fatal("-update requires -expected-failures")
+	}
+	if *workers < 1 {
+		log.Fatal("-j must be at least 1")
+	}
+
+	opt := conformance.Options{Timeout: *timeout, Workers: *workers}
+	if *expectations != "" {
+		var err error
+		opt.Expected, err = conformance.ReadExpectations(*expectations)
+		if os.IsNotExist(err) && *update {
+			err = nil
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, f := range strings.Split(*skipFeatures, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			opt.SkipFeatures = append(opt.SkipFeatures, f)
+		}
+	}
+
+	results, err := conformance.Run(flag.Arg(0), flag.Args()[1:], opt)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, r := range results {
+		switch {
+		case r.Outcome == conformance.Fail, r.Outcome == conformance.ExpectedFail && *verbose:
+			fmt.Printf("FAIL %s: %s\n", r.Name, r.Reason)
+		case r.Outcome == conformance.UnexpectedPass:
+			fmt.Printf("PASS %s: expected to fail\n", r.Name)
+		}
+	}
+	counts := conformance.Count(results)
+	fmt.Printf("Ran %d tests: %d passed, %d failed, %d expected failures, %d unexpectedly passed, %d skipped\n",
+		len(results), counts[conformance.Pass], counts[conformance.Fail], counts[conformance.ExpectedFail], counts[conformance.UnexpectedPass], counts[conformance.Skip])
+
+	if *update {
+		if err := conformance.WriteExpectations(*expectations, results); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if counts[conformance.Fail] > 0 || counts[conformance.UnexpectedPass] > 0 {
+		os.Exit(1)
+	}
+}
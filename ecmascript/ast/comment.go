@@ -0,0 +1,62 @@
+package ast
+
+// Comment is a comment in the source. Comments are not part of the tree, but
+// the parser can keep them alongside it when asked to.
+type Comment struct {
+	// Block is whether this is a /* */ comment rather than a // comment.
+	Block bool
+
+	// Value is the text of the comment, without its delimiters.
+	Value string
+
+	Span Span
+
+	// Range is the start and end of the comment as offsets in UTF-16 code
+	// units, which is how JS strings index text.
+	Range [2]int
+}
+
+// ESTree returns the representation of the comment used by ESTree parsers,
+// such as acorn and espree. Columns in its location count from 0.
+func (c Comment) ESTree() interface{} {
+	typ := "Line"
+	if c.Block {
+		typ = "Block"
+	}
+	return struct {
+		Type  string      `json:"type"`
+		Value string      `json:"value"`
+		Range [2]int      `json:"range"`
+		Loc   interface{} `json:"loc"`
+	}{
+		Type:  typ,
+		Value: c.Value,
+		Range: c.Range,
+		Loc:   estreeLoc(c.Span),
+	}
+}
+
+// estreeLoc returns the ESTree SourceLocation of a span.
+func estreeLoc(s Span) interface{} {
+	type position struct {
+		Line   int `json:"line"`
+		Column int `json:"column"`
+	}
+	return struct {
+		Start position `json:"start"`
+		End   position `json:"end"`
+	}{
+		Start: position{s.Start.Row, s.Start.Column - 1},
+		End:   position{s.End.Row, s.End.Column - 1},
+	}
+}
+
+// estreeComments returns the ESTree representation of comments, or nil if
+// there are none.
+func estreeComments(comments []Comment) []interface{} {
+	var e []interface{}
+	for _, c := range comments {
+		e = append(e, c.ESTree())
+	}
+	return e
+}
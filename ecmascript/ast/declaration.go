@@ -14,9 +14,12 @@ package ast
 //         Params: FormalParameters{},
 //         Body: BlockStatement{},
 //     }
+//
+// The name is a string rather than a node, so its span is kept in IDSpan.
 type FunctionDeclaration struct {
 	BaseNode
 	ID         string
+	IDSpan     Span
 	Params     FormalParameters
 	Body       BlockStatement
 	Generator  bool
@@ -71,7 +74,7 @@ func (n ClassDeclaration) ESTree() interface{} {
 	e := struct {
 		Type       string      `json:"type"`
 		ID         interface{} `json:"id"`
-		SuperClass interface{} `json:"params"`
+		SuperClass interface{} `json:"superClass"`
 		Body       struct {
 			Type string        `json:"type"`
 			Body []interface{} `json:"body"`
@@ -83,6 +86,7 @@ func (n ClassDeclaration) ESTree() interface{} {
 	}
 
 	e.Body.Type = "ClassBody"
+	e.Body.Body = []interface{}{}
 	for _, elem := range n.Body {
 		e.Body.Body = append(e.Body.Body, estree(elem))
 	}
@@ -96,13 +100,21 @@ const (
 	Method MethodKind = iota
 	GetMethod
 	SetMethod
+	ConstructorMethod
 )
 
 // estreeMethodKindMap maps MethodKind values to their corresponding ESTree strings.
 var estreeMethodKindMap = map[MethodKind]string{
-	Method:    "method",
-	GetMethod: "get",
-	SetMethod: "set",
+	Method:            "method",
+	GetMethod:         "get",
+	SetMethod:         "set",
+	ConstructorMethod: "constructor",
+}
+
+// ESTree returns the kind as it is written in the `kind` field of an ESTree
+// node.
+func (k MethodKind) ESTree() string {
+	return estreeMethodKindMap[k]
 }
 
 // MethodDefinition represents a method in a class body.
@@ -133,3 +145,31 @@ func (n MethodDefinition) ESTree() interface{} {
 		Static:   n.Static,
 	}
 }
+
+// AccessorProperty represents an auto-accessor in a class body, from the
+// decorators proposal: a field with a getter and setter made for it, e.g.
+// accessor x = 1;
+type AccessorProperty struct {
+	BaseNode
+	Key      Node
+	Computed bool
+	Value    Node
+	Static   bool
+}
+
+// ESTree returns the corresponding ESTree representation for this node.
+func (n AccessorProperty) ESTree() interface{} {
+	return struct {
+		Type     string      `json:"type"`
+		Key      interface{} `json:"key"`
+		Computed bool        `json:"computed"`
+		Value    interface{} `json:"value"`
+		Static   bool        `json:"static"`
+	}{
+		Type:     "AccessorProperty",
+		Key:      estree(n.Key),
+		Computed: n.Computed,
+		Value:    estree(n.Value),
+		Static:   n.Static,
+	}
+}
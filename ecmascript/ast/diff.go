@@ -0,0 +1,432 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// EditKind is the kind of change that an Edit describes.
+type EditKind int
+
+const (
+	// InsertEdit is a node of the new tree that is not in the old tree.
+	InsertEdit EditKind = iota
+
+	// RemoveEdit is a node of the old tree that is not in the new tree.
+	RemoveEdit
+
+	// MoveEdit is a node that is in both trees, but under a different
+	// parent, or in a different order among its siblings.
+	MoveEdit
+
+	// UpdateEdit is a node that is in both trees with different values of
+	// its own, such as an identifier that was renamed. Its children are
+	// compared on their own.
+	UpdateEdit
+)
+
+var editKindNames = map[EditKind]string{
+	InsertEdit: "insert",
+	RemoveEdit: "remove",
+	MoveEdit:   "move",
+	UpdateEdit: "update",
+}
+
+func (k EditKind) String() string {
+	if s, ok := editKindNames[k]; ok {
+		return s
+	}
+	return fmt.Sprintf("EditKind(%d)", int(k))
+}
+
+// Edit is a change to one node of a tree. The spans of the nodes locate the
+// change in the old and new source.
+type Edit struct {
+	Kind EditKind
+
+	// Old is the node in the old tree, or nil for an insertion.
+	Old Node
+
+	// New is the node in the new tree, or nil for a removal.
+	New Node
+}
+
+// Diff returns the edits that turn the tree old into the tree new. Nodes are
+// compared by their types, values and children, but not their spans, so
+// that trees parsed from different versions of a source can be compared,
+// as can a tree and the result of transforming it.
+//
+// Each node of one tree is paired with at most one node of the other.
+// Identical subtrees are paired first, largest first, and then nodes whose
+// children are mostly paired with each other's, and lastly the unpaired
+// children of paired nodes that have the same type. An edit covers its
+// whole subtree: the children of a removed, inserted or moved node are only
+// listed when they change in another way. Removals are listed first, in the
+// order of the old tree, followed by the other edits in the order of the new
+// tree.
+//
+// Diff does not find the smallest possible list of edits, which is too
+// costly to compute for trees of the size of programs.
+func Diff(old, new Node) []Edit {
+	d := differ{classes: map[string]int{}}
+	a, b := d.index(old, nil), d.index(new, nil)
+	if a == nil || b == nil {
+		var edits []Edit
+		if a != nil {
+			edits = append(edits, Edit{Kind: RemoveEdit, Old: old})
+		}
+		if b != nil {
+			edits = append(edits, Edit{Kind: InsertEdit, New: new})
+		}
+		return edits
+	}
+	d.matchIdentical(a, b)
+	d.matchSimilar(a, b)
+	d.recover(a)
+	return d.edits(a, b)
+}
+
+// diffNode is a node of a tree being compared.
+type diffNode struct {
+	node     Node
+	parent   *diffNode
+	children []*diffNode
+
+	// label holds the type of the node and the values of its own fields,
+	// which do not include its children.
+	label string
+
+	// class is the same for two nodes when their subtrees are identical.
+	class int
+
+	// size is the number of nodes in the subtree.
+	size int
+
+	// index is the position of the node among the children of its parent.
+	index int
+
+	// match is the node of the other tree paired with this one.
+	match *diffNode
+}
+
+// preorder returns the nodes of the subtree rooted at n, in the order of
+// Inspect.
+func (n *diffNode) preorder() []*diffNode {
+	nodes := []*diffNode{n}
+	for _, c := range n.children {
+		nodes = append(nodes, c.preorder()...)
+	}
+	return nodes
+}
+
+type differ struct {
+	// classes interns the labels and child classes of subtrees.
+	classes map[string]int
+}
+
+// index builds the diffNode tree for n.
+func (d *differ) index(n Node, parent *diffNode) *diffNode {
+	if n == nil {
+		return nil
+	}
+	v := reflect.ValueOf(n)
+	var label strings.Builder
+	label.WriteString(v.Type().String())
+	nodeLabel(v, &label, nil)
+	dn := &diffNode{node: n, parent: parent, label: label.String(), size: 1}
+	forEachChild(v, func(c Node) {
+		child := d.index(c, dn)
+		child.index = len(dn.children)
+		dn.children = append(dn.children, child)
+		dn.size += child.size
+	})
+
+	// The key of the class is the label with the classes of the children
+	// written where they are, so that it tells apart children that are
+	// in different fields.
+	var key strings.Builder
+	key.WriteString(v.Type().String())
+	i := 0
+	nodeLabel(v, &key, func(b *strings.Builder) {
+		fmt.Fprintf(b, "<%d>", dn.children[i].class)
+		i++
+	})
+	class, ok := d.classes[key.String()]
+	if !ok {
+		class = len(d.classes)
+		d.classes[key.String()] = class
+	}
+	dn.class = class
+	return dn
+}
+
+var (
+	spanType     = reflect.TypeOf(Span{})
+	locationType = reflect.TypeOf(Location{})
+	commentType  = reflect.TypeOf(Comment{})
+)
+
+// nodeLabel writes the values of the fields of the node or helper structure
+// stored in v, other than source positions. Child nodes are written by child,
+// or left out if it is nil; a missing node is always written.
+func nodeLabel(v reflect.Value, b *strings.Builder, child func(b *strings.Builder)) {
+	for i, n := 0, v.NumField(); i < n; i++ {
+		if f := v.Type().Field(i); f.PkgPath != "" || f.Type == baseNodeType {
+			continue
+		}
+		fieldLabel(v.Field(i), b, child)
+	}
+}
+
+// fieldLabel writes the values stored in v, without descending into nodes.
+func fieldLabel(v reflect.Value, b *strings.Builder, child func(b *strings.Builder)) {
+	if (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr) && v.IsNil() {
+		b.WriteString("nil;")
+		return
+	}
+	switch t := v.Type(); {
+	case t == spanType || t == locationType || t == commentType:
+		return
+	case t.Implements(nodeType):
+		if child != nil {
+			child(b)
+		}
+		return
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		fieldLabel(v.Elem(), b, child)
+	case reflect.Struct:
+		b.WriteString("{")
+		nodeLabel(v, b, child)
+		b.WriteString("}")
+	case reflect.Array, reflect.Slice:
+		b.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			fieldLabel(v.Index(i), b, child)
+		}
+		b.WriteString("]")
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String:
+		fmt.Fprintf(b, "%q;", fmt.Sprint(v.Interface()))
+	}
+}
+
+// pair pairs a with b.
+func pair(a, b *diffNode) {
+	a.match, b.match = b, a
+}
+
+// matchIdentical pairs the identical subtrees of the two trees, from the
+// largest down. Single nodes are left to later steps, since there are
+// usually too many of them that look alike, such as every use of a name.
+// When a subtree appears more than once, the copy whose parent matches best
+// is chosen.
+func (d *differ) matchIdentical(a, b *diffNode) {
+	olds := map[int][]*diffNode{}
+	for _, n := range a.preorder() {
+		if n.size > 1 {
+			olds[n.class] = append(olds[n.class], n)
+		}
+	}
+	news := b.preorder()
+	sort.SliceStable(news, func(i, j int) bool { return news[i].size > news[j].size })
+
+	for _, n := range news {
+		if n.size == 1 {
+			break
+		}
+		if n.match != nil {
+			continue
+		}
+
+		// A subtree is only paired whole, so if the root of a candidate is
+		// unpaired, so is the rest of it.
+		var best *diffNode
+		bestScore := -1
+		for _, o := range olds[n.class] {
+			if o.match != nil {
+				continue
+			}
+			score := 0
+			if o.parent != nil && n.parent != nil {
+				if o.parent.match == n.parent {
+					score = 2
+				} else if o.parent.label == n.parent.label {
+					score = 1
+				}
+			}
+			if score > bestScore {
+				best, bestScore = o, score
+			}
+		}
+		if best != nil {
+			oldNodes, newNodes := best.preorder(), n.preorder()
+			for i := range oldNodes {
+				pair(oldNodes[i], newNodes[i])
+			}
+		}
+	}
+}
+
+// sameType returns whether a and b hold nodes of the same type.
+func sameType(a, b *diffNode) bool {
+	return reflect.TypeOf(a.node) == reflect.TypeOf(b.node)
+}
+
+// matchSimilar pairs the nodes of the old tree with nodes of the same type
+// in the new tree when at least half of their descendants are paired with
+// each other, from the leaves up. The roots are paired if they are of the
+// same type.
+func (d *differ) matchSimilar(a, b *diffNode) {
+	var visit func(o *diffNode)
+	visit = func(o *diffNode) {
+		for _, c := range o.children {
+			visit(c)
+		}
+		if o.match != nil || len(o.children) == 0 {
+			return
+		}
+
+		// Count the paired descendants of o under each candidate.
+		common := map[*diffNode]int{}
+		var candidates []*diffNode
+		for _, desc := range o.preorder()[1:] {
+			if desc.match == nil {
+				continue
+			}
+			for n := desc.match.parent; n != nil; n = n.parent {
+				if n.match == nil && sameType(o, n) {
+					if common[n] == 0 {
+						candidates = append(candidates, n)
+					}
+					common[n]++
+				}
+			}
+		}
+		var best *diffNode
+		bestScore := 0.5
+		for _, n := range candidates {
+			score := 2 * float64(common[n]) / float64(o.size-1+n.size-1)
+			if score > bestScore || score == bestScore && best != nil && n.size < best.size {
+				best, bestScore = n, score
+			}
+		}
+		if best != nil {
+			pair(o, best)
+		}
+	}
+	visit(a)
+	if a.match == nil && b.match == nil && sameType(a, b) {
+		pair(a, b)
+	}
+}
+
+// recover pairs the unpaired children of paired nodes, first those with the
+// same label and then those of the same type, in order.
+func (d *differ) recover(o *diffNode) {
+	if n := o.match; n != nil {
+		for _, same := range []func(a, b *diffNode) bool{
+			func(a, b *diffNode) bool { return a.label == b.label },
+			sameType,
+		} {
+			j := 0
+			for _, oc := range o.children {
+				if oc.match != nil {
+					continue
+				}
+				for k := j; k < len(n.children); k++ {
+					if nc := n.children[k]; nc.match == nil && same(oc, nc) {
+						pair(oc, nc)
+						j = k + 1
+						break
+					}
+				}
+			}
+		}
+	}
+	for _, c := range o.children {
+		d.recover(c)
+	}
+}
+
+// edits lists the edits between the paired trees.
+func (d *differ) edits(a, b *diffNode) []Edit {
+	var edits []Edit
+	for _, o := range a.preorder() {
+		if o.match == nil && (o.parent == nil || o.parent.match != nil) {
+			edits = append(edits, Edit{Kind: RemoveEdit, Old: o.node})
+		}
+	}
+
+	// A child that keeps its parent is moved if it is not among the
+	// longest run of such children that keep their order.
+	moved := map[*diffNode]bool{}
+	for _, n := range b.preorder() {
+		var kept []*diffNode
+		for _, c := range n.children {
+			if c.match != nil && n.match != nil && c.match.parent == n.match {
+				kept = append(kept, c)
+			}
+		}
+		for _, c := range outOfOrder(kept) {
+			moved[c] = true
+		}
+	}
+
+	for _, n := range b.preorder() {
+		switch {
+		case n.match == nil:
+			if n.parent == nil || n.parent.match != nil {
+				edits = append(edits, Edit{Kind: InsertEdit, New: n.node})
+			}
+			continue
+		case n.parent != nil && (n.parent.match == nil || n.match.parent != n.parent.match || moved[n]):
+			edits = append(edits, Edit{Kind: MoveEdit, Old: n.match.node, New: n.node})
+		}
+		if n.label != n.match.label {
+			edits = append(edits, Edit{Kind: UpdateEdit, Old: n.match.node, New: n.node})
+		}
+	}
+	return edits
+}
+
+// outOfOrder returns the nodes of the new tree that are not part of the
+// longest subsequence of nodes whose matches are in the same order.
+func outOfOrder(nodes []*diffNode) []*diffNode {
+	if len(nodes) < 2 {
+		return nil
+	}
+
+	// tails[k] is the index of the node that ends the increasing
+	// subsequence of length k+1 with the smallest last match, and prev[i]
+	// is the node before i in the subsequence that i ends.
+	var tails []int
+	prev := make([]int, len(nodes))
+	for i, n := range nodes {
+		k := sort.Search(len(tails), func(k int) bool { return nodes[tails[k]].match.index >= n.match.index })
+		prev[i] = -1
+		if k > 0 {
+			prev[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+	inOrder := map[int]bool{}
+	for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+		inOrder[i] = true
+	}
+	var out []*diffNode
+	for i, n := range nodes {
+		if !inOrder[i] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
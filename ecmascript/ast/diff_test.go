@@ -0,0 +1,42 @@
+package ast_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		old, new string
+		expected []string
+	}{
+		{old: "f(a); g(b);", new: "f(a);\ng(b);", expected: nil},
+		{old: "f(a);", new: "f(a); g(b);", expected: []string{"insert ast.ExpressionStatement 1:7"}},
+		{old: "f(a); g(b);", new: "g(b);", expected: []string{"remove ast.ExpressionStatement 1:1"}},
+		{old: "f(a); g(b); h(c);", new: "h(c); f(a); g(b);", expected: []string{"move ast.ExpressionStatement 1:13-1:1"}},
+		{old: "f(a, b);", new: "f(a, c);", expected: []string{"update ast.Identifier 1:6-1:6"}},
+		{old: "f(a);", new: "if (x) { f(a); }", expected: []string{"insert ast.IfStatement 1:1", "move ast.ExpressionStatement 1:1-1:10"}},
+		{old: "var x = 1;", new: "let x = 1;", expected: []string{"update ast.VariableDeclaration 1:1-1:1"}},
+	}
+	for _, test := range tests {
+		var result []string
+		for _, e := range ast.Diff(parse(t, test.old), parse(t, test.new)) {
+			var s string
+			switch {
+			case e.Old == nil:
+				s = fmt.Sprintf("%s %T %d:%d", e.Kind, e.New, e.New.Span().Start.Row, e.New.Span().Start.Column)
+			case e.New == nil:
+				s = fmt.Sprintf("%s %T %d:%d", e.Kind, e.Old, e.Old.Span().Start.Row, e.Old.Span().Start.Column)
+			default:
+				s = fmt.Sprintf("%s %T %d:%d-%d:%d", e.Kind, e.New, e.Old.Span().Start.Row, e.Old.Span().Start.Column, e.New.Span().Start.Row, e.New.Span().Start.Column)
+			}
+			result = append(result, s)
+		}
+		if diff := cmp.Diff(test.expected, result); diff != "" {
+			t.Errorf("%q -> %q: edits mismatch (-expected +result):\n%s", test.old, test.new, diff)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package ast
 
+import "strconv"
+
 // estreeIdent returns an identifier node with the given string. Our AST does
 // not use Identifier nodes in cases where it is unambiguous, so this function
 // is useful for converting to estree.
@@ -16,6 +18,29 @@ func estreeIdent(ident string) interface{} {
 	}
 }
 
+// estreeString returns a string literal node with the given value. This is used
+// for module specifiers, which are stored as plain strings.
+func estreeString(value string) interface{} {
+	return struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+		Raw   string `json:"raw"`
+	}{
+		Type:  "Literal",
+		Value: value,
+		Raw:   strconv.Quote(value),
+	}
+}
+
+// estreeModuleName returns an identifier, or a string literal if the name
+// was quoted, for a name imported from or exported by a module.
+func estreeModuleName(name string, quoted bool) interface{} {
+	if quoted {
+		return estreeString(name)
+	}
+	return estreeIdent(name)
+}
+
 // estree returns the result of calling the ESTree method if the node is
 // non-nil, or nil otherwise. This is useful since nil nodes may appear in many
 // different structures.
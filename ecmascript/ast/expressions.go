@@ -25,7 +25,8 @@ func (n ArrayExpression) ESTree() interface{} {
 // children.
 func (n ArrayExpression) ContainsTemporalNodes() bool {
 	for _, elem := range n.Elements {
-		if elem.ContainsTemporalNodes() {
+		// Elem is nil for holes.
+		if elem != nil && elem.ContainsTemporalNodes() {
 			return true
 		}
 	}
@@ -101,10 +102,12 @@ func (n FormalParameters) ESTree() interface{} {
 	return e
 }
 
-// FunctionExpression stores a function expression.
+// FunctionExpression stores a function expression. The name is a string
+// rather than a node, so its span is kept in IDSpan.
 type FunctionExpression struct {
 	BaseNode
 	ID         string
+	IDSpan     Span
 	Params     FormalParameters
 	Body       Node
 	Generator  bool
@@ -179,6 +182,42 @@ func (n ThisExpression) ESTree() interface{} {
 	}
 }
 
+// Super is a node for the ECMAScript `super` keyword. It only appears as the
+// callee of a call expression or the object of a member expression.
+type Super struct {
+	BaseNode
+}
+
+// ESTree returns the corresponding ESTree representation for this node.
+func (n Super) ESTree() interface{} {
+	return struct {
+		Type string `json:"type"`
+	}{
+		Type: "Super",
+	}
+}
+
+// MetaProperty is a node for an ECMAScript meta property, such as
+// `new.target`.
+type MetaProperty struct {
+	BaseNode
+	Meta     string
+	Property string
+}
+
+// ESTree returns the corresponding ESTree representation for this node.
+func (n MetaProperty) ESTree() interface{} {
+	return struct {
+		Type     string      `json:"type"`
+		Meta     interface{} `json:"meta"`
+		Property interface{} `json:"property"`
+	}{
+		Type:     "MetaProperty",
+		Meta:     estreeIdent(n.Meta),
+		Property: estreeIdent(n.Property),
+	}
+}
+
 // MemberExpression is a node for an ECMAScript member expression.
 type MemberExpression struct {
 	BaseNode
@@ -288,6 +327,28 @@ func (n NewExpression) ESTree() interface{} {
 	return e
 }
 
+// TaggedTemplateExpression is a node containing a template literal with a
+// tag, a function that is called with its quasis and the values of its
+// expressions, e.g. String.raw`a\nb`.
+type TaggedTemplateExpression struct {
+	BaseNode
+	Tag   Node
+	Quasi TemplateLiteral
+}
+
+// ESTree returns the corresponding ESTree representation for this node.
+func (n TaggedTemplateExpression) ESTree() interface{} {
+	return struct {
+		Type  string      `json:"type"`
+		Tag   interface{} `json:"tag"`
+		Quasi interface{} `json:"quasi"`
+	}{
+		Type:  "TaggedTemplateExpression",
+		Tag:   estree(n.Tag),
+		Quasi: n.Quasi.ESTree(),
+	}
+}
+
 // PropertyKind is an enumeration type for different kinds of properties.
 type PropertyKind int
 
@@ -303,6 +364,10 @@ const (
 	// SetProperty is the kind of a setter property, which provides a setter
 	// function to handle values being written to the property key.
 	SetProperty
+
+	// SpreadProperty is the kind of a spread property, which copies the
+	// properties of its value (e.g. {...a}). It has no key.
+	SpreadProperty
 )
 
 // estreePropertyKindMap maps PropertyKind values to their corresponding ESTree
@@ -313,6 +378,12 @@ var estreePropertyKindMap = map[PropertyKind]string{
 	SetProperty:  "set",
 }
 
+// ESTree returns the kind as it is written in the `kind` field of an ESTree
+// node.
+func (k PropertyKind) ESTree() string {
+	return estreePropertyKindMap[k]
+}
+
 // Property stores a single property value in an object expression.
 type Property struct {
 	// Key specifies a property key. In the non-computed cases (e.g. {a: 1}),
@@ -347,6 +418,9 @@ type Property struct {
 
 // ESTree returns the corresponding ESTree representation for this node.
 func (n Property) ESTree() interface{} {
+	if n.Kind == SpreadProperty {
+		return SpreadElement{Argument: n.Value}.ESTree()
+	}
 	k := estree(n.Key)
 	v, shorthand := estree(n.Value), false
 	if v == nil {
@@ -396,7 +470,9 @@ func (n ObjectExpression) ESTree() interface{} {
 // children.
 func (n ObjectExpression) ContainsTemporalNodes() bool {
 	for _, prop := range n.Properties {
-		if prop.Key.ContainsTemporalNodes() || prop.Value.ContainsTemporalNodes() {
+		// Key is nil for spread properties and Value is nil for shorthand
+		// properties.
+		if (prop.Key != nil && prop.Key.ContainsTemporalNodes()) || (prop.Value != nil && prop.Value.ContainsTemporalNodes()) {
 			return true
 		}
 	}
@@ -450,6 +526,28 @@ func (n SequenceExpression) ContainsTemporalNodes() bool {
 	return false
 }
 
+// YieldExpression is a node containing a yield expression, which may only
+// appear in a generator. Argument is nil for a bare `yield`. Delegate is set
+// for `yield*`, which yields each value of an iterable in turn.
+type YieldExpression struct {
+	BaseNode
+	Argument Node
+	Delegate bool
+}
+
+// ESTree returns the corresponding ESTree representation for this node.
+func (n YieldExpression) ESTree() interface{} {
+	return struct {
+		Type     string      `json:"type"`
+		Argument interface{} `json:"argument"`
+		Delegate bool        `json:"delegate"`
+	}{
+		Type:     "YieldExpression",
+		Argument: estree(n.Argument),
+		Delegate: n.Delegate,
+	}
+}
+
 // ClassExpression is the AST node that corresponds to an ECMAscript
 // class expression.
 //
@@ -476,7 +574,7 @@ func (n ClassExpression) ESTree() interface{} {
 	e := struct {
 		Type       string      `json:"type"`
 		ID         interface{} `json:"id"`
-		SuperClass interface{} `json:"params"`
+		SuperClass interface{} `json:"superClass"`
 		Body       struct {
 			Type string        `json:"type"`
 			Body []interface{} `json:"body"`
@@ -488,6 +586,7 @@ func (n ClassExpression) ESTree() interface{} {
 	}
 
 	e.Body.Type = "ClassBody"
+	e.Body.Body = []interface{}{}
 	for _, elem := range n.Body {
 		e.Body.Body = append(e.Body.Body, estree(elem))
 	}
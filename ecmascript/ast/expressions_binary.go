@@ -118,6 +118,12 @@ var estreeBinaryOpMap = map[BinaryOperator]string{
 	BinaryCoalesceOp:         "??",
 }
 
+// ESTree returns the operator as it is written in the `operator` field of
+// an ESTree node.
+func (op BinaryOperator) ESTree() string {
+	return estreeBinaryOpMap[op]
+}
+
 // AssignmentOperator is an enumeration type for ECMAScript assignment
 // operators.
 type AssignmentOperator int
@@ -208,6 +214,12 @@ var estreeAssignOpMap = map[AssignmentOperator]string{
 	AssignmentCoalesceOp:       "??=",
 }
 
+// ESTree returns the operator as it is written in the `operator` field of
+// an ESTree node.
+func (op AssignmentOperator) ESTree() string {
+	return estreeAssignOpMap[op]
+}
+
 // BinaryExpression is a node for an ECMAScript binary expression statement.
 //
 // For example:
@@ -232,7 +244,7 @@ type BinaryExpression struct {
 // ESTree returns the corresponding ESTree representation for this node.
 func (n BinaryExpression) ESTree() interface{} {
 	nodeType := "BinaryExpression"
-	if n.Operator == BinaryLogicalAndOp || n.Operator == BinaryLogicalOrOp {
+	if n.Operator == BinaryLogicalAndOp || n.Operator == BinaryLogicalOrOp || n.Operator == BinaryCoalesceOp {
 		nodeType = "LogicalExpression"
 	}
 
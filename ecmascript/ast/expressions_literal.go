@@ -162,3 +162,77 @@ func (n RegExpLiteral) ESTree() interface{} {
 		},
 	}
 }
+
+// TemplateLiteral is a node containing an ECMAScript template literal. Its
+// quasis are the text before, between and after its expressions, so it has
+// one more quasi than it has expressions.
+//
+// For example:
+//
+//     `a${b}\n`
+//
+// Would be represented as:
+//
+//     TemplateLiteral{
+//         Quasis: []TemplateElement{
+//             {Raw: "a", Cooked: "a"},
+//             {Raw: "\\n", Cooked: "\n"},
+//         },
+//         Expressions: []Node{Identifier{Name: "b"}},
+//     }
+type TemplateLiteral struct {
+	BaseNode
+	Quasis      []TemplateElement
+	Expressions []Node
+}
+
+// TemplateElement is the text of a template literal before, between or
+// after its expressions. It is not a node, so that the children of a
+// TemplateLiteral are only its expressions, but it has a span of its own,
+// which does not include the delimiters around it.
+type TemplateElement struct {
+	// Raw is the text as written, with each \r\n or \r written as \n.
+	Raw string
+
+	// Cooked is the text with its escape sequences decoded. A tagged
+	// template may have invalid escape sequences, which leave it empty and
+	// set Invalid.
+	Cooked  string
+	Invalid bool
+
+	Span Span
+}
+
+// ESTree returns the corresponding ESTree representation for this node.
+func (n TemplateLiteral) ESTree() interface{} {
+	type value struct {
+		Raw    string  `json:"raw"`
+		Cooked *string `json:"cooked"`
+	}
+	type element struct {
+		Type  string `json:"type"`
+		Value value  `json:"value"`
+		Tail  bool   `json:"tail"`
+	}
+	e := struct {
+		Type        string        `json:"type"`
+		Quasis      []interface{} `json:"quasis"`
+		Expressions []interface{} `json:"expressions"`
+	}{
+		Type:        "TemplateLiteral",
+		Quasis:      []interface{}{},
+		Expressions: []interface{}{},
+	}
+	for i, q := range n.Quasis {
+		v := value{Raw: q.Raw}
+		if !q.Invalid {
+			cooked := q.Cooked
+			v.Cooked = &cooked
+		}
+		e.Quasis = append(e.Quasis, element{Type: "TemplateElement", Value: v, Tail: i == len(n.Quasis)-1})
+	}
+	for _, expr := range n.Expressions {
+		e.Expressions = append(e.Expressions, estree(expr))
+	}
+	return e
+}
@@ -35,6 +35,17 @@ var estreeUpdateOpPrefixMap = map[UpdateOperator]bool{
 	UpdatePostDecrementOp: false,
 }
 
+// ESTree returns the operator as it is written in the `operator` field of
+// an ESTree node.
+func (op UpdateOperator) ESTree() string {
+	return estreeUpdateOpMap[op]
+}
+
+// Prefix returns whether the operator is written before its argument.
+func (op UpdateOperator) Prefix() bool {
+	return estreeUpdateOpPrefixMap[op]
+}
+
 // UnaryOperator is an enumeration type for ECMAScript unary operators.
 type UnaryOperator int
 
@@ -86,6 +97,12 @@ var estreeUnaryOpPrefixMap = map[UnaryOperator]bool{
 	UnaryNotOp:    true,
 }
 
+// ESTree returns the operator as it is written in the `operator` field of
+// an ESTree node.
+func (op UnaryOperator) ESTree() string {
+	return estreeUnaryOpMap[op]
+}
+
 // UpdateExpression is the node for an ECMAScript update expression statement.
 type UpdateExpression struct {
 	BaseNode
@@ -131,3 +148,22 @@ func (n UnaryExpression) ESTree() interface{} {
 		Prefix:   estreeUnaryOpPrefixMap[n.Operator],
 	}
 }
+
+// AwaitExpression is the AST node for an await expression, which may only
+// appear in an async function. It binds as tightly as a unary operator.
+type AwaitExpression struct {
+	BaseNode
+
+	Argument Node
+}
+
+// ESTree returns the corresponding ESTree representation for this node.
+func (n AwaitExpression) ESTree() interface{} {
+	return struct {
+		Type     string      `json:"type"`
+		Argument interface{} `json:"argument"`
+	}{
+		Type:     "AwaitExpression",
+		Argument: estree(n.Argument),
+	}
+}
@@ -0,0 +1,54 @@
+package ast
+
+import "reflect"
+
+// contains returns whether loc is in the span, which includes its start but
+// not its end. The URIs of the locations are not compared.
+func (s Span) contains(loc Location) bool {
+	return s.Start.Row != 0 && before(s.Start, loc) && !before(s.End, loc)
+}
+
+// FindPath returns the nodes under root whose spans contain loc, from root
+// down to the innermost one, or nil if the span of root does not contain loc.
+// A span contains the locations from its start up to, but not including, its
+// end. Nodes without a span, such as those built by transforms, are skipped
+// along with their children. Binding names are strings rather than nodes, so
+// the path to a location inside one ends at the declaration or pattern that
+// holds it.
+func FindPath(root Node, loc Location) []Node {
+	if root == nil || !root.Span().contains(loc) {
+		return nil
+	}
+	path := []Node{root}
+	for {
+		var next Node
+		forEachChild(reflect.ValueOf(path[len(path)-1]), func(c Node) {
+			if next == nil && c.Span().contains(loc) {
+				next = c
+			}
+		})
+		if next == nil {
+			return path
+		}
+		path = append(path, next)
+	}
+}
+
+// FindNodeAt returns the innermost node under root whose span contains loc,
+// or nil if there is none.
+func FindNodeAt(root Node, loc Location) Node {
+	path := FindPath(root, loc)
+	if len(path) == 0 {
+		return nil
+	}
+	return path[len(path)-1]
+}
+
+// LocationAt returns the location of a byte offset in src, for looking up
+// positions given as offsets. Offsets past the end of src are at its end.
+func LocationAt(src string, offset int) Location {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	return Location{Row: 1, Column: 1}.Advance(src[:offset])
+}
@@ -0,0 +1,128 @@
+package ast_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+func parse(t *testing.T, src string) ast.Node {
+	t.Helper()
+	n, err := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(src), nil))).Parse(parser.ParseOptions{Mode: parser.ScriptMode})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestFindPath(t *testing.T) {
+	tests := []struct {
+		src      string
+		offset   int
+		expected []string
+	}{
+		// Nested nodes.
+		{"f(a + b);", 6, []string{"ast.ScriptNode", "ast.ExpressionStatement", "ast.CallExpression", "ast.BinaryExpression", "ast.Identifier"}},
+		{"function f() { return [1, g(x)]; }", 28, []string{"ast.ScriptNode", "ast.FunctionDeclaration", "ast.BlockStatement", "ast.ReturnStatement", "ast.ArrayExpression", "ast.CallExpression", "ast.Identifier"}},
+		{"if (a) {\n  b = c;\n}", 15, []string{"ast.ScriptNode", "ast.IfStatement", "ast.BlockStatement", "ast.ExpressionStatement", "ast.AssignmentExpression", "ast.Identifier"}},
+
+		// A span includes its start but not its end.
+		{"f(a + b);", 0, []string{"ast.ScriptNode", "ast.ExpressionStatement", "ast.CallExpression", "ast.Identifier"}},
+		{"f(a + b);", 2, []string{"ast.ScriptNode", "ast.ExpressionStatement", "ast.CallExpression", "ast.BinaryExpression", "ast.Identifier"}},
+		{"f(a + b);", 3, []string{"ast.ScriptNode", "ast.ExpressionStatement", "ast.CallExpression", "ast.BinaryExpression"}},
+		{"f(a + b);", 7, []string{"ast.ScriptNode", "ast.ExpressionStatement", "ast.CallExpression"}},
+		{"f(a);\ng(b);", 5, []string{"ast.ScriptNode"}},
+		{"f(a);\ng(b);", 6, []string{"ast.ScriptNode", "ast.ExpressionStatement", "ast.CallExpression", "ast.Identifier"}},
+
+		// Binding names are not nodes.
+		{"var x = 1;", 4, []string{"ast.ScriptNode", "ast.VariableDeclaration"}},
+
+		// Past the end of the program.
+		{"f(a);", 5, nil},
+		{"f(a);", 100, nil},
+	}
+	for _, test := range tests {
+		var result []string
+		for _, n := range ast.FindPath(parse(t, test.src), ast.LocationAt(test.src, test.offset)) {
+			result = append(result, fmt.Sprintf("%T", n))
+		}
+		if diff := cmp.Diff(test.expected, result); diff != "" {
+			t.Errorf("%q at %d: path mismatch (-expected +result):\n%s", test.src, test.offset, diff)
+		}
+	}
+}
+
+func TestFindNodeAt(t *testing.T) {
+	tests := []struct {
+		src      string
+		offset   int
+		expected string
+	}{
+		{"f(alpha, beta);", 9, "beta"},
+		{"f(alpha, beta);", 12, "beta"},
+		{"f(alpha, beta);", 2, "alpha"},
+		{"f(alpha, beta);", 0, "f"},
+		{"x = {a: [y, z]};", 12, "z"},
+	}
+	for _, test := range tests {
+		n := ast.FindNodeAt(parse(t, test.src), ast.LocationAt(test.src, test.offset))
+		id, ok := n.(ast.Identifier)
+		if !ok {
+			t.Errorf("%q at %d: expected identifier, got %T", test.src, test.offset, n)
+			continue
+		}
+		if id.Name != test.expected {
+			t.Errorf("%q at %d: expected %q, got %q", test.src, test.offset, test.expected, id.Name)
+		}
+	}
+
+	src := "f(a);"
+	if n := ast.FindNodeAt(parse(t, src), ast.LocationAt(src, 10)); n != nil {
+		t.Errorf("expected no node past the end, got %T", n)
+	}
+	if n := ast.FindNodeAt(nil, ast.Location{Row: 1, Column: 1}); n != nil {
+		t.Errorf("expected no node in a nil tree, got %T", n)
+	}
+	if path := ast.FindPath(nil, ast.Location{Row: 1, Column: 1}); path != nil {
+		t.Errorf("expected no path in a nil tree, got %d nodes", len(path))
+	}
+}
+
+func TestLocationAt(t *testing.T) {
+	tests := []struct {
+		src      string
+		offset   int
+		expected ast.Location
+	}{
+		{"ab\ncd", 0, ast.Location{Row: 1, Column: 1}},
+		{"ab\ncd", 2, ast.Location{Row: 1, Column: 3}},
+		{"ab\ncd", 3, ast.Location{Row: 2, Column: 1}},
+		{"ab\ncd", 5, ast.Location{Row: 2, Column: 3}},
+		{"ab\ncd", 100, ast.Location{Row: 2, Column: 3}},
+		{"é = 1", 3, ast.Location{Row: 1, Column: 3}},
+		{"a b", 4, ast.Location{Row: 2, Column: 1}},
+	}
+	for _, test := range tests {
+		if result := ast.LocationAt(test.src, test.offset); result != test.expected {
+			t.Errorf("%q at %d: expected %d:%d, got %d:%d", test.src, test.offset, test.expected.Row, test.expected.Column, result.Row, result.Column)
+		}
+	}
+}
+
+func TestOffsets(t *testing.T) {
+	at := func(row, col int) ast.Location { return ast.Location{Row: row, Column: col} }
+	src := "é = 1;\r\nb;"
+	locs := []ast.Location{at(3, 2), at(1, 1), at(1, 2), at(2, 1), at(3, 1), at(1, 2), at(9, 1)}
+	expected := []int{10, 0, 2, 8, 9, 2, 11}
+	if diff := cmp.Diff(expected, at(1, 1).Offsets(src, locs)); diff != "" {
+		t.Errorf("offsets mismatch (-expected +result):\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{0, 2}, at(4, 5).Offsets("ab", []ast.Location{at(4, 5), at(4, 7)})); diff != "" {
+		t.Errorf("offsets mismatch (-expected +result):\n%s", diff)
+	}
+}
@@ -3,6 +3,7 @@ package ast
 import (
 	"fmt"
 	"net/url"
+	"sort"
 )
 
 // Location represents a single source location.
@@ -22,6 +23,52 @@ func (l Location) Span() Span {
 	return Span{l, l}
 }
 
+// Advance returns the location after text, which starts at l. Rows and
+// columns are counted as the lexer counts them: each line terminator,
+// including each half of \r\n, starts a row, and columns count code points.
+func (l Location) Advance(text string) Location {
+	for _, r := range text {
+		switch r {
+		case '\u000a', '\u000d', '\u2028', '\u2029':
+			l.Row++
+			l.Column = 1
+		default:
+			l.Column++
+		}
+	}
+	return l
+}
+
+// Offsets returns the byte offset of each of locs in text, which starts at l,
+// counting rows and columns as Advance does. Locations past the end of the
+// text are at its end.
+func (l Location) Offsets(text string, locs []Location) []int {
+	order := make([]int, len(locs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := locs[order[i]], locs[order[j]]
+		return a.Row < b.Row || a.Row == b.Row && a.Column < b.Column
+	})
+
+	offs, n := make([]int, len(locs)), 0
+	for i, r := range text {
+		for n < len(order) && before(locs[order[n]], l) {
+			offs[order[n]] = i
+			n++
+		}
+		if n == len(order) {
+			return offs
+		}
+		l = l.Advance(string(r))
+	}
+	for ; n < len(order); n++ {
+		offs[order[n]] = len(text)
+	}
+	return offs
+}
+
 // String returns a string representing the source location.
 func (l *Location) String() string {
 	return fmt.Sprintf("%s:%d:%d", l.URI, l.Row, l.Column)
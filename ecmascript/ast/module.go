@@ -4,6 +4,10 @@ package ast
 type ModuleNode struct {
 	BaseNode
 	Body []Node
+
+	// Comments are the comments in the module, if the parser was asked to keep
+	// them.
+	Comments []Comment
 }
 
 // ESTree returns the corresponding ESTree representation for this node.
@@ -12,9 +16,12 @@ func (n ModuleNode) ESTree() interface{} {
 		Type       string        `json:"type"`
 		Body       []interface{} `json:"body"`
 		SourceType string        `json:"sourceType"`
+		Comments   []interface{} `json:"comments,omitempty"`
 	}{
 		Type:       "Program",
+		Body:       []interface{}{},
 		SourceType: "module",
+		Comments:   estreeComments(n.Comments),
 	}
 	for _, stmt := range n.Body {
 		e.Body = append(e.Body, estree(stmt))
@@ -22,6 +29,25 @@ func (n ModuleNode) ESTree() interface{} {
 	return e
 }
 
+// ModuleExpression is the AST node for a module block, an experimental
+// expression whose value is a module made from the items in its body, e.g.
+// let m = module { export let a = 1; };
+type ModuleExpression struct {
+	BaseNode
+	Body []Node
+}
+
+// ESTree returns the corresponding ESTree representation for this node.
+func (n ModuleExpression) ESTree() interface{} {
+	return struct {
+		Type string      `json:"type"`
+		Body interface{} `json:"body"`
+	}{
+		Type: "ModuleExpression",
+		Body: ModuleNode{Body: n.Body}.ESTree(),
+	}
+}
+
 // ImportDeclNode is the AST node for an import declaration.
 type ImportDeclNode struct {
 	BaseNode
@@ -51,11 +77,50 @@ type ImportDeclNode struct {
 
 	// Module to import; string literal.
 	Module string
+
+	// Source is set for an experimental source phase import, which imports
+	// the source of a module rather than its instance, e.g.
+	// import source wasm from "./a.wasm";
+	Source bool
 }
 
 // ESTree returns the corresponding ESTree representation for this node.
 func (n ImportDeclNode) ESTree() interface{} {
-	panic("unimplemented")
+	e := struct {
+		Type       string        `json:"type"`
+		Specifiers []interface{} `json:"specifiers"`
+		Source     interface{}   `json:"source"`
+		Phase      string        `json:"phase,omitempty"`
+	}{
+		Type:       "ImportDeclaration",
+		Specifiers: []interface{}{},
+		Source:     estreeString(n.Module),
+	}
+	if n.Source {
+		e.Phase = "source"
+	}
+	if n.DefaultBinding != nil {
+		e.Specifiers = append(e.Specifiers, struct {
+			Type  string      `json:"type"`
+			Local interface{} `json:"local"`
+		}{
+			Type:  "ImportDefaultSpecifier",
+			Local: estreeIdent(n.DefaultBinding.Identifier),
+		})
+	}
+	if n.NameSpace != nil {
+		e.Specifiers = append(e.Specifiers, struct {
+			Type  string      `json:"type"`
+			Local interface{} `json:"local"`
+		}{
+			Type:  "ImportNamespaceSpecifier",
+			Local: estreeIdent(n.NameSpace.Identifier),
+		})
+	}
+	for _, i := range n.NamedImports {
+		e.Specifiers = append(e.Specifiers, i.ESTree())
+	}
+	return e
 }
 
 // ImportDefaultBinding contains the default import identifier.
@@ -72,4 +137,159 @@ type NameSpaceImport struct {
 type NamedImport struct {
 	Identifier string
 	AsBinding  string
+
+	// Quoted is set if Identifier was written as a string literal, e.g.
+	// import {"a-b" as ab} from "m";
+	Quoted bool
+}
+
+// Binding returns the name of the local binding created by the import.
+func (n NamedImport) Binding() string {
+	if n.AsBinding != "" {
+		return n.AsBinding
+	}
+	return n.Identifier
+}
+
+// ESTree returns the corresponding ESTree representation for this node.
+func (n NamedImport) ESTree() interface{} {
+	return struct {
+		Type     string      `json:"type"`
+		Local    interface{} `json:"local"`
+		Imported interface{} `json:"imported"`
+	}{
+		Type:     "ImportSpecifier",
+		Local:    estreeIdent(n.Binding()),
+		Imported: estreeModuleName(n.Identifier, n.Quoted),
+	}
+}
+
+// ExportDeclNode is the AST node for an export declaration.
+type ExportDeclNode struct {
+	BaseNode
+
+	// Possible combinations:
+	// - Declaration:
+	//       export const version = "17.0.2";
+	//       export function render() {}
+	// - Default:
+	//       export default function () {}
+	//       export default React;
+	// - NameSpace + Module:
+	//       export * from "react";
+	//       export * as React from "react";
+	// - NamedExports:
+	//       export {render, version as VERSION};
+	// - NamedExports + Module:
+	//       export {Component as ReactComponent} from "react";
+
+	// Exported declaration, e.g. export const a = 1;
+	Declaration Node
+
+	// Default export; either a function or class declaration, possibly with
+	// no name, or an expression.
+	Default Node
+
+	// Namespace re-export, e.g. export * from "react";
+	NameSpace *NameSpaceExport
+
+	// Named exports, e.g. export {render as default};
+	NamedExports []NamedExport
+
+	// Module to re-export from; string literal. Empty if not re-exporting.
+	Module string
+}
+
+// ESTree returns the corresponding ESTree representation for this node.
+func (n ExportDeclNode) ESTree() interface{} {
+	var source interface{}
+	if n.Module != "" {
+		source = estreeString(n.Module)
+	}
+	switch {
+	case n.Default != nil:
+		return struct {
+			Type        string      `json:"type"`
+			Declaration interface{} `json:"declaration"`
+		}{
+			Type:        "ExportDefaultDeclaration",
+			Declaration: n.Default.ESTree(),
+		}
+	case n.NameSpace != nil:
+		return struct {
+			Type     string      `json:"type"`
+			Source   interface{} `json:"source"`
+			Exported interface{} `json:"exported"`
+		}{
+			Type:     "ExportAllDeclaration",
+			Source:   source,
+			Exported: estreeModuleName(n.NameSpace.Identifier, n.NameSpace.Quoted),
+		}
+	}
+	e := struct {
+		Type        string        `json:"type"`
+		Declaration interface{}   `json:"declaration"`
+		Specifiers  []interface{} `json:"specifiers"`
+		Source      interface{}   `json:"source"`
+	}{
+		Type:        "ExportNamedDeclaration",
+		Declaration: estree(n.Declaration),
+		Specifiers:  []interface{}{},
+		Source:      source,
+	}
+	for _, x := range n.NamedExports {
+		e.Specifiers = append(e.Specifiers, x.ESTree())
+	}
+	return e
+}
+
+// NameSpaceExport contains the namespace export identifier, if any.
+type NameSpaceExport struct {
+	Identifier string
+
+	// Quoted is set if Identifier was written as a string literal, e.g.
+	// export * as "a-b" from "m";
+	Quoted bool
+}
+
+// NamedExport contains an individual named export.
+type NamedExport struct {
+	Identifier string
+	AsBinding  string
+
+	// Quoted and AsQuoted are set if Identifier and AsBinding were written as
+	// string literals, e.g. export {"a-b" as "c-d"} from "m"; Identifier may
+	// only be quoted when re-exporting from another module.
+	Quoted   bool
+	AsQuoted bool
+}
+
+// ExportedName returns the name the binding is exported as.
+func (n NamedExport) ExportedName() string {
+	if n.AsBinding != "" {
+		return n.AsBinding
+	}
+	return n.Identifier
+}
+
+// exportedQuoted returns whether the exported name was written as a string
+// literal.
+func (n NamedExport) exportedQuoted() bool {
+	if n.AsBinding != "" {
+		return n.AsQuoted
+	}
+	return n.Quoted
+}
+
+// ESTree returns the corresponding ESTree representation for this node.
+func (n NamedExport) ESTree() interface{} {
+	return struct {
+		Type     string      `json:"type"`
+		Local    interface{} `json:"local"`
+		Exported interface{} `json:"exported"`
+	}{
+		Type:     "ExportSpecifier",
+		Local:    estreeModuleName(n.Identifier, n.Quoted),
+		Exported: estreeModuleName(n.ExportedName(), n.exportedQuoted()),
+	}
 }
@@ -4,6 +4,10 @@ package ast
 type ScriptNode struct {
 	BaseNode
 	Body []Node
+
+	// Comments are the comments in the script, if the parser was asked to keep
+	// them.
+	Comments []Comment
 }
 
 // ESTree returns the corresponding ESTree representation for this node.
@@ -12,9 +16,12 @@ func (n ScriptNode) ESTree() interface{} {
 		Type       string        `json:"type"`
 		Body       []interface{} `json:"body"`
 		SourceType string        `json:"sourceType"`
+		Comments   []interface{} `json:"comments,omitempty"`
 	}{
 		Type:       "Program",
+		Body:       []interface{}{},
 		SourceType: "script",
+		Comments:   estreeComments(n.Comments),
 	}
 	for _, stmt := range n.Body {
 		e.Body = append(e.Body, estree(stmt))
@@ -0,0 +1,118 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// SpanError is a node whose span breaks one of the invariants checked by
+// CheckSpans.
+type SpanError struct {
+	Node    Node
+	Message string
+}
+
+func (e SpanError) Error() string {
+	s := e.Node.Span()
+	return fmt.Sprintf("%T at %d:%d-%d:%d: %s", e.Node, s.Start.Row, s.Start.Column, s.End.Row, s.End.Column, e.Message)
+}
+
+// before returns whether a is at or before b.
+func before(a, b Location) bool {
+	return a.Row < b.Row || a.Row == b.Row && a.Column <= b.Column
+}
+
+// CheckSpans checks the spans of the tree under n, which must have been
+// parsed from source rather than built, and returns an error for each node
+// where:
+//
+//   - the span is missing, or ends before it starts;
+//   - the span is not within the span of its parent;
+//   - the span starts before the end of the sibling before it.
+//
+// Siblings are taken in the order Inspect visits them. Nodes with a missing
+// span are reported, but not compared with their parent or siblings.
+func CheckSpans(n Node) []SpanError {
+	var errs []SpanError
+	var check func(n Node)
+	check = func(n Node) {
+		s := n.Span()
+		valid := s.Start.Row != 0 && s.End.Row != 0
+		switch {
+		case !valid:
+			errs = append(errs, SpanError{n, "missing span"})
+		case !before(s.Start, s.End):
+			errs = append(errs, SpanError{n, "ends before it starts"})
+			valid = false
+		}
+		var prev Node
+		forEachChild(reflect.ValueOf(n), func(c Node) {
+			cs := c.Span()
+			if valid && cs.Start.Row != 0 && cs.End.Row != 0 {
+				if !before(s.Start, cs.Start) || !before(cs.End, s.End) {
+					errs = append(errs, SpanError{c, fmt.Sprintf("outside of its parent %T", n)})
+				} else if prev != nil && !before(prev.Span().End, cs.Start) {
+					errs = append(errs, SpanError{c, fmt.Sprintf("starts before the end of the %T before it", prev)})
+				}
+			}
+			if cs.Start.Row != 0 && cs.End.Row != 0 {
+				prev = c
+			}
+			check(c)
+		})
+	}
+	if n != nil {
+		check(n)
+	}
+	return errs
+}
+
+// MapLocations returns a copy of the tree under n where the start and end of
+// the span of each node, each template element and each function name have
+// been replaced by the result of f. Spans that are missing are passed to f as
+// well.
+func MapLocations(n Node, f func(Location) Location) Node {
+	if n == nil {
+		return nil
+	}
+	n = MapChildren(n, func(c Node) Node {
+		return MapLocations(c, f)
+	})
+	v := reflect.ValueOf(n)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	c := reflect.New(v.Type())
+	c.Elem().Set(v)
+	if s, ok := c.Interface().(spanSetter); ok {
+		span := n.Span()
+		s.SetStart(f(span.Start))
+		s.SetEnd(f(span.End))
+	}
+	// The elements of a template literal are not nodes, but have spans.
+	if t, ok := c.Interface().(*TemplateLiteral); ok {
+		quasis := make([]TemplateElement, len(t.Quasis))
+		for i, q := range t.Quasis {
+			q.Span = Span{Start: f(q.Span.Start), End: f(q.Span.End)}
+			quasis[i] = q
+		}
+		t.Quasis = quasis
+	}
+	// Neither are the names of functions.
+	switch t := c.Interface().(type) {
+	case *FunctionDeclaration:
+		t.IDSpan = Span{Start: f(t.IDSpan.Start), End: f(t.IDSpan.End)}
+	case *FunctionExpression:
+		t.IDSpan = Span{Start: f(t.IDSpan.Start), End: f(t.IDSpan.End)}
+	}
+	if reflect.ValueOf(n).Kind() == reflect.Ptr {
+		return c.Interface().(Node)
+	}
+	return c.Elem().Interface().(Node)
+}
+
+// spanSetter is implemented by pointers to nodes that embed BaseNode.
+type spanSetter interface {
+	SetStart(Location)
+	SetEnd(Location)
+}
@@ -79,6 +79,12 @@ var estreeVarKindMap = map[VarKind]string{
 	ConstDeclaration: "const",
 }
 
+// ESTree returns the kind as it is written in the `kind` field of an ESTree
+// node.
+func (k VarKind) ESTree() string {
+	return estreeVarKindMap[k]
+}
+
 // VariableDeclaration is the AST node for a variable declaration statement.
 type VariableDeclaration struct {
 	BaseNode
@@ -150,6 +156,33 @@ func (n BindingPattern) ESTree() interface{} {
 	return nil
 }
 
+// BoundNames returns the names of all of the bindings in the pattern, in
+// source order.
+func (n BindingPattern) BoundNames() []string {
+	names := []string{}
+	switch {
+	case n.ObjectPattern != nil:
+		for _, p := range n.ObjectPattern.Properties {
+			if p.Value.Identifier == "" && p.Value.ObjectPattern == nil && p.Value.ArrayPattern == nil {
+				names = append(names, p.PropertyName)
+			} else {
+				names = append(names, p.Value.BoundNames()...)
+			}
+		}
+		if n.ObjectPattern.RestElement != "" {
+			names = append(names, n.ObjectPattern.RestElement)
+		}
+	case n.ArrayPattern != nil:
+		for _, e := range n.ArrayPattern.Elements {
+			names = append(names, e.Value.BoundNames()...)
+		}
+		names = append(names, n.ArrayPattern.RestElement.BoundNames()...)
+	case n.Identifier != "":
+		names = append(names, n.Identifier)
+	}
+	return names
+}
+
 // ObjectBindingPattern contains a full object binding pattern.
 type ObjectBindingPattern struct {
 	Properties []BindingProperty
@@ -222,6 +255,11 @@ type BindingProperty struct {
 	// BindingIdentifier.
 	PropertyName string
 
+	// Key is set instead of PropertyName when the property key is computed
+	// (e.g. {[a]: b}), or is a string or numeric literal.
+	Key      Node
+	Computed bool
+
 	// Only one of BindingIdentifier and BindingPattern can be set.
 	// - none: { PropertyName = Initializer }
 	// - BindingIdentifier: { PropertyName: BindingIdentifier = Initializer }
@@ -235,10 +273,14 @@ type BindingProperty struct {
 // ESTree returns the corresponding ESTree representation for this node.
 func (n BindingProperty) ESTree() interface{} {
 	k := estreeIdent(n.PropertyName)
-	v, shorthand := n.Value.ESTree(), false
-	if v == nil {
-		v, shorthand = k, true
+	if n.Key != nil {
+		k = n.Key.ESTree()
 	}
+	value, shorthand := n.Value, false
+	if value.ESTree() == nil {
+		value, shorthand = BindingPattern{Identifier: n.PropertyName}, true
+	}
+	v := BindingElement{Value: value, Init: n.Init}.ESTree()
 	return struct {
 		Type      string      `json:"type"`
 		Key       interface{} `json:"key"`
@@ -250,7 +292,7 @@ func (n BindingProperty) ESTree() interface{} {
 	}{
 		Type:      "Property",
 		Key:       k,
-		Computed:  false, // TODO?
+		Computed:  n.Computed,
 		Value:     v,
 		Kind:      "init",
 		Method:    false,
@@ -355,6 +397,26 @@ func (n ThrowStatement) ESTree() interface{} {
 	}
 }
 
+// WithStatement is a node containing a with statement.
+type WithStatement struct {
+	BaseNode
+	Object Node
+	Body   Node
+}
+
+// ESTree returns the corresponding ESTree representation for this node.
+func (n WithStatement) ESTree() interface{} {
+	return struct {
+		Type   string      `json:"type"`
+		Object interface{} `json:"object"`
+		Body   interface{} `json:"body"`
+	}{
+		Type:   "WithStatement",
+		Object: estree(n.Object),
+		Body:   estree(n.Body),
+	}
+}
+
 // IfStatement is a node containing an if statement.
 type IfStatement struct {
 	BaseNode
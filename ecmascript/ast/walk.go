@@ -0,0 +1,186 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var (
+	nodeType     = reflect.TypeOf((*Node)(nil)).Elem()
+	baseNodeType = reflect.TypeOf(BaseNode{})
+)
+
+// Inspect traverses an AST in depth-first order. It starts by calling f(n);
+// if f returns true, Inspect is called recursively for each of the non-nil
+// children of n.
+//
+// Children are any nodes reachable from n without passing through another
+// node, including nodes nested inside of helper structures such as
+// BindingElement or Property.
+func Inspect(n Node, f func(Node) bool) {
+	if n == nil || !f(n) {
+		return
+	}
+	forEachChild(reflect.ValueOf(n), func(c Node) {
+		Inspect(c, f)
+	})
+}
+
+// MapChildren returns a copy of n where each non-nil child node c has been
+// replaced by the result of f(c). The node itself is not passed to f, and the
+// original node is left untouched. This makes it possible to write rewriting
+// passes as a type switch that falls back to MapChildren for any node it does
+// not care about.
+//
+// Some fields hold a concrete node type rather than Node, e.g. the body of a
+// FunctionDeclaration. MapChildren panics if f returns a node that can not be
+// stored in such a field.
+func MapChildren(n Node, f func(Node) Node) Node {
+	if n == nil {
+		return nil
+	}
+	return mapContainer(reflect.ValueOf(n), f).Interface().(Node)
+}
+
+// forEachChild calls fn for each child node of the node stored in v.
+func forEachChild(v reflect.Value, fn func(Node)) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	for i, n := 0, v.NumField(); i < n; i++ {
+		if f := v.Type().Field(i); f.PkgPath != "" || f.Type == baseNodeType {
+			continue
+		}
+		forEachNode(v.Field(i), fn)
+	}
+}
+
+// forEachNode calls fn for each node stored in v, without recursing into the
+// nodes themselves.
+func forEachNode(v reflect.Value, fn func(Node)) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		if n, ok := v.Interface().(Node); ok {
+			fn(n)
+		}
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		if v.Type().Implements(nodeType) {
+			fn(v.Interface().(Node))
+			return
+		}
+		forEachChild(v, fn)
+
+	case reflect.Struct:
+		if v.Type().Implements(nodeType) {
+			fn(v.Interface().(Node))
+			return
+		}
+		forEachChild(v, fn)
+
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			forEachNode(v.Index(i), fn)
+		}
+	}
+}
+
+// mapContainer returns a copy of the structure stored in v with all of the
+// nodes inside of it mapped through f.
+func mapContainer(v reflect.Value, f func(Node) Node) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(mapContainer(v.Elem(), f))
+		return p
+
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i, n := 0, v.NumField(); i < n; i++ {
+			if f := v.Type().Field(i); f.PkgPath != "" || f.Type == baseNodeType {
+				continue
+			}
+			c.Field(i).Set(mapValue(v.Field(i), f))
+		}
+		return c
+	}
+	return v
+}
+
+// mapValue maps the nodes stored in v through f.
+func mapValue(v reflect.Value, f func(Node) Node) reflect.Value {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		n, ok := v.Interface().(Node)
+		if !ok {
+			return v
+		}
+		return replacement(v.Type(), f(n))
+
+	case reflect.Ptr, reflect.Struct:
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return v
+		}
+		if v.Type().Implements(nodeType) {
+			return replacement(v.Type(), f(v.Interface().(Node)))
+		}
+		return mapContainer(v, f)
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s.Index(i).Set(mapValue(v.Index(i), f))
+		}
+		return s
+
+	case reflect.Array:
+		a := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			a.Index(i).Set(mapValue(v.Index(i), f))
+		}
+		return a
+	}
+	return v
+}
+
+// replacement converts a node returned from a mapping function into a value
+// that can be stored in a field of type t.
+func replacement(t reflect.Type, n Node) reflect.Value {
+	if n == nil {
+		return reflect.Zero(t)
+	}
+	r := reflect.ValueOf(n)
+	switch {
+	case r.Type().AssignableTo(t):
+		return r
+	case r.Kind() == reflect.Ptr && r.Type().Elem() == t:
+		return r.Elem()
+	case t.Kind() == reflect.Ptr && t.Elem() == r.Type():
+		p := reflect.New(r.Type())
+		p.Elem().Set(r)
+		return p
+	}
+	panic(fmt.Errorf("ast: can not replace %s with %T", t, n))
+}
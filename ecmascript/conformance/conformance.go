@@ -0,0 +1,261 @@
+// Package conformance runs the test262 conformance suite against the parser,
+// and compares the results with a list of tests that are known to fail, so
+// that changes in conformance show up as failures.
+package conformance
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+// Outcome is the result of a test, compared with what was expected.
+type Outcome int
+
+const (
+	// Pass is a test that passed, as expected.
+	Pass Outcome = iota
+
+	// Fail is a test that failed, but was expected to pass.
+	Fail
+
+	// ExpectedFail is a test that failed, and is known to.
+	ExpectedFail
+
+	// UnexpectedPass is a test that passed, but is known to fail.
+	UnexpectedPass
+
+	// Skip is a test that was not run.
+	Skip
+)
+
+// Result is the result of running a test.
+type Result struct {
+	// Name is the path of the test in the test directory, with slashes,
+	// e.g. "language/expressions/class/name.js".
+	Name    string
+	Outcome Outcome
+
+	// Reason says why a test failed or was skipped.
+	Reason string
+}
+
+// Options controls how tests are run.
+type Options struct {
+	// Expected is the set of names of tests that are known to fail.
+	Expected map[string]bool
+
+	// SkipFeatures are the test262 features whose tests are skipped.
+	SkipFeatures []string
+
+	// Timeout is the time limit for parsing a single test, or 0 for none.
+	Timeout time.Duration
+
+	// Workers is the number of tests to run at once, or 0 for one per CPU.
+	Workers int
+}
+
+// scenario is one way of parsing a test.
+type scenario struct {
+	name string
+	mode parser.ParseMode
+	text string
+}
+
+// scenarios returns the ways a test is run, following the test262
+// interpreting guide: tests without flags are run both as sloppy and strict
+// mode code.
+func scenarios(text string, m *metadata) []scenario {
+	strict := scenario{"strict mode", parser.ScriptMode, "\"use strict\";\n" + text}
+	sloppy := scenario{"non-strict mode", parser.ScriptMode, text}
+	switch {
+	case m.hasFlag("module"):
+		return []scenario{{"module", parser.ModuleMode, text}}
+	case m.hasFlag("raw"), m.hasFlag("noStrict"):
+		return []scenario{sloppy}
+	case m.hasFlag("onlyStrict"):
+		return []scenario{strict}
+	}
+	return []scenario{sloppy, strict}
+}
+
+// parse parses a scenario of the test in path, giving up after the timeout.
+func parse(path string, s scenario, timeout time.Duration) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	uri := &url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	p := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(s.text), uri)))
+	_, err := p.ParseContext(ctx, parser.ParseOptions{Mode: s.mode})
+	return err
+}
+
+// run runs the test in path, returning whether it passed and why not. Only
+// parsing is checked: tests expected to fail at parse time must not parse,
+// and every other test must.
+func run(path string, skipped map[string]bool, timeout time.Duration) (Outcome, string) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Fail, err.Error()
+	}
+	text := string(data)
+	m, err := parseMetadata(text)
+	if err != nil {
+		return Fail, err.Error()
+	}
+	for _, f := range m.Features {
+		if skipped[f] {
+			return Skip, "uses " + f
+		}
+	}
+
+	negative := m.NegativePhase == "parse" || m.NegativePhase == "early"
+	for _, s := range scenarios(text, m) {
+		err := parse(path, s, timeout)
+		switch {
+		case negative && err == nil:
+			return Fail, fmt.Sprintf("%s: expected %s, parsed successfully", s.name, m.NegativeType)
+		case !negative && err != nil:
+			return Fail, fmt.Sprintf("%s: %v", s.name, err)
+		}
+	}
+	return Pass, ""
+}
+
+// findTests returns the tests under root, skipping fixture files which are
+// only imported by other tests.
+func findTests(root string) ([]string, error) {
+	var tests []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && filepath.Ext(path) == ".js" && !strings.Contains(filepath.Base(path), "_FIXTURE") {
+			tests = append(tests, path)
+		}
+		return nil
+	})
+	return tests, err
+}
+
+// Run runs the tests under the given paths of the test directory of a
+// test262 checkout, or all of them if there are none, returning the results
+// sorted by name.
+func Run(checkout string, paths []string, opt Options) ([]Result, error) {
+	testDir := filepath.Join(checkout, "test")
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	var tests []string
+	for _, root := range paths {
+		found, err := findTests(filepath.Join(testDir, root))
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, found...)
+	}
+
+	skipped := map[string]bool{}
+	for _, f := range opt.SkipFeatures {
+		skipped[f] = true
+	}
+	workers := opt.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([]Result, len(tests))
+	next := make(chan int)
+	done := make(chan struct{})
+	go func() {
+		for i := range tests {
+			next <- i
+		}
+		close(next)
+	}()
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range next {
+				name, err := filepath.Rel(testDir, tests[i])
+				if err != nil {
+					name = tests[i]
+				}
+				name = filepath.ToSlash(name)
+				o, reason := run(tests[i], skipped, opt.Timeout)
+				switch {
+				case o == Fail && opt.Expected[name]:
+					o = ExpectedFail
+				case o == Pass && opt.Expected[name]:
+					o = UnexpectedPass
+				}
+				results[i] = Result{name, o, reason}
+				done <- struct{}{}
+			}
+		}()
+	}
+	for range tests {
+		<-done
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results, nil
+}
+
+// Count returns the number of results with each outcome.
+func Count(results []Result) map[Outcome]int {
+	counts := map[Outcome]int{}
+	for _, r := range results {
+		counts[r.Outcome]++
+	}
+	return counts
+}
+
+// ReadExpectations reads a list of names of tests that are known to fail, one
+// per line, ignoring blank lines and comments starting with #.
+func ReadExpectations(path string) (map[string]bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			names[line] = true
+		}
+	}
+	return names, nil
+}
+
+// WriteExpectations writes the names of the tests that failed to path, in
+// the format read by ReadExpectations.
+func WriteExpectations(path string, results []Result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	b := bufio.NewWriter(f)
+	b.WriteString("# Tests that are known to fail. Regenerate with -update.\n")
+	for _, r := range results {
+		if r.Outcome == Fail || r.Outcome == ExpectedFail {
+			b.WriteString(r.Name + "\n")
+		}
+	}
+	if err := b.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
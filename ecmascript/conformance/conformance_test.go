@@ -0,0 +1,138 @@
+package conformance
+
+import (
+	"flag"
+	"os"
+	"reflect"
+	"testing"
+)
+
+var (
+	test262 = flag.String("test262", "", "`path` of a test262 checkout to run TestTest262 against")
+	update  = flag.Bool("update", false, "rewrite testdata/test262.expected-failures with the tests that failed")
+)
+
+func TestParseMetadata(t *testing.T) {
+	text := `// Copyright header
+/*---
+esid: sec-addition-operator-plus
+description: >
+  A description: on more than one line.
+flags: [onlyStrict, "module"]
+features:
+  - BigInt
+  - 'class-fields-public'
+negative:
+  phase: parse
+  type: SyntaxError
+---*/
+1 + 1;
+`
+	m, err := parseMetadata(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &metadata{
+		Flags:         []string{"onlyStrict", "module"},
+		Features:      []string{"BigInt", "class-fields-public"},
+		NegativePhase: "parse",
+		NegativeType:  "SyntaxError",
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("expected %+v, got %+v", expected, m)
+	}
+
+	if _, err := parseMetadata("1 + 1;"); err != errNoMetadata {
+		t.Errorf("expected %v for a test without frontmatter, got %v", errNoMetadata, err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	results, err := Run("testdata/test262", nil, Options{
+		Expected: map[string]bool{
+			"language/expressions/addition.js":       true,
+			"language/expressions/with-statement.js": true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outcomes := map[string]Outcome{}
+	for _, r := range results {
+		outcomes[r.Name] = r.Outcome
+	}
+	expected := map[string]Outcome{
+		"language/expressions/addition.js":              UnexpectedPass,
+		"language/expressions/assignment-to-literal.js": Pass,
+		"language/expressions/with-statement.js":        ExpectedFail,
+		"language/module-code/import.js":                Pass,
+	}
+	if !reflect.DeepEqual(outcomes, expected) {
+		t.Errorf("expected %v, got %v", expected, outcomes)
+	}
+
+	results, err = Run("testdata/test262", []string{"language/expressions"}, Options{SkipFeatures: []string{"exponentiation"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := Count(results)
+	if expected := (map[Outcome]int{Pass: 1, Fail: 1, Skip: 1}); !reflect.DeepEqual(counts, expected) {
+		t.Errorf("expected %v, got %v", expected, counts)
+	}
+}
+
+func TestExpectations(t *testing.T) {
+	path := t.TempDir() + "/expected-failures"
+	results := []Result{
+		{"a.js", Pass, ""},
+		{"b.js", Fail, "strict mode: error"},
+		{"c.js", ExpectedFail, "strict mode: error"},
+		{"d.js", UnexpectedPass, ""},
+		{"e.js", Skip, "uses BigInt"},
+	}
+	if err := WriteExpectations(path, results); err != nil {
+		t.Fatal(err)
+	}
+	names, err := ReadExpectations(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (map[string]bool{"b.js": true, "c.js": true}); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+}
+
+// TestTest262 runs the whole suite from the checkout given with -test262,
+// failing if the results differ from testdata/test262.expected-failures.
+func TestTest262(t *testing.T) {
+	if *test262 == "" {
+		t.Skip("no test262 checkout; run with -test262 path")
+	}
+	const path = "testdata/test262.expected-failures"
+	expected, err := ReadExpectations(path)
+	if err != nil {
+		if !*update || !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+	}
+	results, err := Run(*test262, nil, Options{Expected: expected})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := WriteExpectations(path, results); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	for _, r := range results {
+		switch r.Outcome {
+		case Fail:
+			t.Errorf("%s: %s", r.Name, r.Reason)
+		case UnexpectedPass:
+			t.Errorf("%s: passed, but is listed in %s", r.Name, path)
+		}
+	}
+	counts := Count(results)
+	t.Logf("%d tests: %d passed, %d expected failures, %d skipped", len(results), counts[Pass], counts[ExpectedFail], counts[Skip])
+}
@@ -0,0 +1,146 @@
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// Difference is a difference between the ESTree of a node and a reference
+// ESTree, such as one from acorn.
+type Difference struct {
+	// Path is the property path where the trees differ, such as
+	// "Program.body[0].init".
+	Path    string
+	Message string
+}
+
+func (d Difference) String() string {
+	return d.Path + ": " + d.Message
+}
+
+// normalize decodes JSON, leaving out ignored properties.
+func normalize(data []byte, ignored map[string]bool) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	var strip func(v interface{})
+	strip = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, e := range v {
+				if ignored[k] {
+					delete(v, k)
+				} else {
+					strip(e)
+				}
+			}
+		case []interface{}:
+			for _, e := range v {
+				strip(e)
+			}
+		}
+	}
+	strip(v)
+	return v, nil
+}
+
+// show formats a JSON value briefly, for a difference.
+func show(v interface{}) string {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if t, ok := v["type"].(string); ok {
+			return t + " node"
+		}
+		return "object"
+	case []interface{}:
+		return fmt.Sprintf("array of %d", len(v))
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// diff appends the differences between the expected and actual values, under
+// the property path.
+func diff(ds []Difference, path string, expected, actual interface{}) []Difference {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			break
+		}
+		if et, at := e["type"], a["type"]; et != at {
+			// A different node entirely; its properties are not comparable.
+			return append(ds, Difference{path, fmt.Sprintf("expected %s, got %s", show(e), show(a))})
+		}
+		keys := []string{}
+		for k := range e {
+			keys = append(keys, k)
+		}
+		for k := range a {
+			if _, ok := e[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ev, eok := e[k]
+			av, aok := a[k]
+			switch {
+			case !aok:
+				ds = append(ds, Difference{path + "." + k, "missing, expected " + show(ev)})
+			case !eok:
+				ds = append(ds, Difference{path + "." + k, "unexpected " + show(av)})
+			default:
+				ds = diff(ds, path+"."+k, ev, av)
+			}
+		}
+		return ds
+
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(e) || i < len(a); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(a):
+				ds = append(ds, Difference{p, "missing, expected " + show(e[i])})
+			case i >= len(e):
+				ds = append(ds, Difference{p, "unexpected " + show(a[i])})
+			default:
+				ds = diff(ds, p, e[i], a[i])
+			}
+		}
+		return ds
+
+	default:
+		if expected == actual {
+			return ds
+		}
+	}
+	return append(ds, Difference{path, fmt.Sprintf("expected %s, got %s", show(expected), show(actual))})
+}
+
+// DiffESTree compares the ESTree of n with the reference ESTree JSON, leaving
+// out the ignored properties, such as the positions which only the reference
+// has. It returns the differences in property order.
+func DiffESTree(reference []byte, n ast.Node, ignored map[string]bool) ([]Difference, error) {
+	expected, err := normalize(reference, ignored)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+	data, err := json.Marshal(n.ESTree())
+	if err != nil {
+		return nil, err
+	}
+	actual, err := normalize(data, ignored)
+	if err != nil {
+		return nil, err
+	}
+	return diff(nil, "Program", expected, actual), nil
+}
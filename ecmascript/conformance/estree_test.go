@@ -0,0 +1,142 @@
+package conformance
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+// positions are the properties only the reference has.
+var positions = map[string]bool{"start": true, "end": true, "loc": true, "range": true}
+
+func TestDiffESTree(t *testing.T) {
+	n, err := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader("a = [1, b];"), nil))).Parse(parser.ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reference := `{
+		"type": "Program", "start": 0, "end": 11, "sourceType": "script",
+		"body": [{
+			"type": "ExpressionStatement",
+			"expression": {
+				"type": "AssignmentExpression", "operator": "=",
+				"left": {"type": "Identifier", "name": "a"},
+				"right": {"type": "ArrayExpression", "elements": [
+					{"type": "Literal", "value": 2, "raw": "2"},
+					{"type": "Identifier", "name": "b"},
+					{"type": "Identifier", "name": "c"}
+				]}
+			}
+		}]
+	}`
+	ds, err := DiffESTree([]byte(reference), n, positions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, d := range ds {
+		lines = append(lines, d.String())
+	}
+	expected := []string{
+		"Program.body[0].expression.right.elements[0].raw: expected \"2\", got \"1\"",
+		"Program.body[0].expression.right.elements[0].value: expected 2, got 1",
+		"Program.body[0].expression.right.elements[2]: missing, expected Identifier node",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %q, got %q", expected, lines)
+	}
+
+	if _, err := DiffESTree([]byte("{"), n, positions); err == nil {
+		t.Error("expected an error for an invalid reference")
+	}
+}
+
+// readDivergences reads the known differences from the reference, by fixture.
+func readDivergences(path string) (map[string]map[string]bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	divergences := map[string]map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("%s: expected fixture: path, got %q", path, line)
+		}
+		fixture, prop := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if divergences[fixture] == nil {
+			divergences[fixture] = map[string]bool{}
+		}
+		divergences[fixture][prop] = true
+	}
+	return divergences, nil
+}
+
+// TestESTree compares the ESTree of each fixture in testdata/estree with the
+// reference next to it, written by generate.cjs, failing on differences that
+// are not listed in testdata/estree/divergences, and on listed ones that are
+// gone.
+func TestESTree(t *testing.T) {
+	dir := filepath.Join("testdata", "estree")
+	divergences, err := readDivergences(filepath.Join(dir, "divergences"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixtures, err := filepath.Glob(filepath.Join(dir, "*.*js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range fixtures {
+		name, ext := filepath.Base(path), filepath.Ext(path)
+		if ext != ".js" && ext != ".mjs" {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			reference, err := ioutil.ReadFile(strings.TrimSuffix(path, ext) + ".json")
+			if os.IsNotExist(err) {
+				t.Skip("no reference; write one with generate.cjs")
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			src, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mode := parser.ScriptMode
+			if ext == ".mjs" {
+				mode = parser.ModuleMode
+			}
+			n, err := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(string(src)), nil))).Parse(parser.ParseOptions{Mode: mode})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ds, err := DiffESTree(reference, n, positions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen := map[string]bool{}
+			for _, d := range ds {
+				if divergences[name][d.Path] {
+					seen[d.Path] = true
+				} else {
+					t.Error(d)
+				}
+			}
+			for prop := range divergences[name] {
+				if !seen[prop] {
+					t.Errorf("%s: listed in divergences, but matches the reference", prop)
+				}
+			}
+		})
+	}
+}
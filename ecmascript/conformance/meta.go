@@ -0,0 +1,110 @@
+package conformance
+
+import (
+	"errors"
+	"strings"
+)
+
+// metadata is the frontmatter of a test262 test, between `/*---` and `---*/`.
+// It is YAML, but the tests only use a small part of it, so only that part is
+// understood here: scalars, lists in either style, and the negative mapping.
+type metadata struct {
+	Flags    []string
+	Features []string
+	Includes []string
+
+	// NegativePhase and NegativeType are set for tests that are expected to
+	// fail, e.g. "parse" and "SyntaxError".
+	NegativePhase string
+	NegativeType  string
+}
+
+// hasFlag returns whether the test has the given flag.
+func (m *metadata) hasFlag(flag string) bool {
+	for _, f := range m.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+var errNoMetadata = errors.New("no frontmatter")
+
+// parseMetadata finds and parses the frontmatter of a test.
+func parseMetadata(text string) (*metadata, error) {
+	start := strings.Index(text, "/*---")
+	if start < 0 {
+		return nil, errNoMetadata
+	}
+	end := strings.Index(text[start:], "---*/")
+	if end < 0 {
+		return nil, errNoMetadata
+	}
+	lines := strings.Split(text[start+len("/*---"):start+end], "\n")
+
+	m := &metadata{}
+	var list *[]string
+	parent := ""
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indented := len(trimmed) < len(line)
+
+		if indented && strings.HasPrefix(trimmed, "- ") {
+			// An item of a block list.
+			if list != nil {
+				*list = append(*list, unquote(strings.TrimSpace(trimmed[2:])))
+			}
+			continue
+		}
+
+		key, value := trimmed, ""
+		if i := strings.Index(trimmed, ":"); i >= 0 {
+			key, value = trimmed[:i], strings.TrimSpace(trimmed[i+1:])
+		}
+		if indented {
+			// A member of a mapping, or a line of a multi-line string.
+			if parent == "negative" {
+				switch key {
+				case "phase":
+					m.NegativePhase = unquote(value)
+				case "type":
+					m.NegativeType = unquote(value)
+				}
+			}
+			continue
+		}
+
+		parent, list = key, nil
+		switch key {
+		case "flags":
+			list = &m.Flags
+		case "features":
+			list = &m.Features
+		case "includes":
+			list = &m.Includes
+		default:
+			continue
+		}
+		if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = unquote(strings.TrimSpace(item)); item != "" {
+					*list = append(*list, item)
+				}
+			}
+		}
+	}
+	return m, nil
+}
+
+// unquote removes the quotes around a YAML scalar, if any.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
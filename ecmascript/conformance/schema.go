@@ -0,0 +1,258 @@
+package conformance
+
+import (
+	_ "embed" // for the schema
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// ESTreeSchema is a JSON Schema for the ESTree that the parser emits for a
+// script or module: every node type, and for each, every property it has and
+// the values the property may hold. It is written out in
+// estree.schema.json for other tools to use.
+//
+//go:embed estree.schema.json
+var ESTreeSchema []byte
+
+var (
+	schemaOnce sync.Once
+	schema     map[string]interface{}
+	schemaErr  error
+)
+
+// ValidateESTree checks the ESTree of n against ESTreeSchema. It returns a
+// difference for each property that does not conform, in property order.
+//
+// Only the parts of JSON Schema that ESTreeSchema uses are supported: $ref to
+// its $defs, type, const, enum, properties, required, additionalProperties,
+// items, minItems, maxItems and anyOf.
+func ValidateESTree(n ast.Node) ([]Difference, error) {
+	if err := loadSchema(); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(n.ESTree())
+	if err != nil {
+		return nil, err
+	}
+	return validateJSON(data)
+}
+
+// validateJSON checks an ESTree, as JSON, against ESTreeSchema.
+func validateJSON(data []byte) ([]Difference, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return validate(nil, "Program", v, schema), nil
+}
+
+// loadSchema decodes ESTreeSchema, the first time it is needed.
+func loadSchema() error {
+	schemaOnce.Do(func() {
+		schemaErr = json.Unmarshal(ESTreeSchema, &schema)
+	})
+	if schemaErr != nil {
+		return fmt.Errorf("invalid schema: %w", schemaErr)
+	}
+	return nil
+}
+
+// resolve follows the $ref in s, if any, to a definition.
+func resolve(s map[string]interface{}) map[string]interface{} {
+	for {
+		r, ok := s["$ref"].(string)
+		if !ok {
+			return s
+		}
+		name := strings.TrimPrefix(r, "#/$defs/")
+		s, _ = schema["$defs"].(map[string]interface{})[name].(map[string]interface{})
+		if s == nil {
+			panic(fmt.Sprintf("schema has no definition for %s", r))
+		}
+	}
+}
+
+// validate appends the differences between v and the schema s, under the
+// property path.
+func validate(ds []Difference, path string, v interface{}, s map[string]interface{}) []Difference {
+	s = resolve(s)
+	if alts, ok := s["anyOf"].([]interface{}); ok {
+		return validateAny(ds, path, v, alts)
+	}
+	if t, ok := s["type"]; ok && !hasType(v, t) {
+		return append(ds, Difference{path, fmt.Sprintf("expected %s, got %s", typeNames(t), show(v))})
+	}
+	if c, ok := s["const"]; ok && v != c {
+		return append(ds, Difference{path, fmt.Sprintf("expected %s, got %s", show(c), show(v))})
+	}
+	if e, ok := s["enum"].([]interface{}); ok && !contains(e, v) {
+		return append(ds, Difference{path, fmt.Sprintf("unexpected %s", show(v))})
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		props, _ := s["properties"].(map[string]interface{})
+		keys := []string{}
+		for k := range props {
+			keys = append(keys, k)
+		}
+		for k := range v {
+			if _, ok := props[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		required, _ := s["required"].([]interface{})
+		for _, k := range keys {
+			pv, ok := v[k]
+			ps, known := props[k].(map[string]interface{})
+			switch {
+			case !ok && contains(required, k):
+				ds = append(ds, Difference{path + "." + k, "missing"})
+			case !ok:
+			case !known && s["additionalProperties"] == false:
+				ds = append(ds, Difference{path + "." + k, "unexpected " + show(pv)})
+			case known:
+				ds = validate(ds, path+"."+k, pv, ps)
+			}
+		}
+
+	case []interface{}:
+		if min, ok := s["minItems"].(float64); ok && float64(len(v)) < min {
+			ds = append(ds, Difference{path, fmt.Sprintf("expected at least %v items, got %s", min, show(v))})
+		}
+		if max, ok := s["maxItems"].(float64); ok && float64(len(v)) > max {
+			ds = append(ds, Difference{path, fmt.Sprintf("expected at most %v items, got %s", max, show(v))})
+		}
+		if items, ok := s["items"].(map[string]interface{}); ok {
+			for i, e := range v {
+				ds = validate(ds, fmt.Sprintf("%s[%d]", path, i), e, items)
+			}
+		}
+	}
+	return ds
+}
+
+// validateAny appends the differences between v and the alternatives. A node
+// is checked only against the alternatives for its node type, and any other
+// value only against those that are not nodes, so that a tree is walked
+// about once.
+func validateAny(ds []Difference, path string, v interface{}, alts []interface{}) []Difference {
+	var flat []map[string]interface{}
+	var flatten func(alts []interface{})
+	flatten = func(alts []interface{}) {
+		for _, a := range alts {
+			s := resolve(a.(map[string]interface{}))
+			if inner, ok := s["anyOf"].([]interface{}); ok {
+				flatten(inner)
+				continue
+			}
+			for _, f := range flat {
+				if reflect.ValueOf(f).Pointer() == reflect.ValueOf(s).Pointer() {
+					s = nil
+					break
+				}
+			}
+			if s != nil {
+				flat = append(flat, s)
+			}
+		}
+	}
+	flatten(alts)
+
+	t, isNode := nodeType(v)
+	var first []Difference
+	tried := false
+	for _, s := range flat {
+		if nodeTypeOf(s) != t {
+			continue
+		}
+		alt := validate(nil, path, v, s)
+		if len(alt) == 0 {
+			return ds
+		}
+		if !tried {
+			first, tried = alt, true
+		}
+	}
+	if isNode && tried {
+		return append(ds, first...)
+	}
+	return append(ds, Difference{path, "unexpected " + show(v)})
+}
+
+// nodeType returns the type of v, if it is a node.
+func nodeType(v interface{}) (string, bool) {
+	o, ok := v.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	t, ok := o["type"].(string)
+	return t, ok
+}
+
+// nodeTypeOf returns the node type that the schema s is for, if any.
+func nodeTypeOf(s map[string]interface{}) string {
+	props, _ := s["properties"].(map[string]interface{})
+	t, _ := props["type"].(map[string]interface{})
+	c, _ := t["const"].(string)
+	return c
+}
+
+// hasType returns whether v is of the JSON Schema type t, or one of the
+// types in t.
+func hasType(v interface{}, t interface{}) bool {
+	if ts, ok := t.([]interface{}); ok {
+		for _, t := range ts {
+			if hasType(v, t) {
+				return true
+			}
+		}
+		return false
+	}
+	switch v := v.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case float64:
+		return t == "number" || t == "integer" && v == math.Trunc(v)
+	case []interface{}:
+		return t == "array"
+	case map[string]interface{}:
+		return t == "object"
+	}
+	return false
+}
+
+// typeNames formats the JSON Schema type t, or types in t, for a difference.
+func typeNames(t interface{}) string {
+	ts, ok := t.([]interface{})
+	if !ok {
+		return fmt.Sprint(t)
+	}
+	var names []string
+	for _, t := range ts {
+		names = append(names, fmt.Sprint(t))
+	}
+	return strings.Join(names, " or ")
+}
+
+// contains returns whether v is one of vs.
+func contains(vs []interface{}, v interface{}) bool {
+	for _, e := range vs {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package conformance
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+func validateSource(t *testing.T, name, src string, opt parser.ParseOptions) {
+	t.Helper()
+	n, err := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(src), nil))).Parse(opt)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	ds, err := ValidateESTree(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range ds {
+		t.Errorf("%s: %s", name, d)
+	}
+}
+
+func TestValidateESTree(t *testing.T) {
+	tests := []struct {
+		input string
+		opt   parser.ParseOptions
+	}{
+		{input: ``},
+		{input: `// line
+/* block */ a;`, opt: parser.ParseOptions{Comments: true}},
+		{input: `var a = 1, b = "b", c = true, d = null, e = /x/gi, f; let [g, , h = 1, ...i] = j; const {k, l: m = 2, ...n} = o;`},
+		{input: `a = [1, , ...b]; c = {d, e: 1, [f]: 2, get g() {}, set g(v) {}, h() {}, ...i};`},
+		{input: `a.b[c](d, ...e); new F; new G(h); this.i; new.target;`, opt: parser.ParseOptions{AllowNewTargetOutsideFunction: true}},
+		{input: `a = b ? c : d; e += f, g **= h; i = -j + !k * typeof l - void m; n++; --o; p = q ?? r && s || t; u = v in w instanceof x;`},
+		{input: `function f(a, b = 1, ...c) { return; } g = function* () {}; x = async function () {}; z = async (a) => a; w = () => {};`},
+		{input: `class A extends B { constructor() { super(); super.c(); } static d() {} get e() { return 1; } set e(v) {} [f]() {} } x = class {};`},
+		{input: `if (a) b; else { c; } for (;;) break; for (var d = 0; d < 1; d++) continue; for (e in f); for (const g of h); while (i) j; do k; while (l); m: n; switch (o) { case p: q; default: r; } try { throw s; } catch (t) {} finally {} try {} catch {} with (u) v;`},
+		{input: `import a from "a"; import * as b from "b"; import {c, d as e} from "c"; import "d"; export default 1; export var f = 1; export function g() {} export {a as h, b}; export * from "e"; export * as i from "f"; export {j} from "g";`, opt: parser.ParseOptions{Mode: parser.ModuleMode}},
+		{input: `export default function () {}`, opt: parser.ParseOptions{Mode: parser.ModuleMode}},
+		{input: `export default class {}`, opt: parser.ParseOptions{Mode: parser.ModuleMode}},
+		{input: `import source a from "a"; m = module { import b from "b"; export {b}; };`, opt: parser.ParseOptions{Mode: parser.ModuleMode, Experimental: parser.Experimental{ModuleBlocks: true, SourcePhaseImports: true}}},
+		{input: `class A { accessor a; static accessor [b] = 1; accessor() {} }`, opt: parser.ParseOptions{Experimental: parser.Experimental{Decorators: true}}},
+		{input: "a = `b${c}d${`e`}`; f`g\\u{h}${i}`; new j.k`l`;"},
+		{input: `function* a() { yield; yield b, c; d = yield* e; } f = { *g() {} }; class H { static *[i]() { yield yield j; } }`},
+		{input: `async function a() { await b; c = await d + 1; } e = async f => await f; g = { async h() {} }; class I { async *j() { await (yield); } }`},
+	}
+	for _, test := range tests {
+		validateSource(t, test.input, test.input, test.opt)
+	}
+}
+
+func TestValidateESTreeFixtures(t *testing.T) {
+	var paths []string
+	for _, pattern := range []string{
+		filepath.Join("testdata", "estree", "*.*js"),
+		filepath.Join("..", "parser", "testdata", "golden", "*.*js"),
+		filepath.Join("..", "parser", "testdata", "*.js"),
+	} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, matches...)
+	}
+	for _, path := range paths {
+		ext := filepath.Ext(path)
+		if ext != ".js" && ext != ".mjs" {
+			continue
+		}
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			src, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mode := parser.ScriptMode
+			if ext == ".mjs" {
+				mode = parser.ModuleMode
+			}
+			validateSource(t, path, string(src), parser.ParseOptions{Mode: mode})
+		})
+	}
+}
+
+func TestValidateInvalidESTree(t *testing.T) {
+	if err := loadSchema(); err != nil {
+		t.Fatal(err)
+	}
+	tree := `{
+		"type": "Program", "sourceType": "script", "extra": 1,
+		"body": [
+			{"type": "ExpressionStatement", "expression": {"type": "Identifier"}},
+			{"type": "ExpressionStatement", "expression": {"type": "Literal", "value": {}, "raw": "{}"}},
+			{"type": "VariableDeclaration", "kind": "static", "declarations": []},
+			{"type": "Identifier", "name": "a"}
+		]
+	}`
+	ds, err := validateJSON([]byte(tree))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, d := range ds {
+		lines = append(lines, d.String())
+	}
+	expected := []string{
+		"Program.body[0].expression.name: missing",
+		"Program.body[1].expression.value: expected string or number or boolean or null, got object",
+		"Program.body[2].kind: unexpected \"static\"",
+		"Program.body[3]: unexpected Identifier node",
+		"Program.extra: unexpected 1",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %q, got %q", expected, lines)
+	}
+}
@@ -0,0 +1,7 @@
+class A extends B {
+  constructor() { super(); }
+  static s() {}
+  get g() { return 1; }
+  set g(v) {}
+  ["computed"]() {}
+}
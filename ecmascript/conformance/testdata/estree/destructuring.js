@@ -0,0 +1,5 @@
+var {a, b: c, d = 1, ...e} = o;
+let [f, , g = 2, ...h] = xs;
+({a, b} = o);
+[a, b] = [b, a];
+var o = {a, b: 1, c() {}, get d() { return 1; }, ...e};
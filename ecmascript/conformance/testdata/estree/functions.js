@@ -0,0 +1,4 @@
+function f(a, b = 1, ...c) { return a; }
+const g = function () {};
+const h = (a, b) => a + b;
+const i = async x => x;
@@ -0,0 +1,26 @@
+// Writes the reference ESTree for each fixture in this directory, using
+// acorn, next to the fixture with a .json extension:
+//
+//     npm install acorn
+//     node generate.cjs
+//
+// Files ending in .mjs are parsed as modules, and files ending in .js as
+// scripts. TestESTree in ecmascript/conformance compares the parser's output
+// with them, as does `go run ./cmd/estreediff <this dir>`.
+const acorn = require("acorn");
+const fs = require("fs");
+const path = require("path");
+
+for (const name of fs.readdirSync(__dirname).sort()) {
+  const ext = path.extname(name);
+  if (ext !== ".js" && ext !== ".mjs") {
+    continue;
+  }
+  const file = path.join(__dirname, name);
+  const ast = acorn.parse(fs.readFileSync(file, "utf8"), {
+    ecmaVersion: "latest",
+    sourceType: ext === ".mjs" ? "module" : "script",
+  });
+  const out = file.slice(0, -ext.length) + ".json";
+  fs.writeFileSync(out, JSON.stringify(ast, null, 2) + "\n");
+}
@@ -0,0 +1 @@
+null; true; false; 1; 0x10; 1.5e3; "double"; 'single'; /re/g;
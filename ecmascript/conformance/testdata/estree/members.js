@@ -0,0 +1 @@
+a.b; a[b]; a.b.c(d, e); new A; new A(b);
@@ -0,0 +1,10 @@
+import a from "a";
+import * as b from "b";
+import {c, d as e} from "c";
+import "d";
+export const f = 1;
+export function g() {}
+export default class {}
+export {f as h};
+export * from "e";
+export {i} from "f";
@@ -0,0 +1,2 @@
+a + b * c; a && b || c; a ?? b; !a; typeof a; -a; ++a; a--;
+a = b; a += 1; a **= 2; a ? b : c; a, b;
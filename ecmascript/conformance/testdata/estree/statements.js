@@ -0,0 +1,10 @@
+if (a) b; else c;
+for (var i = 0; i < 10; i++) {}
+for (const k in o) {}
+for (let v of xs) {}
+while (a) break;
+do { continue; } while (a);
+switch (a) { case 1: b(); default: c(); }
+try { a(); } catch (e) { b(); } finally { c(); }
+label: for (;;) break label;
+throw a;
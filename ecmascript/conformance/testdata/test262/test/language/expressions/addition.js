@@ -0,0 +1,6 @@
+/*---
+description: Addition of two numbers
+features: [exponentiation]
+---*/
+
+var x = 1 + 2 ** 3;
@@ -0,0 +1,10 @@
+/*---
+description: A literal is not a valid assignment target
+negative:
+  phase: parse
+  type: SyntaxError
+---*/
+
+$DONOTEVALUATE();
+
+1 = 2;
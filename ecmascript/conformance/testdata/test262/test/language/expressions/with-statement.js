@@ -0,0 +1,5 @@
+/*---
+description: The with statement is not allowed in strict mode code, so this fails when run strictly
+---*/
+
+with ({}) {}
@@ -0,0 +1,6 @@
+/*---
+description: Import declarations are allowed in modules
+flags: [module]
+---*/
+
+import { value } from './import_FIXTURE.js';
@@ -0,0 +1 @@
+export var value = 1;
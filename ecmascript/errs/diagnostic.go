@@ -0,0 +1,225 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// Severity is how serious a diagnostic is.
+type Severity int
+
+const (
+	// SeverityError is for input that can not be processed.
+	SeverityError Severity = iota
+
+	// SeverityWarning is for input that is processed, but is likely wrong.
+	SeverityWarning
+
+	// SeverityInfo is for anything else worth telling the user.
+	SeverityInfo
+)
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInfo:
+		return "info"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// Code identifies a kind of diagnostic. Codes do not change between releases,
+// so tools can use them to filter, suppress or translate diagnostics.
+type Code int
+
+// String returns the code formatted as it is displayed, e.g. ES1003.
+func (c Code) String() string {
+	return fmt.Sprintf("ES%04d", int(c))
+}
+
+// Lexer diagnostics.
+const (
+	// CodeReadError is for input that could not be read.
+	CodeReadError Code = 1001 + iota
+
+	// CodeUnexpectedCharacter is for a character that can not begin a token.
+	CodeUnexpectedCharacter
+
+	// CodeUnterminatedString is for input that ends inside a string literal.
+	CodeUnterminatedString
+
+	// CodeUnterminatedComment is for input that ends inside a comment.
+	CodeUnterminatedComment
+
+	// CodeUnterminatedRegExp is for input that ends inside a regular
+	// expression literal.
+	CodeUnterminatedRegExp
+
+	// CodeInvalidNumber is for a malformed numeric literal.
+	CodeInvalidNumber
+
+	// CodeInvalidRegExp is for a regular expression literal with invalid
+	// flags, or a pattern that is invalid with its flags.
+	CodeInvalidRegExp
+
+	// CodeUnterminatedTemplate is for input that ends inside a template
+	// literal.
+	CodeUnterminatedTemplate
+
+	// CodeInvalidEscape is for a malformed escape sequence, such as \x with
+	// fewer than two hexadecimal digits after it.
+	CodeInvalidEscape
+)
+
+// Parser diagnostics.
+const (
+	// CodeUnexpectedToken is for a token that is not valid where it appears.
+	CodeUnexpectedToken Code = 2001 + iota
+
+	// CodeExpectedIdentifier is for a missing identifier.
+	CodeExpectedIdentifier
+
+	// CodeMissingSemicolon is for a statement that does not end where
+	// automatic semicolon insertion allows it to.
+	CodeMissingSemicolon
+
+	// CodeInvalidDestructuring is for a malformed binding or assignment
+	// pattern.
+	CodeInvalidDestructuring
+
+	// CodeInvalidArrowFunction is for a malformed arrow function head.
+	CodeInvalidArrowFunction
+
+	// CodeIllegalNewline is for a line break where the grammar forbids one.
+	CodeIllegalNewline
+
+	// CodeInvalidModuleSyntax is for a malformed import or export
+	// declaration.
+	CodeInvalidModuleSyntax
+
+	// CodeInvalidAssignmentTarget is for an assignment to something that
+	// can not be assigned to.
+	CodeInvalidAssignmentTarget
+
+	// CodeStrictMode is for syntax that is not allowed in strict mode code.
+	CodeStrictMode
+)
+
+// Warnings, for input that is valid but probably not what was meant.
+const (
+	// CodeWithStatement is for a `with` statement, which makes it impossible
+	// to tell what the names inside of it refer to.
+	CodeWithStatement Code = 3001 + iota
+
+	// CodeLegacyOctalEscape is for an octal escape sequence in a string
+	// literal, which is not allowed in strict mode code.
+	CodeLegacyOctalEscape
+
+	// CodeReturnNewline is for an expression on the line after `return`,
+	// which automatic semicolon insertion separates from the return.
+	CodeReturnNewline
+
+	// CodeDuplicateKey is for an object literal that defines a property more
+	// than once.
+	CodeDuplicateKey
+)
+
+// Lint diagnostics, reported by the rules in the lint package.
+const (
+	// CodeUnusedVariable is for a binding that is never read.
+	CodeUnusedVariable Code = 4001 + iota
+
+	// CodeUndeclaredVariable is for a reference to a name that is not
+	// declared and is not a known global.
+	CodeUndeclaredVariable
+
+	// CodeUnreachableCode is for statements that can never run.
+	CodeUnreachableCode
+
+	// CodeStrictViolation is for code that is an error in strict mode code,
+	// but that the parser accepts.
+	CodeStrictViolation
+)
+
+// Limitations of this implementation rather than of the input.
+const (
+	// CodeUnsupported is for valid syntax the parser can not represent yet.
+	CodeUnsupported Code = 9001 + iota
+
+	// CodeInternal is for a bug in the parser.
+	CodeInternal
+)
+
+// Label is a secondary span of a diagnostic, such as where an unterminated
+// construct begins.
+type Label struct {
+	Span    ast.Span
+	Message string
+}
+
+// Suggestion is a possible fix for a diagnostic: replacing the source in Span
+// with Replacement. An empty span inserts the replacement.
+type Suggestion struct {
+	Message     string
+	Span        ast.Span
+	Replacement string
+}
+
+// Diagnostic is a message about the source, in a form that does not depend on
+// which error type produced it.
+type Diagnostic struct {
+	Code        Code
+	Severity    Severity
+	Span        ast.Span
+	Labels      []Label
+	Suggestions []Suggestion
+	Message     string
+}
+
+// String returns the diagnostic formatted for display, with its labels and
+// suggestions on the lines that follow.
+func (d Diagnostic) String() string {
+	b := &strings.Builder{}
+	b.WriteString(d.header())
+	for _, l := range d.Labels {
+		fmt.Fprintf(b, "\n\t%s: %s", &l.Span.Start, l.Message)
+	}
+	for _, s := range d.Suggestions {
+		fmt.Fprintf(b, "\n\thelp: %s", s.Message)
+	}
+	return b.String()
+}
+
+// header returns the first line of the formatted diagnostic.
+func (d Diagnostic) header() string {
+	return fmt.Sprintf("%s: %s %s: %s", &d.Span.Start, d.Severity, d.Code, d.Message)
+}
+
+// Diagnostics converts an error returned by the lexer or parser into
+// diagnostics. An ErrorList yields one diagnostic per error. Errors that do
+// not carry a code are reported as internal errors.
+func Diagnostics(err error) []Diagnostic {
+	if err == nil {
+		return nil
+	}
+	var list ErrorList
+	if errors.As(err, &list) {
+		var ds []Diagnostic
+		for _, err := range list {
+			ds = append(ds, Diagnostics(err)...)
+		}
+		return ds
+	}
+	var d interface{ Diagnostic() Diagnostic }
+	if errors.As(err, &d) {
+		return []Diagnostic{d.Diagnostic()}
+	}
+	return []Diagnostic{{Code: CodeInternal, Severity: SeverityError, Message: err.Error()}}
+}
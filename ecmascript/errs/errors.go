@@ -7,9 +7,14 @@ import (
 )
 
 // SyntaxError is emitted when the parser or lexer encounters invalid syntax.
+// End is where the invalid input ends, when it is known.
 type SyntaxError struct {
-	Location ast.Location
-	Err      error
+	Location    ast.Location
+	End         ast.Location
+	Code        Code
+	Err         error
+	Labels      []Label
+	Suggestions []Suggestion
 }
 
 // Unwrap returns the embedded error.
@@ -20,6 +25,22 @@ func (e *SyntaxError) Error() string {
 	return fmt.Sprintf("%s: syntax error: %s", &e.Location, e.Err)
 }
 
+// Diagnostic returns the error as a diagnostic.
+func (e *SyntaxError) Diagnostic() Diagnostic {
+	span := e.Location.Span()
+	if e.End.Row != 0 {
+		span.End = e.End
+	}
+	return Diagnostic{
+		Code:        e.Code,
+		Severity:    SeverityError,
+		Span:        span,
+		Labels:      e.Labels,
+		Suggestions: e.Suggestions,
+		Message:     e.Err.Error(),
+	}
+}
+
 // EncodingError is emitted when the scanner encounters an invalid sequence.
 type EncodingError struct {
 	Location ast.Location
@@ -34,9 +55,20 @@ func (e *EncodingError) Error() string {
 	return fmt.Sprintf("%s: encoding error: %s", &e.Location, e.Err)
 }
 
+// Diagnostic returns the error as a diagnostic.
+func (e *EncodingError) Diagnostic() Diagnostic {
+	return Diagnostic{
+		Code:     CodeReadError,
+		Severity: SeverityError,
+		Span:     e.Location.Span(),
+		Message:  e.Err.Error(),
+	}
+}
+
 // ParserError is returned when the parser encounters an error.
 type ParserError struct {
 	Location ast.Location
+	Code     Code
 	Err      error
 }
 
@@ -47,3 +79,31 @@ func (e *ParserError) Unwrap() error { return e.Err }
 func (e *ParserError) Error() string {
 	return fmt.Sprintf("%s: parser error: %s", &e.Location, e.Err)
 }
+
+// Diagnostic returns the error as a diagnostic.
+func (e *ParserError) Diagnostic() Diagnostic {
+	return Diagnostic{
+		Code:     e.Code,
+		Severity: SeverityError,
+		Span:     e.Location.Span(),
+		Message:  e.Err.Error(),
+	}
+}
+
+// ErrorList is a list of errors, returned when the parser continues past
+// errors to find more of them.
+type ErrorList []error
+
+// Unwrap returns the errors in the list.
+func (l ErrorList) Unwrap() []error { return l }
+
+// Error implements the error interface.
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
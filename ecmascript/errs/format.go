@@ -0,0 +1,132 @@
+package errs
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// Source is the text of a source file, kept so diagnostics can quote it.
+type Source struct {
+	lines []string
+}
+
+// NewSource splits text into lines the same way the lexer counts rows, so
+// that the rows of diagnostics index into it.
+func NewSource(text string) *Source {
+	s := &Source{}
+	start := 0
+	for i, r := range text {
+		switch r {
+		case '\u000a', '\u000d', '\u2028', '\u2029':
+			s.lines = append(s.lines, text[start:i])
+			start = i + utf8.RuneLen(r)
+		}
+	}
+	s.lines = append(s.lines, text[start:])
+	return s
+}
+
+// Line returns the text of a row, numbered from 1, without its terminator.
+func (s *Source) Line(row int) (string, bool) {
+	if row < 1 || row > len(s.lines) {
+		return "", false
+	}
+	return s.lines[row-1], true
+}
+
+// marker is an underlined span in a formatted diagnostic.
+type marker struct {
+	span    ast.Span
+	char    string
+	message string
+}
+
+// Format renders the diagnostic for a terminal: the header, then each line it
+// refers to with the primary span underlined with carets and labels
+// underlined with dashes, then any suggestions. Spans outside of src are left
+// out; with a nil src, Format is the same as String.
+func (d Diagnostic) Format(src *Source) string {
+	if src == nil {
+		return d.String()
+	}
+
+	markers := []marker{{span: d.Span, char: "^"}}
+	for _, l := range d.Labels {
+		markers = append(markers, marker{span: l.Span, char: "-", message: l.Message})
+	}
+	sort.SliceStable(markers, func(i, j int) bool {
+		return markers[i].span.Start.Row < markers[j].span.Start.Row
+	})
+
+	width := 0
+	for _, m := range markers {
+		if n := len(strconv.Itoa(m.span.Start.Row)); n > width {
+			width = n
+		}
+	}
+	gutter := strings.Repeat(" ", width) + " |"
+
+	b := &strings.Builder{}
+	b.WriteString(d.header())
+	row := 0
+	for _, m := range markers {
+		line, ok := src.Line(m.span.Start.Row)
+		if !ok {
+			continue
+		}
+		if row == 0 {
+			fmt.Fprintf(b, "\n%s", gutter)
+		}
+		if m.span.Start.Row != row {
+			row = m.span.Start.Row
+			fmt.Fprintf(b, "\n%*d | %s", width, row, line)
+		}
+		fmt.Fprintf(b, "\n%s %s", gutter, underline(line, m))
+	}
+	for _, l := range d.Labels {
+		if _, ok := src.Line(l.Span.Start.Row); !ok {
+			fmt.Fprintf(b, "\n\t%s: %s", &l.Span.Start, l.Message)
+		}
+	}
+	for _, s := range d.Suggestions {
+		fmt.Fprintf(b, "\n%s = help: %s", strings.Repeat(" ", width), s.Message)
+	}
+	return b.String()
+}
+
+// underline returns the marker line for m under line. Tabs before the span
+// are kept so that the marker lines up however tabs are displayed.
+func underline(line string, m marker) string {
+	b := &strings.Builder{}
+	col := 1
+	for _, r := range line {
+		if col >= m.span.Start.Column {
+			break
+		}
+		if r == '\t' {
+			b.WriteRune('\t')
+		} else {
+			b.WriteRune(' ')
+		}
+		col++
+	}
+	for ; col < m.span.Start.Column; col++ {
+		b.WriteRune(' ')
+	}
+
+	n := 1
+	if m.span.End.Row == m.span.Start.Row && m.span.End.Column > m.span.Start.Column {
+		n = m.span.End.Column - m.span.Start.Column
+	}
+	b.WriteString(strings.Repeat(m.char, n))
+	if m.message != "" {
+		b.WriteString(" ")
+		b.WriteString(m.message)
+	}
+	return b.String()
+}
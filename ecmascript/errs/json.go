@@ -0,0 +1,74 @@
+package errs
+
+import (
+	"encoding/json"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// jsonPosition is a location in the JSON form of a diagnostic. Lines and
+// columns count from 1, as in the text form.
+type jsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type jsonLabel struct {
+	Message string       `json:"message"`
+	Start   jsonPosition `json:"start"`
+	End     jsonPosition `json:"end"`
+}
+
+type jsonSuggestion struct {
+	Message     string       `json:"message"`
+	Replacement string       `json:"replacement"`
+	Start       jsonPosition `json:"start"`
+	End         jsonPosition `json:"end"`
+}
+
+type jsonDiagnostic struct {
+	Code        string           `json:"code"`
+	Severity    string           `json:"severity"`
+	Message     string           `json:"message"`
+	File        string           `json:"file,omitempty"`
+	Start       jsonPosition     `json:"start"`
+	End         jsonPosition     `json:"end"`
+	Labels      []jsonLabel      `json:"labels,omitempty"`
+	Suggestions []jsonSuggestion `json:"suggestions,omitempty"`
+}
+
+func position(l ast.Location) jsonPosition {
+	return jsonPosition{Line: l.Row, Column: l.Column}
+}
+
+// MarshalJSON encodes the diagnostic as an object with its code, severity and
+// message as strings, the file it is in as a URI, and its spans as line and
+// column pairs, so that tools do not need to know about the types here.
+func (d Diagnostic) MarshalJSON() ([]byte, error) {
+	j := jsonDiagnostic{
+		Code:     d.Code.String(),
+		Severity: d.Severity.String(),
+		Message:  d.Message,
+		Start:    position(d.Span.Start),
+		End:      position(d.Span.End),
+	}
+	if d.Span.Start.URI != nil {
+		j.File = d.Span.Start.URI.String()
+	}
+	for _, l := range d.Labels {
+		j.Labels = append(j.Labels, jsonLabel{
+			Message: l.Message,
+			Start:   position(l.Span.Start),
+			End:     position(l.Span.End),
+		})
+	}
+	for _, s := range d.Suggestions {
+		j.Suggestions = append(j.Suggestions, jsonSuggestion{
+			Message:     s.Message,
+			Replacement: s.Replacement,
+			Start:       position(s.Span.Start),
+			End:         position(s.Span.End),
+		})
+	}
+	return json.Marshal(j)
+}
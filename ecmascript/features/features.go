@@ -0,0 +1,262 @@
+// Package features finds which ECMAScript language features parsed code uses,
+// along with the edition of the standard that introduced each, so that the
+// oldest runtime able to run the code can be worked out.
+package features
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// Baseline is the year of ES5, the edition that code using none of the
+// features is taken to need.
+const Baseline = 2009
+
+// Feature is a language feature added after ES5.
+type Feature struct {
+	// Name identifies the feature, e.g. "optional-chaining".
+	Name string
+
+	// Doc is a short description of the feature.
+	Doc string
+
+	// Year is the year of the edition that introduced the feature, e.g.
+	// 2020 for ES2020.
+	Year int
+}
+
+// The features that Find reports.
+var (
+	ArrowFunctions          = &Feature{Name: "arrow-functions", Doc: "arrow functions", Year: 2015}
+	Classes                 = &Feature{Name: "classes", Doc: "class declarations and expressions", Year: 2015}
+	LetConst                = &Feature{Name: "let-const", Doc: "let and const declarations", Year: 2015}
+	Generators              = &Feature{Name: "generators", Doc: "generator functions", Year: 2015}
+	Destructuring           = &Feature{Name: "destructuring", Doc: "array and object patterns", Year: 2015}
+	DefaultParameters       = &Feature{Name: "default-parameters", Doc: "default parameter values", Year: 2015}
+	RestParameters          = &Feature{Name: "rest-parameters", Doc: "rest parameters", Year: 2015}
+	Spread                  = &Feature{Name: "spread", Doc: "spread elements in arrays and calls", Year: 2015}
+	ForOf                   = &Feature{Name: "for-of", Doc: "for-of loops", Year: 2015}
+	ObjectLiteralExtensions = &Feature{Name: "object-literal-extensions", Doc: "computed keys and methods in object literals", Year: 2015}
+	BinaryOctalLiterals     = &Feature{Name: "binary-octal-literals", Doc: "0b and 0o numeric literals", Year: 2015}
+	RegExpStickyUnicode     = &Feature{Name: "regexp-sticky-unicode", Doc: "the y and u regular expression flags", Year: 2015}
+	Modules                 = &Feature{Name: "modules", Doc: "import and export declarations", Year: 2015}
+	NewTarget               = &Feature{Name: "new-target", Doc: "new.target", Year: 2015}
+	Exponentiation          = &Feature{Name: "exponentiation", Doc: "the ** and **= operators", Year: 2016}
+	AsyncFunctions          = &Feature{Name: "async-functions", Doc: "async functions", Year: 2017}
+	AsyncGenerators         = &Feature{Name: "async-generators", Doc: "async generator functions", Year: 2018}
+	ObjectRestSpread        = &Feature{Name: "object-rest-spread", Doc: "rest and spread properties in objects", Year: 2018}
+	RegExpDotAll            = &Feature{Name: "regexp-dotall", Doc: "the s regular expression flag", Year: 2018}
+	OptionalCatchBinding    = &Feature{Name: "optional-catch-binding", Doc: "catch clauses without a binding", Year: 2019}
+	OptionalChaining        = &Feature{Name: "optional-chaining", Doc: "the ?. operator", Year: 2020}
+	NullishCoalescing       = &Feature{Name: "nullish-coalescing", Doc: "the ?? operator", Year: 2020}
+	BigInt                  = &Feature{Name: "bigint", Doc: "BigInt literals", Year: 2020}
+	LogicalAssignment       = &Feature{Name: "logical-assignment", Doc: "the &&=, ||= and ??= operators", Year: 2021}
+	StringExportNames       = &Feature{Name: "string-export-names", Doc: "import and export names written as strings", Year: 2022}
+	RegExpIndices           = &Feature{Name: "regexp-indices", Doc: "the d regular expression flag", Year: 2022}
+	RegExpUnicodeSets       = &Feature{Name: "regexp-unicode-sets", Doc: "the v regular expression flag", Year: 2024}
+)
+
+// Features are the features that Find reports, oldest first.
+var Features = []*Feature{
+	ArrowFunctions, Classes, LetConst, Generators, Destructuring,
+	DefaultParameters, RestParameters, Spread, ForOf, ObjectLiteralExtensions,
+	BinaryOctalLiterals, RegExpStickyUnicode, Modules, NewTarget,
+	Exponentiation,
+	AsyncFunctions,
+	AsyncGenerators, ObjectRestSpread, RegExpDotAll,
+	OptionalCatchBinding,
+	OptionalChaining, NullishCoalescing, BigInt,
+	LogicalAssignment,
+	StringExportNames, RegExpIndices,
+	RegExpUnicodeSets,
+}
+
+// Use is a use of a feature in the code.
+type Use struct {
+	Feature *Feature
+
+	// Span is the node that uses the feature. Where the use is part of a
+	// structure that is not a node of its own, such as a parameter or a
+	// property, it is the node around it.
+	Span ast.Span
+}
+
+// Find returns the uses of features in a script or module, or in any other
+// node, in source order.
+func Find(n ast.Node) []Use {
+	var uses []Use
+	use := func(f *Feature, n ast.Node) {
+		uses = append(uses, Use{Feature: f, Span: n.Span()})
+	}
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch t := n.(type) {
+		case ast.FunctionDeclaration:
+			function(use, n, t.Async, t.Generator, false, t.Params)
+		case ast.FunctionExpression:
+			function(use, n, t.Async, t.Generator, t.Arrow, t.Params)
+		case ast.ClassDeclaration, ast.ClassExpression:
+			use(Classes, n)
+		case ast.VariableDeclaration:
+			if t.Kind != ast.VarDeclaration {
+				use(LetConst, n)
+			}
+			for _, d := range t.Declarations {
+				pattern(use, n, d.ID)
+			}
+		case ast.CatchClause:
+			if t.Param == (ast.BindingPattern{}) {
+				use(OptionalCatchBinding, n)
+			}
+			pattern(use, n, t.Param)
+		case ast.AssignmentExpression:
+			switch t.Operator {
+			case ast.AssignmentExponentOp:
+				use(Exponentiation, n)
+			case ast.AssignmentLogicalAndOp, ast.AssignmentLogicalOr, ast.AssignmentCoalesceOp:
+				use(LogicalAssignment, n)
+			}
+			switch t.Left.(type) {
+			case ast.ArrayExpression, ast.ObjectExpression:
+				use(Destructuring, n)
+			}
+		case ast.BinaryExpression:
+			switch t.Operator {
+			case ast.BinaryExponentOp:
+				use(Exponentiation, n)
+			case ast.BinaryCoalesceOp:
+				use(NullishCoalescing, n)
+			}
+		case ast.SpreadElement:
+			use(Spread, n)
+		case ast.ObjectExpression:
+			for _, p := range t.Properties {
+				switch {
+				case p.Kind == ast.SpreadProperty:
+					use(ObjectRestSpread, n)
+				case p.Computed || p.Method:
+					use(ObjectLiteralExtensions, n)
+				}
+			}
+		case ast.MemberExpression:
+			if t.Optional {
+				use(OptionalChaining, n)
+			}
+		case ast.CallExpression:
+			if t.Optional {
+				use(OptionalChaining, n)
+			}
+		case ast.ForOfStatement:
+			use(ForOf, n)
+		case ast.ImportDeclNode:
+			use(Modules, n)
+			for _, i := range t.NamedImports {
+				if i.Quoted {
+					use(StringExportNames, n)
+					break
+				}
+			}
+		case ast.ExportDeclNode:
+			use(Modules, n)
+			quoted := t.NameSpace != nil && t.NameSpace.Quoted
+			for _, x := range t.NamedExports {
+				quoted = quoted || x.Quoted || x.AsQuoted
+			}
+			if quoted {
+				use(StringExportNames, n)
+			}
+		case ast.MetaProperty:
+			if t.Meta == "new" {
+				use(NewTarget, n)
+			}
+		case ast.NumberLiteral:
+			raw := strings.ToLower(t.Raw)
+			if strings.HasPrefix(raw, "0b") || strings.HasPrefix(raw, "0o") {
+				use(BinaryOctalLiterals, n)
+			}
+			if strings.HasSuffix(raw, "n") {
+				use(BigInt, n)
+			}
+		case ast.RegExpLiteral:
+			if strings.ContainsAny(t.Flags, "yu") {
+				use(RegExpStickyUnicode, n)
+			}
+			if strings.Contains(t.Flags, "s") {
+				use(RegExpDotAll, n)
+			}
+			if strings.Contains(t.Flags, "d") {
+				use(RegExpIndices, n)
+			}
+			if strings.Contains(t.Flags, "v") {
+				use(RegExpUnicodeSets, n)
+			}
+		}
+		return true
+	})
+	sort.SliceStable(uses, func(i, j int) bool {
+		a, b := uses[i].Span.Start, uses[j].Span.Start
+		return a.Row < b.Row || a.Row == b.Row && a.Column < b.Column
+	})
+	return uses
+}
+
+// function records the features used by the function n.
+func function(use func(*Feature, ast.Node), n ast.Node, async, generator, arrow bool, params ast.FormalParameters) {
+	switch {
+	case async && generator:
+		use(AsyncGenerators, n)
+	case async:
+		use(AsyncFunctions, n)
+	case generator:
+		use(Generators, n)
+	}
+	if arrow {
+		use(ArrowFunctions, n)
+	}
+	if params.RestParameter != "" {
+		use(RestParameters, n)
+	}
+	for _, p := range params.Parameters {
+		if p.Init != nil {
+			use(DefaultParameters, n)
+			break
+		}
+	}
+	for _, p := range params.Parameters {
+		pattern(use, n, p.Value)
+	}
+}
+
+// pattern records the features used by the binding pattern b, which is part
+// of the node n.
+func pattern(use func(*Feature, ast.Node), n ast.Node, b ast.BindingPattern) {
+	switch {
+	case b.ObjectPattern != nil:
+		use(Destructuring, n)
+		if b.ObjectPattern.RestElement != "" {
+			use(ObjectRestSpread, n)
+		}
+		for _, p := range b.ObjectPattern.Properties {
+			pattern(use, n, p.Value)
+		}
+	case b.ArrayPattern != nil:
+		use(Destructuring, n)
+		for _, e := range b.ArrayPattern.Elements {
+			pattern(use, n, e.Value)
+		}
+		pattern(use, n, b.ArrayPattern.RestElement)
+	}
+}
+
+// Year returns the year of the oldest edition of ECMAScript that has every
+// feature in uses, or Baseline if there are none.
+func Year(uses []Use) int {
+	year := Baseline
+	for _, u := range uses {
+		if u.Feature.Year > year {
+			year = u.Feature.Year
+		}
+	}
+	return year
+}
@@ -0,0 +1,119 @@
+package features
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+func parse(t *testing.T, src string, mode parser.ParseMode) ast.Node {
+	t.Helper()
+	n, err := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(src), nil))).Parse(parser.ParseOptions{Mode: mode})
+	if err != nil {
+		t.Fatalf("error parsing %q: %v", src, err)
+	}
+	return n
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		input    string
+		mode     parser.ParseMode
+		expected []string
+		year     int
+	}{
+		{
+			input: `var a = 1; function f(b) { return b + 0x10; }`,
+			year:  Baseline,
+		},
+		{
+			input:    `let a = (b = 1, ...c) => [...c];`,
+			expected: []string{"1:1 let-const", "1:9 arrow-functions", "1:9 rest-parameters", "1:9 default-parameters", "1:27 spread"},
+			year:     2015,
+		},
+		{
+			input:    `var {a, ...b} = c; [d, e] = f;`,
+			expected: []string{"1:1 destructuring", "1:1 object-rest-spread", "1:20 destructuring"},
+			year:     2018,
+		},
+		{
+			input:    `x = async function* () { for (const y of z) y ?? 0; }`,
+			expected: []string{"1:5 async-generators", "1:26 for-of", "1:31 let-const", "1:45 nullish-coalescing"},
+			year:     2020,
+		},
+		{
+			input:    `try {} catch { a ||= 1_000 ** 2; }`,
+			expected: []string{"1:8 optional-catch-binding", "1:16 logical-assignment", "1:22 exponentiation"},
+			year:     2021,
+		},
+		{
+			input:    `x = { [a]: 1, b() {}, ...c }; /./sd; class C {}`,
+			expected: []string{"1:5 object-literal-extensions", "1:5 object-literal-extensions", "1:5 object-rest-spread", "1:31 regexp-dotall", "1:31 regexp-indices", "1:38 classes"},
+			year:     2022,
+		},
+		{
+			input:    `import a from "a"; export const b = 0b1;`,
+			mode:     parser.ModuleMode,
+			expected: []string{"1:1 modules", "1:20 modules", "1:27 let-const", "1:37 binary-octal-literals"},
+			year:     2015,
+		},
+		{
+			input:    `import {"a-b" as a} from "a"; export {a as "c"}; export * as "d" from "d";`,
+			mode:     parser.ModuleMode,
+			expected: []string{"1:1 modules", "1:1 string-export-names", "1:31 modules", "1:31 string-export-names", "1:50 modules", "1:50 string-export-names"},
+			year:     2022,
+		},
+	}
+	for _, test := range tests {
+		uses := Find(parse(t, test.input, test.mode))
+		var actual []string
+		for _, u := range uses {
+			actual = append(actual, fmt.Sprintf("%d:%d %s", u.Span.Start.Row, u.Span.Start.Column, u.Feature.Name))
+		}
+		if diff := cmp.Diff(test.expected, actual); diff != "" {
+			t.Errorf("%q: uses mismatch (-expected +actual):\n%s", test.input, diff)
+		}
+		if year := Year(uses); year != test.year {
+			t.Errorf("%q: expected year %d, got %d", test.input, test.year, year)
+		}
+	}
+}
+
+func TestFindNodes(t *testing.T) {
+	// The parser does not read these yet, but they can be built.
+	tests := []struct {
+		n        ast.Node
+		expected *Feature
+	}{
+		{ast.MemberExpression{Object: ast.Identifier{Name: "a"}, Property: ast.Identifier{Name: "b"}, Optional: true}, OptionalChaining},
+		{ast.CallExpression{Callee: ast.Identifier{Name: "a"}, Optional: true}, OptionalChaining},
+		{ast.NumberLiteral{Value: 1, Raw: "1n"}, BigInt},
+		{ast.RegExpLiteral{Pattern: ".", Flags: "v", Raw: "/./v"}, RegExpUnicodeSets},
+	}
+	for _, test := range tests {
+		uses := Find(test.n)
+		if len(uses) != 1 || uses[0].Feature != test.expected {
+			t.Errorf("%#v: expected a use of %s, got %v", test.n, test.expected.Name, uses)
+		}
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	names := map[string]bool{}
+	year := Baseline
+	for _, f := range Features {
+		if names[f.Name] {
+			t.Errorf("duplicate feature name %q", f.Name)
+		}
+		names[f.Name] = true
+		if f.Year < year {
+			t.Errorf("feature %q from %d is listed after one from %d", f.Name, f.Year, year)
+		}
+		year = f.Year
+	}
+}
@@ -0,0 +1,229 @@
+// Package graph builds the graph of modules that a set of entry points
+// import, directly or indirectly.
+package graph
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+	"github.com/jchv/cleansheets/ecmascript/resolve"
+)
+
+// Import is an import or re-export of another module.
+type Import struct {
+	Specifier string
+
+	// Span is the span of the declaration the specifier is in.
+	Span ast.Span
+
+	// Path is the file the specifier resolves to, or "" if it could not be
+	// resolved, in which case Err says why.
+	Path string
+	Err  error
+
+	// Ignored is whether the package.json `browser` field replaces the
+	// module with nothing, in which case Path is "" and Err is nil.
+	Ignored bool
+}
+
+// Module is a file in the graph.
+type Module struct {
+	Path string
+
+	// Source is the text of the file, and Node the module parsed from it.
+	// Node is nil if the file could not be read or parsed, in which case Err
+	// says why.
+	Source string
+	Node   ast.Node
+	Err    error
+
+	// Imports are the modules this one imports, in source order.
+	Imports []Import
+}
+
+// Graph is a set of modules and the imports between them.
+type Graph struct {
+	// Entries are the paths of the entry points.
+	Entries []string
+
+	// Modules are the modules in the graph, in the order they were found.
+	Modules []*Module
+
+	byPath map[string]*Module
+}
+
+// Module returns the module for the file path, or nil if it is not in the
+// graph.
+func (g *Graph) Module(path string) *Module {
+	return g.byPath[path]
+}
+
+// Build loads the entry points and every module they import, resolving
+// specifiers with r. Files that can not be read, parsed or resolved are
+// recorded in the graph rather than stopping the build.
+func Build(entries []string, r *resolve.Resolver) *Graph {
+	g := &Graph{byPath: map[string]*Module{}}
+	var queue []*Module
+	add := func(path string) *Module {
+		if m := g.byPath[path]; m != nil {
+			return m
+		}
+		m := &Module{Path: path}
+		g.byPath[path] = m
+		g.Modules = append(g.Modules, m)
+		queue = append(queue, m)
+		return m
+	}
+
+	for _, e := range entries {
+		if abs, err := filepath.Abs(e); err == nil {
+			e = abs
+		}
+		g.Entries = append(g.Entries, e)
+		add(e)
+	}
+
+	for len(queue) > 0 {
+		m := queue[0]
+		queue = queue[1:]
+		m.load()
+		for i := range m.Imports {
+			imp := &m.Imports[i]
+			imp.Path, imp.Err = r.Resolve(imp.Specifier, m.Path)
+			switch {
+			case imp.Err == nil:
+				add(imp.Path)
+			case errors.Is(imp.Err, resolve.ErrIgnored):
+				imp.Err, imp.Ignored = nil, true
+			}
+		}
+	}
+	return g
+}
+
+// load reads and parses the module, and finds its imports.
+func (m *Module) load() {
+	data, err := ioutil.ReadFile(m.Path)
+	if err != nil {
+		m.Err = err
+		return
+	}
+	m.Source = string(data)
+
+	// Files that are not marked as modules or scripts by their extension
+	// are modules if they use import or export declarations.
+	opt := parser.ParseOptions{Mode: parser.DetectMode}
+	switch strings.ToLower(filepath.Ext(m.Path)) {
+	case ".json":
+		return
+	case ".mjs":
+		opt.Mode = parser.ModuleMode
+	case ".cjs":
+		// CommonJS modules run inside of a function wrapper.
+		opt = parser.ParseOptions{
+			Mode:                          parser.ScriptMode,
+			AllowReturnOutsideFunction:    true,
+			AllowNewTargetOutsideFunction: true,
+		}
+	}
+
+	uri := &url.URL{Scheme: "file", Path: filepath.ToSlash(m.Path)}
+	p := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(m.Source), uri)))
+	if m.Node, m.Err = p.Parse(opt); m.Err != nil {
+		m.Node = nil
+		return
+	}
+
+	body, _ := m.Node.(ast.ModuleNode)
+	for _, stmt := range body.Body {
+		switch t := stmt.(type) {
+		case ast.ImportDeclNode:
+			m.Imports = append(m.Imports, Import{Specifier: t.Module, Span: t.Span()})
+		case ast.ExportDeclNode:
+			if t.Module != "" {
+				m.Imports = append(m.Imports, Import{Specifier: t.Module, Span: t.Span()})
+			}
+		}
+	}
+}
+
+// Cycles returns the groups of modules that import each other, directly or
+// indirectly, including modules that import themselves. Each group is in the
+// order its modules were found.
+func (g *Graph) Cycles() [][]*Module {
+	// Tarjan's strongly connected components algorithm.
+	index := map[*Module]int{}
+	low := map[*Module]int{}
+	onStack := map[*Module]bool{}
+	var stack []*Module
+	var groups [][]*Module
+
+	var visit func(m *Module)
+	visit = func(m *Module) {
+		index[m] = len(index)
+		low[m] = index[m]
+		stack = append(stack, m)
+		onStack[m] = true
+
+		self := false
+		for _, imp := range m.Imports {
+			n := g.byPath[imp.Path]
+			switch {
+			case n == nil:
+				continue
+			case n == m:
+				self = true
+			case !onStack[n]:
+				if _, seen := index[n]; seen {
+					continue
+				}
+				visit(n)
+				if low[n] < low[m] {
+					low[m] = low[n]
+				}
+			case index[n] < low[m]:
+				low[m] = index[n]
+			}
+		}
+
+		if low[m] != index[m] {
+			return
+		}
+		i := len(stack) - 1
+		for stack[i] != m {
+			i--
+		}
+		group := append([]*Module(nil), stack[i:]...)
+		for _, n := range group {
+			onStack[n] = false
+		}
+		stack = stack[:i]
+		if len(group) > 1 || self {
+			groups = append(groups, group)
+		}
+	}
+
+	for _, m := range g.Modules {
+		if _, seen := index[m]; !seen {
+			visit(m)
+		}
+	}
+
+	// Order each group, and the groups themselves, by when they were found.
+	order := map[*Module]int{}
+	for i, m := range g.Modules {
+		order[m] = i
+	}
+	for _, group := range groups {
+		sort.Slice(group, func(i, j int) bool { return order[group[i]] < order[group[j]] })
+	}
+	sort.Slice(groups, func(i, j int) bool { return order[groups[i][0]] < order[groups[j][0]] })
+	return groups
+}
@@ -0,0 +1,100 @@
+package graph
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jchv/cleansheets/ecmascript/resolve"
+)
+
+func TestBuild(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"main.js":   "import a from './a.js';\nimport './b';\nexport * from 'missing';\n",
+		"a.js":      "import {c} from './c.js'; export default c;",
+		"b.js":      "export {a} from './a.js'; import './b.js';",
+		"c.js":      "import x from './d.js'; export const c = x;",
+		"d.js":      "import {c} from './c.js'; export default 1;",
+		"broken.js": "import from;",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rel := func(path string) string {
+		r, err := filepath.Rel(root, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	g := Build([]string{filepath.Join(root, "main.js"), filepath.Join(root, "broken.js")}, resolve.NewResolver())
+
+	modules := []string{}
+	for _, m := range g.Modules {
+		modules = append(modules, rel(m.Path))
+	}
+	if diff := cmp.Diff([]string{"main.js", "broken.js", "a.js", "b.js", "c.js", "d.js"}, modules); diff != "" {
+		t.Errorf("modules mismatch (-expected +result):\n%s", diff)
+	}
+
+	main := g.Module(filepath.Join(root, "main.js"))
+	if len(main.Imports) != 3 {
+		t.Fatalf("expected 3 imports, got %d", len(main.Imports))
+	}
+	if imp := main.Imports[1]; imp.Specifier != "./b" || rel(imp.Path) != "b.js" || imp.Span.Start.Row != 2 || imp.Span.Start.Column != 1 {
+		t.Errorf("unexpected import %#v", imp)
+	}
+	if imp := main.Imports[2]; imp.Path != "" || imp.Err == nil {
+		t.Errorf("expected unresolved import, got %#v", imp)
+	}
+	if m := g.Module(filepath.Join(root, "broken.js")); m.Err == nil || m.Node != nil {
+		t.Errorf("expected parse error, got %#v", m)
+	}
+
+	cycles := [][]string{}
+	for _, c := range g.Cycles() {
+		cycle := []string{}
+		for _, m := range c {
+			cycle = append(cycle, rel(m.Path))
+		}
+		cycles = append(cycles, cycle)
+	}
+	if diff := cmp.Diff([][]string{{"b.js"}, {"c.js", "d.js"}}, cycles); diff != "" {
+		t.Errorf("cycles mismatch (-expected +result):\n%s", diff)
+	}
+}
+
+func TestBuildMissingEntry(t *testing.T) {
+	g := Build([]string{filepath.Join(t.TempDir(), "missing.js")}, resolve.NewResolver())
+	if len(g.Modules) != 1 || !os.IsNotExist(g.Modules[0].Err) {
+		t.Errorf("expected missing entry, got %#v", g.Modules)
+	}
+}
+
+func TestBuildIgnored(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"package.json": `{"browser": {"fs": false}}`,
+		"main.js":      "import fs from 'fs';",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := resolve.NewResolver()
+	r.Browser = true
+	g := Build([]string{filepath.Join(root, "main.js")}, r)
+	if len(g.Modules) != 1 {
+		t.Fatalf("expected only the entry, got %d modules", len(g.Modules))
+	}
+	if imp := g.Modules[0].Imports[0]; !imp.Ignored || imp.Err != nil || imp.Path != "" {
+		t.Errorf("expected ignored import, got %#v", imp)
+	}
+}
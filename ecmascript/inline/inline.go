@@ -0,0 +1,105 @@
+// Package inline extracts the scripts embedded in HTML pages, along with
+// where they start in the page, so that they can be parsed with locations and
+// diagnostics that point into the page rather than into the script alone.
+package inline
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/html"
+)
+
+// Script is the body of a script element.
+type Script struct {
+	Text string
+
+	// Module is whether the script is a module script; otherwise, it is a
+	// classic script.
+	Module bool
+
+	// Start is the location of the first character of Text in the page.
+	Start ast.Location
+}
+
+// Scanner returns a scanner for the text of the script, which reports
+// locations in the page.
+func (s Script) Scanner() *lexer.Scanner {
+	return lexer.NewScannerAt(strings.NewReader(s.Text), s.Start)
+}
+
+// javaScriptTypes are the JavaScript MIME types, which a type attribute may
+// give for a classic script.
+var javaScriptTypes = map[string]bool{
+	"application/ecmascript":   true,
+	"application/javascript":   true,
+	"application/x-ecmascript": true,
+	"application/x-javascript": true,
+	"text/ecmascript":          true,
+	"text/javascript":          true,
+	"text/javascript1.0":       true,
+	"text/javascript1.1":       true,
+	"text/javascript1.2":       true,
+	"text/javascript1.3":       true,
+	"text/javascript1.4":       true,
+	"text/javascript1.5":       true,
+	"text/jscript":             true,
+	"text/livescript":          true,
+	"text/x-ecmascript":        true,
+	"text/x-javascript":        true,
+}
+
+// scriptKind returns whether a script element is a classic or module
+// script, and whether it is either. Scripts with a src attribute are not,
+// since their content is ignored.
+func scriptKind(n *html.Node) (module bool, ok bool) {
+	if _, ok := n.Attribute("src"); ok {
+		return false, false
+	}
+	typ, ok := n.Attribute("type")
+	if !ok {
+		if lang, _ := n.Attribute("language"); lang != "" {
+			typ = "text/" + lang
+		}
+	}
+	typ = strings.ToLower(strings.Trim(typ, " \t\n\f\r"))
+	switch {
+	case typ == "", javaScriptTypes[typ]:
+		return false, true
+	case typ == "module":
+		return true, true
+	}
+	return false, false
+}
+
+// Extract returns the inline classic and module scripts in an HTML page, in
+// document order, with locations against uri. Scripts of other types, such
+// as JSON or templates, and scripts with a src attribute are left out.
+func Extract(page string, uri *url.URL) []Script {
+	return Scripts(html.Parse(page, uri))
+}
+
+// Scripts returns the inline classic and module scripts under a node of a
+// parsed page, as Extract does.
+func Scripts(n *html.Node) []Script {
+	var scripts []Script
+	html.Inspect(n, func(c *html.Node) bool {
+		if c.Type != html.ElementNode || c.Data != "script" {
+			return true
+		}
+		module, ok := scriptKind(c)
+		if !ok {
+			return false
+		}
+		s := Script{Module: module, Start: c.StartTag.End}
+		if len(c.Children) > 0 {
+			s.Text = c.Text()
+			s.Start = c.Children[0].Span.Start
+		}
+		scripts = append(scripts, s)
+		return false
+	})
+	return scripts
+}
@@ -0,0 +1,102 @@
+package inline
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+func TestExtract(t *testing.T) {
+	at := func(row, column int) ast.Location { return ast.Location{Row: row, Column: column} }
+	tests := []struct {
+		name     string
+		page     string
+		expected []Script
+	}{
+		{
+			name:     "classic",
+			page:     "<p>hi</p><script>a()</script>",
+			expected: []Script{{Text: "a()", Start: at(1, 18)}},
+		},
+		{
+			name: "types",
+			page: "<script type=module>m</script>\n" +
+				"<SCRIPT TYPE='Text/JavaScript '>c</SCRIPT >\n" +
+				"<script type=\"application/json\">{}</script>\n" +
+				"<script type=text/template><b></script>\n" +
+				"<script language=javascript>l</script>\n" +
+				"<script src=a.js>ignored</script>\n" +
+				"<script type=\"\">e</script>",
+			expected: []Script{
+				{Text: "m", Module: true, Start: at(1, 21)},
+				{Text: "c", Start: at(2, 33)},
+				{Text: "l", Start: at(5, 29)},
+				{Text: "e", Start: at(7, 17)},
+			},
+		},
+		{
+			name: "skipped markup",
+			page: "<!-- <script>a</script> -->" +
+				"<style>.x::after { content: '<script>b</script>' }</style>" +
+				"<textarea><script>c</script></textarea>" +
+				"<script>d</script>",
+			expected: []Script{{Text: "d", Start: at(1, 133)}},
+		},
+		{
+			name:     "end tag",
+			page:     "<script>\n'</scripts>' + '</script'\n</script>",
+			expected: []Script{{Text: "\n'</scripts>' + '</script'\n", Start: at(1, 9)}},
+		},
+		{
+			name:     "rows",
+			page:     "<html>\r\n<body> é<script>\n  x\n</script>",
+			expected: []Script{{Text: "\n  x\n", Start: at(4, 10)}},
+		},
+		{
+			name:     "escaped end tag",
+			page:     "<script><!-- document.write('<script></script>') --></script>",
+			expected: []Script{{Text: "<!-- document.write('<script></script>') -->", Start: at(1, 9)}},
+		},
+		{
+			name:     "unterminated",
+			page:     "<script>a",
+			expected: []Script{{Text: "a", Start: at(1, 9)}},
+		},
+		{
+			name:     "attributes",
+			page:     "<script data-x=\"a>b\" type=module type=json id=&amp; async>m</script>",
+			expected: []Script{{Text: "m", Module: true, Start: at(1, 59)}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.expected, Extract(test.page, nil)); diff != "" {
+				t.Errorf("unexpected scripts (-expected +actual):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExtractLocations(t *testing.T) {
+	uri := &url.URL{Scheme: "https", Host: "example.com", Path: "/index.html"}
+	page := "<!doctype html>\n<title>t</title>\n<script type=module>\n  import a from 'a';\n  let x = ;\n</script>\n"
+	scripts := Extract(page, uri)
+	if len(scripts) != 1 {
+		t.Fatalf("expected one script, got %d", len(scripts))
+	}
+	p := parser.NewParser(lexer.NewLexer(scripts[0].Scanner()))
+	_, err := p.Parse(parser.ParseOptions{Mode: parser.ModuleMode})
+	ds := errs.Diagnostics(err)
+	if len(ds) != 1 {
+		t.Fatalf("expected one diagnostic, got %v", err)
+	}
+	start := ds[0].Span.Start
+	if start.URI != uri || start.Row != 5 || start.Column != 11 {
+		t.Errorf("expected diagnostic at %s:5:11, got %s:%d:%d", uri, start.URI, start.Row, start.Column)
+	}
+}
@@ -0,0 +1,119 @@
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// cook decodes the escape sequences of the raw value of a template literal.
+// Unlike in string literals, octal escapes are not allowed, and neither are
+// \8 and \9.
+func cook(raw string) (string, error) {
+	if !strings.Contains(raw, `\`) {
+		return raw, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(raw); {
+		if raw[i] != '\\' {
+			b.WriteByte(raw[i])
+			i++
+			continue
+		}
+		i++
+		if i == len(raw) {
+			return "", errors.New("escape sequence at end of input")
+		}
+		c := raw[i]
+		i++
+		switch c {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case '\n':
+			// A line continuation stands for nothing.
+		case '0':
+			if i < len(raw) && raw[i] >= '0' && raw[i] <= '9' {
+				return "", errors.New("octal escape sequences are not allowed in template literals")
+			}
+			b.WriteByte(0)
+		case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			return "", fmt.Errorf(`\%c is not allowed in template literals`, c)
+		case 'x':
+			if i+2 > len(raw) {
+				return "", errors.New(`expected two hexadecimal digits after \x`)
+			}
+			v, err := strconv.ParseUint(raw[i:i+2], 16, 8)
+			if err != nil {
+				return "", errors.New(`expected two hexadecimal digits after \x`)
+			}
+			b.WriteRune(rune(v))
+			i += 2
+		case 'u':
+			r, n, err := unicodeEscape(raw[i:])
+			if err != nil {
+				return "", err
+			}
+			i += n
+
+			// A surrogate pair written as two escapes is one code point. A
+			// lone surrogate can not be held in a Go string, and becomes
+			// U+FFFD.
+			if utf16.IsSurrogate(r) && strings.HasPrefix(raw[i:], `\u`) {
+				if r2, n, err := unicodeEscape(raw[i+2:]); err == nil {
+					if pair := utf16.DecodeRune(r, r2); pair != unicode.ReplacementChar {
+						r = pair
+						i += 2 + n
+					}
+				}
+			}
+			b.WriteRune(r)
+		default:
+			// Any other character stands for itself, except for the line
+			// terminators U+2028 and U+2029, which are line continuations.
+			r, n := utf8.DecodeRuneInString(raw[i-1:])
+			if r != '\u2028' && r != '\u2029' {
+				b.WriteString(raw[i-1 : i-1+n])
+			}
+			i += n - 1
+		}
+	}
+	return b.String(), nil
+}
+
+// unicodeEscape decodes the part of a \u escape sequence after the u, either
+// four hexadecimal digits or a code point in braces, and returns its length.
+func unicodeEscape(s string) (rune, int, error) {
+	if strings.HasPrefix(s, "{") {
+		end := strings.IndexByte(s, '}')
+		if end < 2 {
+			return 0, 0, errors.New(`expected a code point in braces after \u`)
+		}
+		v, err := strconv.ParseUint(s[1:end], 16, 32)
+		if err != nil || v > 0x10FFFF {
+			return 0, 0, errors.New(`expected a code point in braces after \u`)
+		}
+		return rune(v), end + 1, nil
+	}
+	if len(s) < 4 {
+		return 0, 0, errors.New(`expected four hexadecimal digits after \u`)
+	}
+	v, err := strconv.ParseUint(s[:4], 16, 16)
+	if err != nil {
+		return 0, 0, errors.New(`expected four hexadecimal digits after \u`)
+	}
+	return rune(v), 4, nil
+}
@@ -1,9 +1,9 @@
 package lexer
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"strings"
+	"unicode/utf8"
 
 	"github.com/jchv/cleansheets/ecmascript/ast"
 	"github.com/jchv/cleansheets/ecmascript/errs"
@@ -20,7 +20,31 @@ import (
 type Lexer struct {
 	s         *Scanner
 	lastToken Token
+	start     ast.Location
 	newLine   bool
+	err       error
+	warnings  []errs.Diagnostic
+
+	// sourceMappingURL is the URL of the last source map comment, cleared
+	// by any token after it.
+	sourceMappingURL string
+
+	// comments are the comments read so far, if keepComments is set, and
+	// onComment is called with each comment as it is read.
+	keepComments bool
+	comments     []ast.Comment
+	onComment    func(ast.Comment)
+
+	// templates holds, for each template substitution being lexed, from the
+	// outermost in, the number of braces open in it. The } that closes the
+	// substitution continues its template literal.
+	templates []int
+
+	// buf and pat are scratch space for the source of the token being
+	// lexed, and the pattern of a regular expression, reused between tokens
+	// so that only the final string is allocated.
+	buf []byte
+	pat []byte
 }
 
 // Location returns the current source location of the lexer.
@@ -28,43 +52,160 @@ func (l *Lexer) Location() ast.Location {
 	return l.s.Location()
 }
 
+// Start returns the location where the last token began. At the end of the
+// input, it is the end of the last token.
+func (l *Lexer) Start() ast.Location {
+	return l.start
+}
+
+// Warnings returns the warnings for the input lexed so far.
+func (l *Lexer) Warnings() []errs.Diagnostic {
+	return l.warnings
+}
+
+// KeepComments makes the lexer keep the comments it reads from now on, for
+// Comments to return.
+func (l *Lexer) KeepComments() {
+	l.keepComments = true
+}
+
+// Comments returns the comments read so far, in the order they appear, if
+// KeepComments was called.
+func (l *Lexer) Comments() []ast.Comment {
+	return l.comments
+}
+
+// OnComment makes the lexer call f with each comment it reads from now on,
+// as it reads it.
+func (l *Lexer) OnComment(f func(ast.Comment)) {
+	l.onComment = f
+}
+
+// readingComments returns whether the comments read are kept or passed on,
+// so that their values are needed.
+func (l *Lexer) readingComments() bool {
+	return l.keepComments || l.onComment != nil
+}
+
 // NewLexer creates a new lexer.
 func NewLexer(s *Scanner) *Lexer {
 	return &Lexer{s: s}
 }
 
-// Lex returns the next token by scanning the input stream.
-func (l *Lexer) Lex() Token {
-	t := l.consumeNextToken()
+// LexContext is like Lex, but returns ctx.Err() instead of a token once ctx
+// is done.
+func (l *Lexer) LexContext(ctx context.Context) (Token, error) {
+	if err := ctx.Err(); err != nil {
+		return Token{}, err
+	}
+	return l.Lex()
+}
+
+// Lex returns the next token by scanning the input stream. After an error,
+// every call returns the same error.
+func (l *Lexer) Lex() (Token, error) {
+	if l.err != nil {
+		return Token{}, l.err
+	}
+	t, err := l.consumeNextToken()
+	if err = l.check(err); err != nil {
+		return Token{}, err
+	}
 	if l.newLine {
 		t.NewLine = true
 		l.newLine = false
 	}
+	if t.Type != TokenNone {
+		l.sourceMappingURL = ""
+	}
 	l.lastToken = t
-	return t
+	return t, nil
 }
 
 // ReLex relexes the last token as a regular expression.
-func (l *Lexer) ReLex() ReToken {
-	t := l.consumeRegex(l.lastToken)
+func (l *Lexer) ReLex() (ReToken, error) {
+	if l.err != nil {
+		return ReToken{}, l.err
+	}
+	t, err := l.consumeRegex(l.lastToken)
+	if err = l.check(err); err != nil {
+		return ReToken{}, err
+	}
 	l.lastToken = t.Token
-	return t
+	return t, nil
+}
+
+// check records the error that stopped lexing, if any. An error reading the
+// input takes precedence, since the scanner reports it as EOF and that is
+// what err will usually be about.
+func (l *Lexer) check(err error) error {
+	if serr := l.s.Err(); serr != nil {
+		err = serr
+	}
+	l.err = err
+	return err
+}
+
+// errorf returns a syntax error at the current location.
+func (l *Lexer) errorf(code errs.Code, format string, args ...interface{}) error {
+	return &errs.SyntaxError{
+		Location: l.s.Location(),
+		Code:     code,
+		Err:      fmt.Errorf(format, args...),
+	}
+}
+
+// unterminated returns a syntax error for input that ends before what began
+// at start does.
+func (l *Lexer) unterminated(code errs.Code, start ast.Location, what string) error {
+	return &errs.SyntaxError{
+		Location: l.s.Location(),
+		Code:     code,
+		Err:      fmt.Errorf("unexpected EOF in %s", what),
+		Labels:   []errs.Label{{Span: start.Span(), Message: what + " begins here"}},
+	}
+}
+
+// write appends r to buf, encoded as UTF-8.
+func (l *Lexer) write(r rune) {
+	l.buf = appendRune(l.buf, r)
+}
+
+// appendRune appends r to b, encoded as UTF-8.
+func appendRune(b []byte, r rune) []byte {
+	if r >= 0 && r < utf8.RuneSelf {
+		return append(b, byte(r))
+	}
+	var e [utf8.UTFMax]byte
+	n := utf8.EncodeRune(e[:], r)
+	return append(b, e[:n]...)
+}
+
+// numberToken returns a numeric literal token for a consumed literal.
+func numberToken(lit string, err error) (Token, error) {
+	if err != nil {
+		return Token{}, err
+	}
+	return Token{Type: TokenLiteralNumber, Literal: lit}, nil
 }
 
 // consumeRegex lexes a regex, using the passed token as initial state.
-func (l *Lexer) consumeRegex(t Token) ReToken {
-	lit := &strings.Builder{} // Literal - includes all runes
-	pat := &strings.Builder{} // Pattern - includes runes in pattern part
-	flg := &strings.Builder{} // Flag - includes runes in flag part
+func (l *Lexer) consumeRegex(t Token) (ReToken, error) {
+	// buf holds the literal, including all runes, and pat the runes in the
+	// pattern part. The flags are the end of the literal.
+
+	// The passed token is on one line and just behind us.
+	start := l.s.Location()
+	start.Column -= len(t.Source())
 
 	// Take the passed token and treat it as the start of the pattern.
-	lit.WriteString(t.Source())
-	pat.WriteString(t.Source()[1:])
+	l.buf = append(l.buf[:0], t.Source()...)
+	l.pat = append(l.pat[:0], t.Source()[1:]...)
 
 patternLoop:
 	for {
 		r := l.s.Read()
-		lit.WriteRune(r)
+		l.write(r)
 
 		switch r {
 		case '/':
@@ -74,166 +215,192 @@ patternLoop:
 		case '[':
 			// Consume character class. It is necessary to do this because / is
 			// allowed in a character class.
-			pat.WriteRune(r)
+			l.pat = appendRune(l.pat, r)
 			for {
 				r := l.s.Read()
-				lit.WriteRune(r)
-				pat.WriteRune(r)
+				l.write(r)
+				l.pat = appendRune(l.pat, r)
 
 				if r == '\\' {
 					r := l.s.Read()
-					lit.WriteRune(r)
-					pat.WriteRune(r)
+					l.write(r)
+					l.pat = appendRune(l.pat, r)
 				} else if r == ']' {
 					break
 				} else if r == EOFRune {
-					panic(&errs.SyntaxError{
-						Location: l.s.Location(),
-						Err:      errors.New("unexpected EOF"),
-					})
+					return ReToken{}, l.unterminated(errs.CodeUnterminatedRegExp, start, "regular expression")
 				}
 			}
 
 		case '\\':
 			// Escape sequence.
 			r = l.s.Read()
-			lit.WriteRune(r)
+			l.write(r)
 
 			if r == '/' || r == '\\' {
-				pat.WriteRune(r)
+				l.pat = appendRune(l.pat, r)
 			} else {
-				pat.WriteRune('\\')
-				pat.WriteRune(r)
+				l.pat = append(l.pat, '\\')
+				l.pat = appendRune(l.pat, r)
 			}
 
 		case EOFRune:
-			panic(&errs.SyntaxError{
-				Location: l.s.Location(),
-				Err:      errors.New("unexpected EOF"),
-			})
+			return ReToken{}, l.unterminated(errs.CodeUnterminatedRegExp, start, "regular expression")
 
 		default:
-			pat.WriteRune(r)
+			l.pat = appendRune(l.pat, r)
 		}
 	}
 
 	// Flag loop
+	flags := len(l.buf)
 	for {
 		r := l.s.Read()
 		if !isIdentifierContinue(r) {
 			l.s.Unread()
 			break
 		}
-		flg.WriteRune(r)
-		lit.WriteRune(r)
+		l.write(r)
 	}
 
+	lit := string(l.buf)
+	if err := checkRegExp(lit[1:flags-1], lit[flags:]); err != nil {
+		return ReToken{}, l.errorf(errs.CodeInvalidRegExp, "%v", err)
+	}
 	return ReToken{
 		Token: Token{
 			Type:    TokenLiteralRegExp,
-			Literal: lit.String(),
+			Literal: lit,
 		},
-		Pattern: pat.String(),
-		Flags:   flg.String(),
-	}
+		Pattern: string(l.pat),
+		Flags:   lit[flags:],
+	}, nil
 }
 
-// Consumes a multi-line comment, eating until after the next */.
-func (l *Lexer) consumeMultiLineComment() {
+// Consumes a multi-line comment, eating until after the next */. A comment
+// with a line terminator in it counts as one for automatic semicolon
+// insertion.
+func (l *Lexer) consumeMultiLineComment() error {
+	// The opening /* is just behind us.
+	start, off := l.s.Location(), l.s.Offset()-2
+	start.Column -= 2
+
+	l.buf = l.buf[:0]
 	var r rune
 	for {
 		r = l.s.Read()
 		switch r {
 		case '*':
-			switch l.s.Read() {
-			case '/':
-				return
-			case EOFRune:
-				panic(&errs.SyntaxError{
-					Location: l.s.Location(),
-					Err:      errors.New("unexpected EOF"),
-				})
+			if l.s.Read() == '/' {
+				l.addComment(true, start, off)
+				return nil
 			}
+			l.s.Unread()
 		case EOFRune:
-			panic(&errs.SyntaxError{
-				Location: l.s.Location(),
-				Err:      errors.New("unexpected EOF"),
-			})
+			return l.unterminated(errs.CodeUnterminatedComment, start, "comment")
+		default:
+			if isLineTerm(r) {
+				l.newLine = true
+			}
+		}
+		if l.readingComments() {
+			l.write(r)
 		}
 	}
 }
 
-// Consumes a single-line comment, eating until after the next line term.
+// Consumes a single-line comment, eating until the next line term.
 func (l *Lexer) consumeSingleLineComment() {
-	var r rune
-	for {
-		r = l.s.Read()
-		if isLineTerm(r) || r == EOFRune {
-			return
+	start, off := l.s.Location(), l.s.Offset()-2
+	start.Column -= 2
+
+	r := l.s.Read()
+	if r == '#' || r == '@' {
+		l.consumeMagicComment()
+		if l.readingComments() {
+			l.buf = append([]byte{byte(r)}, l.buf...)
+		}
+	} else {
+		l.buf = l.buf[:0]
+		for !isLineTerm(r) && r != EOFRune {
+			if l.readingComments() {
+				l.write(r)
+			}
+			r = l.s.Read()
 		}
+		l.s.Unread()
+	}
+	l.addComment(false, start, off)
+}
+
+// addComment keeps or passes on the comment that began at start and off, and
+// ends here, if comments are being read. Its value is in buf.
+func (l *Lexer) addComment(block bool, start ast.Location, off int) {
+	if !l.readingComments() {
+		return
+	}
+	c := ast.Comment{
+		Block: block,
+		Value: string(l.buf),
+		Span:  ast.Span{Start: start, End: l.s.Location()},
+		Range: [2]int{off, l.s.Offset()},
+	}
+	if l.keepComments {
+		l.comments = append(l.comments, c)
+	}
+	if l.onComment != nil {
+		l.onComment(c)
 	}
 }
 
 // Consumes an identifier.
-func (l *Lexer) consumeIdentifier(typ TokenType) Token {
+func (l *Lexer) consumeIdentifier(typ TokenType) (Token, error) {
 	r := l.s.Read()
 	if !isIdentifierStart(r) {
-		panic(&errs.SyntaxError{
-			Location: l.s.Location(),
-			Err:      fmt.Errorf("expected IdentifierStart, got %q", r),
-		})
+		return Token{}, l.errorf(errs.CodeUnexpectedCharacter, "expected IdentifierStart, got %q", r)
 	}
 
-	lit := &strings.Builder{}
-	lit.WriteRune(r)
+	l.buf = l.buf[:0]
+	l.write(r)
 	for {
+		l.buf = l.s.appendRun(l.buf, classIdentifierContinue)
 		r := l.s.Read()
 		if !isIdentifierContinue(r) {
 			l.s.Unread()
-			s := lit.String()
 			if typ == TokenIdentifier {
-				if t, ok := strToKeywordType[s]; ok {
-					return Token{Type: t, Literal: s}
+				if t, ok := strToKeywordType[string(l.buf)]; ok {
+					return Token{Type: t, Literal: keywordLiterals[t]}, nil
 				}
 			}
 			return Token{
 				Type:    typ,
-				Literal: s,
-			}
+				Literal: string(l.buf),
+			}, nil
 		}
-		lit.WriteRune(r)
+		l.write(r)
 	}
 }
 
-// Consumes binary digits.
-func (l *Lexer) consumeBinaryPart(lit *strings.Builder) string {
-	if lit == nil {
-		lit = &strings.Builder{}
-	}
+// Consumes binary digits, appending them to buf.
+func (l *Lexer) consumeBinaryPart() (string, error) {
 	r := l.s.Read()
 
 	if isBinaryDigit(r) {
-		lit.WriteRune(r)
+		l.write(r)
 	} else {
-		panic(&errs.SyntaxError{
-			Location: l.s.Location(),
-			Err:      fmt.Errorf("expected BinaryDigit, got %q", r),
-		})
+		return "", l.errorf(errs.CodeInvalidNumber, "expected BinaryDigit, got %q", r)
 	}
 
 	for {
 		r = l.s.Read()
 		if isBinaryDigit(r) {
-			lit.WriteRune(r)
+			l.write(r)
 		} else if isNumericLiteralSeparator(r) {
 			r = l.s.Read()
 			if isBinaryDigit(r) {
-				lit.WriteRune(r)
+				l.write(r)
 			} else {
-				panic(&errs.SyntaxError{
-					Location: l.s.Location(),
-					Err:      fmt.Errorf("expected BinaryDigit, got %q", r),
-				})
+				return "", l.errorf(errs.CodeInvalidNumber, "expected BinaryDigit, got %q", r)
 			}
 		} else {
 			l.s.Unread()
@@ -241,37 +408,28 @@ func (l *Lexer) consumeBinaryPart(lit *strings.Builder) string {
 		}
 	}
 
-	return lit.String()
+	return string(l.buf), nil
 }
 
-func (l *Lexer) consumeOctalPart(lit *strings.Builder) string {
-	if lit == nil {
-		lit = &strings.Builder{}
-	}
+func (l *Lexer) consumeOctalPart() (string, error) {
 	r := l.s.Read()
 
 	if isOctalDigit(r) {
-		lit.WriteRune(r)
+		l.write(r)
 	} else {
-		panic(&errs.SyntaxError{
-			Location: l.s.Location(),
-			Err:      fmt.Errorf("expected OctalDigit, got %q", r),
-		})
+		return "", l.errorf(errs.CodeInvalidNumber, "expected OctalDigit, got %q", r)
 	}
 
 	for {
 		r = l.s.Read()
 		if isOctalDigit(r) {
-			lit.WriteRune(r)
+			l.write(r)
 		} else if isNumericLiteralSeparator(r) {
 			r = l.s.Read()
 			if isOctalDigit(r) {
-				lit.WriteRune(r)
+				l.write(r)
 			} else {
-				panic(&errs.SyntaxError{
-					Location: l.s.Location(),
-					Err:      fmt.Errorf("expected OctalDigit, got %q", r),
-				})
+				return "", l.errorf(errs.CodeInvalidNumber, "expected OctalDigit, got %q", r)
 			}
 		} else {
 			l.s.Unread()
@@ -279,37 +437,28 @@ func (l *Lexer) consumeOctalPart(lit *strings.Builder) string {
 		}
 	}
 
-	return lit.String()
+	return string(l.buf), nil
 }
 
-func (l *Lexer) consumeHexPart(lit *strings.Builder) string {
-	if lit == nil {
-		lit = &strings.Builder{}
-	}
+func (l *Lexer) consumeHexPart() (string, error) {
 	r := l.s.Read()
 
 	if isHexDigit(r) {
-		lit.WriteRune(r)
+		l.write(r)
 	} else {
-		panic(&errs.SyntaxError{
-			Location: l.s.Location(),
-			Err:      fmt.Errorf("expected HexDigit, got %q", r),
-		})
+		return "", l.errorf(errs.CodeInvalidNumber, "expected HexDigit, got %q", r)
 	}
 
 	for {
 		r = l.s.Read()
 		if isHexDigit(r) {
-			lit.WriteRune(r)
+			l.write(r)
 		} else if isNumericLiteralSeparator(r) {
 			r = l.s.Read()
 			if isHexDigit(r) {
-				lit.WriteRune(r)
+				l.write(r)
 			} else {
-				panic(&errs.SyntaxError{
-					Location: l.s.Location(),
-					Err:      fmt.Errorf("expected HexDigit, got %q", r),
-				})
+				return "", l.errorf(errs.CodeInvalidNumber, "expected HexDigit, got %q", r)
 			}
 		} else {
 			l.s.Unread()
@@ -317,45 +466,37 @@ func (l *Lexer) consumeHexPart(lit *strings.Builder) string {
 		}
 	}
 
-	return lit.String()
+	return string(l.buf), nil
 }
 
-func (l *Lexer) consumeDecimalPart(lit *strings.Builder) string {
-	if lit == nil {
-		lit = &strings.Builder{}
-	}
+func (l *Lexer) consumeDecimalPart() (string, error) {
 	r := l.s.Read()
 
 	if !isDecimalDigit(r) {
-		panic(&errs.SyntaxError{
-			Location: l.s.Location(),
-			Err:      fmt.Errorf("expected DecimalDigit, got %q", r),
-		})
+		return "", l.errorf(errs.CodeInvalidNumber, "expected DecimalDigit, got %q", r)
 	}
-	lit.WriteRune(r)
+	l.write(r)
 
 	for {
+		l.buf = l.s.appendRun(l.buf, classDecimalDigit)
 		r = l.s.Read()
 		if isDecimalDigit(r) {
-			lit.WriteRune(r)
+			l.write(r)
 		} else if isNumericLiteralSeparator(r) {
 			r = l.s.Read()
 			if isDecimalDigit(r) {
-				lit.WriteRune(r)
+				l.write(r)
 			} else {
-				panic(&errs.SyntaxError{
-					Location: l.s.Location(),
-					Err:      fmt.Errorf("expected DecimalDigit, got %q", r),
-				})
+				return "", l.errorf(errs.CodeInvalidNumber, "expected DecimalDigit, got %q", r)
 			}
 		} else if r == '.' {
-			lit.WriteRune(r)
-			return l.consumeFractionalPart(lit)
+			l.write(r)
+			return l.consumeFractionalPart()
 		} else if isExponentIndicator(r) {
 			for {
 				r = l.s.Read()
 				if isDecimalDigit(r) {
-					lit.WriteRune(r)
+					l.write(r)
 				} else {
 					l.s.Unread()
 					break
@@ -368,37 +509,29 @@ func (l *Lexer) consumeDecimalPart(lit *strings.Builder) string {
 		}
 	}
 
-	return lit.String()
+	return string(l.buf), nil
 }
 
-func (l *Lexer) consumeFractionalPart(lit *strings.Builder) string {
-	if lit == nil {
-		lit = &strings.Builder{}
-	}
+func (l *Lexer) consumeFractionalPart() (string, error) {
 	r := l.s.Read()
 
 	if isDecimalDigit(r) {
-		lit.WriteRune(r)
+		l.write(r)
 	} else {
-		panic(&errs.SyntaxError{
-			Location: l.s.Location(),
-			Err:      fmt.Errorf("expected DecimalDigit, got %q", r),
-		})
+		return "", l.errorf(errs.CodeInvalidNumber, "expected DecimalDigit, got %q", r)
 	}
 
 	for {
+		l.buf = l.s.appendRun(l.buf, classDecimalDigit)
 		r = l.s.Read()
 		if isDecimalDigit(r) {
-			lit.WriteRune(r)
+			l.write(r)
 		} else if isNumericLiteralSeparator(r) {
 			r = l.s.Read()
 			if isDecimalDigit(r) {
-				lit.WriteRune(r)
+				l.write(r)
 			} else {
-				panic(&errs.SyntaxError{
-					Location: l.s.Location(),
-					Err:      fmt.Errorf("expected DecimalDigit, got %q", r),
-				})
+				return "", l.errorf(errs.CodeInvalidNumber, "expected DecimalDigit, got %q", r)
 			}
 		} else {
 			l.s.Unread()
@@ -409,28 +542,25 @@ func (l *Lexer) consumeFractionalPart(lit *strings.Builder) string {
 	r = l.s.Read()
 	if !isExponentIndicator(r) {
 		l.s.Unread()
-		return lit.String()
+		return string(l.buf), nil
 	}
-	lit.WriteRune(r)
+	l.write(r)
 
 	r = l.s.Read()
 	if r != '+' && r != '-' && !isDecimalDigit(r) {
-		panic(&errs.SyntaxError{
-			Location: l.s.Location(),
-			Err:      fmt.Errorf("expected DecimalDigit, +, or -, got %q", r),
-		})
+		return "", l.errorf(errs.CodeInvalidNumber, "expected DecimalDigit, +, or -, got %q", r)
 	}
-	lit.WriteRune(r)
+	l.write(r)
 
 	for {
 		r = l.s.Read()
 		if isDecimalDigit(r) {
-			lit.WriteRune(r)
+			l.write(r)
 		} else if isExponentIndicator(r) {
 			for {
 				r = l.s.Read()
 				if isDecimalDigit(r) {
-					lit.WriteRune(r)
+					l.write(r)
 				} else {
 					l.s.Unread()
 					break
@@ -443,43 +573,117 @@ func (l *Lexer) consumeFractionalPart(lit *strings.Builder) string {
 		}
 	}
 
-	return lit.String()
+	return string(l.buf), nil
 }
 
-func (l *Lexer) consumeStringLiteral() Token {
+func (l *Lexer) consumeStringLiteral() (Token, error) {
+	start := l.s.Location()
 	quo := l.s.Read()
 	if quo != '\'' && quo != '"' {
-		panic("unexpected string literal quote")
+		return Token{}, l.errorf(errs.CodeUnexpectedCharacter, "expected string literal, got %q", quo)
 	}
 
-	c := []rune{quo}
+	l.buf = append(l.buf[:0], byte(quo))
 	for {
 		r := l.s.Read()
-		c = append(c, r)
+		l.write(r)
 		if r == quo {
 			break
 		}
 		if r == '\\' {
+			esc := l.s.Location()
+			esc.Column--
 			r = l.s.Read()
-			c = append(c, r)
+			l.write(r)
+			if r >= '0' && r <= '7' {
+				l.consumeOctalEscape(esc, r)
+			}
 		}
 		if r == EOFRune {
-			panic(&errs.SyntaxError{
-				Location: l.s.Location(),
-				Err:      errors.New("unexpected EOF"),
-			})
+			return Token{}, l.unterminated(errs.CodeUnterminatedString, start, "string literal")
 		}
 	}
 
 	return Token{
 		Type:    TokenLiteralString,
-		Literal: string(c),
+		Literal: string(l.buf),
+	}, nil
+}
+
+// consumeTemplate consumes a part of a template literal: the whole of one
+// without substitutions, or the text from the ` or } before a substitution
+// up to the ${ or ` after it. The escapes in it are left for the parser to
+// check, since they are allowed to be invalid in tagged templates.
+func (l *Lexer) consumeTemplate() (Token, error) {
+	start := l.s.Location()
+	l.buf = append(l.buf[:0], byte(l.s.Read()))
+	for {
+		r := l.s.Read()
+		if r == EOFRune {
+			return Token{}, l.unterminated(errs.CodeUnterminatedTemplate, start, "template literal")
+		}
+		l.write(r)
+		switch r {
+		case '`':
+			return Token{Type: TokenLiteralTemplate, Literal: string(l.buf)}, nil
+		case '$':
+			if l.s.Read() == '{' {
+				l.write('{')
+				l.templates = append(l.templates, 0)
+				return Token{Type: TokenLiteralTemplate, Literal: string(l.buf)}, nil
+			}
+			l.s.Unread()
+		case '\\':
+			r = l.s.Read()
+			if r == EOFRune {
+				return Token{}, l.unterminated(errs.CodeUnterminatedTemplate, start, "template literal")
+			}
+			l.write(r)
+		}
+	}
+}
+
+// consumeOctalEscape consumes the rest of an escape sequence beginning with
+// the octal digit first, appending the runes consumed after it to buf. Every such
+// escape other than `\0` on its own is a legacy octal escape, which is
+// warned about.
+func (l *Lexer) consumeOctalEscape(start ast.Location, first rune) {
+	// Up to three digits, as long as the value fits in a byte.
+	n := 3
+	if first > '3' {
+		n = 2
+	}
+	value := int(first - '0')
+	digits := 1
+	next := l.s.Read()
+	for digits < n && next >= '0' && next <= '7' {
+		l.write(next)
+		digits++
+		value = value*8 + int(next-'0')
+		next = l.s.Read()
 	}
+	l.s.Unread()
+
+	if first == '0' && digits == 1 && next != '8' && next != '9' {
+		return
+	}
+	l.warnings = append(l.warnings, errs.Diagnostic{
+		Code:     errs.CodeLegacyOctalEscape,
+		Severity: errs.SeverityWarning,
+		Span:     ast.Span{Start: start, End: l.s.Location()},
+		Message:  "legacy octal escape sequence",
+		Suggestions: []errs.Suggestion{{
+			Message:     "use a hexadecimal escape sequence instead",
+			Span:        ast.Span{Start: start, End: l.s.Location()},
+			Replacement: fmt.Sprintf("\\x%02x", value),
+		}},
+	})
 }
 
-func (l *Lexer) consumeNextToken() Token {
+func (l *Lexer) consumeNextToken() (Token, error) {
 	var r rune
 	for {
+		l.s.skipRun(classWhiteSpace)
 		r = l.s.Read()
 		if isLineTerm(r) {
 			l.newLine = true
@@ -488,84 +692,93 @@ func (l *Lexer) consumeNextToken() Token {
 		if isWhiteSpace(r) {
 			continue
 		}
+		if r != EOFRune {
+			// r is on the same row as the location after it.
+			l.start = l.s.Location()
+			l.start.Column--
+		}
 		switch r {
 		case '{':
-			return Token{Type: TokenPunctuatorOpenBrace}
+			if n := len(l.templates); n > 0 {
+				l.templates[n-1]++
+			}
+			return Token{Type: TokenPunctuatorOpenBrace}, nil
 		case '(':
-			return Token{Type: TokenPunctuatorOpenParen}
+			return Token{Type: TokenPunctuatorOpenParen}, nil
 		case '[':
-			return Token{Type: TokenPunctuatorOpenBracket}
+			return Token{Type: TokenPunctuatorOpenBracket}, nil
 		case ']':
-			return Token{Type: TokenPunctuatorCloseBracket}
+			return Token{Type: TokenPunctuatorCloseBracket}, nil
 		case ')':
-			return Token{Type: TokenPunctuatorCloseParen}
+			return Token{Type: TokenPunctuatorCloseParen}, nil
 		case '}':
-			return Token{Type: TokenPunctuatorCloseBrace}
+			if n := len(l.templates); n > 0 {
+				if l.templates[n-1] == 0 {
+					l.templates = l.templates[:n-1]
+					l.s.Unread()
+					return l.consumeTemplate()
+				}
+				l.templates[n-1]--
+			}
+			return Token{Type: TokenPunctuatorCloseBrace}, nil
 		case '.':
 			switch l.s.Read() {
 			case '.':
 				switch l.s.Read() {
 				case '.':
-					return Token{Type: TokenPunctuatorEllipsis}
+					return Token{Type: TokenPunctuatorEllipsis}, nil
 				default:
-					panic(&errs.SyntaxError{
-						Location: l.s.Location(),
-						Err:      fmt.Errorf("expected ., got %q", r),
-					})
+					return Token{}, l.errorf(errs.CodeInvalidNumber, "expected ., got %q", r)
 				}
 			case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 				l.s.Unread()
-				lit := &strings.Builder{}
-				lit.WriteRune(r)
-				return Token{Type: TokenLiteralNumber, Literal: l.consumeFractionalPart(lit)}
+				l.buf = append(l.buf[:0], '.')
+				return numberToken(l.consumeFractionalPart())
 			default:
 				l.s.Unread()
-				return Token{Type: TokenPunctuatorDot}
+				return Token{Type: TokenPunctuatorDot}, nil
 			}
 		case '0':
-			lit := &strings.Builder{}
-			lit.WriteRune(r)
+			l.buf = append(l.buf[:0], '0')
 			r = l.s.Read()
 			switch r {
 			case 'n':
-				return Token{Type: TokenLiteralNumber, Literal: "0n"}
+				return Token{Type: TokenLiteralNumber, Literal: "0n"}, nil
 			case 'b':
-				lit.WriteRune(r)
-				return Token{Type: TokenLiteralNumber, Literal: l.consumeBinaryPart(lit)}
+				l.write(r)
+				return numberToken(l.consumeBinaryPart())
 			case 'B':
-				lit.WriteRune(r)
-				return Token{Type: TokenLiteralNumber, Literal: l.consumeBinaryPart(lit)}
+				l.write(r)
+				return numberToken(l.consumeBinaryPart())
 			case 'o':
-				lit.WriteRune(r)
-				return Token{Type: TokenLiteralNumber, Literal: l.consumeOctalPart(lit)}
+				l.write(r)
+				return numberToken(l.consumeOctalPart())
 			case 'O':
-				lit.WriteRune(r)
-				return Token{Type: TokenLiteralNumber, Literal: l.consumeOctalPart(lit)}
+				l.write(r)
+				return numberToken(l.consumeOctalPart())
 			case 'x':
-				lit.WriteRune(r)
-				return Token{Type: TokenLiteralNumber, Literal: l.consumeHexPart(lit)}
+				l.write(r)
+				return numberToken(l.consumeHexPart())
 			case 'X':
-				lit.WriteRune(r)
-				return Token{Type: TokenLiteralNumber, Literal: l.consumeHexPart(lit)}
+				l.write(r)
+				return numberToken(l.consumeHexPart())
 			case '_':
-				panic(&errs.SyntaxError{
-					Location: l.s.Location(),
-					Err:      fmt.Errorf("numeric separator can not be used after leading 0"),
-				})
+				return Token{}, l.errorf(errs.CodeInvalidNumber, "numeric separator can not be used after leading 0")
 			case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 				l.s.Unread()
-				return Token{Type: TokenLiteralNumber, Literal: l.consumeDecimalPart(lit)}
+				return numberToken(l.consumeDecimalPart())
 			default:
 				l.s.Unread()
-				return Token{Type: TokenLiteralNumber, Literal: "0"}
+				return Token{Type: TokenLiteralNumber, Literal: "0"}, nil
 			}
 		case '1', '2', '3', '4', '5', '6', '7', '8', '9':
 			l.s.Unread()
-			return Token{Type: TokenLiteralNumber, Literal: l.consumeDecimalPart(nil)}
+			l.buf = l.buf[:0]
+			return numberToken(l.consumeDecimalPart())
 		case ';':
-			return Token{Type: TokenPunctuatorSemicolon}
+			return Token{Type: TokenPunctuatorSemicolon}, nil
 		case ',':
-			return Token{Type: TokenPunctuatorComma}
+			return Token{Type: TokenPunctuatorComma}, nil
 		case '<':
 			switch l.s.Read() {
 			case '<':
@@ -573,22 +786,22 @@ func (l *Lexer) consumeNextToken() Token {
 				case '<':
 					switch l.s.Read() {
 					case '=':
-						return Token{Type: TokenPunctuatorUnsignedRShiftAssign}
+						return Token{Type: TokenPunctuatorUnsignedRShiftAssign}, nil
 					default:
 						l.s.Unread()
-						return Token{Type: TokenPunctuatorUnsignedRShift}
+						return Token{Type: TokenPunctuatorUnsignedRShift}, nil
 					}
 				case '=':
-					return Token{Type: TokenPunctuatorLShiftAssign}
+					return Token{Type: TokenPunctuatorLShiftAssign}, nil
 				default:
 					l.s.Unread()
-					return Token{Type: TokenPunctuatorLShift}
+					return Token{Type: TokenPunctuatorLShift}, nil
 				}
 			case '=':
-				return Token{Type: TokenPunctuatorLessThanEqual}
+				return Token{Type: TokenPunctuatorLessThanEqual}, nil
 			default:
 				l.s.Unread()
-				return Token{Type: TokenPunctuatorLessThan}
+				return Token{Type: TokenPunctuatorLessThan}, nil
 			}
 		case '>':
 			switch l.s.Read() {
@@ -597,154 +810,154 @@ func (l *Lexer) consumeNextToken() Token {
 				case '>':
 					switch l.s.Read() {
 					case '=':
-						return Token{Type: TokenPunctuatorUnsignedRShiftAssign}
+						return Token{Type: TokenPunctuatorUnsignedRShiftAssign}, nil
 					default:
 						l.s.Unread()
-						return Token{Type: TokenPunctuatorUnsignedRShift}
+						return Token{Type: TokenPunctuatorUnsignedRShift}, nil
 					}
 				case '=':
-					return Token{Type: TokenPunctuatorRShiftAssign}
+					return Token{Type: TokenPunctuatorRShiftAssign}, nil
 				default:
 					l.s.Unread()
-					return Token{Type: TokenPunctuatorRShift}
+					return Token{Type: TokenPunctuatorRShift}, nil
 				}
 			case '=':
-				return Token{Type: TokenPunctuatorGreaterThanEqual}
+				return Token{Type: TokenPunctuatorGreaterThanEqual}, nil
 			default:
 				l.s.Unread()
-				return Token{Type: TokenPunctuatorGreaterThan}
+				return Token{Type: TokenPunctuatorGreaterThan}, nil
 			}
 		case '=':
 			switch l.s.Read() {
 			case '=':
 				switch l.s.Read() {
 				case '=':
-					return Token{Type: TokenPunctuatorStrictEqual}
+					return Token{Type: TokenPunctuatorStrictEqual}, nil
 				default:
 					l.s.Unread()
-					return Token{Type: TokenPunctuatorEqual}
+					return Token{Type: TokenPunctuatorEqual}, nil
 				}
 			case '>':
-				return Token{Type: TokenPunctuatorFatArrow}
+				return Token{Type: TokenPunctuatorFatArrow}, nil
 			default:
 				l.s.Unread()
-				return Token{Type: TokenPunctuatorAssign}
+				return Token{Type: TokenPunctuatorAssign}, nil
 			}
 		case '!':
 			switch l.s.Read() {
 			case '=':
 				switch l.s.Read() {
 				case '=':
-					return Token{Type: TokenPunctuatorStrictNotEqual}
+					return Token{Type: TokenPunctuatorStrictNotEqual}, nil
 				default:
 					l.s.Unread()
-					return Token{Type: TokenPunctuatorNotEqual}
+					return Token{Type: TokenPunctuatorNotEqual}, nil
 				}
 			default:
 				l.s.Unread()
-				return Token{Type: TokenPunctuatorNot}
+				return Token{Type: TokenPunctuatorNot}, nil
 			}
 		case '+':
 			switch l.s.Read() {
 			case '+':
-				return Token{Type: TokenPunctuatorIncrement}
+				return Token{Type: TokenPunctuatorIncrement}, nil
 			case '=':
-				return Token{Type: TokenPunctuatorPlusAssign}
+				return Token{Type: TokenPunctuatorPlusAssign}, nil
 			default:
 				l.s.Unread()
-				return Token{Type: TokenPunctuatorPlus}
+				return Token{Type: TokenPunctuatorPlus}, nil
 			}
 		case '-':
 			switch l.s.Read() {
 			case '-':
-				return Token{Type: TokenPunctuatorDecrement}
+				return Token{Type: TokenPunctuatorDecrement}, nil
 			case '=':
-				return Token{Type: TokenPunctuatorMinusAssign}
+				return Token{Type: TokenPunctuatorMinusAssign}, nil
 			default:
 				l.s.Unread()
-				return Token{Type: TokenPunctuatorMinus}
+				return Token{Type: TokenPunctuatorMinus}, nil
 			}
 		case '&':
 			switch l.s.Read() {
 			case '&':
 				switch l.s.Read() {
 				case '=':
-					return Token{Type: TokenPunctuatorLogicalAndAssign}
+					return Token{Type: TokenPunctuatorLogicalAndAssign}, nil
 				default:
 					l.s.Unread()
-					return Token{Type: TokenPunctuatorLogicalAnd}
+					return Token{Type: TokenPunctuatorLogicalAnd}, nil
 				}
 			case '=':
-				return Token{Type: TokenPunctuatorBitAndAssign}
+				return Token{Type: TokenPunctuatorBitAndAssign}, nil
 			default:
 				l.s.Unread()
-				return Token{Type: TokenPunctuatorBitAnd}
+				return Token{Type: TokenPunctuatorBitAnd}, nil
 			}
 		case '|':
 			switch l.s.Read() {
 			case '|':
 				switch l.s.Read() {
 				case '=':
-					return Token{Type: TokenPunctuatorLogicalOrAssign}
+					return Token{Type: TokenPunctuatorLogicalOrAssign}, nil
 				default:
 					l.s.Unread()
-					return Token{Type: TokenPunctuatorLogicalOr}
+					return Token{Type: TokenPunctuatorLogicalOr}, nil
 				}
 			case '=':
-				return Token{Type: TokenPunctuatorBitOrAssign}
+				return Token{Type: TokenPunctuatorBitOrAssign}, nil
 			default:
 				l.s.Unread()
-				return Token{Type: TokenPunctuatorBitOr}
+				return Token{Type: TokenPunctuatorBitOr}, nil
 			}
 		case '^':
 			switch l.s.Read() {
 			case '=':
-				return Token{Type: TokenPunctuatorBitXorAssign}
+				return Token{Type: TokenPunctuatorBitXorAssign}, nil
 			default:
 				l.s.Unread()
-				return Token{Type: TokenPunctuatorBitXor}
+				return Token{Type: TokenPunctuatorBitXor}, nil
 			}
 		case '~':
-			return Token{Type: TokenPunctuatorBitNot}
+			return Token{Type: TokenPunctuatorBitNot}, nil
 		case '?':
 			switch l.s.Read() {
 			case '?':
 				switch l.s.Read() {
 				case '=':
-					return Token{Type: TokenPunctuatorNullCoalesceAssign}
+					return Token{Type: TokenPunctuatorNullCoalesceAssign}, nil
 				default:
 					l.s.Unread()
-					return Token{Type: TokenPunctuatorNullCoalesce}
+					return Token{Type: TokenPunctuatorNullCoalesce}, nil
 				}
 			default:
 				l.s.Unread()
-				return Token{Type: TokenPunctuatorQuestionMark}
+				return Token{Type: TokenPunctuatorQuestionMark}, nil
 			}
 		case ':':
-			return Token{Type: TokenPunctuatorColon}
+			return Token{Type: TokenPunctuatorColon}, nil
 		case '*':
 			switch l.s.Read() {
 			case '*':
 				switch l.s.Read() {
 				case '=':
-					return Token{Type: TokenPunctuatorExponentAssign}
+					return Token{Type: TokenPunctuatorExponentAssign}, nil
 				default:
 					l.s.Unread()
-					return Token{Type: TokenPunctuatorExponent}
+					return Token{Type: TokenPunctuatorExponent}, nil
 				}
 			case '=':
-				return Token{Type: TokenPunctuatorMultAssign}
+				return Token{Type: TokenPunctuatorMultAssign}, nil
 			default:
 				l.s.Unread()
-				return Token{Type: TokenPunctuatorMult}
+				return Token{Type: TokenPunctuatorMult}, nil
 			}
 		case '%':
 			switch l.s.Read() {
 			case '=':
-				return Token{Type: TokenPunctuatorModAssign}
+				return Token{Type: TokenPunctuatorModAssign}, nil
 			default:
 				l.s.Unread()
-				return Token{Type: TokenPunctuatorMod}
+				return Token{Type: TokenPunctuatorMod}, nil
 			}
 		case '/':
 			switch l.s.Read() {
@@ -752,31 +965,33 @@ func (l *Lexer) consumeNextToken() Token {
 				l.consumeSingleLineComment()
 				continue
 			case '*':
-				l.consumeMultiLineComment()
+				if err := l.consumeMultiLineComment(); err != nil {
+					return Token{}, err
+				}
 				continue
 			case '=':
-				return Token{Type: TokenPunctuatorDivAssign}
+				return Token{Type: TokenPunctuatorDivAssign}, nil
 			default:
 				l.s.Unread()
-				return Token{Type: TokenPunctuatorDiv}
+				return Token{Type: TokenPunctuatorDiv}, nil
 			}
 		case '"', '\'':
 			l.s.Unread()
 			return l.consumeStringLiteral()
+		case '`':
+			l.s.Unread()
+			return l.consumeTemplate()
 		case '#':
 			return l.consumeIdentifier(TokenPrivateIdentifier)
 		case EOFRune:
-			return Token{Type: TokenNone}
+			return Token{Type: TokenNone}, nil
 		default:
 			if isIdentifierStart(r) {
 				l.s.Unread()
 				return l.consumeIdentifier(TokenIdentifier)
 			}
 
-			panic(&errs.SyntaxError{
-				Location: l.s.Location(),
-				Err:      fmt.Errorf("unexpected rune %q", r),
-			})
+			return Token{}, l.errorf(errs.CodeUnexpectedCharacter, "unexpected rune %q", r)
 		}
 	}
 }
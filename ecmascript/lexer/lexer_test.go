@@ -1,18 +1,27 @@
 package lexer
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
 )
 
-func lexAll(s string) (t []Token) {
+func lexAll(s string) (t []Token, err error) {
 	l := NewLexer(NewScanner(strings.NewReader(s), nil))
 	for {
-		token := l.Lex()
-		if token.Type == TokenNone {
-			return t
+		token, err := l.Lex()
+		if err != nil || token.Type == TokenNone {
+			return t, err
 		}
 		t = append(t, token)
 	}
@@ -98,14 +107,518 @@ func TestLex(t *testing.T) {
 				{Type: TokenPunctuatorCloseBrace, NewLine: true},
 			},
 		},
+		{
+			"`a${b + {c}.c}d${`e${f}`}`",
+			[]Token{
+				{Type: TokenLiteralTemplate, Literal: "`a${"},
+				{Type: TokenIdentifier, Literal: "b"},
+				{Type: TokenPunctuatorPlus},
+				{Type: TokenPunctuatorOpenBrace},
+				{Type: TokenIdentifier, Literal: "c"},
+				{Type: TokenPunctuatorCloseBrace},
+				{Type: TokenPunctuatorDot},
+				{Type: TokenIdentifier, Literal: "c"},
+				{Type: TokenLiteralTemplate, Literal: "}d${"},
+				{Type: TokenLiteralTemplate, Literal: "`e${"},
+				{Type: TokenIdentifier, Literal: "f"},
+				{Type: TokenLiteralTemplate, Literal: "}`"},
+				{Type: TokenLiteralTemplate, Literal: "}`"},
+			},
+		},
+		{
+			"`$\\`${'}'}`} ``",
+			[]Token{
+				{Type: TokenLiteralTemplate, Literal: "`$\\`${"},
+				{Type: TokenLiteralString, Literal: "'}'"},
+				{Type: TokenLiteralTemplate, Literal: "}`"},
+				{Type: TokenPunctuatorCloseBrace},
+				{Type: TokenLiteralTemplate, Literal: "``"},
+			},
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(strconv.Quote(test.s), func(t *testing.T) {
-			result := lexAll(test.s)
+			result, err := lexAll(test.s)
+			if err != nil {
+				t.Fatal(err)
+			}
 			if !reflect.DeepEqual(result, test.t) {
 				t.Errorf("lex(%q) = %v != %v", test.s, result, test.t)
 			}
 		})
 	}
 }
+
+func TestLexError(t *testing.T) {
+	tests := []struct {
+		s      string
+		column int
+		code   errs.Code
+		err    string
+		label  int
+	}{
+		{`"abc`, 5, errs.CodeUnterminatedString, "unexpected EOF in string literal", 1},
+		{"a /* b", 7, errs.CodeUnterminatedComment, "unexpected EOF in comment", 3},
+		{"a /* b *", 9, errs.CodeUnterminatedComment, "unexpected EOF in comment", 3},
+		{"0x;", 4, errs.CodeInvalidNumber, "expected HexDigit, got ';'", 0},
+		{"0b1_2", 6, errs.CodeInvalidNumber, "expected BinaryDigit, got '2'", 0},
+		{"a @", 4, errs.CodeUnexpectedCharacter, "unexpected rune '@'", 0},
+		{"a `b${c}", 9, errs.CodeUnterminatedTemplate, "unexpected EOF in template literal", 8},
+		{"`\\", 3, errs.CodeUnterminatedTemplate, "unexpected EOF in template literal", 1},
+	}
+
+	for _, test := range tests {
+		t.Run(strconv.Quote(test.s), func(t *testing.T) {
+			_, err := lexAll(test.s)
+			var serr *errs.SyntaxError
+			if !errors.As(err, &serr) {
+				t.Fatalf("expected syntax error, got %v", err)
+			}
+			if serr.Location.Column != test.column || serr.Code != test.code || serr.Err.Error() != test.err {
+				t.Errorf("expected %s %q at column %d, got %s %v", test.code, test.err, test.column, serr.Code, err)
+			}
+			if test.label == 0 {
+				if len(serr.Labels) != 0 {
+					t.Errorf("expected no labels, got %v", serr.Labels)
+				}
+			} else if len(serr.Labels) != 1 || serr.Labels[0].Span.Start.Column != test.label {
+				t.Errorf("expected label at column %d, got %v", test.label, serr.Labels)
+			}
+		})
+	}
+}
+
+func TestTemplateValues(t *testing.T) {
+	tests := []struct {
+		literal, raw, cooked, err string
+		tail                      bool
+	}{
+		{literal: "`a`", raw: "a", cooked: "a", tail: true},
+		{literal: "}a\r\nb${", raw: "a\nb", cooked: "a\nb"},
+		{literal: "`\\n\\x41\\u0042\\u{43}\\uD83D\\uDE00\\0\\`\\\n.`", raw: "\\n\\x41\\u0042\\u{43}\\uD83D\\uDE00\\0\\`\\\n.", cooked: "\nABC\U0001F600\x00`.", tail: true},
+		{literal: "`\\01`", raw: "\\01", err: "octal escape sequences are not allowed in template literals", tail: true},
+		{literal: "`\\8`", raw: "\\8", err: "\\8 is not allowed in template literals", tail: true},
+		{literal: "`\\xg`", raw: "\\xg", err: "expected two hexadecimal digits after \\x", tail: true},
+		{literal: "`\\u{110000}`", raw: "\\u{110000}", err: "expected a code point in braces after \\u", tail: true},
+	}
+	for _, test := range tests {
+		tok := Token{Type: TokenLiteralTemplate, Literal: test.literal}
+		if raw := tok.TemplateRaw(); raw != test.raw {
+			t.Errorf("%q: expected raw %q, got %q", test.literal, test.raw, raw)
+		}
+		if tail := tok.TemplateTail(); tail != test.tail {
+			t.Errorf("%q: expected tail %v, got %v", test.literal, test.tail, tail)
+		}
+		cooked, err := tok.TemplateCooked()
+		switch {
+		case test.err != "" && (err == nil || err.Error() != test.err):
+			t.Errorf("%q: expected error %q, got %v", test.literal, test.err, err)
+		case test.err == "" && (err != nil || cooked != test.cooked):
+			t.Errorf("%q: expected cooked %q, got %q (%v)", test.literal, test.cooked, cooked, err)
+		}
+	}
+}
+
+func TestLexWarnings(t *testing.T) {
+	tests := []struct {
+		s       string
+		literal string
+		spans   [][2]int
+		fixes   []string
+	}{
+		{`"\0"`, `"\0"`, nil, nil},
+		{`"a\tb"`, `"a\tb"`, nil, nil},
+		{`"\1"`, `"\1"`, [][2]int{{2, 4}}, []string{`\x01`}},
+		{`"\08"`, `"\08"`, [][2]int{{2, 4}}, []string{`\x00`}},
+		{`"\101\1011"`, `"\101\1011"`, [][2]int{{2, 6}, {6, 10}}, []string{`\x41`, `\x41`}},
+		{`"\477"`, `"\477"`, [][2]int{{2, 5}}, []string{`\x27`}},
+		{`'\7'`, `'\7'`, [][2]int{{2, 4}}, []string{`\x07`}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.s, func(t *testing.T) {
+			l := NewLexer(NewScanner(strings.NewReader(test.s), nil))
+			token, err := l.Lex()
+			if err != nil || token.Literal != test.literal {
+				t.Fatalf("expected %s, got %v, %v", test.literal, token, err)
+			}
+			ws := l.Warnings()
+			if len(ws) != len(test.spans) {
+				t.Fatalf("expected %d warnings, got %v", len(test.spans), ws)
+			}
+			for i, w := range ws {
+				if w.Code != errs.CodeLegacyOctalEscape || w.Severity != errs.SeverityWarning {
+					t.Errorf("expected legacy octal escape warning, got %v", w)
+				}
+				if w.Span.Start.Column != test.spans[i][0] || w.Span.End.Column != test.spans[i][1] {
+					t.Errorf("expected span %v, got %v", test.spans[i], w.Span)
+				}
+				if len(w.Suggestions) != 1 || w.Suggestions[0].Replacement != test.fixes[i] {
+					t.Errorf("expected replacement %s, got %v", test.fixes[i], w.Suggestions)
+				}
+			}
+		})
+	}
+}
+
+func TestLexReadError(t *testing.T) {
+	l := NewLexer(NewScanner(bufio.NewReader(io.MultiReader(strings.NewReader("a \""), iotest.ErrReader(io.ErrUnexpectedEOF))), nil))
+	if token, err := l.Lex(); err != nil || token.Literal != "a" {
+		t.Fatalf("expected identifier, got %v, %v", token, err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err := l.Lex()
+		var eerr *errs.EncodingError
+		if !errors.As(err, &eerr) || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected encoding error, got %v", err)
+		}
+	}
+}
+
+// runeScanner hides every method of a RuneScanner other than its own, so the
+// scanner cannot read it in chunks.
+type runeScanner struct {
+	io.RuneScanner
+}
+
+func TestLexInputs(t *testing.T) {
+	src := "let x = 1_000.5e3;\n\tfunction légèreté() { return /日本/g + '💌' }\u2028  a\r\nb // c\n"
+	lexLocations := func(r io.RuneScanner) (tokens []Token, locs []string, err error) {
+		l := NewLexer(NewScanner(r, nil))
+		prev := TokenNone
+		for {
+			token, err := l.Lex()
+			if err == nil && prev.RegexAllowed() && token.Type == TokenPunctuatorDiv {
+				var re ReToken
+				re, err = l.ReLex()
+				token = re.Token
+			}
+			if err != nil || token.Type == TokenNone {
+				return tokens, locs, err
+			}
+			prev = token.Type
+			tokens = append(tokens, token)
+			start, end := l.Start(), l.Location()
+			locs = append(locs, start.String()+"-"+end.String())
+		}
+	}
+
+	expectedTokens, expectedLocs, err := lexLocations(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := map[string]io.RuneScanner{
+		"one byte at a time": bufio.NewReader(iotest.OneByteReader(strings.NewReader(src))),
+		"rune scanner":       runeScanner{strings.NewReader(src)},
+	}
+	for name, r := range inputs {
+		t.Run(name, func(t *testing.T) {
+			tokens, locs, err := lexLocations(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tokens, expectedTokens) {
+				t.Errorf("expected tokens %v, got %v", expectedTokens, tokens)
+			}
+			if !reflect.DeepEqual(locs, expectedLocs) {
+				t.Errorf("expected locations %v, got %v", expectedLocs, locs)
+			}
+		})
+	}
+}
+
+func TestLexEndOfInput(t *testing.T) {
+	// Tokens ending at the end of the input read past it, then unread, which
+	// must not move the location back.
+	for _, src := range []string{"a", "légèreté", "12", "1.5", "'a'", "a //", "a /* */"} {
+		l := NewLexer(NewScanner(strings.NewReader(src), nil))
+		if _, err := l.Lex(); err != nil {
+			t.Fatal(err)
+		}
+		end := l.Location()
+		if _, err := l.Lex(); err != nil {
+			t.Fatal(err)
+		}
+		expected := strings.IndexAny(src, " ")
+		if expected < 0 {
+			expected = len([]rune(src))
+		}
+		if end.Row != 1 || end.Column != expected+1 {
+			t.Errorf("%q: expected first token to end at 1:%d, got %d:%d", src, expected+1, end.Row, end.Column)
+		}
+		if loc := l.Location(); loc.Column != len([]rune(src))+1 {
+			t.Errorf("%q: expected end of input at 1:%d, got %d:%d", src, len([]rune(src))+1, loc.Row, loc.Column)
+		}
+	}
+}
+
+func TestLexAt(t *testing.T) {
+	// Locations continue from the starting location, as if the input were
+	// part of a larger document.
+	l := NewLexer(NewScannerAt(strings.NewReader("ab\n  c"), ast.Location{Row: 3, Column: 9}))
+	expected := []ast.Location{{Row: 3, Column: 11}, {Row: 4, Column: 4}}
+	for _, loc := range expected {
+		if _, err := l.Lex(); err != nil {
+			t.Fatal(err)
+		}
+		if actual := l.Location(); actual != loc {
+			t.Errorf("expected token to end at %d:%d, got %d:%d", loc.Row, loc.Column, actual.Row, actual.Column)
+		}
+	}
+}
+
+func TestLexSourceMappingURL(t *testing.T) {
+	tests := []struct {
+		src, expected string
+	}{
+		{"a()\n//# sourceMappingURL=a.js.map\n", "a.js.map"},
+		{"a()\n//@ sourceMappingURL=a.js.map", "a.js.map"},
+		{"a() //#\tsourceMappingURL=data:application/json;base64,e30= \n", "data:application/json;base64,e30="},
+		{"//# sourceMappingURL=a.js.map\n//# sourceMappingURL=b.js.map\n// done", "b.js.map"},
+		{"//# sourceMappingURL=a.js.map\na()", ""},
+		{"a() //# sourceMappingURL=a.js.map\n/* */", "a.js.map"},
+		{"a() //#sourceMappingURL=a.js.map", ""},
+		{"a() //# sourceMappingURL=a b.map", ""},
+		{"a() //# sourceMappingURL='a.js.map'", ""},
+		{"a() //# sourceMappingURL=", ""},
+		{"a() //# sourceURL=a.js", ""},
+		{"a() // # sourceMappingURL=a.js.map", ""},
+	}
+	for _, test := range tests {
+		l := NewLexer(NewScanner(strings.NewReader(test.src), nil))
+		for {
+			token, err := l.Lex()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token.Type == TokenNone {
+				break
+			}
+		}
+		if actual := l.SourceMappingURL(); actual != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.src, test.expected, actual)
+		}
+	}
+}
+
+func TestLexSourceURL(t *testing.T) {
+	base := &url.URL{Scheme: "file", Path: "/src/bundle.js"}
+	tests := []struct {
+		src      string
+		base     *url.URL
+		expected []string
+	}{
+		{"a\n//# sourceURL=foo.js\nb", base, []string{"file:///src/bundle.js", "file:///src/foo.js"}},
+		{"a //@ sourceURL=webpack://app/b.js\nb", base, []string{"file:///src/bundle.js", "webpack://app/b.js"}},
+		{"//# sourceURL=a.js\na\n//# sourceURL=b.js\nb", nil, []string{"a.js", "b.js"}},
+		{"a\n//# sourceURL=%zz\nb", base, []string{"file:///src/bundle.js", "file:///src/bundle.js"}},
+		{"a\n// sourceURL=foo.js\nb", base, []string{"file:///src/bundle.js", "file:///src/bundle.js"}},
+	}
+	for _, test := range tests {
+		l := NewLexer(NewScanner(strings.NewReader(test.src), test.base))
+		var actual []string
+		for {
+			token, err := l.Lex()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token.Type == TokenNone {
+				break
+			}
+			actual = append(actual, l.Start().URI.String())
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%q: expected tokens in %v, got %v", test.src, test.expected, actual)
+		}
+	}
+}
+
+func TestLexComments(t *testing.T) {
+	loc := func(row, col int) ast.Location { return ast.Location{Row: row, Column: col} }
+	tests := []struct {
+		src      string
+		expected []ast.Comment
+	}{
+		{"a", nil},
+		{"a // b\nc", []ast.Comment{
+			{Value: " b", Span: ast.Span{Start: loc(1, 3), End: loc(1, 7)}, Range: [2]int{2, 6}},
+		}},
+		{"/* a\n b */ c //", []ast.Comment{
+			{Block: true, Value: " a\n b ", Span: ast.Span{Start: loc(1, 1), End: loc(2, 6)}, Range: [2]int{0, 10}},
+			{Value: "", Span: ast.Span{Start: loc(2, 9), End: loc(2, 11)}, Range: [2]int{13, 15}},
+		}},
+		{"'\U0001f600' /** 😀 **/", []ast.Comment{
+			{Block: true, Value: "* 😀 *", Span: ast.Span{Start: loc(1, 5), End: loc(1, 14)}, Range: [2]int{5, 15}},
+		}},
+		{"a //# sourceMappingURL=a.js.map\n", []ast.Comment{
+			{Value: "# sourceMappingURL=a.js.map", Span: ast.Span{Start: loc(1, 3), End: loc(1, 32)}, Range: [2]int{2, 31}},
+		}},
+	}
+	for _, test := range tests {
+		l := NewLexer(NewScanner(strings.NewReader(test.src), nil))
+		l.KeepComments()
+		var passed []ast.Comment
+		l.OnComment(func(c ast.Comment) { passed = append(passed, c) })
+		for {
+			token, err := l.Lex()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token.Type == TokenNone {
+				break
+			}
+		}
+		if !reflect.DeepEqual(l.Comments(), test.expected) {
+			t.Errorf("%q: expected comments %v, got %v", test.src, test.expected, l.Comments())
+		}
+		if !reflect.DeepEqual(passed, test.expected) {
+			t.Errorf("%q: expected comments %v passed to OnComment, got %v", test.src, test.expected, passed)
+		}
+	}
+}
+
+func TestLexNewLineInComment(t *testing.T) {
+	tests := []struct {
+		src     string
+		newLine bool
+	}{
+		{"a // b\nc", true},
+		{"a //# sourceURL=b.js\nc", true},
+		{"a /* b\n */ c", true},
+		{"a /* b */ c", false},
+		{"a /* b\u2028 */ c", true},
+		{"a /* b **/ c", false},
+		{"a /** b\n **/ c", true},
+	}
+	for _, test := range tests {
+		tokens, err := lexAll(test.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tokens) != 2 || tokens[1].NewLine != test.newLine {
+			t.Errorf("%q: expected second token with NewLine %v, got %v", test.src, test.newLine, tokens)
+		}
+	}
+}
+
+func TestLexContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := NewLexer(NewScanner(strings.NewReader("a b"), nil))
+	if token, err := l.LexContext(ctx); err != nil || token.Literal != "a" {
+		t.Fatalf("expected identifier, got %v, %v", token, err)
+	}
+	cancel()
+	if _, err := l.LexContext(ctx); err != context.Canceled {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+	if token, err := l.Lex(); err != nil || token.Literal != "b" {
+		t.Fatalf("expected identifier, got %v, %v", token, err)
+	}
+}
+
+func TestRegexAllowed(t *testing.T) {
+	tests := []struct {
+		s     string
+		regex bool
+	}{
+		{"/a/g", true},
+		{"x = /a/g", true},
+		{"return /a/g", true},
+		{"typeof /a/g", true},
+		{"a / b", false},
+		{"a.of / b", false},
+		{"(a) / b", false},
+		{"a[0] /= b", false},
+		{"1 / b", false},
+		{"this / b", false},
+		{"i++ / b", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.s, func(t *testing.T) {
+			l := NewLexer(NewScanner(strings.NewReader(test.s), nil))
+			prev := TokenNone
+			for {
+				token, err := l.Lex()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if token.Type == TokenPunctuatorDiv || token.Type == TokenPunctuatorDivAssign {
+					if regex := prev.RegexAllowed(); regex != test.regex {
+						t.Errorf("expected regex to be %v after %s, got %v", test.regex, prev, regex)
+					}
+					return
+				}
+				if token.Type == TokenNone {
+					t.Fatal("expected `/`")
+				}
+				prev = token.Type
+			}
+		})
+	}
+}
+
+func TestLexRegExp(t *testing.T) {
+	tests := []struct {
+		pattern, flags, err string
+	}{
+		{pattern: `a`, flags: "dgimsuy"},
+		{pattern: `a`, flags: "gv"},
+		{pattern: `a`, flags: "x", err: `invalid regular expression flag 'x'`},
+		{pattern: `a`, flags: "gig", err: `duplicate regular expression flag 'g'`},
+		{pattern: `a`, flags: "uv", err: `regular expression flags u and v can not be used together`},
+
+		// Set notation.
+		{pattern: `[\p{L}--[aeiou]]`, flags: "v"},
+		{pattern: `[\p{L}&&\p{ASCII}&&[^a-z]]`, flags: "v"},
+		{pattern: `[a-z0-9_\d[\w--_]]`, flags: "v"},
+		{pattern: `[[a-c]--b--\q{c}]`, flags: "v"},
+		{pattern: `[]|[^]|[\-\(\)\|\/\{\}\[\]]`, flags: "v"},
+		{pattern: `[\u{1F600}-\u{1F64F}\x41\cA]`, flags: "v"},
+		{pattern: `[a-z--b]`, flags: "v", err: "range can not be an operand of --"},
+		{pattern: `[ab--c]`, flags: "v", err: "set operation can not follow a union"},
+		{pattern: `[a--b&&c]`, flags: "v", err: "set operations in a character class must all be --"},
+		{pattern: `[a&&&b]`, flags: "v", err: "unexpected & after &&"},
+		{pattern: `[(]`, flags: "v", err: `'(' must be escaped`},
+		{pattern: `[a-]`, flags: "v", err: `'-' must be escaped`},
+		{pattern: `[!!]`, flags: "v", err: "!! is reserved"},
+		{pattern: `[\d-z]`, flags: "v", err: "range in character class must be between characters"},
+		{pattern: `[a[b]`, flags: "v", err: "unterminated character class"},
+		{pattern: `[(]`, flags: "u"},
+
+		// Strings.
+		{pattern: `\p{RGI_Emoji}[\p{RGI_Emoji}\q{abc|d}]`, flags: "v"},
+		{pattern: `[^\q{a|b}\p{L}]`, flags: "v"},
+		{pattern: `[^\q{ab}]`, flags: "v", err: "negated character class may match strings"},
+		{pattern: `[^[\p{RGI_Emoji}--\q{x}]]`, flags: "v", err: "negated character class may match strings"},
+		{pattern: `[^\p{RGI_Emoji}&&\p{L}]`, flags: "v"},
+		{pattern: `\P{RGI_Emoji}`, flags: "v", err: `\P{RGI_Emoji} can not be negated`},
+		{pattern: `\q{a}`, flags: "v", err: `\q is only allowed in a character class`},
+		{pattern: `\p{RGI_Emoji}`, flags: "u", err: `\p{RGI_Emoji} requires the v flag`},
+		{pattern: `\p{RGI_Emoji}`},
+	}
+	for _, test := range tests {
+		src := "/" + test.pattern + "/" + test.flags
+		t.Run(src, func(t *testing.T) {
+			l := NewLexer(NewScanner(strings.NewReader(src), nil))
+			if _, err := l.Lex(); err != nil {
+				t.Fatal(err)
+			}
+			re, err := l.ReLex()
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				if re.Flags != test.flags {
+					t.Errorf("expected flags %q, got %q", test.flags, re.Flags)
+				}
+				return
+			}
+			var serr *errs.SyntaxError
+			if !errors.As(err, &serr) || serr.Code != errs.CodeInvalidRegExp || !strings.Contains(serr.Err.Error(), test.err) {
+				t.Errorf("expected %s containing %q, got %v", errs.CodeInvalidRegExp, test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package lexer
+
+import (
+	"bytes"
+	"net/url"
+)
+
+// Magic comments are single-line comments that tools read to learn about
+// the source, such as where its source map is. They begin with //# or, in
+// older code, //@, followed by a space or tab, a name, = and a value.
+
+// SourceMappingURL returns the URL given by the //# sourceMappingURL comment
+// that ends the input lexed so far, or "" if there is none. The URL may be a
+// data URI holding the source map itself. As in browsers, only a comment
+// after the last token counts, so it is only known once the input has been
+// lexed to the end.
+func (l *Lexer) SourceMappingURL() string {
+	return l.sourceMappingURL
+}
+
+// consumeMagicComment consumes the rest of a single-line comment after the
+// # or @ that began it, up to the line terminator that ends it, recording its
+// value if it is a magic comment. The rest of the comment is left in buf.
+func (l *Lexer) consumeMagicComment() {
+	l.buf = l.buf[:0]
+	r := l.s.Read()
+	for ; !isLineTerm(r) && r != EOFRune; r = l.s.Read() {
+		l.write(r)
+	}
+	l.s.Unread()
+	if value, ok := magicCommentValue(l.buf, "sourceMappingURL"); ok {
+		l.sourceMappingURL = value
+	} else if value, ok := magicCommentValue(l.buf, "sourceURL"); ok {
+		l.setSourceURL(value)
+	}
+}
+
+// setSourceURL makes the locations after a //# sourceURL comment refer to
+// the URL it gives, as developer tools do for code that was evaluated or
+// concatenated from other files. A relative URL is resolved against the
+// current one, if that is absolute. Invalid URLs are ignored.
+func (l *Lexer) setSourceURL(value string) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return
+	}
+	if l.s.uri != nil && l.s.uri.IsAbs() {
+		u = l.s.uri.ResolveReference(u)
+	}
+	l.s.uri = u
+}
+
+// magicCommentValue returns the value of the magic comment with the given
+// name, where text is the comment after its # or @. The value may be followed
+// by whitespace, but not contain it or quotes.
+func magicCommentValue(text []byte, name string) (string, bool) {
+	if len(text) == 0 || text[0] != ' ' && text[0] != '\t' {
+		return "", false
+	}
+	text = text[1:]
+	if len(text) <= len(name) || string(text[:len(name)]) != name || text[len(name)] != '=' {
+		return "", false
+	}
+	value := bytes.TrimRightFunc(text[len(name)+1:], isWhiteSpace)
+	if len(value) == 0 || bytes.IndexFunc(value, func(r rune) bool {
+		return isWhiteSpace(r) || r == '"' || r == '\''
+	}) >= 0 {
+		return "", false
+	}
+	return string(value), true
+}
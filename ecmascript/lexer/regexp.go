@@ -0,0 +1,350 @@
+package lexer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// regExpFlags are the flags a regular expression may have.
+const regExpFlags = "dgimsuvy"
+
+// stringProperties are the Unicode properties of strings, which match
+// sequences of code points rather than single ones, and so may only be used
+// with the v flag.
+var stringProperties = map[string]bool{
+	"Basic_Emoji":                 true,
+	"Emoji_Keycap_Sequence":       true,
+	"RGI_Emoji_Modifier_Sequence": true,
+	"RGI_Emoji_Flag_Sequence":     true,
+	"RGI_Emoji_Tag_Sequence":      true,
+	"RGI_Emoji_ZWJ_Sequence":      true,
+	"RGI_Emoji":                   true,
+}
+
+// checkRegExp checks the flags of a regular expression, and the parts of its
+// pattern whose meaning depends on them: with the v flag, the character
+// classes, which may hold set operations and strings, and with either the u
+// or v flag, the properties of strings. The pattern is as written, with its
+// escapes.
+func checkRegExp(pattern, flags string) error {
+	for i, f := range flags {
+		if !strings.ContainsRune(regExpFlags, f) {
+			return fmt.Errorf("invalid regular expression flag %q", f)
+		}
+		if strings.ContainsRune(flags[:i], f) {
+			return fmt.Errorf("duplicate regular expression flag %q", f)
+		}
+	}
+	unicode, sets := strings.ContainsRune(flags, 'u'), strings.ContainsRune(flags, 'v')
+	switch {
+	case unicode && sets:
+		return fmt.Errorf("regular expression flags u and v can not be used together")
+	case sets:
+		c := classSetChecker{p: []rune(pattern)}
+		return c.pattern()
+	case unicode:
+		return checkUnicodeProperties(pattern)
+	}
+	return nil
+}
+
+// checkUnicodeProperties checks that a pattern with the u flag uses no
+// properties of strings.
+func checkUnicodeProperties(pattern string) error {
+	p := []rune(pattern)
+	for i := 0; i < len(p)-1; i++ {
+		if p[i] != '\\' {
+			continue
+		}
+		i++
+		if p[i] == 'p' || p[i] == 'P' {
+			if name, _ := propertyName(p, i+1); stringProperties[name] {
+				return fmt.Errorf("property of strings \\%c{%s} requires the v flag", p[i], name)
+			}
+		}
+	}
+	return nil
+}
+
+// propertyName returns the name in the braces at p[i:] of a \p or \P escape,
+// and the index after the braces.
+func propertyName(p []rune, i int) (string, int) {
+	if i >= len(p) || p[i] != '{' {
+		return "", i
+	}
+	for j := i + 1; j < len(p); j++ {
+		if p[j] == '}' {
+			return string(p[i+1 : j]), j + 1
+		}
+	}
+	return "", i
+}
+
+// classSetChecker checks the character classes of a pattern with the v flag,
+// following ClassSetExpression in the ECMAScript grammar.
+type classSetChecker struct {
+	p []rune
+	i int
+}
+
+// peek returns the rune n runes ahead, or -1 past the end of the pattern.
+func (c *classSetChecker) peek(n int) rune {
+	if c.i+n >= len(c.p) {
+		return -1
+	}
+	return c.p[c.i+n]
+}
+
+// at returns whether the pattern continues with s.
+func (c *classSetChecker) at(s string) bool {
+	for n, r := range []rune(s) {
+		if c.peek(n) != r {
+			return false
+		}
+	}
+	return true
+}
+
+// pattern checks the classes in the pattern, skipping everything else.
+func (c *classSetChecker) pattern() error {
+	for c.i < len(c.p) {
+		switch c.p[c.i] {
+		case '\\':
+			c.i++
+			switch c.peek(0) {
+			case 'p', 'P':
+				if _, err := c.property(); err != nil {
+					return err
+				}
+				continue
+			case 'q':
+				return fmt.Errorf("\\q is only allowed in a character class")
+			}
+			c.i++
+		case '[':
+			if _, err := c.class(); err != nil {
+				return err
+			}
+		default:
+			c.i++
+		}
+	}
+	return nil
+}
+
+// class checks a class at `[`, and returns whether it may match strings.
+func (c *classSetChecker) class() (bool, error) {
+	c.i++
+	negated := c.at("^")
+	if negated {
+		c.i++
+	}
+	strs, err := c.contents()
+	if err != nil {
+		return false, err
+	}
+	if c.peek(0) != ']' {
+		return false, fmt.Errorf("unterminated character class")
+	}
+	c.i++
+	if negated && strs {
+		return false, fmt.Errorf("negated character class may match strings")
+	}
+	return strs, nil
+}
+
+// contents checks the contents of a class: a union of operands and ranges,
+// or operands joined by only `&&` or only `--`.
+func (c *classSetChecker) contents() (bool, error) {
+	if c.at("]") {
+		return false, nil
+	}
+	strs, isRange, err := c.unionItem()
+	if err != nil {
+		return false, err
+	}
+	for _, op := range []string{"&&", "--"} {
+		if !c.at(op) {
+			continue
+		}
+		if isRange {
+			return false, fmt.Errorf("range can not be an operand of %s; use a nested class", op)
+		}
+		for c.at(op) {
+			c.i += 2
+			if op == "&&" && c.at("&") {
+				return false, fmt.Errorf("unexpected & after && in character class")
+			}
+			s, err := c.operand()
+			if err != nil {
+				return false, err
+			}
+			if op == "&&" {
+				strs = strs && s
+			}
+		}
+		if !c.at("]") {
+			return false, fmt.Errorf("set operations in a character class must all be %s; use a nested class", op)
+		}
+		return strs, nil
+	}
+	for !c.at("]") && c.i < len(c.p) {
+		if c.at("&&") || c.at("--") {
+			return false, fmt.Errorf("set operation can not follow a union in a character class; use a nested class")
+		}
+		s, _, err := c.unionItem()
+		if err != nil {
+			return false, err
+		}
+		strs = strs || s
+	}
+	return strs, nil
+}
+
+// unionItem checks an operand or a range, and returns whether it may match
+// strings and whether it was a range.
+func (c *classSetChecker) unionItem() (bool, bool, error) {
+	start := c.i
+	strs, err := c.operand()
+	if err != nil {
+		return false, false, err
+	}
+	if !c.at("-") || c.at("--") {
+		return strs, false, nil
+	}
+	if !c.character(start) {
+		return false, false, fmt.Errorf("range in character class must be between characters")
+	}
+	c.i++
+	if c.at("]") {
+		return false, false, fmt.Errorf("'-' must be escaped in a character class with the v flag")
+	}
+	end := c.i
+	if _, err := c.operand(); err != nil {
+		return false, false, err
+	}
+	if !c.character(end) {
+		return false, false, fmt.Errorf("range in character class must be between characters")
+	}
+	return false, true, nil
+}
+
+// character returns whether the operand that begins at i is a single
+// character, rather than a nested class, escape for a class or string.
+func (c *classSetChecker) character(i int) bool {
+	switch {
+	case c.p[i] == '[':
+		return false
+	case c.p[i] == '\\' && i+1 < len(c.p):
+		return !strings.ContainsRune("dDsSwWpPq", c.p[i+1])
+	}
+	return true
+}
+
+// operand checks a nested class, a class escape, a string disjunction or a
+// character, and returns whether it may match strings.
+func (c *classSetChecker) operand() (bool, error) {
+	switch c.peek(0) {
+	case '[':
+		return c.class()
+	case '\\':
+		switch c.peek(1) {
+		case 'd', 'D', 's', 'S', 'w', 'W':
+			c.i += 2
+			return false, nil
+		case 'p', 'P':
+			c.i++
+			return c.property()
+		case 'q':
+			return c.strings()
+		}
+	}
+	return false, c.setCharacter()
+}
+
+// property checks a \p or \P escape at `p` or `P`, and returns whether it
+// matches strings.
+func (c *classSetChecker) property() (bool, error) {
+	negated := c.p[c.i] == 'P'
+	name, next := propertyName(c.p, c.i+1)
+	if name == "" {
+		return false, fmt.Errorf("expected property name in braces after \\%c", c.p[c.i])
+	}
+	c.i = next
+	if stringProperties[name] && negated {
+		return false, fmt.Errorf("property of strings \\P{%s} can not be negated", name)
+	}
+	return stringProperties[name], nil
+}
+
+// strings checks a \q{...} string disjunction, and returns whether it may
+// match strings, that is, whether any of its alternatives is not a single
+// character.
+func (c *classSetChecker) strings() (bool, error) {
+	c.i += 2
+	if !c.at("{") {
+		return false, fmt.Errorf("expected { after \\q")
+	}
+	c.i++
+	strs, n := false, 0
+	for !c.at("}") {
+		switch {
+		case c.i >= len(c.p):
+			return false, fmt.Errorf("unterminated \\q{")
+		case c.at("|"):
+			strs = strs || n != 1
+			n = 0
+			c.i++
+		default:
+			if err := c.setCharacter(); err != nil {
+				return false, err
+			}
+			n++
+		}
+	}
+	c.i++
+	return strs || n != 1, nil
+}
+
+// setCharacter checks a single character of a class, which must be escaped
+// if it is syntax or doubled punctuation.
+func (c *classSetChecker) setCharacter() error {
+	r := c.peek(0)
+	switch {
+	case r == -1:
+		return fmt.Errorf("unterminated character class")
+	case r == '\\':
+		c.i++
+		return c.escape()
+	case r == c.peek(1) && strings.ContainsRune("&!#$%*+,.:;<=>?@^`~", r):
+		return fmt.Errorf("%c%c is reserved in a character class with the v flag and must be escaped", r, r)
+	case strings.ContainsRune("()[]{}/-|", r):
+		return fmt.Errorf("%q must be escaped in a character class with the v flag", r)
+	}
+	c.i++
+	return nil
+}
+
+// escape skips a character escape after `\`.
+func (c *classSetChecker) escape() error {
+	r := c.peek(0)
+	c.i++
+	switch r {
+	case -1:
+		return fmt.Errorf("unterminated character class")
+	case 'u':
+		if c.at("{") {
+			for c.i < len(c.p) && c.p[c.i] != '}' {
+				c.i++
+			}
+			c.i++
+		} else {
+			c.i += 4
+		}
+	case 'x':
+		c.i += 2
+	case 'c':
+		c.i++
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io"
 	"net/url"
+	"unicode/utf8"
 
 	"github.com/jchv/cleansheets/ecmascript/ast"
 	"github.com/jchv/cleansheets/ecmascript/errs"
@@ -12,24 +13,61 @@ import (
 // EOFRune is returned when the scanner hits an EOF error.
 const EOFRune = rune(-1)
 
+// bufferSize is how much input a Scanner reads at once.
+const bufferSize = 4096
+
 // Scanner provides additional logic on top of a RuneScanner.
 type Scanner struct {
 	r io.RuneScanner
 
+	// If r is also an io.Reader, the input is read from rd into buf instead,
+	// and runes are decoded from buf[pos:], so that runs of ASCII can be
+	// consumed without decoding each rune. width is the size of the last
+	// rune read, for unreading it, and rerr is the error that ended reading.
+	rd    io.Reader
+	buf   []byte
+	pos   int
+	width int
+	rerr  error
+
 	uri      *url.URL
 	col, row int
 
-	eof bool
+	// off is the offset in UTF-16 code units, and units the size of the
+	// last rune read in them, for unreading it.
+	off, units int
+
+	// eofReads is the number of times EOFRune has been read and not unread,
+	// which do not move the location.
+	eof      bool
+	eofReads int
+	err      error
 }
 
 // NewScanner creates a new scanner for the given RuneScanner and URL.
 func NewScanner(r io.RuneScanner, uri *url.URL) *Scanner {
-	return &Scanner{
+	s := &Scanner{
 		r:   r,
 		uri: uri,
 		col: 1,
 		row: 1,
 	}
+	if rd, ok := r.(io.Reader); ok {
+		s.rd = rd
+		s.buf = make([]byte, 0, bufferSize)
+	}
+	return s
+}
+
+// NewScannerAt creates a new scanner for input that starts at the given
+// location of a larger document, such as a script embedded in a page, so
+// that locations are reported in the document rather than in the input.
+func NewScannerAt(r io.RuneScanner, start ast.Location) *Scanner {
+	s := NewScanner(r, start.URI)
+	if start.Row > 0 && start.Column > 0 {
+		s.row, s.col = start.Row, start.Column
+	}
+	return s
 }
 
 // Location returns the current source code location.
@@ -47,26 +85,53 @@ func (s *Scanner) Location() ast.Location {
 	}
 }
 
-// Read reads a rune and returns it. On EOF, EOFRune is returned.
+// Offset returns the number of UTF-16 code units read from the input, which
+// is how JS strings index text.
+func (s *Scanner) Offset() int {
+	return s.off
+}
+
+// Err returns the first error encountered reading the input, if any.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// Read reads a rune and returns it. On EOF, EOFRune is returned. Errors
+// reading the input are also treated as EOF, and reported by Err.
 func (s *Scanner) Read() rune {
-	r, _, err := s.r.ReadRune()
+	if s.err != nil {
+		s.eofReads++
+		return EOFRune
+	}
+
+	r, err := s.readRune()
 
-	if errors.Is(err, io.EOF) {
+	if err != nil && errors.Is(err, io.EOF) {
 		s.eof = true
+		s.eofReads++
 		return EOFRune
 	}
 
 	if err != nil {
-		panic(&errs.EncodingError{
+		s.eof = true
+		s.eofReads++
+		s.err = &errs.EncodingError{
 			Location: s.Location(),
 			Err:      err,
-		})
+		}
+		return EOFRune
+	}
+
+	s.units = 1
+	if r >= 0x10000 {
+		s.units = 2
 	}
+	s.off += s.units
 
 	// Increment source location. On newline, we set col to -col. This allows
 	// us to know when we're unreading a line terminator (because col will be
 	// negative) and what to restore it to without needing additional state.
-	if _, ok := lineterms[r]; ok {
+	if isLineTerm(r) {
 		s.row++
 		if s.col > 0 {
 			// Last read was not a newline
@@ -88,17 +153,28 @@ func (s *Scanner) Read() rune {
 // Unread unreads a rune. If we are at EOF, this will not call the underlying
 // RuneReader, so it is safe to unread at EOF.
 func (s *Scanner) Unread() {
-	if !s.eof {
+	if s.eofReads > 0 {
+		s.eofReads--
+		return
+	}
+	if !s.eof && s.rd != nil {
+		s.pos -= s.width
+		s.width = 0
+	} else if !s.eof {
 		err := s.r.UnreadRune()
 
-		if err != nil {
-			panic(&errs.ParserError{
+		if err != nil && s.err == nil {
+			s.err = &errs.ParserError{
 				Location: s.Location(),
+				Code:     errs.CodeInternal,
 				Err:      err,
-			})
+			}
 		}
 	}
 
+	s.off -= s.units
+	s.units = 0
+
 	// If negative: we just read a line terminal rune. Invert col and
 	// decrement row.
 	// If positive: we read any other rune. Just decrement col.
@@ -109,3 +185,92 @@ func (s *Scanner) Unread() {
 		s.col--
 	}
 }
+
+// readRune reads the next rune from the input.
+func (s *Scanner) readRune() (rune, error) {
+	if s.rd == nil {
+		r, _, err := s.r.ReadRune()
+		return r, err
+	}
+	if s.pos < len(s.buf) && s.buf[s.pos] < utf8.RuneSelf {
+		r := rune(s.buf[s.pos])
+		s.pos++
+		s.width = 1
+		return r, nil
+	}
+	for s.rerr == nil && !utf8.FullRune(s.buf[s.pos:]) {
+		s.fill()
+	}
+	if s.pos >= len(s.buf) {
+		return EOFRune, s.rerr
+	}
+	r, w := utf8.DecodeRune(s.buf[s.pos:])
+	s.pos += w
+	s.width = w
+	return r, nil
+}
+
+// fill reads more input into buf, keeping the unread input and the last rune
+// read, which may yet be unread.
+func (s *Scanner) fill() {
+	keep := s.pos - utf8.UTFMax
+	if keep < 0 {
+		keep = 0
+	}
+	n := copy(s.buf[:cap(s.buf)], s.buf[keep:])
+	s.buf = s.buf[:n]
+	s.pos -= keep
+	for {
+		m, err := s.rd.Read(s.buf[n:cap(s.buf)])
+		s.buf = s.buf[:n+m]
+		if err != nil {
+			s.rerr = err
+			return
+		}
+		if m > 0 {
+			return
+		}
+	}
+}
+
+// run returns the length of the run of buffered ASCII runes in class at the
+// current position. Line terminators must not be in class.
+func (s *Scanner) run(class uint8) int {
+	i := s.pos
+	for i < len(s.buf) && s.buf[i] < utf8.RuneSelf && asciiClasses[s.buf[i]]&class != 0 {
+		i++
+	}
+	return i - s.pos
+}
+
+// skip consumes n buffered ASCII runes, none of them line terminators.
+func (s *Scanner) skip(n int) {
+	if n == 0 {
+		return
+	}
+	s.pos += n
+	s.width = 1
+	s.off += n
+	s.units = 1
+	if s.col < 0 {
+		s.col = 1
+	}
+	s.col += n
+}
+
+// appendRun consumes the run of ASCII runes in class at the current
+// position, as far as it is buffered, and appends it to b. This is a faster
+// way to read those runes one at a time; the caller reads whatever follows
+// as usual.
+func (s *Scanner) appendRun(b []byte, class uint8) []byte {
+	n := s.run(class)
+	b = append(b, s.buf[s.pos:s.pos+n]...)
+	s.skip(n)
+	return b
+}
+
+// skipRun consumes the run of ASCII runes in class at the current position,
+// as far as it is buffered.
+func (s *Scanner) skipRun(class uint8) {
+	s.skip(s.run(class))
+}
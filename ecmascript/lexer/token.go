@@ -3,6 +3,7 @@ package lexer
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 // TokenType is an enumeration of possible token types.
@@ -196,6 +197,16 @@ var strToKeywordType = map[string]TokenType{
 	"yield":      TokenKeywordYield,
 }
 
+// keywordLiterals is the source of each keyword, so that lexing a keyword
+// does not allocate its literal again.
+var keywordLiterals = func() map[TokenType]string {
+	m := make(map[TokenType]string, len(strToKeywordType))
+	for s, t := range strToKeywordType {
+		m[t] = s
+	}
+	return m
+}()
+
 // Token represents an ECMAScript lexical token.
 type Token struct {
 	Type    TokenType
@@ -375,6 +386,28 @@ func (t Token) Source() string {
 	return t.Type.String()
 }
 
+// RegexAllowed reports whether a `/` or `/=` following a token of type t
+// begins a regular expression rather than a division. Only the parser can tell
+// for certain; this guesses from the previous token alone, and so is wrong for
+// a regular expression after the `)` of an if or while condition, after the
+// `}` of a block, or at the start of a template substitution. TokenNone stands
+// for the start of the input.
+func (t TokenType) RegexAllowed() bool {
+	switch t {
+	case TokenIdentifier, TokenPrivateIdentifier,
+		TokenLiteralNumber, TokenLiteralString, TokenLiteralRegExp, TokenLiteralTemplate,
+		TokenPunctuatorCloseBracket, TokenPunctuatorCloseParen, TokenPunctuatorCloseBrace,
+		TokenPunctuatorIncrement, TokenPunctuatorDecrement,
+		TokenKeywordThis, TokenKeywordSuper, TokenKeywordNull, TokenKeywordTrue, TokenKeywordFalse,
+		// Contextual keywords, which are usually identifiers.
+		TokenKeywordAs, TokenKeywordAsync, TokenKeywordFrom, TokenKeywordGet,
+		TokenKeywordLet, TokenKeywordMeta, TokenKeywordOf, TokenKeywordSet,
+		TokenKeywordStatic, TokenKeywordTarget:
+		return false
+	}
+	return true
+}
+
 // StringConstant returns the parsed value for a string constant.
 func (t Token) StringConstant() string {
 	if t.Type != TokenLiteralString {
@@ -385,15 +418,45 @@ func (t Token) StringConstant() string {
 	return t.Literal[1 : len(t.Literal)-1]
 }
 
+// TemplateTail reports whether a template literal token ends its template,
+// rather than a substitution beginning after it.
+func (t Token) TemplateTail() bool {
+	return strings.HasSuffix(t.Literal, "`")
+}
+
+// TemplateRaw returns the raw value of a template literal token: its source
+// without the delimiters, with line terminators written as \r\n or \r turned
+// into \n.
+func (t Token) TemplateRaw() string {
+	if t.Type != TokenLiteralTemplate {
+		panic("expected template literal token")
+	}
+	raw := t.Literal[1:]
+	if t.TemplateTail() {
+		raw = raw[:len(raw)-1]
+	} else {
+		raw = raw[:len(raw)-2]
+	}
+	return strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(raw)
+}
+
+// TemplateCooked returns the value of a template literal token with its
+// escape sequences decoded. The error is for an invalid escape sequence,
+// which is only allowed in tagged templates, where the cooked value is then
+// undefined.
+func (t Token) TemplateCooked() (string, error) {
+	return cook(t.TemplateRaw())
+}
+
 // NumberConstant returns the parsed value for a numeric constant.
-func (t Token) NumberConstant() float64 {
+func (t Token) NumberConstant() (float64, error) {
 	// TODO: lexer should be parsing numbers accurately
 	if v, err := strconv.ParseFloat(t.Literal, 64); err == nil {
-		return v
+		return v, nil
 	}
 	v, err := strconv.ParseInt(t.Literal, 0, 64)
 	if err != nil {
-		panic(err)
+		return 0, fmt.Errorf("unsupported numeric literal %q", t.Literal)
 	}
-	return float64(v)
+	return float64(v), nil
 }
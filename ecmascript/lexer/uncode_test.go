@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"strconv"
 	"testing"
+	"unicode"
 )
 
 func TestEncodeUTF16(t *testing.T) {
@@ -36,3 +37,79 @@ func TestEncodeUTF16(t *testing.T) {
 		})
 	}
 }
+
+// The WhiteSpace and LineTerminator sets as listed in ECMA262, as a reference
+// for the tables.
+var (
+	specWhiteSpace = map[rune]struct{}{
+		'\u0009': {}, '\u000b': {}, '\u000c': {},
+		'\u0020': {}, '\u00a0': {}, '\u1680': {},
+		'\u2000': {}, '\u2001': {}, '\u2002': {},
+		'\u2003': {}, '\u2004': {}, '\u2005': {},
+		'\u2006': {}, '\u2007': {}, '\u2008': {},
+		'\u2009': {}, '\u200a': {}, '\u202f': {},
+		'\u205f': {}, '\u3000': {}, '\ufeff': {},
+	}
+	specLineTerm = map[rune]struct{}{
+		'\u000a': {}, '\u000d': {},
+		'\u2028': {}, '\u2029': {},
+	}
+)
+
+func TestRuneClasses(t *testing.T) {
+	for r := rune(-1); r <= unicode.MaxRune; r++ {
+		_, ws := specWhiteSpace[r]
+		_, lt := specLineTerm[r]
+		if got := isWhiteSpace(r); got != ws {
+			t.Errorf("isWhiteSpace(%U) = %v, want %v", r, got, ws)
+		}
+		if got := isLineTerm(r); got != lt {
+			t.Errorf("isLineTerm(%U) = %v, want %v", r, got, lt)
+		}
+		if got, want := isIdentifierStart(r), isIdentifierStartSlow(r); got != want {
+			t.Errorf("isIdentifierStart(%U) = %v, want %v", r, got, want)
+		}
+		if got, want := isIdentifierContinue(r), isIdentifierContinueSlow(r); got != want {
+			t.Errorf("isIdentifierContinue(%U) = %v, want %v", r, got, want)
+		}
+	}
+}
+
+// benchmarkText is mostly ASCII, like most code, with some other runes.
+const benchmarkText = "function render(element, container) { return légèreté(element, 日本語) }\n"
+
+func BenchmarkIsWhiteSpace(b *testing.B) {
+	b.Run("map", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, r := range benchmarkText {
+				_, _ = specWhiteSpace[r]
+				_, _ = specLineTerm[r]
+			}
+		}
+	})
+	b.Run("table", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, r := range benchmarkText {
+				isWhiteSpace(r)
+				isLineTerm(r)
+			}
+		}
+	})
+}
+
+func BenchmarkIsIdentifierContinue(b *testing.B) {
+	b.Run("unicode.In", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, r := range benchmarkText {
+				isIdentifierContinueSlow(r)
+			}
+		}
+	})
+	b.Run("table", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, r := range benchmarkText {
+				isIdentifierContinue(r)
+			}
+		}
+	})
+}
@@ -1,39 +1,105 @@
 package lexer
 
-import "unicode"
-
-var whitespace = map[rune]struct{}{
-	'\u0009': {}, '\u000b': {}, '\u000c': {},
-	'\u0020': {}, '\u00a0': {}, '\u1680': {},
-	'\u2000': {}, '\u2001': {}, '\u2002': {},
-	'\u2003': {}, '\u2004': {}, '\u2005': {},
-	'\u2006': {}, '\u2007': {}, '\u2008': {},
-	'\u2009': {}, '\u200a': {}, '\u202f': {},
-	'\u205f': {}, '\u3000': {}, '\ufeff': {},
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// whiteSpace is the WhiteSpace production: tab, vertical tab, form feed,
+// the byte order mark, and the Zs category.
+var whiteSpace = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x0009, Hi: 0x0009, Stride: 1},
+		{Lo: 0x000b, Hi: 0x000c, Stride: 1},
+		{Lo: 0x0020, Hi: 0x0020, Stride: 1},
+		{Lo: 0x00a0, Hi: 0x00a0, Stride: 1},
+		{Lo: 0x1680, Hi: 0x1680, Stride: 1},
+		{Lo: 0x2000, Hi: 0x200a, Stride: 1},
+		{Lo: 0x202f, Hi: 0x202f, Stride: 1},
+		{Lo: 0x205f, Hi: 0x205f, Stride: 1},
+		{Lo: 0x3000, Hi: 0x3000, Stride: 1},
+		{Lo: 0xfeff, Hi: 0xfeff, Stride: 1},
+	},
+	LatinOffset: 4,
 }
 
-var lineterms = map[rune]struct{}{
-	'\u000a': {}, '\u000d': {},
-	'\u2028': {}, '\u2029': {},
+// lineTerminators is the LineTerminator production.
+var lineTerminators = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x000a, Hi: 0x000a, Stride: 1},
+		{Lo: 0x000d, Hi: 0x000d, Stride: 1},
+		{Lo: 0x2028, Hi: 0x2029, Stride: 1},
+	},
+	LatinOffset: 2,
 }
 
+// Character classes of ASCII runes, in asciiClasses.
+const (
+	classWhiteSpace uint8 = 1 << iota
+	classLineTerm
+	classIdentifierStart
+	classIdentifierContinue
+	classDecimalDigit
+)
+
+// asciiClasses holds the classes of each ASCII rune, so that the common case
+// is a single lookup rather than a search of the tables.
+var asciiClasses = func() (c [utf8.RuneSelf]uint8) {
+	for r := rune(0); r < utf8.RuneSelf; r++ {
+		if unicode.Is(whiteSpace, r) {
+			c[r] |= classWhiteSpace
+		}
+		if unicode.Is(lineTerminators, r) {
+			c[r] |= classLineTerm
+		}
+		if isIdentifierStartSlow(r) {
+			c[r] |= classIdentifierStart
+		}
+		if isIdentifierContinueSlow(r) {
+			c[r] |= classIdentifierContinue
+		}
+		if isDecimalDigit(r) {
+			c[r] |= classDecimalDigit
+		}
+	}
+	return c
+}()
+
 func isWhiteSpace(r rune) bool {
-	_, ok := whitespace[r]
-	return ok
+	if r >= 0 && r < utf8.RuneSelf {
+		return asciiClasses[r]&classWhiteSpace != 0
+	}
+	return unicode.Is(whiteSpace, r)
 }
 
 func isLineTerm(r rune) bool {
-	_, ok := lineterms[r]
-	return ok
+	if r >= 0 && r < utf8.RuneSelf {
+		return asciiClasses[r]&classLineTerm != 0
+	}
+	return unicode.Is(lineTerminators, r)
 }
 
 func isIdentifierStart(r rune) bool {
+	if r >= 0 && r < utf8.RuneSelf {
+		return asciiClasses[r]&classIdentifierStart != 0
+	}
+	return isIdentifierStartSlow(r)
+}
+
+func isIdentifierContinue(r rune) bool {
+	if r >= 0 && r < utf8.RuneSelf {
+		return asciiClasses[r]&classIdentifierContinue != 0
+	}
+	return isIdentifierContinueSlow(r)
+}
+
+func isIdentifierStartSlow(r rune) bool {
 	return (r == '$' || r == '_' ||
 		(unicode.In(r, unicode.L, unicode.Nl, unicode.Other_ID_Start) &&
 			!unicode.In(r, unicode.Pattern_Syntax, unicode.Pattern_White_Space)))
 }
 
-func isIdentifierContinue(r rune) bool {
+func isIdentifierContinueSlow(r rune) bool {
 	return (r == '$' || r == '_' || r == 0x200C || r == 0x200D ||
 		(unicode.In(r, unicode.L, unicode.Nl, unicode.Other_ID_Start, unicode.Mn,
 			unicode.Mc, unicode.Nd, unicode.Pc, unicode.Other_ID_Continue) &&
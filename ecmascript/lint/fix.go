@@ -0,0 +1,61 @@
+package lint
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
+)
+
+// Fix applies the first suggestion of each diagnostic to text, the source the
+// diagnostics are for, and returns the result and the number of fixes
+// applied. A fix that overlaps one that starts before it is skipped; running
+// the rules again on the result may report it again.
+func Fix(text string, ds []errs.Diagnostic) (string, int) {
+	type edit struct {
+		start, end  int
+		replacement string
+	}
+	last := ast.Location{Row: 1, Column: 1}.Advance(text)
+	var fixes []errs.Suggestion
+	var locs []ast.Location
+	for _, d := range ds {
+		if len(d.Suggestions) == 0 {
+			continue
+		}
+		s := d.Suggestions[0]
+		if !inText(s.Span.Start, last) || !inText(s.Span.End, last) {
+			continue
+		}
+		fixes = append(fixes, s)
+		locs = append(locs, s.Span.Start, s.Span.End)
+	}
+	offs := ast.Location{Row: 1, Column: 1}.Offsets(text, locs)
+	var edits []edit
+	for i, s := range fixes {
+		if start, end := offs[2*i], offs[2*i+1]; start <= end {
+			edits = append(edits, edit{start, end, s.Replacement})
+		}
+	}
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	b := &strings.Builder{}
+	pos, n := 0, 0
+	for _, e := range edits {
+		if e.start < pos {
+			continue
+		}
+		b.WriteString(text[pos:e.start])
+		b.WriteString(e.replacement)
+		pos = e.end
+		n++
+	}
+	b.WriteString(text[pos:])
+	return b.String(), n
+}
+
+// inText returns whether l is a location in a text that ends at last.
+func inText(l, last ast.Location) bool {
+	return l.Row >= 1 && l.Column >= 1 && (l.Row < last.Row || l.Row == last.Row && l.Column <= last.Column)
+}
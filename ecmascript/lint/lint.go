@@ -0,0 +1,161 @@
+// Package lint checks parsed ECMAScript code for likely mistakes that are not
+// syntax errors, such as unused and undeclared variables.
+//
+// Other packages can add rules of their own: a Rule with a Visit function is
+// called for each node of the program, and reports diagnostics, optionally
+// with a fix, through the Pass. Register makes a rule available by name.
+//
+// A comment of the form
+//
+//	// cleansheets-disable-next-line unused-vars, undeclared
+//
+// suppresses the diagnostics of the named rules, or of every rule if none are
+// named, that start on the line after it. Text after `--` in the comment is
+// ignored, to leave room for a reason. Comments are only seen if the parser
+// was asked to keep them.
+package lint
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
+	"github.com/jchv/cleansheets/ecmascript/scope"
+)
+
+// Rule is a single check.
+type Rule struct {
+	// Name identifies the rule, e.g. to enable or disable it.
+	Name string
+
+	// Doc is a one line description of what the rule reports.
+	Doc string
+
+	// Code and Severity are used for every diagnostic the rule reports.
+	Code     errs.Code
+	Severity errs.Severity
+
+	// Visit is called for each node of the program, parents before their
+	// children, with Pass.Scope set to the scope the node is in. Property
+	// names that are not computed are not visited, as they are not
+	// references. Rules from other packages are written as a Visit function.
+	Visit func(p *Pass, n ast.Node)
+
+	run func(p *Pass)
+}
+
+// Rules are the built-in rules, in the order they are documented.
+var Rules = []*Rule{
+	UnusedVariables,
+	UndeclaredVariables,
+	UnreachableCode,
+	StrictMode,
+}
+
+// Lookup returns the built-in rule with the given name, or nil if there is
+// none.
+func Lookup(name string) *Rule {
+	for _, r := range Rules {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Register adds r to Rules, so that Lookup finds it and tools that run every
+// rule run it. It is meant to be called from an init function, and panics if
+// r has no name or Visit function, or has the name of a rule already in Rules.
+func Register(r *Rule) {
+	switch {
+	case r.Name == "":
+		panic("lint: rule has no name")
+	case r.Visit == nil:
+		panic(fmt.Sprintf("lint: rule %q has no Visit function", r.Name))
+	case Lookup(r.Name) != nil:
+		panic(fmt.Sprintf("lint: rule %q is already registered", r.Name))
+	}
+	Rules = append(Rules, r)
+}
+
+// Options adjust what the rules report.
+type Options struct {
+	// Globals are names provided by the host environment, such as `window`
+	// or `require`, in addition to the standard built-in globals.
+	Globals []string
+}
+
+// Pass is the state of running a rule over a program.
+type Pass struct {
+	// Program is the script or module being checked.
+	Program ast.Node
+
+	// Options are the options the rules were run with.
+	Options Options
+
+	// Scope is the scope of the node passed to Visit. It is nil for the
+	// program itself.
+	Scope *scope.Scope
+
+	rule        *Rule
+	diagnostics []errs.Diagnostic
+}
+
+// Reportf records a diagnostic for the rule at span.
+func (p *Pass) Reportf(span ast.Span, format string, args ...interface{}) {
+	p.diagnostics = append(p.diagnostics, errs.Diagnostic{
+		Code:     p.rule.Code,
+		Severity: p.rule.Severity,
+		Span:     span,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// ReportFix records a diagnostic for the rule at span, with a fix that Fix can
+// apply.
+func (p *Pass) ReportFix(span ast.Span, fix errs.Suggestion, format string, args ...interface{}) {
+	p.Reportf(span, format, args...)
+	d := &p.diagnostics[len(p.diagnostics)-1]
+	d.Suggestions = append(d.Suggestions, fix)
+}
+
+// Run runs rules over a script or module, returning their diagnostics in
+// source order. Diagnostics suppressed by comments are left out.
+func Run(n ast.Node, rules []*Rule, opt Options) []errs.Diagnostic {
+	suppressed := suppressions(n)
+	var ds []errs.Diagnostic
+	for _, r := range rules {
+		p := &Pass{Program: n, Options: opt, rule: r}
+		if r.run != nil {
+			r.run(p)
+		}
+		if r.Visit != nil {
+			visit(p, r.Visit)
+		}
+		for _, d := range p.diagnostics {
+			if !suppressed.has(d.Span.Start.Row, r.Name) {
+				ds = append(ds, d)
+			}
+		}
+	}
+	sort.SliceStable(ds, func(i, j int) bool {
+		a, b := ds[i].Span.Start, ds[j].Span.Start
+		return a.Row < b.Row || a.Row == b.Row && a.Column < b.Column
+	})
+	return ds
+}
+
+// visit calls f for each node of the program.
+func visit(p *Pass, f func(p *Pass, n ast.Node)) {
+	w := scope.Walker{
+		OnNode: func(w *scope.Walker, n ast.Node) {
+			if n != nil {
+				p.Scope = w.Scope
+				f(p, n)
+			}
+		},
+	}
+	w.Walk(p.Program)
+	p.Scope = nil
+}
@@ -0,0 +1,243 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+func parse(t *testing.T, src string, mode parser.ParseMode) ast.Node {
+	t.Helper()
+	n, err := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(src), nil))).Parse(parser.ParseOptions{Mode: mode})
+	if err != nil {
+		t.Fatalf("error parsing %q: %v", src, err)
+	}
+	return n
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     *Rule
+		input    string
+		mode     parser.ParseMode
+		globals  []string
+		expected []string
+	}{
+		{
+			name:     "unused locals",
+			rule:     UnusedVariables,
+			input:    `function f(a, b) { var c = 1, d = 2; let e; e = 3; return d; }`,
+			expected: []string{"1:20: `c` is declared but never used", "1:38: `e` is declared but never used"},
+		},
+		{
+			name:     "unused globals in script",
+			rule:     UnusedVariables,
+			input:    `var a; function f() {} class C {}`,
+			expected: nil,
+		},
+		{
+			name:     "unused in module",
+			rule:     UnusedVariables,
+			input:    `import a, {b} from "m"; import * as c from "n"; const d = 1, e = 2; export {d}; export function f() { return b; } export default c;`,
+			mode:     parser.ModuleMode,
+			expected: []string{"1:1: `a` is declared but never used", "1:49: `e` is declared but never used"},
+		},
+		{
+			name:     "used in closures and loops",
+			rule:     UnusedVariables,
+			input:    `(function () { let a = 1; for (const b of c) d(() => a + b); function g() {} })`,
+			expected: []string{"1:62: `g` is declared but never used"},
+		},
+		{
+			name:     "shadowed",
+			rule:     UnusedVariables,
+			input:    `(function () { let a = 1; { let a = 2; f(a); } })`,
+			expected: []string{"1:16: `a` is declared but never used"},
+		},
+		{
+			name:     "undeclared",
+			rule:     UndeclaredVariables,
+			input:    `var a = b; c = Math.max(a); if (typeof d === "undefined") e.f; function g() { return arguments; } x => arguments;`,
+			expected: []string{"1:9: `b` is not defined", "1:12: `c` is not defined", "1:59: `e` is not defined", "1:104: `arguments` is not defined"},
+		},
+		{
+			name:     "undeclared with globals",
+			rule:     UndeclaredVariables,
+			input:    `console.log(window, {a, b: c});`,
+			globals:  []string{"console", "window", "c"},
+			expected: []string{"1:22: `a` is not defined"},
+		},
+		{
+			name:     "unreachable",
+			rule:     UnreachableCode,
+			input:    "function f() { return; g(); h(); }\nfor (;;) { if (a) { break } else continue; var b; function c() {} d(); }\nswitch (a) { case 1: throw e; f(); }",
+			expected: []string{"1:24: unreachable code", "2:67: unreachable code", "3:31: unreachable code"},
+		},
+		{
+			name:     "reachable",
+			rule:     UnreachableCode,
+			input:    `function f() { if (a) return; g(); l: { break l; } h(); }`,
+			expected: nil,
+		},
+		{
+			name:     "sloppy",
+			rule:     StrictMode,
+			input:    `var eval; delete a; x = 010 + "\1"; function f(a, a) {}`,
+			expected: nil,
+		},
+		{
+			name:  "use strict",
+			rule:  StrictMode,
+			input: `"use strict"; var eval; delete a; arguments = 1; x = 010 + "\1\\1"; function f(a, a) {}`,
+			expected: []string{
+				"1:15: `eval` can not be declared in strict mode",
+				"1:25: deleting a variable is not allowed in strict mode",
+				"1:35: assigning to `arguments` is not allowed in strict mode",
+				"1:54: numbers with a leading zero are not allowed in strict mode",
+				"1:60: octal escape sequences are not allowed in strict mode",
+				"1:69: duplicate parameter `a` is not allowed in strict mode",
+			},
+		},
+		{
+			name:     "strict function",
+			rule:     StrictMode,
+			input:    `function f() { "use strict"; delete a; } delete b; class C { m() { delete c; } }`,
+			expected: []string{"1:30: deleting a variable is not allowed in strict mode", "1:68: deleting a variable is not allowed in strict mode"},
+		},
+		{
+			name:     "module",
+			rule:     StrictMode,
+			input:    `delete a;`,
+			mode:     parser.ModuleMode,
+			expected: []string{"1:1: deleting a variable is not allowed in strict mode"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := parse(t, test.input, test.mode)
+			var result []string
+			for _, d := range Run(n, []*Rule{test.rule}, Options{Globals: test.globals}) {
+				if d.Code != test.rule.Code || d.Severity != test.rule.Severity {
+					t.Errorf("expected %s %s, got %s %s", test.rule.Severity, test.rule.Code, d.Severity, d.Code)
+				}
+				result = append(result, fmt.Sprintf("%d:%d: %s", d.Span.Start.Row, d.Span.Start.Column, d.Message))
+			}
+			if diff := cmp.Diff(test.expected, result); diff != "" {
+				t.Errorf("diagnostics mismatch (-expected +result):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, r := range Rules {
+		if Lookup(r.Name) != r {
+			t.Errorf("expected to find rule %q", r.Name)
+		}
+	}
+	if r := Lookup("nope"); r != nil {
+		t.Errorf("expected no rule, got %v", r)
+	}
+}
+
+func TestSuppression(t *testing.T) {
+	src := `// cleansheets-disable-next-line undeclared
+a;
+/* cleansheets-disable-next-line unused-vars, undeclared -- generated code */
+function f() { var b; c; }
+// cleansheets-disable-next-line
+var d = e;
+// cleansheets-disable-next-line unused-vars
+f(g);
+// cleansheets-disable-next-lines
+h;`
+	n, err := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(src), nil))).Parse(parser.ParseOptions{Comments: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []string
+	for _, d := range Run(n, []*Rule{UnusedVariables, UndeclaredVariables}, Options{}) {
+		result = append(result, fmt.Sprintf("%d:%d: %s", d.Span.Start.Row, d.Span.Start.Column, d.Message))
+	}
+	expected := []string{"8:3: `g` is not defined", "10:1: `h` is not defined"}
+	if diff := cmp.Diff(expected, result); diff != "" {
+		t.Errorf("diagnostics mismatch (-expected +result):\n%s", diff)
+	}
+}
+
+func TestCustomRule(t *testing.T) {
+	rule := &Rule{
+		Name: "no-var",
+		Doc:  "var declarations",
+		Visit: func(p *Pass, n ast.Node) {
+			if d, ok := n.(ast.VariableDeclaration); ok && d.Kind == ast.VarDeclaration && p.Scope.Function() != p.Scope {
+				span := d.Span()
+				span.End = span.Start
+				span.End.Column += 3
+				p.ReportFix(d.Span(), errs.Suggestion{Message: "use let", Span: span, Replacement: "let"}, "var in a block")
+			}
+		},
+	}
+	src := "var a = 1;\n{ var b = 2, c; }\nfunction f() { var d; if (d) { var e; } }"
+	ds := Run(parse(t, src, parser.ScriptMode), []*Rule{rule}, Options{})
+	if len(ds) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %v", ds)
+	}
+	result, n := Fix(src, ds)
+	if n != 2 {
+		t.Errorf("expected 2 fixes, got %d", n)
+	}
+	expected := "var a = 1;\n{ let b = 2, c; }\nfunction f() { var d; if (d) { let e; } }"
+	if result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	saved := Rules
+	defer func() { Rules = saved }()
+
+	rule := &Rule{Name: "custom", Visit: func(p *Pass, n ast.Node) {}}
+	Register(rule)
+	if Lookup("custom") != rule {
+		t.Error("expected to find the registered rule")
+	}
+	for _, r := range []*Rule{rule, {Name: "unused-vars", Visit: rule.Visit}, {Name: "other"}, {Visit: rule.Visit}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected registering %q to panic", r.Name)
+				}
+			}()
+			Register(r)
+		}()
+	}
+}
+
+func TestFix(t *testing.T) {
+	at := func(row, col int) ast.Location { return ast.Location{Row: row, Column: col} }
+	fix := func(start, end ast.Location, s string) errs.Diagnostic {
+		return errs.Diagnostic{Suggestions: []errs.Suggestion{{Span: ast.Span{Start: start, End: end}, Replacement: s}}}
+	}
+	src := "é = 1;\r\nb == c;"
+	ds := []errs.Diagnostic{
+		fix(at(3, 3), at(3, 5), "==="),
+		fix(at(1, 1), at(1, 2), "a"),
+		fix(at(3, 4), at(3, 4), "!"),
+		fix(at(1, 7), at(1, 7), " // one"),
+		{},
+		fix(at(9, 1), at(9, 1), "?"),
+	}
+	result, n := Fix(src, ds)
+	if expected := "a = 1; // one\r\nb === c;"; result != expected || n != 3 {
+		t.Errorf("expected %q with 3 fixes, got %q with %d", expected, result, n)
+	}
+}
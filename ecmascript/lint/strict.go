@@ -0,0 +1,175 @@
+package lint
+
+import (
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
+)
+
+// StrictMode reports code in modules, classes and functions or scripts with
+// a "use strict" directive that is an error in strict mode code, but that the
+// parser accepts: deleting a variable, assigning to or declaring `eval` or
+// `arguments`, duplicate parameter names, and legacy octal literals and
+// escape sequences.
+var StrictMode = &Rule{
+	Name:     "strict",
+	Doc:      "code that is not allowed in strict mode code",
+	Code:     errs.CodeStrictViolation,
+	Severity: errs.SeverityError,
+	run:      checkStrict,
+}
+
+func checkStrict(p *Pass) {
+	strict := false
+	switch t := p.Program.(type) {
+	case ast.ModuleNode:
+		strict = true
+	case ast.ScriptNode:
+		strict = useStrict(t.Body)
+	}
+	checkStrictTree(p, p.Program, strict)
+}
+
+// checkStrictTree checks n and its descendants, where strict is whether n is
+// in strict mode code.
+func checkStrictTree(p *Pass, n ast.Node, strict bool) {
+	switch t := n.(type) {
+	case ast.FunctionDeclaration:
+		strict = strict || useStrict(t.Body.Body)
+		if strict {
+			checkStrictFunction(p, t.Span(), t.ID, t.Params)
+		}
+	case ast.FunctionExpression:
+		if b, ok := t.Body.(ast.BlockStatement); ok {
+			strict = strict || useStrict(b.Body)
+		}
+		if strict {
+			checkStrictFunction(p, t.Span(), t.ID, t.Params)
+		}
+	case ast.ClassDeclaration, ast.ClassExpression:
+		strict = true
+	}
+	if strict {
+		checkStrictNode(p, n)
+	}
+
+	// Visit the children, but with the strictness of this node.
+	root := true
+	ast.Inspect(n, func(c ast.Node) bool {
+		if root {
+			root = false
+			return true
+		}
+		checkStrictTree(p, c, strict)
+		return false
+	})
+}
+
+// checkStrictNode reports n if it is not allowed in strict mode code.
+func checkStrictNode(p *Pass, n ast.Node) {
+	switch t := n.(type) {
+	case ast.UnaryExpression:
+		if _, ok := unparen(t.Argument).(ast.Identifier); ok && t.Operator == ast.UnaryDeleteOp {
+			p.Reportf(t.Span(), "deleting a variable is not allowed in strict mode")
+		}
+	case ast.AssignmentExpression:
+		checkStrictTarget(p, t.Left)
+	case ast.UpdateExpression:
+		checkStrictTarget(p, t.Argument)
+	case ast.VariableDeclaration:
+		for _, d := range t.Declarations {
+			for _, name := range d.ID.BoundNames() {
+				if restricted(name) {
+					p.Reportf(t.Span(), "`%s` can not be declared in strict mode", name)
+				}
+			}
+		}
+	case ast.NumberLiteral:
+		if len(t.Raw) > 1 && t.Raw[0] == '0' && t.Raw[1] >= '0' && t.Raw[1] <= '9' {
+			p.Reportf(t.Span(), "numbers with a leading zero are not allowed in strict mode")
+		}
+	case ast.StringLiteral:
+		if hasOctalEscape(t.Raw) {
+			p.Reportf(t.Span(), "octal escape sequences are not allowed in strict mode")
+		}
+	}
+}
+
+// checkStrictTarget reports an assignment to `eval` or `arguments`.
+func checkStrictTarget(p *Pass, n ast.Node) {
+	if id, ok := unparen(n).(ast.Identifier); ok && restricted(id.Name) {
+		p.Reportf(id.Span(), "assigning to `%s` is not allowed in strict mode", id.Name)
+	}
+}
+
+// checkStrictFunction reports the name and parameters of a strict function
+// that are not allowed.
+func checkStrictFunction(p *Pass, span ast.Span, id string, params ast.FormalParameters) {
+	if restricted(id) {
+		p.Reportf(span, "`%s` can not be declared in strict mode", id)
+	}
+	var names []string
+	for _, e := range params.Parameters {
+		names = append(names, e.Value.BoundNames()...)
+	}
+	if params.RestParameter != "" {
+		names = append(names, params.RestParameter)
+	}
+	seen := map[string]bool{}
+	for _, name := range names {
+		switch {
+		case restricted(name):
+			p.Reportf(span, "`%s` can not be declared in strict mode", name)
+		case seen[name]:
+			p.Reportf(span, "duplicate parameter `%s` is not allowed in strict mode", name)
+		}
+		seen[name] = true
+	}
+}
+
+// restricted returns whether name is one that strict mode code can not bind
+// or assign to.
+func restricted(name string) bool {
+	return name == "eval" || name == "arguments"
+}
+
+// useStrict returns whether the directive prologue of body contains a "use
+// strict" directive.
+func useStrict(body []ast.Node) bool {
+	for _, stmt := range body {
+		s, ok := stmt.(ast.ExpressionStatement)
+		if !ok || s.Directive == "" {
+			return false
+		}
+		if s.Directive == "use strict" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOctalEscape returns whether the source of a string literal contains a
+// legacy octal escape sequence.
+func hasOctalEscape(raw string) bool {
+	for i := 0; i+1 < len(raw); i++ {
+		if raw[i] != '\\' {
+			continue
+		}
+		c := raw[i+1]
+		if c >= '1' && c <= '7' || c == '0' && i+2 < len(raw) && raw[i+2] >= '0' && raw[i+2] <= '9' {
+			return true
+		}
+		// Skip the escaped character, which may be another backslash.
+		i++
+	}
+	return false
+}
+
+func unparen(n ast.Node) ast.Node {
+	for {
+		p, ok := n.(ast.ParenthesizedExpression)
+		if !ok {
+			return n
+		}
+		n = p.Expression
+	}
+}
@@ -0,0 +1,72 @@
+package lint
+
+import (
+	"strings"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// disableNextLine is the directive that starts a suppression comment.
+const disableNextLine = "cleansheets-disable-next-line"
+
+// suppression is what the comments before a row suppress: every rule, or
+// the rules named.
+type suppression struct {
+	all   bool
+	rules map[string]bool
+}
+
+// suppressionSet holds the suppressions of each row.
+type suppressionSet map[int]*suppression
+
+// suppressions finds the suppression comments of a program.
+func suppressions(n ast.Node) suppressionSet {
+	var comments []ast.Comment
+	switch t := n.(type) {
+	case ast.ScriptNode:
+		comments = t.Comments
+	case ast.ModuleNode:
+		comments = t.Comments
+	}
+	s := suppressionSet{}
+	for _, c := range comments {
+		names, ok := directive(c.Value)
+		if !ok {
+			continue
+		}
+		row := c.Span.End.Row + 1
+		if s[row] == nil {
+			s[row] = &suppression{rules: map[string]bool{}}
+		}
+		s[row].all = s[row].all || len(names) == 0
+		for _, name := range names {
+			s[row].rules[name] = true
+		}
+	}
+	return s
+}
+
+// directive returns the rules named by a suppression comment, and whether
+// the comment is one.
+func directive(text string) ([]string, bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, disableNextLine) {
+		return nil, false
+	}
+	text = text[len(disableNextLine):]
+	if text != "" && !strings.ContainsAny(text[:1], " \t") {
+		return nil, false
+	}
+	if i := strings.Index(text, "--"); i >= 0 {
+		text = text[:i]
+	}
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	}), true
+}
+
+// has returns whether the rule is suppressed on row.
+func (s suppressionSet) has(row int, rule string) bool {
+	sup := s[row]
+	return sup != nil && (sup.all || sup.rules[rule])
+}
@@ -0,0 +1,66 @@
+package lint
+
+import (
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
+	"github.com/jchv/cleansheets/ecmascript/scope"
+)
+
+// UndeclaredVariables reports references to names that are neither declared
+// nor globals, which usually means a misspelling or a missing import. The
+// operand of `typeof` is allowed to be undeclared, since that is how code
+// tests whether a global exists. Globals other than the standard built-ins
+// have to be listed in Options.Globals.
+var UndeclaredVariables = &Rule{
+	Name:     "undeclared",
+	Doc:      "references to names that are not declared",
+	Code:     errs.CodeUndeclaredVariable,
+	Severity: errs.SeverityError,
+	run:      checkUndeclared,
+}
+
+// builtinGlobals are the globals defined by ECMA262.
+var builtinGlobals = []string{
+	"AggregateError", "Array", "ArrayBuffer", "Atomics", "BigInt",
+	"BigInt64Array", "BigUint64Array", "Boolean", "DataView", "Date",
+	"Error", "EvalError", "FinalizationRegistry", "Float32Array",
+	"Float64Array", "Function", "Infinity", "Int16Array", "Int32Array",
+	"Int8Array", "JSON", "Map", "Math", "NaN", "Number", "Object",
+	"Promise", "Proxy", "RangeError", "ReferenceError", "Reflect", "RegExp",
+	"Set", "SharedArrayBuffer", "String", "Symbol", "SyntaxError",
+	"TypeError", "URIError", "Uint16Array", "Uint32Array", "Uint8Array",
+	"Uint8ClampedArray", "WeakMap", "WeakRef", "WeakSet",
+	"decodeURI", "decodeURIComponent", "encodeURI", "encodeURIComponent",
+	"escape", "eval", "globalThis", "isFinite", "isNaN", "parseFloat",
+	"parseInt", "undefined", "unescape",
+}
+
+func checkUndeclared(p *Pass) {
+	known := map[string]bool{}
+	for _, name := range builtinGlobals {
+		known[name] = true
+	}
+	for _, name := range p.Options.Globals {
+		known[name] = true
+	}
+	typeofs := map[ast.Span]bool{}
+
+	w := scope.Walker{
+		OnNode: func(w *scope.Walker, n ast.Node) {
+			if u, ok := n.(ast.UnaryExpression); ok && u.Operator == ast.UnaryTypeOfOp {
+				if id, ok := u.Argument.(ast.Identifier); ok {
+					typeofs[id.Span()] = true
+				}
+			}
+		},
+		OnRef: func(w *scope.Walker, id ast.Identifier, assign bool) {
+			switch {
+			case known[id.Name], typeofs[id.Span()], w.Scope.Lookup(id.Name) != nil:
+			case id.Name == "arguments" && w.Scope.Function().Kind == scope.FunctionScope:
+			default:
+				p.Reportf(id.Span(), "`%s` is not defined", id.Name)
+			}
+		},
+	}
+	w.Walk(p.Program)
+}
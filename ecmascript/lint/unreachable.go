@@ -0,0 +1,95 @@
+package lint
+
+import (
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
+)
+
+// UnreachableCode reports statements following a return, throw, break or
+// continue statement in the same statement list, or an if statement that
+// ends in one on both branches. Function declarations and var declarations
+// without initializers are hoisted, so they are not reported.
+var UnreachableCode = &Rule{
+	Name:     "unreachable",
+	Doc:      "statements that can never run",
+	Code:     errs.CodeUnreachableCode,
+	Severity: errs.SeverityWarning,
+	run:      checkUnreachable,
+}
+
+func checkUnreachable(p *Pass) {
+	ast.Inspect(p.Program, func(n ast.Node) bool {
+		switch t := n.(type) {
+		case ast.ScriptNode:
+			checkStatements(p, t.Body)
+		case ast.ModuleNode:
+			checkStatements(p, t.Body)
+		case ast.BlockStatement:
+			checkStatements(p, t.Body)
+		case ast.SwitchStatement:
+			for _, c := range t.Cases {
+				checkStatements(p, c.Consequent)
+			}
+		}
+		return true
+	})
+}
+
+// checkStatements reports the statements of a list that follow one that
+// always jumps.
+func checkStatements(p *Pass, body []ast.Node) {
+	for i, stmt := range body {
+		if !jumps(stmt) {
+			continue
+		}
+		var unreachable []ast.Node
+		for _, stmt := range body[i+1:] {
+			if !hoisted(stmt) {
+				unreachable = append(unreachable, stmt)
+			}
+		}
+		if len(unreachable) > 0 {
+			p.Reportf(ast.Span{
+				Start: unreachable[0].Span().Start,
+				End:   unreachable[len(unreachable)-1].Span().End,
+			}, "unreachable code")
+		}
+		return
+	}
+}
+
+// jumps returns whether control never continues past stmt.
+func jumps(stmt ast.Node) bool {
+	switch t := stmt.(type) {
+	case ast.ReturnStatement, ast.ThrowStatement, ast.BreakStatement, ast.ContinueStatement:
+		return true
+	case ast.BlockStatement:
+		for _, s := range t.Body {
+			if jumps(s) {
+				return true
+			}
+		}
+	case ast.IfStatement:
+		return t.Alternate != nil && jumps(t.Consequent) && jumps(t.Alternate)
+	}
+	return false
+}
+
+// hoisted returns whether stmt only has an effect through hoisting.
+func hoisted(stmt ast.Node) bool {
+	switch t := stmt.(type) {
+	case ast.FunctionDeclaration, ast.EmptyStatement:
+		return true
+	case ast.VariableDeclaration:
+		if t.Kind != ast.VarDeclaration {
+			return false
+		}
+		for _, d := range t.Declarations {
+			if d.Init != nil {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
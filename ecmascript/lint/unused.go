@@ -0,0 +1,120 @@
+package lint
+
+import (
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
+	"github.com/jchv/cleansheets/ecmascript/scope"
+)
+
+// UnusedVariables reports variables, functions, classes and imports that are
+// never read. Declarations at the top level of a script are left alone, since
+// they are globals that other scripts may use. So are parameters and catch
+// bindings, which often have to be declared whether or not they are used.
+var UnusedVariables = &Rule{
+	Name:     "unused-vars",
+	Doc:      "bindings that are declared but never read",
+	Code:     errs.CodeUnusedVariable,
+	Severity: errs.SeverityWarning,
+	run:      checkUnused,
+}
+
+// binding identifies a binding by the scope it is declared in.
+type binding struct {
+	scope *scope.Scope
+	name  string
+}
+
+func checkUnused(p *Pass) {
+	var declared []binding
+	spans := map[binding]ast.Span{}
+	used := map[binding]bool{}
+
+	declare := func(w *scope.Walker, name string, span ast.Span) {
+		b := binding{w.Scope.Lookup(name), name}
+		if _, ok := spans[b]; !ok && b.scope != nil {
+			declared = append(declared, b)
+			spans[b] = span
+		}
+	}
+	use := func(w *scope.Walker, name string) {
+		if s := w.Scope.Lookup(name); s != nil {
+			used[binding{s, name}] = true
+		}
+	}
+
+	w := scope.Walker{
+		OnNode: func(w *scope.Walker, n ast.Node) {
+			switch t := n.(type) {
+			case ast.VariableDeclaration:
+				for _, d := range t.Declarations {
+					for _, name := range d.ID.BoundNames() {
+						declare(w, name, t.Span())
+					}
+				}
+			case ast.FunctionDeclaration:
+				if t.ID != "" {
+					declare(w, t.ID, t.Span())
+				}
+			case ast.ClassDeclaration:
+				if t.ID != "" {
+					declare(w, t.ID, t.Span())
+				}
+			case ast.ImportDeclNode:
+				if t.DefaultBinding != nil {
+					declare(w, t.DefaultBinding.Identifier, t.Span())
+				}
+				if t.NameSpace != nil {
+					declare(w, t.NameSpace.Identifier, t.Span())
+				}
+				for _, i := range t.NamedImports {
+					declare(w, i.Binding(), t.Span())
+				}
+			case ast.ExportDeclNode:
+				// Exported bindings are used by the modules that import them.
+				for _, name := range exportedBindings(t) {
+					use(w, name)
+				}
+			}
+		},
+		OnRef: func(w *scope.Walker, id ast.Identifier, assign bool) {
+			if !assign {
+				use(w, id.Name)
+			}
+		},
+	}
+	w.Walk(p.Program)
+
+	for _, b := range declared {
+		if !used[b] && b.scope.Kind != scope.GlobalScope {
+			p.Reportf(spans[b], "`%s` is declared but never used", b.name)
+		}
+	}
+}
+
+// exportedBindings returns the names of the local bindings that an export
+// declaration exports.
+func exportedBindings(n ast.ExportDeclNode) []string {
+	if n.Module != "" {
+		// Re-exports do not refer to local bindings.
+		return nil
+	}
+	var names []string
+	decl := n.Declaration
+	if n.Default != nil {
+		decl = n.Default
+	}
+	switch d := decl.(type) {
+	case ast.VariableDeclaration:
+		for _, v := range d.Declarations {
+			names = append(names, v.ID.BoundNames()...)
+		}
+	case ast.FunctionDeclaration:
+		names = append(names, d.ID)
+	case ast.ClassDeclaration:
+		names = append(names, d.ID)
+	}
+	for _, e := range n.NamedExports {
+		names = append(names, e.Identifier)
+	}
+	return names
+}
@@ -7,6 +7,36 @@ type parseContext struct {
 	strictMode bool
 	async      bool
 	generator  bool
+
+	// function is set inside of a function, where return statements are
+	// allowed. newTarget is set inside of a function that is not an arrow
+	// function, where new.target is allowed, and super inside of a method,
+	// where super properties are allowed. Arrow functions take newTarget and
+	// super from the code around them.
+	function  bool
+	newTarget bool
+	super     bool
+
+	// parameters is set while parsing a formal parameter list, where yield
+	// expressions are not allowed even in a generator.
+	parameters bool
+}
+
+// enterFunction updates the context for parsing the parameters and body of a
+// function, and returns the context to restore once they are parsed. A
+// function is neither async nor a generator until the caller says so, whatever
+// the function around it is.
+func (p *Parser) enterFunction(arrow, method bool) parseContext {
+	ctx := p.ctx
+	p.ctx.function = true
+	p.ctx.async = false
+	p.ctx.generator = false
+	p.ctx.parameters = false
+	if !arrow {
+		p.ctx.newTarget = true
+		p.ctx.super = method
+	}
+	return ctx
 }
 
 // keywordToIdentifier converts a keyword to an identifier, if permissible in
@@ -26,7 +56,7 @@ func (ctx *parseContext) keywordToIdentifier(token lexer.Token, force bool) lexe
 				return token
 			}
 		case reservedGenerator:
-			if ctx.generator {
+			if ctx.generator || ctx.strictMode {
 				return token
 			}
 		case reservedStrict:
@@ -2,84 +2,150 @@ package parser
 
 import (
 	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
 	"github.com/jchv/cleansheets/ecmascript/lexer"
 )
 
-func (p *Parser) parseDeclaration() ast.Node {
+func (p *Parser) parseDeclaration() (ast.Node, error) {
 	switch p.s.PeekAt(0).Type {
 	case lexer.TokenKeywordFunction:
-		return p.parseFunctionDeclaration()
+		return p.parseFunctionDeclaration(false)
+	case lexer.TokenKeywordAsync:
+		if p.atAsyncFunction() {
+			return p.parseFunctionDeclaration(false)
+		}
 	case lexer.TokenKeywordLet, lexer.TokenKeywordConst:
 		return p.parseLexicalDeclaration()
 	case lexer.TokenKeywordClass:
-		return p.parseClassDeclaration()
+		return p.parseClassDeclaration(false)
+	}
+	return nil, nil
+}
+
+// atAsyncFunction returns whether the next tokens begin an async function,
+// with no line terminator between `async` and `function`. It only looks past
+// `async`, so that a regular expression that follows can still be relexed.
+func (p *Parser) atAsyncFunction() bool {
+	if p.s.PeekAt(0).Type != lexer.TokenKeywordAsync {
+		return false
 	}
-	return nil
+	next := p.s.PeekAt(1)
+	return next.Type == lexer.TokenKeywordFunction && !next.NewLine
 }
 
-func (p *Parser) parseFunctionDeclaration() ast.Node {
-	s := p.s.Location()
-	p.s.ScanExpect(lexer.TokenKeywordFunction, "expected function")
-	// TODO: support eliding name when in `export default` context.
-	name := p.scanIdent("expected identifier")
-	// TODO: generator support
-	p.s.ScanExpect(lexer.TokenPunctuatorOpenParen, "expected parameter list following function declaration")
-	params := p.parseParametersTail()
-	body := p.parseBlock()
-	n := ast.FunctionDeclaration{
-		ID:     name,
-		Params: params,
-		Body:   body,
-	}
-	n.SetStart(s)
+// parseFunctionDeclaration parses a function declaration, which may be async.
+// The name may only be omitted in an `export default` declaration.
+func (p *Parser) parseFunctionDeclaration(optionalName bool) (ast.Node, error) {
+	n := ast.FunctionDeclaration{Async: p.atAsyncFunction()}
+	p.setStart(&n)
+	if n.Async {
+		p.s.Scan()
+	}
+	if _, err := p.s.ScanExpect(lexer.TokenKeywordFunction, "expected function"); err != nil {
+		return nil, err
+	}
+	generator := false
+	if p.s.PeekAt(0).Type == lexer.TokenPunctuatorMult {
+		p.s.Scan()
+		generator = true
+	}
+	name := ""
+	if !optionalName || p.s.PeekAt(0).Type != lexer.TokenPunctuatorOpenParen {
+		var err error
+		if name, err = p.scanIdent("expected identifier"); err != nil {
+			return nil, err
+		}
+		n.IDSpan = p.s.prevSpan
+	}
+	if _, err := p.s.ScanExpect(lexer.TokenPunctuatorOpenParen, "expected parameter list following function declaration"); err != nil {
+		return nil, err
+	}
+	ctx := p.enterFunction(false, false)
+	p.ctx.generator = generator
+	params, err := p.parseParametersTail()
+	if err != nil {
+		p.ctx = ctx
+		return nil, err
+	}
+	p.ctx.async = n.Async
+	body, err := p.parseBlock()
+	p.ctx = ctx
+	if err != nil {
+		return nil, err
+	}
+	n.ID = name
+	n.Params = params
+	n.Body = body
+	n.Generator = generator
 	n.SetEnd(p.s.Location())
-	return n
+	return n, nil
 }
 
-func (p *Parser) parseLexicalDeclaration() ast.VariableDeclaration {
-	n := p.parseLexicalDeclarationNoSemicolon()
-	p.expectSemicolon()
+func (p *Parser) parseLexicalDeclaration() (ast.Node, error) {
+	n, err := p.parseLexicalDeclarationNoSemicolon()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectSemicolon(); err != nil {
+		return nil, p.suggestStatementEnd(err, n.Declarations[len(n.Declarations)-1].Init)
+	}
 	p.setEnd(&n)
-	return n
+	return n, nil
 }
 
-func (p *Parser) parseLexicalDeclarationNoSemicolon() ast.VariableDeclaration {
+func (p *Parser) parseLexicalDeclarationNoSemicolon() (ast.VariableDeclaration, error) {
 	n := ast.VariableDeclaration{}
 	p.setStart(&n)
-	defer p.setEnd(&n)
 
+	var err error
 	switch p.s.Scan().Type {
 	case lexer.TokenKeywordLet:
-		n.Declarations = p.parseVariableDeclarations()
+		n.Declarations, err = p.parseVariableDeclarations()
 		n.Kind = ast.LetDeclaration
 	case lexer.TokenKeywordConst:
-		n.Declarations = p.parseVariableDeclarations()
+		n.Declarations, err = p.parseVariableDeclarations()
 		n.Kind = ast.ConstDeclaration
 	default:
-		p.s.SyntaxError("expected lexical declaration")
+		err = p.s.SyntaxError(errs.CodeUnexpectedToken, "expected lexical declaration")
 	}
-	return n
+	p.setEnd(&n)
+	return n, err
 }
 
-func (p *Parser) parseClassDeclaration() ast.Node {
+// parseClassDeclaration parses a class declaration. The name may only be
+// omitted in an `export default` declaration.
+func (p *Parser) parseClassDeclaration(optionalName bool) (ast.Node, error) {
 	n := ast.ClassDeclaration{}
 	p.setStart(&n)
-	defer p.setEnd(&n)
 
-	p.s.ScanExpect(lexer.TokenKeywordClass, "expected class")
-	n.ID = p.scanIdent("expected class name")
+	var err error
+	if _, err = p.s.ScanExpect(lexer.TokenKeywordClass, "expected class"); err != nil {
+		return nil, err
+	}
+	if t := p.s.PeekAt(0).Type; !optionalName || (t != lexer.TokenKeywordExtends && t != lexer.TokenPunctuatorOpenBrace) {
+		if n.ID, err = p.scanIdent("expected class name"); err != nil {
+			return nil, err
+		}
+	}
 
 	if p.s.PeekAt(0).Type == lexer.TokenKeywordExtends {
 		p.s.Scan()
-		n.SuperClass = p.parseExpression(exprOrderMemberExpr, 0)
+		if n.SuperClass, err = p.parseExpression(exprOrderMemberExpr, 0); err != nil {
+			return nil, err
+		}
 	}
 
-	n.Body = p.parseClassBody()
-	return n
+	if n.Body, err = p.parseClassBody(); err != nil {
+		return nil, err
+	}
+	p.setEnd(&n)
+	return n, nil
 }
 
-func (p *Parser) parseClassBody() []ast.Node {
-	p.s.ScanExpect(lexer.TokenPunctuatorOpenBrace, "expected '{'")
+func (p *Parser) parseClassBody() ([]ast.Node, error) {
+	if _, err := p.s.ScanExpect(lexer.TokenPunctuatorOpenBrace, "expected '{'"); err != nil {
+		return nil, err
+	}
 
 	n := []ast.Node{}
 
@@ -92,6 +158,7 @@ func (p *Parser) parseClassBody() []ast.Node {
 
 		// TODO: implement member variables...
 		m := ast.MethodDefinition{}
+		p.setStart(&m)
 
 		// Static specifier
 		if peek.Type == lexer.TokenKeywordStatic {
@@ -100,7 +167,19 @@ func (p *Parser) parseClassBody() []ast.Node {
 			m.Static = true
 		}
 
-		// Get/set specifier
+		if p.atAccessor() {
+			a, err := p.parseAccessorProperty(m.Static)
+			if err != nil {
+				return nil, err
+			}
+			a.SetStart(m.Span().Start)
+			n = append(n, a)
+			continue
+		}
+
+		// Get/set specifier, or async and generator. A method may itself
+		// be named async.
+		async, generator := false, false
 		switch peek.Type {
 		case lexer.TokenKeywordGet:
 			p.s.Scan()
@@ -109,31 +188,130 @@ func (p *Parser) parseClassBody() []ast.Node {
 		case lexer.TokenKeywordSet:
 			p.s.Scan()
 			m.Kind = ast.SetMethod
+
+		case lexer.TokenKeywordAsync:
+			if next := p.s.PeekAt(1); next.Type != lexer.TokenPunctuatorOpenParen && !next.NewLine {
+				p.s.Scan()
+				async = true
+				if p.s.PeekAt(0).Type == lexer.TokenPunctuatorMult {
+					p.s.Scan()
+					generator = true
+				}
+			}
+
+		case lexer.TokenPunctuatorMult:
+			p.s.Scan()
+			generator = true
 		}
 
 		// Identifier (possibly computed)
-		t := p.s.Scan()
-		switch t.Type {
-		case lexer.TokenIdentifier:
-			m.Key = ast.Identifier{Name: t.Literal}
-
-		case lexer.TokenPunctuatorOpenBracket:
-			m.Computed = true
-			m.Key = p.parseExpression(exprOrderComma, 0)
-			p.s.ScanExpect(lexer.TokenPunctuatorCloseBracket, "expected `]`")
-
-		default:
-			p.s.SyntaxError("expected method definition")
+		var err error
+		if m.Key, m.Computed, err = p.parseClassElementName("expected method definition"); err != nil {
+			return nil, err
+		}
+
+		if key, ok := m.Key.(ast.Identifier); ok && key.Name == "constructor" && !m.Computed && !m.Static {
+			if m.Kind != ast.Method {
+				return nil, p.s.SyntaxError(errs.CodeUnexpectedToken, "class constructor may not be an accessor")
+			}
+			if generator {
+				return nil, p.s.SyntaxError(errs.CodeUnexpectedToken, "class constructor may not be a generator")
+			}
+			if async {
+				return nil, p.s.SyntaxError(errs.CodeUnexpectedToken, "class constructor may not be async")
+			}
+			m.Kind = ast.ConstructorMethod
 		}
 
-		fn := ast.FunctionExpression{}
-		fn.Params = p.parseParameters()
-		fn.Body = p.parseBlock()
+		fn := ast.FunctionExpression{Async: async, Generator: generator}
+		p.setStart(&fn)
+		ctx := p.enterFunction(false, true)
+		p.ctx.generator = generator
+		fn.Params, err = p.parseParameters()
+		p.ctx.async = async
+		if err == nil {
+			fn.Body, err = p.parseBlock()
+		}
+		p.ctx = ctx
+		if err != nil {
+			return nil, err
+		}
 		fn.SetEnd(p.s.Location())
 		m.Value = fn
+		p.setEnd(&m)
 
 		n = append(n, m)
 	}
 
-	return n
+	return n, nil
+}
+
+// parseClassElementName parses the name of a class element: an identifier,
+// or a computed name in brackets.
+func (p *Parser) parseClassElementName(msg string) (ast.Node, bool, error) {
+	t := p.ctx.keywordToIdentifier(p.s.Scan(), true)
+	switch t.Type {
+	case lexer.TokenIdentifier:
+		key := ast.Identifier{Name: t.Literal}
+		key.SetStart(p.s.prevSpan.Start)
+		key.SetEnd(p.s.prevSpan.End)
+		return key, false, nil
+
+	case lexer.TokenPunctuatorOpenBracket:
+		key, err := p.parseExpression(exprOrderComma, 0)
+		if err != nil {
+			return nil, false, err
+		}
+		if _, err = p.s.ScanExpect(lexer.TokenPunctuatorCloseBracket, "expected `]`"); err != nil {
+			return nil, false, err
+		}
+		return key, true, nil
+	}
+	return nil, false, p.s.SyntaxError(errs.CodeUnexpectedToken, msg)
+}
+
+// atAccessor returns whether the next class element is an auto-accessor.
+// Without a name after it on the same line, `accessor` is itself the name of
+// a method or field.
+func (p *Parser) atAccessor() bool {
+	if !p.experimental.Decorators {
+		return false
+	}
+	t, next := p.s.PeekAt(0), p.s.PeekAt(1)
+	if t.Type != lexer.TokenIdentifier || t.Literal != "accessor" || next.NewLine {
+		return false
+	}
+	return next.Type == lexer.TokenIdentifier || next.Type == lexer.TokenPunctuatorOpenBracket
+}
+
+// parseAccessorProperty parses an auto-accessor at `accessor`. The caller
+// sets its start, which may be at `static`.
+func (p *Parser) parseAccessorProperty(static bool) (ast.AccessorProperty, error) {
+	a := ast.AccessorProperty{Static: static}
+	p.s.Scan()
+
+	var err error
+	if a.Key, a.Computed, err = p.parseClassElementName("expected accessor name"); err != nil {
+		return a, err
+	}
+	if key, ok := a.Key.(ast.Identifier); ok && key.Name == "constructor" && !a.Computed {
+		return a, p.s.SyntaxError(errs.CodeUnexpectedToken, "class constructor may not be an accessor")
+	}
+
+	if p.s.PeekAt(0).Type == lexer.TokenPunctuatorAssign {
+		p.s.Scan()
+
+		// The initializer is evaluated as if in a method of the class.
+		ctx := p.enterFunction(false, true)
+		a.Value, err = p.parseExpression(exprOrderAssign, 0)
+		p.ctx = ctx
+		if err != nil {
+			return a, err
+		}
+	}
+	if err = p.expectSemicolon(); err != nil {
+		return a, err
+	}
+	p.setEnd(&a)
+	return a, nil
 }
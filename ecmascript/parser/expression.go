@@ -2,8 +2,10 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
 	"github.com/jchv/cleansheets/ecmascript/lexer"
 )
 
@@ -50,166 +52,245 @@ const (
 // Flags mainly control context-specific behavior, such as allowing the 'in'
 // operator. Note that flags may or may not propagate to sub-expressions,
 // depending on exactly what kind of sub-expression it is.
-func (p *Parser) parseExpression(order exprOrder, flags exprFlags) ast.Node {
+func (p *Parser) parseExpression(order exprOrder, flags exprFlags) (ast.Node, error) {
+	return p.parseExpressionAt(order, flags, nil)
+}
+
+// parseExpressionAt is parseExpression, which also sets start, if it is not
+// nil, to where the expression starts.
+func (p *Parser) parseExpressionAt(order exprOrder, flags exprFlags, start *ast.Location) (ast.Node, error) {
 	if flags&exprFlagMaybeArrow != 0 {
 		switch p.s.PeekAt(0).Type {
 		case lexer.TokenPunctuatorCloseParen:
 			// This is a parameter list, not an expression.
-			return ast.TemporalEmptyArrowHead{}
+			return ast.TemporalEmptyArrowHead{}, nil
 		case lexer.TokenPunctuatorEllipsis:
 			// Rest parameter inside of possible arrow function head.
-			p.s.ScanExpect(lexer.TokenPunctuatorEllipsis, "expected `...`")
-			return ast.TemporalFloatingRestElement{
-				Identifier: p.forceScanIdent("unexpected token"),
+			p.s.Scan()
+			name, err := p.forceScanIdent("unexpected token")
+			if err != nil {
+				return nil, err
 			}
+			return ast.TemporalFloatingRestElement{Identifier: name}, nil
 		}
 	}
 
 	var n ast.Node
-	s := p.s.Location()
+	var err error
+	at := p.s.Location()
 	t := p.ctx.keywordToIdentifier(p.s.Scan(), false)
+	s := p.s.prevSpan.Start
+	if start != nil {
+		*start = s
+	}
 
-	invalidprimary := func() {
-		p.s.SyntaxError(fmt.Sprintf("unexpected token `%s`, expected primary expression", t.Source()))
+	invalidprimary := func() error {
+		return p.s.SyntaxError(errs.CodeUnexpectedToken, fmt.Sprintf("unexpected token `%s`, expected primary expression", t.Source()))
 	}
 
-	wrap := func(n spannedNode, precedence exprOrder) ast.Node {
+	wrap := func(n spannedNode, precedence exprOrder) (ast.Node, error) {
 		if order > precedence {
-			invalidprimary()
+			return nil, invalidprimary()
 		}
 		n.SetStart(s)
 		n.SetEnd(p.s.Location())
-		return n
+		// The span is set through a pointer, but the tree holds values.
+		switch t := n.(type) {
+		case *ast.UnaryExpression:
+			return *t, nil
+		case *ast.UpdateExpression:
+			return *t, nil
+		case *ast.AwaitExpression:
+			return *t, nil
+		}
+		return n, nil
 	}
 
-	wrapbinary := func(op ast.BinaryOperator, next exprOrder) ast.Node {
-		m := ast.BinaryExpression{Operator: op}
-		m.Left = n
-		m.Right = p.parseExpression(next, flags)
-		m.SetStart(s)
-		m.SetEnd(p.s.Location())
-		return m
+	wrapunary := func(op ast.UnaryOperator) (ast.Node, error) {
+		arg, err := p.parseExpression(exprOrderUnaryExpr, flags&^exprFlagMaybeArrow)
+		if err != nil {
+			return nil, err
+		}
+		return wrap(&ast.UnaryExpression{Operator: op, Argument: arg}, exprOrderUnaryExpr)
+	}
+
+	wrapupdate := func(op ast.UpdateOperator) (ast.Node, error) {
+		// TODO: should add order for update operator?
+		arg, err := p.parseExpression(exprOrderLHSExpr, flags&^exprFlagMaybeArrow)
+		if err != nil {
+			return nil, err
+		}
+		return wrap(&ast.UpdateExpression{Operator: op, Argument: arg}, exprOrderUnaryExpr)
 	}
 
-	wrapassign := func(op ast.AssignmentOperator, next exprOrder) ast.Node {
+	wrapassign := func(op ast.AssignmentOperator, next exprOrder) (ast.Node, error) {
+		if !isAssignmentTarget(n, op == ast.AssignmentOp) {
+			opSpan := p.s.prevSpan
+			err := p.s.SyntaxErrorAt(opSpan, errs.CodeInvalidAssignmentTarget, "invalid assignment target")
+			if op == ast.AssignmentOp && at == p.cond {
+				err = p.suggest(err, errs.Suggestion{
+					Message:     "did you mean `===` to compare?",
+					Span:        opSpan,
+					Replacement: "===",
+				})
+			}
+			return nil, err
+		}
 		m := ast.AssignmentExpression{Operator: op}
 		m.Left = n
-		m.Right = p.parseExpression(next, flags)
+		// The right side keeps the flags, since it may itself be a pattern in
+		// an arrow head, but it can't be the end or the rest of one.
+		right, err := p.parseExpression(next, flags)
+		if err != nil {
+			return nil, err
+		}
+		if isArrowHeadPart(right) {
+			return nil, p.s.SyntaxError(errs.CodeUnexpectedToken, "expected expression after assignment operator")
+		}
+		m.Right = right
 		m.SetStart(s)
 		m.SetEnd(p.s.Location())
-		return m
+		return m, nil
 	}
 
 	// Can't be Div/DivAssign here, relex as a regex. NOTE: if we are peeked
 	// ahead at this point, this will fail.
 	re := lexer.ReToken{}
 	if t.Type == lexer.TokenPunctuatorDiv || t.Type == lexer.TokenPunctuatorDivAssign {
-		re = p.s.ReScan()
+		if re, err = p.s.ReScan(); err != nil {
+			return nil, err
+		}
 		t = re.Token
 	}
 
 	switch t.Type {
 	// Unary operators
 	case lexer.TokenPunctuatorIncrement:
-		// TODO: should add order for update operator?
-		n = wrap(&ast.UpdateExpression{Operator: ast.UpdatePreIncrementOp, Argument: p.parseExpression(exprOrderLHSExpr, flags)}, exprOrderUnaryExpr)
+		n, err = wrapupdate(ast.UpdatePreIncrementOp)
 	case lexer.TokenPunctuatorDecrement:
-		// TODO: should add order for update operator?
-		n = wrap(&ast.UpdateExpression{Operator: ast.UpdatePreDecrementOp, Argument: p.parseExpression(exprOrderLHSExpr, flags)}, exprOrderUnaryExpr)
+		n, err = wrapupdate(ast.UpdatePreDecrementOp)
 	case lexer.TokenKeywordDelete:
-		n = wrap(&ast.UnaryExpression{Operator: ast.UnaryDeleteOp, Argument: p.parseExpression(exprOrderUnaryExpr, flags)}, exprOrderUnaryExpr)
+		n, err = wrapunary(ast.UnaryDeleteOp)
 	case lexer.TokenKeywordVoid:
-		n = wrap(&ast.UnaryExpression{Operator: ast.UnaryVoidOp, Argument: p.parseExpression(exprOrderUnaryExpr, flags)}, exprOrderUnaryExpr)
+		n, err = wrapunary(ast.UnaryVoidOp)
 	case lexer.TokenKeywordTypeOf:
-		n = wrap(&ast.UnaryExpression{Operator: ast.UnaryTypeOfOp, Argument: p.parseExpression(exprOrderUnaryExpr, flags)}, exprOrderUnaryExpr)
+		n, err = wrapunary(ast.UnaryTypeOfOp)
 	case lexer.TokenPunctuatorPlus:
-		n = wrap(&ast.UnaryExpression{Operator: ast.UnaryPlusOp, Argument: p.parseExpression(exprOrderUnaryExpr, flags)}, exprOrderUnaryExpr)
+		n, err = wrapunary(ast.UnaryPlusOp)
 	case lexer.TokenPunctuatorMinus:
-		n = wrap(&ast.UnaryExpression{Operator: ast.UnaryMinusOp, Argument: p.parseExpression(exprOrderUnaryExpr, flags)}, exprOrderUnaryExpr)
+		n, err = wrapunary(ast.UnaryMinusOp)
 	case lexer.TokenPunctuatorBitNot:
-		n = wrap(&ast.UnaryExpression{Operator: ast.UnaryBitNotOp, Argument: p.parseExpression(exprOrderUnaryExpr, flags)}, exprOrderUnaryExpr)
+		n, err = wrapunary(ast.UnaryBitNotOp)
 	case lexer.TokenPunctuatorNot:
-		n = wrap(&ast.UnaryExpression{Operator: ast.UnaryNotOp, Argument: p.parseExpression(exprOrderUnaryExpr, flags)}, exprOrderUnaryExpr)
+		n, err = wrapunary(ast.UnaryNotOp)
 
 	// Primary Expression
 	case lexer.TokenKeywordThis:
-		n = ast.ThisExpression{}
+		m := ast.ThisExpression{}
+		m.SetStart(s)
+		m.SetEnd(p.s.prevSpan.End)
+		n = m
+	case lexer.TokenKeywordSuper:
+		// TODO: only allow super calls inside of constructors.
+		if !p.ctx.super {
+			return nil, p.s.SyntaxError(errs.CodeUnexpectedToken, "`super` is only allowed inside of a method")
+		}
+		switch p.s.PeekAt(0).Type {
+		case lexer.TokenPunctuatorOpenParen, lexer.TokenPunctuatorDot, lexer.TokenPunctuatorOpenBracket:
+			m := ast.Super{}
+			m.SetStart(s)
+			m.SetEnd(p.s.prevSpan.End)
+			n = m
+		default:
+			err = invalidprimary()
+		}
 	case lexer.TokenIdentifier:
 		if t.Literal == "async" {
 			peek := p.s.PeekAt(0)
 			ident := p.ctx.keywordToIdentifier(peek, true)
-			if peek.Type == lexer.TokenKeywordFunction {
+			if peek.Type == lexer.TokenKeywordFunction && !peek.NewLine {
 				// Async function expression
 				p.s.Scan()
-				n = p.parseFunctionExpressionTail(s, false)
-			} else if ident.Type == lexer.TokenIdentifier {
+				n, err = p.parseFunctionExpressionTail(s, true)
+			} else if ident.Type == lexer.TokenIdentifier && !peek.NewLine {
 				// Async arrow function with bare parameter
 				p.s.Scan()
-				p.s.ScanExpect(lexer.TokenPunctuatorFatArrow, "expected '=>'")
-				return ast.FunctionExpression{
+				if _, err := p.s.ScanExpect(lexer.TokenPunctuatorFatArrow, "expected '=>'"); err != nil {
+					return nil, err
+				}
+				body, err := p.parseBlockOrShorthand(true)
+				if err != nil {
+					return nil, err
+				}
+				m := ast.FunctionExpression{
 					Params: ast.FormalParameters{Parameters: []ast.BindingElement{{Value: ast.BindingPattern{Identifier: ident.Literal}}}},
-					Body:   p.parseBlockOrShorthand(),
+					Body:   body,
 					Arrow:  true,
 					Async:  true,
 				}
+				m.SetStart(s)
+				m.SetEnd(p.s.Location())
+				return m, nil
 			} else if peek.Type == lexer.TokenPunctuatorOpenParen {
 				// Async arrow function with parameter list
 				// OR
 				// Call to function named "async"
+				id := ast.Identifier{Name: t.Literal}
+				id.SetStart(s)
+				id.SetEnd(p.s.prevSpan.End)
 				p.s.Scan()
-				inner := p.parseExpression(exprOrderComma, exprFlagMaybeArrow)
-				p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)` operator")
-				if p.s.PeekAt(0).Type == lexer.TokenPunctuatorFatArrow {
-					// This was an arrow function after all. Fix up the parenthesized
-					// expression to be a parameter list.
-					p.s.ScanExpect(lexer.TokenPunctuatorFatArrow, "expected `=>` operator")
-					params := p.convertExprToArrowParams(inner)
-					m := ast.FunctionExpression{
-						Params: params,
-						Body:   p.parseBlockOrShorthand(),
-						Arrow:  true,
-						Async:  true,
-					}
-					m.SetStart(s)
-					m.SetEnd(p.s.Location())
-					n = m
-				} else {
-					// This was a call to a function named "async"
-					n = ast.CallExpression{
-						Callee:    ast.Identifier{Name: t.Literal},
-						Arguments: p.convertExprToCallParams(inner),
-					}
-				}
+				n, err = p.parseParenthesizedTail(s, &id)
 			} else {
 				// Async as a non-reserved identifier
-				n = ast.Identifier{Name: t.Literal}
+				id := ast.Identifier{Name: t.Literal}
+				id.SetStart(s)
+				id.SetEnd(p.s.prevSpan.End)
+				n = id
 			}
+		} else if next := p.s.PeekAt(0); t.Literal == "module" && p.experimental.ModuleBlocks && next.Type == lexer.TokenPunctuatorOpenBrace && !next.NewLine {
+			n, err = p.parseModuleBlockTail(s)
 		} else {
-			n = ast.Identifier{Name: t.Literal}
+			id := ast.Identifier{Name: t.Literal}
+			id.SetStart(p.s.prevSpan.Start)
+			id.SetEnd(p.s.prevSpan.End)
+			n = id
 		}
 	case lexer.TokenKeywordNull:
-		n = ast.NullLiteral{}
-	case lexer.TokenKeywordTrue:
-		n = ast.BooleanLiteral{Value: true, Raw: t.Literal}
-	case lexer.TokenKeywordFalse:
-		n = ast.BooleanLiteral{Value: false, Raw: t.Literal}
+		m := ast.NullLiteral{}
+		m.SetStart(s)
+		m.SetEnd(p.s.prevSpan.End)
+		n = m
+	case lexer.TokenKeywordTrue, lexer.TokenKeywordFalse:
+		m := ast.BooleanLiteral{Value: t.Type == lexer.TokenKeywordTrue, Raw: t.Literal}
+		m.SetStart(s)
+		m.SetEnd(p.s.prevSpan.End)
+		n = m
 	case lexer.TokenLiteralNumber:
-		n = ast.NumberLiteral{Value: t.NumberConstant(), Raw: t.Literal}
+		n, err = p.numberLiteral(t)
 	case lexer.TokenLiteralString:
-		n = ast.StringLiteral{Value: t.StringConstant(), Raw: t.Literal}
+		m := ast.StringLiteral{Value: t.StringConstant(), Raw: t.Literal}
+		m.SetStart(s)
+		m.SetEnd(p.s.prevSpan.End)
+		n = m
 	case lexer.TokenPunctuatorOpenBracket:
-		n = p.parseArrayTail(s, flags&exprFlagMaybeArrow)
+		n, err = p.parseArrayTail(s, flags&exprFlagMaybeArrow)
 	case lexer.TokenPunctuatorOpenBrace:
-		n = p.parseObjectTail(s, flags&exprFlagMaybeArrow)
+		n, err = p.parseObjectTail(s, flags&exprFlagMaybeArrow)
 	case lexer.TokenKeywordFunction:
-		n = p.parseFunctionExpressionTail(s, false)
+		n, err = p.parseFunctionExpressionTail(s, false)
 	case lexer.TokenKeywordNew:
-		ctor := p.parseExpression(exprOrderMemberExpr, flags)
-		m := ast.NewExpression{
-			Callee: ctor,
+		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorDot {
+			n, err = p.parseNewTargetTail(s)
+			break
+		}
+		m := ast.NewExpression{}
+		if m.Callee, err = p.parseExpression(exprOrderMemberExpr, flags); err != nil {
+			return nil, err
 		}
 		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorOpenParen {
-			m.Arguments = p.parseArguments()
+			if m.Arguments, err = p.parseArguments(); err != nil {
+				return nil, err
+			}
 		}
 		m.SetStart(s)
 		m.SetEnd(p.s.Location())
@@ -217,13 +298,21 @@ func (p *Parser) parseExpression(order exprOrder, flags exprFlags) ast.Node {
 	case lexer.TokenKeywordClass:
 		m := ast.ClassExpression{}
 		if p.s.PeekAt(0).Type == lexer.TokenIdentifier {
-			m.ID = p.scanIdent("expected class name")
+			if m.ID, err = p.scanIdent("expected class name"); err != nil {
+				return nil, err
+			}
 		}
 		if p.s.PeekAt(0).Type == lexer.TokenKeywordExtends {
 			p.s.Scan()
-			m.SuperClass = p.parseExpression(exprOrderMemberExpr, 0)
+			if m.SuperClass, err = p.parseExpression(exprOrderMemberExpr, 0); err != nil {
+				return nil, err
+			}
 		}
-		m.Body = p.parseClassBody()
+		if m.Body, err = p.parseClassBody(); err != nil {
+			return nil, err
+		}
+		m.SetStart(s)
+		m.SetEnd(p.s.Location())
 		n = m
 	case lexer.TokenLiteralRegExp:
 		m := ast.RegExpLiteral{
@@ -235,50 +324,46 @@ func (p *Parser) parseExpression(order exprOrder, flags exprFlags) ast.Node {
 		m.SetEnd(p.s.Location())
 		n = m
 	case lexer.TokenLiteralTemplate:
-		panic("unimplemented: template literal")
-	case lexer.TokenPunctuatorOpenParen:
-		// Tricky: this could be a parenthesized expression, or the parameter
-		// list of an arrow function. To avoid look-ahead, the parser will
-		// parse as an expression where possible, but also allow some invalid
-		// productions, and then it will be fixed up here.
-		inner := p.parseExpression(exprOrderComma, exprFlagMaybeArrow)
-		p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)` operator")
-		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorFatArrow {
-			// This was an arrow function after all. Fix up the parenthesized
-			// expression to be a parameter list.
-			p.s.ScanExpect(lexer.TokenPunctuatorFatArrow, "expected `=>` operator")
-			params := p.convertExprToArrowParams(inner)
-			m := ast.FunctionExpression{
-				Params: params,
-				Body:   p.parseBlockOrShorthand(),
-				Arrow:  true,
-			}
-			m.SetStart(s)
-			m.SetEnd(p.s.Location())
-			n = m
-		} else {
-			// Was not an arrow. Deal disallowed syntax retroactively.
-			if _, ok := inner.(ast.TemporalEmptyArrowHead); ok || inner.ContainsTemporalNodes() {
-				p.s.SyntaxError("expected `=>` operator")
-			}
-
-			m := ast.ParenthesizedExpression{Expression: inner}
-			m.SetStart(s)
-			m.SetEnd(p.s.Location())
-			n = m
+		if !strings.HasPrefix(t.Literal, "`") {
+			err = invalidprimary()
+			break
 		}
+		n, err = p.parseTemplateTail(t, s, false)
+	case lexer.TokenPunctuatorOpenParen:
+		n, err = p.parseParenthesizedTail(s, nil)
+	case lexer.TokenKeywordAwait:
+		// Only reached in an async function; elsewhere, await is an
+		// identifier.
+		var arg ast.Node
+		if arg, err = p.parseExpression(exprOrderUnaryExpr, flags&^exprFlagMaybeArrow); err == nil {
+			n, err = wrap(&ast.AwaitExpression{Argument: arg}, exprOrderUnaryExpr)
+		}
+	case lexer.TokenKeywordYield:
+		// Only reached in a generator or strict mode code; elsewhere, yield
+		// is an identifier.
+		if !p.ctx.generator {
+			return nil, p.s.SyntaxErrorAt(p.s.prevSpan, errs.CodeUnexpectedToken, "yield is a reserved word in strict mode code")
+		}
+		if p.ctx.parameters {
+			return nil, p.s.SyntaxErrorAt(p.s.prevSpan, errs.CodeUnexpectedToken, "yield expressions are not allowed in formal parameters")
+		}
+		if order > exprOrderAssign {
+			return nil, invalidprimary()
+		}
+		n, err = p.parseYieldTail(s, flags&^exprFlagMaybeArrow)
 	default:
-		invalidprimary()
+		err = invalidprimary()
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	// Handle single-parameter bare parameter list.
 	if i, ok := n.(ast.Identifier); ok && p.s.PeekAt(0).Type == lexer.TokenPunctuatorFatArrow {
-		p.s.ScanExpect(lexer.TokenPunctuatorFatArrow, "expected `=>` operator")
-		var body ast.Node
-		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorOpenBrace {
-			body = p.parseBlock()
-		} else {
-			body = p.parseExpression(exprOrderConditional, 0)
+		p.s.Scan()
+		body, err := p.parseBlockOrShorthand(false)
+		if err != nil {
+			return nil, err
 		}
 		m := ast.FunctionExpression{
 			Params: ast.FormalParameters{Parameters: []ast.BindingElement{{Value: ast.BindingPattern{Identifier: i.Name}}}},
@@ -287,37 +372,52 @@ func (p *Parser) parseExpression(order exprOrder, flags exprFlags) ast.Node {
 		}
 		m.SetStart(s)
 		m.SetEnd(p.s.Location())
-		return m
+		return m, nil
 	}
 
 	if order >= exprOrderPrimaryExpr {
-		return n
+		return n, nil
 	}
 
+	// Each iteration either extends n with an operator, which may fail, or
+	// ends the expression.
 	for {
-		// exprOrderLHSExpr
+		if err != nil {
+			return nil, err
+		}
+
+		// An assignment, conditional or yield expression takes in every
+		// operator that follows it, except for a comma. Anything else can only
+		// follow one that ends in a bare yield, and begins the next statement.
 		t = p.s.PeekAt(0)
+		if isAssignmentExpression(n) && t.Type != lexer.TokenPunctuatorComma {
+			break
+		}
+
+		// exprOrderLHSExpr
 		if t.Type == lexer.TokenPunctuatorDot {
-			p.s.ScanExpect(lexer.TokenPunctuatorDot, "expected `.` operator")
-			m := ast.MemberExpression{
-				Object:   n,
-				Computed: false,
-				Property: ast.Identifier{
-					Name: p.forceScanIdent("expected property name after `.` operator"),
-				},
-			}
+			p.s.Scan()
+			m := ast.MemberExpression{Object: n}
+			m.Property, err = p.parsePropertyName()
 			m.SetStart(s)
 			m.SetEnd(p.s.Location())
 			n = m
 			continue
 		} else if t.Type == lexer.TokenPunctuatorOpenBracket {
-			p.s.ScanExpect(lexer.TokenPunctuatorOpenBracket, "expected `[` operator")
-			m := ast.MemberExpression{
-				Object:   n,
-				Computed: true,
-				Property: p.parseExpression(exprOrderAssign, 0),
+			p.s.Scan()
+			m := ast.MemberExpression{Object: n, Computed: true}
+			m.Property, err = p.parseComputedProperty()
+			m.SetStart(s)
+			m.SetEnd(p.s.Location())
+			n = m
+			continue
+		} else if t.Type == lexer.TokenLiteralTemplate && strings.HasPrefix(t.Literal, "`") {
+			p.s.Scan()
+			if inOptionalChain(n) {
+				return nil, p.s.SyntaxErrorAt(p.s.prevSpan, errs.CodeUnexpectedToken, "a tagged template can not be in an optional chain")
 			}
-			p.s.ScanExpect(lexer.TokenPunctuatorCloseBracket, "expected `]` operator")
+			m := ast.TaggedTemplateExpression{Tag: n}
+			m.Quasi, err = p.parseTemplateTail(t, p.s.prevSpan.Start, true)
 			m.SetStart(s)
 			m.SetEnd(p.s.Location())
 			n = m
@@ -328,10 +428,8 @@ func (p *Parser) parseExpression(order exprOrder, flags exprFlags) ast.Node {
 		}
 
 		if t.Type == lexer.TokenPunctuatorOpenParen {
-			m := ast.CallExpression{
-				Callee:    n,
-				Arguments: p.parseArguments(),
-			}
+			m := ast.CallExpression{Callee: n}
+			m.Arguments, err = p.parseArguments()
 			m.SetStart(s)
 			m.SetEnd(p.s.Location())
 			n = m
@@ -342,37 +440,23 @@ func (p *Parser) parseExpression(order exprOrder, flags exprFlags) ast.Node {
 		}
 
 		if t.Type == lexer.TokenPunctuatorOptionalChain {
-			p.s.ScanExpect(lexer.TokenPunctuatorDot, "expected `?.` operator")
+			p.s.Scan()
 			if p.s.PeekAt(0).Type == lexer.TokenPunctuatorOpenBracket {
-				p.s.ScanExpect(lexer.TokenPunctuatorOpenBracket, "expected `[` operator")
-				m := ast.MemberExpression{
-					Object:   n,
-					Computed: true,
-					Property: p.parseExpression(exprOrderAssign, 0),
-					Optional: true,
-				}
-				p.s.ScanExpect(lexer.TokenPunctuatorCloseBracket, "expected `]` operator")
+				p.s.Scan()
+				m := ast.MemberExpression{Object: n, Computed: true, Optional: true}
+				m.Property, err = p.parseComputedProperty()
 				m.SetStart(s)
 				m.SetEnd(p.s.Location())
 				n = m
 			} else if p.s.PeekAt(0).Type == lexer.TokenPunctuatorOpenParen {
-				m := ast.CallExpression{
-					Callee:    n,
-					Optional:  true,
-					Arguments: p.parseArguments(),
-				}
+				m := ast.CallExpression{Callee: n, Optional: true}
+				m.Arguments, err = p.parseArguments()
 				m.SetStart(s)
 				m.SetEnd(p.s.Location())
 				n = m
 			} else {
-				m := ast.MemberExpression{
-					Object:   n,
-					Computed: false,
-					Property: ast.Identifier{
-						Name: p.forceScanIdent("expected property name after `.` operator"),
-					},
-					Optional: true,
-				}
+				m := ast.MemberExpression{Object: n, Optional: true}
+				m.Property, err = p.parsePropertyName()
 				m.SetStart(s)
 				m.SetEnd(p.s.Location())
 				n = m
@@ -385,167 +469,20 @@ func (p *Parser) parseExpression(order exprOrder, flags exprFlags) ast.Node {
 
 		// TODO: should add order for update?
 		if t.Type == lexer.TokenPunctuatorIncrement {
-			p.s.ScanExpect(lexer.TokenPunctuatorIncrement, "expected `++` operator")
-			n = wrap(&ast.UpdateExpression{Operator: ast.UpdatePostIncrementOp, Argument: n}, exprOrderUnaryExpr)
+			p.s.Scan()
+			n, err = wrap(&ast.UpdateExpression{Operator: ast.UpdatePostIncrementOp, Argument: n}, exprOrderUnaryExpr)
 			continue
 		} else if t.Type == lexer.TokenPunctuatorDecrement {
-			p.s.ScanExpect(lexer.TokenPunctuatorDecrement, "expected `--` operator")
-			n = wrap(&ast.UpdateExpression{Operator: ast.UpdatePostDecrementOp, Argument: n}, exprOrderUnaryExpr)
+			p.s.Scan()
+			n, err = wrap(&ast.UpdateExpression{Operator: ast.UpdatePostDecrementOp, Argument: n}, exprOrderUnaryExpr)
 			continue
 		}
 		if order >= exprOrderUnaryExpr {
 			break
 		}
 
-		if t.Type == lexer.TokenPunctuatorExponent {
-			p.s.ScanExpect(lexer.TokenPunctuatorExponent, "expected `**` operator")
-			n = wrapbinary(ast.BinaryExponentOp, exprOrderUnaryExpr)
-			continue
-		}
-		if order >= exprOrderExponentExpr {
-			break
-		}
-
-		if t.Type == lexer.TokenPunctuatorMult {
-			p.s.ScanExpect(lexer.TokenPunctuatorMult, "expected `*` operator")
-			n = wrapbinary(ast.BinaryMultOp, exprOrderExponentExpr)
-			continue
-		} else if t.Type == lexer.TokenPunctuatorDiv {
-			p.s.ScanExpect(lexer.TokenPunctuatorDiv, "expected `/` operator")
-			n = wrapbinary(ast.BinaryDivOp, exprOrderExponentExpr)
-			continue
-		} else if t.Type == lexer.TokenPunctuatorMod {
-			p.s.ScanExpect(lexer.TokenPunctuatorMod, "expected `%` operator")
-			n = wrapbinary(ast.BinaryModOp, exprOrderExponentExpr)
-			continue
-		}
-		if order >= exprOrderMultiplicativeExpr {
-			break
-		}
-
-		if t.Type == lexer.TokenPunctuatorPlus {
-			p.s.ScanExpect(lexer.TokenPunctuatorPlus, "expected `+` operator")
-			n = wrapbinary(ast.BinaryAddOp, exprOrderMultiplicativeExpr)
-			continue
-		} else if t.Type == lexer.TokenPunctuatorMinus {
-			p.s.ScanExpect(lexer.TokenPunctuatorMinus, "expected `-` operator")
-			n = wrapbinary(ast.BinarySubOp, exprOrderMultiplicativeExpr)
-			continue
-		}
-		if order >= exprOrderAdditiveExpr {
-			break
-		}
-
-		if t.Type == lexer.TokenPunctuatorLShift {
-			p.s.ScanExpect(lexer.TokenPunctuatorLShift, "expected `<<` operator")
-			n = wrapbinary(ast.BinaryLShiftOp, exprOrderAdditiveExpr)
-			continue
-		} else if t.Type == lexer.TokenPunctuatorRShift {
-			p.s.ScanExpect(lexer.TokenPunctuatorRShift, "expected `>>` operator")
-			n = wrapbinary(ast.BinaryRShiftOp, exprOrderAdditiveExpr)
-			continue
-		} else if t.Type == lexer.TokenPunctuatorUnsignedRShift {
-			p.s.ScanExpect(lexer.TokenPunctuatorUnsignedRShift, "expected `>>>` operator")
-			n = wrapbinary(ast.BinaryUnsignedRShiftOp, exprOrderAdditiveExpr)
-			continue
-		}
-		if order >= exprOrderShiftExpr {
-			break
-		}
-
-		if t.Type == lexer.TokenPunctuatorLessThan {
-			p.s.ScanExpect(lexer.TokenPunctuatorLessThan, "expected `<` operator")
-			n = wrapbinary(ast.BinaryLessThanOp, exprOrderShiftExpr)
-			continue
-		} else if t.Type == lexer.TokenPunctuatorGreaterThan {
-			p.s.ScanExpect(lexer.TokenPunctuatorGreaterThan, "expected `>` operator")
-			n = wrapbinary(ast.BinaryGreaterThanOp, exprOrderShiftExpr)
-			continue
-		} else if t.Type == lexer.TokenPunctuatorLessThanEqual {
-			p.s.ScanExpect(lexer.TokenPunctuatorLessThanEqual, "expected `<=` operator")
-			n = wrapbinary(ast.BinaryLessThanEqualOp, exprOrderShiftExpr)
-			continue
-		} else if t.Type == lexer.TokenPunctuatorGreaterThanEqual {
-			p.s.ScanExpect(lexer.TokenPunctuatorGreaterThanEqual, "expected `>=` operator")
-			n = wrapbinary(ast.BinaryGreaterThanEqualOp, exprOrderShiftExpr)
-			continue
-		} else if t.Type == lexer.TokenKeywordInstanceOf {
-			p.s.ScanExpect(lexer.TokenKeywordInstanceOf, "expected `instanceof` operator")
-			n = wrapbinary(ast.BinaryInstanceOfOp, exprOrderShiftExpr)
-			continue
-		} else if flags&exprFlagDisallowIn == 0 && t.Type == lexer.TokenKeywordIn {
-			p.s.ScanExpect(lexer.TokenKeywordIn, "expected `in` operator")
-			n = wrapbinary(ast.BinaryInOp, exprOrderShiftExpr)
-			continue
-		}
-		if order >= exprOrderRelationalExpr {
-			break
-		}
-
-		if t.Type == lexer.TokenPunctuatorEqual {
-			p.s.ScanExpect(lexer.TokenPunctuatorEqual, "expected `==` operator")
-			n = wrapbinary(ast.BinaryEqualOp, exprOrderRelationalExpr)
-			continue
-		} else if t.Type == lexer.TokenPunctuatorNotEqual {
-			p.s.ScanExpect(lexer.TokenPunctuatorNotEqual, "expected `!=` operator")
-			n = wrapbinary(ast.BinaryNotEqualOp, exprOrderRelationalExpr)
-			continue
-		} else if t.Type == lexer.TokenPunctuatorStrictEqual {
-			p.s.ScanExpect(lexer.TokenPunctuatorStrictEqual, "expected `===` operator")
-			n = wrapbinary(ast.BinaryStrictEqualOp, exprOrderRelationalExpr)
-			continue
-		} else if t.Type == lexer.TokenPunctuatorStrictNotEqual {
-			p.s.ScanExpect(lexer.TokenPunctuatorStrictNotEqual, "expected `!==` operator")
-			n = wrapbinary(ast.BinaryStrictNotEqualOp, exprOrderRelationalExpr)
-			continue
-		}
-		if order >= exprOrderEqualityExpr {
-			break
-		}
-
-		if t.Type == lexer.TokenPunctuatorBitAnd {
-			p.s.ScanExpect(lexer.TokenPunctuatorBitAnd, "expected `&` operator")
-			n = wrapbinary(ast.BinaryBitAndOp, exprOrderEqualityExpr)
-			continue
-		}
-		if order >= exprOrderBitwiseAnd {
-			break
-		}
-
-		if t.Type == lexer.TokenPunctuatorBitXor {
-			p.s.ScanExpect(lexer.TokenPunctuatorBitXor, "expected `^` operator")
-			n = wrapbinary(ast.BinaryBitXorOp, exprOrderBitwiseAnd)
-			continue
-		}
-		if order >= exprOrderBitwiseXor {
-			break
-		}
-
-		if t.Type == lexer.TokenPunctuatorBitOr {
-			p.s.ScanExpect(lexer.TokenPunctuatorBitOr, "expected `|` operator")
-			n = wrapbinary(ast.BinaryBitXorOp, exprOrderBitwiseXor)
-			continue
-		}
-		if order >= exprOrderBitwiseOr {
-			break
-		}
-
-		if t.Type == lexer.TokenPunctuatorLogicalAnd {
-			p.s.ScanExpect(lexer.TokenPunctuatorLogicalAnd, "expected `&&` operator")
-			n = wrapbinary(ast.BinaryLogicalAndOp, exprOrderBitwiseOr)
-			continue
-		}
-		if order >= exprOrderLogicalAnd {
-			break
-		}
-
-		if t.Type == lexer.TokenPunctuatorLogicalOr {
-			p.s.ScanExpect(lexer.TokenPunctuatorLogicalOr, "expected `||` operator")
-			n = wrapbinary(ast.BinaryLogicalOrOp, exprOrderLogicalAnd)
-			continue
-		} else if t.Type == lexer.TokenPunctuatorNullCoalesce {
-			p.s.ScanExpect(lexer.TokenPunctuatorNullCoalesce, "expected `??` operator")
-			n = wrapbinary(ast.BinaryCoalesceOp, exprOrderLogicalAnd)
+		if _, ok := binaryOperatorFor(t, flags, order); ok {
+			n, err = p.parseBinaryExpression(n, s, order, flags)
 			continue
 		}
 		if order >= exprOrderLogicalOr {
@@ -553,15 +490,15 @@ func (p *Parser) parseExpression(order exprOrder, flags exprFlags) ast.Node {
 		}
 
 		if t.Type == lexer.TokenPunctuatorQuestionMark {
-			p.s.ScanExpect(lexer.TokenPunctuatorQuestionMark, "expected `?` operator in conditional expression")
-			a := p.parseExpression(exprOrderAssign, 0)
-			p.s.ScanExpect(lexer.TokenPunctuatorColon, "expected `:` operator in conditional expression")
-			b := p.parseExpression(exprOrderAssign, 0)
-			m := ast.ConditionalExpression{
-				Test:       n,
-				Consequent: a,
-				Alternate:  b,
+			p.s.Scan()
+			m := ast.ConditionalExpression{Test: n}
+			if m.Consequent, err = p.parseExpression(exprOrderAssign, 0); err != nil {
+				continue
+			}
+			if _, err = p.s.ScanExpect(lexer.TokenPunctuatorColon, "expected `:` operator in conditional expression"); err != nil {
+				continue
 			}
+			m.Alternate, err = p.parseExpression(exprOrderAssign, 0)
 			m.SetStart(s)
 			m.SetEnd(p.s.Location())
 			n = m
@@ -572,85 +509,85 @@ func (p *Parser) parseExpression(order exprOrder, flags exprFlags) ast.Node {
 		}
 
 		if t.Type == lexer.TokenPunctuatorAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorAssign, "expected `=` operator")
-			n = wrapassign(ast.AssignmentOp, exprOrderAssign)
+			p.s.Scan()
+			n, err = wrapassign(ast.AssignmentOp, exprOrderAssign)
 			continue
 		} else if t.Type == lexer.TokenPunctuatorMultAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorMultAssign, "expected `*=` operator")
-			n = wrapassign(ast.AssignmentMultOp, exprOrderAssign)
+			p.s.Scan()
+			n, err = wrapassign(ast.AssignmentMultOp, exprOrderAssign)
 			continue
 		} else if t.Type == lexer.TokenPunctuatorDivAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorDivAssign, "expected `/=` operator")
-			n = wrapassign(ast.AssignmentDivOp, exprOrderAssign)
+			p.s.Scan()
+			n, err = wrapassign(ast.AssignmentDivOp, exprOrderAssign)
 			continue
 		} else if t.Type == lexer.TokenPunctuatorModAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorModAssign, "expected `%=` operator")
-			n = wrapassign(ast.AssignmentModOp, exprOrderAssign)
+			p.s.Scan()
+			n, err = wrapassign(ast.AssignmentModOp, exprOrderAssign)
 			continue
 		} else if t.Type == lexer.TokenPunctuatorPlusAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorPlusAssign, "expected `+=` operator")
-			n = wrapassign(ast.AssignmentAddOp, exprOrderAssign)
+			p.s.Scan()
+			n, err = wrapassign(ast.AssignmentAddOp, exprOrderAssign)
 			continue
 		} else if t.Type == lexer.TokenPunctuatorMinusAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorMinusAssign, "expected `-=` operator")
-			n = wrapassign(ast.AssignmentSubOp, exprOrderAssign)
+			p.s.Scan()
+			n, err = wrapassign(ast.AssignmentSubOp, exprOrderAssign)
 			continue
 		} else if t.Type == lexer.TokenPunctuatorLShiftAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorLShiftAssign, "expected `<<=` operator")
-			n = wrapassign(ast.AssignmentLShiftOp, exprOrderAssign)
+			p.s.Scan()
+			n, err = wrapassign(ast.AssignmentLShiftOp, exprOrderAssign)
 			continue
 		} else if t.Type == lexer.TokenPunctuatorRShiftAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorRShiftAssign, "expected `>>=` operator")
-			n = wrapassign(ast.AssignmentRShiftOp, exprOrderAssign)
+			p.s.Scan()
+			n, err = wrapassign(ast.AssignmentRShiftOp, exprOrderAssign)
 			continue
 		} else if t.Type == lexer.TokenPunctuatorUnsignedRShiftAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorUnsignedRShiftAssign, "expected `>>>=` operator")
-			n = wrapassign(ast.AssignmentUnsignedRShiftOp, exprOrderAssign)
+			p.s.Scan()
+			n, err = wrapassign(ast.AssignmentUnsignedRShiftOp, exprOrderAssign)
 			continue
 		} else if t.Type == lexer.TokenPunctuatorBitAndAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorBitAndAssign, "expected `&=` operator")
-			n = wrapassign(ast.AssignmentBitAndOp, exprOrderAssign)
+			p.s.Scan()
+			n, err = wrapassign(ast.AssignmentBitAndOp, exprOrderAssign)
 			continue
 		} else if t.Type == lexer.TokenPunctuatorBitXorAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorBitXorAssign, "expected `^=` operator")
-			n = wrapassign(ast.AssignmentBitXorOp, exprOrderAssign)
+			p.s.Scan()
+			n, err = wrapassign(ast.AssignmentBitXorOp, exprOrderAssign)
 			continue
 		} else if t.Type == lexer.TokenPunctuatorBitOrAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorBitOrAssign, "expected `|=` operator")
-			n = wrapassign(ast.AssignmentBitOrOp, exprOrderAssign)
+			p.s.Scan()
+			n, err = wrapassign(ast.AssignmentBitOrOp, exprOrderAssign)
 			continue
 		} else if t.Type == lexer.TokenPunctuatorExponentAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorExponentAssign, "expected `**=` operator")
-			n = wrapassign(ast.AssignmentExponentOp, exprOrderAssign)
+			p.s.Scan()
+			n, err = wrapassign(ast.AssignmentExponentOp, exprOrderAssign)
 			continue
 		} else if t.Type == lexer.TokenPunctuatorLogicalAndAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorLogicalAndAssign, "expected `&&=` operator")
-			n = wrapassign(ast.AssignmentLogicalAndOp, exprOrderAssign)
+			p.s.Scan()
+			n, err = wrapassign(ast.AssignmentLogicalAndOp, exprOrderAssign)
 			continue
 		} else if t.Type == lexer.TokenPunctuatorLogicalOrAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorLogicalOrAssign, "expected `||=` operator")
-			n = wrapassign(ast.AssignmentLogicalOr, exprOrderAssign)
+			p.s.Scan()
+			n, err = wrapassign(ast.AssignmentLogicalOr, exprOrderAssign)
 			continue
 		} else if t.Type == lexer.TokenPunctuatorNullCoalesceAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorNullCoalesceAssign, "expected `??=` operator")
-			n = wrapassign(ast.AssignmentCoalesceOp, exprOrderAssign)
+			p.s.Scan()
+			n, err = wrapassign(ast.AssignmentCoalesceOp, exprOrderAssign)
 			continue
 		}
 		if order >= exprOrderAssign {
 			break
 		}
 		if t.Type == lexer.TokenPunctuatorComma {
-			p.s.ScanExpect(lexer.TokenPunctuatorComma, "expected `,` operator")
-			if seq, ok := n.(ast.SequenceExpression); ok {
-				seq.Expressions = append(seq.Expressions, p.parseExpression(exprOrderAssign, flags))
-				n = seq
-			} else {
-				seq := ast.SequenceExpression{Expressions: []ast.Node{n}}
+			p.s.Scan()
+			seq, ok := n.(ast.SequenceExpression)
+			if !ok {
+				seq = ast.SequenceExpression{Expressions: []ast.Node{n}}
 				seq.SetStart(s)
-				seq.SetEnd(p.s.Location())
-				seq.Expressions = append(seq.Expressions, p.parseExpression(exprOrderAssign, flags))
-				n = seq
 			}
+			var next ast.Node
+			next, err = p.parseExpression(exprOrderAssign, flags)
+			seq.Expressions = append(seq.Expressions, next)
+			seq.SetEnd(p.s.Location())
+			n = seq
 			continue
 		}
 		if order >= exprOrderComma {
@@ -661,108 +598,373 @@ func (p *Parser) parseExpression(order exprOrder, flags exprFlags) ast.Node {
 		break
 	}
 
-	return n
+	return n, nil
 }
 
-func (p *Parser) convertExprToArrowParams(inner ast.Node) ast.FormalParameters {
-	params := ast.FormalParameters{}
+// binaryOperator is a binary operator and the precedence it is parsed at.
+// Each operator binds more tightly than those at a lower precedence.
+type binaryOperator struct {
+	op    ast.BinaryOperator
+	order exprOrder
+}
 
-	convarg := func(n ast.Node, params *ast.FormalParameters) {
-		switch t := n.(type) {
-		case ast.Identifier:
-			params.Parameters = append(params.Parameters, ast.BindingElement{
-				Value: ast.BindingPattern{Identifier: t.Name},
-			})
-			return
-
-		case ast.AssignmentExpression:
-			left, ok := t.Left.(ast.Identifier)
-			if !ok {
-				p.s.SyntaxError("expected identifier in argument list")
+// binaryOperators gives the binary operator for each token.
+var binaryOperators = map[lexer.TokenType]binaryOperator{
+	lexer.TokenPunctuatorExponent:         {ast.BinaryExponentOp, exprOrderExponentExpr},
+	lexer.TokenPunctuatorMult:             {ast.BinaryMultOp, exprOrderMultiplicativeExpr},
+	lexer.TokenPunctuatorDiv:              {ast.BinaryDivOp, exprOrderMultiplicativeExpr},
+	lexer.TokenPunctuatorMod:              {ast.BinaryModOp, exprOrderMultiplicativeExpr},
+	lexer.TokenPunctuatorPlus:             {ast.BinaryAddOp, exprOrderAdditiveExpr},
+	lexer.TokenPunctuatorMinus:            {ast.BinarySubOp, exprOrderAdditiveExpr},
+	lexer.TokenPunctuatorLShift:           {ast.BinaryLShiftOp, exprOrderShiftExpr},
+	lexer.TokenPunctuatorRShift:           {ast.BinaryRShiftOp, exprOrderShiftExpr},
+	lexer.TokenPunctuatorUnsignedRShift:   {ast.BinaryUnsignedRShiftOp, exprOrderShiftExpr},
+	lexer.TokenPunctuatorLessThan:         {ast.BinaryLessThanOp, exprOrderRelationalExpr},
+	lexer.TokenPunctuatorGreaterThan:      {ast.BinaryGreaterThanOp, exprOrderRelationalExpr},
+	lexer.TokenPunctuatorLessThanEqual:    {ast.BinaryLessThanEqualOp, exprOrderRelationalExpr},
+	lexer.TokenPunctuatorGreaterThanEqual: {ast.BinaryGreaterThanEqualOp, exprOrderRelationalExpr},
+	lexer.TokenKeywordInstanceOf:          {ast.BinaryInstanceOfOp, exprOrderRelationalExpr},
+	lexer.TokenKeywordIn:                  {ast.BinaryInOp, exprOrderRelationalExpr},
+	lexer.TokenPunctuatorEqual:            {ast.BinaryEqualOp, exprOrderEqualityExpr},
+	lexer.TokenPunctuatorNotEqual:         {ast.BinaryNotEqualOp, exprOrderEqualityExpr},
+	lexer.TokenPunctuatorStrictEqual:      {ast.BinaryStrictEqualOp, exprOrderEqualityExpr},
+	lexer.TokenPunctuatorStrictNotEqual:   {ast.BinaryStrictNotEqualOp, exprOrderEqualityExpr},
+	lexer.TokenPunctuatorBitAnd:           {ast.BinaryBitAndOp, exprOrderBitwiseAnd},
+	lexer.TokenPunctuatorBitXor:           {ast.BinaryBitXorOp, exprOrderBitwiseXor},
+	lexer.TokenPunctuatorBitOr:            {ast.BinaryBitOrOp, exprOrderBitwiseOr},
+	lexer.TokenPunctuatorLogicalAnd:       {ast.BinaryLogicalAndOp, exprOrderLogicalAnd},
+	lexer.TokenPunctuatorLogicalOr:        {ast.BinaryLogicalOrOp, exprOrderLogicalOr},
+	lexer.TokenPunctuatorNullCoalesce:     {ast.BinaryCoalesceOp, exprOrderLogicalOr},
+}
+
+// binaryOperatorFor returns the binary operator for t, if it is one that may
+// be parsed in an expression up to the given precedence.
+func binaryOperatorFor(t lexer.Token, flags exprFlags, order exprOrder) (binaryOperator, bool) {
+	b, ok := binaryOperators[t.Type]
+	if !ok || b.order <= order || t.Type == lexer.TokenKeywordIn && flags&exprFlagDisallowIn != 0 {
+		return binaryOperator{}, false
+	}
+	return b, true
+}
+
+// binaryOperand is an operand of a binary expression that is still being
+// parsed, along with its span.
+type binaryOperand struct {
+	node       ast.Node
+	start, end ast.Location
+}
+
+// parseBinaryExpression parses the binary operators that follow left, which
+// starts at start, and their operands, as far as operators of a higher
+// precedence than order continue.
+//
+// Operands and operators are kept on stacks until an operator that does not
+// bind more tightly follows them, so the stack of calls only grows with the
+// nesting of operands, such as in parentheses, and not with the number of
+// operators or precedence levels.
+func (p *Parser) parseBinaryExpression(left ast.Node, start ast.Location, order exprOrder, flags exprFlags) (ast.Node, error) {
+	// Most expressions are short enough for the stacks to fit in these.
+	var operandBuf [8]binaryOperand
+	var operatorBuf [8]binaryOperator
+	operands := append(operandBuf[:0], binaryOperand{left, start, p.s.Location()})
+	operators := operatorBuf[:0]
+
+	// reduce combines the operator on top of the stack with its operands.
+	reduce := func() {
+		l, r := operands[len(operands)-2], operands[len(operands)-1]
+		m := ast.BinaryExpression{Operator: operators[len(operators)-1].op, Left: l.node, Right: r.node}
+		m.SetStart(l.start)
+		m.SetEnd(r.end)
+		operands = append(operands[:len(operands)-2], binaryOperand{m, l.start, r.end})
+		operators = operators[:len(operators)-1]
+	}
+
+	for {
+		t := p.s.PeekAt(0)
+		b, ok := binaryOperatorFor(t, flags, order)
+		if !ok {
+			break
+		}
+		// Operators that bind at least as tightly come first, except that
+		// exponentiation is right-associative.
+		for len(operators) > 0 {
+			top := operators[len(operators)-1].order
+			if top < b.order || top == b.order && b.order == exprOrderExponentExpr {
+				break
 			}
-			name := left.Name
-			params.Parameters = append(params.Parameters, ast.BindingElement{
-				Value: ast.BindingPattern{Identifier: name},
-				Init:  t.Right,
-			})
-			return
-
-		case ast.ArrayExpression:
-			pat := ast.ArrayBindingPattern{}
-			for _, e := range t.Elements {
-				elem := ast.BindingElement{}
-				switch e := e.(type) {
-				case nil:
-					break
-
-				case ast.Identifier:
-					elem.Value = ast.BindingPattern{Identifier: e.Name}
-
-				case ast.AssignmentExpression:
-					left, ok := e.Left.(ast.Identifier)
-					if !ok {
-						p.s.SyntaxError("expected identifier in argument list")
-					}
-					name := left.Name
-					elem = ast.BindingElement{Value: ast.BindingPattern{Identifier: name}, Init: e.Right}
-
-				case ast.TemporalArrayRestElement:
-					pat.RestElement = e.BindingPattern
-					params.Parameters = append(params.Parameters, ast.BindingElement{Value: ast.BindingPattern{ArrayPattern: &pat}})
-					return
-
-				default:
-					p.s.SyntaxError(fmt.Sprintf("unexpected production in array destructuring: %T", e))
-				}
-				pat.Elements = append(pat.Elements, elem)
+			reduce()
+		}
+		if b.op == ast.BinaryExponentOp {
+			// Whether -a ** b would mean (-a) ** b or -(a ** b) is left
+			// ambiguous, so the operand of a unary operator or await must
+			// be in parentheses.
+			switch l := operands[len(operands)-1]; l.node.(type) {
+			case ast.UnaryExpression, ast.AwaitExpression:
+				return nil, p.s.SyntaxErrorAt(ast.Span{Start: l.start, End: l.end}, errs.CodeUnexpectedToken, "a unary expression can not be the left operand of `**` without parentheses")
 			}
-			params.Parameters = append(params.Parameters, ast.BindingElement{Value: ast.BindingPattern{ArrayPattern: &pat}})
-			return
-
-		case ast.ObjectExpression:
-			pat := ast.ObjectBindingPattern{}
-			for _, prop := range t.Properties {
-				if rest, ok := prop.Key.(ast.TemporalObjectRestElement); ok {
-					pat.RestElement = rest.Identifier
-					break
-				}
-				binding := ast.BindingProperty{}
-				fmt.Printf("prop: %#v\n", prop)
-				if key, ok := prop.Key.(ast.Identifier); ok {
-					binding.PropertyName = key.Name
-				}
-				switch key := prop.Value.(type) {
-				case ast.Identifier:
-					binding.Value.Identifier = key.Name
-
-				case ast.AssignmentExpression:
-					left, ok := key.Left.(ast.Identifier)
-					if !ok {
-						p.s.SyntaxError("expected identifier in argument list")
-					}
-					binding.Value.Identifier = left.Name
-					binding.Init = key.Right
-
-				case nil:
-					break
-
-				default:
-					p.s.SyntaxError(fmt.Sprintf("unexpected production in object destructuring: %T", key))
-				}
-				if prop.DestructureInit != nil {
-					binding.Init = prop.DestructureInit
-				}
-				pat.Properties = append(pat.Properties, binding)
+		}
+		p.s.Scan()
+		operators = append(operators, b)
+
+		var operand binaryOperand
+		var err error
+		if operand.node, err = p.parseExpressionAt(exprOrderUnaryExpr, flags&^exprFlagMaybeArrow, &operand.start); err != nil {
+			return nil, err
+		}
+		operand.end = p.s.Location()
+		operands = append(operands, operand)
+	}
+	for len(operators) > 0 {
+		reduce()
+	}
+	return operands[0].node, nil
+}
+
+// parsePropertyName parses the name following a `.` or `?.` operator.
+func (p *Parser) parsePropertyName() (ast.Node, error) {
+	name, err := p.forceScanIdent("expected property name after `.` operator")
+	if err != nil {
+		return nil, err
+	}
+	id := ast.Identifier{Name: name}
+	id.SetStart(p.s.prevSpan.Start)
+	id.SetEnd(p.s.prevSpan.End)
+	return id, nil
+}
+
+// parseComputedProperty parses a computed property assuming a `[` was already
+// consumed.
+func (p *Parser) parseComputedProperty() (ast.Node, error) {
+	n, err := p.parseExpression(exprOrderAssign, 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.s.ScanExpect(lexer.TokenPunctuatorCloseBracket, "expected `]` operator"); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// parseTemplateTail parses a template literal whose first part, t, was
+// already consumed, and which starts at start. Invalid escape sequences are
+// only allowed in a tagged template.
+func (p *Parser) parseTemplateTail(t lexer.Token, start ast.Location, tagged bool) (ast.TemplateLiteral, error) {
+	m := ast.TemplateLiteral{}
+	for {
+		q := ast.TemplateElement{Raw: t.TemplateRaw(), Span: p.s.prevSpan}
+		q.Span.Start.Column++
+		q.Span.End.Column--
+		if !t.TemplateTail() {
+			q.Span.End.Column--
+		}
+		var err error
+		if q.Cooked, err = t.TemplateCooked(); err != nil {
+			if !tagged {
+				return m, p.s.SyntaxErrorAt(p.s.prevSpan, errs.CodeInvalidEscape, err.Error())
 			}
-			params.Parameters = append(params.Parameters, ast.BindingElement{Value: ast.BindingPattern{ObjectPattern: &pat}})
-			return
+			q.Invalid = true
+		}
+		m.Quasis = append(m.Quasis, q)
+		if t.TemplateTail() {
+			break
+		}
+
+		expr, err := p.parseExpression(exprOrderComma, 0)
+		if err != nil {
+			return m, err
+		}
+		m.Expressions = append(m.Expressions, expr)
+		if t = p.s.Scan(); t.Type != lexer.TokenLiteralTemplate || !strings.HasPrefix(t.Literal, "}") {
+			return m, p.s.SyntaxErrorAt(p.s.prevSpan, errs.CodeUnexpectedToken, fmt.Sprintf("expected `}` after template substitution, got %q", t.Source()))
+		}
+	}
+	m.SetStart(start)
+	m.SetEnd(p.s.Location())
+	return m, nil
+}
+
+// parseYieldTail parses a yield expression, which starts at start, assuming
+// the `yield` was already consumed. Like a return statement, a yield has no
+// argument when a line terminator follows it; it also has none when what
+// follows ends the expression it is in.
+func (p *Parser) parseYieldTail(start ast.Location, flags exprFlags) (ast.Node, error) {
+	m := ast.YieldExpression{}
+	m.SetStart(start)
+	m.SetEnd(p.s.prevSpan.End)
+	t := p.s.PeekAt(0)
+	if t.NewLine {
+		return m, nil
+	}
+	switch t.Type {
+	case lexer.TokenNone, lexer.TokenPunctuatorSemicolon, lexer.TokenPunctuatorComma,
+		lexer.TokenPunctuatorColon, lexer.TokenPunctuatorCloseParen,
+		lexer.TokenPunctuatorCloseBracket, lexer.TokenPunctuatorCloseBrace:
+		return m, nil
+	case lexer.TokenKeywordIn:
+		if flags&exprFlagDisallowIn != 0 {
+			return m, nil
+		}
+	case lexer.TokenLiteralTemplate:
+		if strings.HasPrefix(t.Literal, "}") {
+			// The end of a template substitution.
+			return m, nil
+		}
+	case lexer.TokenPunctuatorMult:
+		p.s.Scan()
+		m.Delegate = true
+	}
+
+	var err error
+	if m.Argument, err = p.parseExpression(exprOrderAssign, flags); err != nil {
+		return nil, err
+	}
+	m.SetEnd(p.s.Location())
+	return m, nil
+}
+
+// parseParenthesizedTail parses an expression assuming a `(` was already
+// consumed.
+//
+// Tricky: this could be a parenthesized expression, or the parameter list of
+// an arrow function. To avoid look-ahead, the parser will parse as an
+// expression where possible, but also allow some invalid productions, and
+// then it will be fixed up here. After `async`, which is passed in async, it
+// could also be the arguments of a call to a function named "async".
+func (p *Parser) parseParenthesizedTail(start ast.Location, async *ast.Identifier) (ast.Node, error) {
+	inner, err := p.parseExpression(exprOrderComma, exprFlagMaybeArrow)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)` operator"); err != nil {
+		return nil, err
+	}
+
+	if p.s.PeekAt(0).Type == lexer.TokenPunctuatorFatArrow {
+		// This was an arrow function after all. Fix up the parenthesized
+		// expression to be a parameter list.
+		p.s.Scan()
+		params, err := p.convertExprToArrowParams(inner)
+		if err != nil {
+			return nil, err
+		}
+		body, err := p.parseBlockOrShorthand(async != nil)
+		if err != nil {
+			return nil, err
+		}
+		m := ast.FunctionExpression{
+			Params: params,
+			Body:   body,
+			Arrow:  true,
+			Async:  async != nil,
+		}
+		m.SetStart(start)
+		m.SetEnd(p.s.Location())
+		return m, nil
+	}
+
+	if async != nil {
+		// This was a call to a function named "async"
+		args, err := p.convertExprToCallParams(inner)
+		if err != nil {
+			return nil, err
+		}
+		m := ast.CallExpression{
+			Callee:    *async,
+			Arguments: args,
+		}
+		m.SetStart(start)
+		m.SetEnd(p.s.Location())
+		return m, nil
+	}
+
+	// Was not an arrow. Deal disallowed syntax retroactively.
+	if _, ok := inner.(ast.TemporalEmptyArrowHead); ok || inner.ContainsTemporalNodes() {
+		err := p.s.SyntaxError(errs.CodeInvalidArrowFunction, "expected `=>` operator")
+		return nil, p.suggest(err, arrowSuggestion(p.s.Location()))
+	}
+
+	m := ast.ParenthesizedExpression{Expression: inner}
+	m.SetStart(start)
+	m.SetEnd(p.s.Location())
+	return m, nil
+}
+
+// isAssignmentExpression reports whether n is an expression at the precedence
+// of an assignment, which no operator other than a comma can follow.
+func isAssignmentExpression(n ast.Node) bool {
+	switch n.(type) {
+	case ast.AssignmentExpression, ast.ConditionalExpression, ast.YieldExpression:
+		return true
+	}
+	return false
+}
+
+// isArrowHeadPart returns whether n is the end or the rest element of an arrow
+// function head, which parseExpression returns on its own when it might be
+// parsing one, but which cannot be nested in another expression.
+func isArrowHeadPart(n ast.Node) bool {
+	switch n.(type) {
+	case ast.TemporalEmptyArrowHead, ast.TemporalFloatingRestElement:
+		return true
+	}
+	return false
+}
+
+// isAssignmentTarget reports whether n can be assigned to. Object and array
+// literals can be assigned to as destructuring patterns, when allowed.
+func isAssignmentTarget(n ast.Node, pattern bool) bool {
+	switch n := n.(type) {
+	case ast.Identifier:
+		return true
+	case ast.MemberExpression:
+		return !inOptionalChain(n)
+	case ast.ParenthesizedExpression:
+		return isAssignmentTarget(n.Expression, false)
+	case ast.ObjectExpression, ast.ArrayExpression:
+		return pattern
+	}
+	return false
+}
+
+// inOptionalChain reports whether n is part of an optional chain.
+func inOptionalChain(n ast.Node) bool {
+	for {
+		switch m := n.(type) {
+		case ast.MemberExpression:
+			if m.Optional {
+				return true
+			}
+			n = m.Object
+		case ast.CallExpression:
+			if m.Optional {
+				return true
+			}
+			n = m.Callee
+		default:
+			return false
+		}
+	}
+}
+
+func (p *Parser) convertExprToArrowParams(inner ast.Node) (ast.FormalParameters, error) {
+	params := ast.FormalParameters{}
+
+	convarg := func(n ast.Node) error {
+		switch t := n.(type) {
+		case ast.Identifier, ast.AssignmentExpression, ast.ArrayExpression, ast.ObjectExpression:
+			b, err := p.convertExprToBindingElement(t)
+			if err != nil {
+				return err
+			}
+			params.Parameters = append(params.Parameters, b)
+			return nil
 
 		case ast.TemporalFloatingRestElement:
 			params.RestParameter = t.Identifier
-			return
+			return nil
 
 		default:
-			p.s.SyntaxError(fmt.Sprintf("unexpected production %T in arrow function parameter list", n))
+			return p.s.SyntaxError(errs.CodeInvalidArrowFunction, fmt.Sprintf("unexpected production %T in arrow function parameter list", n))
 		}
 	}
 
@@ -772,75 +974,170 @@ func (p *Parser) convertExprToArrowParams(inner ast.Node) ast.FormalParameters {
 
 	case ast.SequenceExpression:
 		for _, e := range t.Expressions {
-			convarg(e, &params)
+			if err := convarg(e); err != nil {
+				return params, err
+			}
 		}
 
 	default:
-		convarg(t, &params)
+		if err := convarg(t); err != nil {
+			return params, err
+		}
 	}
 
-	return params
+	return params, nil
 }
 
-func (p *Parser) convertExprToCallParams(inner ast.Node) []ast.Node {
+// convertExprToBindingElement converts an expression that was parsed in place
+// of a binding pattern, possibly with a default value, into a binding element.
+func (p *Parser) convertExprToBindingElement(n ast.Node) (ast.BindingElement, error) {
+	if t, ok := n.(ast.AssignmentExpression); ok {
+		if t.Operator != ast.AssignmentOp {
+			return ast.BindingElement{}, p.s.SyntaxError(errs.CodeInvalidDestructuring, "invalid destructuring default")
+		}
+		value, err := p.convertExprToBindingPattern(t.Left)
+		return ast.BindingElement{Value: value, Init: t.Right}, err
+	}
+	value, err := p.convertExprToBindingPattern(n)
+	return ast.BindingElement{Value: value}, err
+}
+
+// convertExprToBindingPattern converts an expression that was parsed in place
+// of a binding pattern into a binding pattern.
+func (p *Parser) convertExprToBindingPattern(n ast.Node) (ast.BindingPattern, error) {
+	switch t := n.(type) {
+	case ast.Identifier:
+		return ast.BindingPattern{Identifier: t.Name}, nil
+
+	case ast.ArrayExpression:
+		pat := &ast.ArrayBindingPattern{}
+		for i, e := range t.Elements {
+			switch e := e.(type) {
+			case nil:
+				// Elision
+				pat.Elements = append(pat.Elements, ast.BindingElement{})
+
+			case ast.SpreadElement:
+				if i != len(t.Elements)-1 {
+					return ast.BindingPattern{}, p.s.SyntaxError(errs.CodeInvalidDestructuring, "rest element must be last element")
+				}
+				rest, err := p.convertExprToBindingPattern(e.Argument)
+				if err != nil {
+					return ast.BindingPattern{}, err
+				}
+				pat.RestElement = rest
+
+			default:
+				b, err := p.convertExprToBindingElement(e)
+				if err != nil {
+					return ast.BindingPattern{}, err
+				}
+				pat.Elements = append(pat.Elements, b)
+			}
+		}
+		return ast.BindingPattern{ArrayPattern: pat}, nil
+
+	case ast.ObjectExpression:
+		pat := &ast.ObjectBindingPattern{}
+		for i, prop := range t.Properties {
+			if prop.Kind == ast.SpreadProperty {
+				rest, ok := prop.Value.(ast.Identifier)
+				if !ok || i != len(t.Properties)-1 {
+					return ast.BindingPattern{}, p.s.SyntaxError(errs.CodeInvalidDestructuring, "rest property must be last and must be an identifier")
+				}
+				pat.RestElement = rest.Name
+				continue
+			}
+			if prop.Kind != ast.InitProperty || prop.Method {
+				return ast.BindingPattern{}, p.s.SyntaxError(errs.CodeInvalidDestructuring, "invalid destructuring target")
+			}
+			binding := ast.BindingProperty{}
+			if key, ok := prop.Key.(ast.Identifier); ok && !prop.Computed {
+				binding.PropertyName = key.Name
+			} else {
+				binding.Key, binding.Computed = prop.Key, prop.Computed
+			}
+			if prop.Value == nil {
+				// Shorthand
+				binding.Init = prop.DestructureInit
+			} else {
+				e, err := p.convertExprToBindingElement(prop.Value)
+				if err != nil {
+					return ast.BindingPattern{}, err
+				}
+				binding.Value, binding.Init = e.Value, e.Init
+			}
+			pat.Properties = append(pat.Properties, binding)
+		}
+		return ast.BindingPattern{ObjectPattern: pat}, nil
+	}
+	return ast.BindingPattern{}, p.s.SyntaxError(errs.CodeInvalidDestructuring, fmt.Sprintf("unexpected production %T in destructuring pattern", n))
+}
+
+func (p *Parser) convertExprToCallParams(inner ast.Node) ([]ast.Node, error) {
+	if _, ok := inner.(ast.TemporalEmptyArrowHead); ok {
+		return []ast.Node{}, nil
+	}
+	if inner.ContainsTemporalNodes() {
+		return nil, p.s.SyntaxError(errs.CodeInvalidArrowFunction, "expected `=>` operator")
+	}
 	if args, ok := inner.(ast.SequenceExpression); ok {
-		return args.Expressions
+		return args.Expressions, nil
 	} else {
-		return []ast.Node{inner}
+		return []ast.Node{inner}, nil
 	}
 }
 
 // Parses an array assuming a `[` was already consumed.
-func (p *Parser) parseArrayTail(start ast.Location, flags exprFlags) ast.Node {
+func (p *Parser) parseArrayTail(start ast.Location, flags exprFlags) (ast.Node, error) {
 	n := ast.ArrayExpression{}
 	n.SetStart(start)
-	defer p.setEnd(&n)
 
 	for {
 		for p.s.PeekAt(0).Type == lexer.TokenPunctuatorComma {
 			n.Elements = append(n.Elements, nil)
-			p.s.ScanExpect(lexer.TokenPunctuatorComma, "expected `,`")
+			p.s.Scan()
 		}
 		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorCloseBracket {
 			break
 		}
-		if flags&exprFlagMaybeArrow != 0 && p.s.PeekAt(0).Type == lexer.TokenPunctuatorEllipsis {
-			p.s.ScanExpect(lexer.TokenPunctuatorEllipsis, "expected `...`")
-			rest := ast.TemporalArrayRestElement{}
-			switch p.s.PeekAt(0).Type {
-			case lexer.TokenPunctuatorCloseBracket:
-				p.s.SyntaxError("expected expression, got ']'")
-			case lexer.TokenPunctuatorOpenBracket:
-				rest.ArrayPattern = p.parseArrayBindingPattern()
-			case lexer.TokenPunctuatorOpenBrace:
-				rest.ObjectPattern = p.parseObjectBindingPattern()
-			case lexer.TokenIdentifier:
-				rest.Identifier = p.forceScanIdent("unexpected token")
-			default:
-				p.s.SyntaxError("missing variable name")
-			}
-			n.Elements = append(n.Elements, rest)
-			break
-		} else {
-			n.Elements = append(n.Elements, p.parseExpression(exprOrderAssign, flags))
+		spread, spreadStart := false, ast.Location{}
+		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorEllipsis {
+			// Spread element, or rest element in a destructuring pattern.
+			p.s.Scan()
+			spread, spreadStart = true, p.s.prevSpan.Start
 		}
-		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorComma {
-			p.s.ScanExpect(lexer.TokenPunctuatorComma, "expected `,`")
+		e, err := p.parseExpression(exprOrderAssign, flags)
+		if err != nil {
+			return nil, err
 		}
-		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorCloseBracket {
+		if isArrowHeadPart(e) {
+			return nil, p.s.SyntaxError(errs.CodeUnexpectedToken, "expected array element")
+		}
+		if spread {
+			m := ast.SpreadElement{Argument: e}
+			m.SetStart(spreadStart)
+			m.SetEnd(p.s.Location())
+			e = m
+		}
+		n.Elements = append(n.Elements, e)
+		if p.s.PeekAt(0).Type != lexer.TokenPunctuatorComma {
 			break
 		}
+		p.s.Scan()
 	}
 
-	p.s.ScanExpect(lexer.TokenPunctuatorCloseBracket, "expected `]`")
-	return n
+	if _, err := p.s.ScanExpect(lexer.TokenPunctuatorCloseBracket, "expected `]`"); err != nil {
+		return nil, err
+	}
+	p.setEnd(&n)
+	return n, nil
 }
 
 // Parses an object assuming a `{` was already consumed.
-func (p *Parser) parseObjectTail(start ast.Location, flags exprFlags) ast.Node {
+func (p *Parser) parseObjectTail(start ast.Location, flags exprFlags) (ast.Node, error) {
 	n := ast.ObjectExpression{}
 	n.SetStart(start)
-	defer p.setEnd(&n)
 
 	atEndOfPropertyKey := func() bool {
 		// Colon ends the property key when not using shorthand, otherwise
@@ -860,27 +1157,22 @@ func (p *Parser) parseObjectTail(start ast.Location, flags exprFlags) ast.Node {
 			t == lexer.TokenPunctuatorOpenParen
 	}
 
-	parseRest := func() ast.TemporalObjectRestElement {
-		rest := ast.TemporalObjectRestElement{}
-		switch p.s.PeekAt(0).Type {
-		case lexer.TokenPunctuatorCloseBrace:
-			p.s.SyntaxError("expected expression, got '}'")
-		case lexer.TokenIdentifier:
-			rest.Identifier = p.forceScanIdent("unexpected token")
-		default:
-			p.s.SyntaxError("missing variable name")
-		}
-		return rest
-	}
+	// keys are where each non-computed key was first defined, so that
+	// duplicates can be pointed out. Patterns may repeat keys, so this is
+	// skipped when the object could turn out to be one.
+	keys := map[string]ast.Span{}
 
 	for {
 		// On first iteration: ends empty object. On other iterations: ends
 		// object after trailing comma.
 		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorCloseBrace {
-			p.s.ScanExpect(lexer.TokenPunctuatorCloseBrace, "expected `}`")
-			return n
+			p.s.Scan()
+			p.setEnd(&n)
+			return n, nil
 		}
 
+		var err error
+
 		// Keeps track of specifiers that are specified for the method
 		// shorthand.
 		async := false
@@ -895,6 +1187,25 @@ func (p *Parser) parseObjectTail(start ast.Location, flags exprFlags) ast.Node {
 		// Handle specifiers before keyword.
 		t := p.s.Scan()
 
+		// Spread properties have no key. In a destructuring pattern, this is
+		// the rest property instead.
+		if t.Type == lexer.TokenPunctuatorEllipsis {
+			prop.Kind = ast.SpreadProperty
+			if prop.Value, err = p.parseExpression(exprOrderAssign, flags); err != nil {
+				return nil, err
+			}
+			n.Properties = append(n.Properties, prop)
+			if p.s.PeekAt(0).Type == lexer.TokenPunctuatorCloseBrace {
+				p.s.Scan()
+				p.setEnd(&n)
+				return n, nil
+			}
+			if _, err = p.s.ScanExpect(lexer.TokenPunctuatorComma, "expected `,` or `}`"); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
 		// We need to special case if we have started on a computed key because
 		// an arbitrary number of tokens will be the computed expression.
 		startedOnComputedKey := t.Type == lexer.TokenPunctuatorOpenBracket
@@ -925,19 +1236,10 @@ func (p *Parser) parseObjectTail(start ast.Location, flags exprFlags) ast.Node {
 			case lexer.TokenPunctuatorMult:
 				generator = true
 
-			case lexer.TokenPunctuatorEllipsis:
-				// For possible-arrow-function: parse rest binding.
-				if flags&exprFlagMaybeArrow != 0 {
-					n.Properties = append(n.Properties, ast.Property{Key: parseRest()})
-					p.s.ScanExpect(lexer.TokenPunctuatorCloseBrace, "expected `}`")
-					return n
-				}
-
-				fallthrough
 			default:
 				// We don't know what is wrong here.
 				// TODO: better error message heuristics here?
-				p.s.SyntaxError("invalid property syntax")
+				return nil, p.s.SyntaxError(errs.CodeUnexpectedToken, "invalid property syntax")
 			}
 
 			pos = p.s.Location()
@@ -963,7 +1265,10 @@ func (p *Parser) parseObjectTail(start ast.Location, flags exprFlags) ast.Node {
 
 		case lexer.TokenLiteralNumber:
 			// Number literal.
-			id := ast.NumberLiteral{Value: t.NumberConstant(), Raw: t.Literal}
+			id, err := p.numberLiteral(t)
+			if err != nil {
+				return nil, err
+			}
 			id.SetStart(pos)
 			id.SetEnd(p.s.Location())
 			prop.Key = id
@@ -971,11 +1276,24 @@ func (p *Parser) parseObjectTail(start ast.Location, flags exprFlags) ast.Node {
 		case lexer.TokenPunctuatorOpenBracket:
 			// Computed identifier.
 			prop.Computed = true
-			prop.Key = p.parseExpression(exprOrderComma, flags)
-			p.s.ScanExpect(lexer.TokenPunctuatorCloseBracket, "expected `]`")
+			if prop.Key, err = p.parseExpression(exprOrderComma, flags); err != nil {
+				return nil, err
+			}
+			if _, err = p.s.ScanExpect(lexer.TokenPunctuatorCloseBracket, "expected `]`"); err != nil {
+				return nil, err
+			}
 
 		default:
-			p.s.SyntaxError("expected property name")
+			return nil, p.s.SyntaxError(errs.CodeUnexpectedToken, "expected property name")
+		}
+
+		if name, ok := propertyKeyName(prop.Key); ok && !prop.Computed && prop.Kind == ast.InitProperty && flags&exprFlagMaybeArrow == 0 {
+			if first, ok := keys[name]; ok {
+				p.warn(p.s.prevSpan, errs.CodeDuplicateKey, fmt.Sprintf("duplicate key %q in object literal", name),
+					errs.Label{Span: first, Message: "first defined here"})
+			} else {
+				keys[name] = p.s.prevSpan
+			}
 		}
 
 		peek := p.s.PeekAt(0)
@@ -984,27 +1302,39 @@ func (p *Parser) parseObjectTail(start ast.Location, flags exprFlags) ast.Node {
 		case prop.Kind == ast.GetProperty || prop.Kind == ast.SetProperty:
 			// Getter/setter
 			fn := ast.FunctionExpression{}
-			fn.Params = p.parseParameters()
-			fn.Body = p.parseBlock()
+			p.setStart(&fn)
+			ctx := p.enterFunction(false, true)
+			fn.Params, err = p.parseParameters()
+			if err == nil {
+				fn.Body, err = p.parseBlock()
+			}
+			p.ctx = ctx
+			if err != nil {
+				return nil, err
+			}
 			fn.SetEnd(p.s.Location())
 			prop.Value = fn
 
 		case peek.Type == lexer.TokenPunctuatorColon:
 			// Normal init property
 			if async || generator {
-				p.s.SyntaxError("expected method")
+				return nil, p.s.SyntaxError(errs.CodeUnexpectedToken, "expected method")
 			}
 
-			p.s.ScanExpect(lexer.TokenPunctuatorColon, "expected `:`")
-			prop.Value = p.parseExpression(exprOrderAssign, flags)
+			p.s.Scan()
+			if prop.Value, err = p.parseExpression(exprOrderAssign, flags); err != nil {
+				return nil, err
+			}
 
 		case flags&exprFlagMaybeArrow != 0 && peek.Type == lexer.TokenPunctuatorAssign:
-			p.s.ScanExpect(lexer.TokenPunctuatorAssign, "expected '='")
-			prop.DestructureInit = p.parseExpression(exprOrderAssign, flags)
+			p.s.Scan()
+			if prop.DestructureInit, err = p.parseExpression(exprOrderAssign, flags); err != nil {
+				return nil, err
+			}
 
 		case peek.Type == lexer.TokenPunctuatorOpenParen:
 			// Method short-hand property
-			ctx := p.ctx
+			ctx := p.enterFunction(false, true)
 			p.ctx.async = async
 			p.ctx.generator = generator
 
@@ -1013,52 +1343,76 @@ func (p *Parser) parseObjectTail(start ast.Location, flags exprFlags) ast.Node {
 				Generator: generator,
 			}
 
-			fn.SetStart(p.s.Location())
-			fn.Params = p.parseParameters()
-			fn.Body = p.parseBlock()
+			p.setStart(&fn)
+			fn.Params, err = p.parseParameters()
+			if err == nil {
+				fn.Body, err = p.parseBlock()
+			}
+			p.ctx = ctx
+			if err != nil {
+				return nil, err
+			}
 			fn.SetEnd(p.s.Location())
 
 			prop.Value = fn
 			prop.Method = true
 
-			p.ctx = ctx
-
 		case peek.Type == lexer.TokenPunctuatorComma ||
 			peek.Type == lexer.TokenPunctuatorCloseBrace:
 			// Shorthand syntax. We don't need to do anything, but we should
 			// disallow this from happening with a computed property.
 			if prop.Computed {
-				p.s.SyntaxError("shorthand not allowed for computed property")
+				return nil, p.s.SyntaxError(errs.CodeUnexpectedToken, "shorthand not allowed for computed property")
 			}
 
 			// We also should not allow this when async/generator is specified.
 			if async || generator {
-				p.s.SyntaxError("expected method")
+				return nil, p.s.SyntaxError(errs.CodeUnexpectedToken, "expected method")
 			}
 
 		default:
-			p.s.SyntaxError("expected `,` or `}`")
+			err := p.s.SyntaxError(errs.CodeUnexpectedToken, "expected `,` or `}`")
+			return nil, p.suggestComma(err, p.s.Location(), peek, "properties")
 		}
 
 		n.Properties = append(n.Properties, prop)
 
 		// Object ends after a property.
 		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorCloseBrace {
-			p.s.ScanExpect(lexer.TokenPunctuatorCloseBrace, "expected `}`")
-			return n
+			p.s.Scan()
+			p.setEnd(&n)
+			return n, nil
 		}
 
 		// Comma before next property, or before ending after a trailing comma.
-		p.s.ScanExpect(lexer.TokenPunctuatorComma, "expected `,` or `}`")
+		end := p.s.Location()
+		if t, err := p.s.ScanExpect(lexer.TokenPunctuatorComma, "expected `,` or `}`"); err != nil {
+			return nil, p.suggestComma(err, end, t, "properties")
+		}
 	}
 }
 
+// parseNewTargetTail parses the rest of new.target after `new`.
+func (p *Parser) parseNewTargetTail(start ast.Location) (ast.Node, error) {
+	p.s.Scan()
+	if t := p.s.Scan(); t.Type != lexer.TokenKeywordTarget {
+		return nil, p.s.SyntaxError(errs.CodeUnexpectedToken, fmt.Sprintf("expected `target` after `new.`, got %s", t.Source()))
+	}
+	if !p.ctx.newTarget {
+		return nil, p.s.SyntaxErrorAt(ast.Span{Start: start, End: p.s.prevSpan.End}, errs.CodeUnexpectedToken, "`new.target` is only allowed inside of a function")
+	}
+	m := ast.MetaProperty{Meta: "new", Property: "target"}
+	m.SetStart(start)
+	m.SetEnd(p.s.prevSpan.End)
+	return m, nil
+}
+
 // Parse traditional function expression
-func (p *Parser) parseFunctionExpressionTail(start ast.Location, async bool) ast.FunctionExpression {
+func (p *Parser) parseFunctionExpressionTail(start ast.Location, async bool) (ast.Node, error) {
 	t := p.ctx.keywordToIdentifier(p.s.Scan(), false)
-	name := ""
+	name, idSpan := "", ast.Span{}
 	if t.Type == lexer.TokenIdentifier {
-		name = t.Literal
+		name, idSpan = t.Literal, p.s.prevSpan
 		t = p.s.Scan()
 	}
 
@@ -1069,18 +1423,27 @@ func (p *Parser) parseFunctionExpressionTail(start ast.Location, async bool) ast
 	}
 
 	if t.Type != lexer.TokenPunctuatorOpenParen {
-		p.s.SyntaxError("expected parameter list following function expression head")
+		return nil, p.s.SyntaxError(errs.CodeUnexpectedToken, "expected parameter list following function expression head")
 	}
 
-	params := p.parseParametersTail()
+	ctx := p.enterFunction(false, false)
+	p.ctx.generator = generator
+	params, err := p.parseParametersTail()
+	if err != nil {
+		p.ctx = ctx
+		return nil, err
+	}
 
-	wasgen := p.ctx.generator
-	p.ctx.generator = true
-	body := p.parseBlock()
-	p.ctx.generator = wasgen
+	p.ctx.async = async
+	body, err := p.parseBlock()
+	p.ctx = ctx
+	if err != nil {
+		return nil, err
+	}
 
 	m := ast.FunctionExpression{
 		ID:        name,
+		IDSpan:    idSpan,
 		Params:    params,
 		Body:      body,
 		Async:     async,
@@ -1090,77 +1453,97 @@ func (p *Parser) parseFunctionExpressionTail(start ast.Location, async bool) ast
 	m.SetStart(start)
 	m.SetEnd(p.s.Location())
 
-	return m
+	return m, nil
 }
 
 // Parses arguments.
-func (p *Parser) parseArguments() []ast.Node {
+func (p *Parser) parseArguments() ([]ast.Node, error) {
 	n := []ast.Node{}
 
-	p.s.ScanExpect(lexer.TokenPunctuatorOpenParen, "expected `(`")
+	if _, err := p.s.ScanExpect(lexer.TokenPunctuatorOpenParen, "expected `(`"); err != nil {
+		return nil, err
+	}
 	if p.s.PeekAt(0).Type == lexer.TokenPunctuatorCloseParen {
-		p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)`")
-		return n
+		p.s.Scan()
+		return n, nil
 	}
 	for {
-		spread := false
+		spread, spreadStart := false, ast.Location{}
 		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorEllipsis {
 			p.s.Scan()
-			spread = true
+			spread, spreadStart = true, p.s.prevSpan.Start
+		}
+		m, err := p.parseExpression(exprOrderAssign, 0)
+		if err != nil {
+			return nil, err
 		}
-		m := p.parseExpression(exprOrderAssign, 0)
 		if spread {
-			m = ast.SpreadElement{Argument: m}
+			e := ast.SpreadElement{Argument: m}
+			e.SetStart(spreadStart)
+			e.SetEnd(p.s.Location())
+			m = e
 		}
 		n = append(n, m)
-		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorComma {
-			p.s.ScanExpect(lexer.TokenPunctuatorComma, "expected `,`")
-		}
-		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorCloseParen {
-			p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)`")
-			return n
+		end := p.s.Location()
+		switch t := p.s.Scan(); t.Type {
+		case lexer.TokenPunctuatorComma:
+			// Allow a trailing comma.
+			if p.s.PeekAt(0).Type == lexer.TokenPunctuatorCloseParen {
+				p.s.Scan()
+				return n, nil
+			}
+		case lexer.TokenPunctuatorCloseParen:
+			return n, nil
+		case lexer.TokenNone:
+			return nil, p.s.SyntaxError(errs.CodeUnexpectedToken, "expected `,` or `)`, got eof")
+		default:
+			err := p.s.SyntaxError(errs.CodeUnexpectedToken, fmt.Sprintf("expected `,` or `)`, got %q", t.Source()))
+			return nil, p.suggestComma(err, end, t, "arguments")
 		}
 	}
 }
 
 // Parses parameters.
-func (p *Parser) parseParameters() ast.FormalParameters {
-	p.s.ScanExpect(lexer.TokenPunctuatorOpenParen, "expected `(`")
+func (p *Parser) parseParameters() (ast.FormalParameters, error) {
+	if _, err := p.s.ScanExpect(lexer.TokenPunctuatorOpenParen, "expected `(`"); err != nil {
+		return ast.FormalParameters{}, err
+	}
 	return p.parseParametersTail()
 }
 
-func (p *Parser) parseParametersTail() ast.FormalParameters {
+func (p *Parser) parseParametersTail() (ast.FormalParameters, error) {
 	n := ast.FormalParameters{}
+	parameters := p.ctx.parameters
+	p.ctx.parameters = true
+	defer func() { p.ctx.parameters = parameters }()
 
 	for {
+		var err error
 		b := ast.BindingElement{}
 		t := p.ctx.keywordToIdentifier(p.s.Scan(), false)
 		switch t.Type {
-		case lexer.TokenIdentifier:
-			b.Value.Identifier = t.Literal
-
 		case lexer.TokenPunctuatorCloseParen:
-			return n
-
-		case lexer.TokenPunctuatorOpenBracket:
-			b.Value.ArrayPattern = p.parseArrayBindingPatternTail()
-
-		case lexer.TokenPunctuatorOpenBrace:
-			b.Value.ObjectPattern = p.parseObjectBindingPatternTail()
+			return n, nil
 
 		case lexer.TokenPunctuatorEllipsis:
-			n.RestParameter = p.scanIdent("expected identifier for rest parameter")
-			p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected closing paren")
-			return n
+			if n.RestParameter, err = p.scanIdent("expected identifier for rest parameter"); err != nil {
+				return n, err
+			}
+			_, err = p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected closing paren")
+			return n, err
 
 		default:
-			p.s.SyntaxError(fmt.Sprintf("unexpected token in formal parameter list: %s", p.s.Scan().Source()))
+			if err = p.parseBindingTarget(t, &b.Value, "formal parameter list"); err != nil {
+				return n, err
+			}
 		}
 
 		// Default syntax
 		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorAssign, "expected default assignment `=`")
-			b.Init = p.parseExpression(exprOrderAssign, 0)
+			p.s.Scan()
+			if b.Init, err = p.parseExpression(exprOrderAssign, 0); err != nil {
+				return n, err
+			}
 		}
 
 		n.Parameters = append(n.Parameters, b)
@@ -1171,10 +1554,10 @@ func (p *Parser) parseParametersTail() ast.FormalParameters {
 			continue
 
 		case lexer.TokenPunctuatorCloseParen:
-			return n
+			return n, nil
 
 		default:
-			p.s.SyntaxError(fmt.Sprintf("expected `,` or `)`, but got: %s", t.Source()))
+			return n, p.s.SyntaxError(errs.CodeUnexpectedToken, fmt.Sprintf("expected `,` or `)`, but got: %s", t.Source()))
 		}
 	}
 }
@@ -1,9 +1,11 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
 )
 
 func TestObjectLiteral(t *testing.T) {
@@ -233,6 +235,84 @@ func TestRegexpLiteral(t *testing.T) {
 	}
 }
 
+func TestBinaryExpressions(t *testing.T) {
+	bin := func(op ast.BinaryOperator, left, right ast.Node) ast.BinaryExpression {
+		return ast.BinaryExpression{Operator: op, Left: left, Right: right}
+	}
+	a, b, c := ident("a"), ident("b"), ident("c")
+	tests := []struct {
+		name     string
+		input    string
+		expected ast.Node
+	}{
+		{
+			"left-associative",
+			"a - b - c",
+			bin(ast.BinarySubOp, bin(ast.BinarySubOp, a, b), c),
+		},
+		{
+			"exponent is right-associative",
+			"a ** b ** c",
+			bin(ast.BinaryExponentOp, a, bin(ast.BinaryExponentOp, b, c)),
+		},
+		{
+			"tighter operator on the right",
+			"a + b * c",
+			bin(ast.BinaryAddOp, a, bin(ast.BinaryMultOp, b, c)),
+		},
+		{
+			"tighter operator on the left",
+			"a * b + c",
+			bin(ast.BinaryAddOp, bin(ast.BinaryMultOp, a, b), c),
+		},
+		{
+			"bitwise operators",
+			"a | b ^ c & a",
+			bin(ast.BinaryBitOrOp, a, bin(ast.BinaryBitXorOp, b, bin(ast.BinaryBitAndOp, c, a))),
+		},
+		{
+			"logical operators",
+			"a || b && c",
+			bin(ast.BinaryLogicalOrOp, a, bin(ast.BinaryLogicalAndOp, b, c)),
+		},
+		{
+			"unary operands",
+			"-a < !b",
+			bin(ast.BinaryLessThanOp, ast.UnaryExpression{Operator: ast.UnaryMinusOp, Argument: a}, ast.UnaryExpression{Operator: ast.UnaryNotOp, Argument: b}),
+		},
+		{
+			"ends at conditional",
+			"a == b ? b : c",
+			ast.ConditionalExpression{Test: bin(ast.BinaryEqualOp, a, b), Consequent: b, Alternate: c},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assertTree(t, test.input, ast.ModuleNode{
+				Body: []ast.Node{
+					ast.ExpressionStatement{
+						Expression: test.expected,
+					},
+				},
+			}, ParseOptions{Mode: ModuleMode})
+		})
+	}
+}
+
+func TestLongBinaryExpression(t *testing.T) {
+	ops := []string{"+", "*", "-", "/", "**", "||", "&&", "|", "&", "<", "==", "<<"}
+	var b strings.Builder
+	b.WriteString("a")
+	for i := 0; i < 10000; i++ {
+		b.WriteString(" " + ops[i%len(ops)] + " a")
+	}
+	p := NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(b.String()), nil)))
+	if _, err := p.Parse(ParseOptions{Mode: ScriptMode}); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestArrowFunctions(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -290,6 +370,48 @@ func TestArrowFunctions(t *testing.T) {
 				Arrow: true,
 			},
 		},
+		{
+			name:  "arrow function returning shorthand object literal",
+			input: "x => ({x})",
+			expected: ast.FunctionExpression{
+				Params: ast.FormalParameters{
+					Parameters: []ast.BindingElement{
+						{Value: ast.BindingPattern{Identifier: "x"}},
+					},
+				},
+				Body: ast.ParenthesizedExpression{
+					Expression: ast.ObjectExpression{
+						Properties: []ast.Property{{Kind: ast.InitProperty, Key: ident("x")}},
+					},
+				},
+				Arrow: true,
+			},
+		},
+		{
+			name:  "arrow function with nested destructuring parameter",
+			input: "({a: [b] = [], [c]: d, ...e}) => {}",
+			expected: ast.FunctionExpression{
+				Params: ast.FormalParameters{
+					Parameters: []ast.BindingElement{
+						{Value: ast.BindingPattern{ObjectPattern: &ast.ObjectBindingPattern{
+							Properties: []ast.BindingProperty{
+								{
+									PropertyName: "a",
+									Value: ast.BindingPattern{ArrayPattern: &ast.ArrayBindingPattern{
+										Elements: []ast.BindingElement{{Value: ast.BindingPattern{Identifier: "b"}}},
+									}},
+									Init: ast.ArrayExpression{},
+								},
+								{Key: ident("c"), Computed: true, Value: ast.BindingPattern{Identifier: "d"}},
+							},
+							RestElement: "e",
+						}}},
+					},
+				},
+				Body:  ast.BlockStatement{},
+				Arrow: true,
+			},
+		},
 		{
 			name:  "arrow function with parameter returning parameter, async",
 			input: "async x => x",
@@ -745,3 +867,292 @@ func TestArrowFunctions(t *testing.T) {
 		})
 	}
 }
+
+func TestAsyncFunctionExpression(t *testing.T) {
+	assertTree(t, "x = async function* () {}", ast.ScriptNode{
+		Body: []ast.Node{
+			ast.ExpressionStatement{
+				Expression: ast.AssignmentExpression{
+					Left:  ident("x"),
+					Right: ast.FunctionExpression{Body: ast.BlockStatement{}, Async: true, Generator: true},
+				},
+			},
+		},
+	}, ParseOptions{})
+}
+
+func TestClassExpressions(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected ast.ClassExpression
+	}{
+		{
+			name:     "empty class",
+			input:    "(class {})",
+			expected: ast.ClassExpression{Body: []ast.Node{}},
+		},
+		{
+			name:  "constructor",
+			input: "(class A extends B { constructor() { super(); } })",
+			expected: ast.ClassExpression{
+				ID:         "A",
+				SuperClass: ident("B"),
+				Body: []ast.Node{
+					ast.MethodDefinition{
+						Key:  ident("constructor"),
+						Kind: ast.ConstructorMethod,
+						Value: ast.FunctionExpression{
+							Body: ast.BlockStatement{Body: []ast.Node{
+								ast.ExpressionStatement{Expression: ast.CallExpression{Callee: ast.Super{}, Arguments: []ast.Node{}}},
+							}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:  "methods using super properties",
+			input: "(class extends B { get a() { return super.a; } static [b]() { return super[b]; } })",
+			expected: ast.ClassExpression{
+				SuperClass: ident("B"),
+				Body: []ast.Node{
+					ast.MethodDefinition{
+						Key:  ident("a"),
+						Kind: ast.GetMethod,
+						Value: ast.FunctionExpression{
+							Body: ast.BlockStatement{Body: []ast.Node{
+								ast.ReturnStatement{Argument: ast.MemberExpression{Object: ast.Super{}, Property: ident("a")}},
+							}},
+						},
+					},
+					ast.MethodDefinition{
+						Key:      ident("b"),
+						Computed: true,
+						Static:   true,
+						Value: ast.FunctionExpression{
+							Body: ast.BlockStatement{Body: []ast.Node{
+								ast.ReturnStatement{Argument: ast.MemberExpression{Object: ast.Super{}, Property: ident("b"), Computed: true}},
+							}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:  "static constructor method",
+			input: "(class { static constructor() {} })",
+			expected: ast.ClassExpression{
+				Body: []ast.Node{
+					ast.MethodDefinition{
+						Key:    ident("constructor"),
+						Static: true,
+						Value:  ast.FunctionExpression{Body: ast.BlockStatement{}},
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assertTree(t, test.input, ast.ScriptNode{
+				Body: []ast.Node{
+					ast.ExpressionStatement{
+						Expression: ast.ParenthesizedExpression{Expression: test.expected},
+					},
+				},
+			}, ParseOptions{Mode: ScriptMode})
+		})
+	}
+}
+
+func TestTemplateLiteral(t *testing.T) {
+	span := func(r1, c1, r2, c2 int) ast.Span {
+		return ast.Span{Start: ast.Location{Row: r1, Column: c1}, End: ast.Location{Row: r2, Column: c2}}
+	}
+	n, err := NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader("tag`a\\x${b}\nc`"), nil))).Parse(ParseOptions{Mode: ExpressionMode})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged, ok := n.(ast.TaggedTemplateExpression)
+	if !ok {
+		t.Fatalf("expected a tagged template, got %T", n)
+	}
+	quasis := tagged.Quasi.Quasis
+	expected := []ast.TemplateElement{
+		{Raw: `a\x`, Invalid: true, Span: span(1, 5, 1, 8)},
+		{Raw: "\nc", Cooked: "\nc", Span: span(1, 12, 2, 2)},
+	}
+	if len(quasis) != len(expected) {
+		t.Fatalf("expected %d quasis, got %d", len(expected), len(quasis))
+	}
+	for i, q := range quasis {
+		if q != expected[i] {
+			t.Errorf("quasi %d: expected %+v, got %+v", i, expected[i], q)
+		}
+	}
+	if s := tagged.Quasi.Span(); s != span(1, 4, 2, 3) {
+		t.Errorf("expected the template to span 1:4-2:3, got %v", s.String())
+	}
+}
+
+func TestYieldExpression(t *testing.T) {
+	num := func(v float64, raw string) ast.NumberLiteral {
+		return ast.NumberLiteral{Value: v, Raw: raw}
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected []ast.Node
+	}{
+		{
+			name:  "bare",
+			input: "yield; yield",
+			expected: []ast.Node{
+				ast.ExpressionStatement{Expression: ast.YieldExpression{}},
+				ast.ExpressionStatement{Expression: ast.YieldExpression{}},
+			},
+		},
+		{
+			name:  "argument and delegate",
+			input: "yield a; yield* b",
+			expected: []ast.Node{
+				ast.ExpressionStatement{Expression: ast.YieldExpression{Argument: ident("a")}},
+				ast.ExpressionStatement{Expression: ast.YieldExpression{Argument: ident("b"), Delegate: true}},
+			},
+		},
+		{
+			name:  "argument takes in an assignment but not a comma",
+			input: "yield a = 1, b",
+			expected: []ast.Node{
+				ast.ExpressionStatement{Expression: ast.SequenceExpression{Expressions: []ast.Node{
+					ast.YieldExpression{Argument: ast.AssignmentExpression{Left: ident("a"), Right: num(1, "1")}},
+					ident("b"),
+				}}},
+			},
+		},
+		{
+			name:  "nested",
+			input: "yield yield* a",
+			expected: []ast.Node{
+				ast.ExpressionStatement{Expression: ast.YieldExpression{Argument: ast.YieldExpression{Argument: ident("a"), Delegate: true}}},
+			},
+		},
+		{
+			name:  "no argument before a line terminator",
+			input: "yield\na; x = yield\n+1",
+			expected: []ast.Node{
+				ast.ExpressionStatement{Expression: ast.YieldExpression{}},
+				ast.ExpressionStatement{Expression: ident("a")},
+				ast.ExpressionStatement{Expression: ast.AssignmentExpression{Left: ident("x"), Right: ast.YieldExpression{}}},
+				ast.ExpressionStatement{Expression: ast.UnaryExpression{Operator: ast.UnaryPlusOp, Argument: num(1, "1")}},
+			},
+		},
+		{
+			name:  "no argument before a closing token",
+			input: "f(yield, [yield], {a: yield})",
+			expected: []ast.Node{
+				ast.ExpressionStatement{Expression: ast.CallExpression{Callee: ident("f"), Arguments: []ast.Node{
+					ast.YieldExpression{},
+					ast.ArrayExpression{Elements: []ast.Node{ast.YieldExpression{}}},
+					ast.ObjectExpression{Properties: []ast.Property{{Key: ident("a"), Value: ast.YieldExpression{}}}},
+				}}},
+			},
+		},
+		{
+			name:  "identifier in a nested function",
+			input: "function f() { return yield }",
+			expected: []ast.Node{
+				ast.FunctionDeclaration{ID: "f", Body: ast.BlockStatement{Body: []ast.Node{
+					ast.ReturnStatement{Argument: ident("yield")},
+				}}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assertTree(t, "function* g() {"+test.input+"}", ast.ScriptNode{
+				Body: []ast.Node{
+					ast.FunctionDeclaration{ID: "g", Generator: true, Body: ast.BlockStatement{Body: test.expected}},
+				},
+			}, ParseOptions{Mode: ScriptMode})
+		})
+	}
+}
+
+func TestAwaitExpression(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected ast.Node
+	}{
+		{
+			name:     "async function",
+			input:    "async function f() { await a }",
+			expected: ast.FunctionDeclaration{ID: "f", Async: true, Body: ast.BlockStatement{Body: []ast.Node{ast.ExpressionStatement{Expression: ast.AwaitExpression{Argument: ident("a")}}}}},
+		},
+		{
+			name:  "binds like a unary operator",
+			input: "x = async function () { await a + await b.c() }",
+			expected: ast.ExpressionStatement{Expression: ast.AssignmentExpression{
+				Left: ident("x"),
+				Right: ast.FunctionExpression{Async: true, Body: ast.BlockStatement{Body: []ast.Node{
+					ast.ExpressionStatement{Expression: ast.BinaryExpression{
+						Operator: ast.BinaryAddOp,
+						Left:     ast.AwaitExpression{Argument: ident("a")},
+						Right:    ast.AwaitExpression{Argument: ast.CallExpression{Callee: ast.MemberExpression{Object: ident("b"), Property: ident("c")}, Arguments: []ast.Node{}}},
+					}},
+				}}},
+			}},
+		},
+		{
+			name:  "async arrow bodies",
+			input: "x = async y => await y, async () => { await await z }",
+			expected: ast.ExpressionStatement{Expression: ast.SequenceExpression{Expressions: []ast.Node{
+				ast.AssignmentExpression{
+					Left: ident("x"),
+					Right: ast.FunctionExpression{
+						Params: ast.FormalParameters{Parameters: []ast.BindingElement{{Value: ast.BindingPattern{Identifier: "y"}}}},
+						Body:   ast.AwaitExpression{Argument: ident("y")},
+						Arrow:  true,
+						Async:  true,
+					},
+				},
+				ast.FunctionExpression{
+					Body: ast.BlockStatement{Body: []ast.Node{
+						ast.ExpressionStatement{Expression: ast.AwaitExpression{Argument: ast.AwaitExpression{Argument: ident("z")}}},
+					}},
+					Arrow: true,
+					Async: true,
+				},
+			}}},
+		},
+		{
+			name:  "identifier outside of async functions",
+			input: "async function f() { function g() { return await } }",
+			expected: ast.FunctionDeclaration{ID: "f", Async: true, Body: ast.BlockStatement{Body: []ast.Node{
+				ast.FunctionDeclaration{ID: "g", Body: ast.BlockStatement{Body: []ast.Node{ast.ReturnStatement{Argument: ident("await")}}}},
+			}}},
+		},
+		{
+			name:  "async method",
+			input: "class A { async *m() { await (yield) } }",
+			expected: ast.ClassDeclaration{ID: "A", Body: []ast.Node{
+				ast.MethodDefinition{
+					Key: ident("m"),
+					Value: ast.FunctionExpression{Async: true, Generator: true, Body: ast.BlockStatement{Body: []ast.Node{
+						ast.ExpressionStatement{Expression: ast.AwaitExpression{Argument: ast.ParenthesizedExpression{Expression: ast.YieldExpression{}}}},
+					}}},
+				},
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assertTree(t, test.input, ast.ScriptNode{Body: []ast.Node{test.expected}}, ParseOptions{Mode: ScriptMode})
+		})
+	}
+}
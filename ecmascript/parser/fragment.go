@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+)
+
+// ParseFragment parses a list of statements that is meant to be placed inside
+// of other code, such as the body of a function, rather than to stand alone.
+// In ScriptMode the fragment holds statements and declarations, and in
+// ModuleMode it may hold import and export declarations as well. Unlike
+// Parse, it does not treat a leading "use strict" as a directive, since that
+// depends on where the fragment ends up. When parsing fails, the statements
+// parsed before the error are returned alongside it.
+func (p *Parser) ParseFragment(opt ParseOptions) ([]ast.Node, error) {
+	var parse func() (ast.Node, error)
+	switch opt.Mode {
+	case ScriptMode:
+		parse = p.parseStatementItem
+	case ModuleMode:
+		parse = p.parseModuleItem
+	default:
+		return nil, fmt.Errorf("unexpected fragment parse mode %d", opt.Mode)
+	}
+	var body []ast.Node
+	err := p.run(context.Background(), opt, func() (err error) {
+		body, err = p.parseStatementList(parse, false)
+		return err
+	})
+	return body, err
+}
+
+// ParseFunction parses a function from the source text of its parameters and
+// of its body, as the Function constructor does. Each must be valid on its
+// own, so that, for example, the body can not end the function early and add
+// code after it.
+//
+// The result is a function named anonymous. Its spans refer to the source
+// text that the Function constructor gives the function it creates, which
+// joins the parameters and body as follows, and they carry uri.
+//
+//	function anonymous(params
+//	) {
+//	body
+//	}
+func ParseFunction(params, body string, uri *url.URL) (ast.FunctionExpression, error) {
+	start := ast.Location{URI: uri, Row: 1, Column: 1}
+	paramsStart := start.Advance("function anonymous")
+	// The body starts where the parameters end, as when the whole text is
+	// parsed.
+	open := paramsStart.Advance("(" + params + "\n)")
+	bodyStart := open.Advance(" {\n")
+
+	f := ast.FunctionExpression{ID: "anonymous", IDSpan: ast.Span{Start: start.Advance("function "), End: paramsStart}}
+	p := NewParser(lexer.NewLexer(lexer.NewScannerAt(strings.NewReader("("+params+"\n)"), paramsStart)))
+	err := p.run(context.Background(), ParseOptions{}, func() (err error) {
+		p.enterFunction(false, false)
+		if f.Params, err = p.parseParameters(); err != nil {
+			return err
+		}
+		if t := p.s.PeekAt(0); t.Type != lexer.TokenNone {
+			return p.s.SyntaxError(errs.CodeUnexpectedToken, fmt.Sprintf("expected end of parameters, got %s", t.Source()))
+		}
+		return nil
+	})
+	if err != nil {
+		return f, err
+	}
+
+	b := ast.BlockStatement{}
+	p = NewParser(lexer.NewLexer(lexer.NewScannerAt(strings.NewReader(body), bodyStart)))
+	if err = p.run(context.Background(), ParseOptions{}, func() (err error) {
+		p.enterFunction(false, false)
+		b.Body, err = p.parseStatementList(p.parseStatementItem, true)
+		return err
+	}); err != nil {
+		return f, err
+	}
+	end := bodyStart.Advance(body + "\n}")
+	b.SetStart(open.Advance(" "))
+	b.SetEnd(end)
+	f.Body = b
+	f.SetStart(start)
+	f.SetEnd(end)
+	return f, nil
+}
@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+)
+
+func TestParseFunction(t *testing.T) {
+	tests := []struct {
+		params, body string
+	}{
+		{"", ""},
+		{"a, b = 1", "return a + b;"},
+		{"a,\n{ b }", "'use strict';\nreturn b;"},
+		{"// c", "/* d */"},
+		{"...rest", "var x = rest.length\nreturn x"},
+	}
+	uri, _ := url.Parse("file:///test.js")
+	for _, test := range tests {
+		src := "function anonymous(" + test.params + "\n) {\n" + test.body + "\n}"
+		t.Run(src, func(t *testing.T) {
+			expected, err := NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(src), uri))).Parse(ParseOptions{Mode: ExpressionMode})
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := ParseFunction(test.params, test.body, uri)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(expected, result, cmp.AllowUnexported(ast.BaseNode{})); diff != "" {
+				t.Errorf("ast mismatch (-expected +result):\n%s", diff)
+			}
+			// The body starts at its opening brace.
+			open := ast.LocationAt(src, len("function anonymous("+test.params+"\n) "))
+			open.URI = uri
+			if start := result.Body.Span().Start; start != open {
+				t.Errorf("expected body to start at %d:%d, got %d:%d", open.Row, open.Column, start.Row, start.Column)
+			}
+		})
+	}
+}
+
+func TestParseFunctionErrors(t *testing.T) {
+	tests := []struct {
+		params, body string
+		loc          ast.Location
+	}{
+		{"a) { x(); } function f(", "", ast.Location{Row: 1, Column: 23}},
+		{"a", "}); x(); (function() {", ast.Location{Row: 3, Column: 1}},
+		{"/*", "*/", ast.Location{Row: 2, Column: 2}},
+		{"a", "return", ast.Location{}},
+		{"a", "'use strict'; var let;", ast.Location{Row: 3, Column: 19}},
+		{"a b", "", ast.Location{Row: 1, Column: 22}},
+	}
+	for _, test := range tests {
+		_, err := ParseFunction(test.params, test.body, nil)
+		if test.loc.Row == 0 {
+			if err != nil {
+				t.Errorf("%q, %q: %v", test.params, test.body, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%q, %q: expected error", test.params, test.body)
+			continue
+		}
+		if d := errs.Diagnostics(err); d[0].Span.Start != test.loc {
+			t.Errorf("%q, %q: expected error at %s, got %v", test.params, test.body, &test.loc, err)
+		}
+	}
+}
+
+func TestParseFragment(t *testing.T) {
+	tests := []struct {
+		s        string
+		mode     ParseMode
+		expected []ast.Node
+	}{
+		{s: "", expected: nil},
+		{s: "a; return", expected: []ast.Node{
+			ast.ExpressionStatement{Expression: ident("a")},
+			ast.ReturnStatement{},
+		}},
+		{s: "'use strict'; var let;", expected: []ast.Node{
+			ast.ExpressionStatement{Expression: ast.StringLiteral{Value: "use strict", Raw: "'use strict'"}},
+			ast.VariableDeclaration{Kind: ast.VarDeclaration, Declarations: []ast.VariableDeclarator{{ID: ast.BindingPattern{Identifier: "let"}}}},
+		}},
+		{s: "export var a;", mode: ModuleMode, expected: []ast.Node{
+			ast.ExportDeclNode{Declaration: ast.VariableDeclaration{Kind: ast.VarDeclaration, Declarations: []ast.VariableDeclarator{{ID: ast.BindingPattern{Identifier: "a"}}}}},
+		}},
+	}
+	for _, test := range tests {
+		result, err := NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(test.s), nil))).ParseFragment(ParseOptions{Mode: test.mode, AllowReturnOutsideFunction: true})
+		if err != nil {
+			t.Errorf("%q: %v", test.s, err)
+			continue
+		}
+		if diff := cmp.Diff(test.expected, result, cmpopts.IgnoreUnexported(ast.BaseNode{}), cmpopts.IgnoreTypes(ast.Span{})); diff != "" {
+			t.Errorf("%q: ast mismatch (-expected +result):\n%s", test.s, diff)
+		}
+	}
+
+	p := NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader("a"), nil)))
+	if _, err := p.ParseFragment(ParseOptions{Mode: ExpressionMode}); err == nil {
+		t.Error("expected error parsing an expression fragment")
+	}
+	p = NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader("import a from 'a';"), nil)))
+	if _, err := p.ParseFragment(ParseOptions{Mode: ScriptMode}); err == nil {
+		t.Error("expected error parsing an import in a script fragment")
+	}
+}
@@ -0,0 +1,126 @@
+//go:build go1.18
+// +build go1.18
+
+package parser
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+)
+
+// lineLengths returns the number of code points on each row of text, counting
+// rows the way the lexer does.
+func lineLengths(text string) []int {
+	lengths := []int{0}
+	for _, r := range text {
+		switch r {
+		case '\u000a', '\u000d', '\u2028', '\u2029':
+			lengths = append(lengths, 0)
+		default:
+			lengths[len(lengths)-1]++
+		}
+	}
+	return lengths
+}
+
+// inBounds returns whether loc is in text, or just past its end.
+func inBounds(lengths []int, loc ast.Location) bool {
+	return loc.Row >= 1 && loc.Row <= len(lengths) && loc.Column >= 1 && loc.Column <= lengths[loc.Row-1]+1
+}
+
+// addSeeds adds the fixtures in testdata/golden with the given extension to
+// the seed corpus.
+func addSeeds(f *testing.F, ext string) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "golden", "*"+ext))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(data))
+	}
+}
+
+// fuzzParse checks that parsing text neither panics nor fails with anything
+// but a diagnostic, that every span in the result or its diagnostics is
+// within the text, and that a tree parsed without errors passes CheckSpans.
+func fuzzParse(t *testing.T, text string, mode ParseMode) {
+	lengths := lineLengths(text)
+	for _, recover := range []bool{false, true} {
+		n, err := NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(text), nil))).Parse(ParseOptions{Mode: mode, Recover: recover})
+		for _, d := range errs.Diagnostics(err) {
+			if d.Code == errs.CodeInternal {
+				t.Fatalf("recover=%v: %s", recover, d.Message)
+			}
+			if !inBounds(lengths, d.Span.Start) {
+				t.Errorf("recover=%v: diagnostic %q at %d:%d, outside of the input", recover, d.Message, d.Span.Start.Row, d.Span.Start.Column)
+			}
+		}
+		if n == nil {
+			continue
+		}
+		ast.Inspect(n, func(c ast.Node) bool {
+			s := c.Span()
+			for _, loc := range []ast.Location{s.Start, s.End} {
+				if loc.Row != 0 && !inBounds(lengths, loc) {
+					t.Errorf("recover=%v: %T at %d:%d, outside of the input", recover, c, loc.Row, loc.Column)
+				}
+			}
+			return true
+		})
+		if err == nil {
+			n.ESTree()
+			for _, err := range ast.CheckSpans(n) {
+				t.Errorf("recover=%v: %v", recover, err)
+			}
+		}
+	}
+}
+
+func FuzzParseScript(f *testing.F) {
+	addSeeds(f, ".js")
+	f.Fuzz(func(t *testing.T, text string) {
+		fuzzParse(t, text, ScriptMode)
+	})
+}
+
+func FuzzParseModule(f *testing.F) {
+	addSeeds(f, ".mjs")
+	addSeeds(f, ".js")
+	f.Fuzz(func(t *testing.T, text string) {
+		fuzzParse(t, text, ModuleMode)
+	})
+}
+
+func FuzzParseDetect(f *testing.F) {
+	addSeeds(f, ".mjs")
+	addSeeds(f, ".js")
+	f.Fuzz(func(t *testing.T, text string) {
+		fuzzParse(t, text, DetectMode)
+	})
+}
+
+func FuzzParseExpression(f *testing.F) {
+	for _, text := range []string{
+		"a + b * c ** d",
+		"x => ({ ...x, [k]: `${v}` })",
+		"async function* () { yield await 0 }",
+		"a?.b ?? c?.[d] || e && !f",
+		"/re/g.test(s) ? new C(...args) : class extends B {}",
+		"[a, { b = 1 }] = [1, {}]",
+	} {
+		f.Add(text)
+	}
+	f.Fuzz(func(t *testing.T, text string) {
+		fuzzParse(t, text, ExpressionMode)
+	})
+}
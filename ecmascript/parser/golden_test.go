@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden.json files in testdata/golden with the current output")
+
+// goldenESTree parses a fixture and returns its ESTree as indented JSON. Files
+// ending in .mjs are parsed as modules, and the rest as scripts.
+func goldenESTree(path string) ([]byte, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mode := ScriptMode
+	if filepath.Ext(path) == ".mjs" {
+		mode = ModuleMode
+	}
+	n, err := NewParser(lexer.NewLexer(lexer.NewScanner(bytes.NewReader(src), nil))).Parse(ParseOptions{Mode: mode})
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(n.ESTree()); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// TestGolden compares the ESTree of each .js and .mjs file in testdata/golden
+// with the .golden.json file next to it. To cover a new construct, add a
+// fixture and run the test with -update to write its golden file, then check
+// the result.
+func TestGolden(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "golden", "*.*js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range fixtures {
+		path := path
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			actual, err := goldenESTree(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := strings.TrimSuffix(path, filepath.Ext(path)) + ".golden.json"
+			if *update {
+				if err := ioutil.WriteFile(golden, actual, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			expected, err := ioutil.ReadFile(golden)
+			if os.IsNotExist(err) {
+				t.Fatalf("no golden file; run go test -run TestGolden -update to write %s", golden)
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(strings.Split(string(expected), "\n"), strings.Split(string(actual), "\n")); diff != "" {
+				t.Errorf("ESTree differs from %s (-golden +actual):\n%s", golden, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+)
+
+// Edit is a change to a text, replacing the bytes from Start up to End with
+// Text.
+type Edit struct {
+	Start, End int
+	Text       string
+}
+
+// Apply returns the result of making the edit to text.
+func (e Edit) Apply(text string) string {
+	return text[:e.Start] + e.Text + text[e.End:]
+}
+
+// Reparse parses the text made by applying edit to old, where prev is the
+// tree from a successful parse of old with the same URI and options. Rather
+// than parsing the whole text, it parses again only the top-level statements
+// that the edit touches, and reuses the others from prev, moving the spans of
+// those after the edit to where they are in the new text. It returns the new
+// tree, along with the number of statements at the start and at the end of
+// its body that were reused.
+//
+// The whole text is parsed again, reusing nothing, when prev is nil or is not
+// a script or module, when comments are kept or tokens and comments are
+// passed to callbacks, when the edit touches the first statement or the
+// directive prologue, or when the statements around the edit might run into
+// the ones next to them, such as through automatic semicolon insertion. This
+// also happens when the statements around the edit fail to parse, so that
+// errors are reported just as Parse reports them.
+func Reparse(prev ast.Node, old string, edit Edit, uri *url.URL, opt ParseOptions) (n ast.Node, head, tail int, err error) {
+	if edit.Start < 0 || edit.Start > edit.End || edit.End > len(old) {
+		return nil, 0, 0, fmt.Errorf("edit from %d to %d is outside of the text", edit.Start, edit.End)
+	}
+	text := edit.Apply(old)
+	if n, head, tail, ok := reparse(prev, old, text, edit, uri, opt); ok {
+		return n, head, tail, nil
+	}
+	n, err = NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(text), uri))).Parse(opt)
+	return n, 0, 0, err
+}
+
+// reparse does the work of Reparse, returning false if the whole text must be
+// parsed instead.
+func reparse(prev ast.Node, old, text string, edit Edit, uri *url.URL, opt ParseOptions) (ast.Node, int, int, bool) {
+	// With DetectMode, the statements are parsed again the way the whole
+	// text was, which a script can check by failing on module syntax.
+	detect := opt.Mode == DetectMode
+	var body []ast.Node
+	switch t := prev.(type) {
+	case ast.ScriptNode:
+		body = t.Body
+		if opt.Mode == DetectMode {
+			opt.Mode = ScriptMode
+		}
+	case ast.ModuleNode:
+		body = t.Body
+		if opt.Mode == DetectMode {
+			opt.Mode = ModuleMode
+		}
+	}
+	if len(body) == 0 || opt.Mode == ExpressionMode || opt.Comments || opt.OnToken != nil || opt.OnComment != nil {
+		return nil, 0, 0, false
+	}
+
+	locs := make([]ast.Location, len(body))
+	for i, s := range body {
+		if locs[i] = s.Span().Start; locs[i].Row == 0 {
+			return nil, 0, 0, false
+		}
+	}
+	starts := ast.Location{Row: 1, Column: 1}.Offsets(old, locs)
+	end := func(i int) int {
+		if i+1 < len(starts) {
+			return starts[i+1]
+		}
+		return len(old)
+	}
+
+	prologue, strict := 0, false
+	for _, s := range body {
+		d := directive(s)
+		if d == "" {
+			break
+		}
+		prologue++
+		strict = strict || d == "use strict"
+	}
+
+	// Statements a to b are parsed again: the first that does not end before
+	// the edit, through the last that starts at or before its end. A
+	// statement ending right where the edit starts may be continued by it,
+	// and one starting right where it ends may begin differently. Each
+	// statement is taken to run up to the start of the next.
+	a := 0
+	for a+1 < len(starts) && starts[a+1] < edit.Start {
+		a++
+	}
+	b := len(starts) - 1
+	for b > a && starts[b] > edit.End {
+		b--
+	}
+	if a == 0 || a < prologue || !terminated(body[a-1], old[starts[a-1]:end(a-1)]) {
+		return nil, 0, 0, false
+	}
+
+	delta := len(text) - len(old)
+	start, stop := starts[a], end(b)+delta
+	from := locs[a]
+	from.URI = uri
+	p := NewParser(lexer.NewLexer(lexer.NewScannerAt(strings.NewReader(text[start:stop]), from)))
+	p.ctx.strictMode = strict
+	n, err := p.Parse(opt)
+	if err != nil {
+		return nil, 0, 0, false
+	}
+	region := programBody(n)
+	if len(region) > 0 {
+		first, last := region[0], region[len(region)-1]
+		lastStart := from.Offsets(text[start:stop], []ast.Location{last.Span().Start})[0]
+		if directive(first) != "" || !terminated(last, text[start+lastStart:stop]) {
+			return nil, 0, 0, false
+		}
+	}
+
+	// The text after the region is unchanged, so locations in it move down
+	// by the number of rows the region gained, and those on its first row
+	// also move across by the number of columns.
+	oldEnd, newEnd := prev.Span().End, from.Advance(text[start:stop])
+	if b+1 < len(locs) {
+		oldEnd = locs[b+1]
+	}
+	move := func(l ast.Location) ast.Location {
+		switch {
+		case l.Row == 0:
+		case l.Row == oldEnd.Row:
+			l.Row, l.Column = newEnd.Row, l.Column-oldEnd.Column+newEnd.Column
+		default:
+			l.Row += newEnd.Row - oldEnd.Row
+		}
+		return l
+	}
+
+	stmts := make([]ast.Node, 0, len(body)-(b-a+1)+len(region))
+	stmts = append(stmts, body[:a]...)
+	stmts = append(stmts, region...)
+	for _, s := range body[b+1:] {
+		if oldEnd != newEnd {
+			s = ast.MapLocations(s, move)
+		}
+		stmts = append(stmts, s)
+	}
+	if _, ok := prev.(ast.ModuleNode); ok && detect && !hasModuleSyntax(stmts) {
+		// The edit removed the last import or export, so the text is now
+		// parsed as a script.
+		return nil, 0, 0, false
+	}
+
+	// The program ends where its last statement does.
+	last := move(prev.Span().End)
+	switch {
+	case b+1 < len(body):
+	case len(region) > 0:
+		last = n.Span().End
+	default:
+		last = body[a-1].Span().End
+	}
+	switch t := prev.(type) {
+	case ast.ScriptNode:
+		t.Body = stmts
+		t.SetEnd(last)
+		return t, a, len(body) - b - 1, true
+	case ast.ModuleNode:
+		t.Body = stmts
+		t.SetEnd(last)
+		return t, a, len(body) - b - 1, true
+	}
+	return nil, 0, 0, false
+}
+
+// programBody returns the top-level statements of a script or module.
+func programBody(n ast.Node) []ast.Node {
+	switch n := n.(type) {
+	case ast.ScriptNode:
+		return n.Body
+	case ast.ModuleNode:
+		return n.Body
+	}
+	return nil
+}
+
+// hasModuleSyntax returns whether any of stmts is an import or export
+// declaration.
+func hasModuleSyntax(stmts []ast.Node) bool {
+	for _, s := range stmts {
+		switch s.(type) {
+		case ast.ImportDeclNode, ast.ExportDeclNode:
+			return true
+		}
+	}
+	return false
+}
+
+// directive returns the directive of a statement in a directive prologue.
+func directive(n ast.Node) string {
+	if e, ok := n.(ast.ExpressionStatement); ok {
+		return e.Directive
+	}
+	return ""
+}
+
+// endsInBlock returns whether a statement ends with a closing brace which
+// cannot be followed by more of the same statement.
+func endsInBlock(n ast.Node) bool {
+	switch n := n.(type) {
+	case ast.BlockStatement, ast.FunctionDeclaration, ast.ClassDeclaration, ast.TryStatement, ast.SwitchStatement:
+		return true
+	case ast.IfStatement:
+		if n.Alternate != nil {
+			return endsInBlock(n.Alternate)
+		}
+		return endsInBlock(n.Consequent)
+	case ast.WhileStatement:
+		return endsInBlock(n.Body)
+	case ast.ForStatement:
+		return endsInBlock(n.Body)
+	case ast.ForInStatement:
+		return endsInBlock(n.Body)
+	case ast.ForOfStatement:
+		return endsInBlock(n.Body)
+	case ast.WithStatement:
+		return endsInBlock(n.Body)
+	case ast.LabeledStatement:
+		return endsInBlock(n.Body)
+	case ast.ExportDeclNode:
+		if n.Declaration != nil {
+			return endsInBlock(n.Declaration)
+		}
+		return n.Default != nil && endsInBlock(n.Default)
+	}
+	return false
+}
+
+// terminated returns whether the statement n, with the source text src,
+// definitely ends where src does: either with a semicolon, or with a block
+// that ends it. Otherwise, the text after it might continue it.
+func terminated(n ast.Node, src string) bool {
+	l := lexer.NewLexer(lexer.NewScanner(strings.NewReader(src), nil))
+	last := lexer.TokenNone
+	for {
+		t, err := l.Lex()
+		if err == nil && last.RegexAllowed() &&
+			(t.Type == lexer.TokenPunctuatorDiv || t.Type == lexer.TokenPunctuatorDivAssign) {
+			var re lexer.ReToken
+			re, err = l.ReLex()
+			t = re.Token
+		}
+		if err != nil {
+			return false
+		}
+		if t.Type == lexer.TokenNone {
+			break
+		}
+		last = t.Type
+	}
+	return last == lexer.TokenPunctuatorSemicolon ||
+		last == lexer.TokenPunctuatorCloseBrace && endsInBlock(n)
+}
@@ -0,0 +1,227 @@
+package parser
+
+import (
+	"math/rand"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+)
+
+// assertReparse checks that reparsing old after edit gives the same tree,
+// spans included, as parsing the new text from scratch, and returns the
+// counts of reused statements.
+func assertReparse(t *testing.T, old string, edit Edit, opt ParseOptions) (ast.Node, int, int) {
+	t.Helper()
+	uri, _ := url.Parse("file:///test.js")
+	prev, err := NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(old), uri))).Parse(opt)
+	if err != nil {
+		t.Fatalf("error parsing %q: %v", old, err)
+	}
+	text := edit.Apply(old)
+	expected, expectedErr := NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(text), uri))).Parse(opt)
+	result, head, tail, err := Reparse(prev, old, edit, uri, opt)
+	if (err == nil) != (expectedErr == nil) {
+		t.Fatalf("reparsing %q: expected error %v, got %v", text, expectedErr, err)
+	}
+	if err != nil {
+		return nil, head, tail
+	}
+	if diff := cmp.Diff(expected, result, cmp.AllowUnexported(ast.BaseNode{})); diff != "" {
+		t.Errorf("reparsing %q: tree mismatch (-expected +result):\n%s", text, diff)
+	}
+	return result, head, tail
+}
+
+func TestReparse(t *testing.T) {
+	tests := []struct {
+		name       string
+		old        string
+		edit       Edit
+		mode       ParseMode
+		head, tail int
+	}{
+		{
+			name: "replace in middle",
+			old:  "a;\nb = 1;\nc;\n",
+			edit: Edit{Start: 7, End: 8, Text: "22"},
+			head: 1, tail: 1,
+		},
+		{
+			name: "new lines before tail",
+			old:  "a;\nb = 1; c(d);\n",
+			edit: Edit{Start: 7, End: 8, Text: "f(\n  1\n)"},
+			head: 1, tail: 1,
+		},
+		{
+			name: "same line as tail",
+			old:  "a; b = 1; c(d, e);",
+			edit: Edit{Start: 7, End: 8, Text: "22"},
+			head: 1, tail: 1,
+		},
+		{
+			name: "delete statement",
+			old:  "a;\nb;\nc;\nd;\ne;",
+			edit: Edit{Start: 6, End: 9},
+			head: 1, tail: 1,
+		},
+		{
+			name: "insert statements",
+			old:  "a;\nb;\nc;",
+			edit: Edit{Start: 5, End: 5, Text: "\nx; y;"},
+			head: 1, tail: 1,
+		},
+		{
+			name: "end of text",
+			old:  "a;\nb;",
+			edit: Edit{Start: 4, End: 5, Text: "c;\n\n"},
+			head: 1, tail: 0,
+		},
+		{
+			name: "first statement",
+			old:  "a;\nb;",
+			edit: Edit{Start: 0, End: 1, Text: "x"},
+		},
+		{
+			name: "continues previous statement",
+			old:  "a\nb;\nc;",
+			edit: Edit{Start: 2, End: 3, Text: "(b)"},
+		},
+		{
+			name: "continues into next statement",
+			old:  "a;\nb;\nc;",
+			edit: Edit{Start: 4, End: 5},
+		},
+		{
+			name: "string after prologue",
+			old:  "'use strict';\na;\n'b';\nc;",
+			edit: Edit{Start: 18, End: 19, Text: "use strict"},
+		},
+		{
+			name: "strict mode",
+			old:  "'use strict';\na;\nb;\nc;",
+			edit: Edit{Start: 17, End: 18, Text: "var let = 1"},
+		},
+		{
+			name: "module",
+			old:  "import a from 'a';\nexport const b = a;\nc;",
+			edit: Edit{Start: 36, End: 37, Text: "a + 1"},
+			mode: ModuleMode,
+			head: 1, tail: 1,
+		},
+		{
+			name: "detected module",
+			old:  "import b from 'b';\na = 1;\nc;",
+			edit: Edit{Start: 23, End: 24, Text: "2"},
+			mode: DetectMode,
+			head: 1, tail: 1,
+		},
+		{
+			name: "detected script",
+			old:  "a;\nwith (b) c = 1;\nd;",
+			edit: Edit{Start: 15, End: 16, Text: "2"},
+			mode: DetectMode,
+			head: 1, tail: 1,
+		},
+		{
+			name: "no longer a module",
+			old:  "a;\nimport b from 'b';\nc;",
+			edit: Edit{Start: 4, End: 20, Text: "f (x) y"},
+			mode: DetectMode,
+		},
+		{
+			name: "syntax error",
+			old:  "a;\nb;\nc;",
+			edit: Edit{Start: 3, End: 4, Text: "("},
+		},
+		{
+			name: "unterminated comment",
+			old:  "a;\nb;\nc;",
+			edit: Edit{Start: 3, End: 3, Text: "/*"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, head, tail := assertReparse(t, test.old, test.edit, ParseOptions{Mode: test.mode})
+			if head != test.head || tail != test.tail {
+				t.Errorf("expected %d and %d statements reused, got %d and %d", test.head, test.tail, head, tail)
+			}
+		})
+	}
+}
+
+func TestReparseEditOutOfRange(t *testing.T) {
+	if _, _, _, err := Reparse(nil, "a;", Edit{Start: 1, End: 3}, nil, ParseOptions{}); err == nil {
+		t.Error("expected error for edit past the end of the text")
+	}
+}
+
+func TestReparseRandomEdits(t *testing.T) {
+	snippets := []string{
+		"a", "b;", ";", "\n", " ", "(", ")", "{", "}", "x = 1;", "function f() {}",
+		"if (a) b;", "'s'", "/re/g", "/ 2", "\r\n", "\u2028", "é", "// c\n", "/* c */",
+		"var", "return", "`t`", "} else {", "for (;;) {}",
+	}
+	src := []string{
+		"var a = 1;",
+		"function f(x) {\n  return x * 2;\n}",
+		"a = f(a) / 2;",
+		"if (a) {\n  b();\n} else c();",
+		"/* é */ var s = 'é\\n';",
+		"for (var i = 0; i < 10; i++) a += i;",
+		"label: while (true) break label;",
+		"x = /re/g.test(s);",
+	}
+	text := strings.Join(src, "\n")
+	opt := ParseOptions{Mode: ScriptMode}
+	prev, err := NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(text), nil))).Parse(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	reused := 0
+	for i := 0; i < 2000; i++ {
+		start := r.Intn(len(text) + 1)
+		end := start + r.Intn(8)
+		if end > len(text) {
+			end = len(text)
+		}
+		edit := Edit{Start: start, End: end, Text: snippets[r.Intn(len(snippets))]}
+		next := edit.Apply(text)
+		if !utf8Boundaries(text, start, end) {
+			continue
+		}
+		expected, expectedErr := NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(next), nil))).Parse(opt)
+		result, head, tail, err := Reparse(prev, text, edit, nil, opt)
+		if (err == nil) != (expectedErr == nil) {
+			t.Fatalf("edit %d of %q: expected error %v, got %v", i, text, expectedErr, err)
+		}
+		if err != nil {
+			continue
+		}
+		if diff := cmp.Diff(expected, result, cmp.AllowUnexported(ast.BaseNode{})); diff != "" {
+			t.Fatalf("edit %d of %q to %s: tree mismatch (-expected +result):\n%s", i, text, strconv.Quote(next), diff)
+		}
+		reused += head + tail
+		text, prev = next, result
+	}
+	if reused == 0 {
+		t.Error("expected some statements to be reused")
+	}
+}
+
+// utf8Boundaries returns whether start and end are not inside of an encoded
+// code point in s.
+func utf8Boundaries(s string, start, end int) bool {
+	for _, i := range []int{start, end} {
+		if i < len(s) && s[i]&0xc0 == 0x80 {
+			return false
+		}
+	}
+	return true
+}
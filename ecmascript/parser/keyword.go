@@ -15,8 +15,8 @@ const (
 	// can be an identifier outside of async contexts.
 	reservedAsync
 
-	// reservedGenerator specifies that a keyword is reserved in generators;
-	// can be an identifier outside of generators.
+	// reservedGenerator specifies that a keyword is reserved in generators
+	// and in strict contexts; can be an identifier elsewhere.
 	reservedGenerator
 
 	// reservedStrict specifies that a keyword is reserved in strict contexts;
@@ -4,55 +4,155 @@ import (
 	"fmt"
 
 	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
 	"github.com/jchv/cleansheets/ecmascript/lexer"
 )
 
-func (p *Parser) parseModule() ast.Node {
+func (p *Parser) parseModule() (ast.Node, error) {
 	// Modules are always strict.
 	p.ctx.strictMode = true
 
 	m := ast.ModuleNode{}
 	p.setStart(&m)
-	defer p.setEnd(&m)
 
-	for {
-		if p.s.PeekAt(0).Type == lexer.TokenNone {
-			break
-		}
-		m.Body = append(m.Body, p.parseModuleItem())
-	}
+	var err error
+	m.Body, err = p.parseStatementList(p.parseModuleItem, true)
+	p.setEnd(&m)
+	return m, err
+}
 
-	return m
+// parseDetect parses a module if the input has import or export
+// declarations, or else a script. It first parses the input as a module, and
+// if that fails before finding any import or export declarations, parses the
+// tokens again as a script, since the input may use syntax that is only
+// allowed outside of strict mode code. A module without import or export
+// declarations that parses without errors would parse the same as a script,
+// so it is returned as one.
+func (p *Parser) parseDetect() (ast.Node, error) {
+	ctx, warnings := p.ctx, len(p.warnings)
+	p.s.record(true)
+	n, err := p.parseModule()
+	if p.moduleSyntax || p.checkDone() != nil {
+		p.s.record(false)
+		return n, err
+	}
+	if err == nil && len(p.errs) == 0 {
+		p.s.record(false)
+		m := ast.ScriptNode{Body: n.(ast.ModuleNode).Body}
+		m.SetStart(n.Span().Start)
+		m.SetEnd(n.Span().End)
+		return m, nil
+	}
+	p.s.rewind()
+	p.ctx, p.warnings, p.errs = ctx, p.warnings[:warnings], nil
+	return p.parseScript()
 }
 
-func (p *Parser) parseModuleItem() ast.Node {
+func (p *Parser) parseModuleItem() (ast.Node, error) {
 	switch p.s.PeekAt(0).Type {
 	case lexer.TokenNone:
-		return nil
+		return nil, nil
 	case lexer.TokenKeywordImport:
+		p.moduleSyntax = true
 		return p.parseImportDecl()
 	case lexer.TokenKeywordExport:
+		p.moduleSyntax = true
 		return p.parseExportDecl()
 	default:
 		return p.parseStatementItem()
 	}
 }
 
-func (p *Parser) parseImportDecl() ast.ImportDeclNode {
+// scanModuleSpecifier expects the string literal naming a module.
+func (p *Parser) scanModuleSpecifier() (string, error) {
+	t, err := p.s.ScanExpect(lexer.TokenLiteralString, "expected module specifier after `from`")
+	if err != nil {
+		return "", err
+	}
+	return t.StringConstant(), nil
+}
+
+// moduleExportName reads the name of an import or export from t, which may be
+// any identifier name, including reserved words, or a string literal. It
+// returns whether the name was a string literal.
+func (p *Parser) moduleExportName(t lexer.Token, message string) (string, bool, error) {
+	if t.Type == lexer.TokenLiteralString {
+		return t.StringConstant(), true, nil
+	}
+	name, err := p.forceIdent(t, message)
+	return name, false, err
+}
+
+// sourcePhaseBinding returns whether `import source` is followed by a
+// binding, making it a source phase import rather than a default import
+// named source.
+func (p *Parser) sourcePhaseBinding() bool {
+	next := p.s.PeekAt(0)
+	if next.Type == lexer.TokenKeywordFrom {
+		// Either import source from from "m"; or import source from "m";
+		return p.s.PeekAt(1).Type == lexer.TokenKeywordFrom
+	}
+	return p.ctx.keywordToIdentifier(next, false).Type == lexer.TokenIdentifier
+}
+
+// parseModuleBlockTail parses a module block after `module`.
+func (p *Parser) parseModuleBlockTail(start ast.Location) (ast.Node, error) {
+	n := ast.ModuleExpression{Body: []ast.Node{}}
+	n.SetStart(start)
+	if _, err := p.s.ScanExpect(lexer.TokenPunctuatorOpenBrace, "expected `{` after `module`"); err != nil {
+		return nil, err
+	}
+
+	// The block is a module of its own, so it is strict and does not see the
+	// functions around it, and its import and export declarations do not make
+	// the code around it a module.
+	ctx, moduleSyntax := p.ctx, p.moduleSyntax
+	p.ctx = parseContext{strictMode: true}
+	defer func() { p.ctx, p.moduleSyntax = ctx, moduleSyntax }()
+
+	for {
+		if t := p.s.PeekAt(0).Type; t == lexer.TokenPunctuatorCloseBrace || t == lexer.TokenNone {
+			if _, err := p.s.ScanExpect(lexer.TokenPunctuatorCloseBrace, "expected module item or closing brace `}`"); err != nil {
+				return nil, err
+			}
+			break
+		}
+		item, err := p.parseListItem(p.parseModuleItem)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
+			n.Body = append(n.Body, item)
+		}
+	}
+	n.SetEnd(p.s.Location())
+	return n, nil
+}
+
+func (p *Parser) parseImportDecl() (ast.Node, error) {
 	n := ast.ImportDeclNode{}
 	p.setStart(&n)
-	defer p.setEnd(&n)
 
-	p.s.ScanExpect(lexer.TokenKeywordImport, "expected `import` declaration")
+	var err error
+	if _, err = p.s.ScanExpect(lexer.TokenKeywordImport, "expected `import` declaration"); err != nil {
+		return nil, err
+	}
 
 	t := p.ctx.keywordToIdentifier(p.s.Scan(), false)
 	switch t.Type {
 	case lexer.TokenLiteralString:
 		n.Module = t.StringConstant()
-		p.expectSemicolon()
-		return n
+		if err = p.expectSemicolon(); err != nil {
+			return nil, err
+		}
+		p.setEnd(&n)
+		return n, nil
 
 	case lexer.TokenIdentifier:
+		if p.experimental.SourcePhaseImports && t.Literal == "source" && p.sourcePhaseBinding() {
+			n.Source = true
+			t = p.ctx.keywordToIdentifier(p.s.Scan(), false)
+		}
 		n.DefaultBinding = &ast.ImportDefaultBinding{
 			Identifier: t.Literal,
 		}
@@ -60,23 +160,35 @@ func (p *Parser) parseImportDecl() ast.ImportDeclNode {
 		t = p.s.Scan()
 		switch t.Type {
 		case lexer.TokenPunctuatorComma:
+			if n.Source {
+				return nil, p.s.SyntaxError(errs.CodeInvalidModuleSyntax, "expected `from` after binding in source import; a source import has a single binding")
+			}
 			t = p.s.Scan()
 
 		case lexer.TokenKeywordFrom:
-			t = p.s.ScanExpect(lexer.TokenLiteralString, "expected module specifier after `from`")
-			n.Module = t.StringConstant()
-			p.expectSemicolon()
-			return n
+			if n.Module, err = p.scanModuleSpecifier(); err != nil {
+				return nil, err
+			}
+			if err = p.expectSemicolon(); err != nil {
+				return nil, err
+			}
+			p.setEnd(&n)
+			return n, nil
 
 		default:
-			p.s.SyntaxError(fmt.Sprintf("expected `,` or `from` after default import in import declaration, got %q", t.Source()))
+			return nil, p.s.SyntaxError(errs.CodeInvalidModuleSyntax, fmt.Sprintf("expected `,` or `from` after default import in import declaration, got %q", t.Source()))
 		}
 	}
 
 	switch t.Type {
 	case lexer.TokenPunctuatorMult:
-		p.s.ScanExpect(lexer.TokenKeywordAs, "expected `as` after namespace binding operator `*`")
-		n.NameSpace = &ast.NameSpaceImport{Identifier: p.scanIdent("expected namespace binding after `* as`")}
+		if _, err = p.s.ScanExpect(lexer.TokenKeywordAs, "expected `as` after namespace binding operator `*`"); err != nil {
+			return nil, err
+		}
+		n.NameSpace = &ast.NameSpaceImport{}
+		if n.NameSpace.Identifier, err = p.scanIdent("expected namespace binding after `* as`"); err != nil {
+			return nil, err
+		}
 
 	case lexer.TokenPunctuatorOpenBrace:
 		n.NamedImports = []ast.NamedImport{}
@@ -87,10 +199,20 @@ func (p *Parser) parseImportDecl() ast.ImportDeclNode {
 			if t.Type == lexer.TokenPunctuatorCloseBrace {
 				break importList
 			}
-			item := ast.NamedImport{
-				Identifier: p.expectIdent(t, "expected import specifier in import list"),
+			// Imported names may be reserved words or strings, but then they
+			// must be renamed with `as`.
+			reserved := p.ctx.keywordToIdentifier(t, false).Type != lexer.TokenIdentifier
+			item := ast.NamedImport{}
+			if item.Identifier, item.Quoted, err = p.moduleExportName(t, "expected import specifier in import list"); err != nil {
+				return nil, err
 			}
 			t = p.s.Scan()
+			if item.Quoted && t.Type != lexer.TokenKeywordAs {
+				return nil, p.s.SyntaxError(errs.CodeInvalidModuleSyntax, fmt.Sprintf("expected `as` after string %q in import list", item.Identifier))
+			}
+			if reserved && t.Type != lexer.TokenKeywordAs {
+				return nil, p.s.SyntaxError(errs.CodeInvalidModuleSyntax, fmt.Sprintf("expected `as` after reserved word %q in import list", item.Identifier))
+			}
 			switch t.Type {
 			case lexer.TokenPunctuatorCloseBrace:
 				n.NamedImports = append(n.NamedImports, item)
@@ -98,7 +220,9 @@ func (p *Parser) parseImportDecl() ast.ImportDeclNode {
 			case lexer.TokenPunctuatorComma:
 				n.NamedImports = append(n.NamedImports, item)
 			case lexer.TokenKeywordAs:
-				item.AsBinding = p.scanIdent("expected import binding after `as` in import list")
+				if item.AsBinding, err = p.scanIdent("expected import binding after `as` in import list"); err != nil {
+					return nil, err
+				}
 				t = p.s.Scan()
 				switch t.Type {
 				case lexer.TokenPunctuatorCloseBrace:
@@ -111,17 +235,150 @@ func (p *Parser) parseImportDecl() ast.ImportDeclNode {
 		}
 
 	default:
-		p.s.SyntaxError("expected namespace or named imports in import statement")
+		return nil, p.s.SyntaxError(errs.CodeInvalidModuleSyntax, "expected namespace or named imports in import statement")
 	}
 
-	p.s.ScanExpect(lexer.TokenKeywordFrom, "expected `from` clause in import declaration")
-	n.Module = p.s.ScanExpect(lexer.TokenLiteralString, "expected module specifier after `from`").StringConstant()
+	if _, err = p.s.ScanExpect(lexer.TokenKeywordFrom, "expected `from` clause in import declaration"); err != nil {
+		return nil, err
+	}
+	if n.Module, err = p.scanModuleSpecifier(); err != nil {
+		return nil, err
+	}
 
-	p.expectSemicolon()
+	if err = p.expectSemicolon(); err != nil {
+		return nil, err
+	}
 
-	return n
+	p.setEnd(&n)
+	return n, nil
 }
 
-func (p *Parser) parseExportDecl() ast.Node {
-	panic("unimplemented")
+func (p *Parser) parseExportDecl() (ast.Node, error) {
+	n := ast.ExportDeclNode{}
+	p.setStart(&n)
+
+	var err error
+	if _, err = p.s.ScanExpect(lexer.TokenKeywordExport, "expected `export` declaration"); err != nil {
+		return nil, err
+	}
+
+	t := p.s.PeekAt(0)
+	switch t.Type {
+	case lexer.TokenKeywordDefault:
+		p.s.Scan()
+		switch t := p.s.PeekAt(0); {
+		case t.Type == lexer.TokenKeywordFunction || p.atAsyncFunction():
+			n.Default, err = p.parseFunctionDeclaration(true)
+		case t.Type == lexer.TokenKeywordClass:
+			n.Default, err = p.parseClassDeclaration(true)
+		default:
+			if n.Default, err = p.parseExpression(exprOrderAssign, 0); err == nil {
+				err = p.expectSemicolon()
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.setEnd(&n)
+		return n, nil
+
+	case lexer.TokenKeywordVar:
+		if n.Declaration, err = p.parseVariableStatement(); err != nil {
+			return nil, err
+		}
+		p.setEnd(&n)
+		return n, nil
+
+	case lexer.TokenKeywordFunction, lexer.TokenKeywordAsync, lexer.TokenKeywordClass, lexer.TokenKeywordLet, lexer.TokenKeywordConst:
+		if n.Declaration, err = p.parseDeclaration(); err != nil {
+			return nil, err
+		}
+		if n.Declaration == nil {
+			// After `async`, only a function is a declaration.
+			return nil, p.s.SyntaxError(errs.CodeInvalidModuleSyntax, "expected `function` after `export async`")
+		}
+		p.setEnd(&n)
+		return n, nil
+
+	case lexer.TokenPunctuatorMult:
+		p.s.Scan()
+		n.NameSpace = &ast.NameSpaceExport{}
+		if p.s.PeekAt(0).Type == lexer.TokenKeywordAs {
+			p.s.Scan()
+			if n.NameSpace.Identifier, n.NameSpace.Quoted, err = p.moduleExportName(p.s.Scan(), "expected export name after `* as`"); err != nil {
+				return nil, err
+			}
+		}
+		if _, err = p.s.ScanExpect(lexer.TokenKeywordFrom, "expected `from` clause in export declaration"); err != nil {
+			return nil, err
+		}
+		if n.Module, err = p.scanModuleSpecifier(); err != nil {
+			return nil, err
+		}
+		if err = p.expectSemicolon(); err != nil {
+			return nil, err
+		}
+		p.setEnd(&n)
+		return n, nil
+
+	case lexer.TokenPunctuatorOpenBrace:
+		p.s.Scan()
+
+	default:
+		return nil, p.s.SyntaxError(errs.CodeInvalidModuleSyntax, fmt.Sprintf("expected declaration, `default`, `*` or export list after `export`, got %q", t.Source()))
+	}
+
+	// Local names may be reserved words or strings only when re-exporting
+	// from another module, so keep track of the first one to report it later.
+	reserved := lexer.Token{}
+
+	n.NamedExports = []ast.NamedExport{}
+
+exportList:
+	for {
+		t = p.s.Scan()
+		if t.Type == lexer.TokenPunctuatorCloseBrace {
+			break exportList
+		}
+		if reserved.Type == lexer.TokenNone && p.ctx.keywordToIdentifier(t, false).Type != lexer.TokenIdentifier {
+			reserved = t
+		}
+		item := ast.NamedExport{}
+		if item.Identifier, item.Quoted, err = p.moduleExportName(t, "expected export specifier in export list"); err != nil {
+			return nil, err
+		}
+		t = p.s.Scan()
+		if t.Type == lexer.TokenKeywordAs {
+			if item.AsBinding, item.AsQuoted, err = p.moduleExportName(p.s.Scan(), "expected export name after `as` in export list"); err != nil {
+				return nil, err
+			}
+			t = p.s.Scan()
+		}
+		n.NamedExports = append(n.NamedExports, item)
+		switch t.Type {
+		case lexer.TokenPunctuatorCloseBrace:
+			break exportList
+		case lexer.TokenPunctuatorComma:
+		default:
+			return nil, p.s.SyntaxError(errs.CodeInvalidModuleSyntax, fmt.Sprintf("expected `,` or `}` in export list, got %q", t.Source()))
+		}
+	}
+
+	if p.s.PeekAt(0).Type == lexer.TokenKeywordFrom {
+		p.s.Scan()
+		if n.Module, err = p.scanModuleSpecifier(); err != nil {
+			return nil, err
+		}
+	} else if reserved.Type == lexer.TokenLiteralString {
+		return nil, p.s.SyntaxError(errs.CodeInvalidModuleSyntax, fmt.Sprintf("unexpected string %s in export list without `from`", reserved.Source()))
+	} else if reserved.Type != lexer.TokenNone {
+		return nil, p.s.SyntaxError(errs.CodeInvalidModuleSyntax, fmt.Sprintf("unexpected reserved word %q in export list", reserved.Source()))
+	}
+
+	if err = p.expectSemicolon(); err != nil {
+		return nil, err
+	}
+
+	p.setEnd(&n)
+	return n, nil
 }
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/jchv/cleansheets/ecmascript/ast"
@@ -20,17 +21,92 @@ const (
 
 	// ExpressionMode parses the ECMAScript code as an expression.
 	ExpressionMode
+
+	// DetectMode parses the ECMAScript code as a module if it has import or
+	// export declarations, and as a script otherwise. Whether the result is
+	// a ModuleNode or a ScriptNode tells which was used.
+	DetectMode
 )
 
 // ParseOptions are options that adjust how ECMAScript code should be parsed.
 type ParseOptions struct {
 	Mode ParseMode
+
+	// Recover continues parsing after a syntax error by skipping to the end
+	// of the statement it occurred in. Parse then returns an errs.ErrorList
+	// of every error found alongside a best-effort AST that leaves out the
+	// statements that failed to parse. Lexer errors still end the parse.
+	Recover bool
+
+	// AllowReturnOutsideFunction accepts return statements outside of
+	// functions, for code that a host wraps in a function before running it,
+	// such as a CommonJS module.
+	AllowReturnOutsideFunction bool
+
+	// AllowNewTargetOutsideFunction accepts new.target outside of functions,
+	// for the same kind of wrapped code.
+	AllowNewTargetOutsideFunction bool
+
+	// AllowSuperOutsideMethod accepts super properties and calls outside of
+	// methods, for code that a host runs as if it were inside of one, such
+	// as a snippet entered in a debugger paused in a method.
+	AllowSuperOutsideMethod bool
+
+	// Comments keeps the comments in the input and sets them as the
+	// Comments of the returned script or module.
+	Comments bool
+
+	// OnToken and OnComment, if set, are called with each token and comment
+	// in the input in the order they appear, as the parser reads them, for
+	// callers that want them without lexing the input again. Where a `/`
+	// begins a regular expression, the token passed is the whole regular
+	// expression. Tokens are passed once each, even when DetectMode reads
+	// them again to parse a script.
+	OnToken   func(t lexer.Token, span ast.Span)
+	OnComment func(c ast.Comment)
+
+	// Experimental accepts syntax from proposals that are not part of
+	// ECMAScript yet. How such syntax is parsed may change as the proposals
+	// do.
+	Experimental Experimental
+}
+
+// Experimental selects the proposals whose syntax is accepted.
+type Experimental struct {
+	// ModuleBlocks accepts module blocks, which are expressions, e.g.
+	// let m = module { export let a = 1; };
+	ModuleBlocks bool
+
+	// SourcePhaseImports accepts imports of the source of a module, e.g.
+	// import source wasm from "./a.wasm";
+	SourcePhaseImports bool
+
+	// Decorators accepts the class elements of the decorators proposal. So
+	// far these are only auto-accessors, e.g. class A { accessor x = 1; };
+	// decorators themselves are not parsed yet.
+	Decorators bool
 }
 
 // Parser parses ECMAScript code according to ECMA262.
 type Parser struct {
 	s   *Scanner
 	ctx parseContext
+
+	recover      bool
+	experimental Experimental
+	errs         []error
+	warnings     []errs.Diagnostic
+
+	// moduleSyntax is set once an import or export declaration is found.
+	moduleSyntax bool
+
+	// cond is where the condition being parsed begins, if any, so that an
+	// assignment in place of a comparison can be pointed out.
+	cond ast.Location
+
+	// done and doneErr come from the context passed to ParseContext.
+	done    <-chan struct{}
+	doneErr func() error
 }
 
 // NewParser creates a new parser.
@@ -38,75 +114,179 @@ func NewParser(l *lexer.Lexer) *Parser {
 	return &Parser{s: NewScanner(l)}
 }
 
-// Parse parses ECMAScript code.
-func (p *Parser) Parse(opt ParseOptions) (n ast.Node, err error) {
+// Parse parses ECMAScript code. When parsing fails, the returned script or
+// module holds the statements that were parsed before the error, alongside
+// the error.
+func (p *Parser) Parse(opt ParseOptions) (ast.Node, error) {
+	return p.ParseContext(context.Background(), opt)
+}
+
+// ParseContext parses ECMAScript code, giving up once ctx is done. It checks
+// ctx before each statement and returns ctx.Err() if it is done.
+func (p *Parser) ParseContext(ctx context.Context, opt ParseOptions) (n ast.Node, err error) {
+	var parse func() (ast.Node, error)
+	switch opt.Mode {
+	case ScriptMode:
+		parse = p.parseScript
+	case ModuleMode:
+		parse = p.parseModule
+	case ExpressionMode:
+		parse = func() (ast.Node, error) {
+			return p.parseExpression(exprOrderComma, 0)
+		}
+	case DetectMode:
+		parse = p.parseDetect
+	default:
+		return nil, fmt.Errorf("unexpected parse mode %d", opt.Mode)
+	}
+	err = p.run(ctx, opt, func() (err error) {
+		n, err = parse()
+		return err
+	})
+	if opt.Comments {
+		switch t := n.(type) {
+		case ast.ScriptNode:
+			t.Comments = p.s.l.Comments()
+			n = t
+		case ast.ModuleNode:
+			t.Comments = p.s.l.Comments()
+			n = t
+		}
+	}
+	return n, err
+}
+
+// run calls parse with the options and context of a parse, and returns the
+// error to report for it.
+func (p *Parser) run(ctx context.Context, opt ParseOptions, parse func() error) (err error) {
+	// Errors are returned normally; this only keeps a bug in the parser from
+	// crashing the caller.
 	defer func() {
 		if r := recover(); r != nil {
-			switch t := r.(type) {
-			case *errs.SyntaxError:
-				err = t
-			case *errs.EncodingError:
-				err = t
-			case *errs.ParserError:
-				err = t
-			default:
-				panic(err)
+			err = &errs.ParserError{
+				Location: p.s.Location(),
+				Code:     errs.CodeInternal,
+				Err:      fmt.Errorf("internal error: %v", r),
 			}
 		}
 	}()
-	switch opt.Mode {
-	case ScriptMode:
-		return p.parseScript(), nil
-	case ModuleMode:
-		return p.parseModule(), nil
-	case ExpressionMode:
-		return p.parseExpression(exprOrderComma, 0), nil
+	p.recover = opt.Recover
+	p.experimental = opt.Experimental
+	p.done, p.doneErr = ctx.Done(), ctx.Err
+	p.ctx.function = opt.AllowReturnOutsideFunction
+	p.ctx.newTarget = opt.AllowNewTargetOutsideFunction
+	p.ctx.super = opt.AllowSuperOutsideMethod
+	if opt.Comments {
+		p.s.l.KeepComments()
+	}
+	if opt.OnComment != nil {
+		p.s.l.OnComment(opt.OnComment)
+	}
+	p.s.onToken = opt.OnToken
+	err = parse()
+	if p.s.onToken != nil {
+		p.s.flush()
+	}
+	if err != nil && p.checkDone() != nil {
+		// Parsing stopped because of the context.
+		return p.doneErr()
+	}
+	if err == nil {
+		err = p.s.Err()
+	}
+	if err != nil {
+		p.errs = append(p.errs, err)
+	}
+	if opt.Recover && len(p.errs) > 0 {
+		return errs.ErrorList(p.errs)
+	}
+	return err
+}
+
+// SourceMappingURL returns the URL given by the //# sourceMappingURL comment
+// at the end of the input, or "" if there is none, once the input has been
+// parsed.
+func (p *Parser) SourceMappingURL() string {
+	return p.s.l.SourceMappingURL()
+}
+
+// checkDone returns the error of the context passed to ParseContext once it
+// is done.
+func (p *Parser) checkDone() error {
+	select {
+	case <-p.done:
+		return p.doneErr()
 	default:
-		panic(fmt.Errorf("unexpected parse mode %d", opt.Mode))
+		return nil
 	}
 }
 
 // scanIdent expects an identifier.
-func (p *Parser) scanIdent(err string) string {
+func (p *Parser) scanIdent(err string) (string, error) {
 	return p.expectIdent(p.s.Scan(), err)
 }
 
 // forceScanIdent expects an identifier even when a reserved keyword is found.
-func (p *Parser) forceScanIdent(err string) string {
+func (p *Parser) forceScanIdent(err string) (string, error) {
 	return p.forceIdent(p.s.Scan(), err)
 }
 
 // expectIdent expects an identifier.
-func (p *Parser) expectIdent(t lexer.Token, err string) string {
+func (p *Parser) expectIdent(t lexer.Token, err string) (string, error) {
 	t = p.ctx.keywordToIdentifier(t, false)
 	if t.Type != lexer.TokenIdentifier {
-		p.s.SyntaxError(fmt.Sprintf("expected identifier, got %s: %s", t.Source(), err))
+		return "", p.s.SyntaxError(errs.CodeExpectedIdentifier, fmt.Sprintf("expected identifier, got %s: %s", t.Source(), err))
 	}
-	return t.Literal
+	return t.Literal, nil
 }
 
 // forceIdent forces conversion to identifier; for contexts where keywords can't appear.
-func (p *Parser) forceIdent(t lexer.Token, err string) string {
+func (p *Parser) forceIdent(t lexer.Token, err string) (string, error) {
 	t = p.ctx.keywordToIdentifier(t, true)
 	if t.Type != lexer.TokenIdentifier {
-		p.s.SyntaxError(fmt.Sprintf("expected identifier, got %s: %s", t.Source(), err))
+		return "", p.s.SyntaxError(errs.CodeExpectedIdentifier, fmt.Sprintf("expected identifier, got %s: %s", t.Source(), err))
 	}
-	return t.Literal
+	return t.Literal, nil
 }
 
 // expectSemicolon expects either a semicolon, or an eligible newline for
 // semicolon insertion.
-func (p *Parser) expectSemicolon() {
+func (p *Parser) expectSemicolon() error {
 	t := p.s.PeekAt(0)
 
 	if t.Type != lexer.TokenPunctuatorSemicolon {
 		// Part of the automatic semi-colon insertion algorithm.
 		if t.NewLine || t.Type == lexer.TokenPunctuatorCloseBrace || t.Type == lexer.TokenNone {
-			return
+			return nil
 		}
 	}
 
-	p.s.ScanExpect(lexer.TokenPunctuatorSemicolon, "did you forget a semicolon?")
+	if t := p.s.Scan(); t.Type != lexer.TokenPunctuatorSemicolon {
+		return p.s.SyntaxError(errs.CodeMissingSemicolon, fmt.Sprintf("expected `;`, got %q: did you forget a semicolon?", t.Source()))
+	}
+	return nil
+}
+
+// unsupported returns an error for valid syntax that the parser can not
+// represent yet.
+func (p *Parser) unsupported(what string) error {
+	return &errs.ParserError{
+		Location: p.s.Location(),
+		Code:     errs.CodeUnsupported,
+		Err:      fmt.Errorf("%s is not supported", what),
+	}
+}
+
+// numberLiteral converts a numeric literal token to a node.
+func (p *Parser) numberLiteral(t lexer.Token) (ast.NumberLiteral, error) {
+	v, err := t.NumberConstant()
+	if err != nil {
+		return ast.NumberLiteral{}, p.s.SyntaxError(errs.CodeInvalidNumber, err.Error())
+	}
+	n := ast.NumberLiteral{Value: v, Raw: t.Literal}
+	n.SetStart(p.s.prevSpan.Start)
+	n.SetEnd(p.s.prevSpan.End)
+	return n, nil
 }
 
 type spannedNode interface {
@@ -115,12 +295,18 @@ type spannedNode interface {
 	SetEnd(ast.Location)
 }
 
-// setStart sets the start of a node to the current location.
+// setStart sets the start of a node to the start of the next token, if it has
+// been peeked at, or else to the current location.
 func (p *Parser) setStart(s spannedNode) {
+	if len(p.s.spans) > 0 {
+		s.SetStart(p.s.spans[0].Start)
+		return
+	}
 	s.SetStart(p.s.Location())
 }
 
-// setEnd sets the end of a node; ideal for use with defer.
+// setEnd sets the end of a node to the end of the last token scanned. It
+// must be called before the node is returned, since nodes are values.
 func (p *Parser) setEnd(s spannedNode) {
 	s.SetEnd(p.s.Location())
 }
@@ -13,8 +13,47 @@ import (
 type Scanner struct {
 	l *lexer.Lexer
 
-	last []lexer.Token
-	loc  []ast.Location
+	last  []lexer.Token
+	loc   []ast.Location
+	spans []ast.Span
+	err   error
+
+	// scanned counts the tokens returned by Scan, so callers can tell
+	// whether any input was consumed, and prev is the last of them.
+	scanned  int
+	prev     lexer.TokenType
+	prevSpan ast.Span
+
+	// While recording, the tokens read from the lexer are kept in tape, so
+	// that the input can be parsed again after rewinding. pos counts the
+	// tokens read since the start, which come from the tape while there are
+	// any left in it.
+	recording bool
+	tape      []lexed
+	pos       int
+
+	// onToken is called with each token read from the lexer once it is
+	// known whether it begins a regular expression. Until then it is
+	// pending, since ReScan may yet relex it.
+	onToken     func(lexer.Token, ast.Span)
+	pending     lexer.Token
+	pendingSpan ast.Span
+}
+
+// lexed is a token read from the lexer, along with where the lexer was before
+// and after reading it.
+type lexed struct {
+	loc   ast.Location
+	token lexer.Token
+	span  ast.Span
+	err   error
+
+	// re is the token relexed as a regular expression, if it was, and reEnd
+	// and reErr are the end of the regular expression and the error from
+	// relexing it.
+	re    *lexer.ReToken
+	reEnd ast.Location
+	reErr error
 }
 
 // NewScanner creates a new scanner.
@@ -27,15 +66,92 @@ func (s *Scanner) Location() ast.Location {
 	if len(s.loc) > 0 {
 		return s.loc[0]
 	}
+	return s.lexLocation()
+}
+
+// lexLocation returns the location of the lexer, or where it was when it
+// read the next token on the tape.
+func (s *Scanner) lexLocation() ast.Location {
+	if s.pos < len(s.tape) {
+		return s.tape[s.pos].loc
+	}
 	return s.l.Location()
 }
 
+// Err returns the error that stopped the lexer, if any. Once the lexer stops,
+// the scanner returns TokenNone as though the input had ended, so any parse
+// that reaches the end needs to check Err before succeeding.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// lex returns the next token from the lexer and its span, or TokenNone after
+// an error.
+func (s *Scanner) lex() (lexer.Token, ast.Span) {
+	if s.err != nil {
+		return lexer.Token{}, s.lexLocation().Span()
+	}
+	if s.pos < len(s.tape) {
+		e := s.tape[s.pos]
+		s.pos++
+		s.err = e.err
+		return e.token, e.span
+	}
+	s.flush()
+	loc := s.l.Location()
+	t, err := s.l.Lex()
+	if err != nil {
+		s.err = err
+	}
+	span := s.l.Location().Span()
+	if t.Type != lexer.TokenNone {
+		span = ast.Span{Start: s.l.Start(), End: s.l.Location()}
+		if s.onToken != nil {
+			s.pending, s.pendingSpan = t, span
+		}
+	}
+	if s.recording {
+		s.tape = append(s.tape, lexed{loc: loc, token: t, span: span, err: err})
+	}
+	s.pos++
+	return t, span
+}
+
+// flush calls onToken with the pending token, if there is one.
+func (s *Scanner) flush() {
+	if s.pending.Type != lexer.TokenNone {
+		s.onToken(s.pending, s.pendingSpan)
+		s.pending = lexer.Token{}
+	}
+}
+
+// record starts or stops recording the tokens read from the lexer. Stopping
+// discards the tokens recorded so far.
+func (s *Scanner) record(on bool) {
+	s.recording = on
+	s.tape = nil
+	s.pos = 0
+}
+
+// rewind stops recording and goes back to the start of the tokens recorded,
+// so that they are read again before any more are read from the lexer.
+func (s *Scanner) rewind() {
+	s.recording = false
+	s.pos = 0
+	s.last, s.loc, s.spans = nil, nil, nil
+	s.err = nil
+	s.scanned, s.prev, s.prevSpan = 0, lexer.TokenNone, ast.Span{}
+}
+
 // PeekAt peeks into the future of the lexer. Calling this function will lex
 // up to i tokens into the future.
 func (s *Scanner) PeekAt(i int) lexer.Token {
 	for len(s.last) <= i {
-		s.loc = append(s.loc, s.Location())
-		s.last = append(s.last, s.l.Lex())
+		// The lexer is at the end of the last token peeked, or scanned.
+		s.loc = append(s.loc, s.lexLocation())
+		t, span := s.lex()
+		s.last = append(s.last, t)
+		s.spans = append(s.spans, span)
 	}
 	return s.last[i]
 }
@@ -46,42 +162,101 @@ func (s *Scanner) PeekLen() int {
 }
 
 // Scan returns the next lexical token.
-func (s *Scanner) Scan() lexer.Token {
+func (s *Scanner) Scan() (t lexer.Token) {
 	if len(s.last) > 0 {
-		t := s.last[0]
+		t, s.prevSpan = s.last[0], s.spans[0]
 		s.last = s.last[1:]
 		s.loc = s.loc[1:]
-		return t
+		s.spans = s.spans[1:]
+	} else {
+		t, s.prevSpan = s.lex()
 	}
-	return s.l.Lex()
+	s.scanned++
+	s.prev = t.Type
+	return t
 }
 
-// ReScan relexes the last token as a regular expression. Panics if we are
-// currently peeked into the future, since ReScan changes the future.
-func (s *Scanner) ReScan() lexer.ReToken {
+// ReScan relexes the last token as a regular expression. It is an error to
+// call ReScan while peeked into the future, since ReScan changes the future.
+func (s *Scanner) ReScan() (lexer.ReToken, error) {
 	if len(s.last) > 0 {
-		panic("internal error")
+		return lexer.ReToken{}, &errs.ParserError{
+			Location: s.Location(),
+			Code:     errs.CodeInternal,
+			Err:      errors.New("cannot relex a regular expression after peeking"),
+		}
+	}
+	if s.err != nil {
+		return lexer.ReToken{}, s.err
+	}
+	if i := s.pos - 1; i >= 0 && i < len(s.tape) {
+		e := &s.tape[i]
+		switch {
+		case e.re != nil:
+			s.prevSpan.End = e.reEnd
+			s.err = e.reErr
+			return *e.re, e.reErr
+		case s.pos < len(s.tape):
+			return lexer.ReToken{}, &errs.ParserError{
+				Location: s.Location(),
+				Code:     errs.CodeInternal,
+				Err:      errors.New("cannot relex a regular expression read from the tape"),
+			}
+		}
 	}
-	return s.l.ReLex()
+	t, err := s.l.ReLex()
+	if err != nil {
+		s.err = err
+	}
+	s.prevSpan.End = s.l.Location()
+	if s.onToken != nil && err == nil {
+		s.pending, s.pendingSpan = t.Token, s.prevSpan
+	}
+	if s.recording && s.pos > 0 {
+		e := &s.tape[s.pos-1]
+		e.re, e.reEnd, e.reErr = &t, s.prevSpan.End, err
+	}
+	return t, err
 }
 
-// ScanExpect scans and panics if the token is not of the expected type.
-func (s *Scanner) ScanExpect(typ lexer.TokenType, err string) lexer.Token {
+// ScanExpect scans and returns an error if the token is not of the expected
+// type.
+func (s *Scanner) ScanExpect(typ lexer.TokenType, err string) (lexer.Token, error) {
 	t := s.Scan()
 	if t.Type != typ {
 		if t.Type == lexer.TokenNone {
-			s.SyntaxError(fmt.Sprintf("expected %s, got eof: %s", typ, err))
-		} else {
-			s.SyntaxError(fmt.Sprintf("expected %s, got %q: %s", typ, t.Source(), err))
+			return t, s.SyntaxError(errs.CodeUnexpectedToken, fmt.Sprintf("expected %s, got eof: %s", typ, err))
 		}
+		return t, s.SyntaxError(errs.CodeUnexpectedToken, fmt.Sprintf("expected %s, got %q: %s", typ, t.Source(), err))
 	}
-	return t
+	return t, nil
+}
+
+// SyntaxError returns a syntax error with the given string, spanning the
+// token that is most likely at fault: the next one if it has been peeked at,
+// or else the last one scanned. If the lexer has stopped, its error is
+// returned instead, since the unexpected token is likely the result of it.
+func (s *Scanner) SyntaxError(code errs.Code, err string) error {
+	if s.err != nil {
+		return s.err
+	}
+	span := s.prevSpan
+	if len(s.spans) > 0 {
+		span = s.spans[0]
+	}
+	return s.SyntaxErrorAt(span, code, err)
 }
 
-// SyntaxError panics with a syntax error with the given string.
-func (s *Scanner) SyntaxError(err string) {
-	panic(&errs.SyntaxError{
-		Location: s.Location(),
+// SyntaxErrorAt returns a syntax error with the given string that spans the
+// given source, or the lexer's error if it has stopped.
+func (s *Scanner) SyntaxErrorAt(span ast.Span, code errs.Code, err string) error {
+	if s.err != nil {
+		return s.err
+	}
+	return &errs.SyntaxError{
+		Location: span.Start,
+		End:      span.End,
+		Code:     code,
 		Err:      errors.New(err),
-	})
+	}
 }
@@ -1,21 +1,13 @@
 package parser
 
-import (
-	"github.com/jchv/cleansheets/ecmascript/ast"
-	"github.com/jchv/cleansheets/ecmascript/lexer"
-)
+import "github.com/jchv/cleansheets/ecmascript/ast"
 
-func (p *Parser) parseScript() ast.Node {
+func (p *Parser) parseScript() (ast.Node, error) {
 	m := ast.ScriptNode{}
 	p.setStart(&m)
-	defer p.setEnd(&m)
 
-	for {
-		if p.s.PeekAt(0).Type == lexer.TokenNone {
-			break
-		}
-		m.Body = append(m.Body, p.parseStatementItem())
-	}
-
-	return m
+	var err error
+	m.Body, err = p.parseStatementList(p.parseStatementItem, true)
+	p.setEnd(&m)
+	return m, err
 }
@@ -4,21 +4,21 @@ import (
 	"fmt"
 
 	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
 	"github.com/jchv/cleansheets/ecmascript/lexer"
 )
 
-func (p *Parser) parseStatementItem() ast.Node {
-	if n := p.parseStatement(); n != nil {
-		return n
+func (p *Parser) parseStatementItem() (ast.Node, error) {
+	if n, err := p.parseStatement(); n != nil || err != nil {
+		return n, err
 	}
-	if n := p.parseDeclaration(); n != nil {
-		return n
+	if n, err := p.parseDeclaration(); n != nil || err != nil {
+		return n, err
 	}
-	p.s.SyntaxError("expected declaration or statement")
-	return nil
+	return nil, p.s.SyntaxError(errs.CodeUnexpectedToken, "expected declaration or statement")
 }
 
-func (p *Parser) parseStatement() ast.Node {
+func (p *Parser) parseStatement() (ast.Node, error) {
 	switch p.s.PeekAt(0).Type {
 	case lexer.TokenPunctuatorOpenBrace:
 		return p.parseBlock()
@@ -38,7 +38,7 @@ func (p *Parser) parseStatement() ast.Node {
 		// allowed if not followed by `function` with no newline. `let` is
 		// allowed if not followed by `[`.
 		// Additionally, we handle expressions starting with identifiers further down.
-		lexer.TokenKeywordThis, lexer.TokenKeywordNull, lexer.TokenKeywordTrue,
+		lexer.TokenKeywordThis, lexer.TokenKeywordSuper, lexer.TokenKeywordNull, lexer.TokenKeywordTrue,
 		lexer.TokenKeywordFalse, lexer.TokenKeywordNew,
 		lexer.TokenLiteralNumber, lexer.TokenLiteralString,
 		lexer.TokenLiteralTemplate,
@@ -47,19 +47,19 @@ func (p *Parser) parseStatement() ast.Node {
 		// These will get relexed as a regexp, so they are valid to begin an expression.
 		lexer.TokenPunctuatorDiv, lexer.TokenPunctuatorDivAssign:
 		// Async function declaration (async [no line terminator] function)
-		if p.s.PeekAt(0).Type == lexer.TokenKeywordAsync && p.s.PeekAt(1).Type == lexer.TokenKeywordFunction && !p.s.PeekAt(1).NewLine {
-			return nil
+		if p.atAsyncFunction() {
+			return nil, nil
 		}
 		if p.s.PeekAt(0).Type == lexer.TokenKeywordLet {
 			if p.s.PeekAt(1).Type == lexer.TokenPunctuatorOpenBracket {
 				// Array destructuring let (let [)
-				return nil
+				return nil, nil
 			} else if p.s.PeekAt(1).Type == lexer.TokenPunctuatorOpenBrace {
 				// Object destructuring let (let {)
-				return nil
+				return nil, nil
 			} else if p.ctx.keywordToIdentifier(p.s.PeekAt(1), true).Type == lexer.TokenIdentifier {
 				// Let with identifier (let ident)
-				return nil
+				return nil, nil
 			}
 		}
 		return p.parseExpressionStatement()
@@ -85,6 +85,14 @@ func (p *Parser) parseStatement() ast.Node {
 		return p.parseTryStatement()
 	case lexer.TokenKeywordDebugger:
 		return p.parseDebuggerStatement()
+	case lexer.TokenKeywordYield, lexer.TokenKeywordAwait:
+		// In a generator or an async function respectively, this begins a
+		// yield or await expression. Elsewhere, it is an identifier, and may
+		// be a label.
+		if p.ctx.keywordToIdentifier(p.s.PeekAt(0), false).Type != lexer.TokenIdentifier {
+			return p.parseExpressionStatement()
+		}
+		fallthrough
 	case lexer.TokenIdentifier:
 		fallthrough
 	default:
@@ -95,19 +103,42 @@ func (p *Parser) parseStatement() ast.Node {
 			return p.parseExpressionStatement()
 		}
 	}
-	return nil
+	return nil, nil
+}
+
+// parseSubStatement parses the statement that forms the body of another.
+func (p *Parser) parseSubStatement() (ast.Node, error) {
+	n, err := p.parseStatement()
+	if n == nil && err == nil {
+		if t := p.s.PeekAt(0); t.Type == lexer.TokenNone {
+			err = p.s.SyntaxError(errs.CodeUnexpectedToken, "expected statement, got eof")
+		} else {
+			err = p.s.SyntaxError(errs.CodeUnexpectedToken, fmt.Sprintf("expected statement, got %q", t.Source()))
+		}
+	}
+	return n, err
 }
 
-func (p *Parser) parseExpressionStatement() ast.Node {
-	expr := p.parseExpression(exprOrderComma, 0)
+func (p *Parser) parseExpressionStatement() (ast.Node, error) {
+	expr, err := p.parseExpression(exprOrderComma, 0)
+	if err != nil {
+		return nil, err
+	}
 	n := ast.ExpressionStatement{Expression: expr}
 	n.SetStart(expr.Span().Start)
 	n.SetEnd(expr.Span().End)
-	p.expectSemicolon()
-	return n
+	if err := p.expectSemicolon(); err != nil {
+		return nil, p.suggestStatementEnd(err, expr)
+	}
+	return n, nil
 }
 
-func (p *Parser) parseBlockOrShorthand() ast.Node {
+// parseBlockOrShorthand parses the body of an arrow function, which is async
+// if async is set.
+func (p *Parser) parseBlockOrShorthand(async bool) (ast.Node, error) {
+	ctx := p.enterFunction(true, false)
+	defer func() { p.ctx = ctx }()
+	p.ctx.async = async
 	if p.s.PeekAt(0).Type == lexer.TokenPunctuatorOpenBrace {
 		return p.parseBlock()
 	} else {
@@ -115,107 +146,239 @@ func (p *Parser) parseBlockOrShorthand() ast.Node {
 	}
 }
 
-func (p *Parser) parseBlock() ast.BlockStatement {
+func (p *Parser) parseBlock() (ast.BlockStatement, error) {
 	n := ast.BlockStatement{}
+	// Peek at the brace, so that the block starts at it rather than where the
+	// token before it ends.
+	p.s.PeekAt(0)
 	p.setStart(&n)
-	defer p.setEnd(&n)
-
-	p.s.ScanExpect(lexer.TokenPunctuatorOpenBrace, "expected block opening brace `{`")
 
-	// Early exit for empty block.
-	if p.s.PeekAt(0).Type == lexer.TokenPunctuatorCloseBrace {
-		p.s.ScanExpect(lexer.TokenPunctuatorCloseBrace, "expected statement, declaration, or closing brace `}`")
-		return n
+	if _, err := p.s.ScanExpect(lexer.TokenPunctuatorOpenBrace, "expected block opening brace `{`"); err != nil {
+		p.setEnd(&n)
+		return n, err
 	}
 
 	ctx := p.ctx
 
-	// Parse first statement so we can parse directives out of it.
-	stmt := p.parseStatementItem()
-	if expr, ok := stmt.(ast.ExpressionStatement); ok {
-		if str, ok := expr.Expression.(ast.StringLiteral); ok {
-			if str.Value == "use strict" {
-				ctx.strictMode = true
-				expr.Directive = "use strict"
+	for {
+		if t := p.s.PeekAt(0).Type; t == lexer.TokenPunctuatorCloseBrace || t == lexer.TokenNone {
+			if _, err := p.s.ScanExpect(lexer.TokenPunctuatorCloseBrace, "expected statement, declaration, or closing brace `}`"); err != nil {
+				p.setEnd(&n)
+				return n, err
 			}
+			break
+		}
+		stmt, err := p.parseListItem(p.parseStatementItem)
+		if err != nil {
+			p.setEnd(&n)
+			return n, err
+		}
+		if stmt == nil {
+			continue
 		}
-		stmt = expr
+		if len(n.Body) == 0 {
+			// Parse directives out of the first statement. Strict mode
+			// applies to the rest of the block, and ends with it.
+			stmt = p.parseDirective(stmt, &p.ctx)
+		}
+		n.Body = append(n.Body, stmt)
 	}
-	n.Body = append(n.Body, stmt)
 
-	for {
-		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorCloseBrace {
-			p.s.ScanExpect(lexer.TokenPunctuatorCloseBrace, "expected statement, declaration, or closing brace `}`")
-			break
+	p.ctx = ctx
+
+	p.setEnd(&n)
+	return n, nil
+}
+
+// parseStatementList parses items with parse up to the end of the input. If
+// directives is set, a leading "use strict" is parsed as a directive, as at
+// the start of a script, module or function body.
+func (p *Parser) parseStatementList(parse func() (ast.Node, error), directives bool) ([]ast.Node, error) {
+	var body []ast.Node
+	for p.s.PeekAt(0).Type != lexer.TokenNone {
+		stmt, err := p.parseListItem(parse)
+		if err != nil {
+			return body, err
+		}
+		if stmt == nil {
+			continue
 		}
-		n.Body = append(n.Body, p.parseStatementItem())
+		if directives && len(body) == 0 {
+			stmt = p.parseDirective(stmt, &p.ctx)
+		}
+		body = append(body, stmt)
 	}
+	return body, nil
+}
 
-	p.ctx = ctx
+// parseListItem parses an item of a statement list with parse. When
+// recovering from errors, a failed item is recorded and skipped, returning a
+// nil node. Lexer errors and cancellation can not be skipped and are always
+// returned.
+func (p *Parser) parseListItem(parse func() (ast.Node, error)) (ast.Node, error) {
+	if err := p.checkDone(); err != nil {
+		return nil, err
+	}
+	start := p.s.scanned
+	n, err := parse()
+	if err == nil || !p.recover || p.s.Err() != nil || p.checkDone() != nil {
+		return n, err
+	}
+	p.errs = append(p.errs, err)
+	p.skipStatement()
+	if p.s.scanned == start {
+		// Nothing could be parsed or skipped, e.g. a stray `}` at the top
+		// level; drop a token so the parse always moves forward.
+		p.s.Scan()
+	}
+	return nil, nil
+}
 
-	return n
+// skipStatement skips to the end of the current statement: past the next
+// semicolon or balanced block, or up to a closing brace that belongs to the
+// enclosing block.
+func (p *Parser) skipStatement() {
+	if p.s.prev == lexer.TokenPunctuatorSemicolon {
+		// The error was at the semicolon, which already ends the statement.
+		return
+	}
+	depth := 0
+	for {
+		switch p.s.PeekAt(0).Type {
+		case lexer.TokenNone:
+			return
+		case lexer.TokenPunctuatorOpenBrace:
+			depth++
+		case lexer.TokenPunctuatorCloseBrace:
+			if depth == 0 {
+				return
+			}
+			depth--
+			if depth == 0 {
+				p.s.Scan()
+				return
+			}
+		case lexer.TokenPunctuatorSemicolon:
+			if depth == 0 {
+				p.s.Scan()
+				return
+			}
+		}
+		p.s.Scan()
+	}
 }
 
-func (p *Parser) parseVariableStatement() ast.VariableDeclaration {
-	n := p.parseVariableStatementNoSemicolon()
-	p.expectSemicolon()
+// parseDirective marks stmt as a directive if it is a "use strict" directive
+// and enables strict mode in ctx.
+func (p *Parser) parseDirective(stmt ast.Node, ctx *parseContext) ast.Node {
+	if expr, ok := stmt.(ast.ExpressionStatement); ok {
+		if str, ok := expr.Expression.(ast.StringLiteral); ok {
+			if str.Value == "use strict" {
+				ctx.strictMode = true
+				expr.Directive = "use strict"
+			}
+		}
+		return expr
+	}
+	return stmt
+}
+
+func (p *Parser) parseVariableStatement() (ast.Node, error) {
+	n, err := p.parseVariableStatementNoSemicolon()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectSemicolon(); err != nil {
+		return nil, p.suggestStatementEnd(err, n.Declarations[len(n.Declarations)-1].Init)
+	}
 	p.setEnd(&n)
-	return n
+	return n, nil
 }
 
-func (p *Parser) parseVariableStatementNoSemicolon() ast.VariableDeclaration {
+func (p *Parser) parseVariableStatementNoSemicolon() (ast.VariableDeclaration, error) {
 	n := ast.VariableDeclaration{}
 	p.setStart(&n)
-	defer p.setEnd(&n)
 
-	p.s.ScanExpect(lexer.TokenKeywordVar, "expected variable declaration")
-	n.Declarations = p.parseVariableDeclarations()
-	return n
+	var err error
+	if _, err = p.s.ScanExpect(lexer.TokenKeywordVar, "expected variable declaration"); err != nil {
+		p.setEnd(&n)
+		return n, err
+	}
+	n.Declarations, err = p.parseVariableDeclarations()
+	p.setEnd(&n)
+	return n, err
 }
 
-func (p *Parser) parseVariableDeclarations() []ast.VariableDeclarator {
+func (p *Parser) parseVariableDeclarations() ([]ast.VariableDeclarator, error) {
 	v := []ast.VariableDeclarator{}
 	for {
-		v = append(v, p.parseVariableDeclaration())
+		d, err := p.parseVariableDeclaration()
+		if err != nil {
+			return nil, err
+		}
+		v = append(v, d)
 		if p.s.PeekAt(0).Type != lexer.TokenPunctuatorComma {
 			break
 		}
-		p.s.ScanExpect(lexer.TokenPunctuatorComma, "expected comma")
+		p.s.Scan()
 	}
-	return v
+	return v, nil
 }
 
-func (p *Parser) parseVariableDeclaration() ast.VariableDeclarator {
+func (p *Parser) parseVariableDeclaration() (ast.VariableDeclarator, error) {
 	v := ast.VariableDeclarator{}
 
+	var err error
 	t := p.ctx.keywordToIdentifier(p.s.PeekAt(0), false)
 	switch t.Type {
 	case lexer.TokenIdentifier:
-		v.ID.Identifier = p.scanIdent("expected variable identifier")
+		v.ID.Identifier, err = p.scanIdent("expected variable identifier")
 	case lexer.TokenPunctuatorOpenBracket:
-		v.ID.ArrayPattern = p.parseArrayBindingPattern()
+		v.ID.ArrayPattern, err = p.parseArrayBindingPattern()
 	case lexer.TokenPunctuatorOpenBrace:
-		v.ID.ObjectPattern = p.parseObjectBindingPattern()
+		v.ID.ObjectPattern, err = p.parseObjectBindingPattern()
 	default:
-		p.s.SyntaxError(fmt.Sprintf("unexpected token in variable declaration: %s", p.s.Scan().Source()))
+		err = p.s.SyntaxError(errs.CodeUnexpectedToken, fmt.Sprintf("unexpected token in variable declaration: %s", p.s.Scan().Source()))
+	}
+	if err != nil {
+		return v, err
 	}
 
 	if p.s.PeekAt(0).Type == lexer.TokenPunctuatorAssign {
-		p.s.ScanExpect(lexer.TokenPunctuatorAssign, "expected `=`")
-		v.Init = p.parseExpression(exprOrderAssign, 0)
+		p.s.Scan()
+		v.Init, err = p.parseExpression(exprOrderAssign, 0)
 	}
 
-	return v
+	return v, err
+}
+
+// parseBindingTarget parses the identifier or nested pattern that a binding
+// element binds to, given its first token.
+func (p *Parser) parseBindingTarget(t lexer.Token, b *ast.BindingPattern, context string) (err error) {
+	switch t.Type {
+	case lexer.TokenIdentifier:
+		b.Identifier = t.Literal
+	case lexer.TokenPunctuatorOpenBracket:
+		b.ArrayPattern, err = p.parseArrayBindingPatternTail()
+	case lexer.TokenPunctuatorOpenBrace:
+		b.ObjectPattern, err = p.parseObjectBindingPatternTail()
+	default:
+		err = p.s.SyntaxError(errs.CodeUnexpectedToken, fmt.Sprintf("unexpected token in %s: %s", context, t.Source()))
+	}
+	return err
 }
 
-func (p *Parser) parseArrayBindingPattern() *ast.ArrayBindingPattern {
-	p.s.ScanExpect(lexer.TokenPunctuatorOpenBracket, "expected array binding pattern")
+func (p *Parser) parseArrayBindingPattern() (*ast.ArrayBindingPattern, error) {
+	if _, err := p.s.ScanExpect(lexer.TokenPunctuatorOpenBracket, "expected array binding pattern"); err != nil {
+		return nil, err
+	}
 	return p.parseArrayBindingPatternTail()
 }
 
-func (p *Parser) parseArrayBindingPatternTail() *ast.ArrayBindingPattern {
+func (p *Parser) parseArrayBindingPatternTail() (*ast.ArrayBindingPattern, error) {
 	n := &ast.ArrayBindingPattern{}
 	for {
+		var err error
 		b := ast.BindingElement{}
 		t := p.ctx.keywordToIdentifier(p.s.Scan(), false)
 		switch t.Type {
@@ -228,37 +391,47 @@ func (p *Parser) parseArrayBindingPatternTail() *ast.ArrayBindingPattern {
 			continue
 
 		case lexer.TokenPunctuatorCloseBracket:
-			return n
+			return n, nil
 
 		case lexer.TokenPunctuatorOpenBracket:
-			b.Value.ArrayPattern = p.parseArrayBindingPatternTail()
+			b.Value.ArrayPattern, err = p.parseArrayBindingPatternTail()
 
 		case lexer.TokenPunctuatorOpenBrace:
-			b.Value.ObjectPattern = p.parseObjectBindingPatternTail()
+			b.Value.ObjectPattern, err = p.parseObjectBindingPatternTail()
 
 		case lexer.TokenPunctuatorEllipsis:
 			t := p.ctx.keywordToIdentifier(p.s.PeekAt(0), false)
 			switch t.Type {
 			case lexer.TokenIdentifier:
-				n.RestElement.Identifier = p.scanIdent("expected variable identifier")
+				n.RestElement.Identifier, err = p.scanIdent("expected variable identifier")
 			case lexer.TokenPunctuatorOpenBracket:
-				n.RestElement.ArrayPattern = p.parseArrayBindingPattern()
+				n.RestElement.ArrayPattern, err = p.parseArrayBindingPattern()
 			case lexer.TokenPunctuatorOpenBrace:
-				n.RestElement.ObjectPattern = p.parseObjectBindingPattern()
+				n.RestElement.ObjectPattern, err = p.parseObjectBindingPattern()
 			default:
-				p.s.SyntaxError(fmt.Sprintf("unexpected token in rest pattern: %s", p.s.Scan().Source()))
+				err = p.s.SyntaxError(errs.CodeInvalidDestructuring, fmt.Sprintf("unexpected token in rest pattern: %s", p.s.Scan().Source()))
+			}
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.s.ScanExpect(lexer.TokenPunctuatorCloseBracket, "expected closing braket"); err != nil {
+				return nil, err
 			}
-			p.s.ScanExpect(lexer.TokenPunctuatorCloseBracket, "expected closing braket")
-			return n
+			return n, nil
 
 		default:
-			p.s.SyntaxError(fmt.Sprintf("unexpected token in array binding pattern: %s", p.s.Scan().Source()))
+			err = p.s.SyntaxError(errs.CodeInvalidDestructuring, fmt.Sprintf("unexpected token in array binding pattern: %s", t.Source()))
+		}
+		if err != nil {
+			return nil, err
 		}
 
 		// Default syntax
 		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorAssign, "expected default assignment `=`")
-			b.Init = p.parseExpression(exprOrderAssign, 0)
+			p.s.Scan()
+			if b.Init, err = p.parseExpression(exprOrderAssign, 0); err != nil {
+				return nil, err
+			}
 		}
 
 		n.Elements = append(n.Elements, b)
@@ -269,63 +442,80 @@ func (p *Parser) parseArrayBindingPatternTail() *ast.ArrayBindingPattern {
 			continue
 
 		case lexer.TokenPunctuatorCloseBracket:
-			return n
+			return n, nil
 
 		default:
-			p.s.SyntaxError(fmt.Sprintf("expected `,` or `}`, but got: %s", t.Source()))
+			return nil, p.s.SyntaxError(errs.CodeInvalidDestructuring, fmt.Sprintf("expected `,` or `]`, but got: %s", t.Source()))
 		}
 	}
 }
 
-func (p *Parser) parseObjectBindingPattern() *ast.ObjectBindingPattern {
-	p.s.ScanExpect(lexer.TokenPunctuatorOpenBrace, "expected object binding pattern")
+func (p *Parser) parseObjectBindingPattern() (*ast.ObjectBindingPattern, error) {
+	if _, err := p.s.ScanExpect(lexer.TokenPunctuatorOpenBrace, "expected object binding pattern"); err != nil {
+		return nil, err
+	}
 	return p.parseObjectBindingPatternTail()
 }
 
-func (p *Parser) parseObjectBindingPatternTail() *ast.ObjectBindingPattern {
+func (p *Parser) parseObjectBindingPatternTail() (*ast.ObjectBindingPattern, error) {
 	n := &ast.ObjectBindingPattern{}
 	for {
+		var err error
 		b := ast.BindingProperty{}
 		t := p.ctx.keywordToIdentifier(p.s.Scan(), false)
 		switch t.Type {
 		case lexer.TokenIdentifier:
 			b.PropertyName = t.Literal
 
+		case lexer.TokenLiteralString:
+			b.Key = ast.StringLiteral{Value: t.StringConstant(), Raw: t.Literal}
+
+		case lexer.TokenLiteralNumber:
+			b.Key, err = p.numberLiteral(t)
+
+		case lexer.TokenPunctuatorOpenBracket:
+			if b.Key, err = p.parseExpression(exprOrderAssign, 0); err == nil {
+				b.Computed = true
+				_, err = p.s.ScanExpect(lexer.TokenPunctuatorCloseBracket, "expected `]`")
+			}
+
 		case lexer.TokenPunctuatorEllipsis:
-			n.RestElement = p.scanIdent("expected rest identifier")
-			p.s.ScanExpect(lexer.TokenPunctuatorCloseBrace, "expected closing brace")
-			return n
+			if n.RestElement, err = p.scanIdent("expected rest identifier"); err != nil {
+				return nil, err
+			}
+			if _, err = p.s.ScanExpect(lexer.TokenPunctuatorCloseBrace, "expected closing brace"); err != nil {
+				return nil, err
+			}
+			return n, nil
 
 		case lexer.TokenPunctuatorCloseBrace:
-			return n
+			return n, nil
 
 		default:
-			p.s.SyntaxError(fmt.Sprintf("expected property name, `...`, or `}`, but got: %s", t.Source()))
+			err = p.s.SyntaxError(errs.CodeInvalidDestructuring, fmt.Sprintf("expected property name, `...`, or `}`, but got: %s", t.Source()))
+		}
+		if err != nil {
+			return nil, err
 		}
 
-		// Binding syntax
+		// Binding syntax; only identifier keys may be used without it.
+		if b.Key != nil && p.s.PeekAt(0).Type != lexer.TokenPunctuatorColon {
+			return nil, p.s.SyntaxError(errs.CodeInvalidDestructuring, "expected binding `:`")
+		}
 		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorColon {
-			p.s.ScanExpect(lexer.TokenPunctuatorColon, "expected binding `:`")
+			p.s.Scan()
 			t = p.ctx.keywordToIdentifier(p.s.Scan(), false)
-			switch t.Type {
-			case lexer.TokenIdentifier:
-				b.Value.Identifier = t.Literal
-
-			case lexer.TokenPunctuatorOpenBracket:
-				b.Value.ArrayPattern = p.parseArrayBindingPatternTail()
-
-			case lexer.TokenPunctuatorOpenBrace:
-				b.Value.ObjectPattern = p.parseObjectBindingPatternTail()
-
-			default:
-				p.s.SyntaxError(fmt.Sprintf("unexpected token in object binding pattern: %s", p.s.Scan().Source()))
+			if err = p.parseBindingTarget(t, &b.Value, "object binding pattern"); err != nil {
+				return nil, err
 			}
 		}
 
 		// Default syntax
 		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorAssign {
-			p.s.ScanExpect(lexer.TokenPunctuatorAssign, "expected default assignment `=`")
-			b.Init = p.parseExpression(exprOrderAssign, 0)
+			p.s.Scan()
+			if b.Init, err = p.parseExpression(exprOrderAssign, 0); err != nil {
+				return nil, err
+			}
 		}
 
 		n.Properties = append(n.Properties, b)
@@ -336,304 +526,468 @@ func (p *Parser) parseObjectBindingPatternTail() *ast.ObjectBindingPattern {
 			continue
 
 		case lexer.TokenPunctuatorCloseBrace:
-			return n
+			return n, nil
 
 		default:
-			p.s.SyntaxError(fmt.Sprintf("expected `,` or `}`, but got: %s", t.Source()))
+			return nil, p.s.SyntaxError(errs.CodeInvalidDestructuring, fmt.Sprintf("expected `,` or `}`, but got: %s", t.Source()))
 		}
 	}
 }
 
-func (p *Parser) parseEmptyExpression() ast.Node {
+func (p *Parser) parseEmptyExpression() (ast.Node, error) {
 	n := ast.EmptyStatement{}
 	p.setStart(&n)
-	defer p.setEnd(&n)
 
-	p.expectSemicolon()
-	return n
+	if err := p.expectSemicolon(); err != nil {
+		return nil, err
+	}
+	p.setEnd(&n)
+	return n, nil
 }
 
-func (p *Parser) parseIfStatement() ast.Node {
+func (p *Parser) parseIfStatement() (ast.Node, error) {
 	n := ast.IfStatement{}
 	p.setStart(&n)
-	defer p.setEnd(&n)
 
-	p.s.ScanExpect(lexer.TokenKeywordIf, "expected `if` statement")
-	p.s.ScanExpect(lexer.TokenPunctuatorOpenParen, "expected `(` after `if`")
-	n.Test = p.parseExpression(exprOrderComma, 0)
-	p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)`")
-	n.Consequent = p.parseStatement()
+	var err error
+	if _, err = p.s.ScanExpect(lexer.TokenKeywordIf, "expected `if` statement"); err != nil {
+		return nil, err
+	}
+	if _, err = p.s.ScanExpect(lexer.TokenPunctuatorOpenParen, "expected `(` after `if`"); err != nil {
+		return nil, err
+	}
+	if n.Test, err = p.parseCondition(); err != nil {
+		return nil, err
+	}
+	if _, err = p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)`"); err != nil {
+		return nil, err
+	}
+	if n.Consequent, err = p.parseSubStatement(); err != nil {
+		return nil, err
+	}
 	if p.s.PeekAt(0).Type == lexer.TokenKeywordElse {
-		p.s.ScanExpect(lexer.TokenKeywordElse, "expected `else`")
-		n.Alternate = p.parseStatement()
+		p.s.Scan()
+		if n.Alternate, err = p.parseSubStatement(); err != nil {
+			return nil, err
+		}
 	}
-	return n
+	p.setEnd(&n)
+	return n, nil
+}
+
+// parseCondition parses the test of an if, while or do-while statement.
+func (p *Parser) parseCondition() (ast.Node, error) {
+	cond := p.cond
+	p.cond = p.s.Location()
+	n, err := p.parseExpression(exprOrderComma, 0)
+	p.cond = cond
+	return n, err
 }
 
-func (p *Parser) parseDoWhileStatement() ast.Node {
+func (p *Parser) parseDoWhileStatement() (ast.Node, error) {
 	n := ast.DoWhileStatement{}
 	p.setStart(&n)
-	defer p.setEnd(&n)
 
-	p.s.ScanExpect(lexer.TokenKeywordDo, "expected `do` statement")
-	n.Body = p.parseStatement()
-	p.s.ScanExpect(lexer.TokenKeywordWhile, "expected `while` in do/while statement")
-	p.s.ScanExpect(lexer.TokenPunctuatorOpenParen, "expected `(` in `while` of do/while statement")
-	n.Test = p.parseExpression(exprOrderComma, 0)
-	p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)` in `while` of do/while statement")
-	p.expectSemicolon()
-	return n
+	var err error
+	if _, err = p.s.ScanExpect(lexer.TokenKeywordDo, "expected `do` statement"); err != nil {
+		return nil, err
+	}
+	if n.Body, err = p.parseSubStatement(); err != nil {
+		return nil, err
+	}
+	if _, err = p.s.ScanExpect(lexer.TokenKeywordWhile, "expected `while` in do/while statement"); err != nil {
+		return nil, err
+	}
+	if _, err = p.s.ScanExpect(lexer.TokenPunctuatorOpenParen, "expected `(` in `while` of do/while statement"); err != nil {
+		return nil, err
+	}
+	if n.Test, err = p.parseCondition(); err != nil {
+		return nil, err
+	}
+	if _, err = p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)` in `while` of do/while statement"); err != nil {
+		return nil, err
+	}
+	if err = p.expectSemicolon(); err != nil {
+		return nil, err
+	}
+	p.setEnd(&n)
+	return n, nil
 }
 
-func (p *Parser) parseWhileStatement() ast.Node {
+func (p *Parser) parseWhileStatement() (ast.Node, error) {
 	n := ast.WhileStatement{}
 	p.setStart(&n)
-	defer p.setEnd(&n)
 
-	p.s.ScanExpect(lexer.TokenKeywordWhile, "expected `while` statement")
-	p.s.ScanExpect(lexer.TokenPunctuatorOpenParen, "expected `(` in `while` of do/while statement")
-	n.Test = p.parseExpression(exprOrderComma, 0)
-	p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)` in `while` of do/while statement")
-	n.Body = p.parseStatement()
-	return n
+	var err error
+	if _, err = p.s.ScanExpect(lexer.TokenKeywordWhile, "expected `while` statement"); err != nil {
+		return nil, err
+	}
+	if _, err = p.s.ScanExpect(lexer.TokenPunctuatorOpenParen, "expected `(` in `while` of do/while statement"); err != nil {
+		return nil, err
+	}
+	if n.Test, err = p.parseCondition(); err != nil {
+		return nil, err
+	}
+	if _, err = p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)` in `while` of do/while statement"); err != nil {
+		return nil, err
+	}
+	if n.Body, err = p.parseSubStatement(); err != nil {
+		return nil, err
+	}
+	p.setEnd(&n)
+	return n, nil
 }
 
-func (p *Parser) parseForStatement() ast.Node {
+func (p *Parser) parseForStatement() (ast.Node, error) {
 	n := ast.ForStatement{}
 	p.setStart(&n)
-	defer p.setEnd(&n)
 
-	p.s.ScanExpect(lexer.TokenKeywordFor, "expected `for` statement")
+	var err error
+	if _, err = p.s.ScanExpect(lexer.TokenKeywordFor, "expected `for` statement"); err != nil {
+		return nil, err
+	}
 	// TODO: async
-	p.s.ScanExpect(lexer.TokenPunctuatorOpenParen, "expected `(`")
+	if _, err = p.s.ScanExpect(lexer.TokenPunctuatorOpenParen, "expected `(`"); err != nil {
+		return nil, err
+	}
 
 	t := p.s.PeekAt(0)
-	// TODO: let, const, more of/in cases, etc.
+	// TODO: more of/in cases, etc.
 	if t.Type == lexer.TokenPunctuatorSemicolon {
 		n.Init = nil
-		p.expectSemicolon()
+		if err = p.expectSemicolon(); err != nil {
+			return nil, err
+		}
 	} else {
 		var v ast.Node
-		if t.Type == lexer.TokenKeywordVar {
-			v = p.parseVariableStatementNoSemicolon()
-		} else {
-			v = p.parseExpression(exprOrderComma, exprFlagDisallowIn)
+		switch t.Type {
+		case lexer.TokenKeywordVar:
+			v, err = p.parseVariableStatementNoSemicolon()
+		case lexer.TokenKeywordLet, lexer.TokenKeywordConst:
+			v, err = p.parseLexicalDeclarationNoSemicolon()
+		default:
+			v, err = p.parseExpression(exprOrderComma, exprFlagDisallowIn)
+		}
+		if err != nil {
+			return nil, err
 		}
 		// for in/of
 		switch p.s.PeekAt(0).Type {
 		case lexer.TokenKeywordIn:
-			p.s.ScanExpect(lexer.TokenKeywordIn, "expected `in`")
-			m := ast.ForInStatement{
-				Left:  v,
-				Right: p.parseExpression(exprOrderComma, 0),
+			p.s.Scan()
+			m := ast.ForInStatement{Left: v}
+			if m.Right, err = p.parseExpression(exprOrderComma, 0); err != nil {
+				return nil, err
 			}
 			m.SetStart(n.Span().Start)
-			p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)`")
-			m.Body = p.parseStatement()
+			if _, err = p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)`"); err != nil {
+				return nil, err
+			}
+			if m.Body, err = p.parseSubStatement(); err != nil {
+				return nil, err
+			}
 			p.setEnd(&m)
-			return m
+			return m, nil
 
 		case lexer.TokenKeywordOf:
-			p.s.ScanExpect(lexer.TokenKeywordOf, "expected `of`")
-			m := ast.ForOfStatement{
-				Left:  v,
-				Right: p.parseExpression(exprOrderComma, 0),
+			p.s.Scan()
+			m := ast.ForOfStatement{Left: v}
+			if m.Right, err = p.parseExpression(exprOrderComma, 0); err != nil {
+				return nil, err
 			}
 			m.SetStart(n.Span().Start)
-			p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)`")
-			m.Body = p.parseStatement()
+			if _, err = p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)`"); err != nil {
+				return nil, err
+			}
+			if m.Body, err = p.parseSubStatement(); err != nil {
+				return nil, err
+			}
 			p.setEnd(&m)
-			return m
+			return m, nil
 		}
 		n.Init = v
-		p.expectSemicolon()
+		if err = p.expectSemicolon(); err != nil {
+			return nil, err
+		}
 	}
 	if p.s.PeekAt(0).Type != lexer.TokenPunctuatorSemicolon {
-		n.Test = p.parseExpression(exprOrderComma, 0)
+		if n.Test, err = p.parseExpression(exprOrderComma, 0); err != nil {
+			return nil, err
+		}
+	}
+	if err = p.expectSemicolon(); err != nil {
+		return nil, err
 	}
-	p.expectSemicolon()
 	if p.s.PeekAt(0).Type != lexer.TokenPunctuatorCloseParen {
-		n.Update = p.parseExpression(exprOrderComma, 0)
+		if n.Update, err = p.parseExpression(exprOrderComma, 0); err != nil {
+			return nil, err
+		}
+	}
+	if _, err = p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)`"); err != nil {
+		return nil, err
+	}
+	if n.Body, err = p.parseSubStatement(); err != nil {
+		return nil, err
 	}
-	p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)`")
-	n.Body = p.parseStatement()
-	return n
+	p.setEnd(&n)
+	return n, nil
 }
 
-func (p *Parser) parseSwitchStatement() ast.Node {
+func (p *Parser) parseSwitchStatement() (ast.Node, error) {
 	n := ast.SwitchStatement{}
 	p.setStart(&n)
-	defer p.setEnd(&n)
 
-	p.s.ScanExpect(lexer.TokenKeywordSwitch, "expected `switch` statement")
-	p.s.ScanExpect(lexer.TokenPunctuatorOpenParen, "expected `(`")
-	n.Discriminant = p.parseExpression(exprOrderComma, 0)
-	p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)`")
+	var err error
+	if _, err = p.s.ScanExpect(lexer.TokenKeywordSwitch, "expected `switch` statement"); err != nil {
+		return nil, err
+	}
+	if _, err = p.s.ScanExpect(lexer.TokenPunctuatorOpenParen, "expected `(`"); err != nil {
+		return nil, err
+	}
+	if n.Discriminant, err = p.parseExpression(exprOrderComma, 0); err != nil {
+		return nil, err
+	}
+	if _, err = p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)`"); err != nil {
+		return nil, err
+	}
 
-	p.s.ScanExpect(lexer.TokenPunctuatorOpenBrace, "expected `{`")
+	if _, err = p.s.ScanExpect(lexer.TokenPunctuatorOpenBrace, "expected `{`"); err != nil {
+		return nil, err
+	}
 	for {
+		c := ast.SwitchCase{}
 		switch p.s.PeekAt(0).Type {
 		case lexer.TokenKeywordCase:
-			p.s.ScanExpect(lexer.TokenKeywordCase, "expected `case`")
-			c := ast.SwitchCase{
-				Test: p.parseExpression(exprOrderComma, 0),
+			p.s.Scan()
+			if c.Test, err = p.parseExpression(exprOrderComma, 0); err != nil {
+				return nil, err
 			}
-			p.s.ScanExpect(lexer.TokenPunctuatorColon, "expected `:`")
-		caseStatements:
-			for {
-				switch p.s.PeekAt(0).Type {
-				case lexer.TokenKeywordCase, lexer.TokenKeywordDefault, lexer.TokenPunctuatorCloseBrace:
-					break caseStatements
-				default:
-					c.Consequent = append(c.Consequent, p.parseStatement())
-				}
-			}
-			n.Cases = append(n.Cases, c)
 
 		case lexer.TokenKeywordDefault:
-			c := ast.SwitchCase{}
-			p.s.ScanExpect(lexer.TokenKeywordDefault, "expected `default`")
-			p.s.ScanExpect(lexer.TokenPunctuatorColon, "expected `:`")
-		defaultStatements:
-			for {
-				switch p.s.PeekAt(0).Type {
-				case lexer.TokenKeywordCase, lexer.TokenKeywordDefault, lexer.TokenPunctuatorCloseBrace:
-					break defaultStatements
-				default:
-					c.Consequent = append(c.Consequent, p.parseStatement())
-				}
-			}
-			n.Cases = append(n.Cases, c)
+			p.s.Scan()
 
 		case lexer.TokenPunctuatorCloseBrace:
-			p.s.ScanExpect(lexer.TokenPunctuatorCloseBrace, "expected `}`")
-			return n
+			p.s.Scan()
+			p.setEnd(&n)
+			return n, nil
+
+		default:
+			return nil, p.s.SyntaxError(errs.CodeUnexpectedToken, fmt.Sprintf("expected `case`, `default` or `}`, got %s", p.s.Scan().Source()))
+		}
+		if _, err = p.s.ScanExpect(lexer.TokenPunctuatorColon, "expected `:`"); err != nil {
+			return nil, err
 		}
+	statements:
+		for {
+			switch p.s.PeekAt(0).Type {
+			case lexer.TokenKeywordCase, lexer.TokenKeywordDefault, lexer.TokenPunctuatorCloseBrace, lexer.TokenNone:
+				break statements
+			default:
+				stmt, err := p.parseListItem(p.parseStatementItem)
+				if err != nil {
+					return nil, err
+				}
+				if stmt != nil {
+					c.Consequent = append(c.Consequent, stmt)
+				}
+			}
+		}
+		n.Cases = append(n.Cases, c)
 	}
 }
 
-func (p *Parser) parseContinueStatement() ast.Node {
-	n := ast.ContinueStatement{}
-	p.setStart(&n)
-	defer p.setEnd(&n)
-
-	p.s.ScanExpect(lexer.TokenKeywordContinue, "expected continue statement")
+// parseJumpLabel parses the optional label of a break or continue statement.
+func (p *Parser) parseJumpLabel() (string, error) {
 	t := p.ctx.keywordToIdentifier(p.s.PeekAt(0), false)
 	if t.NewLine || t.Type != lexer.TokenIdentifier {
-		p.expectSemicolon()
-		return n
+		return "", p.expectSemicolon()
 	}
-	n.Label = p.scanIdent("expected identifier")
+	label, err := p.scanIdent("expected identifier")
+	if err != nil {
+		return "", err
+	}
+	return label, p.expectSemicolon()
+}
+
+func (p *Parser) parseContinueStatement() (ast.Node, error) {
+	n := ast.ContinueStatement{}
+	p.setStart(&n)
 
-	p.expectSemicolon()
-	return n
+	var err error
+	if _, err = p.s.ScanExpect(lexer.TokenKeywordContinue, "expected continue statement"); err != nil {
+		return nil, err
+	}
+	if n.Label, err = p.parseJumpLabel(); err != nil {
+		return nil, err
+	}
+	p.setEnd(&n)
+	return n, nil
 }
 
-func (p *Parser) parseBreakStatement() ast.Node {
+func (p *Parser) parseBreakStatement() (ast.Node, error) {
 	n := ast.BreakStatement{}
 	p.setStart(&n)
-	defer p.setEnd(&n)
 
-	p.s.ScanExpect(lexer.TokenKeywordBreak, "expected break statement")
-	t := p.ctx.keywordToIdentifier(p.s.PeekAt(0), false)
-	if t.NewLine || t.Type != lexer.TokenIdentifier {
-		p.expectSemicolon()
-		return n
+	var err error
+	if _, err = p.s.ScanExpect(lexer.TokenKeywordBreak, "expected break statement"); err != nil {
+		return nil, err
 	}
-	n.Label = p.scanIdent("expected identifier")
-
-	p.expectSemicolon()
-	return n
+	if n.Label, err = p.parseJumpLabel(); err != nil {
+		return nil, err
+	}
+	p.setEnd(&n)
+	return n, nil
 }
 
-func (p *Parser) parseReturnStatement() ast.Node {
+func (p *Parser) parseReturnStatement() (ast.Node, error) {
 	n := ast.ReturnStatement{}
 	p.setStart(&n)
-	defer p.setEnd(&n)
 
-	p.s.ScanExpect(lexer.TokenKeywordReturn, "expected return statement")
+	var err error
+	if _, err = p.s.ScanExpect(lexer.TokenKeywordReturn, "expected return statement"); err != nil {
+		return nil, err
+	}
+	if !p.ctx.function {
+		return nil, p.s.SyntaxError(errs.CodeUnexpectedToken, "`return` is only allowed inside of a function")
+	}
 	t := p.s.PeekAt(0)
-	if t.NewLine || t.Type == lexer.TokenPunctuatorSemicolon || t.Type == lexer.TokenPunctuatorCloseBrace {
-		p.expectSemicolon()
-		return n
+	if t.NewLine || t.Type == lexer.TokenPunctuatorSemicolon || t.Type == lexer.TokenPunctuatorCloseBrace || t.Type == lexer.TokenNone {
+		if t.NewLine && startsExpression(t) {
+			p.warn(p.s.spans[0], errs.CodeReturnNewline, "expression after `return` is on the next line, so it is not returned",
+				errs.Label{Span: p.s.prevSpan, Message: "a semicolon is inserted after this `return`"})
+		}
+		if err = p.expectSemicolon(); err != nil {
+			return nil, err
+		}
+		p.setEnd(&n)
+		return n, nil
 	}
 
-	n.Argument = p.parseExpression(exprOrderComma, 0)
-	p.expectSemicolon()
-	return n
+	if n.Argument, err = p.parseExpression(exprOrderComma, 0); err != nil {
+		return nil, err
+	}
+	if err = p.expectSemicolon(); err != nil {
+		return nil, err
+	}
+	p.setEnd(&n)
+	return n, nil
 }
 
-func (p *Parser) parseWithStatement() ast.Node {
-	panic("unimplemented")
+func (p *Parser) parseWithStatement() (ast.Node, error) {
+	n := ast.WithStatement{}
+	p.setStart(&n)
+
+	var err error
+	if _, err = p.s.ScanExpect(lexer.TokenKeywordWith, "expected `with` statement"); err != nil {
+		return nil, err
+	}
+	if p.ctx.strictMode {
+		return nil, p.s.SyntaxErrorAt(p.s.prevSpan, errs.CodeStrictMode, "`with` statement is not allowed in strict mode")
+	}
+	p.warn(p.s.prevSpan, errs.CodeWithStatement, "`with` statement makes names in its body ambiguous")
+	if _, err = p.s.ScanExpect(lexer.TokenPunctuatorOpenParen, "expected `(` in `with` statement"); err != nil {
+		return nil, err
+	}
+	if n.Object, err = p.parseExpression(exprOrderComma, 0); err != nil {
+		return nil, err
+	}
+	if _, err = p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)` in `with` statement"); err != nil {
+		return nil, err
+	}
+	if n.Body, err = p.parseSubStatement(); err != nil {
+		return nil, err
+	}
+	p.setEnd(&n)
+	return n, nil
 }
 
-func (p *Parser) parseThrowStatement() ast.Node {
+func (p *Parser) parseThrowStatement() (ast.Node, error) {
 	n := ast.ThrowStatement{}
 	p.setStart(&n)
-	defer p.setEnd(&n)
 
-	p.s.ScanExpect(lexer.TokenKeywordThrow, "expected throw statement")
+	var err error
+	if _, err = p.s.ScanExpect(lexer.TokenKeywordThrow, "expected throw statement"); err != nil {
+		return nil, err
+	}
 	if p.s.PeekAt(0).NewLine {
-		p.s.SyntaxError("illegal newline after throw")
+		return nil, p.s.SyntaxError(errs.CodeIllegalNewline, "illegal newline after throw")
 	}
 
-	n.Argument = p.parseExpression(exprOrderComma, 0)
-	p.expectSemicolon()
-	return n
+	if n.Argument, err = p.parseExpression(exprOrderComma, 0); err != nil {
+		return nil, err
+	}
+	if err = p.expectSemicolon(); err != nil {
+		return nil, err
+	}
+	p.setEnd(&n)
+	return n, nil
 }
 
-func (p *Parser) parseTryStatement() ast.Node {
+func (p *Parser) parseTryStatement() (ast.Node, error) {
 	n := ast.TryStatement{}
 	p.setStart(&n)
-	defer p.setEnd(&n)
 
-	p.s.ScanExpect(lexer.TokenKeywordTry, "expected try statement")
-	n.Block = p.parseBlock()
+	var err error
+	if _, err = p.s.ScanExpect(lexer.TokenKeywordTry, "expected try statement"); err != nil {
+		return nil, err
+	}
+	if n.Block, err = p.parseBlock(); err != nil {
+		return nil, err
+	}
 	if p.s.PeekAt(0).Type == lexer.TokenKeywordCatch {
-		p.s.ScanExpect(lexer.TokenKeywordCatch, "expected catch statement")
+		p.s.Scan()
 		h := ast.CatchClause{}
-		h.SetStart(p.s.Location())
+		h.SetStart(p.s.prevSpan.Start)
 		if p.s.PeekAt(0).Type == lexer.TokenPunctuatorOpenParen {
-			p.s.ScanExpect(lexer.TokenPunctuatorOpenParen, "expected `(`")
-			h.Param = p.parseCatchParameter()
-			p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)`")
+			p.s.Scan()
+			if h.Param, err = p.parseCatchParameter(); err != nil {
+				return nil, err
+			}
+			if _, err = p.s.ScanExpect(lexer.TokenPunctuatorCloseParen, "expected `)`"); err != nil {
+				return nil, err
+			}
+		}
+		if h.Body, err = p.parseBlock(); err != nil {
+			return nil, err
 		}
 		h.SetEnd(p.s.Location())
-		h.Body = p.parseBlock()
 		n.Handler = h
 	}
 	if p.s.PeekAt(0).Type == lexer.TokenKeywordFinally {
-		p.s.ScanExpect(lexer.TokenKeywordFinally, "expected finally statement")
-		n.Finalizer = p.parseBlock()
+		p.s.Scan()
+		if n.Finalizer, err = p.parseBlock(); err != nil {
+			return nil, err
+		}
 	}
-	return n
+	p.setEnd(&n)
+	return n, nil
 }
 
-func (p *Parser) parseCatchParameter() ast.BindingPattern {
+func (p *Parser) parseCatchParameter() (ast.BindingPattern, error) {
 	b := ast.BindingPattern{}
 	t := p.ctx.keywordToIdentifier(p.s.Scan(), false)
-	switch t.Type {
-	case lexer.TokenIdentifier:
-		b.Identifier = t.Literal
-	case lexer.TokenPunctuatorOpenBracket:
-		b.ArrayPattern = p.parseArrayBindingPatternTail()
-	case lexer.TokenPunctuatorOpenBrace:
-		b.ObjectPattern = p.parseObjectBindingPatternTail()
-	}
-	return b
+	err := p.parseBindingTarget(t, &b, "catch parameter")
+	return b, err
 }
 
-func (p *Parser) parseDebuggerStatement() ast.Node {
-	panic("unimplemented")
+func (p *Parser) parseDebuggerStatement() (ast.Node, error) {
+	return nil, p.unsupported("`debugger` statement")
 }
 
-func (p *Parser) parseLabelledStatement() ast.Node {
+func (p *Parser) parseLabelledStatement() (ast.Node, error) {
 	n := ast.LabeledStatement{}
 	p.setStart(&n)
-	defer p.setEnd(&n)
 
-	n.Label = p.scanIdent("expected statement label")
-	p.s.ScanExpect(lexer.TokenPunctuatorColon, "expected `:` after statement label")
-	n.Body = p.parseStatement()
-	return n
+	var err error
+	if n.Label, err = p.scanIdent("expected statement label"); err != nil {
+		return nil, err
+	}
+	if _, err = p.s.ScanExpect(lexer.TokenPunctuatorColon, "expected `:` after statement label"); err != nil {
+		return nil, err
+	}
+	if n.Body, err = p.parseSubStatement(); err != nil {
+		return nil, err
+	}
+	p.setEnd(&n)
+	return n, nil
 }
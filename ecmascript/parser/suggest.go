@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+)
+
+// suggest attaches a suggested fix to err. Errors from the lexer are left
+// alone, since whatever the parser was doing is not their cause.
+func (p *Parser) suggest(err error, s errs.Suggestion) error {
+	var serr *errs.SyntaxError
+	if p.s.Err() == nil && errors.As(err, &serr) {
+		serr.Suggestions = append(serr.Suggestions, s)
+	}
+	return err
+}
+
+// suggestStatementEnd adds suggestions to err, an error for a statement that
+// goes on past expr, for the mistakes that commonly cause this.
+func (p *Parser) suggestStatementEnd(err error, expr ast.Node) error {
+	switch n := expr.(type) {
+	case ast.Identifier:
+		// A misspelled keyword reads as an identifier.
+		if kw := similarKeyword(n.Name); kw != "" {
+			return p.suggest(err, errs.Suggestion{
+				Message:     fmt.Sprintf("did you mean `%s`?", kw),
+				Span:        n.Span(),
+				Replacement: kw,
+			})
+		}
+
+	case ast.ParenthesizedExpression:
+		// A parameter list followed by a body is an arrow function without
+		// its arrow.
+		if p.s.prev != lexer.TokenPunctuatorOpenBrace {
+			break
+		}
+		if _, cerr := p.convertExprToArrowParams(n.Expression); cerr == nil {
+			return p.suggest(err, arrowSuggestion(n.Span().End))
+		}
+	}
+	return err
+}
+
+// arrowSuggestion suggests inserting a missing `=>` at the given location.
+func arrowSuggestion(at ast.Location) errs.Suggestion {
+	return errs.Suggestion{
+		Message:     "add `=>` after the parameter list to make an arrow function",
+		Span:        at.Span(),
+		Replacement: " =>",
+	}
+}
+
+// suggestComma adds a suggestion to err, an error for a token following an
+// item of a list, to insert a comma at the end of the item if the token could
+// begin another.
+func (p *Parser) suggestComma(err error, end ast.Location, next lexer.Token, what string) error {
+	switch next.Type {
+	case lexer.TokenIdentifier, lexer.TokenPrivateIdentifier,
+		lexer.TokenLiteralString, lexer.TokenLiteralNumber,
+		lexer.TokenPunctuatorOpenBracket, lexer.TokenPunctuatorEllipsis:
+	default:
+		if _, ok := reservedWords[next.Type]; !ok {
+			return err
+		}
+	}
+	return p.suggest(err, errs.Suggestion{
+		Message:     fmt.Sprintf("add `,` to separate %s", what),
+		Span:        end.Span(),
+		Replacement: ",",
+	})
+}
+
+// statementKeywords are the keywords suggested in place of a misspelling.
+var statementKeywords = []string{
+	"async", "await", "break", "case", "catch", "class", "const", "continue",
+	"default", "delete", "else", "export", "finally", "function", "import",
+	"let", "return", "static", "switch", "throw", "typeof", "var", "while",
+	"yield",
+}
+
+// similarKeyword returns the keyword that name is most likely a misspelling
+// of, or "" if there is none.
+func similarKeyword(name string) string {
+	best, bestDist := "", 0
+	for _, kw := range statementKeywords {
+		// Allow one edit for short keywords, and two for longer ones.
+		limit := 1
+		if len(kw) > 5 {
+			limit = 2
+		}
+		d := editDistance(name, kw)
+		if d == 0 || d > limit {
+			continue
+		}
+		if best == "" || d < bestDist {
+			best, bestDist = kw, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the number of single character insertions, deletions,
+// substitutions and adjacent transpositions needed to turn a into b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = minInt(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
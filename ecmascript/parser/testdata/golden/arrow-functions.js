@@ -0,0 +1,8 @@
+const id = x => x;
+const add = (a, b) => a + b;
+const defaults = (a = 1, { b, c = 2 }, [d, ...e], ...rest) => rest;
+const object = () => ({ a: 1 });
+const block = async () => {
+  return id(1);
+};
+const nested = a => b => c => a(b(c));
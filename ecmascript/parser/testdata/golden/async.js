@@ -0,0 +1,16 @@
+async function a(b) {
+  await b;
+  const c = await d() + await e.f;
+  return await await g;
+}
+async function* h() { yield await i; }
+var j = async function () { await k; };
+var l = async m => await m;
+var n = async (o, p) => { await o; return await p; };
+var q = { async r() { await s; } };
+class T { async u() { await v; } static async *[w]() {} async() {} }
+async function x() {
+  function y() { var await = 1; return await; }
+}
+async
+function z() {}
@@ -0,0 +1,25 @@
+outer: for (const key in object) {
+  for (const value of object[key]) {
+    if (!value) continue outer;
+    else if (value > 1) break outer;
+  }
+}
+
+switch (kind) {
+  case "a":
+  case "b":
+    handle(kind);
+    break;
+  default:
+    throw new Error(kind);
+}
+
+try {
+  run();
+} catch ({ message }) {
+  report(message);
+} finally {
+  done = true;
+}
+
+do x--; while (x > 0)
@@ -0,0 +1,10 @@
+import def, { a, b as c } from "./a.js";
+import * as ns from "./b.js";
+import { "a-b" as ab } from "./b.js";
+export { a, c as d };
+export * from "./c.js";
+export { ab as "a-b", "x" as "y" } from "./c.js";
+export * as "n-s" from "./c.js";
+export default function () {}
+export const e = 1;
+export class F {}
@@ -0,0 +1,14 @@
+function* a() {
+  yield;
+  yield 1, 2;
+  yield* b();
+  const c = yield d ? e : f;
+  yield
+  g;
+}
+var h = function* () { yield yield i; };
+var j = { *k() { yield `${yield}`; } };
+class L { static *[Symbol.iterator]() { yield* this.m; } }
+function* n() {
+  function o() { var yield = 1; return yield; }
+}
@@ -0,0 +1,13 @@
+const o = {
+  a,
+  b: 1,
+  "c": 2,
+  3: 3,
+  [d]: 4,
+  ...rest,
+  get e() { return 5; },
+  set e(v) {},
+  f() {},
+  async g() {},
+};
+const { a: x, b = 1, ...others } = o;
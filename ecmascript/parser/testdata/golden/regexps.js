@@ -0,0 +1,6 @@
+const a = /ab+c/gi;
+const b = x / y / z;
+const c = [/[/]/, /\//u];
+if (/^\d+$/.test(s)) {
+  s = s.replace(/(\w)\1/g, "$1");
+}
@@ -0,0 +1,9 @@
+a = `plain`;
+b = `one ${x} two ${y + 1}`;
+c = `nested ${`inner ${z}`} ${{ key: 1 }.key}`;
+d = `multi
+line\nA\
+`;
+e = tag`raw \u{zz} ${x}`;
+f = a.b`member``chained`;
+g = `${() => { return x; }}${/}/.source}`;
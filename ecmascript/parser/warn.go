@@ -0,0 +1,69 @@
+package parser
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+)
+
+// Warnings returns the warnings for the input parsed so far, including those
+// from the lexer, in source order. Warnings do not affect the result of the
+// parse.
+func (p *Parser) Warnings() []errs.Diagnostic {
+	ws := append([]errs.Diagnostic(nil), p.s.l.Warnings()...)
+	ws = append(ws, p.warnings...)
+	sort.SliceStable(ws, func(i, j int) bool {
+		a, b := ws[i].Span.Start, ws[j].Span.Start
+		return a.Row < b.Row || a.Row == b.Row && a.Column < b.Column
+	})
+	return ws
+}
+
+// warn records a warning spanning span.
+func (p *Parser) warn(span ast.Span, code errs.Code, msg string, labels ...errs.Label) {
+	p.warnings = append(p.warnings, errs.Diagnostic{
+		Code:     code,
+		Severity: errs.SeverityWarning,
+		Span:     span,
+		Labels:   labels,
+		Message:  msg,
+	})
+}
+
+// startsExpression returns whether t can only begin an expression statement,
+// so that a line beginning with it is unlikely to be meant as a statement of
+// its own.
+func startsExpression(t lexer.Token) bool {
+	switch t.Type {
+	case lexer.TokenIdentifier,
+		lexer.TokenLiteralNumber, lexer.TokenLiteralString, lexer.TokenLiteralTemplate,
+		lexer.TokenKeywordThis, lexer.TokenKeywordNull, lexer.TokenKeywordTrue, lexer.TokenKeywordFalse,
+		lexer.TokenKeywordNew, lexer.TokenKeywordTypeOf, lexer.TokenKeywordVoid, lexer.TokenKeywordDelete,
+		lexer.TokenPunctuatorOpenBrace, lexer.TokenPunctuatorOpenParen, lexer.TokenPunctuatorOpenBracket,
+		lexer.TokenPunctuatorNot, lexer.TokenPunctuatorBitNot, lexer.TokenPunctuatorPlus,
+		lexer.TokenPunctuatorMinus, lexer.TokenPunctuatorIncrement, lexer.TokenPunctuatorDecrement:
+		return true
+	}
+	return false
+}
+
+// propertyKeyName returns the name a non-computed property key defines.
+func propertyKeyName(key ast.Node) (string, bool) {
+	switch k := key.(type) {
+	case ast.Identifier:
+		return k.Name, true
+	case ast.StringLiteral:
+		return k.Value, true
+	case ast.NumberLiteral:
+		// Close enough to how numbers become property names to catch
+		// `{1: a, 1.0: b}`.
+		if k.Value > -1e21 && k.Value < 1e21 {
+			return strconv.FormatFloat(k.Value, 'f', -1, 64), true
+		}
+		return strconv.FormatFloat(k.Value, 'g', -1, 64), true
+	}
+	return "", false
+}
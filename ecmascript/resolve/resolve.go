@@ -0,0 +1,466 @@
+// Package resolve finds the files that import specifiers refer to, following
+// the Node.js module resolution algorithm.
+package resolve
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Error is returned when a specifier can not be resolved.
+type Error struct {
+	Specifier string
+
+	// From is the file the specifier was imported from.
+	From string
+
+	// Reason describes why resolution failed.
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("cannot resolve %q from %s: %s", e.Specifier, e.From, e.Reason)
+}
+
+// ErrIgnored is returned when the package.json `browser` field replaces a
+// module with nothing, so that importing it has no effect.
+var ErrIgnored = errors.New("module is ignored by the browser field")
+
+// Resolver resolves import specifiers to files.
+type Resolver struct {
+	// Extensions are tried in order when a specifier does not name a file
+	// exactly, and for the index file of a directory.
+	Extensions []string
+
+	// MainFields are the package.json fields that name the entry point of a
+	// package without an `exports` field, in order of preference.
+	MainFields []string
+
+	// Conditions are the conditions matched in package.json `exports`, in
+	// addition to `default`.
+	Conditions []string
+
+	// Browser resolves for browsers, following the package.json `browser`
+	// field: a string replaces the entry point of the package, and an
+	// object replaces files in the package, or packages imported from it,
+	// with others or with nothing. It also matches the `browser` condition.
+	Browser bool
+
+	// Aliases replace specifiers before they are resolved. A key matches a
+	// specifier that is the same or that continues with a /, which is kept
+	// after the target, and the longest match wins. Targets are package
+	// specifiers or absolute paths.
+	Aliases map[string]string
+
+	// TSConfig, if set, maps bare specifiers to files as TypeScript does,
+	// before they are looked for in node_modules directories.
+	TSConfig *TSConfig
+}
+
+// NewResolver creates a resolver for ES modules, which prefers the `module`
+// field of packages and matches the `import` condition.
+func NewResolver() *Resolver {
+	return &Resolver{
+		Extensions: []string{".js", ".mjs", ".cjs", ".json"},
+		MainFields: []string{"module", "main"},
+		Conditions: []string{"import"},
+	}
+}
+
+// Resolve returns the file that specifier refers to when imported from the
+// file from. Relative and absolute specifiers name files; other specifiers
+// name packages, which are looked for in node_modules directories.
+//
+// With Browser set, it returns ErrIgnored for modules that the `browser`
+// field replaces with nothing.
+func (r *Resolver) Resolve(specifier, from string) (string, error) {
+	original := specifier
+	fail := func(reason string) (string, error) {
+		return "", &Error{Specifier: original, From: from, Reason: reason}
+	}
+	dir := filepath.Dir(from)
+	specifier = r.alias(specifier)
+
+	if r.Browser && !isPath(specifier) {
+		if pkg, browser := browserField(dir); browser != nil {
+			if v, ok := browser[specifier]; ok {
+				target, ok := browserTarget(v)
+				switch {
+				case !ok:
+					return "", ErrIgnored
+				case isPath(target):
+					if f, ok := r.loadPath(filepath.Join(pkg, filepath.FromSlash(target))); ok {
+						return f, nil
+					}
+					return fail("browser field of " + filepath.Join(pkg, "package.json") + " names a missing file")
+				}
+				specifier = target
+			}
+		}
+	}
+
+	if r.TSConfig != nil && !isPath(specifier) {
+		if f, ok := r.tsPaths(specifier); ok {
+			return r.browserReplace(f)
+		}
+	}
+
+	if isPath(specifier) {
+		path := filepath.FromSlash(specifier)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if f, ok := r.loadPath(path); ok {
+			return r.browserReplace(f)
+		}
+		return fail("no such file")
+	}
+
+	if i := strings.Index(specifier, ":"); i > 0 && !strings.ContainsAny(specifier[:i], "/@") {
+		return fail("URL specifiers are not supported")
+	}
+
+	name, sub := splitPackage(specifier)
+	if name == "" {
+		return fail("invalid package name")
+	}
+	for d := dir; ; {
+		if filepath.Base(d) != "node_modules" {
+			pkg := filepath.Join(d, "node_modules", name)
+			if isDir(pkg) {
+				f, reason := r.loadPackage(pkg, sub)
+				if reason != "" {
+					return fail(reason)
+				}
+				return r.browserReplace(f)
+			}
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	return fail("package not found")
+}
+
+// alias applies the longest matching alias to specifier.
+func (r *Resolver) alias(specifier string) string {
+	best := ""
+	for k := range r.Aliases {
+		if len(k) > len(best) && (specifier == k || strings.HasPrefix(specifier, k+"/")) {
+			best = k
+		}
+	}
+	if best == "" {
+		return specifier
+	}
+	return r.Aliases[best] + specifier[len(best):]
+}
+
+// isPath returns whether a specifier names a file rather than a package.
+func isPath(specifier string) bool {
+	return specifier == "." || specifier == ".." ||
+		strings.HasPrefix(specifier, "/") ||
+		strings.HasPrefix(specifier, "./") ||
+		strings.HasPrefix(specifier, "../")
+}
+
+// splitPackage splits a package specifier into the package name and the
+// subpath within it, relative to the package, e.g. `@a/b/c` into `@a/b` and
+// `./c`.
+func splitPackage(specifier string) (name, sub string) {
+	parts := strings.SplitN(specifier, "/", 3)
+	n := 1
+	if strings.HasPrefix(specifier, "@") {
+		if len(parts) < 2 || parts[1] == "" {
+			return "", ""
+		}
+		n = 2
+	}
+	name = strings.Join(parts[:n], "/")
+	if rest := strings.TrimPrefix(specifier, name); rest != "" {
+		return name, "." + rest
+	}
+	return name, "."
+}
+
+func isFile(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().IsRegular()
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+// loadFile finds the file path names, possibly without its extension.
+func (r *Resolver) loadFile(path string) (string, bool) {
+	if isFile(path) {
+		return path, true
+	}
+	for _, ext := range r.Extensions {
+		if isFile(path + ext) {
+			return path + ext, true
+		}
+	}
+	return "", false
+}
+
+// loadPath finds the file or directory entry point path names.
+func (r *Resolver) loadPath(path string) (string, bool) {
+	if f, ok := r.loadFile(path); ok {
+		return f, true
+	}
+	return r.loadDir(path)
+}
+
+// loadDir finds the entry point of the directory path: the file named by its
+// package.json, or else its index file.
+func (r *Resolver) loadDir(path string) (string, bool) {
+	if pkg, _ := readPackage(path); pkg != nil {
+		fields := r.MainFields
+		if r.Browser {
+			// Only a string names the entry point; an object is ignored
+			// here, as it does not unmarshal into one.
+			fields = append([]string{"browser"}, fields...)
+		}
+		for _, field := range fields {
+			var main string
+			if json.Unmarshal(pkg[field], &main) != nil || main == "" {
+				continue
+			}
+			main = filepath.Join(path, filepath.FromSlash(main))
+			if f, ok := r.loadFile(main); ok {
+				return f, true
+			}
+			if f, ok := r.loadIndex(main); ok {
+				return f, true
+			}
+		}
+	}
+	return r.loadIndex(path)
+}
+
+// loadIndex finds the index file of the directory path.
+func (r *Resolver) loadIndex(path string) (string, bool) {
+	for _, ext := range r.Extensions {
+		if f := filepath.Join(path, "index"+ext); isFile(f) {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// loadPackage finds the file for the subpath sub of the package in the
+// directory path. It returns the reason when there is none.
+func (r *Resolver) loadPackage(path, sub string) (string, string) {
+	pkg, err := readPackage(path)
+	if err != nil {
+		return "", err.Error()
+	}
+	if exports, ok := pkg["exports"]; ok {
+		target, ok := r.exportsTarget(exports, sub)
+		if !ok {
+			return "", fmt.Sprintf("%q is not exported by %s", sub, filepath.Join(path, "package.json"))
+		}
+		f := filepath.Join(path, filepath.FromSlash(target))
+		if !isFile(f) {
+			return "", fmt.Sprintf("exported file %s does not exist", f)
+		}
+		return f, ""
+	}
+	if sub == "." {
+		if f, ok := r.loadDir(path); ok {
+			return f, ""
+		}
+		return "", "package has no entry point"
+	}
+	if f, ok := r.loadPath(filepath.Join(path, filepath.FromSlash(sub))); ok {
+		return f, ""
+	}
+	return "", "no such file in package"
+}
+
+// readPackage reads the package.json in dir, if there is one.
+func readPackage(dir string) (map[string]json.RawMessage, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "package.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	pkg := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", filepath.Join(dir, "package.json"), err)
+	}
+	return pkg, nil
+}
+
+// exportsTarget finds the target for the subpath sub in the `exports` field
+// of a package.json.
+func (r *Resolver) exportsTarget(exports json.RawMessage, sub string) (string, bool) {
+	keys, values := decodeObject(exports)
+	if len(keys) == 0 || !strings.HasPrefix(keys[0], ".") {
+		// Either a single target or conditions, both for the main entry.
+		if sub != "." {
+			return "", false
+		}
+		return r.target(exports, "")
+	}
+
+	if v, ok := values[sub]; ok {
+		return r.target(v, "")
+	}
+
+	// Patterns are matched by the longest prefix before the `*`.
+	best, match := "", ""
+	for _, k := range keys {
+		i := strings.Index(k, "*")
+		if i < 0 {
+			continue
+		}
+		prefix, suffix := k[:i], k[i+1:]
+		if len(prefix) > len(best) && len(sub) >= len(prefix)+len(suffix) &&
+			strings.HasPrefix(sub, prefix) && strings.HasSuffix(sub, suffix) {
+			best, match = k, sub[len(prefix):len(sub)-len(suffix)]
+		}
+	}
+	if best == "" {
+		return "", false
+	}
+	return r.target(values[best], match)
+}
+
+// target resolves an `exports` target, replacing `*` in it with match.
+func (r *Resolver) target(v json.RawMessage, match string) (string, bool) {
+	var s string
+	if json.Unmarshal(v, &s) == nil {
+		if !strings.HasPrefix(s, "./") {
+			return "", false
+		}
+		return strings.Replace(s, "*", match, -1), true
+	}
+
+	var alternatives []json.RawMessage
+	if json.Unmarshal(v, &alternatives) == nil {
+		for _, a := range alternatives {
+			if t, ok := r.target(a, match); ok {
+				return t, true
+			}
+		}
+		return "", false
+	}
+
+	keys, values := decodeObject(v)
+	for _, k := range keys {
+		if k == "default" || r.matches(k) {
+			if t, ok := r.target(values[k], match); ok {
+				return t, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (r *Resolver) matches(condition string) bool {
+	if r.Browser && condition == "browser" {
+		return true
+	}
+	for _, c := range r.Conditions {
+		if c == condition {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeObject decodes a JSON object, returning its keys in order, since
+// conditions are matched in the order they are written. It returns nothing
+// if v is not an object.
+func decodeObject(v json.RawMessage) ([]string, map[string]json.RawMessage) {
+	values := map[string]json.RawMessage{}
+	if json.Unmarshal(v, &values) != nil {
+		return nil, nil
+	}
+	d := json.NewDecoder(bytes.NewReader(v))
+	var keys []string
+	if _, err := d.Token(); err != nil {
+		return nil, nil
+	}
+	for d.More() {
+		k, err := d.Token()
+		if err != nil {
+			return nil, nil
+		}
+		keys = append(keys, k.(string))
+		var skip json.RawMessage
+		if err := d.Decode(&skip); err != nil {
+			return nil, nil
+		}
+	}
+	return keys, values
+}
+
+// browserField finds the package that dir is in, and returns its directory
+// and its `browser` field, if that is an object.
+func browserField(dir string) (string, map[string]json.RawMessage) {
+	for {
+		if isFile(filepath.Join(dir, "package.json")) {
+			pkg, _ := readPackage(dir)
+			var browser map[string]json.RawMessage
+			if json.Unmarshal(pkg["browser"], &browser) != nil {
+				return dir, nil
+			}
+			return dir, browser
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// browserTarget decodes a value of the `browser` field, which is a
+// replacement, or false to replace a module with nothing.
+func browserTarget(v json.RawMessage) (string, bool) {
+	var s string
+	if json.Unmarshal(v, &s) != nil {
+		return "", false
+	}
+	return s, true
+}
+
+// browserReplace returns the file that the `browser` field of its package
+// replaces the resolved file f with, or f if it is not replaced.
+func (r *Resolver) browserReplace(f string) (string, error) {
+	if !r.Browser {
+		return f, nil
+	}
+	pkg, browser := browserField(filepath.Dir(f))
+	for k, v := range browser {
+		if !isPath(k) {
+			continue
+		}
+		if kf, ok := r.loadFile(filepath.Join(pkg, filepath.FromSlash(k))); !ok || kf != f {
+			continue
+		}
+		target, ok := browserTarget(v)
+		if !ok {
+			return "", ErrIgnored
+		}
+		if rf, ok := r.loadPath(filepath.Join(pkg, filepath.FromSlash(target))); ok {
+			return rf, nil
+		}
+		return "", &Error{Specifier: k, From: filepath.Join(pkg, "package.json"), Reason: "browser field names a missing file"}
+	}
+	return f, nil
+}
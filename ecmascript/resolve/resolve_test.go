@@ -0,0 +1,340 @@
+package resolve
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTree writes files under a new temporary directory, and returns it.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestResolve(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"src/main.js":             "",
+		"src/util.mjs":            "",
+		"src/lib/index.js":        "",
+		"src/data.json":           "",
+		"src/app/main.js":         "",
+		"src/pkgdir/a.js":         "",
+		"src/pkgdir/package.json": `{"main": "a.js"}`,
+
+		"node_modules/plain/index.js": "",
+		"node_modules/plain/sub.js":   "",
+
+		"node_modules/fields/package.json": `{"main": "cjs.js", "module": "esm/index"}`,
+		"node_modules/fields/cjs.js":       "",
+		"node_modules/fields/esm/index.js": "",
+
+		"node_modules/@scope/pkg/package.json": `{"main": "lib/main.js"}`,
+		"node_modules/@scope/pkg/lib/main.js":  "",
+		"node_modules/@scope/pkg/lib/other.js": "",
+
+		"node_modules/exp/package.json": `{
+			"exports": {
+				".": {"require": "./main.cjs", "import": "./main.mjs"},
+				"./feature": "./src/feature.js",
+				"./utils/*": "./src/utils/*.js",
+				"./utils/internal/*": null
+			}
+		}`,
+		"node_modules/exp/main.cjs":                "",
+		"node_modules/exp/main.mjs":                "",
+		"node_modules/exp/src/feature.js":          "",
+		"node_modules/exp/src/utils/a.js":          "",
+		"node_modules/exp/src/utils/internal/b.js": "",
+
+		"node_modules/sugar/package.json": `{"exports": "./index.mjs"}`,
+		"node_modules/sugar/index.mjs":    "",
+
+		"src/node_modules/plain/index.js": "",
+	})
+	from := filepath.Join(root, "src", "main.js")
+
+	tests := []struct {
+		specifier string
+		from      string
+		expected  string
+	}{
+		{"./util.mjs", from, "src/util.mjs"},
+		{"./util", from, "src/util.mjs"},
+		{"./lib", from, "src/lib/index.js"},
+		{"./data", from, "src/data.json"},
+		{"./pkgdir", from, "src/pkgdir/a.js"},
+		{"../main", filepath.Join(root, "src", "app", "main.js"), "src/main.js"},
+		{filepath.ToSlash(filepath.Join(root, "src", "util")), from, "src/util.mjs"},
+		{"plain", from, "src/node_modules/plain/index.js"},
+		{"plain", filepath.Join(root, "main.js"), "node_modules/plain/index.js"},
+		{"plain/sub", filepath.Join(root, "main.js"), "node_modules/plain/sub.js"},
+		{"fields", from, "node_modules/fields/esm/index.js"},
+		{"@scope/pkg", from, "node_modules/@scope/pkg/lib/main.js"},
+		{"@scope/pkg/lib/other", from, "node_modules/@scope/pkg/lib/other.js"},
+		{"exp", from, "node_modules/exp/main.mjs"},
+		{"exp/feature", from, "node_modules/exp/src/feature.js"},
+		{"exp/utils/a", from, "node_modules/exp/src/utils/a.js"},
+		{"sugar", from, "node_modules/sugar/index.mjs"},
+	}
+
+	r := NewResolver()
+	for _, test := range tests {
+		t.Run(test.specifier, func(t *testing.T) {
+			result, err := r.Resolve(test.specifier, test.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := filepath.Join(root, filepath.FromSlash(test.expected))
+			if result != expected {
+				t.Errorf("expected %s, got %s", expected, result)
+			}
+		})
+	}
+}
+
+func TestResolveError(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"main.js":                        "",
+		"node_modules/exp/package.json":  `{"exports": {".": "./index.js", "./internal/*": null, "./*": "./*.js"}}`,
+		"node_modules/exp/index.js":      "",
+		"node_modules/exp/internal/a.js": "",
+		"node_modules/gone/package.json": `{"exports": "./missing.js"}`,
+		"node_modules/bad/package.json":  `{`,
+	})
+	from := filepath.Join(root, "main.js")
+
+	tests := []struct {
+		specifier string
+		reason    string
+	}{
+		{"./missing", "no such file"},
+		{"missing", "package not found"},
+		{"node:fs", "URL specifiers are not supported"},
+		{"exp/internal/a", `"./internal/a" is not exported by ` + filepath.Join(root, "node_modules", "exp", "package.json")},
+		{"exp/other", "exported file " + filepath.Join(root, "node_modules", "exp", "other.js") + " does not exist"},
+		{"gone", "exported file " + filepath.Join(root, "node_modules", "gone", "missing.js") + " does not exist"},
+		{"@scope", "invalid package name"},
+	}
+
+	r := NewResolver()
+	for _, test := range tests {
+		t.Run(test.specifier, func(t *testing.T) {
+			_, err := r.Resolve(test.specifier, from)
+			var rerr *Error
+			if !errors.As(err, &rerr) {
+				t.Fatalf("expected resolve error, got %v", err)
+			}
+			if rerr.Reason != test.reason {
+				t.Errorf("expected reason %q, got %q", test.reason, rerr.Reason)
+			}
+		})
+	}
+
+	if _, err := r.Resolve("bad", from); err == nil {
+		t.Error("expected error for invalid package.json")
+	}
+}
+
+func TestResolveBrowser(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"package.json":  `{"browser": {"./src/server.js": "./src/client.js", "os": "./shims/os.js", "fs": false, "http": "stream-http"}}`,
+		"src/main.js":   "",
+		"src/server.js": "",
+		"src/client.js": "",
+		"shims/os.js":   "",
+
+		"node_modules/str/package.json": `{"main": "node.js", "browser": "browser.js"}`,
+		"node_modules/str/node.js":      "",
+		"node_modules/str/browser.js":   "",
+
+		"node_modules/obj/package.json":     `{"main": "index.js", "browser": {"./index.js": "./web.js", "./lib/node-only.js": false}}`,
+		"node_modules/obj/index.js":         "",
+		"node_modules/obj/web.js":           "",
+		"node_modules/obj/lib/node-only.js": "",
+
+		"node_modules/cond/package.json": `{"exports": {"browser": "./web.mjs", "default": "./node.mjs"}}`,
+		"node_modules/cond/web.mjs":      "",
+		"node_modules/cond/node.mjs":     "",
+
+		"node_modules/stream-http/index.js": "",
+	})
+	from := filepath.Join(root, "src", "main.js")
+
+	tests := []struct {
+		specifier string
+		expected  string
+		node      string
+	}{
+		{"./server", "src/client.js", "src/server.js"},
+		{"os", "shims/os.js", ""},
+		{"http", "node_modules/stream-http/index.js", ""},
+		{"str", "node_modules/str/browser.js", "node_modules/str/node.js"},
+		{"obj", "node_modules/obj/web.js", "node_modules/obj/index.js"},
+		{"cond", "node_modules/cond/web.mjs", "node_modules/cond/node.mjs"},
+	}
+
+	node, browser := NewResolver(), NewResolver()
+	browser.Browser = true
+	for _, test := range tests {
+		t.Run(test.specifier, func(t *testing.T) {
+			result, err := browser.Resolve(test.specifier, from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := filepath.Join(root, filepath.FromSlash(test.expected)); result != expected {
+				t.Errorf("expected %s, got %s", expected, result)
+			}
+			if test.node == "" {
+				return
+			}
+			result, err = node.Resolve(test.specifier, from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := filepath.Join(root, filepath.FromSlash(test.node)); result != expected {
+				t.Errorf("expected %s without Browser, got %s", expected, result)
+			}
+		})
+	}
+
+	for _, specifier := range []string{"fs", "obj/lib/node-only"} {
+		if _, err := browser.Resolve(specifier, from); err != ErrIgnored {
+			t.Errorf("%s: expected %v, got %v", specifier, ErrIgnored, err)
+		}
+	}
+}
+
+func TestResolveAlias(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"src/main.js":                   "",
+		"src/utils/index.js":            "",
+		"src/utils/strings.js":          "",
+		"node_modules/preact/compat.js": "",
+	})
+	from := filepath.Join(root, "src", "main.js")
+
+	r := NewResolver()
+	r.Aliases = map[string]string{
+		"react":      "preact/compat",
+		"@app":       filepath.ToSlash(filepath.Join(root, "src")),
+		"@app/utils": filepath.ToSlash(filepath.Join(root, "src", "utils")),
+	}
+	tests := []struct {
+		specifier string
+		expected  string
+	}{
+		{"react", "node_modules/preact/compat.js"},
+		{"@app/main", "src/main.js"},
+		{"@app/utils", "src/utils/index.js"},
+		{"@app/utils/strings", "src/utils/strings.js"},
+	}
+	for _, test := range tests {
+		t.Run(test.specifier, func(t *testing.T) {
+			result, err := r.Resolve(test.specifier, from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := filepath.Join(root, filepath.FromSlash(test.expected)); result != expected {
+				t.Errorf("expected %s, got %s", expected, result)
+			}
+		})
+	}
+
+	_, err := r.Resolve("reactive", from)
+	var rerr *Error
+	if !errors.As(err, &rerr) || rerr.Specifier != "reactive" {
+		t.Errorf("expected reactive not to match the react alias, got %v", err)
+	}
+}
+
+func TestParseTSConfig(t *testing.T) {
+	data := []byte(`{
+		// Comments and trailing commas are allowed.
+		"compilerOptions": {
+			"baseUrl": "./src", /* relative to the file */
+			"paths": {
+				"@app/*": ["app/*", "generated/*",],
+				"//not-a-comment": ["x"],
+			},
+		},
+	}`)
+	dir := filepath.Join("project")
+	c, err := ParseTSConfig(data, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &TSConfig{
+		BaseURL: filepath.Join("project", "src"),
+		Paths: map[string][]string{
+			"@app/*":          {"app/*", "generated/*"},
+			"//not-a-comment": {"x"},
+		},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected %#v, got %#v", expected, c)
+	}
+
+	if _, err := ParseTSConfig([]byte(`{"compilerOptions": `), dir); err == nil {
+		t.Error("expected error for invalid tsconfig")
+	}
+}
+
+func TestResolveTSConfig(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"src/main.js":                 "",
+		"src/app/utils/index.js":      "",
+		"src/generated/schema.js":     "",
+		"src/lib/exact.js":            "",
+		"src/shared.js":               "",
+		"node_modules/shared/a.js":    "",
+		"node_modules/other/index.js": "",
+	})
+	from := filepath.Join(root, "src", "main.js")
+
+	r := NewResolver()
+	r.TSConfig = &TSConfig{
+		BaseURL: filepath.Join(root, "src"),
+		Paths: map[string][]string{
+			"@app/*":     {"app/*", "generated/*"},
+			"@app/gen/*": {"generated/*"},
+			"exact":      {"lib/exact.js"},
+			"*":          {"*", "missing/*"},
+		},
+	}
+	tests := []struct {
+		specifier string
+		expected  string
+	}{
+		{"@app/utils", "src/app/utils/index.js"},
+		{"@app/schema", "src/generated/schema.js"},
+		{"@app/gen/schema", "src/generated/schema.js"},
+		{"exact", "src/lib/exact.js"},
+		{"shared", "src/shared.js"},
+		{"other", "node_modules/other/index.js"},
+	}
+	for _, test := range tests {
+		t.Run(test.specifier, func(t *testing.T) {
+			result, err := r.Resolve(test.specifier, from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := filepath.Join(root, filepath.FromSlash(test.expected)); result != expected {
+				t.Errorf("expected %s, got %s", expected, result)
+			}
+		})
+	}
+}
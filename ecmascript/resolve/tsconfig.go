@@ -0,0 +1,133 @@
+package resolve
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// TSConfig is the part of a tsconfig.json that affects resolution: the
+// baseUrl and paths compiler options.
+type TSConfig struct {
+	// BaseURL is the directory that Paths targets are relative to. Bare
+	// specifiers that no pattern matches are also looked for in it, before
+	// node_modules directories.
+	BaseURL string
+
+	// Paths maps specifier patterns, which may contain one *, to the
+	// targets tried for them in order. A * in a target is replaced with what
+	// the * in the pattern matched. An exact pattern is preferred, then the
+	// pattern with the longest prefix before its *.
+	Paths map[string][]string
+}
+
+// ParseTSConfig reads the compiler options of a tsconfig.json, which may have
+// comments and trailing commas, from data. Relative paths in it are taken
+// relative to dir, the directory of the file. Configurations that extend
+// others are not followed.
+func ParseTSConfig(data []byte, dir string) (*TSConfig, error) {
+	var v struct {
+		CompilerOptions struct {
+			BaseURL string              `json:"baseUrl"`
+			Paths   map[string][]string `json:"paths"`
+		} `json:"compilerOptions"`
+	}
+	if err := json.Unmarshal(stripJSONC(data), &v); err != nil {
+		return nil, fmt.Errorf("invalid tsconfig: %w", err)
+	}
+	c := &TSConfig{BaseURL: dir, Paths: v.CompilerOptions.Paths}
+	if base := v.CompilerOptions.BaseURL; base != "" {
+		c.BaseURL = filepath.Join(dir, filepath.FromSlash(base))
+	}
+	return c, nil
+}
+
+// stripJSONC removes the comments and trailing commas that tsconfig.json
+// files may have but JSON does not allow, keeping offsets for errors.
+func stripJSONC(data []byte) []byte {
+	out := append([]byte(nil), data...)
+	blank := func(i, j int) {
+		for ; i < j; i++ {
+			if out[i] != '\n' {
+				out[i] = ' '
+			}
+		}
+	}
+	comma := -1
+	for i := 0; i < len(out); i++ {
+		switch c := out[i]; {
+		case c == '"':
+			for i++; i < len(out) && out[i] != '"'; i++ {
+				if out[i] == '\\' {
+					i++
+				}
+			}
+			comma = -1
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			j := i
+			for j < len(out) && out[j] != '\n' {
+				j++
+			}
+			blank(i, j)
+			i = j
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			j := i + 2
+			for j+1 < len(out) && !(out[j] == '*' && out[j+1] == '/') {
+				j++
+			}
+			j += 2
+			if j > len(out) {
+				j = len(out)
+			}
+			blank(i, j)
+			i = j - 1
+		case c == ',':
+			comma = i
+		case c == '}' || c == ']':
+			if comma >= 0 {
+				out[comma] = ' '
+			}
+			comma = -1
+		case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			comma = -1
+		}
+	}
+	return out
+}
+
+// tsPaths finds the file for a bare specifier using the TSConfig, if there
+// is one.
+func (r *Resolver) tsPaths(specifier string) (string, bool) {
+	c := r.TSConfig
+	targets, match := c.Paths[specifier], ""
+	if targets == nil {
+		best, bestKey := -1, ""
+		for k, v := range c.Paths {
+			i := strings.Index(k, "*")
+			if i < 0 {
+				continue
+			}
+			prefix, suffix := k[:i], k[i+1:]
+			if len(specifier) < len(prefix)+len(suffix) || !strings.HasPrefix(specifier, prefix) || !strings.HasSuffix(specifier, suffix) {
+				continue
+			}
+			// Ties are broken by key, so that the result does not depend on
+			// the order of the map.
+			if len(prefix) > best || len(prefix) == best && k < bestKey {
+				best, bestKey = len(prefix), k
+				targets, match = v, specifier[len(prefix):len(specifier)-len(suffix)]
+			}
+		}
+	}
+	for _, t := range targets {
+		t = strings.Replace(t, "*", match, 1)
+		if f, ok := r.loadPath(filepath.Join(c.BaseURL, filepath.FromSlash(t))); ok {
+			return f, true
+		}
+	}
+	if c.BaseURL != "" {
+		return r.loadPath(filepath.Join(c.BaseURL, filepath.FromSlash(specifier)))
+	}
+	return "", false
+}
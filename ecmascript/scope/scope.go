@@ -0,0 +1,263 @@
+package scope
+
+import "github.com/jchv/cleansheets/ecmascript/ast"
+
+// Kind is an enumeration type for the different kinds of scopes.
+type Kind int
+
+const (
+	// GlobalScope is the top-level scope of a script.
+	GlobalScope Kind = iota
+
+	// ModuleScope is the top-level scope of a module.
+	ModuleScope
+
+	// FunctionScope is the scope of a function, other than an arrow function.
+	// It provides bindings for `this` and `arguments`.
+	FunctionScope
+
+	// ArrowScope is the scope of an arrow function. `this` and `arguments`
+	// are inherited from the surrounding scope.
+	ArrowScope
+
+	// BlockScope is the scope of a block, or another statement that can
+	// contain lexical declarations, such as a for loop or catch clause.
+	BlockScope
+)
+
+// BindingKind is an enumeration type for the different ways a name can be
+// bound.
+type BindingKind int
+
+const (
+	// VarBinding is a binding created by a var statement.
+	VarBinding BindingKind = iota
+
+	// LetBinding is a binding created by a let declaration.
+	LetBinding
+
+	// ConstBinding is a binding created by a const declaration.
+	ConstBinding
+
+	// FunctionBinding is a binding created by a function declaration, or the
+	// name of a function expression.
+	FunctionBinding
+
+	// ClassBinding is a binding created by a class declaration, or the name
+	// of a class expression.
+	ClassBinding
+
+	// ParameterBinding is a binding created by a function parameter.
+	ParameterBinding
+
+	// CatchBinding is a binding created by a catch clause parameter.
+	CatchBinding
+
+	// ImportBinding is a binding created by an import declaration.
+	ImportBinding
+)
+
+// Scope is a single lexical scope.
+type Scope struct {
+	Kind   Kind
+	Parent *Scope
+
+	// Bindings contains the names declared directly in this scope.
+	Bindings map[string]BindingKind
+}
+
+// New returns the scope created by a node, with the given parent. It returns
+// nil if the node does not create a scope.
+//
+// The body of a function shares the scope of the function itself, so New
+// should not be called for the BlockStatement that makes up a function body.
+func New(n ast.Node, parent *Scope) *Scope {
+	s := &Scope{Parent: parent, Bindings: map[string]BindingKind{}}
+	switch t := n.(type) {
+	case ast.ScriptNode:
+		s.Kind = GlobalScope
+		s.declareVars(t.Body)
+		s.declareLexical(t.Body)
+
+	case ast.ModuleNode:
+		s.Kind = ModuleScope
+		s.declareVars(t.Body)
+		s.declareLexical(t.Body)
+
+	case ast.ModuleExpression:
+		// A module block is a module of its own, and can not refer to the
+		// bindings of the code around it.
+		s.Kind = ModuleScope
+		s.Parent = nil
+		s.declareVars(t.Body)
+		s.declareLexical(t.Body)
+
+	case ast.FunctionDeclaration:
+		s.Kind = FunctionScope
+		s.declareParams(t.Params)
+		s.declareVars(t.Body.Body)
+		s.declareLexical(t.Body.Body)
+
+	case ast.FunctionExpression:
+		s.Kind = FunctionScope
+		if t.Arrow {
+			s.Kind = ArrowScope
+		}
+		if t.ID != "" {
+			s.Bindings[t.ID] = FunctionBinding
+		}
+		s.declareParams(t.Params)
+		if b, ok := t.Body.(ast.BlockStatement); ok {
+			s.declareVars(b.Body)
+			s.declareLexical(b.Body)
+		}
+
+	case ast.ClassExpression:
+		if t.ID == "" {
+			return nil
+		}
+		s.Kind = BlockScope
+		s.Bindings[t.ID] = ClassBinding
+
+	case ast.BlockStatement:
+		s.Kind = BlockScope
+		s.declareLexical(t.Body)
+
+	case ast.SwitchStatement:
+		s.Kind = BlockScope
+		for _, c := range t.Cases {
+			s.declareLexical(c.Consequent)
+		}
+
+	case ast.ForStatement:
+		s.Kind = BlockScope
+		s.declareLexical([]ast.Node{t.Init})
+
+	case ast.ForInStatement:
+		s.Kind = BlockScope
+		s.declareLexical([]ast.Node{t.Left})
+
+	case ast.ForOfStatement:
+		s.Kind = BlockScope
+		s.declareLexical([]ast.Node{t.Left})
+
+	case ast.CatchClause:
+		s.Kind = BlockScope
+		for _, name := range t.Param.BoundNames() {
+			s.Bindings[name] = CatchBinding
+		}
+
+	default:
+		return nil
+	}
+	return s
+}
+
+// Lookup finds the scope that a name resolves to, starting at s. It returns
+// nil if the name is not bound in any scope, meaning it refers to a global
+// object property.
+func (s *Scope) Lookup(name string) *Scope {
+	for ; s != nil; s = s.Parent {
+		if _, ok := s.Bindings[name]; ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// Function returns the closest scope that provides `this` and `arguments`:
+// either a function scope, or the top-level scope.
+func (s *Scope) Function() *Scope {
+	for s.Kind == ArrowScope || s.Kind == BlockScope {
+		s = s.Parent
+	}
+	return s
+}
+
+func (s *Scope) declareParams(p ast.FormalParameters) {
+	for _, e := range p.Parameters {
+		for _, name := range e.Value.BoundNames() {
+			s.Bindings[name] = ParameterBinding
+		}
+	}
+	if p.RestParameter != "" {
+		s.Bindings[p.RestParameter] = ParameterBinding
+	}
+}
+
+// declareVars declares var bindings in a statement list, including those in
+// nested blocks but not those in nested functions.
+func (s *Scope) declareVars(body []ast.Node) {
+	for _, stmt := range body {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			switch t := n.(type) {
+			case ast.VariableDeclaration:
+				if t.Kind == ast.VarDeclaration {
+					for _, d := range t.Declarations {
+						for _, name := range d.ID.BoundNames() {
+							s.declare(name, VarBinding)
+						}
+					}
+				}
+			case ast.FunctionDeclaration, ast.FunctionExpression, ast.ClassDeclaration, ast.ClassExpression, ast.ModuleExpression:
+				return false
+			}
+			return true
+		})
+	}
+}
+
+// declareLexical declares bindings for the declarations directly contained in
+// a statement list.
+func (s *Scope) declareLexical(body []ast.Node) {
+	for _, stmt := range body {
+		if e, ok := stmt.(ast.ExportDeclNode); ok {
+			stmt = e.Declaration
+			if e.Default != nil {
+				stmt = e.Default
+			}
+		}
+		switch t := stmt.(type) {
+		case ast.VariableDeclaration:
+			kind := LetBinding
+			switch t.Kind {
+			case ast.VarDeclaration:
+				continue
+			case ast.ConstDeclaration:
+				kind = ConstBinding
+			}
+			for _, d := range t.Declarations {
+				for _, name := range d.ID.BoundNames() {
+					s.declare(name, kind)
+				}
+			}
+		case ast.FunctionDeclaration:
+			if t.ID != "" {
+				s.declare(t.ID, FunctionBinding)
+			}
+		case ast.ClassDeclaration:
+			if t.ID != "" {
+				s.declare(t.ID, ClassBinding)
+			}
+		case ast.ImportDeclNode:
+			if t.DefaultBinding != nil {
+				s.declare(t.DefaultBinding.Identifier, ImportBinding)
+			}
+			if t.NameSpace != nil {
+				s.declare(t.NameSpace.Identifier, ImportBinding)
+			}
+			for _, i := range t.NamedImports {
+				s.declare(i.Binding(), ImportBinding)
+			}
+		}
+	}
+}
+
+// declare adds a binding to the scope. A var declaration with the same name as
+// a parameter refers to the parameter, so it does not replace it.
+func (s *Scope) declare(name string, kind BindingKind) {
+	if prev, ok := s.Bindings[name]; ok && prev == ParameterBinding && kind == VarBinding {
+		return
+	}
+	s.Bindings[name] = kind
+}
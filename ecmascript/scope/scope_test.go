@@ -0,0 +1,182 @@
+package scope
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+func parse(t *testing.T, src string, mode parser.ParseMode) ast.Node {
+	t.Helper()
+	n, err := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(src), nil))).Parse(parser.ParseOptions{Mode: mode, Experimental: parser.Experimental{ModuleBlocks: true}})
+	if err != nil {
+		t.Fatalf("error parsing %q: %v", src, err)
+	}
+	return n
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		mode     parser.ParseMode
+		node     func(ast.Node) ast.Node
+		kind     Kind
+		bindings map[string]BindingKind
+	}{
+		{
+			name:  "script",
+			input: `var a, {b, c: [d, ...e], ...f} = g; let h; const i = 1; function j() { var k; } class L {} if (a) { var m; let n; }`,
+			node:  func(n ast.Node) ast.Node { return n },
+			kind:  GlobalScope,
+			bindings: map[string]BindingKind{
+				"a": VarBinding, "b": VarBinding, "d": VarBinding, "e": VarBinding, "f": VarBinding,
+				"h": LetBinding, "i": ConstBinding, "j": FunctionBinding, "L": ClassBinding, "m": VarBinding,
+			},
+		},
+		{
+			name:  "module",
+			input: `import a, {b as c} from "m"; import * as d from "m"; export const e = 1; export default function f() {}`,
+			mode:  parser.ModuleMode,
+			node:  func(n ast.Node) ast.Node { return n },
+			kind:  ModuleScope,
+			bindings: map[string]BindingKind{
+				"a": ImportBinding, "c": ImportBinding, "d": ImportBinding, "e": ConstBinding, "f": FunctionBinding,
+			},
+		},
+		{
+			name:  "function",
+			input: `function f(a, [b] = 1, ...c) { var a, d; let e; }`,
+			node:  func(n ast.Node) ast.Node { return n.(ast.ScriptNode).Body[0] },
+			kind:  FunctionScope,
+			bindings: map[string]BindingKind{
+				"a": ParameterBinding, "b": ParameterBinding, "c": ParameterBinding, "d": VarBinding, "e": LetBinding,
+			},
+		},
+		{
+			name:  "named function expression",
+			input: `(function f(a) {})`,
+			node: func(n ast.Node) ast.Node {
+				return n.(ast.ScriptNode).Body[0].(ast.ExpressionStatement).Expression.(ast.ParenthesizedExpression).Expression
+			},
+			kind:     FunctionScope,
+			bindings: map[string]BindingKind{"f": FunctionBinding, "a": ParameterBinding},
+		},
+		{
+			name:     "arrow function",
+			input:    `(a, {b}) => { var c; }`,
+			node:     func(n ast.Node) ast.Node { return n.(ast.ScriptNode).Body[0].(ast.ExpressionStatement).Expression },
+			kind:     ArrowScope,
+			bindings: map[string]BindingKind{"a": ParameterBinding, "b": ParameterBinding, "c": VarBinding},
+		},
+		{
+			name:     "block",
+			input:    `{ var a; let b; const c = 1; function d() {} }`,
+			node:     func(n ast.Node) ast.Node { return n.(ast.ScriptNode).Body[0] },
+			kind:     BlockScope,
+			bindings: map[string]BindingKind{"b": LetBinding, "c": ConstBinding, "d": FunctionBinding},
+		},
+		{
+			name:     "for",
+			input:    `for (let i = 0, j; ;) {}`,
+			node:     func(n ast.Node) ast.Node { return n.(ast.ScriptNode).Body[0] },
+			kind:     BlockScope,
+			bindings: map[string]BindingKind{"i": LetBinding, "j": LetBinding},
+		},
+		{
+			name:  "module block",
+			input: `var a; m = module { var b; let c; export function d() {} };`,
+			node: func(n ast.Node) ast.Node {
+				return n.(ast.ScriptNode).Body[1].(ast.ExpressionStatement).Expression.(ast.AssignmentExpression).Right
+			},
+			kind:     ModuleScope,
+			bindings: map[string]BindingKind{"b": VarBinding, "c": LetBinding, "d": FunctionBinding},
+		},
+		{
+			name:     "catch",
+			input:    `try {} catch ({message}) {}`,
+			node:     func(n ast.Node) ast.Node { return n.(ast.ScriptNode).Body[0].(ast.TryStatement).Handler },
+			kind:     BlockScope,
+			bindings: map[string]BindingKind{"message": CatchBinding},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := New(test.node(parse(t, test.input, test.mode)), nil)
+			if s == nil {
+				t.Fatal("expected scope, got nil")
+			}
+			if s.Kind != test.kind {
+				t.Errorf("expected kind %d, got %d", test.kind, s.Kind)
+			}
+			if diff := cmp.Diff(test.bindings, s.Bindings); diff != "" {
+				t.Errorf("bindings mismatch (-expected +result):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewNoScope(t *testing.T) {
+	if s := New(ast.Identifier{Name: "a"}, nil); s != nil {
+		t.Errorf("expected nil scope, got %#v", s)
+	}
+	if s := New(ast.ClassExpression{}, nil); s != nil {
+		t.Errorf("expected nil scope for anonymous class, got %#v", s)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	global := &Scope{Kind: GlobalScope, Bindings: map[string]BindingKind{"a": VarBinding}}
+	fn := &Scope{Kind: FunctionScope, Parent: global, Bindings: map[string]BindingKind{"b": ParameterBinding}}
+	arrow := &Scope{Kind: ArrowScope, Parent: fn, Bindings: map[string]BindingKind{}}
+	block := &Scope{Kind: BlockScope, Parent: arrow, Bindings: map[string]BindingKind{"a": LetBinding}}
+
+	if s := block.Lookup("a"); s != block {
+		t.Errorf("expected a to resolve to block scope, got %#v", s)
+	}
+	if s := block.Lookup("b"); s != fn {
+		t.Errorf("expected b to resolve to function scope, got %#v", s)
+	}
+	if s := block.Lookup("c"); s != nil {
+		t.Errorf("expected c to be unresolved, got %#v", s)
+	}
+	if s := block.Function(); s != fn {
+		t.Errorf("expected function scope, got %#v", s)
+	}
+	if s := global.Function(); s != global {
+		t.Errorf("expected global scope, got %#v", s)
+	}
+}
+
+func TestWalker(t *testing.T) {
+	n := parse(t, `var a = b; function f(c) { let d = c; a = d; [e, a] = g; } for (const h of i) { h++; }`, parser.ScriptMode)
+
+	kinds := map[Kind]string{GlobalScope: "global", FunctionScope: "function", BlockScope: "block"}
+	result := []string{}
+	w := Walker{
+		OnRef: func(w *Walker, id ast.Identifier, assign bool) {
+			ref := id.Name
+			if assign {
+				ref += "="
+			}
+			if s := w.Scope.Lookup(id.Name); s != nil {
+				ref += "@" + kinds[s.Kind]
+			}
+			result = append(result, ref)
+		},
+	}
+	w.Walk(n)
+
+	expected := []string{
+		"b", "c@function", "a=@global", "d@function", "e=", "a=@global", "g",
+		"i", "h=@block",
+	}
+	if diff := cmp.Diff(expected, result); diff != "" {
+		t.Errorf("refs mismatch (-expected +result):\n%s", diff)
+	}
+}
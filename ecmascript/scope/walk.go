@@ -0,0 +1,198 @@
+package scope
+
+import "github.com/jchv/cleansheets/ecmascript/ast"
+
+// Walker visits the identifier references in a tree, tracking the scope that
+// each one is resolved in. The callbacks are optional.
+type Walker struct {
+	// Scope is the scope of the node being visited. It may be set before
+	// walking a subtree to resolve references from within it.
+	Scope *Scope
+
+	// Functions and Loops are the number of functions and loops between the
+	// current node and the root.
+	Functions, Loops int
+
+	// OnNode is called for each node before it is visited, in the scope that
+	// contains it.
+	OnNode func(w *Walker, n ast.Node)
+
+	// OnScope is called when a new scope is entered.
+	OnScope func(w *Walker, s *Scope)
+
+	// OnRef is called for each identifier reference. assign is set when the
+	// reference is the target of an assignment.
+	OnRef func(w *Walker, id ast.Identifier, assign bool)
+
+	// assigning is set while visiting a destructuring assignment target.
+	assigning bool
+}
+
+// Walk visits n and its descendants.
+func (w *Walker) Walk(n ast.Node) {
+	w.walk(n)
+}
+
+func (w *Walker) enter(s *Scope) func() {
+	saved := w.Scope
+	w.Scope = s
+	if w.OnScope != nil {
+		w.OnScope(w, s)
+	}
+	return func() { w.Scope = saved }
+}
+
+func (w *Walker) ref(id ast.Identifier, assign bool) {
+	if w.OnRef != nil {
+		w.OnRef(w, id, assign)
+	}
+}
+
+// target visits the target of an assignment.
+func (w *Walker) target(n ast.Node) {
+	for {
+		p, ok := n.(ast.ParenthesizedExpression)
+		if !ok {
+			break
+		}
+		n = p.Expression
+	}
+	switch t := n.(type) {
+	case ast.Identifier:
+		w.ref(t, true)
+	case ast.ArrayExpression, ast.ObjectExpression:
+		saved := w.assigning
+		w.assigning = true
+		w.walk(t)
+		w.assigning = saved
+	default:
+		w.walk(n)
+	}
+}
+
+// function visits the parameters and body of a function in scope s.
+func (w *Walker) function(s *Scope, params ast.FormalParameters, body ast.Node) {
+	saved := w.assigning
+	w.Functions++
+	w.assigning = false
+	restore := w.enter(s)
+	defer func() {
+		restore()
+		w.Functions--
+		w.assigning = saved
+	}()
+
+	// FormalParameters is not a node itself, so wrap it in one to visit the
+	// default values in it.
+	ast.MapChildren(ast.FunctionExpression{Params: params}, w.walk)
+	if b, ok := body.(ast.BlockStatement); ok {
+		for _, stmt := range b.Body {
+			w.walk(stmt)
+		}
+	} else {
+		w.walk(body)
+	}
+}
+
+func (w *Walker) walk(n ast.Node) ast.Node {
+	if w.OnNode != nil {
+		w.OnNode(w, n)
+	}
+
+	switch t := n.(type) {
+	case ast.FunctionDeclaration:
+		w.function(New(t, w.Scope), t.Params, t.Body)
+		return n
+
+	case ast.FunctionExpression:
+		w.function(New(t, w.Scope), t.Params, t.Body)
+		return n
+
+	case ast.ModuleExpression:
+		// The body of a module block is at the top level of its own module.
+		functions, loops := w.Functions, w.Loops
+		w.Functions, w.Loops = 0, 0
+		defer func() { w.Functions, w.Loops = functions, loops }()
+
+	case ast.ForStatement, ast.WhileStatement, ast.DoWhileStatement:
+		w.Loops++
+		defer func() { w.Loops-- }()
+
+	case ast.ForInStatement, ast.ForOfStatement:
+		w.Loops++
+		defer func() { w.Loops-- }()
+		defer w.enter(New(t, w.Scope))()
+		var left, right, body ast.Node
+		if f, ok := t.(ast.ForInStatement); ok {
+			left, right, body = f.Left, f.Right, f.Body
+		} else {
+			f := t.(ast.ForOfStatement)
+			left, right, body = f.Left, f.Right, f.Body
+		}
+		if _, ok := left.(ast.VariableDeclaration); ok {
+			w.walk(left)
+		} else {
+			w.target(left)
+		}
+		w.walk(right)
+		w.walk(body)
+		return n
+
+	case ast.AssignmentExpression:
+		w.target(t.Left)
+		w.walk(t.Right)
+		return n
+
+	case ast.UpdateExpression:
+		w.target(t.Argument)
+		return n
+
+	case ast.Identifier:
+		w.ref(t, w.assigning)
+		return n
+
+	case ast.MethodDefinition:
+		if t.Computed {
+			w.walk(t.Key)
+		}
+		w.walk(t.Value)
+		return n
+
+	case ast.AccessorProperty:
+		if t.Computed {
+			w.walk(t.Key)
+		}
+		if t.Value != nil {
+			w.walk(t.Value)
+		}
+		return n
+
+	case ast.MemberExpression:
+		w.walk(t.Object)
+		if t.Computed {
+			w.walk(t.Property)
+		}
+		return n
+
+	case ast.ObjectExpression:
+		for _, p := range t.Properties {
+			if p.Computed {
+				w.walk(p.Key)
+			}
+			if p.Value != nil {
+				w.walk(p.Value)
+			} else if id, ok := p.Key.(ast.Identifier); ok {
+				w.ref(id, w.assigning)
+			}
+			if p.DestructureInit != nil {
+				w.walk(p.DestructureInit)
+			}
+		}
+		return n
+	}
+
+	if s := New(n, w.Scope); s != nil {
+		defer w.enter(s)()
+	}
+	return ast.MapChildren(n, w.walk)
+}
@@ -0,0 +1,291 @@
+package transform
+
+import (
+	"errors"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/scope"
+)
+
+// LowerArrowFunctions rewrites arrow functions into function expressions.
+//
+// Arrow functions inherit `this`, `arguments` and `new.target` from the
+// function they are in. Where an arrow function uses any of them, the
+// enclosing function stores it in a temporary variable, and the arrow function
+// refers to that instead. Functions that contain no such arrow functions are
+// left as-is.
+//
+// Parameter lists and auto-accessor initializers can not see variables
+// declared in a function body, so values captured in them are passed to an
+// immediately invoked function wrapped around the expression instead.
+//
+// `super` can not be captured in a variable, so arrow functions that use it
+// are reported as an error.
+func LowerArrowFunctions(n ast.Node) (ast.Node, error) {
+	l := arrowLowering{names: newNameGenerator(n)}
+	n = l.visit(n)
+	return n, l.err
+}
+
+type arrowLowering struct {
+	names *nameGenerator
+	scope *scope.Scope
+
+	// Names of the temporaries. These are chosen once, when first needed,
+	// since each function declares its own.
+	thisName, argumentsName, newTargetName string
+
+	// capture tracks what the arrow functions in the current function use.
+	capture *arrowCapture
+
+	// arrows is the number of arrow functions between the current node and
+	// the current function.
+	arrows int
+
+	// err is the first error encountered.
+	err error
+}
+
+type arrowCapture struct {
+	this, arguments, newTarget bool
+}
+
+// function visits the body of a function or program, declaring captured
+// values at the start if any arrow functions in it need them.
+func (l *arrowLowering) function(s *scope.Scope, params *ast.FormalParameters, body []ast.Node) []ast.Node {
+	saved, savedCapture, savedArrows := l.scope, l.capture, l.arrows
+	l.scope, l.capture, l.arrows = s, &arrowCapture{}, 0
+	defer func() {
+		l.scope, l.capture, l.arrows = saved, savedCapture, savedArrows
+	}()
+
+	if params != nil {
+		*params = mapParams(*params, l.isolated)
+	}
+	body = l.statements(body)
+
+	decl := ast.VariableDeclaration{Kind: ast.VarDeclaration}
+	for _, c := range l.captured() {
+		decl.Declarations = append(decl.Declarations, varDecl(c.name, c.value))
+	}
+	if len(decl.Declarations) == 0 {
+		return body
+	}
+	return prependStatements(body, decl)
+}
+
+// isolated visits an expression that can not see the variables declared in
+// the function body, such as a default parameter value. If arrow functions in
+// it capture anything, the expression is wrapped in a function that takes the
+// captured values as arguments.
+func (l *arrowLowering) isolated(n ast.Node) ast.Node {
+	savedCapture, savedArrows := l.capture, l.arrows
+	l.capture, l.arrows = &arrowCapture{}, 0
+	defer func() {
+		l.capture, l.arrows = savedCapture, savedArrows
+	}()
+
+	n = l.visit(n)
+
+	fn := ast.FunctionExpression{Body: ast.BlockStatement{Body: []ast.Node{ast.ReturnStatement{Argument: n}}}}
+	var args []ast.Node
+	for _, c := range l.captured() {
+		fn.Params.Parameters = append(fn.Params.Parameters, ast.BindingElement{Value: ast.BindingPattern{Identifier: c.name}})
+		args = append(args, c.value)
+	}
+	if len(args) == 0 {
+		return n
+	}
+	return call(ast.ParenthesizedExpression{Expression: fn}, args...)
+}
+
+// arrowTemp is a temporary that holds a captured value.
+type arrowTemp struct {
+	name  string
+	value ast.Node
+}
+
+// captured returns the temporaries needed by the current capture.
+func (l *arrowLowering) captured() []arrowTemp {
+	var temps []arrowTemp
+	if l.capture.this {
+		temps = append(temps, arrowTemp{l.thisName, ast.ThisExpression{}})
+	}
+	if l.capture.arguments {
+		temps = append(temps, arrowTemp{l.argumentsName, ast.Identifier{Name: "arguments"}})
+	}
+	if l.capture.newTarget {
+		temps = append(temps, arrowTemp{l.newTargetName, ast.MetaProperty{Meta: "new", Property: "target"}})
+	}
+	return temps
+}
+
+func (l *arrowLowering) statements(body []ast.Node) []ast.Node {
+	out := make([]ast.Node, len(body))
+	for i, stmt := range body {
+		out[i] = l.visit(stmt)
+	}
+	return out
+}
+
+// arrow lowers an arrow function.
+func (l *arrowLowering) arrow(n ast.FunctionExpression) ast.Node {
+	saved := l.scope
+	l.scope = scope.New(n, l.scope)
+	l.arrows++
+	defer func() {
+		l.scope = saved
+		l.arrows--
+	}()
+
+	n.Params = mapParams(n.Params, l.visit)
+	b, ok := n.Body.(ast.BlockStatement)
+	if ok {
+		b.Body = l.statements(b.Body)
+	} else {
+		// Concise body.
+		b = ast.BlockStatement{Body: []ast.Node{ast.ReturnStatement{Argument: l.visit(n.Body)}}}
+		b.SetStart(n.Body.Span().Start)
+		b.SetEnd(n.Body.Span().End)
+	}
+	n.Body = b
+	n.Arrow = false
+	n.Expression = false
+	return n
+}
+
+// this returns the replacement for a `this` expression.
+func (l *arrowLowering) this(n ast.ThisExpression) ast.Node {
+	if l.arrows == 0 {
+		return n
+	}
+	if l.thisName == "" {
+		l.thisName = l.names.generate("this")
+	}
+	l.capture.this = true
+	return ast.Identifier{Name: l.thisName}
+}
+
+// metaProperty returns the replacement for a meta property.
+func (l *arrowLowering) metaProperty(n ast.MetaProperty) ast.Node {
+	if n.Meta != "new" || l.arrows == 0 {
+		return n
+	}
+	if l.newTargetName == "" {
+		l.newTargetName = l.names.generate("newTarget")
+	}
+	l.capture.newTarget = true
+	return ast.Identifier{Name: l.newTargetName}
+}
+
+// identifier returns the replacement for an identifier reference.
+func (l *arrowLowering) identifier(n ast.Identifier) ast.Node {
+	if n.Name != "arguments" || l.arrows == 0 {
+		return n
+	}
+	// Only the implicit arguments object of the enclosing function needs to
+	// be captured; a variable named arguments is still in scope after the
+	// rewrite.
+	fn := l.scope.Function()
+	if fn.Kind != scope.FunctionScope || l.scope.Lookup(n.Name) != nil {
+		return n
+	}
+	if l.argumentsName == "" {
+		l.argumentsName = l.names.generate("arguments")
+	}
+	l.capture.arguments = true
+	return ast.Identifier{Name: l.argumentsName}
+}
+
+func (l *arrowLowering) visit(n ast.Node) ast.Node {
+	switch t := n.(type) {
+	case ast.ScriptNode:
+		t.Body = l.function(scope.New(t, l.scope), nil, t.Body)
+		return t
+
+	case ast.ModuleNode:
+		t.Body = l.function(scope.New(t, l.scope), nil, t.Body)
+		return t
+
+	case ast.FunctionDeclaration:
+		t.Body.Body = l.function(scope.New(t, l.scope), &t.Params, t.Body.Body)
+		return t
+
+	case ast.FunctionExpression:
+		if t.Arrow {
+			return l.arrow(t)
+		}
+		b := t.Body.(ast.BlockStatement)
+		b.Body = l.function(scope.New(t, l.scope), &t.Params, b.Body)
+		t.Body = b
+		return t
+
+	case ast.MethodDefinition:
+		if t.Computed {
+			t.Key = l.visit(t.Key)
+		}
+		t.Value = l.visit(t.Value).(ast.FunctionExpression)
+		return t
+
+	case ast.AccessorProperty:
+		if t.Computed {
+			t.Key = l.visit(t.Key)
+		}
+		if t.Value != nil {
+			// The initializer is evaluated with the instance as `this`.
+			t.Value = l.isolated(t.Value)
+		}
+		return t
+
+	case ast.MemberExpression:
+		t.Object = l.visit(t.Object)
+		if t.Computed {
+			t.Property = l.visit(t.Property)
+		}
+		return t
+
+	case ast.ObjectExpression:
+		props := make([]ast.Property, len(t.Properties))
+		for i, p := range t.Properties {
+			if p.Computed {
+				p.Key = l.visit(p.Key)
+			}
+			if p.Value != nil {
+				p.Value = l.visit(p.Value)
+			} else if id, ok := p.Key.(ast.Identifier); ok {
+				// Expand shorthand properties whose value is replaced.
+				if r, ok := l.identifier(id).(ast.Identifier); ok && r.Name != id.Name {
+					p.Value = r
+				}
+			}
+			if p.DestructureInit != nil {
+				p.DestructureInit = l.visit(p.DestructureInit)
+			}
+			props[i] = p
+		}
+		t.Properties = props
+		return t
+
+	case ast.ThisExpression:
+		return l.this(t)
+
+	case ast.Identifier:
+		return l.identifier(t)
+
+	case ast.MetaProperty:
+		return l.metaProperty(t)
+
+	case ast.Super:
+		if l.arrows > 0 && l.err == nil {
+			l.err = errors.New("arrow function transform does not support super in arrow functions")
+		}
+		return t
+	}
+
+	if s := scope.New(n, l.scope); s != nil {
+		saved := l.scope
+		l.scope = s
+		defer func() { l.scope = saved }()
+	}
+	return ast.MapChildren(n, l.visit)
+}
@@ -0,0 +1,142 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+func TestLowerArrowFunctions(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{
+			name:     "no captures",
+			input:    `var f = (a, b = 1, ...c) => a + b;`,
+			expected: `var f = function (a, b = 1, ...c) { return a + b; };`,
+		},
+		{
+			name:     "block body",
+			input:    `var f = x => { return x; };`,
+			expected: `var f = function (x) { return x; };`,
+		},
+		{
+			name:     "this",
+			input:    `function f() { "use strict"; return () => this.a; }`,
+			expected: `function f() { "use strict"; var _this = this; return function () { return _this.a; }; }`,
+		},
+		{
+			name:     "this in nested arrows",
+			input:    `function f() { return () => () => this; }`,
+			expected: `function f() { var _this = this; return function () { return function () { return _this; }; }; }`,
+		},
+		{
+			name:     "this in nested function",
+			input:    `function f() { return () => function () { return this; }; }`,
+			expected: `function f() { return function () { return function () { return this; }; }; }`,
+		},
+		{
+			name:     "this at top level",
+			input:    `var f = () => this;`,
+			expected: `var _this = this; var f = function () { return _this; };`,
+		},
+		{
+			name:     "arguments",
+			input:    `function f() { return () => arguments[0] + this.a; }`,
+			expected: `function f() { var _this = this, _arguments = arguments; return function () { return _arguments[0] + _this.a; }; }`,
+		},
+		{
+			name:     "arguments shorthand",
+			input:    `function f() { return () => ({arguments}); }`,
+			expected: `function f() { var _arguments = arguments; return function () { return {arguments: _arguments}; }; }`,
+		},
+		{
+			name:     "arguments variable",
+			input:    `function f(arguments) { return () => arguments; } function g() { return arguments => arguments; } function h() { return () => { let arguments; return arguments; }; }`,
+			expected: `function f(arguments) { return function () { return arguments; }; } function g() { return function (arguments) { return arguments; }; } function h() { return function () { let arguments; return arguments; }; }`,
+		},
+		{
+			name:     "arguments at top level",
+			input:    `var f = () => arguments;`,
+			expected: `var f = function () { return arguments; };`,
+		},
+		{
+			name:     "property names",
+			input:    `function f() { return () => ({arguments: a.arguments, [arguments]: 1}); }`,
+			expected: `function f() { var _arguments = arguments; return function () { return {arguments: a.arguments, [_arguments]: 1}; }; }`,
+		},
+		{
+			name:     "methods",
+			input:    `class A { m() { return () => this; } }`,
+			expected: `class A { m() { var _this = this; return function () { return _this; }; } }`,
+		},
+		{
+			name:     "new.target",
+			input:    `function F() { return () => new.target; }`,
+			expected: `function F() { var _newTarget = new.target; return function () { return _newTarget; }; }`,
+		},
+		{
+			name:     "default parameters",
+			input:    `function f(a = () => this, b = () => arguments.length + new.target) { return () => this; }`,
+			expected: `function f(a = (function (_this) { return function () { return _this; }; })(this), b = (function (_arguments, _newTarget) { return function () { return _arguments.length + _newTarget; }; })(arguments, new.target)) { var _this = this; return function () { return _this; }; }`,
+		},
+		{
+			name:     "parameter patterns",
+			input:    `function f({[(() => this)()]: a} = {}) {}`,
+			expected: `function f({[(function (_this) { return (function () { return _this; })(); })(this)]: a} = {}) {}`,
+		},
+		{
+			name:     "this in parameters",
+			input:    `function f(a = this) { return (b = () => this) => b; }`,
+			expected: `function f(a = this) { var _this = this; return function (b = function () { return _this; }) { return b; }; }`,
+		},
+		{
+			name:     "name collisions",
+			input:    `var _this; function f() { return () => this; }`,
+			expected: `var _this; function f() { var _this2 = this; return function () { return _this2; }; }`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := LowerArrowFunctions(parse(t, test.input, parser.ScriptMode))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertESTree(t, parseScript(t, test.expected), result)
+		})
+	}
+}
+
+func TestLowerArrowFunctionsAccessors(t *testing.T) {
+	parse := func(src string) ast.Node {
+		opt := parser.ParseOptions{Mode: parser.ScriptMode, Experimental: parser.Experimental{Decorators: true}}
+		n, err := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(src), nil))).Parse(opt)
+		if err != nil {
+			t.Fatalf("error parsing %q: %v", src, err)
+		}
+		return n
+	}
+	result, err := LowerArrowFunctions(parse(`function f() { class A { accessor x = () => this; accessor [(() => this)()] = 1; } }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `function f() { var _this = this; class A { accessor x = (function (_this) { return function () { return _this; }; })(this); accessor [(function () { return _this; })()] = 1; } }`
+	assertESTree(t, parse(expected), result)
+}
+
+func TestLowerArrowFunctionsErrors(t *testing.T) {
+	tests := []string{
+		`class A extends B { m() { return () => super.m(); } }`,
+		`class A extends B { constructor() { (() => super())(); } }`,
+		`class A extends B { m(a = () => super.a) {} }`,
+	}
+	for _, test := range tests {
+		if _, err := LowerArrowFunctions(parseScript(t, test)); err == nil {
+			t.Errorf("%q: expected error, got nil", test)
+		}
+	}
+}
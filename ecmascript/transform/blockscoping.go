@@ -0,0 +1,775 @@
+package transform
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/scope"
+)
+
+// LowerBlockScoping rewrites let and const declarations into var
+// declarations.
+//
+// A binding declared in a block is renamed if its name is declared anywhere
+// else, or refers to a global anywhere, since hoisting it to the enclosing
+// function could change what other references resolve to. When closures in
+// the body of a loop capture bindings declared in the loop, the body is moved
+// into a function that is called on each iteration, giving every iteration
+// its own copy of the bindings; break, continue and return statements in the
+// body are passed through the return value of the function. The temporal dead
+// zone is not emulated, and assignments to constants are not prevented.
+//
+// Var declarations in moved loop bodies become assignments, which requires
+// destructuring patterns in them to have been lowered already.
+func LowerBlockScoping(n ast.Node) (ast.Node, error) {
+	switch n.(type) {
+	case ast.ScriptNode, ast.ModuleNode:
+	default:
+		return nil, errors.New("block scoping transform requires a script or module")
+	}
+
+	l := blockScoping{
+		names:     newNameGenerator(n),
+		conflicts: map[string]bool{},
+		renames:   map[*scope.Scope]map[string]string{},
+	}
+	counts := map[string]int{}
+	w := scope.Walker{
+		OnScope: func(w *scope.Walker, s *scope.Scope) {
+			for name := range s.Bindings {
+				counts[name]++
+			}
+		},
+		OnRef: func(w *scope.Walker, id ast.Identifier, assign bool) {
+			if w.Scope.Lookup(id.Name) == nil {
+				l.conflicts[id.Name] = true
+			}
+		},
+	}
+	w.Walk(n)
+	for name, count := range counts {
+		if count > 1 {
+			l.conflicts[name] = true
+		}
+	}
+
+	n = l.visit(n)
+	return n, l.err
+}
+
+type blockScoping struct {
+	names *nameGenerator
+	scope *scope.Scope
+
+	// conflicts contains the names that block-scoped bindings can not keep.
+	conflicts map[string]bool
+
+	// renames maps the bindings of each block scope to their new names.
+	renames map[*scope.Scope]map[string]string
+
+	// loop is the loop body being moved into a function. It is nil outside of
+	// loop bodies, and inside of nested functions.
+	loop *loopFunction
+
+	// capture is the loop body that `this` and `arguments` are captured for.
+	// Unlike loop, it is kept inside of arrow functions.
+	capture *loopFunction
+
+	// pre contains statements to insert before the current statement.
+	pre []ast.Node
+
+	// err is the first error encountered.
+	err error
+}
+
+// loopFunction is a loop body that is moved into a function.
+type loopFunction struct {
+	// parent is the loop body that contains this one, if they share the same
+	// `this` and `arguments`.
+	parent *loopFunction
+
+	// out maps bindings from the head of the loop that the body assigns to
+	// the variables their values are copied out through.
+	out map[string]string
+
+	// vars contains the names of var declarations in the body, which are
+	// declared outside of the function instead.
+	vars []string
+
+	// thisName and argumentsName are the names of the variables holding the
+	// captured `this` and `arguments`, if any.
+	thisName, argumentsName string
+
+	// loops and switches are the number of statements between the current
+	// node and the body that unlabeled jumps may target; labels contains the
+	// labels in between.
+	loops, switches int
+	labels          map[string]bool
+
+	// returns is set if the body contains return statements, and exits
+	// contains the completions of other jumps that leave the body.
+	returns bool
+	exits   []string
+}
+
+func (l *blockScoping) fail(msg string) {
+	if l.err == nil {
+		l.err = errors.New(msg)
+	}
+}
+
+// enter makes s the current scope, choosing new names for its bindings as
+// needed, and returns a function that restores the previous scope.
+func (l *blockScoping) enter(s *scope.Scope) func() {
+	saved := l.scope
+	l.scope = s
+	if s.Kind == scope.BlockScope {
+		names := []string{}
+		for name, kind := range s.Bindings {
+			if (kind == scope.LetBinding || kind == scope.ConstBinding) && l.conflicts[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if l.renames[s] == nil {
+				l.renames[s] = map[string]string{}
+			}
+			l.renames[s][name] = l.names.generate(name)
+		}
+	}
+	return func() { l.scope = saved }
+}
+
+// rename returns the name that a reference to name in the current scope
+// should use.
+func (l *blockScoping) rename(name string) string {
+	if r, ok := l.renames[l.scope.Lookup(name)][name]; ok {
+		return r
+	}
+	return name
+}
+
+// renamePattern renames the bindings of a lexical declaration.
+func (l *blockScoping) renamePattern(p ast.BindingPattern) ast.BindingPattern {
+	switch {
+	case p.ObjectPattern != nil:
+		o := *p.ObjectPattern
+		o.Properties = append([]ast.BindingProperty(nil), o.Properties...)
+		for i, prop := range o.Properties {
+			if isEmptyBinding(prop.Value) {
+				// Shorthand
+				if r := l.rename(prop.PropertyName); r != prop.PropertyName {
+					prop.Value = ast.BindingPattern{Identifier: r}
+				}
+			} else {
+				prop.Value = l.renamePattern(prop.Value)
+			}
+			o.Properties[i] = prop
+		}
+		if o.RestElement != "" {
+			o.RestElement = l.rename(o.RestElement)
+		}
+		p.ObjectPattern = &o
+
+	case p.ArrayPattern != nil:
+		a := *p.ArrayPattern
+		a.Elements = append([]ast.BindingElement(nil), a.Elements...)
+		for i, e := range a.Elements {
+			if !isEmptyBinding(e.Value) {
+				a.Elements[i].Value = l.renamePattern(e.Value)
+			}
+		}
+		if !isEmptyBinding(a.RestElement) {
+			a.RestElement = l.renamePattern(a.RestElement)
+		}
+		p.ArrayPattern = &a
+
+	default:
+		p.Identifier = l.rename(p.Identifier)
+	}
+	return p
+}
+
+// function visits the parameters and body of a function in scope s.
+func (l *blockScoping) function(s *scope.Scope, arrow bool, visit func()) {
+	savedLoop, savedCapture := l.loop, l.capture
+	l.loop = nil
+	if !arrow {
+		l.capture = nil
+	}
+	restore := l.enter(s)
+	defer func() {
+		restore()
+		l.loop, l.capture = savedLoop, savedCapture
+	}()
+	visit()
+}
+
+// statements visits a statement list, inserting any statements that need to
+// precede each statement.
+func (l *blockScoping) statements(body []ast.Node) []ast.Node {
+	saved := l.pre
+	defer func() { l.pre = saved }()
+
+	out := make([]ast.Node, 0, len(body))
+	for _, stmt := range body {
+		l.pre = nil
+		r := l.visit(stmt)
+		out = append(out, l.pre...)
+		if _, ok := r.(ast.EmptyStatement); ok {
+			// Hoisted declarations without initializers leave nothing behind.
+			if _, ok := stmt.(ast.VariableDeclaration); ok {
+				continue
+			}
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// declaration lowers a variable declaration in statement position or in the
+// head of a for statement. It returns nil if nothing remains of the
+// declaration after hoisting it.
+func (l *blockScoping) declaration(n ast.VariableDeclaration, head bool) ast.Node {
+	n = ast.MapChildren(n, l.visit).(ast.VariableDeclaration)
+	if n.Kind == ast.VarDeclaration {
+		if l.loop != nil {
+			return l.hoist(n)
+		}
+		return n
+	}
+
+	n.Kind = ast.VarDeclaration
+	decls := make([]ast.VariableDeclarator, len(n.Declarations))
+	for i, d := range n.Declarations {
+		d.ID = l.renamePattern(d.ID)
+		if d.Init == nil && !head && l.scope.Kind == scope.BlockScope {
+			// The declaration may run more than once, and each time it
+			// creates a new binding that starts out undefined.
+			d.Init = ast.UnaryExpression{Operator: ast.UnaryVoidOp, Argument: numberLiteral(0)}
+		}
+		decls[i] = d
+	}
+	n.Declarations = decls
+	return n
+}
+
+// hoist moves the bindings of a var declaration out of the current loop body,
+// returning an expression that performs its initializers, or nil if there are
+// none.
+func (l *blockScoping) hoist(n ast.VariableDeclaration) ast.Node {
+	exprs := []ast.Node{}
+	for _, d := range n.Declarations {
+		if d.ID.Identifier == "" {
+			l.fail("destructuring in a loop body with captured bindings can not be hoisted; lower destructuring first")
+			continue
+		}
+		l.loop.vars = append(l.loop.vars, d.ID.Identifier)
+		if d.Init != nil {
+			exprs = append(exprs, ast.AssignmentExpression{
+				Operator: ast.AssignmentOp,
+				Left:     ast.Identifier{Name: d.ID.Identifier},
+				Right:    d.Init,
+			})
+		}
+	}
+	switch len(exprs) {
+	case 0:
+		return nil
+	case 1:
+		return exprs[0]
+	}
+	return ast.SequenceExpression{Expressions: exprs}
+}
+
+// forHead lowers the declaration in the head of a for-in or for-of
+// statement.
+func (l *blockScoping) forHead(n ast.Node) ast.Node {
+	d, ok := n.(ast.VariableDeclaration)
+	if !ok {
+		return l.visit(n)
+	}
+	if d.Kind == ast.VarDeclaration && l.loop != nil {
+		l.hoist(d)
+		return ast.Identifier{Name: d.Declarations[0].ID.Identifier}
+	}
+	return l.declaration(d, true)
+}
+
+// loopStatement lowers a loop, moving its body into a function if closures
+// capture any of the bindings declared in it.
+func (l *blockScoping) loopStatement(n ast.Node) ast.Node {
+	head := scope.New(n, l.scope)
+	if head != nil {
+		defer l.enter(head)()
+	}
+
+	var body ast.Node
+	var params []string
+	switch t := n.(type) {
+	case ast.ForStatement:
+		body = t.Body
+		if d, ok := t.Init.(ast.VariableDeclaration); ok && d.Kind != ast.VarDeclaration {
+			params = declaredNames(d)
+		}
+	case ast.ForInStatement:
+		body = t.Body
+		if d, ok := t.Left.(ast.VariableDeclaration); ok && d.Kind != ast.VarDeclaration {
+			params = declaredNames(d)
+		}
+	case ast.ForOfStatement:
+		body = t.Body
+		if d, ok := t.Left.(ast.VariableDeclaration); ok && d.Kind != ast.VarDeclaration {
+			params = declaredNames(d)
+		}
+	case ast.WhileStatement:
+		body = t.Body
+	case ast.DoWhileStatement:
+		body = t.Body
+	}
+	captured, assigned := analyzeLoop(body, l.scope, head)
+
+	// The head is visited first, so that it is not part of the body.
+	switch t := n.(type) {
+	case ast.ForStatement:
+		if d, ok := t.Init.(ast.VariableDeclaration); ok {
+			if d.Kind == ast.VarDeclaration && l.loop != nil {
+				t.Init = l.hoist(d)
+			} else {
+				t.Init = l.declaration(d, false)
+			}
+		} else if t.Init != nil {
+			t.Init = l.visit(t.Init)
+		}
+		if t.Test != nil {
+			t.Test = l.visit(t.Test)
+		}
+		if t.Update != nil {
+			t.Update = l.visit(t.Update)
+		}
+		n = t
+	case ast.ForInStatement:
+		t.Left = l.forHead(t.Left)
+		t.Right = l.visit(t.Right)
+		n = t
+	case ast.ForOfStatement:
+		t.Left = l.forHead(t.Left)
+		t.Right = l.visit(t.Right)
+		n = t
+	case ast.WhileStatement:
+		t.Test = l.visit(t.Test)
+		n = t
+	case ast.DoWhileStatement:
+		t.Test = l.visit(t.Test)
+		n = t
+	}
+
+	if captured {
+		body = l.wrapLoopBody(body, params, assigned)
+	} else {
+		if l.loop != nil {
+			l.loop.loops++
+			defer func() { l.loop.loops-- }()
+		}
+		body = l.visit(body)
+	}
+
+	switch t := n.(type) {
+	case ast.ForStatement:
+		t.Body = body
+		return t
+	case ast.ForInStatement:
+		t.Body = body
+		return t
+	case ast.ForOfStatement:
+		t.Body = body
+		return t
+	case ast.WhileStatement:
+		t.Body = body
+		return t
+	case ast.DoWhileStatement:
+		t.Body = body
+		return t
+	}
+	return n
+}
+
+// wrapLoopBody moves a loop body into a function, declared before the loop,
+// and returns the new body that calls it. params are the bindings declared in
+// the head of the loop, which are passed in on each iteration.
+func (l *blockScoping) wrapLoopBody(body ast.Node, params []string, assigned map[string]bool) ast.Node {
+	f := &loopFunction{parent: l.capture, out: map[string]string{}, labels: map[string]bool{}}
+	fn := ast.FunctionExpression{Params: ast.FormalParameters{Parameters: []ast.BindingElement{}}}
+	args := []ast.Node{}
+	for i, name := range params {
+		renamed := l.rename(name)
+		params[i] = renamed
+		fn.Params.Parameters = append(fn.Params.Parameters, ast.BindingElement{Value: ast.BindingPattern{Identifier: renamed}})
+		args = append(args, ast.Identifier{Name: renamed})
+		if assigned[name] {
+			f.out[renamed] = l.names.generate(renamed)
+		}
+	}
+
+	outer, outerCapture := l.loop, l.capture
+	l.loop, l.capture = f, f
+	var stmts []ast.Node
+	if b, ok := l.visit(body).(ast.BlockStatement); ok {
+		stmts = b.Body
+	} else {
+		stmts = l.statements([]ast.Node{body})
+	}
+	l.loop, l.capture = outer, outerCapture
+	fn.Body = ast.BlockStatement{Body: append(stmts, f.copyOut()...)}
+
+	// Declare the function and everything it uses before the loop.
+	decl := ast.VariableDeclaration{Kind: ast.VarDeclaration}
+	if outer != nil {
+		outer.vars = append(outer.vars, f.vars...)
+	} else {
+		for _, name := range f.vars {
+			decl.Declarations = append(decl.Declarations, varDecl(name, nil))
+		}
+	}
+	if f.thisName != "" {
+		decl.Declarations = append(decl.Declarations, varDecl(f.thisName, ast.ThisExpression{}))
+	}
+	if f.argumentsName != "" {
+		decl.Declarations = append(decl.Declarations, varDecl(f.argumentsName, ast.Identifier{Name: "arguments"}))
+	}
+	for _, name := range params {
+		if out, ok := f.out[name]; ok {
+			decl.Declarations = append(decl.Declarations, varDecl(out, nil))
+		}
+	}
+	loopName := l.names.generate("loop")
+	decl.Declarations = append(decl.Declarations, varDecl(loopName, fn))
+	l.pre = append(l.pre, decl)
+
+	// Call the function, copy out the bindings it assigned, and complete any
+	// jumps that it returned.
+	result := call(ast.Identifier{Name: loopName}, args...)
+	stmts = []ast.Node{}
+	var ret ast.Identifier
+	if f.returns || len(f.exits) > 0 {
+		ret = ast.Identifier{Name: l.names.generate("ret")}
+		stmts = append(stmts, ast.VariableDeclaration{
+			Kind:         ast.VarDeclaration,
+			Declarations: []ast.VariableDeclarator{varDecl(ret.Name, result)},
+		})
+	} else {
+		stmts = append(stmts, ast.ExpressionStatement{Expression: result})
+	}
+	for _, name := range params {
+		if out, ok := f.out[name]; ok {
+			stmts = append(stmts, ast.ExpressionStatement{Expression: ast.AssignmentExpression{
+				Operator: ast.AssignmentOp,
+				Left:     ast.Identifier{Name: name},
+				Right:    ast.Identifier{Name: out},
+			}})
+		}
+	}
+	checks := []ast.Node{}
+	if f.returns {
+		checks = append(checks, ast.IfStatement{
+			Test: ast.BinaryExpression{
+				Operator: ast.BinaryStrictEqualOp,
+				Left:     ast.UnaryExpression{Operator: ast.UnaryTypeOfOp, Argument: ret},
+				Right:    stringLiteral("object"),
+			},
+			Consequent: ast.ReturnStatement{Argument: member(ret, "v")},
+		})
+	}
+	for _, exit := range f.exits {
+		var jump ast.Node
+		switch {
+		case exit == "break":
+			jump = ast.BreakStatement{}
+		case exit[:len("break|")] == "break|":
+			jump = ast.BreakStatement{Label: exit[len("break|"):]}
+		default:
+			jump = ast.ContinueStatement{Label: exit[len("continue|"):]}
+		}
+		checks = append(checks, ast.IfStatement{
+			Test:       ast.BinaryExpression{Operator: ast.BinaryStrictEqualOp, Left: ret, Right: stringLiteral(exit)},
+			Consequent: jump,
+		})
+	}
+
+	if outer != nil {
+		// The jumps may leave the outer loop body too.
+		outer.loops++
+		checks = l.statements(checks)
+		outer.loops--
+	}
+	return ast.BlockStatement{Body: append(stmts, checks...)}
+}
+
+// copyOut returns statements that copy the bindings from the head of the loop
+// that the body assigns out of the function.
+func (f *loopFunction) copyOut() []ast.Node {
+	names := []string{}
+	for name := range f.out {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	stmts := []ast.Node{}
+	for _, name := range names {
+		stmts = append(stmts, ast.ExpressionStatement{Expression: ast.AssignmentExpression{
+			Operator: ast.AssignmentOp,
+			Left:     ast.Identifier{Name: f.out[name]},
+			Right:    ast.Identifier{Name: name},
+		}})
+	}
+	return stmts
+}
+
+// jump lowers a break or continue statement inside of a loop body that is
+// moved into a function.
+func (l *blockScoping) jump(n ast.Node, kind, label string) ast.Node {
+	f := l.loop
+	switch {
+	case label != "" && f.labels[label]:
+		return n
+	case label == "" && (f.loops > 0 || (kind == "break" && f.switches > 0)):
+		return n
+	}
+
+	var ret ast.ReturnStatement
+	if kind != "continue" || label != "" {
+		exit := kind
+		if label != "" {
+			exit += "|" + label
+		}
+		ret.Argument = stringLiteral(exit)
+		found := false
+		for _, e := range f.exits {
+			found = found || e == exit
+		}
+		if !found {
+			f.exits = append(f.exits, exit)
+		}
+	}
+	stmts := f.copyOut()
+	if len(stmts) == 0 {
+		return ret
+	}
+	return ast.BlockStatement{Body: append(stmts, ret)}
+}
+
+// captureRoot returns the outermost loop body that shares `this` and
+// `arguments` with the current one.
+func (l *blockScoping) captureRoot() *loopFunction {
+	f := l.capture
+	for f.parent != nil {
+		f = f.parent
+	}
+	return f
+}
+
+// identifier returns the replacement for an identifier reference.
+func (l *blockScoping) identifier(n ast.Identifier) ast.Node {
+	if n.Name == "arguments" && l.capture != nil && l.scope.Lookup(n.Name) == nil {
+		f := l.captureRoot()
+		if f.argumentsName == "" {
+			f.argumentsName = l.names.generate("arguments")
+		}
+		return ast.Identifier{Name: f.argumentsName}
+	}
+	n.Name = l.rename(n.Name)
+	return n
+}
+
+func (l *blockScoping) visit(n ast.Node) ast.Node {
+	switch t := n.(type) {
+	case ast.ScriptNode:
+		l.function(scope.New(t, nil), false, func() { t.Body = l.statements(t.Body) })
+		return t
+
+	case ast.ModuleNode:
+		l.function(scope.New(t, nil), false, func() { t.Body = l.statements(t.Body) })
+		return t
+
+	case ast.FunctionDeclaration:
+		l.function(scope.New(t, l.scope), false, func() {
+			t.Params = mapParams(t.Params, l.visit)
+			t.Body.Body = l.statements(t.Body.Body)
+		})
+		return t
+
+	case ast.FunctionExpression:
+		l.function(scope.New(t, l.scope), t.Arrow, func() {
+			t.Params = mapParams(t.Params, l.visit)
+			if b, ok := t.Body.(ast.BlockStatement); ok {
+				b.Body = l.statements(b.Body)
+				t.Body = b
+			} else {
+				t.Body = l.visit(t.Body)
+			}
+		})
+		return t
+
+	case ast.BlockStatement:
+		defer l.enter(scope.New(t, l.scope))()
+		t.Body = l.statements(t.Body)
+		return t
+
+	case ast.SwitchStatement:
+		t.Discriminant = l.visit(t.Discriminant)
+		defer l.enter(scope.New(t, l.scope))()
+		if l.loop != nil {
+			l.loop.switches++
+			defer func() { l.loop.switches-- }()
+		}
+		cases := make([]ast.SwitchCase, len(t.Cases))
+		for i, c := range t.Cases {
+			if c.Test != nil {
+				c.Test = l.visit(c.Test)
+			}
+			c.Consequent = l.statements(c.Consequent)
+			cases[i] = c
+		}
+		t.Cases = cases
+		return t
+
+	case ast.VariableDeclaration:
+		if d := l.declaration(t, false); d != nil {
+			if e, ok := d.(ast.VariableDeclaration); ok {
+				return e
+			}
+			return ast.ExpressionStatement{Expression: d}
+		}
+		return ast.EmptyStatement{}
+
+	case ast.ForStatement, ast.ForInStatement, ast.ForOfStatement, ast.WhileStatement, ast.DoWhileStatement:
+		return l.loopStatement(n)
+
+	case ast.LabeledStatement:
+		if l.loop != nil {
+			l.loop.labels[t.Label] = true
+			defer delete(l.loop.labels, t.Label)
+		}
+
+	case ast.BreakStatement:
+		if l.loop != nil {
+			return l.jump(t, "break", t.Label)
+		}
+
+	case ast.ContinueStatement:
+		if l.loop != nil {
+			return l.jump(t, "continue", t.Label)
+		}
+
+	case ast.ReturnStatement:
+		if l.loop != nil {
+			l.loop.returns = true
+			var value ast.Node = ast.UnaryExpression{Operator: ast.UnaryVoidOp, Argument: numberLiteral(0)}
+			if t.Argument != nil {
+				value = l.visit(t.Argument)
+			}
+			t.Argument = ast.ObjectExpression{Properties: []ast.Property{{
+				Kind:  ast.InitProperty,
+				Key:   ast.Identifier{Name: "v"},
+				Value: value,
+			}}}
+			return t
+		}
+
+	case ast.ThisExpression:
+		if l.capture != nil {
+			f := l.captureRoot()
+			if f.thisName == "" {
+				f.thisName = l.names.generate("this")
+			}
+			return ast.Identifier{Name: f.thisName}
+		}
+
+	case ast.Identifier:
+		return l.identifier(t)
+
+	case ast.MethodDefinition:
+		if t.Computed {
+			t.Key = l.visit(t.Key)
+		}
+		t.Value = l.visit(t.Value).(ast.FunctionExpression)
+		return t
+
+	case ast.AccessorProperty:
+		if t.Computed {
+			t.Key = l.visit(t.Key)
+		}
+		t.Value = l.visit(t.Value)
+		return t
+
+	case ast.MemberExpression:
+		t.Object = l.visit(t.Object)
+		if t.Computed {
+			t.Property = l.visit(t.Property)
+		}
+		return t
+
+	case ast.ObjectExpression:
+		props := make([]ast.Property, len(t.Properties))
+		for i, p := range t.Properties {
+			if p.Computed {
+				p.Key = l.visit(p.Key)
+			}
+			if p.Value != nil {
+				p.Value = l.visit(p.Value)
+			} else if id, ok := p.Key.(ast.Identifier); ok {
+				// Expand shorthand properties whose value is replaced.
+				if r, ok := l.identifier(id).(ast.Identifier); ok && r.Name != id.Name {
+					p.Value = r
+				}
+			}
+			if p.DestructureInit != nil {
+				p.DestructureInit = l.visit(p.DestructureInit)
+			}
+			props[i] = p
+		}
+		t.Properties = props
+		return t
+	}
+
+	if s := scope.New(n, l.scope); s != nil {
+		defer l.enter(s)()
+	}
+	return ast.MapChildren(n, l.visit)
+}
+
+// analyzeLoop finds whether closures in the body of a loop capture any of the
+// lexical bindings declared in the loop, and which of the bindings in the
+// head of the loop the body assigns to. s is the scope of the body, and head
+// is the scope of the head, if the loop has one.
+func analyzeLoop(body ast.Node, s, head *scope.Scope) (captured bool, assigned map[string]bool) {
+	assigned = map[string]bool{}
+	// Bindings in nested loops are the concern of those loops.
+	owned := map[*scope.Scope]bool{head: head != nil}
+	w := scope.Walker{
+		Scope: s,
+		OnScope: func(w *scope.Walker, s *scope.Scope) {
+			if w.Functions == 0 && w.Loops == 0 {
+				owned[s] = true
+			}
+		},
+		OnRef: func(w *scope.Walker, id ast.Identifier, assign bool) {
+			name := id.Name
+			d := w.Scope.Lookup(name)
+			if !owned[d] {
+				return
+			}
+			if kind := d.Bindings[name]; kind != scope.LetBinding && kind != scope.ConstBinding {
+				return
+			}
+			if w.Functions > 0 {
+				captured = true
+			} else if assign && d == head {
+				assigned[name] = true
+			}
+		},
+	}
+	w.Walk(body)
+	return captured, assigned
+}
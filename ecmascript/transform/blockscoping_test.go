@@ -0,0 +1,140 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+func TestLowerBlockScoping(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+		mode                  parser.ParseMode
+	}{
+		{
+			name:     "declarations",
+			input:    `let a = 1; const b = 2; function f() { let c; return c; }`,
+			expected: `var a = 1; var b = 2; function f() { var c; return c; }`,
+		},
+		{
+			name:     "shadowed binding",
+			input:    `let a = 1; { let a = 2; f(a); } f(a);`,
+			expected: `var a = 1; { var _a = 2; f(_a); } f(a);`,
+		},
+		{
+			name:     "global reference",
+			input:    `{ let x = 1; } f(x);`,
+			expected: `{ var _x = 1; } f(x);`,
+		},
+		{
+			name:     "uninitialized binding",
+			input:    `for (;;) { let a; a = f(a); }`,
+			expected: `for (;;) { var a = void 0; a = f(a); }`,
+		},
+		{
+			name:     "patterns",
+			input:    `let x; { let {x, y: [z]} = o; f({x, z}); }`,
+			expected: `var x; { var {x: _x, y: [z]} = o; f({x: _x, z}); }`,
+		},
+		{
+			name:  "captured loop binding",
+			input: `for (let i = 0; i < 3; i++) { fns.push(function () { return i; }); }`,
+			expected: `var _loop = function (i) { fns.push(function () { return i; }); };
+				for (var i = 0; i < 3; i++) { _loop(i); }`,
+		},
+		{
+			name: "jumps",
+			input: `function f() {
+				outer: for (let i = 0; i < 3; i++) {
+					for (let j = 0; ; j++) { if (j) break; }
+					if (i === 1) continue;
+					if (i === 2) break outer;
+					g(() => i);
+					if (h()) return i;
+					if (k()) break;
+				}
+			}`,
+			expected: `function f() {
+				var _loop = function (i) {
+					for (var j = 0; ; j++) { if (j) break; }
+					if (i === 1) return;
+					if (i === 2) return "break|outer";
+					g(() => i);
+					if (h()) return {v: i};
+					if (k()) return "break";
+				};
+				outer: for (var i = 0; i < 3; i++) {
+					var _ret = _loop(i);
+					if (typeof _ret === "object") return _ret.v;
+					if (_ret === "break|outer") break outer;
+					if (_ret === "break") break;
+				}
+			}`,
+		},
+		{
+			name: "assignments, this and arguments",
+			input: `function f() {
+				for (let i = 0; i < 3; i++) {
+					var x = this;
+					g(() => i + arguments[0]);
+					if (x) { i++; continue; }
+				}
+			}`,
+			expected: `function f() {
+				var x, _this = this, _arguments = arguments, _i, _loop = function (i) {
+					x = _this;
+					g(() => i + _arguments[0]);
+					if (x) { i++; { _i = i; return; } }
+					_i = i;
+				};
+				for (var i = 0; i < 3; i++) { _loop(i); i = _i; }
+			}`,
+		},
+		{
+			name:  "for-of and while",
+			input: `for (const x of xs) fns.push(() => x); while (a) { let y = a.pop(); fns.push(() => y); }`,
+			expected: `var _loop = function (x) { fns.push(() => x); };
+				for (var x of xs) { _loop(x); }
+				var _loop2 = function () { var y = a.pop(); fns.push(() => y); };
+				while (a) { _loop2(); }`,
+		},
+		{
+			name:  "nested loops",
+			input: `for (let i = 0; i < 2; i++) { for (let j = 0; j < 2; j++) { fns.push(() => i + j); if (j) continue; } }`,
+			expected: `var _loop2 = function (i) {
+					var _loop = function (j) { fns.push(() => i + j); if (j) return; };
+					for (var j = 0; j < 2; j++) { _loop(j); }
+				};
+				for (var i = 0; i < 2; i++) { _loop2(i); }`,
+		},
+		{
+			name:     "exports",
+			input:    `export let a = 1;`,
+			expected: `export var a = 1;`,
+			mode:     parser.ModuleMode,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := LowerBlockScoping(parse(t, test.input, test.mode))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertESTree(t, parse(t, test.expected, test.mode), result)
+		})
+	}
+}
+
+func TestLowerBlockScopingHoistedPattern(t *testing.T) {
+	if _, err := LowerBlockScoping(parseScript(t, `for (let i of xs) { var [a] = i; f(() => i); }`)); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestLowerBlockScopingRequiresProgram(t *testing.T) {
+	if _, err := LowerBlockScoping(ast.BlockStatement{}); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
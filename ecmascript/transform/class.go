@@ -0,0 +1,347 @@
+package transform
+
+import (
+	"errors"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// LowerClasses rewrites class declarations and expressions into constructor
+// functions.
+//
+// Each class becomes an immediately invoked function that declares the
+// constructor, assigns methods to the prototype (or to the constructor, for
+// static methods) and defines accessors using Object.defineProperty. Uses of
+// super refer to the superclass directly. The output is loose in the same ways
+// as other ES5 class transforms: methods are enumerable, the constructor can
+// be called without new, accessors reached through super see the superclass
+// prototype as this, and subclassing builtins such as Array does not work.
+//
+// Arrow functions inside of methods may use super, so classes should be
+// lowered before arrow functions are.
+//
+// Auto-accessors are not supported, and are reported as an error.
+func LowerClasses(n ast.Node) (ast.Node, error) {
+	accessor := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		_, ok := n.(ast.AccessorProperty)
+		accessor = accessor || ok
+		return !accessor
+	})
+	if accessor {
+		return nil, errors.New("class transform does not support auto-accessors")
+	}
+	names := newNameGenerator(n)
+	l := classLowering{names: names, helpers: newHelperSet(names, classHelpers)}
+	switch t := n.(type) {
+	case ast.ScriptNode:
+		l.strict = isStrict(t.Body)
+		t.Body = prependStatements(l.statements(t.Body), l.helpers.decls...)
+		return t, nil
+
+	case ast.ModuleNode:
+		l.strict = true
+		t.Body = prependStatements(l.statements(t.Body), l.helpers.decls...)
+		return t, nil
+	}
+	return nil, errors.New("class transform requires a script or module")
+}
+
+// classHelpers contains the source of the runtime helpers.
+var classHelpers = map[string]string{
+	"inherits": `
+		function NAME(subClass, superClass) {
+			if (typeof superClass !== "function" && superClass !== null) throw new TypeError("Class extends value " + superClass + " is not a constructor or null");
+			subClass.prototype = Object.create(superClass && superClass.prototype, { constructor: { value: subClass, writable: true, configurable: true } });
+			if (superClass) Object.setPrototypeOf ? Object.setPrototypeOf(subClass, superClass) : subClass.__proto__ = superClass;
+		}
+	`,
+}
+
+type classLowering struct {
+	names   *nameGenerator
+	helpers *helperSet
+
+	// home is the method currently being visited, which super refers to. It
+	// is nil outside of class methods.
+	home *classHome
+
+	// strict is set when the current code is strict mode code. Class bodies
+	// are always strict, so they are marked as such when they are lowered
+	// into non-strict code.
+	strict bool
+}
+
+// classHome describes the method that super references are resolved from.
+type classHome struct {
+	// superClass is the identifier holding the superclass, or nil if the
+	// class does not have one.
+	superClass ast.Node
+
+	static bool
+}
+
+// target returns the object that super property references look up.
+func (h *classHome) target() ast.Node {
+	switch {
+	case h.superClass == nil && h.static:
+		return member(ast.Identifier{Name: "Function"}, "prototype")
+	case h.superClass == nil:
+		return member(ast.Identifier{Name: "Object"}, "prototype")
+	case h.static:
+		return h.superClass
+	}
+	return member(h.superClass, "prototype")
+}
+
+// classMember is a property that a class defines.
+type classMember struct {
+	static   bool
+	key      ast.Node
+	computed bool
+
+	// value is set for methods; get and set are set for accessors.
+	value, get, set ast.Node
+}
+
+func (l *classLowering) statements(body []ast.Node) []ast.Node {
+	out := make([]ast.Node, 0, len(body))
+	for _, stmt := range body {
+		e, ok := stmt.(ast.ExportDeclNode)
+		if !ok {
+			out = append(out, l.visit(stmt))
+			continue
+		}
+		c, ok := e.Default.(ast.ClassDeclaration)
+		switch {
+		case ok && c.ID == "":
+			e.Default = l.class(c.ID, c.SuperClass, c.Body)
+		case ok:
+			// The class is still declared locally, so it needs a declaration
+			// and a separate export.
+			out = append(out, l.visit(c))
+			e = ast.ExportDeclNode{NamedExports: []ast.NamedExport{{Identifier: c.ID, AsBinding: "default"}}}
+		default:
+			e = l.visit(e).(ast.ExportDeclNode)
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// function visits the parameters and body of a function, which are not part
+// of any method even when the function is inside of one.
+func (l *classLowering) function(params *ast.FormalParameters, body []ast.Node) []ast.Node {
+	savedHome, savedStrict := l.home, l.strict
+	l.home = nil
+	l.strict = l.strict || isStrict(body)
+	defer func() { l.home, l.strict = savedHome, savedStrict }()
+
+	*params = mapParams(*params, l.visit)
+	return l.statements(body)
+}
+
+// method visits a method body with the given home.
+func (l *classLowering) method(fn ast.FunctionExpression, home *classHome) ast.FunctionExpression {
+	saved := l.home
+	l.home = home
+	defer func() { l.home = saved }()
+
+	fn.Params = mapParams(fn.Params, l.visit)
+	b := fn.Body.(ast.BlockStatement)
+	b.Body = l.statements(b.Body)
+	fn.Body = b
+	return fn
+}
+
+// class returns an expression that evaluates to the constructor of a class.
+func (l *classLowering) class(id string, superClass ast.Node, body []ast.Node) ast.Node {
+	name := id
+	if name == "" {
+		name = l.names.generate("class")
+	}
+	ctor := ast.Identifier{Name: name}
+	ctorDecl := ast.FunctionDeclaration{ID: name, Body: ast.BlockStatement{Body: []ast.Node{}}}
+
+	wrapper := ast.FunctionExpression{}
+	args := []ast.Node{}
+	stmts := []ast.Node{}
+	if !l.strict {
+		stmts = append(stmts, ast.ExpressionStatement{Expression: stringLiteral("use strict"), Directive: "use strict"})
+	}
+
+	var super ast.Node
+	if superClass != nil {
+		s := l.names.generate("super")
+		super = ast.Identifier{Name: s}
+		wrapper.Params.Parameters = []ast.BindingElement{{Value: ast.BindingPattern{Identifier: s}}}
+		args = append(args, l.visit(superClass))
+		stmts = append(stmts, ast.ExpressionStatement{Expression: call(l.helpers.get("inherits"), ctor, super)})
+
+		// The default constructor of a derived class passes its arguments on.
+		ctorDecl.Body.Body = append(ctorDecl.Body.Body, ast.ExpressionStatement{
+			Expression: call(member(super, "apply"), ast.ThisExpression{}, ast.Identifier{Name: "arguments"}),
+		})
+	}
+
+	savedStrict := l.strict
+	l.strict = true
+	defer func() { l.strict = savedStrict }()
+
+	members := []*classMember{}
+	accessors := map[bool]map[string]*classMember{false: {}, true: {}}
+	for _, e := range body {
+		m := e.(ast.MethodDefinition)
+		home := &classHome{superClass: super, static: m.Static}
+		if m.Kind == ast.ConstructorMethod {
+			fn := l.method(m.Value, home)
+			ctorDecl.Params = fn.Params
+			ctorDecl.Body = fn.Body.(ast.BlockStatement)
+			continue
+		}
+
+		key := m.Key
+		if m.Computed {
+			key = l.visit(key)
+		}
+		fn := l.method(m.Value, home)
+		if m.Kind == ast.Method {
+			members = append(members, &classMember{static: m.Static, key: key, computed: m.Computed, value: fn})
+			continue
+		}
+
+		// Non-computed getters and setters for the same property share a
+		// single property definition.
+		var a *classMember
+		if id, ok := key.(ast.Identifier); ok && !m.Computed {
+			a = accessors[m.Static][id.Name]
+			if a == nil {
+				a = &classMember{static: m.Static, key: stringLiteral(id.Name)}
+				accessors[m.Static][id.Name] = a
+				members = append(members, a)
+			}
+		} else {
+			a = &classMember{static: m.Static, key: key}
+			members = append(members, a)
+		}
+		if m.Kind == ast.GetMethod {
+			a.get = fn
+		} else {
+			a.set = fn
+		}
+	}
+
+	stmts = append(stmts, ctorDecl)
+	for _, m := range members {
+		var target ast.Node = member(ctor, "prototype")
+		if m.static {
+			target = ctor
+		}
+		if m.value != nil {
+			stmts = append(stmts, ast.ExpressionStatement{Expression: ast.AssignmentExpression{
+				Operator: ast.AssignmentOp,
+				Left:     ast.MemberExpression{Object: target, Property: m.key, Computed: m.computed},
+				Right:    m.value,
+			}})
+			continue
+		}
+		desc := ast.ObjectExpression{}
+		if m.get != nil {
+			desc.Properties = append(desc.Properties, ast.Property{Kind: ast.InitProperty, Key: ast.Identifier{Name: "get"}, Value: m.get})
+		}
+		if m.set != nil {
+			desc.Properties = append(desc.Properties, ast.Property{Kind: ast.InitProperty, Key: ast.Identifier{Name: "set"}, Value: m.set})
+		}
+		desc.Properties = append(desc.Properties, ast.Property{
+			Kind:  ast.InitProperty,
+			Key:   ast.Identifier{Name: "configurable"},
+			Value: ast.BooleanLiteral{Value: true, Raw: "true"},
+		})
+		stmts = append(stmts, ast.ExpressionStatement{
+			Expression: call(member(ast.Identifier{Name: "Object"}, "defineProperty"), target, m.key, desc),
+		})
+	}
+	stmts = append(stmts, ast.ReturnStatement{Argument: ctor})
+
+	wrapper.Body = ast.BlockStatement{Body: stmts}
+	return call(wrapper, args...)
+}
+
+// superMember returns the replacement for a super property reference.
+func (l *classLowering) superMember(n ast.MemberExpression) ast.Node {
+	n.Object = l.home.target()
+	if n.Computed {
+		n.Property = l.visit(n.Property)
+	}
+	return n
+}
+
+func (l *classLowering) visit(n ast.Node) ast.Node {
+	switch t := n.(type) {
+	case ast.FunctionDeclaration:
+		t.Body.Body = l.function(&t.Params, t.Body.Body)
+		return t
+
+	case ast.FunctionExpression:
+		if t.Arrow {
+			break
+		}
+		b := t.Body.(ast.BlockStatement)
+		b.Body = l.function(&t.Params, b.Body)
+		t.Body = b
+		return t
+
+	case ast.ClassDeclaration:
+		d := ast.VariableDeclaration{
+			Kind:         ast.VarDeclaration,
+			Declarations: []ast.VariableDeclarator{varDecl(t.ID, l.class(t.ID, t.SuperClass, t.Body))},
+		}
+		d.SetStart(t.Span().Start)
+		d.SetEnd(t.Span().End)
+		return d
+
+	case ast.ClassExpression:
+		return l.class(t.ID, t.SuperClass, t.Body)
+
+	case ast.CallExpression:
+		if l.home == nil {
+			break
+		}
+		args := make([]ast.Node, len(t.Arguments))
+		for i, a := range t.Arguments {
+			args[i] = l.visit(a)
+		}
+		switch callee := t.Callee.(type) {
+		case ast.Super:
+			if l.home.superClass == nil {
+				break
+			}
+			return call(member(l.home.superClass, "call"), append([]ast.Node{ast.ThisExpression{}}, args...)...)
+		case ast.MemberExpression:
+			if _, ok := callee.Object.(ast.Super); ok {
+				// Methods looked up on super are called with the current this.
+				return call(member(l.superMember(callee), "call"), append([]ast.Node{ast.ThisExpression{}}, args...)...)
+			}
+		}
+
+	case ast.AssignmentExpression:
+		if l.home == nil {
+			break
+		}
+		if m, ok := t.Left.(ast.MemberExpression); ok {
+			if _, ok := m.Object.(ast.Super); ok {
+				// Assigning to a super property sets it on this.
+				m.Object = ast.ThisExpression{}
+				t.Left = ast.MapChildren(m, l.visit)
+				t.Right = l.visit(t.Right)
+				return t
+			}
+		}
+
+	case ast.MemberExpression:
+		if _, ok := t.Object.(ast.Super); ok && l.home != nil {
+			return l.superMember(t)
+		}
+	}
+	return ast.MapChildren(n, l.visit)
+}
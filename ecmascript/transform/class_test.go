@@ -0,0 +1,122 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+func TestLowerClasses(t *testing.T) {
+	inherits := `function _inherits(subClass, superClass) {
+		if (typeof superClass !== "function" && superClass !== null) throw new TypeError("Class extends value " + superClass + " is not a constructor or null");
+		subClass.prototype = Object.create(superClass && superClass.prototype, { constructor: { value: subClass, writable: true, configurable: true } });
+		if (superClass) Object.setPrototypeOf ? Object.setPrototypeOf(subClass, superClass) : subClass.__proto__ = superClass;
+	}`
+
+	tests := []struct {
+		name, input, expected string
+		mode                  parser.ParseMode
+	}{
+		{
+			name:     "empty class",
+			input:    `class A {}`,
+			expected: `var A = (function () { "use strict"; function A() {} return A; })();`,
+		},
+		{
+			name:     "strict mode",
+			input:    `"use strict"; class A {}`,
+			expected: `"use strict"; var A = (function () { function A() {} return A; })();`,
+		},
+		{
+			name:  "constructor and methods",
+			input: `"use strict"; class A { constructor(a) { this.a = a; } m() { return this.a; } static s() {} [k]() {} }`,
+			expected: `"use strict"; var A = (function () {
+				function A(a) { this.a = a; }
+				A.prototype.m = function () { return this.a; };
+				A.s = function () {};
+				A.prototype[k] = function () {};
+				return A;
+			})();`,
+		},
+		{
+			name:  "accessors",
+			input: `"use strict"; class A { get a() { return 1; } static set a(v) {} set a(v) {} get [b]() {} }`,
+			expected: `"use strict"; var A = (function () {
+				function A() {}
+				Object.defineProperty(A.prototype, "a", { get: function () { return 1; }, set: function (v) {}, configurable: true });
+				Object.defineProperty(A, "a", { set: function (v) {}, configurable: true });
+				Object.defineProperty(A.prototype, b, { get: function () {}, configurable: true });
+				return A;
+			})();`,
+		},
+		{
+			name:  "subclass",
+			input: `"use strict"; class A extends B { constructor(x) { super(x); super.x = 1; } m() { return super.m(1) + super[k]; } static s() { return super.s(); } }`,
+			expected: `"use strict"; ` + inherits + ` var A = (function (_super) {
+				_inherits(A, _super);
+				function A(x) { _super.call(this, x); this.x = 1; }
+				A.prototype.m = function () { return _super.prototype.m.call(this, 1) + _super.prototype[k]; };
+				A.s = function () { return _super.s.call(this); };
+				return A;
+			})(B);`,
+		},
+		{
+			name:  "default derived constructor",
+			input: `"use strict"; var A = class extends B {};`,
+			expected: `"use strict"; ` + inherits + ` var A = (function (_super) {
+				_inherits(_class, _super);
+				function _class() { _super.apply(this, arguments); }
+				return _class;
+			})(B);`,
+		},
+		{
+			name:     "super in arrow function",
+			input:    `"use strict"; class A extends B { m() { return () => super.m(); } }`,
+			expected: `"use strict"; ` + inherits + ` var A = (function (_super) { _inherits(A, _super); function A() { _super.apply(this, arguments); } A.prototype.m = function () { return () => _super.prototype.m.call(this); }; return A; })(B);`,
+		},
+		{
+			name:     "super in nested function",
+			input:    `"use strict"; class A { m() { return { m() { return super.m(); } }; } }`,
+			expected: `"use strict"; var A = (function () { function A() {} A.prototype.m = function () { return { m() { return super.m(); } }; }; return A; })();`,
+		},
+		{
+			name:     "nested classes",
+			input:    `"use strict"; class A extends B { m() { return class extends super.m {}; } }`,
+			expected: `"use strict"; ` + inherits + ` var A = (function (_super) { _inherits(A, _super); function A() { _super.apply(this, arguments); } A.prototype.m = function () { return (function (_super2) { _inherits(_class, _super2); function _class() { _super2.apply(this, arguments); } return _class; })(_super.prototype.m); }; return A; })(B);`,
+		},
+		{
+			name:     "super without superclass",
+			input:    `"use strict"; class A { m() { return super.m; } static n() { return super.n; } }`,
+			expected: `"use strict"; var A = (function () { function A() {} A.prototype.m = function () { return Object.prototype.m; }; A.n = function () { return Function.prototype.n; }; return A; })();`,
+		},
+		{
+			name:     "exports",
+			input:    `export class A {} export default class B {}`,
+			expected: `export var A = (function () { function A() {} return A; })(); var B = (function () { function B() {} return B; })(); export {B as default};`,
+			mode:     parser.ModuleMode,
+		},
+		{
+			name:     "anonymous default export",
+			input:    `export default class {}`,
+			expected: `export default (function () { function _class() {} return _class; })();`,
+			mode:     parser.ModuleMode,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := LowerClasses(parse(t, test.input, test.mode))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertESTree(t, parse(t, test.expected, test.mode), result)
+		})
+	}
+}
+
+func TestLowerClassesRequiresProgram(t *testing.T) {
+	if _, err := LowerClasses(ast.ClassExpression{}); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
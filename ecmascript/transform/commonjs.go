@@ -0,0 +1,419 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/scope"
+)
+
+// ToCommonJS rewrites a module into a CommonJS script. Imports become calls
+// to require and exports become getters on the exports object, marked with
+// __esModule so that interop with other transpiled modules works.
+//
+// Both exports and imports are live bindings. References to an imported
+// binding become reads from the required module each time they are evaluated,
+// so they see later changes to the export, and cyclic dependencies see values
+// once they are initialized. Assigning to an imported binding is reported as
+// an error.
+func ToCommonJS(n ast.Node) (ast.Node, error) {
+	m, ok := n.(ast.ModuleNode)
+	if !ok {
+		return nil, errors.New("commonjs transform requires a module")
+	}
+	if err := checkImportAssignments(m); err != nil {
+		return nil, err
+	}
+	names := newNameGenerator(m)
+	c := commonJS{
+		names:   names,
+		modules: map[string]string{},
+		imports: map[string]ast.Node{},
+		helpers: newHelperSet(names, commonJSHelpers),
+	}
+
+	// Imports are hoisted, so they are known before any reference to them.
+	for _, stmt := range m.Body {
+		if t, ok := stmt.(ast.ImportDeclNode); ok {
+			c.importDecl(t)
+		}
+	}
+	body := []ast.Node{}
+	for _, stmt := range m.Body {
+		switch t := stmt.(type) {
+		case ast.ImportDeclNode:
+		case ast.ExportDeclNode:
+			body = append(body, c.exportDecl(t)...)
+		default:
+			body = append(body, replaceThis(stmt))
+		}
+	}
+
+	r := importRefs{imports: c.imports, module: scope.New(m, nil)}
+	r.scope = r.module
+	c.exports = r.statements(c.exports)
+	body = r.statements(body)
+
+	s := ast.ScriptNode{}
+	s.SetStart(m.Span().Start)
+	s.SetEnd(m.Span().End)
+	s.Body = append(s.Body, ast.ExpressionStatement{
+		Expression: stringLiteral("use strict"),
+		Directive:  "use strict",
+	})
+	s.Body = append(s.Body, template(`Object.defineProperty(exports, "__esModule", { value: true });`, nil)...)
+	s.Body = append(s.Body, c.exports...)
+	s.Body = append(s.Body, c.helpers.decls...)
+	s.Body = append(s.Body, c.requires...)
+	s.Body = append(s.Body, body...)
+	return s, nil
+}
+
+type commonJS struct {
+	names *nameGenerator
+
+	// modules maps module specifiers to the variable holding the result of
+	// requiring them.
+	modules map[string]string
+
+	// imports maps imported bindings to the expressions that read them.
+	imports map[string]ast.Node
+
+	helpers *helperSet
+
+	// requires contains hoisted require calls and namespace objects.
+	requires []ast.Node
+
+	// exports contains hoisted export getter definitions.
+	exports []ast.Node
+}
+
+// commonJSHelpers contains the source of the runtime helpers.
+var commonJSHelpers = map[string]string{
+	"interopRequireDefault": `
+		function NAME(obj) {
+			return obj && obj.__esModule ? obj : { default: obj };
+		}
+	`,
+	"interopRequireWildcard": `
+		function NAME(obj) {
+			if (obj && obj.__esModule) return obj;
+			var ns = {};
+			if (obj != null) for (var key in obj) if (key !== "default" && Object.prototype.hasOwnProperty.call(obj, key)) ns[key] = obj[key];
+			ns.default = obj;
+			return ns;
+		}
+	`,
+	"exportStar": `
+		function NAME(from, to) {
+			Object.keys(from).forEach(function (key) {
+				if (key === "default" || key === "__esModule" || Object.prototype.hasOwnProperty.call(to, key)) return;
+				Object.defineProperty(to, key, { enumerable: true, get: function () { return from[key]; } });
+			});
+		}
+	`,
+}
+
+// require returns an identifier for the variable holding the given module,
+// emitting the require call the first time it is used.
+func (c *commonJS) require(module string) ast.Identifier {
+	if id, ok := c.modules[module]; ok {
+		return ast.Identifier{Name: id}
+	}
+	id := c.names.generate(module)
+	c.modules[module] = id
+	c.requires = append(c.requires, ast.VariableDeclaration{
+		Kind:         ast.VarDeclaration,
+		Declarations: []ast.VariableDeclarator{varDecl(id, call(ast.Identifier{Name: "require"}, stringLiteral(module)))},
+	})
+	return ast.Identifier{Name: id}
+}
+
+// importedName returns an expression that reads an export of a module.
+func (c *commonJS) importedName(module ast.Identifier, name string, quoted bool) ast.Node {
+	switch {
+	case name == "default":
+		return member(call(c.helpers.get("interopRequireDefault"), module), "default")
+	case quoted:
+		// The name may not be an identifier, so index the module by it.
+		return ast.MemberExpression{Object: module, Property: stringLiteral(name), Computed: true}
+	}
+	return member(module, name)
+}
+
+func (c *commonJS) importDecl(n ast.ImportDeclNode) {
+	if n.DefaultBinding == nil && n.NameSpace == nil && n.NamedImports == nil {
+		// Import for side effects only; no variable is needed unless another
+		// import of the module already created one.
+		if _, ok := c.modules[n.Module]; !ok {
+			c.requires = append(c.requires, ast.ExpressionStatement{
+				Expression: call(ast.Identifier{Name: "require"}, stringLiteral(n.Module)),
+			})
+		}
+		return
+	}
+	m := c.require(n.Module)
+	if n.DefaultBinding != nil {
+		c.imports[n.DefaultBinding.Identifier] = c.importedName(m, "default", false)
+	}
+	if n.NameSpace != nil {
+		// The namespace object itself never changes, so it can be stored.
+		c.requires = append(c.requires, ast.VariableDeclaration{
+			Kind:         ast.VarDeclaration,
+			Declarations: []ast.VariableDeclarator{varDecl(n.NameSpace.Identifier, call(c.helpers.get("interopRequireWildcard"), m))},
+		})
+	}
+	for _, i := range n.NamedImports {
+		c.imports[i.Binding()] = c.importedName(m, i.Identifier, i.Quoted)
+	}
+}
+
+// checkImportAssignments returns an error if the module assigns to one of its
+// imported bindings, which are read-only.
+func checkImportAssignments(m ast.ModuleNode) error {
+	var err error
+	w := scope.Walker{
+		OnRef: func(w *scope.Walker, id ast.Identifier, assign bool) {
+			if !assign || err != nil {
+				return
+			}
+			if s := w.Scope.Lookup(id.Name); s != nil && s.Bindings[id.Name] == scope.ImportBinding {
+				err = fmt.Errorf("commonjs transform: assignment to imported binding %s", id.Name)
+			}
+		},
+	}
+	w.Walk(m)
+	return err
+}
+
+// importRefs rewrites references to imported bindings into reads from the
+// required modules.
+type importRefs struct {
+	imports map[string]ast.Node
+	module  *scope.Scope
+	scope   *scope.Scope
+}
+
+// lookup returns the expression that reads the binding id refers to, or nil
+// if it does not refer to an import.
+func (r *importRefs) lookup(id ast.Identifier) ast.Node {
+	if r.scope.Lookup(id.Name) != r.module {
+		return nil
+	}
+	return r.imports[id.Name]
+}
+
+// callee rewrites the callee of a call. An imported function is called
+// without the module object as `this`, as in `(0, _m.f)()`.
+func (r *importRefs) callee(n ast.Node) ast.Node {
+	if id, ok := n.(ast.Identifier); ok {
+		if e := r.lookup(id); e != nil {
+			return ast.ParenthesizedExpression{Expression: ast.SequenceExpression{Expressions: []ast.Node{numberLiteral(0), e}}}
+		}
+	}
+	return r.visit(n)
+}
+
+func (r *importRefs) statements(body []ast.Node) []ast.Node {
+	out := make([]ast.Node, len(body))
+	for i, stmt := range body {
+		out[i] = r.visit(stmt)
+	}
+	return out
+}
+
+func (r *importRefs) visit(n ast.Node) ast.Node {
+	switch t := n.(type) {
+	case ast.Identifier:
+		if e := r.lookup(t); e != nil {
+			return e
+		}
+		return t
+
+	case ast.CallExpression:
+		t.Callee = r.callee(t.Callee)
+		args := make([]ast.Node, len(t.Arguments))
+		for i, arg := range t.Arguments {
+			args[i] = r.visit(arg)
+		}
+		t.Arguments = args
+		return t
+
+	case ast.TaggedTemplateExpression:
+		t.Tag = r.callee(t.Tag)
+		t.Quasi = r.visit(t.Quasi).(ast.TemplateLiteral)
+		return t
+
+	case ast.MethodDefinition:
+		if t.Computed {
+			t.Key = r.visit(t.Key)
+		}
+		t.Value = r.visit(t.Value).(ast.FunctionExpression)
+		return t
+
+	case ast.AccessorProperty:
+		if t.Computed {
+			t.Key = r.visit(t.Key)
+		}
+		if t.Value != nil {
+			t.Value = r.visit(t.Value)
+		}
+		return t
+
+	case ast.MemberExpression:
+		t.Object = r.visit(t.Object)
+		if t.Computed {
+			t.Property = r.visit(t.Property)
+		}
+		return t
+
+	case ast.ObjectExpression:
+		props := make([]ast.Property, len(t.Properties))
+		for i, p := range t.Properties {
+			if p.Computed {
+				p.Key = r.visit(p.Key)
+			}
+			if p.Value != nil {
+				p.Value = r.visit(p.Value)
+			} else if id, ok := p.Key.(ast.Identifier); ok {
+				// Expand shorthand properties that refer to imports.
+				if e := r.lookup(id); e != nil {
+					p.Value = e
+				}
+			}
+			if p.DestructureInit != nil {
+				p.DestructureInit = r.visit(p.DestructureInit)
+			}
+			props[i] = p
+		}
+		t.Properties = props
+		return t
+	}
+
+	if s := scope.New(n, r.scope); s != nil {
+		saved := r.scope
+		r.scope = s
+		defer func() { r.scope = saved }()
+	}
+	return ast.MapChildren(n, r.visit)
+}
+
+// export defines a getter on the exports object.
+func (c *commonJS) export(name string, value ast.Node) {
+	c.exports = append(c.exports, template(`
+		Object.defineProperty(exports, NAME, { enumerable: true, get: function () { return VALUE; } });
+	`, map[string]ast.Node{
+		"NAME":  stringLiteral(name),
+		"VALUE": value,
+	})...)
+}
+
+// exportDecl handles an export declaration, returning the statements that
+// should remain in place.
+func (c *commonJS) exportDecl(n ast.ExportDeclNode) []ast.Node {
+	switch {
+	case n.Declaration != nil:
+		for _, name := range declaredNames(n.Declaration) {
+			c.export(name, ast.Identifier{Name: name})
+		}
+		return []ast.Node{replaceThis(n.Declaration)}
+
+	case n.Default != nil:
+		switch t := n.Default.(type) {
+		case ast.FunctionDeclaration:
+			if t.ID == "" {
+				t.ID = c.names.generate("default")
+			}
+			c.export("default", ast.Identifier{Name: t.ID})
+			return []ast.Node{t}
+		case ast.ClassDeclaration:
+			if t.ID == "" {
+				t.ID = c.names.generate("default")
+			}
+			c.export("default", ast.Identifier{Name: t.ID})
+			return []ast.Node{replaceThis(t)}
+		}
+		return []ast.Node{ast.ExpressionStatement{
+			Expression: ast.AssignmentExpression{
+				Operator: ast.AssignmentOp,
+				Left:     member(ast.Identifier{Name: "exports"}, "default"),
+				Right:    replaceThis(n.Default),
+			},
+		}}
+
+	case n.NameSpace != nil:
+		m := c.require(n.Module)
+		if n.NameSpace.Identifier == "" {
+			c.requires = append(c.requires, ast.ExpressionStatement{
+				Expression: call(c.helpers.get("exportStar"), m, ast.Identifier{Name: "exports"}),
+			})
+			return nil
+		}
+		ns := c.names.generate(n.NameSpace.Identifier)
+		c.requires = append(c.requires, ast.VariableDeclaration{
+			Kind:         ast.VarDeclaration,
+			Declarations: []ast.VariableDeclarator{varDecl(ns, call(c.helpers.get("interopRequireWildcard"), m))},
+		})
+		c.export(n.NameSpace.Identifier, ast.Identifier{Name: ns})
+		return nil
+	}
+
+	if n.Module != "" {
+		m := c.require(n.Module)
+		for _, x := range n.NamedExports {
+			c.export(x.ExportedName(), c.importedName(m, x.Identifier, x.Quoted))
+		}
+		return nil
+	}
+	for _, x := range n.NamedExports {
+		c.export(x.ExportedName(), ast.Identifier{Name: x.Identifier})
+	}
+	return nil
+}
+
+// declaredNames returns the names bound by a declaration.
+func declaredNames(n ast.Node) []string {
+	switch t := n.(type) {
+	case ast.FunctionDeclaration:
+		return []string{t.ID}
+	case ast.ClassDeclaration:
+		return []string{t.ID}
+	case ast.VariableDeclaration:
+		names := []string{}
+		for _, d := range t.Declarations {
+			names = append(names, d.ID.BoundNames()...)
+		}
+		return names
+	}
+	return nil
+}
+
+// replaceThis replaces top-level uses of `this`, which is undefined in
+// modules, with `void 0`.
+func replaceThis(n ast.Node) ast.Node {
+	switch t := n.(type) {
+	case ast.ThisExpression:
+		return ast.UnaryExpression{Operator: ast.UnaryVoidOp, Argument: numberLiteral(0)}
+	case ast.FunctionExpression:
+		if !t.Arrow {
+			return n
+		}
+	case ast.FunctionDeclaration:
+		return n
+	case ast.ClassDeclaration:
+		t.SuperClass = replaceThisOptional(t.SuperClass)
+		return t
+	case ast.ClassExpression:
+		t.SuperClass = replaceThisOptional(t.SuperClass)
+		return t
+	}
+	return ast.MapChildren(n, replaceThis)
+}
+
+func replaceThisOptional(n ast.Node) ast.Node {
+	if n == nil {
+		return nil
+	}
+	return replaceThis(n)
+}
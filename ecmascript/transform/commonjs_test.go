@@ -0,0 +1,136 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+func TestToCommonJS(t *testing.T) {
+	const prologue = `"use strict"; Object.defineProperty(exports, "__esModule", { value: true });`
+	helper := func(name string) string {
+		return strings.Replace(commonJSHelpers[name], "NAME", "_"+name, 1)
+	}
+	getter := func(name, value string) string {
+		return `Object.defineProperty(exports, "` + name + `", { enumerable: true, get: function () { return ` + value + `; } });`
+	}
+
+	tests := []struct {
+		name, input, expected string
+	}{
+		{
+			name:     "side effect import",
+			input:    `import "polyfill"; a();`,
+			expected: prologue + `require("polyfill"); a();`,
+		},
+		{
+			name:  "imports",
+			input: `f(React, useS, R2, ns); import React, {useState as useS, default as R2} from "react"; import * as ns from "./ns.js";`,
+			expected: prologue + helper("interopRequireDefault") + helper("interopRequireWildcard") + `
+				var _react = require("react");
+				var _nsJs = require("./ns.js");
+				var ns = _interopRequireWildcard(_nsJs);
+				f(_interopRequireDefault(_react).default, _react.useState, _interopRequireDefault(_react).default, ns);`,
+		},
+		{
+			name: "import references",
+			input: `import {a, b, c} from "m";
+				function f(b) { let c; return [a, b, c, a.b, {a}, {b: a}]; }
+				a(); a.b(); c` + "`t`" + `; new a();`,
+			expected: prologue + `
+				var _m = require("m");
+				function f(b) { let c; return [_m.a, b, c, _m.a.b, {a: _m.a}, {b: _m.a}]; }
+				(0, _m.a)(); _m.a.b(); (0, _m.c)` + "`t`" + `; new _m.a();`,
+		},
+		{
+			name:     "exported imports",
+			input:    `import {a} from "m"; export {a as b}; export default a;`,
+			expected: prologue + getter("b", "_m.a") + `var _m = require("m"); exports.default = _m.a;`,
+		},
+		{
+			name:  "export declarations",
+			input: `export var a = 1, {b, c: [d, ...e]} = f; export function g() {} export class C {}`,
+			expected: prologue + getter("a", "a") + getter("b", "b") + getter("d", "d") + getter("e", "e") + getter("g", "g") + getter("C", "C") + `
+				var a = 1, {b, c: [d, ...e]} = f; function g() {} class C {}`,
+		},
+		{
+			name:     "export list",
+			input:    `var a, b; export {a, b as default};`,
+			expected: prologue + getter("a", "a") + getter("default", "b") + `var a, b;`,
+		},
+		{
+			name:     "export default expression",
+			input:    `export default this.a;`,
+			expected: prologue + `exports.default = (void 0).a;`,
+		},
+		{
+			name:     "export default function",
+			input:    `var _default; export default function () { return this; }`,
+			expected: prologue + getter("default", "_default2") + `var _default; function _default2() { return this; }`,
+		},
+		{
+			name:     "export default class",
+			input:    `export default class A {}`,
+			expected: prologue + getter("default", "A") + `class A {}`,
+		},
+		{
+			name:  "re-exports",
+			input: `export {a, default as b} from "m"; export * from "n"; export * as o from "o";`,
+			expected: prologue + getter("a", "_m.a") + getter("b", "_interopRequireDefault(_m).default") + getter("o", "_o2") +
+				helper("interopRequireDefault") + helper("exportStar") + helper("interopRequireWildcard") + `
+				var _m = require("m");
+				var _n = require("n");
+				_exportStar(_n, exports);
+				var _o = require("o");
+				var _o2 = _interopRequireWildcard(_o);`,
+		},
+		{
+			name:  "string export names",
+			input: `import {"a-b" as ab} from "m"; export {ab as "c-d"}; export {"e-f" as g} from "n";`,
+			expected: prologue + getter("c-d", `_m["a-b"]`) + getter("g", `_n["e-f"]`) + `
+				var _m = require("m");
+				var _n = require("n");`,
+		},
+		{
+			name:     "top-level this",
+			input:    `this.a; (() => this)(); (function () { return this; });`,
+			expected: prologue + `(void 0).a; (() => void 0)(); (function () { return this; });`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := ToCommonJS(parse(t, test.input, parser.ModuleMode))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertESTree(t, parseScript(t, test.expected), result)
+		})
+	}
+}
+
+func TestToCommonJSImportAssignments(t *testing.T) {
+	tests := []string{
+		`import a from "m"; a = 1;`,
+		`import {a} from "m"; a++;`,
+		`import {a} from "m"; [a] = [1];`,
+		`import * as a from "m"; function f() { ({a} = {}); }`,
+		`import {a} from "m"; for (a of []);`,
+	}
+	for _, test := range tests {
+		if _, err := ToCommonJS(parse(t, test, parser.ModuleMode)); err == nil {
+			t.Errorf("%q: expected error, got nil", test)
+		}
+	}
+
+	if _, err := ToCommonJS(parse(t, `import {a} from "m"; function f(a) { a = 1; } { let a; a = 1; }`, parser.ModuleMode)); err != nil {
+		t.Errorf("unexpected error assigning to a shadowing binding: %v", err)
+	}
+}
+
+func TestToCommonJSRequiresModule(t *testing.T) {
+	if _, err := ToCommonJS(parseScript(t, `a;`)); err == nil {
+		t.Error("expected error transforming a script")
+	}
+}
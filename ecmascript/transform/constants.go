@@ -0,0 +1,292 @@
+package transform
+
+import (
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/graph"
+	"github.com/jchv/cleansheets/ecmascript/scope"
+)
+
+// PropagateConstants replaces the uses of imported constants in the modules
+// of g with the constants' values, so that a later pass can evaluate the
+// conditions they appear in and remove the branches that never run, e.g. an
+// `if (DEBUG)` in each module that imports DEBUG from
+//
+//	export const DEBUG = false;
+//
+// A constant is a top-level const declaration, or a default export, whose
+// value is a number, string, boolean or null literal, or a negated number.
+// Constants are followed through re-exports. Named and namespace imports of
+// them are replaced, and an import specifier is removed once nothing uses
+// it; the import declaration is kept, even with no specifiers left, for the
+// side effects of the module.
+//
+// The Node of each module that changes is replaced. PropagateConstants
+// returns the number of uses replaced.
+func PropagateConstants(g *graph.Graph) int {
+	p := constantPropagation{graph: g, exports: map[*graph.Module]map[string]ast.Node{}}
+	count := 0
+	for _, m := range g.Modules {
+		if n, ok := m.Node.(ast.ModuleNode); ok {
+			var replaced int
+			m.Node, replaced = p.module(m, n)
+			count += replaced
+		}
+	}
+	return count
+}
+
+type constantPropagation struct {
+	graph *graph.Graph
+
+	// exports holds the constants each module exports, by exported name. A
+	// module is mapped to nil while its exports are being found, so that
+	// cycles of re-exports end.
+	exports map[*graph.Module]map[string]ast.Node
+}
+
+// imported returns the module that an import or re-export of specifier in m
+// refers to, or nil if it is not in the graph.
+func (p *constantPropagation) imported(m *graph.Module, specifier string) *graph.Module {
+	for _, imp := range m.Imports {
+		if imp.Specifier == specifier && imp.Path != "" {
+			return p.graph.Module(imp.Path)
+		}
+	}
+	return nil
+}
+
+// constants returns the constants that m exports.
+func (p *constantPropagation) constants(m *graph.Module) map[string]ast.Node {
+	if exports, ok := p.exports[m]; ok {
+		return exports
+	}
+	p.exports[m] = nil
+	n, ok := m.Node.(ast.ModuleNode)
+	if !ok {
+		return nil
+	}
+
+	local := map[string]ast.Node{}
+	for _, stmt := range n.Body {
+		if e, ok := stmt.(ast.ExportDeclNode); ok && e.Declaration != nil {
+			stmt = e.Declaration
+		}
+		if d, ok := stmt.(ast.VariableDeclaration); ok && d.Kind == ast.ConstDeclaration {
+			for _, decl := range d.Declarations {
+				if decl.ID.Identifier != "" && isConstant(decl.Init) {
+					local[decl.ID.Identifier] = decl.Init
+				}
+			}
+		}
+	}
+
+	exports := map[string]ast.Node{}
+	for _, stmt := range n.Body {
+		e, ok := stmt.(ast.ExportDeclNode)
+		if !ok {
+			continue
+		}
+		from := local
+		if e.Module != "" {
+			from = nil
+			if target := p.imported(m, e.Module); target != nil {
+				from = p.constants(target)
+			}
+		}
+		switch {
+		case e.Declaration != nil:
+			if d, ok := e.Declaration.(ast.VariableDeclaration); ok {
+				for _, decl := range d.Declarations {
+					if v, ok := local[decl.ID.Identifier]; ok {
+						exports[decl.ID.Identifier] = v
+					}
+				}
+			}
+
+		case e.Default != nil:
+			if isConstant(e.Default) {
+				exports["default"] = e.Default
+			}
+
+		case e.NameSpace != nil:
+			if e.NameSpace.Identifier != "" {
+				continue
+			}
+			for name, v := range from {
+				if _, ok := exports[name]; !ok && name != "default" {
+					exports[name] = v
+				}
+			}
+
+		default:
+			for _, x := range e.NamedExports {
+				if v, ok := from[x.Identifier]; ok {
+					exports[x.ExportedName()] = v
+				}
+			}
+		}
+	}
+	p.exports[m] = exports
+	return exports
+}
+
+// isConstant returns whether n is a value that can be copied into another
+// module.
+func isConstant(n ast.Node) bool {
+	switch t := n.(type) {
+	case ast.NumberLiteral, ast.StringLiteral, ast.BooleanLiteral, ast.NullLiteral:
+		return true
+	case ast.UnaryExpression:
+		_, ok := t.Argument.(ast.NumberLiteral)
+		return ok && t.Operator == ast.UnaryMinusOp
+	}
+	return false
+}
+
+// module replaces the uses of imported constants in n.
+func (p *constantPropagation) module(m *graph.Module, n ast.ModuleNode) (ast.Node, int) {
+	// Find the constants each import binding stands for. A namespace
+	// binding stands for all of the constants of its module.
+	named := map[string]ast.Node{}
+	namespaces := map[string]map[string]ast.Node{}
+	for _, stmt := range n.Body {
+		d, ok := stmt.(ast.ImportDeclNode)
+		if !ok || d.Source {
+			continue
+		}
+		target := p.imported(m, d.Module)
+		if target == nil {
+			continue
+		}
+		exports := p.constants(target)
+		if d.DefaultBinding != nil {
+			if v, ok := exports["default"]; ok {
+				named[d.DefaultBinding.Identifier] = v
+			}
+		}
+		if d.NameSpace != nil && len(exports) > 0 {
+			namespaces[d.NameSpace.Identifier] = exports
+		}
+		for _, x := range d.NamedImports {
+			if v, ok := exports[x.Identifier]; ok {
+				named[x.Binding()] = v
+			}
+		}
+	}
+	if len(named) == 0 && len(namespaces) == 0 {
+		return n, 0
+	}
+
+	// Find the uses to replace, by their spans, and the bindings that have
+	// other uses.
+	replace := map[ast.Span]ast.Node{}
+	used := map[string]bool{}
+	var root *scope.Scope
+	isImport := func(w *scope.Walker, name string) bool {
+		return w.Scope.Lookup(name) == root && root.Bindings[name] == scope.ImportBinding
+	}
+	w := scope.Walker{
+		OnScope: func(w *scope.Walker, s *scope.Scope) {
+			if root == nil {
+				root = s
+			}
+		},
+		OnNode: func(w *scope.Walker, n ast.Node) {
+			e, ok := n.(ast.MemberExpression)
+			if !ok || e.Computed || e.Span() == (ast.Span{}) {
+				return
+			}
+			object, ok1 := e.Object.(ast.Identifier)
+			property, ok2 := e.Property.(ast.Identifier)
+			if ok1 && ok2 && isImport(w, object.Name) {
+				if v, ok := namespaces[object.Name][property.Name]; ok {
+					replace[e.Span()] = v
+					replace[object.Span()] = nil
+				}
+			}
+		},
+		OnRef: func(w *scope.Walker, id ast.Identifier, assign bool) {
+			if !isImport(w, id.Name) {
+				return
+			}
+			if _, done := replace[id.Span()]; done {
+				return
+			}
+			if v, ok := named[id.Name]; ok && !assign && id.Span() != (ast.Span{}) {
+				replace[id.Span()] = v
+			} else {
+				used[id.Name] = true
+			}
+		},
+	}
+	w.Walk(n)
+	for _, stmt := range n.Body {
+		if e, ok := stmt.(ast.ExportDeclNode); ok && e.Module == "" {
+			for _, x := range e.NamedExports {
+				used[x.Identifier] = true
+			}
+		}
+	}
+
+	count := 0
+	var rewrite func(n ast.Node) ast.Node
+	rewrite = func(n ast.Node) ast.Node {
+		switch t := n.(type) {
+		case ast.Identifier:
+			if v := replace[t.Span()]; v != nil {
+				count++
+				return v
+			}
+			return t
+
+		case ast.MemberExpression:
+			if v := replace[t.Span()]; v != nil {
+				count++
+				return v
+			}
+
+		case ast.ObjectExpression:
+			// A shorthand property keeps its name when its value is
+			// replaced.
+			props := make([]ast.Property, len(t.Properties))
+			for i, prop := range t.Properties {
+				if id, ok := prop.Key.(ast.Identifier); ok && prop.Value == nil && prop.Kind == ast.InitProperty {
+					if v := replace[id.Span()]; v != nil {
+						count++
+						prop.Value = v
+						delete(replace, id.Span())
+					}
+				}
+				props[i] = prop
+			}
+			t.Properties = props
+			return ast.MapChildren(t, rewrite)
+		}
+		return ast.MapChildren(n, rewrite)
+	}
+	n = rewrite(n).(ast.ModuleNode)
+
+	// Remove the specifiers that are no longer used.
+	n.Body = append([]ast.Node(nil), n.Body...)
+	for i, stmt := range n.Body {
+		d, ok := stmt.(ast.ImportDeclNode)
+		if !ok {
+			continue
+		}
+		if d.DefaultBinding != nil && named[d.DefaultBinding.Identifier] != nil && !used[d.DefaultBinding.Identifier] {
+			d.DefaultBinding = nil
+		}
+		if d.NameSpace != nil && namespaces[d.NameSpace.Identifier] != nil && !used[d.NameSpace.Identifier] {
+			d.NameSpace = nil
+		}
+		var imports []ast.NamedImport
+		for _, x := range d.NamedImports {
+			if named[x.Binding()] == nil || used[x.Binding()] {
+				imports = append(imports, x)
+			}
+		}
+		d.NamedImports = imports
+		n.Body[i] = d
+	}
+	return n, count
+}
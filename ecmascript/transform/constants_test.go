@@ -0,0 +1,55 @@
+package transform
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jchv/cleansheets/ecmascript/graph"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+	"github.com/jchv/cleansheets/ecmascript/resolve"
+)
+
+func TestPropagateConstants(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"main.js": `import {DEBUG, LEVEL as level, name, NAME, other} from "./config.js";
+			import * as ns from "./reexport.js";
+			import def from "./default.js";
+			import {DEBUG as assigned} from "./config.js";
+			if (DEBUG) log(level, {name: NAME, DEBUG});
+			function f(DEBUG) { return DEBUG; }
+			x = ns.DEBUG ? ns.NEG : ns.other + def + typeof ns;
+			assigned = 1;
+			export {name};`,
+		"config.js":   `export const DEBUG = false, LEVEL = 2, name = "n", other = f(); const N = "config"; export {N as NAME}; export let mutable = 1;`,
+		"reexport.js": `export * from "./config.js"; export {NEG} from "./neg.js"; export * from "./reexport.js";`,
+		"neg.js":      `export const NEG = -1;`,
+		"default.js":  `export default null;`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g := graph.Build([]string{filepath.Join(root, "main.js")}, resolve.NewResolver())
+	for _, m := range g.Modules {
+		if m.Err != nil {
+			t.Fatal(m.Err)
+		}
+	}
+
+	if n := PropagateConstants(g); n != 7 {
+		t.Errorf("expected 7 replacements, got %d", n)
+	}
+	expected := `import {name, other} from "./config.js";
+		import * as ns from "./reexport.js";
+		import "./default.js";
+		import {DEBUG as assigned} from "./config.js";
+		if (false) log(2, {name: "config", DEBUG: false});
+		function f(DEBUG) { return DEBUG; }
+		x = false ? -1 : ns.other + null + typeof ns;
+		assigned = 1;
+		export {name};`
+	assertESTree(t, parse(t, expected, parser.ModuleMode), g.Module(filepath.Join(root, "main.js")).Node)
+}
@@ -0,0 +1,449 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// DefaultCoverageVariable is the global that coverage data is stored in by
+// default. This matches what Istanbul (nyc) and its reporters expect.
+const DefaultCoverageVariable = "__coverage__"
+
+// CoverageOptions are options that adjust how code is instrumented.
+type CoverageOptions struct {
+	// Path is the file path recorded in the coverage data. It is also used as
+	// the key into the global coverage object.
+	Path string
+
+	// Variable is the name of the global coverage object. If empty,
+	// DefaultCoverageVariable is used.
+	Variable string
+}
+
+// CoveragePosition is a position in the original source, in the format used
+// by Istanbul: lines are 1-based and columns are 0-based.
+type CoveragePosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// CoverageRange is a range of the original source.
+type CoverageRange struct {
+	Start CoveragePosition `json:"start"`
+	End   CoveragePosition `json:"end"`
+}
+
+// FunctionMapping describes an instrumented function.
+type FunctionMapping struct {
+	Name string        `json:"name"`
+	Decl CoverageRange `json:"decl"`
+	Loc  CoverageRange `json:"loc"`
+	Line int           `json:"line"`
+}
+
+// BranchMapping describes an instrumented branch point. Each location is a
+// possible path through the branch point.
+type BranchMapping struct {
+	Loc       CoverageRange   `json:"loc"`
+	Type      string          `json:"type"`
+	Locations []CoverageRange `json:"locations"`
+	Line      int             `json:"line"`
+}
+
+// FileCoverage is the coverage data for a single file. It marshals to the
+// Istanbul file coverage format, so a map of paths to FileCoverage values can
+// be fed to existing report tooling as-is.
+//
+// The counters start out as zero; at runtime the instrumented code increments
+// the copy stored in the global coverage object.
+type FileCoverage struct {
+	Path         string                     `json:"path"`
+	StatementMap map[string]CoverageRange   `json:"statementMap"`
+	FnMap        map[string]FunctionMapping `json:"fnMap"`
+	BranchMap    map[string]BranchMapping   `json:"branchMap"`
+	S            map[string]int             `json:"s"`
+	F            map[string]int             `json:"f"`
+	B            map[string][]int           `json:"b"`
+}
+
+// InstrumentCoverage returns a copy of a script or module with counters
+// inserted for each statement, function and branch, along with the coverage
+// data describing those counters.
+func InstrumentCoverage(n ast.Node, opt CoverageOptions) (ast.Node, *FileCoverage, error) {
+	if opt.Variable == "" {
+		opt.Variable = DefaultCoverageVariable
+	}
+	c := coverageInstrumenter{
+		cov: &FileCoverage{
+			Path:         opt.Path,
+			StatementMap: map[string]CoverageRange{},
+			FnMap:        map[string]FunctionMapping{},
+			BranchMap:    map[string]BranchMapping{},
+			S:            map[string]int{},
+			F:            map[string]int{},
+			B:            map[string][]int{},
+		},
+		ident: coverageIdent(opt.Path),
+	}
+	switch t := n.(type) {
+	case ast.ScriptNode:
+		t.Body = c.program(t.Body, opt.Variable)
+		return t, c.cov, nil
+	case ast.ModuleNode:
+		t.Body = c.program(t.Body, opt.Variable)
+		return t, c.cov, nil
+	}
+	return nil, nil, errors.New("coverage instrumentation requires a script or module")
+}
+
+// coverageIdent returns the name of the local variable used to refer to the
+// coverage data of a file.
+func coverageIdent(path string) string {
+	h := fnv.New32a()
+	h.Write([]byte(path))
+	return fmt.Sprintf("__cov_%08x", h.Sum32())
+}
+
+type coverageInstrumenter struct {
+	cov   *FileCoverage
+	ident string
+}
+
+// program instruments the body of a script or module and prepends the code
+// that registers the file's coverage data.
+func (c *coverageInstrumenter) program(body []ast.Node, global string) []ast.Node {
+	body = c.statements(body)
+	// Binding names are not nodes, so the identifier is spliced in directly.
+	header := template(`
+		var `+c.ident+` = (function () {
+			var path = PATH;
+			var global = typeof globalThis !== "undefined" ? globalThis : new Function("return this")();
+			var coverage = global[GLOBAL] || (global[GLOBAL] = {});
+			if (!coverage[path]) coverage[path] = DATA;
+			return coverage[path];
+		})();
+	`, map[string]ast.Node{
+		"PATH":   stringLiteral(c.cov.Path),
+		"GLOBAL": stringLiteral(global),
+		"DATA":   literal(c.cov),
+	})
+	return prependStatements(body, header...)
+}
+
+// counter returns an expression that increments a counter.
+func (c *coverageInstrumenter) counter(kind string, index ...int) ast.Node {
+	var n ast.Node = ast.MemberExpression{
+		Object:   ast.Identifier{Name: c.ident},
+		Property: ast.Identifier{Name: kind},
+	}
+	for _, i := range index {
+		n = ast.MemberExpression{Object: n, Property: numberLiteral(float64(i)), Computed: true}
+	}
+	return ast.UpdateExpression{Operator: ast.UpdatePostIncrementOp, Argument: n}
+}
+
+// counted returns an expression that increments a counter and then evaluates
+// to expr.
+func (c *coverageInstrumenter) counted(expr ast.Node, kind string, index ...int) ast.Node {
+	return ast.ParenthesizedExpression{
+		Expression: ast.SequenceExpression{
+			Expressions: []ast.Node{c.counter(kind, index...), expr},
+		},
+	}
+}
+
+func (c *coverageInstrumenter) statement(span ast.Span) ast.Node {
+	i := len(c.cov.S)
+	k := strconv.Itoa(i)
+	c.cov.StatementMap[k] = coverageRange(span)
+	c.cov.S[k] = 0
+	return ast.ExpressionStatement{Expression: c.counter("s", i)}
+}
+
+// function records a function spanning span, whose name is at decl.
+func (c *coverageInstrumenter) function(name string, span, decl ast.Span) ast.Node {
+	i := len(c.cov.F)
+	k := strconv.Itoa(i)
+	if name == "" {
+		name = fmt.Sprintf("(anonymous_%d)", i)
+	}
+	r := coverageRange(span)
+	c.cov.FnMap[k] = FunctionMapping{Name: name, Decl: coverageRange(decl), Loc: r, Line: r.Start.Line}
+	c.cov.F[k] = 0
+	return ast.ExpressionStatement{Expression: c.counter("f", i)}
+}
+
+func (c *coverageInstrumenter) branch(typ string, span ast.Span, locations ...ast.Span) int {
+	i := len(c.cov.B)
+	k := strconv.Itoa(i)
+	m := BranchMapping{Loc: coverageRange(span), Type: typ, Line: span.Start.Row}
+	for _, l := range locations {
+		m.Locations = append(m.Locations, coverageRange(l))
+	}
+	c.cov.BranchMap[k] = m
+	c.cov.B[k] = make([]int, len(locations))
+	return i
+}
+
+// addLocation adds another location to an existing branch point.
+func (c *coverageInstrumenter) addLocation(branch int, span ast.Span) int {
+	k := strconv.Itoa(branch)
+	m := c.cov.BranchMap[k]
+	m.Locations = append(m.Locations, coverageRange(span))
+	c.cov.BranchMap[k] = m
+	c.cov.B[k] = append(c.cov.B[k], 0)
+	return len(m.Locations) - 1
+}
+
+// functionDecl returns the span recorded as the declaration of a function: the
+// span of its name, id, if it has one, or else of the keyword it starts with.
+// Arrow functions that are not async have no keyword, so the first character
+// is used instead, as Istanbul does.
+func functionDecl(span, id ast.Span, async, arrow bool) ast.Span {
+	if id.Start.Row != 0 {
+		return id
+	}
+	end := span.Start
+	switch {
+	case async:
+		end = end.Advance("async")
+	case arrow:
+		end.Column++
+	default:
+		end = end.Advance("function")
+	}
+	return ast.Span{Start: span.Start, End: end}
+}
+
+func coverageRange(s ast.Span) CoverageRange {
+	return CoverageRange{Start: coveragePosition(s.Start), End: coveragePosition(s.End)}
+}
+
+func coveragePosition(l ast.Location) CoveragePosition {
+	p := CoveragePosition{Line: l.Row, Column: l.Column - 1}
+	if p.Column < 0 {
+		// Unknown location.
+		p.Column = 0
+	}
+	return p
+}
+
+// isCountedStatement returns true if a statement should get a counter when
+// it appears in a statement list. Declarations that are hoisted and blocks,
+// whose contents are counted individually, are skipped.
+func isCountedStatement(n ast.Node) bool {
+	switch n := n.(type) {
+	case ast.FunctionDeclaration, ast.BlockStatement, ast.EmptyStatement, ast.ImportDeclNode:
+		return false
+	case ast.ExpressionStatement:
+		return n.Directive == ""
+	}
+	return true
+}
+
+// statements instruments a statement list, inserting statement counters.
+func (c *coverageInstrumenter) statements(list []ast.Node) []ast.Node {
+	out := make([]ast.Node, 0, len(list)*2)
+	for _, stmt := range list {
+		if isCountedStatement(stmt) {
+			out = append(out, c.statement(stmt.Span()))
+		}
+		out = append(out, c.visit(stmt))
+	}
+	return out
+}
+
+// block instruments a statement in a position that only allows a single
+// statement, turning it into a block so that counters can be inserted.
+func (c *coverageInstrumenter) block(n ast.Node, prefix ...ast.Node) ast.Node {
+	var body []ast.Node
+	switch t := n.(type) {
+	case nil:
+	case ast.BlockStatement:
+		body = c.statements(t.Body)
+	default:
+		body = c.statements([]ast.Node{n})
+	}
+	b := ast.BlockStatement{Body: append(prefix, body...)}
+	if n != nil {
+		b.SetStart(n.Span().Start)
+		b.SetEnd(n.Span().End)
+	}
+	return b
+}
+
+// functionBody instruments the body of a function, inserting the function
+// counter after the directive prologue.
+func (c *coverageInstrumenter) functionBody(body ast.Node, fn ast.Node) ast.BlockStatement {
+	b, ok := body.(ast.BlockStatement)
+	if !ok {
+		// Concise arrow function body.
+		b = ast.BlockStatement{Body: []ast.Node{ast.ReturnStatement{Argument: body}}}
+		b.SetStart(body.Span().Start)
+		b.SetEnd(body.Span().End)
+	}
+	b.Body = prependStatements(c.statements(b.Body), fn)
+	return b
+}
+
+// functionExpression instruments a function expression. A method passes the
+// name and span of its key, which are used instead of those of the function.
+func (c *coverageInstrumenter) functionExpression(n ast.FunctionExpression, name string, decl ast.Span) ast.FunctionExpression {
+	if name == "" {
+		name = n.ID
+	}
+	if decl.Start.Row == 0 {
+		decl = functionDecl(n.Span(), n.IDSpan, n.Async, n.Arrow)
+	}
+	fn := c.function(name, n.Span(), decl)
+	n.Params = mapParams(n.Params, c.visit)
+	n.Body = c.functionBody(n.Body, fn)
+	return n
+}
+
+func (c *coverageInstrumenter) visit(n ast.Node) ast.Node {
+	switch t := n.(type) {
+	case ast.BlockStatement:
+		t.Body = c.statements(t.Body)
+		return t
+
+	case ast.FunctionDeclaration:
+		fn := c.function(t.ID, t.Span(), functionDecl(t.Span(), t.IDSpan, t.Async, false))
+		t.Params = mapParams(t.Params, c.visit)
+		t.Body = c.functionBody(t.Body, fn)
+		return t
+
+	case ast.FunctionExpression:
+		return c.functionExpression(t, "", ast.Span{})
+
+	case ast.MethodDefinition:
+		t.Key = c.visit(t.Key)
+		name := ""
+		if id, ok := t.Key.(ast.Identifier); ok && !t.Computed {
+			name = id.Name
+		}
+		t.Value = c.functionExpression(t.Value, name, t.Key.Span())
+		return t
+
+	case ast.IfStatement:
+		alt := t.Span()
+		if t.Alternate != nil {
+			alt = t.Alternate.Span()
+		}
+		b := c.branch("if", t.Span(), t.Consequent.Span(), alt)
+		t.Test = c.visit(t.Test)
+		t.Consequent = c.block(t.Consequent, ast.ExpressionStatement{Expression: c.counter("b", b, 0)})
+		t.Alternate = c.block(t.Alternate, ast.ExpressionStatement{Expression: c.counter("b", b, 1)})
+		return t
+
+	case ast.WhileStatement:
+		t.Test = c.visit(t.Test)
+		t.Body = c.block(t.Body)
+		return t
+
+	case ast.DoWhileStatement:
+		t.Body = c.block(t.Body)
+		t.Test = c.visit(t.Test)
+		return t
+
+	case ast.WithStatement:
+		t.Object = c.visit(t.Object)
+		t.Body = c.block(t.Body)
+		return t
+
+	case ast.ForStatement:
+		t.Init = c.visitOptional(t.Init)
+		t.Test = c.visitOptional(t.Test)
+		t.Update = c.visitOptional(t.Update)
+		t.Body = c.block(t.Body)
+		return t
+
+	case ast.ForInStatement:
+		t.Left = c.visit(t.Left)
+		t.Right = c.visit(t.Right)
+		t.Body = c.block(t.Body)
+		return t
+
+	case ast.ForOfStatement:
+		t.Left = c.visit(t.Left)
+		t.Right = c.visit(t.Right)
+		t.Body = c.block(t.Body)
+		return t
+
+	case ast.LabeledStatement:
+		// The body can not be wrapped in a block, as that would break
+		// `continue` statements targeting the label.
+		t.Body = c.visit(t.Body)
+		return t
+
+	case ast.SwitchStatement:
+		b := c.branch("switch", t.Span())
+		t.Discriminant = c.visit(t.Discriminant)
+		cases := make([]ast.SwitchCase, len(t.Cases))
+		for i, sc := range t.Cases {
+			span := t.Span()
+			if sc.Test != nil {
+				span = sc.Test.Span()
+			}
+			k := c.addLocation(b, span)
+			sc.Test = c.visitOptional(sc.Test)
+			sc.Consequent = append([]ast.Node{ast.ExpressionStatement{Expression: c.counter("b", b, k)}}, c.statements(sc.Consequent)...)
+			cases[i] = sc
+		}
+		t.Cases = cases
+		return t
+
+	case ast.ConditionalExpression:
+		b := c.branch("cond-expr", t.Span(), t.Consequent.Span(), t.Alternate.Span())
+		t.Test = c.visit(t.Test)
+		t.Consequent = c.counted(c.visit(t.Consequent), "b", b, 0)
+		t.Alternate = c.counted(c.visit(t.Alternate), "b", b, 1)
+		return t
+
+	case ast.BinaryExpression:
+		if isLogicalOp(t.Operator) {
+			b := c.branch("binary-expr", t.Span())
+			return c.logical(t, b)
+		}
+	}
+	return ast.MapChildren(n, c.visit)
+}
+
+func (c *coverageInstrumenter) visitOptional(n ast.Node) ast.Node {
+	if n == nil {
+		return nil
+	}
+	return c.visit(n)
+}
+
+// logical instruments each operand of a chain of logical operators as a
+// location of a single branch point.
+func (c *coverageInstrumenter) logical(n ast.Node, b int) ast.Node {
+	switch t := n.(type) {
+	case ast.BinaryExpression:
+		if isLogicalOp(t.Operator) {
+			t.Left = c.logical(t.Left, b)
+			t.Right = c.logical(t.Right, b)
+			return t
+		}
+	case ast.ParenthesizedExpression:
+		if e, ok := t.Expression.(ast.BinaryExpression); ok && isLogicalOp(e.Operator) {
+			t.Expression = c.logical(e, b)
+			return t
+		}
+	}
+	k := c.addLocation(b, n.Span())
+	return c.counted(c.visit(n), "b", b, k)
+}
+
+func isLogicalOp(op ast.BinaryOperator) bool {
+	switch op {
+	case ast.BinaryLogicalAndOp, ast.BinaryLogicalOrOp, ast.BinaryCoalesceOp:
+		return true
+	}
+	return false
+}
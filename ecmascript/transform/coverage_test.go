@@ -0,0 +1,243 @@
+package transform
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+func parse(t *testing.T, src string, mode parser.ParseMode) ast.Node {
+	t.Helper()
+	n, err := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(src), nil))).Parse(parser.ParseOptions{Mode: mode})
+	if err != nil {
+		t.Fatalf("error parsing %q: %v", src, err)
+	}
+	return n
+}
+
+func parseScript(t *testing.T, src string) ast.Node {
+	t.Helper()
+	return parse(t, src, parser.ScriptMode)
+}
+
+// assertESTree compares two nodes by their ESTree representation, which
+// ignores source spans and parentheses.
+func assertESTree(t *testing.T, expected, result ast.Node) {
+	t.Helper()
+	e, err := json.Marshal(expected.ESTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := json.Marshal(result.ESTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ev, rv interface{}
+	json.Unmarshal(e, &ev)
+	json.Unmarshal(r, &rv)
+	if diff := cmp.Diff(ev, rv); diff != "" {
+		t.Errorf("estree mismatch (-expected +result):\n%s", diff)
+	}
+}
+
+func TestInstrumentCoverage(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{
+			name:     "statements",
+			input:    `var a = 1; a++; ;`,
+			expected: `COV.s[0]++; var a = 1; COV.s[1]++; a++; ;`,
+		},
+		{
+			name:     "function declaration",
+			input:    `function f(a) { return a; }`,
+			expected: `function f(a) { COV.f[0]++; COV.s[0]++; return a; }`,
+		},
+		{
+			name:     "function directives",
+			input:    `function f() { "use strict"; }`,
+			expected: `function f() { "use strict"; COV.f[0]++; }`,
+		},
+		{
+			name:     "arrow function",
+			input:    `x => x * 2;`,
+			expected: `COV.s[0]++; x => { COV.f[0]++; COV.s[1]++; return x * 2; };`,
+		},
+		{
+			name:     "default parameters",
+			input:    `function f(a = () => 1) {}`,
+			expected: `function f(a = () => { COV.f[1]++; COV.s[0]++; return 1; }) { COV.f[0]++; }`,
+		},
+		{
+			name:     "class methods",
+			input:    `class A { m() {} }`,
+			expected: `COV.s[0]++; class A { m() { COV.f[0]++; } }`,
+		},
+		{
+			name:     "if",
+			input:    `if (a) b();`,
+			expected: `COV.s[0]++; if (a) { COV.b[0][0]++; COV.s[1]++; b(); } else { COV.b[0][1]++; }`,
+		},
+		{
+			name:     "if else",
+			input:    `if (a) { b(); } else c();`,
+			expected: `COV.s[0]++; if (a) { COV.b[0][0]++; COV.s[1]++; b(); } else { COV.b[0][1]++; COV.s[2]++; c(); }`,
+		},
+		{
+			name:     "loops",
+			input:    `while (a) b(); for (;;) {}`,
+			expected: `COV.s[0]++; while (a) { COV.s[1]++; b(); } COV.s[2]++; for (;;) {}`,
+		},
+		{
+			name:     "with",
+			input:    `with (a) b();`,
+			expected: `COV.s[0]++; with (a) { COV.s[1]++; b(); }`,
+		},
+		{
+			name:     "labels",
+			input:    `l: for (;;) continue l;`,
+			expected: `COV.s[0]++; l: for (;;) { COV.s[1]++; continue l; }`,
+		},
+		{
+			name:     "switch",
+			input:    `switch (a) { case 1: b(); default: }`,
+			expected: `COV.s[0]++; switch (a) { case 1: COV.b[0][0]++; COV.s[1]++; b(); default: COV.b[0][1]++; }`,
+		},
+		{
+			name:     "conditional",
+			input:    `a ? b : c;`,
+			expected: `COV.s[0]++; a ? (COV.b[0][0]++, b) : (COV.b[0][1]++, c);`,
+		},
+		{
+			name:     "logical",
+			input:    `a && (b || c) && d + e;`,
+			expected: `COV.s[0]++; (COV.b[0][0]++, a) && ((COV.b[0][1]++, b) || (COV.b[0][2]++, c)) && (COV.b[0][3]++, d + e);`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, _, err := InstrumentCoverage(parseScript(t, test.input), CoverageOptions{Path: "test.js"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := result.(ast.ScriptNode).Body
+			ident := coverageIdent("test.js")
+			n := directives(body)
+			if d, ok := body[n].(ast.VariableDeclaration); !ok || d.Declarations[0].ID.Identifier != ident {
+				t.Fatalf("expected coverage header at %d, got %#v", n, body[n])
+			}
+			result = ast.ScriptNode{Body: append(body[:n:n], body[n+1:]...)}
+			expected := substitute(parseScript(t, test.expected), map[string]ast.Node{"COV": ast.Identifier{Name: ident}})
+			assertESTree(t, expected, result)
+		})
+	}
+}
+
+func span(startRow, startCol, endRow, endCol int) ast.BaseNode {
+	b := ast.BaseNode{}
+	b.SetStart(ast.Location{Row: startRow, Column: startCol})
+	b.SetEnd(ast.Location{Row: endRow, Column: endCol})
+	return b
+}
+
+func TestCoverageData(t *testing.T) {
+	// function f() {
+	//   return a ? 1 : 2;
+	// }
+	input := ast.ScriptNode{
+		Body: []ast.Node{
+			ast.FunctionDeclaration{
+				BaseNode: span(1, 1, 3, 2),
+				ID:       "f",
+				IDSpan:   ast.Span{Start: ast.Location{Row: 1, Column: 10}, End: ast.Location{Row: 1, Column: 11}},
+				Body: ast.BlockStatement{
+					BaseNode: span(1, 14, 3, 2),
+					Body: []ast.Node{
+						ast.ReturnStatement{
+							BaseNode: span(2, 3, 2, 20),
+							Argument: ast.ConditionalExpression{
+								BaseNode:   span(2, 10, 2, 19),
+								Test:       ast.Identifier{BaseNode: span(2, 10, 2, 11), Name: "a"},
+								Consequent: ast.NumberLiteral{BaseNode: span(2, 14, 2, 15), Value: 1, Raw: "1"},
+								Alternate:  ast.NumberLiteral{BaseNode: span(2, 18, 2, 19), Value: 2, Raw: "2"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	_, cov, err := InstrumentCoverage(input, CoverageOptions{Path: "/src/f.js"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &FileCoverage{
+		Path: "/src/f.js",
+		StatementMap: map[string]CoverageRange{
+			"0": {Start: CoveragePosition{Line: 2, Column: 2}, End: CoveragePosition{Line: 2, Column: 19}},
+		},
+		FnMap: map[string]FunctionMapping{
+			"0": {
+				Name: "f",
+				Decl: CoverageRange{Start: CoveragePosition{Line: 1, Column: 9}, End: CoveragePosition{Line: 1, Column: 10}},
+				Loc:  CoverageRange{Start: CoveragePosition{Line: 1, Column: 0}, End: CoveragePosition{Line: 3, Column: 1}},
+				Line: 1,
+			},
+		},
+		BranchMap: map[string]BranchMapping{
+			"0": {
+				Loc:  CoverageRange{Start: CoveragePosition{Line: 2, Column: 9}, End: CoveragePosition{Line: 2, Column: 18}},
+				Type: "cond-expr",
+				Locations: []CoverageRange{
+					{Start: CoveragePosition{Line: 2, Column: 13}, End: CoveragePosition{Line: 2, Column: 14}},
+					{Start: CoveragePosition{Line: 2, Column: 17}, End: CoveragePosition{Line: 2, Column: 18}},
+				},
+				Line: 2,
+			},
+		},
+		S: map[string]int{"0": 0},
+		F: map[string]int{"0": 0},
+		B: map[string][]int{"0": {0, 0}},
+	}
+	if diff := cmp.Diff(expected, cov); diff != "" {
+		t.Errorf("coverage mismatch (-expected +result):\n%s", diff)
+	}
+}
+
+func TestCoverageFunctionDecl(t *testing.T) {
+	src := "function f() {}\nvar g = function h() {};\nvar i = function () {};\nvar j = async function () {};\nvar k = x => x;\nclass A { m() {} }"
+	_, cov, err := InstrumentCoverage(parseScript(t, src), CoverageOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(line, start, end int) CoverageRange {
+		return CoverageRange{Start: CoveragePosition{Line: line, Column: start}, End: CoveragePosition{Line: line, Column: end}}
+	}
+	expected := []CoverageRange{
+		at(1, 9, 10),
+		at(2, 17, 18),
+		at(3, 8, 16),
+		at(4, 8, 13),
+		at(5, 8, 9),
+		at(6, 10, 11),
+	}
+	for i, decl := range expected {
+		if diff := cmp.Diff(decl, cov.FnMap[strconv.Itoa(i)].Decl); diff != "" {
+			t.Errorf("function %d: decl mismatch (-expected +result):\n%s", i, diff)
+		}
+	}
+}
+
+func TestInstrumentCoverageRequiresProgram(t *testing.T) {
+	if _, _, err := InstrumentCoverage(ast.Identifier{Name: "a"}, CoverageOptions{}); err == nil {
+		t.Error("expected error instrumenting an expression")
+	}
+}
@@ -0,0 +1,264 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+	"github.com/jchv/cleansheets/ecmascript/scope"
+)
+
+// Defines maps global names, or member expressions on them such as
+// process.env.NODE_ENV, to the values that Define replaces them with.
+type Defines map[string]ast.Node
+
+// ParseDefines parses the values of defines, which are written as
+// expressions, e.g. {"process.env.NODE_ENV": `"production"`, "__DEV__":
+// "false"}. Each name must be an identifier, or identifiers joined by dots.
+func ParseDefines(defines map[string]string) (Defines, error) {
+	d := Defines{}
+	for name, value := range defines {
+		for _, part := range strings.Split(name, ".") {
+			if !isIdentifierName(part) {
+				return nil, fmt.Errorf("invalid define %q: expected identifiers joined by dots", name)
+			}
+		}
+		v, err := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(value), nil))).Parse(parser.ParseOptions{Mode: parser.ExpressionMode})
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for define %q: %w", name, err)
+		}
+		d[name] = v
+	}
+	return d, nil
+}
+
+// isIdentifierName returns whether s can be written as an identifier.
+func isIdentifierName(s string) bool {
+	for i, r := range s {
+		if !(r == '_' || r == '$' || unicode.IsLetter(r) || i > 0 && unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// Define returns a copy of a script or module with the names in defines
+// replaced by their values. This is how bundlers strip code meant only for
+// development: once `process.env.NODE_ENV !== "production"` becomes
+// `"production" !== "production"`, constant folding and dead code
+// elimination can remove what it guards.
+//
+// Only names that refer to globals are replaced, not local variables that
+// shadow them. Member expressions are matched when written with dots, as in
+// process.env.NODE_ENV, rather than with brackets. Names that are assigned
+// to, incremented or deleted are left as they are.
+func Define(n ast.Node, defines Defines) ast.Node {
+	d := definer{defines: defines}
+	return d.visit(n)
+}
+
+// definer replaces defined names as it walks a tree, tracking scopes so that
+// local variables are left alone. Replacing in place, rather than looking up
+// nodes found by an earlier walk, works for nodes without a source span.
+type definer struct {
+	defines Defines
+	scope   *scope.Scope
+}
+
+// replacement returns the value that a name or member expression is replaced
+// with, or nil if it is not replaced.
+func (d *definer) replacement(n ast.Node) ast.Node {
+	path, root, ok := memberPath(n)
+	if !ok || d.scope.Lookup(root) != nil {
+		return nil
+	}
+	return d.defines[path]
+}
+
+// target visits the target of an assignment, which is not replaced itself,
+// although the expressions in it may be.
+func (d *definer) target(n ast.Node) ast.Node {
+	switch t := n.(type) {
+	case ast.Identifier:
+		return t
+
+	case ast.ParenthesizedExpression:
+		t.Expression = d.target(t.Expression)
+		return t
+
+	case ast.MemberExpression:
+		t.Object = d.visit(t.Object)
+		if t.Computed {
+			t.Property = d.visit(t.Property)
+		}
+		return t
+
+	case ast.SpreadElement:
+		t.Argument = d.target(t.Argument)
+		return t
+
+	case ast.ArrayExpression:
+		elems := make([]ast.Node, len(t.Elements))
+		for i, e := range t.Elements {
+			switch e.(type) {
+			case nil:
+			case ast.AssignmentExpression:
+				// An element with a default value.
+				elems[i] = d.visit(e)
+			default:
+				elems[i] = d.target(e)
+			}
+		}
+		t.Elements = elems
+		return t
+
+	case ast.ObjectExpression:
+		props := make([]ast.Property, len(t.Properties))
+		for i, p := range t.Properties {
+			if p.Computed {
+				p.Key = d.visit(p.Key)
+			}
+			if p.Value != nil {
+				p.Value = d.target(p.Value)
+			}
+			if p.DestructureInit != nil {
+				p.DestructureInit = d.visit(p.DestructureInit)
+			}
+			props[i] = p
+		}
+		t.Properties = props
+		return t
+	}
+	return d.visit(n)
+}
+
+func (d *definer) visit(n ast.Node) ast.Node {
+	switch t := n.(type) {
+	case ast.Identifier:
+		if v := d.replacement(t); v != nil {
+			return v
+		}
+		return t
+
+	case ast.MemberExpression:
+		if v := d.replacement(t); v != nil {
+			return v
+		}
+		t.Object = d.visit(t.Object)
+		if t.Computed {
+			t.Property = d.visit(t.Property)
+		}
+		return t
+
+	case ast.AssignmentExpression:
+		t.Left = d.target(t.Left)
+		t.Right = d.visit(t.Right)
+		return t
+
+	case ast.UpdateExpression:
+		t.Argument = d.target(t.Argument)
+		return t
+
+	case ast.UnaryExpression:
+		if t.Operator == ast.UnaryDeleteOp {
+			t.Argument = d.target(t.Argument)
+		} else {
+			t.Argument = d.visit(t.Argument)
+		}
+		return t
+
+	case ast.ForInStatement, ast.ForOfStatement:
+		saved := d.scope
+		d.scope = scope.New(t, d.scope)
+		defer func() { d.scope = saved }()
+		left := func(n ast.Node) ast.Node {
+			if _, ok := n.(ast.VariableDeclaration); ok {
+				return d.visit(n)
+			}
+			return d.target(n)
+		}
+		if f, ok := t.(ast.ForInStatement); ok {
+			f.Left, f.Right, f.Body = left(f.Left), d.visit(f.Right), d.visit(f.Body)
+			return f
+		}
+		f := t.(ast.ForOfStatement)
+		f.Left, f.Right, f.Body = left(f.Left), d.visit(f.Right), d.visit(f.Body)
+		return f
+
+	case ast.MethodDefinition:
+		if t.Computed {
+			t.Key = d.visit(t.Key)
+		}
+		t.Value = d.visit(t.Value).(ast.FunctionExpression)
+		return t
+
+	case ast.AccessorProperty:
+		if t.Computed {
+			t.Key = d.visit(t.Key)
+		}
+		if t.Value != nil {
+			t.Value = d.visit(t.Value)
+		}
+		return t
+
+	case ast.ObjectExpression:
+		props := make([]ast.Property, len(t.Properties))
+		for i, p := range t.Properties {
+			if p.Computed {
+				p.Key = d.visit(p.Key)
+			}
+			if p.Value != nil {
+				p.Value = d.visit(p.Value)
+			} else if id, ok := p.Key.(ast.Identifier); ok && p.Kind == ast.InitProperty {
+				// A shorthand property keeps its name when its value is
+				// replaced.
+				if v := d.replacement(id); v != nil {
+					p.Value = v
+				}
+			}
+			if p.DestructureInit != nil {
+				p.DestructureInit = d.visit(p.DestructureInit)
+			}
+			props[i] = p
+		}
+		t.Properties = props
+		return t
+	}
+
+	if s := scope.New(n, d.scope); s != nil {
+		saved := d.scope
+		d.scope = s
+		defer func() { d.scope = saved }()
+	}
+	return ast.MapChildren(n, d.visit)
+}
+
+// DefinePass returns a pass that replaces the names in defines. It has no
+// dependencies, so it runs before the built-in passes when it is added to a
+// pipeline first.
+func DefinePass(defines Defines) Pass {
+	return NewPass("define", nil, func(n ast.Node, ctx *Context) (ast.Node, error) {
+		return Define(n, defines), nil
+	})
+}
+
+// memberPath returns the dotted name of an identifier, or of a member
+// expression on one written with dots, along with the identifier it starts
+// with.
+func memberPath(n ast.Node) (path string, root string, ok bool) {
+	switch t := n.(type) {
+	case ast.Identifier:
+		return t.Name, t.Name, true
+	case ast.MemberExpression:
+		property, isIdent := t.Property.(ast.Identifier)
+		if t.Computed || !isIdent {
+			return "", "", false
+		}
+		path, root, ok := memberPath(t.Object)
+		return path + "." + property.Name, root, ok
+	}
+	return "", "", false
+}
@@ -0,0 +1,60 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+func TestDefine(t *testing.T) {
+	defines, err := ParseDefines(map[string]string{
+		"process.env.NODE_ENV": `"production"`,
+		"__DEV__":              "false",
+		"VERSION":              "[1, 2]",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := `if (process.env.NODE_ENV !== "production" && __DEV__) log(VERSION, {__DEV__}, typeof __DEV__);
+		process.env.NODE_ENV.length; process.env.OTHER; process["env"].NODE_ENV; o.__DEV__;
+		process.env.NODE_ENV = "test"; __DEV__++; delete process.env.NODE_ENV; for (__DEV__ in o);
+		[process.env.NODE_ENV, ...__DEV__] = [__DEV__]; ({a: __DEV__, b: VERSION = __DEV__} = o);
+		function f(__DEV__, process) { return __DEV__ || process.env.NODE_ENV; }`
+	expected := `if ("production" !== "production" && false) log([1, 2], {__DEV__: false}, typeof false);
+		"production".length; process.env.OTHER; process["env"].NODE_ENV; o.__DEV__;
+		process.env.NODE_ENV = "test"; __DEV__++; delete process.env.NODE_ENV; for (__DEV__ in o);
+		[process.env.NODE_ENV, ...__DEV__] = [false]; ({a: __DEV__, b: VERSION = false} = o);
+		function f(__DEV__, process) { return __DEV__ || process.env.NODE_ENV; }`
+	assertESTree(t, parseScript(t, expected), Define(parseScript(t, input), defines))
+}
+
+func TestDefineWithoutSpans(t *testing.T) {
+	defines, err := ParseDefines(map[string]string{"__DEV__": "false", "a.b": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Built nodes have no spans, so they can not be told apart by span.
+	dev := ast.Identifier{Name: "__DEV__"}
+	input := ast.ScriptNode{Body: []ast.Node{
+		ast.ExpressionStatement{Expression: ast.AssignmentExpression{Operator: ast.AssignmentOp, Left: dev, Right: dev}},
+		ast.ExpressionStatement{Expression: call(member(member(ast.Identifier{Name: "a"}, "b"), "c"), dev)},
+	}}
+	expected := `__DEV__ = false; (1).c(false);`
+	assertESTree(t, parseScript(t, expected), Define(input, defines))
+}
+
+func TestParseDefinesErrors(t *testing.T) {
+	tests := map[string]map[string]string{
+		"expected identifiers": {"process.env[0]": "1"},
+		"invalid value":        {"a": "1 +"},
+	}
+	for e, defines := range tests {
+		if _, err := ParseDefines(defines); err == nil || !strings.Contains(err.Error(), e) {
+			t.Errorf("expected error to contain %q, got %v", e, err)
+		}
+	}
+	if _, err := ParseDefines(map[string]string{"a..b": "1"}); err == nil {
+		t.Error("expected an error for an empty name")
+	}
+}
@@ -0,0 +1,837 @@
+package transform
+
+import (
+	"errors"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// LowerDestructuring rewrites destructuring patterns, default and rest
+// parameters, and spread elements into ES5 code.
+//
+// Patterns become a series of property reads, using temporary variables so
+// that each value is evaluated exactly once. Array patterns and spread
+// elements accept any iterable where Symbol is available, and array-like
+// objects otherwise. Functions that use any of these features in their
+// parameters get a plain parameter list, so their length may change, and
+// default values are evaluated in the scope of the function body.
+//
+// Rest parameters and spread arguments in super calls can not be expressed in
+// arrow functions and classes, so LowerArrowFunctions and LowerClasses need to
+// run first. An error is returned if any remain.
+func LowerDestructuring(n ast.Node) (ast.Node, error) {
+	names := newNameGenerator(n)
+	d := destructuring{names: names, helpers: newHelperSet(names, destructuringHelpers)}
+	switch t := n.(type) {
+	case ast.ScriptNode:
+		t.Body = d.program(t.Body)
+		return t, d.err
+
+	case ast.ModuleNode:
+		t.Body = d.program(t.Body)
+		return t, d.err
+	}
+	return nil, errors.New("destructuring transform requires a script or module")
+}
+
+// destructuringHelpers contains the source of the runtime helpers.
+var destructuringHelpers = map[string]string{
+	// toArray copies an iterable into a new array.
+	"toArray": `
+		function NAME(iter) {
+			var arr = [];
+			if (typeof Symbol !== "undefined" && iter != null && iter[Symbol.iterator] != null && !Array.isArray(iter)) {
+				for (var it = iter[Symbol.iterator](), step; !(step = it.next()).done;) arr.push(step.value);
+			} else if (iter != null && typeof iter.length === "number") {
+				for (var i = 0; i < iter.length; i++) arr.push(iter[i]);
+			} else {
+				throw new TypeError(iter + " is not iterable");
+			}
+			return arr;
+		}
+	`,
+	// slicedToArray returns an indexable object containing at least the
+	// first n values of an iterable, closing the iterator afterwards.
+	"slicedToArray": `
+		function NAME(iter, n) {
+			if (Array.isArray(iter)) return iter;
+			if (typeof Symbol !== "undefined" && iter != null && iter[Symbol.iterator] != null) {
+				var arr = [], it = iter[Symbol.iterator](), step;
+				while (arr.length < n) {
+					if ((step = it.next()).done) return arr;
+					arr.push(step.value);
+				}
+				if (typeof it["return"] === "function") it["return"]();
+				return arr;
+			}
+			if (iter != null && typeof iter.length === "number") return iter;
+			throw new TypeError(iter + " is not iterable");
+		}
+	`,
+	// objectSpread copies the own enumerable properties of each source onto
+	// target.
+	"objectSpread": `
+		function NAME(target) {
+			for (var i = 1; i < arguments.length; i++) {
+				var source = arguments[i];
+				if (source == null) continue;
+				var keys = Object.keys(Object(source));
+				if (typeof Object.getOwnPropertySymbols === "function") {
+					keys = keys.concat(Object.getOwnPropertySymbols(Object(source)).filter(function (sym) {
+						return Object.getOwnPropertyDescriptor(Object(source), sym).enumerable;
+					}));
+				}
+				for (var j = 0; j < keys.length; j++) {
+					Object.defineProperty(target, keys[j], { value: source[keys[j]], enumerable: true, configurable: true, writable: true });
+				}
+			}
+			return target;
+		}
+	`,
+	// objectWithoutProperties copies the own enumerable properties of source
+	// that are not excluded into a new object.
+	"objectWithoutProperties": `
+		function NAME(source, excluded) {
+			if (source == null) throw new TypeError("Cannot destructure " + source);
+			excluded = excluded.map(function (key) {
+				return typeof key === "symbol" ? key : String(key);
+			});
+			var target = {}, keys = Object.keys(Object(source));
+			if (typeof Object.getOwnPropertySymbols === "function") {
+				keys = keys.concat(Object.getOwnPropertySymbols(Object(source)).filter(function (sym) {
+					return Object.getOwnPropertyDescriptor(Object(source), sym).enumerable;
+				}));
+			}
+			for (var i = 0; i < keys.length; i++) {
+				if (excluded.indexOf(keys[i]) >= 0) continue;
+				Object.defineProperty(target, keys[i], { value: source[keys[i]], enumerable: true, configurable: true, writable: true });
+			}
+			return target;
+		}
+	`,
+}
+
+type destructuring struct {
+	names   *nameGenerator
+	helpers *helperSet
+
+	// temps contains temporaries that need to be declared in the current
+	// function.
+	temps *[]string
+
+	// err is the first error encountered.
+	err error
+}
+
+func (d *destructuring) fail(msg string) {
+	if d.err == nil {
+		d.err = errors.New(msg)
+	}
+}
+
+// temp returns a new temporary variable declared in the current function.
+func (d *destructuring) temp() ast.Identifier {
+	id := ast.Identifier{Name: d.names.generate("ref")}
+	*d.temps = append(*d.temps, id.Name)
+	return id
+}
+
+// declareTemps prepends a declaration of the temporaries in temps to body.
+func declareTemps(body []ast.Node, temps []string) []ast.Node {
+	if len(temps) == 0 {
+		return body
+	}
+	decl := ast.VariableDeclaration{Kind: ast.VarDeclaration}
+	for _, name := range temps {
+		decl.Declarations = append(decl.Declarations, varDecl(name, nil))
+	}
+	return prependStatements(body, decl)
+}
+
+func (d *destructuring) program(body []ast.Node) []ast.Node {
+	temps := []string{}
+	d.temps = &temps
+
+	out := make([]ast.Node, 0, len(body))
+	for _, stmt := range body {
+		e, ok := stmt.(ast.ExportDeclNode)
+		if !ok {
+			out = append(out, d.visit(stmt))
+			continue
+		}
+		decl, ok := e.Declaration.(ast.VariableDeclaration)
+		if !ok || !hasPatterns(decl) {
+			out = append(out, d.visit(e))
+			continue
+		}
+		// Temporaries must not be exported, so export the bound names
+		// separately.
+		e = ast.ExportDeclNode{NamedExports: []ast.NamedExport{}}
+		for _, name := range declaredNames(decl) {
+			e.NamedExports = append(e.NamedExports, ast.NamedExport{Identifier: name})
+		}
+		out = append(out, d.visit(decl), e)
+	}
+	return prependStatements(declareTemps(out, temps), d.helpers.decls...)
+}
+
+func hasPatterns(n ast.VariableDeclaration) bool {
+	for _, decl := range n.Declarations {
+		if decl.ID.Identifier == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// simpleParams returns whether a parameter list contains only identifiers.
+func simpleParams(p ast.FormalParameters) bool {
+	if p.RestParameter != "" {
+		return false
+	}
+	for _, e := range p.Parameters {
+		if e.Value.Identifier == "" || e.Init != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// function lowers the parameters of a function into statements at the start
+// of its body, and visits the body.
+func (d *destructuring) function(params ast.FormalParameters, body []ast.Node, arrow bool) (ast.FormalParameters, []ast.Node) {
+	saved := d.temps
+	temps := []string{}
+	d.temps = &temps
+	defer func() { d.temps = saved }()
+
+	if simpleParams(params) {
+		return params, declareTemps(d.statements(body), temps)
+	}
+
+	out := ast.FormalParameters{Parameters: []ast.BindingElement{}}
+	pre := []ast.Node{}
+	for _, p := range params.Parameters {
+		if p.Value.Identifier != "" {
+			out.Parameters = append(out.Parameters, ast.BindingElement{Value: p.Value})
+			if p.Init != nil {
+				id := ast.Identifier{Name: p.Value.Identifier}
+				pre = append(pre, ast.IfStatement{
+					Test: isUndefined(id),
+					Consequent: ast.ExpressionStatement{Expression: ast.AssignmentExpression{
+						Operator: ast.AssignmentOp,
+						Left:     id,
+						Right:    d.visit(p.Init),
+					}},
+				})
+			}
+			continue
+		}
+		id := ast.Identifier{Name: d.names.generate("ref")}
+		out.Parameters = append(out.Parameters, ast.BindingElement{Value: ast.BindingPattern{Identifier: id.Name}})
+		var value ast.Node = id
+		if p.Init != nil {
+			value = ast.ConditionalExpression{Test: isUndefined(id), Consequent: d.visit(p.Init), Alternate: id}
+		}
+		pre = append(pre, ast.VariableDeclaration{Kind: ast.VarDeclaration, Declarations: d.declarators(p.Value, value)})
+	}
+	if params.RestParameter != "" {
+		if arrow {
+			d.fail("rest parameters in arrow functions can not be lowered; lower arrow functions first")
+		}
+		pre = append(pre, ast.VariableDeclaration{
+			Kind: ast.VarDeclaration,
+			Declarations: []ast.VariableDeclarator{varDecl(params.RestParameter, call(
+				member(member(member(ast.Identifier{Name: "Array"}, "prototype"), "slice"), "call"),
+				ast.Identifier{Name: "arguments"},
+				numberLiteral(float64(len(params.Parameters))),
+			))},
+		})
+	}
+	body = d.statements(body)
+	return out, declareTemps(prependStatements(body, pre...), temps)
+}
+
+func (d *destructuring) functionExpression(n ast.FunctionExpression) ast.FunctionExpression {
+	b, ok := n.Body.(ast.BlockStatement)
+	if !ok {
+		// Concise body. It only needs to become a block if statements are
+		// added to it.
+		b = ast.BlockStatement{Body: []ast.Node{ast.ReturnStatement{Argument: n.Body}}}
+		b.SetStart(n.Body.Span().Start)
+		b.SetEnd(n.Body.Span().End)
+	}
+	var body []ast.Node
+	n.Params, body = d.function(n.Params, b.Body, n.Arrow)
+	if !ok && len(body) == 1 {
+		n.Body = body[0].(ast.ReturnStatement).Argument
+		return n
+	}
+	b.Body = body
+	n.Body = b
+	n.Expression = false
+	return n
+}
+
+func (d *destructuring) statements(body []ast.Node) []ast.Node {
+	out := make([]ast.Node, len(body))
+	for i, stmt := range body {
+		out[i] = d.visit(stmt)
+	}
+	return out
+}
+
+// declarators returns variable declarators that bind a pattern to value.
+func (d *destructuring) declarators(p ast.BindingPattern, value ast.Node) []ast.VariableDeclarator {
+	if p.Identifier != "" {
+		return []ast.VariableDeclarator{varDecl(p.Identifier, value)}
+	}
+	e := newDestructEmitter(d, d.bindingTarget(p), true)
+	e.emit(e.root, value, nil)
+	decls := make([]ast.VariableDeclarator, len(e.steps))
+	for i, s := range e.steps {
+		decls[i] = varDecl(s.target.(ast.Identifier).Name, s.value)
+	}
+	return decls
+}
+
+// assignment returns an expression that assigns value to an assignment
+// pattern. If used is set, the expression evaluates to value, like the
+// assignment it replaces.
+func (d *destructuring) assignment(pattern ast.Node, value ast.Node, used bool) ast.Node {
+	e := newDestructEmitter(d, d.expressionTarget(pattern), false)
+	if used {
+		value = e.reusable(value)
+	}
+	e.emit(e.root, value, nil)
+	exprs := []ast.Node{}
+	for _, s := range e.steps {
+		exprs = append(exprs, ast.AssignmentExpression{Operator: ast.AssignmentOp, Left: s.target, Right: s.value})
+	}
+	if used {
+		exprs = append(exprs, value)
+	}
+	if len(exprs) == 1 {
+		return exprs[0]
+	}
+	return ast.SequenceExpression{Expressions: exprs}
+}
+
+// isAssignmentPattern returns whether the target of an assignment is a
+// destructuring pattern.
+func isAssignmentPattern(n ast.Node) bool {
+	switch n.(type) {
+	case ast.ArrayExpression, ast.ObjectExpression:
+		return true
+	}
+	return false
+}
+
+// forHead lowers a pattern in the head of a for-in or for-of statement,
+// returning the new head and body.
+func (d *destructuring) forHead(left, body ast.Node) (ast.Node, ast.Node) {
+	var stmt ast.Node
+	lexical := false
+	switch t := left.(type) {
+	case ast.VariableDeclaration:
+		if !hasPatterns(t) {
+			return t, d.visit(body)
+		}
+		id := ast.Identifier{Name: d.names.generate("ref")}
+		lexical = t.Kind != ast.VarDeclaration
+		left = ast.VariableDeclaration{Kind: t.Kind, Declarations: []ast.VariableDeclarator{varDecl(id.Name, nil)}}
+		stmt = ast.VariableDeclaration{Kind: t.Kind, Declarations: d.declarators(t.Declarations[0].ID, id)}
+
+	default:
+		if !isAssignmentPattern(left) {
+			return d.visit(left), d.visit(body)
+		}
+		id := d.temp()
+		stmt = ast.ExpressionStatement{Expression: d.assignment(left, id, false)}
+		left = id
+	}
+
+	body = d.visit(body)
+	if b, ok := body.(ast.BlockStatement); ok && !lexical {
+		b.Body = append([]ast.Node{stmt}, b.Body...)
+		return left, b
+	}
+	// Lexical declarations in the body may shadow the bindings, so the body
+	// is kept as a separate block.
+	return left, ast.BlockStatement{Body: []ast.Node{stmt, body}}
+}
+
+// isUndefined returns an expression that tests whether n is undefined.
+func isUndefined(n ast.Node) ast.Node {
+	return ast.BinaryExpression{
+		Operator: ast.BinaryStrictEqualOp,
+		Left:     n,
+		Right:    ast.UnaryExpression{Operator: ast.UnaryVoidOp, Argument: numberLiteral(0)},
+	}
+}
+
+// spreadArray returns an expression that evaluates to an array of elements,
+// some of which may be spread elements.
+func (d *destructuring) spreadArray(elements []ast.Node) ast.Node {
+	// Runs of elements without spread stay as array literals, which are
+	// joined with the spread values using concat.
+	parts := []ast.Node{}
+	var run []ast.Node
+	for _, e := range elements {
+		if s, ok := e.(ast.SpreadElement); ok {
+			if run != nil {
+				parts = append(parts, ast.ArrayExpression{Elements: run})
+				run = nil
+			}
+			parts = append(parts, call(d.helpers.get("toArray"), d.visit(s.Argument)))
+			continue
+		}
+		if e != nil {
+			e = d.visit(e)
+		}
+		run = append(run, e)
+	}
+	if run != nil {
+		parts = append(parts, ast.ArrayExpression{Elements: run})
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return call(member(parts[0], "concat"), parts[1:]...)
+}
+
+func hasSpread(elements []ast.Node) bool {
+	for _, e := range elements {
+		if _, ok := e.(ast.SpreadElement); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// spreadCall lowers a call with spread arguments into a call to apply.
+func (d *destructuring) spreadCall(n ast.CallExpression) ast.Node {
+	var callee, this ast.Node
+	switch t := n.Callee.(type) {
+	case ast.Super:
+		d.fail("spread arguments in super calls can not be lowered; lower classes first")
+		return n
+	case ast.MemberExpression:
+		switch t.Object.(type) {
+		case ast.Identifier, ast.ThisExpression:
+			this = t.Object
+		case ast.Super:
+			this = ast.ThisExpression{}
+		default:
+			// The object is evaluated once, and used as both the object the
+			// method is found on and the value of this.
+			tmp := d.temp()
+			t.Object = ast.AssignmentExpression{Operator: ast.AssignmentOp, Left: tmp, Right: d.visit(t.Object)}
+			this = tmp
+		}
+		if t.Computed {
+			t.Property = d.visit(t.Property)
+		}
+		callee = t
+	default:
+		callee = d.visit(n.Callee)
+		this = ast.UnaryExpression{Operator: ast.UnaryVoidOp, Argument: numberLiteral(0)}
+	}
+	return call(member(callee, "apply"), this, d.spreadArray(n.Arguments))
+}
+
+// spreadObject lowers an object expression containing spread properties.
+func (d *destructuring) spreadObject(n ast.ObjectExpression) ast.Node {
+	// The first argument is the object that the properties are copied onto,
+	// so it must be a new object.
+	args := []ast.Node{}
+	var run []ast.Property
+	for i, p := range n.Properties {
+		if p.Kind == ast.SpreadProperty {
+			if run != nil || i == 0 {
+				args = append(args, d.visit(ast.ObjectExpression{Properties: run}))
+				run = nil
+			}
+			args = append(args, d.visit(p.Value))
+			continue
+		}
+		run = append(run, p)
+	}
+	if run != nil {
+		args = append(args, d.visit(ast.ObjectExpression{Properties: run}))
+	}
+	return call(d.helpers.get("objectSpread"), args...)
+}
+
+func hasSpreadProperty(n ast.ObjectExpression) bool {
+	for _, p := range n.Properties {
+		if p.Kind == ast.SpreadProperty {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *destructuring) visit(n ast.Node) ast.Node {
+	switch t := n.(type) {
+	case ast.FunctionDeclaration:
+		t.Params, t.Body.Body = d.function(t.Params, t.Body.Body, false)
+		return t
+
+	case ast.FunctionExpression:
+		return d.functionExpression(t)
+
+	case ast.VariableDeclaration:
+		decls := []ast.VariableDeclarator{}
+		for _, decl := range t.Declarations {
+			var init ast.Node
+			if decl.Init != nil {
+				init = d.visit(decl.Init)
+			}
+			if decl.ID.Identifier != "" {
+				decl.Init = init
+				decls = append(decls, decl)
+				continue
+			}
+			decls = append(decls, d.declarators(decl.ID, init)...)
+		}
+		t.Declarations = decls
+		return t
+
+	case ast.ForInStatement:
+		t.Right = d.visit(t.Right)
+		t.Left, t.Body = d.forHead(t.Left, t.Body)
+		return t
+
+	case ast.ForOfStatement:
+		t.Right = d.visit(t.Right)
+		t.Left, t.Body = d.forHead(t.Left, t.Body)
+		return t
+
+	case ast.CatchClause:
+		b := d.visit(t.Body).(ast.BlockStatement)
+		if t.Param.Identifier == "" && !isEmptyBinding(t.Param) {
+			id := ast.Identifier{Name: d.names.generate("ref")}
+			// The bindings are scoped to the catch clause, like the
+			// parameter.
+			decl := ast.VariableDeclaration{Kind: ast.LetDeclaration, Declarations: d.declarators(t.Param, id)}
+			b.Body = append([]ast.Node{decl}, b.Body...)
+			t.Param = ast.BindingPattern{Identifier: id.Name}
+		}
+		t.Body = b
+		return t
+
+	case ast.ExpressionStatement:
+		if a, ok := unparen(t.Expression).(ast.AssignmentExpression); ok && a.Operator == ast.AssignmentOp && isAssignmentPattern(a.Left) {
+			t.Expression = d.assignment(a.Left, d.visit(a.Right), false)
+			return t
+		}
+
+	case ast.AssignmentExpression:
+		if t.Operator == ast.AssignmentOp && isAssignmentPattern(t.Left) {
+			return d.assignment(t.Left, d.visit(t.Right), true)
+		}
+
+	case ast.ArrayExpression:
+		if hasSpread(t.Elements) {
+			return d.spreadArray(t.Elements)
+		}
+
+	case ast.CallExpression:
+		if hasSpread(t.Arguments) {
+			return d.spreadCall(t)
+		}
+
+	case ast.NewExpression:
+		if hasSpread(t.Arguments) {
+			// Function.prototype.bind can create a constructor with bound
+			// arguments; the first argument is the ignored this value.
+			bind := member(member(member(ast.Identifier{Name: "Function"}, "prototype"), "bind"), "apply")
+			args := d.spreadArray(append([]ast.Node{ast.NullLiteral{}}, t.Arguments...))
+			t.Callee = call(bind, d.visit(t.Callee), args)
+			t.Arguments = []ast.Node{}
+			return t
+		}
+
+	case ast.ObjectExpression:
+		if hasSpreadProperty(t) {
+			return d.spreadObject(t)
+		}
+	}
+	return ast.MapChildren(n, d.visit)
+}
+
+// destructTarget is the target of a destructuring binding or assignment: a
+// simple target, or a nested pattern.
+type destructTarget struct {
+	// simple is the identifier or member expression assigned to.
+	simple ast.Node
+
+	pattern *destructPattern
+}
+
+type destructPattern struct {
+	array    bool
+	elements []destructElement
+
+	// rest is the target of the rest element, if any.
+	rest *destructTarget
+}
+
+type destructElement struct {
+	// key is the property key in an object pattern.
+	key      ast.Node
+	computed bool
+
+	// target is nil for holes in array patterns.
+	target *destructTarget
+	init   ast.Node
+}
+
+// bindingTarget converts a binding pattern into a destructuring target.
+func (d *destructuring) bindingTarget(p ast.BindingPattern) *destructTarget {
+	switch {
+	case p.ArrayPattern != nil:
+		pat := &destructPattern{array: true}
+		for _, e := range p.ArrayPattern.Elements {
+			pat.elements = append(pat.elements, d.bindingElement(e.Value, e.Init))
+		}
+		if rest := p.ArrayPattern.RestElement; !isEmptyBinding(rest) {
+			pat.rest = d.bindingTarget(rest)
+		}
+		return &destructTarget{pattern: pat}
+
+	case p.ObjectPattern != nil:
+		pat := &destructPattern{}
+		for _, prop := range p.ObjectPattern.Properties {
+			value := prop.Value
+			if isEmptyBinding(value) {
+				value.Identifier = prop.PropertyName
+			}
+			e := d.bindingElement(value, prop.Init)
+			e.key, e.computed = prop.Key, prop.Computed
+			if e.key == nil {
+				e.key = ast.Identifier{Name: prop.PropertyName}
+			} else {
+				e.key = d.visit(e.key)
+			}
+			pat.elements = append(pat.elements, e)
+		}
+		if rest := p.ObjectPattern.RestElement; rest != "" {
+			pat.rest = &destructTarget{simple: ast.Identifier{Name: rest}}
+		}
+		return &destructTarget{pattern: pat}
+	}
+	return &destructTarget{simple: ast.Identifier{Name: p.Identifier}}
+}
+
+func (d *destructuring) bindingElement(p ast.BindingPattern, init ast.Node) destructElement {
+	e := destructElement{}
+	if !isEmptyBinding(p) {
+		e.target = d.bindingTarget(p)
+	}
+	if init != nil {
+		e.init = d.visit(init)
+	}
+	return e
+}
+
+// expressionTarget converts the target of a destructuring assignment into a
+// destructuring target.
+func (d *destructuring) expressionTarget(n ast.Node) *destructTarget {
+	switch t := unparen(n).(type) {
+
+	case ast.ArrayExpression:
+		pat := &destructPattern{array: true}
+		for _, e := range t.Elements {
+			switch e := e.(type) {
+			case nil:
+				pat.elements = append(pat.elements, destructElement{})
+			case ast.SpreadElement:
+				pat.rest = d.expressionTarget(e.Argument)
+			default:
+				pat.elements = append(pat.elements, d.expressionElement(e))
+			}
+		}
+		return &destructTarget{pattern: pat}
+
+	case ast.ObjectExpression:
+		pat := &destructPattern{}
+		for _, prop := range t.Properties {
+			if prop.Kind == ast.SpreadProperty {
+				pat.rest = d.expressionTarget(prop.Value)
+				continue
+			}
+			var e destructElement
+			if prop.Value == nil {
+				// Shorthand
+				e = destructElement{target: &destructTarget{simple: prop.Key}}
+				if prop.DestructureInit != nil {
+					e.init = d.visit(prop.DestructureInit)
+				}
+			} else {
+				e = d.expressionElement(prop.Value)
+			}
+			e.key, e.computed = prop.Key, prop.Computed
+			if e.computed {
+				e.key = d.visit(e.key)
+			}
+			pat.elements = append(pat.elements, e)
+		}
+		return &destructTarget{pattern: pat}
+	}
+	return &destructTarget{simple: d.visit(n)}
+}
+
+func (d *destructuring) expressionElement(n ast.Node) destructElement {
+	if a, ok := n.(ast.AssignmentExpression); ok && a.Operator == ast.AssignmentOp {
+		return destructElement{target: d.expressionTarget(a.Left), init: d.visit(a.Right)}
+	}
+	return destructElement{target: d.expressionTarget(n)}
+}
+
+// destructEmitter produces the assignments that make up a destructuring
+// binding or assignment.
+type destructEmitter struct {
+	d    *destructuring
+	root *destructTarget
+
+	// declare is set when the assignments are variable declarations, in
+	// which case temporaries are declared alongside them.
+	declare bool
+
+	// assigned contains the identifiers that are assigned to. Values held
+	// in these identifiers can not be read after the assignments start.
+	assigned map[string]bool
+
+	steps []destructStep
+}
+
+type destructStep struct {
+	target, value ast.Node
+}
+
+func newDestructEmitter(d *destructuring, root *destructTarget, declare bool) *destructEmitter {
+	e := &destructEmitter{d: d, root: root, declare: declare, assigned: map[string]bool{}}
+	e.collectAssigned(root)
+	return e
+}
+
+func (e *destructEmitter) collectAssigned(t *destructTarget) {
+	if t == nil {
+		return
+	}
+	if t.pattern == nil {
+		if id, ok := t.simple.(ast.Identifier); ok {
+			e.assigned[id.Name] = true
+		}
+		return
+	}
+	for _, el := range t.pattern.elements {
+		e.collectAssigned(el.target)
+	}
+	e.collectAssigned(t.pattern.rest)
+}
+
+// temp stores value in a new temporary.
+func (e *destructEmitter) temp(value ast.Node) ast.Identifier {
+	var id ast.Identifier
+	if e.declare {
+		id = ast.Identifier{Name: e.d.names.generate("ref")}
+	} else {
+		id = e.d.temp()
+	}
+	e.steps = append(e.steps, destructStep{id, value})
+	return id
+}
+
+// reusable returns an expression that evaluates to value and can be
+// evaluated more than once.
+func (e *destructEmitter) reusable(value ast.Node) ast.Node {
+	if id, ok := value.(ast.Identifier); ok && !e.assigned[id.Name] {
+		return id
+	}
+	return e.temp(value)
+}
+
+func (e *destructEmitter) emit(t *destructTarget, value, init ast.Node) {
+	if init != nil {
+		v := e.reusable(value)
+		value = ast.ConditionalExpression{Test: isUndefined(v), Consequent: init, Alternate: v}
+	}
+	if t.pattern == nil {
+		e.steps = append(e.steps, destructStep{t.simple, value})
+		return
+	}
+	p := t.pattern
+
+	if p.array {
+		n := len(p.elements)
+		if lit, ok := value.(ast.ArrayExpression); !ok || hasSpread(lit.Elements) {
+			if p.rest != nil {
+				value = call(e.d.helpers.get("toArray"), value)
+			} else {
+				value = call(e.d.helpers.get("slicedToArray"), value, numberLiteral(float64(n)))
+			}
+		}
+		arr := e.reusable(value)
+		for i, el := range p.elements {
+			if el.target != nil {
+				index := ast.MemberExpression{Object: arr, Property: numberLiteral(float64(i)), Computed: true}
+				e.emit(el.target, index, el.init)
+			}
+		}
+		if p.rest != nil {
+			e.emit(p.rest, call(member(arr, "slice"), numberLiteral(float64(n))), nil)
+		}
+		return
+	}
+
+	obj := e.reusable(value)
+	excluded := []ast.Node{}
+	for _, el := range p.elements {
+		key := el.key
+		switch k := key.(type) {
+		case ast.Identifier:
+			if !el.computed {
+				excluded = append(excluded, stringLiteral(k.Name))
+				break
+			}
+			if p.rest != nil {
+				key = e.temp(key)
+			}
+			excluded = append(excluded, key)
+		case ast.StringLiteral, ast.NumberLiteral:
+			excluded = append(excluded, key)
+		default:
+			// The key is needed again to exclude it from the rest.
+			if p.rest != nil {
+				key = e.temp(key)
+			}
+			excluded = append(excluded, key)
+		}
+		_, ident := key.(ast.Identifier)
+		prop := ast.MemberExpression{Object: obj, Property: key, Computed: el.computed || !ident}
+		e.emit(el.target, prop, el.init)
+	}
+	if p.rest != nil {
+		rest := call(e.d.helpers.get("objectWithoutProperties"), obj, ast.ArrayExpression{Elements: excluded})
+		e.emit(p.rest, rest, nil)
+	}
+}
+
+// isEmptyBinding returns whether p is unset, as for holes in array patterns.
+func isEmptyBinding(p ast.BindingPattern) bool {
+	return p.Identifier == "" && p.ObjectPattern == nil && p.ArrayPattern == nil
+}
+
+// unparen returns n without any enclosing parentheses.
+func unparen(n ast.Node) ast.Node {
+	for {
+		p, ok := n.(ast.ParenthesizedExpression)
+		if !ok {
+			return n
+		}
+		n = p.Expression
+	}
+}
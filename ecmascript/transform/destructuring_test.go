@@ -0,0 +1,131 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+func TestLowerDestructuring(t *testing.T) {
+	helper := func(name string) string {
+		return strings.Replace(destructuringHelpers[name], "NAME", "_"+name, 1)
+	}
+
+	tests := []struct {
+		name, input, expected string
+		mode                  parser.ParseMode
+	}{
+		{
+			name:     "array pattern",
+			input:    `var [a, , b = 1] = c;`,
+			expected: helper("slicedToArray") + `var _ref = _slicedToArray(c, 3), a = _ref[0], _ref2 = _ref[2], b = _ref2 === void 0 ? 1 : _ref2;`,
+		},
+		{
+			name:  "array rest",
+			input: `var [a, ...[b, c]] = d;`,
+			expected: helper("toArray") + helper("slicedToArray") +
+				`var _ref = _toArray(d), a = _ref[0], _ref2 = _slicedToArray(_ref.slice(1), 2), b = _ref2[0], c = _ref2[1];`,
+		},
+		{
+			name:  "object pattern",
+			input: `var {a, b: {c}, [k]: d = 1, 'e-f': e, 0: f, ...g} = h;`,
+			expected: helper("objectWithoutProperties") + `var a = h.a, _ref = h.b, c = _ref.c, _ref2 = k, _ref3 = h[_ref2], d = _ref3 === void 0 ? 1 : _ref3,
+				e = h['e-f'], f = h[0], g = _objectWithoutProperties(h, ["a", "b", _ref2, 'e-f', 0]);`,
+		},
+		{
+			name:     "assignment",
+			input:    `[a, b] = [b, a]; ({c = 1} = d);`,
+			expected: `var _ref, _ref2; _ref = [b, a], a = _ref[0], b = _ref[1]; _ref2 = d.c, c = _ref2 === void 0 ? 1 : _ref2;`,
+		},
+		{
+			name:     "assignment expression",
+			input:    `x = ({a, b: o.c} = d);`,
+			expected: `x = (a = d.a, o.c = d.b, d);`,
+		},
+		{
+			name:  "parameters",
+			input: `function f(a, [b] = [], {c} = {}, d = 1, ...e) { return a; }`,
+			expected: helper("slicedToArray") + `function f(a, _ref, _ref3, d) {
+				var _ref2 = _slicedToArray(_ref === void 0 ? [] : _ref, 1), b = _ref2[0];
+				var _ref4 = _ref3 === void 0 ? {} : _ref3, c = _ref4.c;
+				if (d === void 0) d = 1;
+				var e = Array.prototype.slice.call(arguments, 4);
+				return a;
+			}`,
+		},
+		{
+			name:     "arrow functions",
+			input:    `var f = ({a}) => a, g = (x) => [...x];`,
+			expected: helper("toArray") + `var f = (_ref) => { var a = _ref.a; return a; }, g = (x) => _toArray(x);`,
+		},
+		{
+			name:  "for-of heads",
+			input: `for (var [a, b] of c) f(a); for (const {d} of e) { g(d); } for ([h, i] of j) {}`,
+			expected: helper("slicedToArray") + `var _ref4, _ref5;
+				for (var _ref of c) { var _ref2 = _slicedToArray(_ref, 2), a = _ref2[0], b = _ref2[1]; f(a); }
+				for (const _ref3 of e) { const d = _ref3.d; { g(d); } }
+				for (_ref4 of j) { _ref5 = _slicedToArray(_ref4, 2), h = _ref5[0], i = _ref5[1]; }`,
+		},
+		{
+			name:     "catch parameter",
+			input:    `try {} catch ({message}) { f(message); }`,
+			expected: `try {} catch (_ref) { let message = _ref.message; f(message); }`,
+		},
+		{
+			name:  "spread elements",
+			input: `f(...a); o.m(a, ...b, c); g().m(...d); new F(...e); [x, , ...y];`,
+			expected: helper("toArray") + `var _ref;
+				f.apply(void 0, _toArray(a));
+				o.m.apply(o, [a].concat(_toArray(b), [c]));
+				(_ref = g()).m.apply(_ref, _toArray(d));
+				new (Function.prototype.bind.apply(F, [null].concat(_toArray(e))))();
+				[x, ,].concat(_toArray(y));`,
+		},
+		{
+			name:     "object spread",
+			input:    `var o = {a, ...b, c: 1}, p = {...q};`,
+			expected: helper("objectSpread") + `var o = _objectSpread({a}, b, {c: 1}), p = _objectSpread({}, q);`,
+		},
+		{
+			name:     "exports",
+			input:    `export var [a, b] = c;`,
+			expected: helper("slicedToArray") + `var _ref = _slicedToArray(c, 2), a = _ref[0], b = _ref[1]; export {a, b};`,
+			mode:     parser.ModuleMode,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := LowerDestructuring(parse(t, test.input, test.mode))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertESTree(t, parse(t, test.expected, test.mode), result)
+		})
+	}
+}
+
+func TestLowerDestructuringErrors(t *testing.T) {
+	tests := []struct {
+		name, input string
+	}{
+		{"arrow rest parameter", `var f = (...a) => a;`},
+		{"super spread", `class A extends B { constructor() { super(...a); } }`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := LowerDestructuring(parseScript(t, test.input)); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestLowerDestructuringRequiresProgram(t *testing.T) {
+	if _, err := LowerDestructuring(ast.ArrayExpression{}); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
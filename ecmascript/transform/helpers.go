@@ -0,0 +1,36 @@
+package transform
+
+import "github.com/jchv/cleansheets/ecmascript/ast"
+
+// helperSet emits runtime helper functions as they are needed. Helper sources
+// are function declarations named NAME; the real name of each helper is chosen
+// when it is first used, to avoid collisions.
+type helperSet struct {
+	names   *nameGenerator
+	sources map[string]string
+
+	// emitted maps helper names to the name they were emitted as.
+	emitted map[string]string
+
+	// decls contains the declarations of the emitted helpers.
+	decls []ast.Node
+}
+
+func newHelperSet(names *nameGenerator, sources map[string]string) *helperSet {
+	return &helperSet{names: names, sources: sources, emitted: map[string]string{}}
+}
+
+// get returns an identifier referring to the named helper, emitting it the
+// first time it is used.
+func (h *helperSet) get(name string) ast.Identifier {
+	if id, ok := h.emitted[name]; ok {
+		return ast.Identifier{Name: id}
+	}
+	id := h.names.generate(name)
+	h.emitted[name] = id
+	// Function names are not nodes, so the name is set after parsing.
+	decl := template(h.sources[name], nil)[0].(ast.FunctionDeclaration)
+	decl.ID = id
+	h.decls = append(h.decls, decl)
+	return ast.Identifier{Name: id}
+}
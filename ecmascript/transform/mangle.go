@@ -0,0 +1,350 @@
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// PropertyMap records the names that properties were mangled to, so that
+// later builds can give them the same names, along with the names that are
+// never to be mangled. It is stored as JSON, e.g.
+//
+//	{"reserved": ["_keep"], "renames": {"_count": "a", "_items": "b"}}
+type PropertyMap struct {
+	// Reserved are names that are neither mangled nor chosen as new names.
+	Reserved []string `json:"reserved,omitempty"`
+
+	// Renames maps each mangled name to its new name.
+	Renames map[string]string `json:"renames"`
+}
+
+// ReadPropertyMap reads a property map stored as JSON.
+func ReadPropertyMap(r io.Reader) (*PropertyMap, error) {
+	m := &PropertyMap{}
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, fmt.Errorf("invalid property map: %w", err)
+	}
+	return m, nil
+}
+
+// Write writes the property map as JSON, with its renames sorted by name.
+func (m *PropertyMap) Write(w io.Writer) error {
+	data, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// PropertyMangleOptions are options that adjust how properties are mangled.
+type PropertyMangleOptions struct {
+	// Pattern selects the property names to mangle, e.g. ^_ for names that
+	// start with an underscore. If nil, no properties are mangled.
+	Pattern *regexp.Regexp
+
+	// Map holds reserved names and the renames from earlier builds, and the
+	// new renames are added to it. If nil, nothing is reserved.
+	Map *PropertyMap
+}
+
+// MangleProperties renames the properties matching a pattern to short names,
+// across every module of a program. The modules must be all of the code that
+// uses the properties, such as each module of a graph.Graph: a property that
+// is renamed in one module but not in another no longer matches.
+//
+// Properties are renamed where they are written as names: in member
+// accesses, object literals, classes and object patterns. A name that is
+// also written quoted, as a string key or in a computed access such as
+// o["_x"], is left as it is, as are reserved names. New names are chosen so
+// that they do not clash with any other property name in the program; the
+// most used properties get the shortest names. Renames from Map are reused,
+// and it is an error if one of them now clashes with another property.
+//
+// Property names that are only made at runtime, such as in o["_" + k], can
+// not be seen, and so the properties they name must be reserved.
+func MangleProperties(modules []ast.Node, opt PropertyMangleOptions) ([]ast.Node, error) {
+	if opt.Map == nil {
+		opt.Map = &PropertyMap{}
+	}
+	if opt.Map.Renames == nil {
+		opt.Map.Renames = map[string]string{}
+	}
+	if opt.Pattern == nil {
+		return modules, nil
+	}
+
+	c := propertyCounter{counts: map[string]int{}, quoted: map[string]bool{}}
+	for _, n := range modules {
+		if n == nil {
+			return nil, errors.New("property mangling requires every module to be parsed")
+		}
+		c.visit(n)
+	}
+
+	reserved := map[string]bool{}
+	for _, name := range opt.Map.Reserved {
+		reserved[name] = true
+	}
+	m := propertyMangler{renames: map[string]string{}}
+	var candidates []string
+	for name := range c.counts {
+		if opt.Pattern.MatchString(name) && !reserved[name] && !c.quoted[name] {
+			candidates = append(candidates, name)
+		}
+	}
+
+	// Every name that stays as it is is taken, as are the new names of
+	// earlier builds, so that they stay free for the properties they name.
+	taken := map[string]bool{}
+	for name := range reserved {
+		taken[name] = true
+	}
+	for name := range c.counts {
+		if !opt.Pattern.MatchString(name) || reserved[name] || c.quoted[name] {
+			taken[name] = true
+		}
+	}
+	for name := range c.quoted {
+		taken[name] = true
+	}
+	for from, to := range opt.Map.Renames {
+		if taken[to] && c.counts[from] > 0 && !reserved[from] && !c.quoted[from] {
+			return nil, fmt.Errorf("property %s was renamed to %s, which is now the name of another property", from, to)
+		}
+	}
+	for _, to := range opt.Map.Renames {
+		taken[to] = true
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		return c.counts[a] > c.counts[b] || c.counts[a] == c.counts[b] && a < b
+	})
+	next := 0
+	for _, name := range candidates {
+		to, ok := opt.Map.Renames[name]
+		if !ok {
+			for to = shortName(next); taken[to]; to = shortName(next) {
+				next++
+			}
+			taken[to] = true
+			opt.Map.Renames[name] = to
+		}
+		m.renames[name] = to
+	}
+
+	out := make([]ast.Node, len(modules))
+	for i, n := range modules {
+		out[i] = m.visit(n)
+	}
+	return out, nil
+}
+
+// shortNameFirst and shortNameRest are the characters of the names chosen
+// for mangled properties.
+const (
+	shortNameFirst = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ$_"
+	shortNameRest  = shortNameFirst + "0123456789"
+)
+
+// shortName returns the i-th shortest property name.
+func shortName(i int) string {
+	b := []byte{shortNameFirst[i%len(shortNameFirst)]}
+	for i /= len(shortNameFirst); i > 0; i /= len(shortNameRest) {
+		i--
+		b = append(b, shortNameRest[i%len(shortNameRest)])
+	}
+	return string(b)
+}
+
+// propertyCounter counts the property names written in a program, and finds
+// the ones that are written quoted.
+type propertyCounter struct {
+	counts map[string]int
+	quoted map[string]bool
+}
+
+func (c *propertyCounter) key(key ast.Node, computed bool) {
+	switch k := key.(type) {
+	case ast.Identifier:
+		if !computed {
+			c.counts[k.Name]++
+		}
+	case ast.StringLiteral:
+		c.quoted[k.Value] = true
+	}
+}
+
+func (c *propertyCounter) visit(n ast.Node) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch t := n.(type) {
+		case ast.MemberExpression:
+			c.key(t.Property, t.Computed)
+		case ast.ObjectExpression:
+			for _, p := range t.Properties {
+				if p.Kind != ast.SpreadProperty {
+					c.key(p.Key, p.Computed)
+				}
+			}
+		case ast.MethodDefinition:
+			c.key(t.Key, t.Computed)
+		case ast.AccessorProperty:
+			c.key(t.Key, t.Computed)
+		case ast.VariableDeclaration:
+			for _, d := range t.Declarations {
+				c.pattern(d.ID)
+			}
+		case ast.CatchClause:
+			c.pattern(t.Param)
+		case ast.FunctionDeclaration:
+			c.elements(t.Params.Parameters)
+		case ast.FunctionExpression:
+			c.elements(t.Params.Parameters)
+		}
+		return true
+	})
+}
+
+// Binding patterns are not nodes, so the names in them are found by hand.
+// The keys and default values in them are nodes, which Inspect visits.
+func (c *propertyCounter) pattern(p ast.BindingPattern) {
+	switch {
+	case p.ObjectPattern != nil:
+		for _, bp := range p.ObjectPattern.Properties {
+			if bp.Key != nil {
+				c.key(bp.Key, bp.Computed)
+			} else {
+				c.counts[bp.PropertyName]++
+			}
+			c.pattern(bp.Value)
+		}
+
+	case p.ArrayPattern != nil:
+		c.elements(p.ArrayPattern.Elements)
+		c.pattern(p.ArrayPattern.RestElement)
+	}
+}
+
+func (c *propertyCounter) elements(elems []ast.BindingElement) {
+	for _, e := range elems {
+		c.pattern(e.Value)
+	}
+}
+
+// propertyMangler renames properties.
+type propertyMangler struct {
+	renames map[string]string
+}
+
+// key returns the renamed key of a property.
+func (m *propertyMangler) key(key ast.Node, computed bool) ast.Node {
+	if id, ok := key.(ast.Identifier); ok && !computed {
+		if to, ok := m.renames[id.Name]; ok {
+			id.Name = to
+			return id
+		}
+	}
+	return key
+}
+
+func (m *propertyMangler) visit(n ast.Node) ast.Node {
+	switch t := n.(type) {
+	case ast.MemberExpression:
+		if !t.Computed {
+			t.Property = m.key(t.Property, false)
+		}
+		return ast.MapChildren(t, m.visit)
+
+	case ast.ObjectExpression:
+		props := make([]ast.Property, len(t.Properties))
+		for i, p := range t.Properties {
+			if id, ok := p.Key.(ast.Identifier); ok && !p.Computed && p.Kind != ast.SpreadProperty {
+				// A shorthand property names a variable as well as a
+				// property, so it keeps the variable when it is renamed.
+				if _, renamed := m.renames[id.Name]; renamed && p.Value == nil {
+					p.Value = id
+					if p.DestructureInit != nil {
+						p.Value = ast.AssignmentExpression{Operator: ast.AssignmentOp, Left: id, Right: p.DestructureInit}
+						p.DestructureInit = nil
+					}
+				}
+				p.Key = m.key(p.Key, false)
+			}
+			props[i] = p
+		}
+		t.Properties = props
+		return ast.MapChildren(t, m.visit)
+
+	case ast.MethodDefinition:
+		t.Key = m.key(t.Key, t.Computed)
+		return ast.MapChildren(t, m.visit)
+
+	case ast.AccessorProperty:
+		t.Key = m.key(t.Key, t.Computed)
+		return ast.MapChildren(t, m.visit)
+
+	case ast.VariableDeclaration:
+		decls := make([]ast.VariableDeclarator, len(t.Declarations))
+		for i, d := range t.Declarations {
+			d.ID = m.pattern(d.ID)
+			decls[i] = d
+		}
+		t.Declarations = decls
+		return ast.MapChildren(t, m.visit)
+
+	case ast.CatchClause:
+		t.Param = m.pattern(t.Param)
+		return ast.MapChildren(t, m.visit)
+
+	case ast.FunctionDeclaration:
+		t.Params.Parameters = m.elements(t.Params.Parameters)
+		return ast.MapChildren(t, m.visit)
+
+	case ast.FunctionExpression:
+		t.Params.Parameters = m.elements(t.Params.Parameters)
+		return ast.MapChildren(t, m.visit)
+	}
+	return ast.MapChildren(n, m.visit)
+}
+
+func (m *propertyMangler) pattern(p ast.BindingPattern) ast.BindingPattern {
+	switch {
+	case p.ObjectPattern != nil:
+		op := *p.ObjectPattern
+		op.Properties = make([]ast.BindingProperty, len(p.ObjectPattern.Properties))
+		for i, bp := range p.ObjectPattern.Properties {
+			if to, ok := m.renames[bp.PropertyName]; ok && bp.Key == nil {
+				if bp.Value.Identifier == "" && bp.Value.ObjectPattern == nil && bp.Value.ArrayPattern == nil {
+					bp.Value.Identifier = bp.PropertyName
+				}
+				bp.PropertyName = to
+			}
+			bp.Value = m.pattern(bp.Value)
+			op.Properties[i] = bp
+		}
+		p.ObjectPattern = &op
+
+	case p.ArrayPattern != nil:
+		ap := *p.ArrayPattern
+		ap.Elements = m.elements(p.ArrayPattern.Elements)
+		ap.RestElement = m.pattern(p.ArrayPattern.RestElement)
+		p.ArrayPattern = &ap
+	}
+	return p
+}
+
+func (m *propertyMangler) elements(elems []ast.BindingElement) []ast.BindingElement {
+	out := make([]ast.BindingElement, len(elems))
+	for i, e := range elems {
+		e.Value = m.pattern(e.Value)
+		out[i] = e
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package transform
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+func TestMangleProperties(t *testing.T) {
+	inputs := []string{
+		`export class Counter { constructor() { this._count = 0; this._step = 1; } get _value() { return this._count; } _inc() { this._count += this._step; } }`,
+		`import {Counter} from "./counter.js"; var c = new Counter(); c._inc(); var o = {_count: 1, _count2: c._count, b: 2}; var {_count, _step: s = 1} = o; ({_step} = o); o["_quoted"] = o._quoted; o._keep; a._b;`,
+	}
+	expected := []string{
+		`export class Counter { constructor() { this.a = 0; this.c = 1; } get f() { return this.a; } _inc() { this.a += this.c; } }`,
+		`import {Counter} from "./counter.js"; var c = new Counter(); c._inc(); var o = {a: 1, e: c.a, b: 2}; var {a: _count, c: s = 1} = o; ({c: _step} = o); o["_quoted"] = o._quoted; o._keep; a.d;`,
+	}
+	var modules []ast.Node
+	for _, src := range inputs {
+		modules = append(modules, parse(t, src, parser.ModuleMode))
+	}
+	m := &PropertyMap{Reserved: []string{"_keep"}, Renames: map[string]string{"_inc": "_inc", "_old": "g"}}
+	result, err := MangleProperties(modules, PropertyMangleOptions{Pattern: regexp.MustCompile(`^_`), Map: m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range expected {
+		assertESTree(t, parse(t, expected[i], parser.ModuleMode), result[i])
+	}
+
+	renames := map[string]string{"_count": "a", "_step": "c", "_b": "d", "_count2": "e", "_value": "f", "_inc": "_inc", "_old": "g"}
+	if !reflect.DeepEqual(m.Renames, renames) {
+		t.Errorf("expected renames %v, got %v", renames, m.Renames)
+	}
+
+	// The map keeps the names the same in a later build, even as the counts
+	// change. Names only used in the earlier build may be reused.
+	var buf strings.Builder
+	if err := m.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	m, err = ReadPropertyMap(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = MangleProperties([]ast.Node{parseScript(t, `x._b._b._b._new; x._value;`)}, PropertyMangleOptions{Pattern: regexp.MustCompile(`^_`), Map: m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertESTree(t, parseScript(t, `x.d.d.d.b; x.f;`), result[0])
+}
+
+func TestMangleClash(t *testing.T) {
+	m := &PropertyMap{Renames: map[string]string{"_a": "x"}}
+	_, err := MangleProperties([]ast.Node{parseScript(t, `o._a; o.x;`)}, PropertyMangleOptions{Pattern: regexp.MustCompile(`^_`), Map: m})
+	if err == nil || !strings.Contains(err.Error(), "_a was renamed to x") {
+		t.Errorf("expected a clash, got %v", err)
+	}
+}
+
+func TestShortName(t *testing.T) {
+	for i, expected := range map[int]string{0: "a", 25: "z", 53: "_", 54: "aa", 55: "ba", 54 + 53: "_a", 54 + 54: "ab", 54 + 54*64: "aaa"} {
+		if name := shortName(i); name != expected {
+			t.Errorf("expected name %d to be %q, got %q", i, expected, name)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package transform
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// nameGenerator creates identifiers that do not collide with any name used in
+// an AST.
+type nameGenerator struct {
+	used map[string]bool
+}
+
+// newNameGenerator returns a name generator for the given AST. Rather than
+// performing scope analysis, every string stored in the AST is considered to
+// be in use. This overestimates the set of names in use, but is never wrong.
+func newNameGenerator(n ast.Node) *nameGenerator {
+	g := &nameGenerator{used: map[string]bool{}}
+	g.collect(reflect.ValueOf(n))
+	return g
+}
+
+func (g *nameGenerator) collect(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		g.used[v.String()] = true
+	case reflect.Interface, reflect.Ptr:
+		if !v.IsNil() {
+			g.collect(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				g.collect(v.Field(i))
+			}
+		}
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			g.collect(v.Index(i))
+		}
+	}
+}
+
+// generate returns an unused identifier based on hint and marks it as used.
+func (g *nameGenerator) generate(hint string) string {
+	base := "_" + identifierFrom(hint)
+	name := base
+	for i := 2; g.used[name]; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	g.used[name] = true
+	return name
+}
+
+// identifierFrom converts an arbitrary string, such as a module specifier,
+// into a camel-cased identifier.
+func identifierFrom(s string) string {
+	b := strings.Builder{}
+	upper := false
+	for _, r := range s {
+		switch {
+		case r == '_' || r == '$' || unicode.IsLetter(r) || (unicode.IsDigit(r) && b.Len() > 0):
+			if upper && b.Len() > 0 {
+				r = unicode.ToUpper(r)
+			}
+			b.WriteRune(r)
+			upper = false
+		default:
+			upper = true
+		}
+	}
+	if b.Len() == 0 {
+		return "ref"
+	}
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package transform
+
+import "github.com/jchv/cleansheets/ecmascript/ast"
+
+func call(callee ast.Node, args ...ast.Node) ast.CallExpression {
+	return ast.CallExpression{Callee: callee, Arguments: args}
+}
+
+func member(object ast.Node, property string) ast.MemberExpression {
+	return ast.MemberExpression{Object: object, Property: ast.Identifier{Name: property}}
+}
+
+func varDecl(name string, init ast.Node) ast.VariableDeclarator {
+	return ast.VariableDeclarator{ID: ast.BindingPattern{Identifier: name}, Init: init}
+}
+
+// mapParams maps the nodes in a parameter list, such as default values,
+// through f.
+func mapParams(p ast.FormalParameters, f func(ast.Node) ast.Node) ast.FormalParameters {
+	// FormalParameters is not a node itself, so wrap it in one to map it.
+	n := ast.MapChildren(ast.FunctionExpression{Params: p}, f)
+	return n.(ast.FunctionExpression).Params
+}
+
+// prependStatements inserts statements at the start of a statement list, after
+// the directive prologue.
+func prependStatements(body []ast.Node, stmts ...ast.Node) []ast.Node {
+	n := directives(body)
+	out := make([]ast.Node, 0, len(body)+len(stmts))
+	out = append(out, body[:n]...)
+	out = append(out, stmts...)
+	return append(out, body[n:]...)
+}
+
+// directives returns the length of the directive prologue in body.
+func directives(body []ast.Node) int {
+	for i, stmt := range body {
+		if s, ok := stmt.(ast.ExpressionStatement); !ok || s.Directive == "" {
+			return i
+		}
+	}
+	return len(body)
+}
+
+// isStrict returns whether the directive prologue of body enables strict mode.
+func isStrict(body []ast.Node) bool {
+	for _, stmt := range body[:directives(body)] {
+		if stmt.(ast.ExpressionStatement).Directive == "use strict" {
+			return true
+		}
+	}
+	return false
+}
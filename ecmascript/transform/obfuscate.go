@@ -0,0 +1,314 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// ObfuscationStrength selects the passes that Obfuscate applies. Each
+// strength also applies the passes of the strengths below it.
+type ObfuscationStrength int
+
+const (
+	// ObfuscateProperties rewrites property accesses such as a.b into
+	// computed accesses such as a["b"].
+	ObfuscateProperties ObfuscationStrength = iota + 1
+
+	// ObfuscateStrings encodes string literals, including the names of
+	// rewritten property accesses, and decodes them at runtime.
+	ObfuscateStrings
+
+	// ObfuscateControlFlow flattens the control flow of selected functions.
+	ObfuscateControlFlow
+)
+
+// ObfuscateOptions are options that adjust how code is obfuscated.
+type ObfuscateOptions struct {
+	// Strength selects the passes to apply. If zero, the code is left as it
+	// is.
+	Strength ObfuscationStrength
+
+	// Flatten contains the names of the functions whose control flow is
+	// flattened, when Strength is at least ObfuscateControlFlow.
+	Flatten []string
+
+	// Seed seeds the choice of string keys and states, so that the same input
+	// and options always produce the same output.
+	Seed int64
+}
+
+// Obfuscate returns a copy of a script or module that is harder to read but
+// behaves the same. Unlike minification, this makes the code larger and
+// slower.
+//
+// String literals are encoded by XORing their UTF-16 code units with a key
+// chosen for each literal, and are decoded by a helper function at runtime.
+// Directives and the keys of object literals, classes and binding patterns
+// are left as they are, since replacing them would change their meaning.
+//
+// A flattened function has each statement of its body moved into a case of a
+// switch statement inside of a loop, in shuffled order, with a variable
+// holding the next case to run. Function declarations are kept ahead of the
+// loop. Let, const and class declarations in the body would get a new
+// binding on each iteration, so functions that have them can not be
+// flattened unless block scoping has been lowered first.
+func Obfuscate(n ast.Node, opt ObfuscateOptions) (ast.Node, error) {
+	names := newNameGenerator(n)
+	o := obfuscator{
+		opt:     opt,
+		rand:    rand.New(rand.NewSource(opt.Seed)),
+		names:   names,
+		helpers: newHelperSet(names, obfuscationHelpers),
+		flatten: map[string]bool{},
+	}
+	if opt.Strength >= ObfuscateControlFlow {
+		for _, name := range opt.Flatten {
+			o.flatten[name] = true
+		}
+	}
+	switch t := n.(type) {
+	case ast.ScriptNode:
+		t = o.visit(t).(ast.ScriptNode)
+		t.Body = prependStatements(t.Body, o.helpers.decls...)
+		return t, o.err
+
+	case ast.ModuleNode:
+		t = o.visit(t).(ast.ModuleNode)
+		t.Body = prependStatements(t.Body, o.helpers.decls...)
+		return t, o.err
+	}
+	return nil, errors.New("obfuscation requires a script or module")
+}
+
+// ObfuscatePass returns a pass that obfuscates code with the given options.
+// It runs after the built-in passes, so that it sees what they produce.
+func ObfuscatePass(opt ObfuscateOptions) Pass {
+	deps := []string{"classes", "arrow-functions", "destructuring", "block-scoping", "commonjs"}
+	return NewPass("obfuscate", deps, func(n ast.Node, ctx *Context) (ast.Node, error) {
+		return Obfuscate(n, opt)
+	})
+}
+
+// obfuscationHelpers contains the source of the runtime helpers.
+var obfuscationHelpers = map[string]string{
+	"decodeString": `
+		function NAME(s, k) {
+			var r = "";
+			for (var i = 0; i < s.length; i++) r += String.fromCharCode(s.charCodeAt(i) ^ k);
+			return r;
+		}
+	`,
+}
+
+type obfuscator struct {
+	opt     ObfuscateOptions
+	rand    *rand.Rand
+	names   *nameGenerator
+	helpers *helperSet
+
+	// flatten contains the names of the functions to flatten.
+	flatten map[string]bool
+
+	// err is the first error encountered.
+	err error
+}
+
+func (o *obfuscator) visit(n ast.Node) ast.Node {
+	switch t := n.(type) {
+	case ast.ExpressionStatement:
+		if t.Directive != "" {
+			return t
+		}
+
+	case ast.StringLiteral:
+		return o.string(t)
+
+	case ast.MemberExpression:
+		if id, ok := t.Property.(ast.Identifier); ok && !t.Computed && o.opt.Strength >= ObfuscateProperties && !strings.Contains(id.Name, `\`) {
+			t.Property, t.Computed = stringLiteral(id.Name), true
+		}
+		return ast.MapChildren(t, o.visit)
+
+	case ast.ObjectExpression:
+		props := make([]ast.Property, len(t.Properties))
+		for i, p := range t.Properties {
+			p.Key = o.key(p.Key, p.Computed)
+			p.Value = o.visit(p.Value)
+			p.DestructureInit = o.visit(p.DestructureInit)
+			props[i] = p
+		}
+		t.Properties = props
+		return t
+
+	case ast.MethodDefinition:
+		t.Key = o.key(t.Key, t.Computed)
+		t.Value = o.visit(t.Value).(ast.FunctionExpression)
+		return t
+
+	case ast.AccessorProperty:
+		t.Key = o.key(t.Key, t.Computed)
+		t.Value = o.visit(t.Value)
+		return t
+
+	case ast.VariableDeclaration:
+		decls := make([]ast.VariableDeclarator, len(t.Declarations))
+		for i, d := range t.Declarations {
+			d.ID = o.pattern(d.ID)
+			d.Init = o.visit(d.Init)
+			decls[i] = d
+		}
+		t.Declarations = decls
+		return t
+
+	case ast.CatchClause:
+		t.Param = o.pattern(t.Param)
+		t.Body = o.visit(t.Body)
+		return t
+
+	case ast.FunctionDeclaration:
+		t.Params = o.params(t.Params)
+		t.Body = o.visit(t.Body).(ast.BlockStatement)
+		if o.flatten[t.ID] {
+			t.Body = o.flattenBody(t.ID, t.Body)
+		}
+		return t
+
+	case ast.FunctionExpression:
+		t.Params = o.params(t.Params)
+		t.Body = o.visit(t.Body)
+		if body, ok := t.Body.(ast.BlockStatement); ok && t.ID != "" && o.flatten[t.ID] {
+			t.Body = o.flattenBody(t.ID, body)
+		}
+		return t
+	}
+	return ast.MapChildren(n, o.visit)
+}
+
+// key visits the key of a property. Keys that are not computed are names
+// rather than expressions, so they are left as they are.
+func (o *obfuscator) key(key ast.Node, computed bool) ast.Node {
+	if !computed {
+		return key
+	}
+	return o.visit(key)
+}
+
+// Binding patterns are not nodes, so the keys and default values inside of
+// them are visited by hand.
+func (o *obfuscator) pattern(p ast.BindingPattern) ast.BindingPattern {
+	switch {
+	case p.ObjectPattern != nil:
+		op := *p.ObjectPattern
+		op.Properties = make([]ast.BindingProperty, len(p.ObjectPattern.Properties))
+		for i, bp := range p.ObjectPattern.Properties {
+			bp.Key = o.key(bp.Key, bp.Computed)
+			bp.Value = o.pattern(bp.Value)
+			bp.Init = o.visit(bp.Init)
+			op.Properties[i] = bp
+		}
+		p.ObjectPattern = &op
+
+	case p.ArrayPattern != nil:
+		ap := *p.ArrayPattern
+		ap.Elements = o.elements(p.ArrayPattern.Elements)
+		ap.RestElement = o.pattern(p.ArrayPattern.RestElement)
+		p.ArrayPattern = &ap
+	}
+	return p
+}
+
+func (o *obfuscator) elements(elems []ast.BindingElement) []ast.BindingElement {
+	out := make([]ast.BindingElement, len(elems))
+	for i, e := range elems {
+		e.Value = o.pattern(e.Value)
+		e.Init = o.visit(e.Init)
+		out[i] = e
+	}
+	return out
+}
+
+func (o *obfuscator) params(p ast.FormalParameters) ast.FormalParameters {
+	p.Parameters = o.elements(p.Parameters)
+	return p
+}
+
+// string returns an expression that decodes an encoded copy of s. The lexer
+// does not decode escape sequences yet, so strings that have them are left as
+// they are rather than encoding the wrong value.
+func (o *obfuscator) string(s ast.StringLiteral) ast.Node {
+	if o.opt.Strength < ObfuscateStrings || strings.Contains(s.Raw, `\`) {
+		return s
+	}
+	// XORing with a key below 0x400 keeps each code unit inside of its
+	// 1024-unit block, so surrogate pairs stay valid pairs.
+	key := 1 + o.rand.Intn(0x3ff)
+	units := utf16.Encode([]rune(s.Value))
+	for i := range units {
+		units[i] ^= uint16(key)
+	}
+	return call(o.helpers.get("decodeString"), stringLiteral(string(utf16.Decode(units))), numberLiteral(float64(key)))
+}
+
+// flattenBody moves the statements of a function body into the cases of a
+// switch statement that runs in a loop.
+func (o *obfuscator) flattenBody(name string, body ast.BlockStatement) ast.BlockStatement {
+	n := directives(body.Body)
+	head := append([]ast.Node{}, body.Body[:n]...)
+	stmts := []ast.Node{}
+	for _, stmt := range body.Body[n:] {
+		switch s := stmt.(type) {
+		case ast.FunctionDeclaration:
+			head = append(head, s)
+			continue
+		case ast.ClassDeclaration:
+			o.fail(name, "class")
+		case ast.VariableDeclaration:
+			if s.Kind != ast.VarDeclaration {
+				o.fail(name, "let or const")
+			}
+		}
+		stmts = append(stmts, stmt)
+	}
+	if len(stmts) == 0 {
+		return body
+	}
+
+	state := o.names.generate("state")
+	order := o.rand.Perm(len(stmts))
+	cases := make([]ast.SwitchCase, len(stmts))
+	for i, stmt := range stmts {
+		c := ast.SwitchCase{Test: numberLiteral(float64(order[i])), Consequent: []ast.Node{stmt}}
+		if i+1 < len(stmts) {
+			next := ast.AssignmentExpression{
+				Operator: ast.AssignmentOp,
+				Left:     ast.Identifier{Name: state},
+				Right:    numberLiteral(float64(order[i+1])),
+			}
+			c.Consequent = append(c.Consequent, ast.ExpressionStatement{Expression: next}, ast.ContinueStatement{})
+		} else {
+			c.Consequent = append(c.Consequent, ast.ReturnStatement{})
+		}
+		cases[order[i]] = c
+	}
+	body.Body = append(head,
+		ast.VariableDeclaration{
+			Kind:         ast.VarDeclaration,
+			Declarations: []ast.VariableDeclarator{varDecl(state, numberLiteral(float64(order[0])))},
+		},
+		ast.ForStatement{
+			Body: ast.SwitchStatement{Discriminant: ast.Identifier{Name: state}, Cases: cases},
+		},
+	)
+	return body
+}
+
+func (o *obfuscator) fail(name, kind string) {
+	if o.err == nil {
+		o.err = fmt.Errorf("can not flatten function %s: its body declares %s bindings", name, kind)
+	}
+}
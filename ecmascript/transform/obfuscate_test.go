@@ -0,0 +1,177 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+// decodeStrings replaces calls to the string decoder in n with the strings
+// they decode to, and removes the decoder.
+func decodeStrings(t *testing.T, n ast.Node) ast.Node {
+	t.Helper()
+	var decode func(n ast.Node) ast.Node
+	decode = func(n ast.Node) ast.Node {
+		if c, ok := n.(ast.CallExpression); ok {
+			if id, ok := c.Callee.(ast.Identifier); ok && id.Name == "_decodeString" {
+				s := c.Arguments[0].(ast.StringLiteral).Value
+				key := uint16(c.Arguments[1].(ast.NumberLiteral).Value)
+				if key == 0 || key >= 0x400 {
+					t.Errorf("unexpected key %d", key)
+				}
+				units := utf16.Encode([]rune(s))
+				for i := range units {
+					units[i] ^= key
+				}
+				return stringLiteral(string(utf16.Decode(units)))
+			}
+		}
+		return ast.MapChildren(n, decode)
+	}
+	body := []ast.Node{}
+	for _, stmt := range n.(ast.ScriptNode).Body {
+		if f, ok := stmt.(ast.FunctionDeclaration); ok && f.ID == "_decodeString" {
+			continue
+		}
+		body = append(body, decode(stmt))
+	}
+	return ast.ScriptNode{Body: body}
+}
+
+func TestObfuscateProperties(t *testing.T) {
+	input := `a.b; ({x: 1, y}).x; var {z} = a.z; super_.if = 1;`
+	expected := `a["b"]; ({x: 1, y})["x"]; var {z} = a["z"]; super_["if"] = 1;`
+	result, err := Obfuscate(parseScript(t, input), ObfuscateOptions{Strength: ObfuscateProperties})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertESTree(t, parseScript(t, expected), result)
+}
+
+func TestObfuscateStrings(t *testing.T) {
+	input := `"use strict";
+		var s = "héllo 😀", e = "";
+		o.p;
+		({ "k": s, ["c"]: 1 });
+		var { "k": v = "d", ["e"]: w } = o;
+		class A { m() { return "r"; } }
+		function f(x = "y") { "use strict"; switch (x) { case "y": return "\ud800"; } }`
+	expected := `"use strict";
+		var s = "héllo 😀", e = "";
+		o["p"];
+		({ "k": s, ["c"]: 1 });
+		var { "k": v = "d", ["e"]: w } = o;
+		class A { m() { return "r"; } }
+		function f(x = "y") { "use strict"; switch (x) { case "y": return "\ud800"; } }`
+
+	result, err := Obfuscate(parseScript(t, input), ObfuscateOptions{Strength: ObfuscateStrings})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := result.(ast.ScriptNode).Body
+	if f, ok := body[1].(ast.FunctionDeclaration); !ok || f.ID != "_decodeString" {
+		t.Fatalf("expected decoder after directives, got %#v", body[1])
+	}
+	if s, ok := body[2].(ast.VariableDeclaration).Declarations[0].Init.(ast.CallExpression); !ok {
+		t.Errorf("expected string to be encoded, got %#v", s)
+	}
+
+	// The escaped string is left as it is, keeping its raw text.
+	assertESTree(t, decodeStrings(t, parseScript(t, expected)), decodeStrings(t, result))
+}
+
+func TestObfuscateControlFlow(t *testing.T) {
+	input := `
+		function f(a) {
+			"use strict";
+			var b = a + 1;
+			if (b > 2) return b;
+			function g() { return a; }
+			b = g();
+			return b * 2;
+		}
+		function h() { a(); b(); }`
+
+	// The seed shuffles the four statements into states 2, 1, 3 and 0.
+	expected := `
+		function f(a) {
+			"use strict";
+			function g() { return a; }
+			var _state = 2;
+			for (;;) switch (_state) {
+			case 0: return b * 2; return;
+			case 1: if (b > 2) return b; _state = 3; continue;
+			case 2: var b = a + 1; _state = 1; continue;
+			case 3: b = g(); _state = 0; continue;
+			}
+		}
+		function h() { a(); b(); }`
+
+	result, err := Obfuscate(parseScript(t, input), ObfuscateOptions{Strength: ObfuscateControlFlow, Flatten: []string{"f"}, Seed: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertESTree(t, parseScript(t, expected), result)
+}
+
+func TestObfuscateFunctionExpression(t *testing.T) {
+	input := `x = function f() { a(); }; y = function g() { b(); };`
+	expected := `x = function f() { var _state = 0; for (;;) switch (_state) { case 0: a(); return; } }; y = function g() { b(); };`
+	result, err := Obfuscate(parseScript(t, input), ObfuscateOptions{Strength: ObfuscateControlFlow, Flatten: []string{"f"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertESTree(t, parseScript(t, expected), result)
+}
+
+func TestObfuscateStrength(t *testing.T) {
+	input := `function f() { let a = "s"; return a.b; }`
+	tests := []struct {
+		strength ObfuscationStrength
+		expected string
+		err      bool
+	}{
+		{0, input, false},
+		{ObfuscateProperties, `function f() { let a = "s"; return a["b"]; }`, false},
+		{ObfuscateStrings, `function f() { let a = "s"; return a["b"]; }`, false},
+		{ObfuscateControlFlow, "", true},
+	}
+	for _, test := range tests {
+		result, err := Obfuscate(parseScript(t, input), ObfuscateOptions{Strength: test.strength, Flatten: []string{"f"}})
+		if test.err {
+			if err == nil || !strings.Contains(err.Error(), "let or const") {
+				t.Errorf("strength %d: expected error, got %v", test.strength, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("strength %d: %v", test.strength, err)
+		}
+		assertESTree(t, decodeStrings(t, parseScript(t, test.expected)), decodeStrings(t, result))
+	}
+}
+
+func TestObfuscatePass(t *testing.T) {
+	p := NewPipeline(ObfuscatePass(ObfuscateOptions{Strength: ObfuscateControlFlow, Flatten: []string{"f"}}), BlockScopingPass)
+	result, _, err := p.Run(parse(t, `function f() { let a = 1; return a; }`, parser.ScriptMode))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `function f() {
+		var _state = 1;
+		for (;;) switch (_state) {
+		case 0: return a; return;
+		case 1: var a = 1; _state = 0; continue;
+		}
+	}`
+	assertESTree(t, parseScript(t, expected), result)
+}
+
+func TestObfuscateRequiresProgram(t *testing.T) {
+	if _, err := Obfuscate(ast.Identifier{Name: "a"}, ObfuscateOptions{}); err == nil {
+		t.Error("expected error obfuscating an expression")
+	}
+}
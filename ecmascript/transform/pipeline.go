@@ -0,0 +1,176 @@
+package transform
+
+import (
+	"fmt"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/scope"
+)
+
+// Pass is a transform that can be composed with others in a Pipeline.
+type Pass interface {
+	// Name returns the name that other passes refer to the pass by.
+	Name() string
+
+	// Dependencies returns the names of the passes that must run before this
+	// one. Dependencies that are not part of a pipeline are ignored.
+	Dependencies() []string
+
+	// Run transforms a script or module.
+	Run(n ast.Node, ctx *Context) (ast.Node, error)
+}
+
+// NewPass returns a pass that calls run.
+func NewPass(name string, dependencies []string, run func(n ast.Node, ctx *Context) (ast.Node, error)) Pass {
+	return funcPass{name: name, dependencies: dependencies, run: run}
+}
+
+type funcPass struct {
+	name         string
+	dependencies []string
+	run          func(n ast.Node, ctx *Context) (ast.Node, error)
+}
+
+func (p funcPass) Name() string                                   { return p.name }
+func (p funcPass) Dependencies() []string                         { return p.dependencies }
+func (p funcPass) Run(n ast.Node, ctx *Context) (ast.Node, error) { return p.run(n, ctx) }
+
+// The built-in passes. Each lowers a language feature using the function of
+// the same name, and depends on the passes that must see that feature before
+// it is lowered.
+var (
+	ClassesPass = NewPass("classes", nil, func(n ast.Node, ctx *Context) (ast.Node, error) {
+		return LowerClasses(n)
+	})
+	ArrowFunctionsPass = NewPass("arrow-functions", []string{"classes"}, func(n ast.Node, ctx *Context) (ast.Node, error) {
+		return LowerArrowFunctions(n)
+	})
+	DestructuringPass = NewPass("destructuring", []string{"classes", "arrow-functions"}, func(n ast.Node, ctx *Context) (ast.Node, error) {
+		return LowerDestructuring(n)
+	})
+	BlockScopingPass = NewPass("block-scoping", []string{"destructuring"}, func(n ast.Node, ctx *Context) (ast.Node, error) {
+		return LowerBlockScoping(n)
+	})
+	CommonJSPass = NewPass("commonjs", nil, func(n ast.Node, ctx *Context) (ast.Node, error) {
+		return ToCommonJS(n)
+	})
+)
+
+// Diagnostic is a message reported by a pass.
+type Diagnostic struct {
+	Pass     string
+	Location ast.Location
+	Message  string
+}
+
+// String returns the diagnostic formatted for display.
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s", &d.Location, d.Pass, d.Message)
+}
+
+// Context is shared by the passes in a pipeline run.
+type Context struct {
+	// Diagnostics contains the messages reported so far.
+	Diagnostics []Diagnostic
+
+	pass  string
+	root  ast.Node
+	scope *scope.Scope
+}
+
+// Report adds a diagnostic about a node from the current pass.
+func (c *Context) Report(n ast.Node, format string, args ...interface{}) {
+	d := Diagnostic{Pass: c.pass, Message: fmt.Sprintf(format, args...)}
+	if n != nil {
+		d.Location = n.Span().Start
+	}
+	c.Diagnostics = append(c.Diagnostics, d)
+}
+
+// Scope returns the top-level scope of the program, as passed to the current
+// pass. It is computed when first needed, and shared by the rest of the pass.
+func (c *Context) Scope() *scope.Scope {
+	if c.scope == nil {
+		c.scope = scope.New(c.root, nil)
+	}
+	return c.scope
+}
+
+// Pipeline runs a set of passes in dependency order.
+type Pipeline struct {
+	passes []Pass
+}
+
+// NewPipeline returns a pipeline containing the given passes.
+func NewPipeline(passes ...Pass) *Pipeline {
+	return &Pipeline{passes: passes}
+}
+
+// Add adds passes to the pipeline.
+func (p *Pipeline) Add(passes ...Pass) {
+	p.passes = append(p.passes, passes...)
+}
+
+// Order returns the passes in the order they run: each pass runs after its
+// dependencies, and otherwise in the order it was added.
+func (p *Pipeline) Order() ([]Pass, error) {
+	byName := map[string]Pass{}
+	for _, pass := range p.passes {
+		if _, ok := byName[pass.Name()]; ok {
+			return nil, fmt.Errorf("duplicate pass %q", pass.Name())
+		}
+		byName[pass.Name()] = pass
+	}
+
+	order := make([]Pass, 0, len(p.passes))
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var visit func(pass Pass) error
+	visit = func(pass Pass) error {
+		switch state[pass.Name()] {
+		case visiting:
+			return fmt.Errorf("pass %q depends on itself", pass.Name())
+		case done:
+			return nil
+		}
+		state[pass.Name()] = visiting
+		for _, name := range pass.Dependencies() {
+			if dep, ok := byName[name]; ok {
+				if err := visit(dep); err != nil {
+					return err
+				}
+			}
+		}
+		state[pass.Name()] = done
+		order = append(order, pass)
+		return nil
+	}
+	for _, pass := range p.passes {
+		if err := visit(pass); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// Run runs the passes on a script or module, returning the result and the
+// diagnostics they reported. It stops at the first pass that fails.
+func (p *Pipeline) Run(n ast.Node) (ast.Node, []Diagnostic, error) {
+	order, err := p.Order()
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx := &Context{root: n}
+	for _, pass := range order {
+		ctx.pass = pass.Name()
+		n, err = pass.Run(n, ctx)
+		if err != nil {
+			return nil, ctx.Diagnostics, fmt.Errorf("%s: %w", pass.Name(), err)
+		}
+		ctx.root, ctx.scope = n, nil
+	}
+	return n, ctx.Diagnostics, nil
+}
@@ -0,0 +1,125 @@
+package transform
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+func TestPipelineOrder(t *testing.T) {
+	pass := func(name string, deps ...string) Pass {
+		return NewPass(name, deps, func(n ast.Node, ctx *Context) (ast.Node, error) { return n, nil })
+	}
+
+	tests := []struct {
+		name     string
+		passes   []Pass
+		expected string
+		err      bool
+	}{
+		{
+			name:     "added order",
+			passes:   []Pass{pass("a"), pass("b"), pass("c")},
+			expected: "a b c",
+		},
+		{
+			name:     "dependencies first",
+			passes:   []Pass{pass("a", "c"), pass("b"), pass("c", "b")},
+			expected: "b c a",
+		},
+		{
+			name:     "missing dependency",
+			passes:   []Pass{pass("a", "x"), pass("b")},
+			expected: "a b",
+		},
+		{
+			name:   "cycle",
+			passes: []Pass{pass("a", "b"), pass("b", "a")},
+			err:    true,
+		},
+		{
+			name:   "duplicate",
+			passes: []Pass{pass("a"), pass("a")},
+			err:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			order, err := NewPipeline(test.passes...).Order()
+			if test.err {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, pass := range order {
+				names = append(names, pass.Name())
+			}
+			if result := strings.Join(names, " "); result != test.expected {
+				t.Errorf("expected order %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestPipelineRun(t *testing.T) {
+	p := NewPipeline(BlockScopingPass, DestructuringPass, ArrowFunctionsPass, ClassesPass)
+	result, diags, err := p.Run(parseScript(t, `"use strict"; class A { m(...a) { for (let [x] of a) f(() => x + this.y); } }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diags)
+	}
+	expected := `"use strict";
+		function _slicedToArray(iter, n) {` + strings.SplitN(destructuringHelpers["slicedToArray"], "{", 2)[1] + `
+		var A = (function () {
+			function A() {}
+			A.prototype.m = function () {
+				var a = Array.prototype.slice.call(arguments, 0);
+				var _this = this;
+				var _loop = function (_ref) {
+					var _ref2 = _slicedToArray(_ref, 1), x = _ref2[0];
+					f(function () { return x + _this.y; });
+				};
+				for (var _ref of a) {
+					_loop(_ref);
+				}
+			};
+			return A;
+		})();`
+	assertESTree(t, parseScript(t, expected), result)
+}
+
+func TestPipelineContext(t *testing.T) {
+	var names []string
+	report := NewPass("report", nil, func(n ast.Node, ctx *Context) (ast.Node, error) {
+		for name := range ctx.Scope().Bindings {
+			names = append(names, name)
+		}
+		ctx.Report(n.(ast.ScriptNode).Body[0], "found %d bindings", len(names))
+		return n, nil
+	})
+	fail := NewPass("fail", []string{"report"}, func(n ast.Node, ctx *Context) (ast.Node, error) {
+		return nil, errors.New("failed")
+	})
+
+	_, diags, err := NewPipeline(fail, report).Run(parse(t, `var a = 1;`, parser.ScriptMode))
+	if err == nil || err.Error() != "fail: failed" {
+		t.Errorf("expected error from fail pass, got %v", err)
+	}
+	if len(names) != 1 || names[0] != "a" {
+		t.Errorf("expected bindings [a], got %v", names)
+	}
+	if len(diags) != 1 || diags[0].Pass != "report" || diags[0].Message != "found 1 bindings" || diags[0].Location.Row != 1 {
+		t.Errorf("unexpected diagnostics %v", diags)
+	}
+}
@@ -0,0 +1,130 @@
+package transform
+
+import (
+	"errors"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// DefaultProfileCollector is the identifier that profiling calls are made on
+// by default.
+const DefaultProfileCollector = "__profile__"
+
+// ProfileOptions are options that adjust how code is instrumented for
+// profiling.
+type ProfileOptions struct {
+	// Path is the file path passed to the collector along with the location
+	// of each function.
+	Path string
+
+	// Collector is the identifier of the object that receives the profiling
+	// calls. If empty, DefaultProfileCollector is used.
+	Collector string
+}
+
+// InstrumentProfiling returns a copy of a script or module where each
+// function reports when it is entered and exited to a collector object.
+//
+// On entry, a function calls collector.enter(name, path, line, column), with
+// its position counted as in CoveragePosition, and keeps the result. When it
+// returns or throws, it passes that result to collector.exit. The collector
+// is expected to be defined before the instrumented code runs, and can time
+// calls using whatever clock is available to it.
+//
+// Functions are instrumented in place rather than wrapped, so their names,
+// lengths and spans are unchanged, and the statements of their bodies keep
+// their spans. For generators and async functions, the time between entry
+// and exit includes the time spent suspended, and entry is reported when a
+// generator is first resumed rather than when it is called.
+func InstrumentProfiling(n ast.Node, opt ProfileOptions) (ast.Node, error) {
+	if opt.Collector == "" {
+		opt.Collector = DefaultProfileCollector
+	}
+	names := newNameGenerator(n)
+	names.used[opt.Collector] = true
+	p := profileInstrumenter{opt: opt, ident: names.generate("profile")}
+	switch t := n.(type) {
+	case ast.ScriptNode:
+		return ast.MapChildren(t, p.visit), nil
+	case ast.ModuleNode:
+		return ast.MapChildren(t, p.visit), nil
+	}
+	return nil, errors.New("profiling instrumentation requires a script or module")
+}
+
+type profileInstrumenter struct {
+	opt ProfileOptions
+
+	// ident is the name of the variable that holds the result of the enter
+	// call in each function.
+	ident string
+}
+
+func (p *profileInstrumenter) visit(n ast.Node) ast.Node {
+	switch t := n.(type) {
+	case ast.FunctionDeclaration:
+		t.Params = mapParams(t.Params, p.visit)
+		t.Body = p.functionBody(t.Body, t.ID, t.Span())
+		return t
+
+	case ast.FunctionExpression:
+		return p.functionExpression(t, "")
+
+	case ast.MethodDefinition:
+		t.Key = p.visit(t.Key)
+		name := ""
+		if id, ok := t.Key.(ast.Identifier); ok && !t.Computed {
+			name = id.Name
+		}
+		t.Value = p.functionExpression(t.Value, name)
+		return t
+	}
+	return ast.MapChildren(n, p.visit)
+}
+
+func (p *profileInstrumenter) functionExpression(n ast.FunctionExpression, name string) ast.FunctionExpression {
+	if name == "" {
+		name = n.ID
+	}
+	n.Params = mapParams(n.Params, p.visit)
+	n.Body = p.functionBody(n.Body, name, n.Span())
+	return n
+}
+
+// functionBody instruments the body of a function, moving the statements
+// after the directive prologue into a try statement that reports the exit.
+func (p *profileInstrumenter) functionBody(body ast.Node, name string, span ast.Span) ast.BlockStatement {
+	b, ok := body.(ast.BlockStatement)
+	if !ok {
+		// Concise arrow function body.
+		b = ast.BlockStatement{Body: []ast.Node{ast.ReturnStatement{Argument: body}}}
+		b.SetStart(body.Span().Start)
+		b.SetEnd(body.Span().End)
+	}
+	b = ast.MapChildren(b, p.visit).(ast.BlockStatement)
+	if name == "" {
+		name = "(anonymous)"
+	}
+	pos := coveragePosition(span.Start)
+
+	// The variable is a binding, which substitute can not replace, so its
+	// name is written into the source.
+	stmts := template(`
+		var `+p.ident+` = COLLECTOR.enter(NAME, PATH, LINE, COLUMN);
+		try {} finally { COLLECTOR.exit(`+p.ident+`); }
+	`, map[string]ast.Node{
+		"COLLECTOR": ast.Identifier{Name: p.opt.Collector},
+		"NAME":      stringLiteral(name),
+		"PATH":      stringLiteral(p.opt.Path),
+		"LINE":      numberLiteral(float64(pos.Line)),
+		"COLUMN":    numberLiteral(float64(pos.Column)),
+	})
+	n := directives(b.Body)
+	block := ast.BlockStatement{Body: b.Body[n:]}
+	block.SetStart(b.Span().Start)
+	block.SetEnd(b.Span().End)
+	try := stmts[1].(ast.TryStatement)
+	try.Block = block
+	b.Body = append(b.Body[:n:n], stmts[0], try)
+	return b
+}
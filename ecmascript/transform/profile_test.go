@@ -0,0 +1,111 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+func TestInstrumentProfiling(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{
+			name:  "function declaration",
+			input: `function f(a) { return a; }`,
+			expected: `function f(a) {
+				var _profile = __profile__.enter("f", "test.js", 1, 0);
+				try { return a; } finally { __profile__.exit(_profile); }
+			}`,
+		},
+		{
+			name:  "directives",
+			input: `function f() { "use strict"; g(); }`,
+			expected: `function f() {
+				"use strict";
+				var _profile = __profile__.enter("f", "test.js", 1, 0);
+				try { g(); } finally { __profile__.exit(_profile); }
+			}`,
+		},
+		{
+			name:  "arrow function",
+			input: `x = a => a * 2;`,
+			expected: `x = a => {
+				var _profile = __profile__.enter("(anonymous)", "test.js", 1, 4);
+				try { return a * 2; } finally { __profile__.exit(_profile); }
+			};`,
+		},
+		{
+			name:  "nested functions and methods",
+			input: "class A {\n  m(f = function g() {}) { let x = 1; function h() { return x; } }\n}",
+			expected: `class A {
+				m(f = function g() {
+					var _profile = __profile__.enter("g", "test.js", 2, 8);
+					try {} finally { __profile__.exit(_profile); }
+				}) {
+					var _profile = __profile__.enter("m", "test.js", 2, 3);
+					try {
+						let x = 1;
+						function h() {
+							var _profile = __profile__.enter("h", "test.js", 2, 38);
+							try { return x; } finally { __profile__.exit(_profile); }
+						}
+					} finally { __profile__.exit(_profile); }
+				}
+			}`,
+		},
+		{
+			name:  "name collision",
+			input: `function _profile() {}`,
+			expected: `function _profile() {
+				var _profile2 = __profile__.enter("_profile", "test.js", 1, 0);
+				try {} finally { __profile__.exit(_profile2); }
+			}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := InstrumentProfiling(parseScript(t, test.input), ProfileOptions{Path: "test.js"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertESTree(t, parseScript(t, test.expected), result)
+		})
+	}
+}
+
+func TestInstrumentProfilingCollector(t *testing.T) {
+	result, err := InstrumentProfiling(parseScript(t, `function f() {}`), ProfileOptions{Collector: "_profile"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `function f() {
+		var _profile2 = _profile.enter("f", "", 1, 0);
+		try {} finally { _profile.exit(_profile2); }
+	}`
+	assertESTree(t, parseScript(t, expected), result)
+}
+
+func TestInstrumentProfilingSpans(t *testing.T) {
+	input := parseScript(t, "function f() {\n  g();\n}")
+	result, err := InstrumentProfiling(input, ProfileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := input.(ast.ScriptNode).Body[0].(ast.FunctionDeclaration)
+	out := result.(ast.ScriptNode).Body[0].(ast.FunctionDeclaration)
+	if out.Span() != in.Span() || out.Body.Span() != in.Body.Span() {
+		t.Errorf("expected function spans to be kept, got %v and %v", out.Span(), out.Body.Span())
+	}
+	call := out.Body.Body[1].(ast.TryStatement).Block.(ast.BlockStatement).Body[0]
+	if call.Span() != in.Body.Body[0].Span() {
+		t.Errorf("expected statement span %v, got %v", in.Body.Body[0].Span(), call.Span())
+	}
+}
+
+func TestInstrumentProfilingRequiresProgram(t *testing.T) {
+	if _, err := InstrumentProfiling(ast.Identifier{Name: "a"}, ProfileOptions{}); err == nil {
+		t.Error("expected error instrumenting an expression")
+	}
+}
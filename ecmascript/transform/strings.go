@@ -0,0 +1,426 @@
+package transform
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// OptimizeStrings returns a copy of a script or module in which string
+// literals take less code to write:
+//
+//   - Concatenations of adjacent string literals are merged, so "a" + "b"
+//     becomes "ab", and x + "a" + "b" becomes x + "ab".
+//   - A concatenation is written as a template literal where that is
+//     shorter, and a template literal as a concatenation, so "a" + x + "b"
+//     becomes `a${x}b`, and `a${x}` becomes "a" + x.
+//   - In a module, a string that is used often enough that declaring it once
+//     is shorter is declared as a constant at the top of the module, and its
+//     uses refer to the constant.
+//
+// Scripts do not get constants, since their top-level declarations would be
+// globals. A constant is not initialized until the module body runs, so a
+// function of the module that a circular import calls before then sees it
+// uninitialized, where it would have seen the literal.
+//
+// Strings whose source has escape sequences other than for quotes are left as
+// they are, since the lexer does not decode them yet, as are template
+// literals with escape sequences or line breaks. A value in a template literal
+// is converted to a string directly, where one added to a string is first
+// converted to a primitive with no hint, so an object whose valueOf and
+// toString methods give different strings is written differently after
+// either change; as with other minifiers, such objects are assumed not to be
+// concatenated.
+func OptimizeStrings(n ast.Node) (ast.Node, error) {
+	switch t := n.(type) {
+	case ast.ScriptNode:
+		return mergeStrings(t), nil
+
+	case ast.ModuleNode:
+		t = mergeStrings(t).(ast.ModuleNode)
+		return shareStrings(t), nil
+	}
+	return nil, errors.New("string optimization requires a script or module")
+}
+
+// OptimizeStringsPass is a pass that calls OptimizeStrings. It runs after the
+// built-in passes and defines, so that it sees the strings they add.
+var OptimizeStringsPass = NewPass("strings", []string{"define", "classes", "arrow-functions", "destructuring", "block-scoping", "commonjs"}, func(n ast.Node, ctx *Context) (ast.Node, error) {
+	return OptimizeStrings(n)
+})
+
+// plainString returns the value of n, if it is a string literal with no
+// escape sequences other than for quotes.
+func plainString(n ast.Node) (string, bool) {
+	s, ok := n.(ast.StringLiteral)
+	if !ok || len(s.Raw) < 2 {
+		return "", false
+	}
+	// The lexer does not decode escape sequences yet, so the value is found
+	// from the source, where only quotes can be escaped.
+	v := quoteEscapes.Replace(s.Raw[1 : len(s.Raw)-1])
+	return v, !strings.Contains(v, `\`)
+}
+
+// quoteEscapes decodes the escape sequences for quotes. Any other escape
+// sequence, including for a backslash, leaves a backslash behind.
+var quoteEscapes = strings.NewReplacer(`\"`, `"`, `\'`, `'`)
+
+// quoteString returns a string literal for s, which must not need escape
+// sequences other than for quotes, using the quote that needs the fewest.
+func quoteString(s string) ast.StringLiteral {
+	q := `"`
+	if strings.Count(s, `"`) > strings.Count(s, `'`) {
+		q = `'`
+	}
+	return ast.StringLiteral{Value: s, Raw: q + strings.ReplaceAll(s, q, `\`+q) + q}
+}
+
+// mergeStrings merges the concatenations of adjacent string literals in n,
+// and writes each concatenation or template literal in whichever of the two
+// forms is shorter.
+func mergeStrings(n ast.Node) ast.Node {
+	if t, ok := n.(ast.TaggedTemplateExpression); ok {
+		// The quasi of a tagged template is passed to the tag as it is.
+		t.Tag = mergeStrings(t.Tag)
+		exprs := make([]ast.Node, len(t.Quasi.Expressions))
+		for i, e := range t.Quasi.Expressions {
+			exprs[i] = mergeStrings(e)
+		}
+		t.Quasi.Expressions = exprs
+		return t
+	}
+	n = ast.MapChildren(n, mergeStrings)
+	switch t := n.(type) {
+	case ast.BinaryExpression:
+		if t.Operator != ast.BinaryAddOp {
+			return n
+		}
+	case ast.TemplateLiteral:
+	default:
+		return n
+	}
+	parts, size, ok := concatParts(n)
+	if !ok {
+		return n
+	}
+	parts = mergeParts(parts)
+	concat, concatSize := concatNode(parts)
+	template, templateSize := templateNode(parts)
+	switch {
+	case concatSize < size && concatSize <= templateSize:
+		return concat
+	case templateSize < size:
+		return template
+	}
+	return n
+}
+
+// concatPart is a part of a string made by concatenation: either a string,
+// or the value of expr converted to a string.
+type concatPart struct {
+	expr ast.Node
+	str  string
+}
+
+// concatParts returns the parts of n, if it is a string literal, a template
+// literal or a concatenation with one of them, along with the size of the
+// code that n takes to write, less that of the expressions in its parts.
+// Only the operands of a concatenation from the first string onwards are
+// parts; x + y + "a" adds x and y before converting them to a string.
+func concatParts(n ast.Node) ([]concatPart, int, bool) {
+	switch t := n.(type) {
+	case ast.StringLiteral:
+		if v, ok := plainString(t); ok {
+			return []concatPart{{str: v}}, len(t.Raw), true
+		}
+
+	case ast.TemplateLiteral:
+		size := len("``") + len("${}")*len(t.Expressions)
+		var parts []concatPart
+		for i, q := range t.Quasis {
+			if q.Invalid || strings.ContainsAny(q.Raw, "\\\n\r") {
+				return nil, 0, false
+			}
+			size += len(q.Raw)
+			parts = append(parts, concatPart{str: q.Cooked})
+			if i < len(t.Expressions) {
+				parts = append(parts, concatPart{expr: t.Expressions[i]})
+			}
+		}
+		return parts, size, true
+
+	case ast.BinaryExpression:
+		if t.Operator != ast.BinaryAddOp {
+			break
+		}
+		right, rightSize, ok := concatParts(t.Right)
+		if !ok {
+			right, rightSize = []concatPart{{expr: t.Right}}, 0
+		}
+		if left, leftSize, ok := concatParts(t.Left); ok {
+			return append(left, right...), leftSize + len("+") + rightSize, true
+		}
+		if ok {
+			// The right operand is a string, so the left one is converted to
+			// a string as it is.
+			return append([]concatPart{{expr: t.Left}}, right...), len("+") + rightSize, true
+		}
+	}
+	return nil, 0, false
+}
+
+// mergeParts merges adjacent strings in parts, so that strings and
+// expressions alternate, starting and ending with a string, which may be
+// empty.
+func mergeParts(parts []concatPart) []concatPart {
+	merged := []concatPart{{}}
+	for _, p := range parts {
+		last := &merged[len(merged)-1]
+		switch {
+		case p.expr == nil && last.expr == nil:
+			last.str += p.str
+		case p.expr != nil && last.expr != nil:
+			merged = append(merged, concatPart{}, p)
+		default:
+			merged = append(merged, p)
+		}
+	}
+	if merged[len(merged)-1].expr != nil {
+		merged = append(merged, concatPart{})
+	}
+	return merged
+}
+
+// concatNode returns the concatenation of parts, as merged by mergeParts, and
+// the size of its code less that of the expressions. Empty strings are left
+// out, except where one is needed to make the expressions next to it be
+// added as strings, as in "" + x + y.
+func concatNode(parts []concatPart) (ast.Node, int) {
+	var ops []ast.Node
+	size, str := 0, false
+	for i, p := range parts {
+		if p.expr != nil {
+			op, wrapped := concatOperand(p.expr, len(ops) == 0)
+			if wrapped {
+				size += len("()")
+			}
+			ops = append(ops, op)
+		} else if p.str != "" || !str && (i+2 >= len(parts) || parts[i+2].str == "") {
+			lit := quoteString(p.str)
+			size += len(lit.Raw)
+			ops = append(ops, lit)
+			str = true
+		}
+		str = str || len(ops) == 2
+	}
+	n := ops[0]
+	for _, op := range ops[1:] {
+		n = ast.BinaryExpression{Operator: ast.BinaryAddOp, Left: n, Right: op}
+	}
+	return n, size + len("+")*(len(ops)-1)
+}
+
+// concatOperand returns expr, in parentheses if it binds less tightly than
+// an operand of + must, along with whether it added them. The left operand
+// may itself be an addition.
+func concatOperand(expr ast.Node, left bool) (ast.Node, bool) {
+	switch t := expr.(type) {
+	case ast.BinaryExpression:
+		if t.Operator <= ast.BinaryModOp || left && t.Operator <= ast.BinarySubOp {
+			return expr, false
+		}
+	case ast.FunctionExpression:
+		if !t.Arrow {
+			return expr, false
+		}
+	case ast.AssignmentExpression, ast.ConditionalExpression, ast.SequenceExpression, ast.YieldExpression:
+	default:
+		return expr, false
+	}
+	return ast.ParenthesizedExpression{Expression: expr}, true
+}
+
+// templateNode returns the template literal for parts, as merged by
+// mergeParts, and the size of its code less that of the expressions.
+func templateNode(parts []concatPart) (ast.Node, int) {
+	n := ast.TemplateLiteral{}
+	size := len("``")
+	for _, p := range parts {
+		if p.expr != nil {
+			n.Expressions = append(n.Expressions, p.expr)
+			size += len("${}")
+			continue
+		}
+		raw := templateEscapes.Replace(p.str)
+		n.Quasis = append(n.Quasis, ast.TemplateElement{Raw: raw, Cooked: p.str})
+		size += len(raw)
+	}
+	return n, size
+}
+
+// templateEscapes escapes the text of a string, which has no backslashes, for
+// a template literal.
+var templateEscapes = strings.NewReplacer("`", "\\`", "${", "\\${")
+
+// shareStrings declares the strings of a module that are shorter to write as
+// constants.
+func shareStrings(n ast.ModuleNode) ast.Node {
+	var order []string
+	uses := map[string]int{}
+	raws := map[string]string{}
+	(&stringVisitor{f: func(s ast.StringLiteral) ast.Node {
+		if v, ok := plainString(s); ok {
+			if uses[v] == 0 {
+				order = append(order, v)
+				raws[v] = quoteString(v).Raw
+			}
+			uses[v]++
+		}
+		return s
+	}}).visit(n)
+
+	// Each shared string costs `_s=` and a comma, along with the literal,
+	// and each use of it then saves the length of the literal less that of
+	// the name. The declaration costs `const ;` on top of that.
+	const name = len("_s")
+	var candidates []string
+	saved := -len("const ;")
+	for _, s := range order {
+		k, raw := uses[s], len(raws[s])
+		if d := k*raw - (raw + name + 2 + k*name); d > 0 {
+			candidates = append(candidates, s)
+			saved += d
+		}
+	}
+	if saved <= 0 {
+		return n
+	}
+
+	names := newNameGenerator(n)
+	shared := map[string]ast.Identifier{}
+	decls := make([]ast.VariableDeclarator, len(candidates))
+	for i, s := range candidates {
+		id := ast.Identifier{Name: names.generate("s")}
+		shared[s] = id
+		decls[i] = varDecl(id.Name, quoteString(s))
+	}
+
+	n = (&stringVisitor{f: func(s ast.StringLiteral) ast.Node {
+		if v, ok := plainString(s); ok {
+			if id, ok := shared[v]; ok {
+				return id
+			}
+		}
+		return s
+	}}).visit(n).(ast.ModuleNode)
+	decl := ast.VariableDeclaration{Kind: ast.ConstDeclaration, Declarations: decls}
+	n.Body = prependStatements(n.Body, decl)
+	return n
+}
+
+// stringVisitor calls f for each string literal that is an expression, and
+// replaces it with the result. Directives and the keys of properties are
+// names rather than expressions, so they are left as they are.
+type stringVisitor struct {
+	f func(s ast.StringLiteral) ast.Node
+}
+
+func (v *stringVisitor) key(key ast.Node, computed bool) ast.Node {
+	if !computed {
+		return key
+	}
+	return v.visit(key)
+}
+
+func (v *stringVisitor) visit(n ast.Node) ast.Node {
+	switch t := n.(type) {
+	case ast.StringLiteral:
+		return v.f(t)
+
+	case ast.ExpressionStatement:
+		if t.Directive != "" {
+			return t
+		}
+
+	case ast.ObjectExpression:
+		props := make([]ast.Property, len(t.Properties))
+		for i, p := range t.Properties {
+			p.Key = v.key(p.Key, p.Computed)
+			p.Value = v.visit(p.Value)
+			p.DestructureInit = v.visit(p.DestructureInit)
+			props[i] = p
+		}
+		t.Properties = props
+		return t
+
+	case ast.MethodDefinition:
+		t.Key = v.key(t.Key, t.Computed)
+		t.Value = v.visit(t.Value).(ast.FunctionExpression)
+		return t
+
+	case ast.AccessorProperty:
+		t.Key = v.key(t.Key, t.Computed)
+		t.Value = v.visit(t.Value)
+		return t
+
+	case ast.VariableDeclaration:
+		decls := make([]ast.VariableDeclarator, len(t.Declarations))
+		for i, d := range t.Declarations {
+			d.ID = v.pattern(d.ID)
+			d.Init = v.visit(d.Init)
+			decls[i] = d
+		}
+		t.Declarations = decls
+		return t
+
+	case ast.CatchClause:
+		t.Param = v.pattern(t.Param)
+		t.Body = v.visit(t.Body)
+		return t
+
+	case ast.FunctionDeclaration:
+		t.Params.Parameters = v.elements(t.Params.Parameters)
+		t.Body = v.visit(t.Body).(ast.BlockStatement)
+		return t
+
+	case ast.FunctionExpression:
+		t.Params.Parameters = v.elements(t.Params.Parameters)
+		t.Body = v.visit(t.Body)
+		return t
+	}
+	return ast.MapChildren(n, v.visit)
+}
+
+func (v *stringVisitor) pattern(p ast.BindingPattern) ast.BindingPattern {
+	switch {
+	case p.ObjectPattern != nil:
+		op := *p.ObjectPattern
+		op.Properties = make([]ast.BindingProperty, len(p.ObjectPattern.Properties))
+		for i, bp := range p.ObjectPattern.Properties {
+			bp.Key = v.key(bp.Key, bp.Computed)
+			bp.Value = v.pattern(bp.Value)
+			bp.Init = v.visit(bp.Init)
+			op.Properties[i] = bp
+		}
+		p.ObjectPattern = &op
+
+	case p.ArrayPattern != nil:
+		ap := *p.ArrayPattern
+		ap.Elements = v.elements(p.ArrayPattern.Elements)
+		ap.RestElement = v.pattern(p.ArrayPattern.RestElement)
+		p.ArrayPattern = &ap
+	}
+	return p
+}
+
+func (v *stringVisitor) elements(elems []ast.BindingElement) []ast.BindingElement {
+	out := make([]ast.BindingElement, len(elems))
+	for i, e := range elems {
+		e.Value = v.pattern(e.Value)
+		e.Init = v.visit(e.Init)
+		out[i] = e
+	}
+	return out
+}
@@ -0,0 +1,128 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+func TestOptimizeStrings(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+		mode                  parser.ParseMode
+	}{
+		{
+			name:     "adjacent strings",
+			input:    `f("a" + "b" + 'c', "a" + x + "b" + "c", x + "a" + y, "a" + 1 + "b", "a\n" + "b");`,
+			expected: "f(\"abc\", `a${x}bc`, x + \"a\" + y, `a${1}b`, \"a\\n\" + \"b\");",
+			mode:     parser.ScriptMode,
+		},
+		{
+			name:     "concatenations as templates",
+			input:    "f(\"a\" + x, \"a\" + x + \"b\", x + y + \"a\" + z + \"b\", \"a`\" + x + \"b\" + y + \"c\");",
+			expected: "f(\"a\" + x, `a${x}b`, x + y + \"a\" + z + \"b\", `a\\`${x}b${y}c`);",
+			mode:     parser.ScriptMode,
+		},
+		{
+			name:     "templates as concatenations",
+			input:    "f(`a${x}`, `${x}${y}`, `${x}`, `a${x}b${y}c`, `a${x}` + \"b\", \"a\" + `b${x}c` + \"d\", `a\\n${x}`);",
+			expected: "f(\"a\" + x, \"\" + x + y, \"\" + x, `a${x}b${y}c`, `a${x}b`, `ab${x}cd`, `a\\n${x}`);",
+			mode:     parser.ScriptMode,
+		},
+		{
+			name:     "tagged templates",
+			input:    "tag`${x}`; tag`a${\"b\" + \"c\"}`;",
+			expected: "tag`${x}`; tag`a${\"bc\"}`;",
+			mode:     parser.ScriptMode,
+		},
+		{
+			name:     "scripts share nothing",
+			input:    `f("a long enough string", "a long enough string", "a long enough string");`,
+			expected: `f("a long enough string", "a long enough string", "a long enough string");`,
+			mode:     parser.ScriptMode,
+		},
+		{
+			name: "shared strings",
+			input: `"use strict";
+				f("a long enough string", "a long " + "enough string", "a long enough string", "short", "short");
+				var {"a long enough string": x = "a long enough string"} = o;
+				o = {"a long enough string": 1, ["a long enough string"]: 2};
+				let _s;`,
+			expected: `"use strict";
+				const _s2 = "a long enough string";
+				f(_s2, _s2, _s2, "short", "short");
+				var {"a long enough string": x = _s2} = o;
+				o = {"a long enough string": 1, [_s2]: 2};
+				let _s;`,
+			mode: parser.ModuleMode,
+		},
+		{
+			name:     "rarely used strings",
+			input:    `f("short", "short", "short", "a string", "a string");`,
+			expected: `f("short", "short", "short", "a string", "a string");`,
+			mode:     parser.ModuleMode,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := OptimizeStrings(parse(t, test.input, test.mode))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertESTree(t, parse(t, test.expected, test.mode), result)
+		})
+	}
+}
+
+// The values of string literals that are parsed are not decoded yet, so the
+// escaped quotes are checked by hand.
+func TestOptimizeStringsQuotes(t *testing.T) {
+	tests := map[string]string{
+		`'a"' + 'b';`:             `'a"b'`,
+		`'"' + "'" + '"';`:        "`\"'\"`",
+		`"it's" + ' \'quoted\'';`: `"it's 'quoted'"`,
+		`"\\" + "a";`:             `"\\" + "a"`,
+	}
+	for input, expected := range tests {
+		result, err := OptimizeStrings(parseScript(t, input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var raw string
+		switch e := result.(ast.ScriptNode).Body[0].(ast.ExpressionStatement).Expression.(type) {
+		case ast.StringLiteral:
+			raw = e.Raw
+		case ast.TemplateLiteral:
+			raw = "`" + e.Quasis[0].Raw + "`"
+		case ast.BinaryExpression:
+			raw = e.Left.(ast.StringLiteral).Raw + " + " + e.Right.(ast.StringLiteral).Raw
+		}
+		if raw != expected {
+			t.Errorf("%s: expected %s, got %s", input, expected, raw)
+		}
+	}
+	if _, err := OptimizeStrings(ast.StringLiteral{}); err == nil {
+		t.Error("expected an error for an expression")
+	}
+}
+
+// Operands of the concatenation made from a template literal are put in
+// parentheses where they bind less tightly than +, which the ESTree does not
+// show.
+func TestOptimizeStringsParentheses(t *testing.T) {
+	result, err := OptimizeStrings(parseScript(t, "`${x}${y - z}`;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, ok := result.(ast.ScriptNode).Body[0].(ast.ExpressionStatement).Expression.(ast.BinaryExpression)
+	if !ok {
+		t.Fatalf("expected a concatenation, got %T", result.(ast.ScriptNode).Body[0].(ast.ExpressionStatement).Expression)
+	}
+	if _, ok := e.Right.(ast.ParenthesizedExpression); !ok {
+		t.Errorf("expected y - z in parentheses, got %T", e.Right)
+	}
+	if _, ok := e.Left.(ast.BinaryExpression).Right.(ast.Identifier); !ok {
+		t.Errorf("expected x without parentheses, got %T", e.Left.(ast.BinaryExpression).Right)
+	}
+}
@@ -0,0 +1,141 @@
+package transform
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Feature is a language feature that can be lowered by a pass.
+type Feature string
+
+// The features lowered by the built-in passes. Each has the name of the pass
+// that lowers it.
+const (
+	FeatureClasses        Feature = "classes"
+	FeatureArrowFunctions Feature = "arrow-functions"
+	FeatureDestructuring  Feature = "destructuring"
+	FeatureBlockScoping   Feature = "block-scoping"
+)
+
+// Features lists the built-in features along with the edition of the
+// language that introduced them, in the order their passes are added to a
+// pipeline.
+var Features = []struct {
+	Feature Feature
+	Edition int
+}{
+	{FeatureClasses, 2015},
+	{FeatureArrowFunctions, 2015},
+	{FeatureDestructuring, 2015},
+	{FeatureBlockScoping, 2015},
+}
+
+// Target is the set of features supported by the environment that code is
+// compiled for.
+type Target map[Feature]bool
+
+// Edition returns a target supporting the features introduced in or before
+// the given edition of the language, such as 2017. Editions before 2015 are
+// treated as ES5.
+func Edition(year int) Target {
+	t := Target{}
+	for _, f := range Features {
+		if f.Edition <= year {
+			t[f.Feature] = true
+		}
+	}
+	return t
+}
+
+// ParseTarget parses a comma-separated target description. Each item is
+// either an edition, such as "es5", "es2017" or "esnext", or the name of a
+// feature in Features that is supported in addition to those of the editions.
+// Other names are reported as an error, so that a misspelled feature does not
+// go unnoticed.
+func ParseTarget(s string) (Target, error) {
+	t := Target{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		switch {
+		case item == "esnext":
+			for _, f := range Features {
+				t[f.Feature] = true
+			}
+		case item == "es5" || item == "es3":
+			// No features to add.
+		case strings.HasPrefix(item, "es"):
+			year, err := strconv.Atoi(item[2:])
+			if err != nil {
+				return nil, fmt.Errorf("unknown target %q", item)
+			}
+			if year < 2015 {
+				// Accept the old edition numbers, e.g. es6 for es2015.
+				year += 2009
+			}
+			for f := range Edition(year) {
+				t[f] = true
+			}
+		case item != "":
+			if !isFeature(Feature(item)) {
+				return nil, fmt.Errorf("unknown feature %q", item)
+			}
+			t[Feature(item)] = true
+		}
+	}
+	return t, nil
+}
+
+// isFeature returns whether f is one of the built-in features.
+func isFeature(f Feature) bool {
+	for _, g := range Features {
+		if g.Feature == f {
+			return true
+		}
+	}
+	return false
+}
+
+// Transforms maps features to the passes that lower them.
+type Transforms map[Feature]Pass
+
+// DefaultTransforms returns the built-in passes for each feature. The result
+// is a new map, so entries can be replaced with other passes, or deleted to
+// leave a feature as it is.
+func DefaultTransforms() Transforms {
+	return Transforms{
+		FeatureClasses:        ClassesPass,
+		FeatureArrowFunctions: ArrowFunctionsPass,
+		FeatureDestructuring:  DestructuringPass,
+		FeatureBlockScoping:   BlockScopingPass,
+	}
+}
+
+// Pipeline returns a pipeline that lowers each feature that target does not
+// support. Built-in features are added in the order of Features, followed by
+// any others in order of name.
+func (t Transforms) Pipeline(target Target) *Pipeline {
+	features := make([]Feature, 0, len(t))
+	known := map[Feature]bool{}
+	for _, f := range Features {
+		known[f.Feature] = true
+		features = append(features, f.Feature)
+	}
+	extra := []Feature{}
+	for f := range t {
+		if !known[f] {
+			extra = append(extra, f)
+		}
+	}
+	sort.Slice(extra, func(i, j int) bool { return extra[i] < extra[j] })
+	features = append(features, extra...)
+
+	p := NewPipeline()
+	for _, f := range features {
+		if pass := t[f]; pass != nil && !target[f] {
+			p.Add(pass)
+		}
+	}
+	return p
+}
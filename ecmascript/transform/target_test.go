@@ -0,0 +1,75 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+func TestTargetPipeline(t *testing.T) {
+	pass := func(name string) Pass {
+		return NewPass(name, nil, func(n ast.Node, ctx *Context) (ast.Node, error) { return n, nil })
+	}
+
+	tests := []struct {
+		target     string
+		transforms func(Transforms)
+		expected   string
+		err        bool
+	}{
+		{target: "es5", expected: "classes arrow-functions destructuring block-scoping"},
+		{target: "es2015", expected: ""},
+		{target: "ES6", expected: ""},
+		{target: "es2017", expected: ""},
+		{target: "esnext", expected: ""},
+		{target: "es5, classes, arrow-functions", expected: "destructuring block-scoping"},
+		{target: "block-scoping", expected: "classes arrow-functions destructuring"},
+		{
+			target:     "es5",
+			transforms: func(t Transforms) { delete(t, FeatureClasses) },
+			expected:   "arrow-functions destructuring block-scoping",
+		},
+		{
+			target:     "es5,destructuring",
+			transforms: func(t Transforms) { t[FeatureClasses] = pass("custom"); t["other"] = pass("other") },
+			expected:   "custom arrow-functions block-scoping other",
+		},
+		{target: "es20x", err: true},
+		{target: "es5,arrow-function", err: true},
+		{target: "other", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.target, func(t *testing.T) {
+			target, err := ParseTarget(test.target)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected error, got %v", target)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			transforms := DefaultTransforms()
+			if test.transforms != nil {
+				test.transforms(transforms)
+			}
+			names := []string{}
+			for _, pass := range transforms.Pipeline(target).passes {
+				names = append(names, pass.Name())
+			}
+			if result := strings.Join(names, " "); result != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestDefaultTransformsCopy(t *testing.T) {
+	delete(DefaultTransforms(), FeatureClasses)
+	if DefaultTransforms()[FeatureClasses] == nil {
+		t.Error("expected deleting from one map to leave the next intact")
+	}
+}
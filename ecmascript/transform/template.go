@@ -0,0 +1,101 @@
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+// template parses a script snippet and returns its statements. Identifiers
+// that match a key in subs are replaced with the corresponding node. Snippets
+// are fixed strings inside this package, so failing to parse one is a bug.
+func template(src string, subs map[string]ast.Node) []ast.Node {
+	body, err := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(src), nil))).ParseFragment(parser.ParseOptions{Mode: parser.ScriptMode})
+	if err != nil {
+		panic(fmt.Errorf("transform: bad template %q: %w", src, err))
+	}
+	for i := range body {
+		body[i] = substitute(body[i], subs)
+	}
+	return body
+}
+
+// substitute replaces identifiers inside of n according to subs.
+func substitute(n ast.Node, subs map[string]ast.Node) ast.Node {
+	if id, ok := n.(ast.Identifier); ok {
+		if r, ok := subs[id.Name]; ok {
+			return r
+		}
+		return n
+	}
+	return ast.MapChildren(n, func(c ast.Node) ast.Node {
+		return substitute(c, subs)
+	})
+}
+
+// literal returns an expression that evaluates to the JSON representation of
+// v. Object keys are emitted in sorted order so that output is deterministic.
+func literal(v interface{}) ast.Node {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	var j interface{}
+	if err := json.Unmarshal(b, &j); err != nil {
+		panic(err)
+	}
+	return jsonLiteral(j)
+}
+
+func jsonLiteral(v interface{}) ast.Node {
+	switch v := v.(type) {
+	case nil:
+		return ast.NullLiteral{}
+	case bool:
+		return ast.BooleanLiteral{Value: v, Raw: strconv.FormatBool(v)}
+	case float64:
+		if v < 0 {
+			return ast.UnaryExpression{Operator: ast.UnaryMinusOp, Argument: numberLiteral(-v)}
+		}
+		return numberLiteral(v)
+	case string:
+		return stringLiteral(v)
+	case []interface{}:
+		a := ast.ArrayExpression{Elements: []ast.Node{}}
+		for _, e := range v {
+			a.Elements = append(a.Elements, jsonLiteral(e))
+		}
+		return a
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		o := ast.ObjectExpression{Properties: []ast.Property{}}
+		for _, k := range keys {
+			o.Properties = append(o.Properties, ast.Property{
+				Key:   stringLiteral(k),
+				Value: jsonLiteral(v[k]),
+				Kind:  ast.InitProperty,
+			})
+		}
+		return o
+	}
+	panic(fmt.Errorf("transform: unexpected JSON value %T", v))
+}
+
+func numberLiteral(v float64) ast.NumberLiteral {
+	return ast.NumberLiteral{Value: v, Raw: strconv.FormatFloat(v, 'g', -1, 64)}
+}
+
+func stringLiteral(s string) ast.StringLiteral {
+	b, _ := json.Marshal(s)
+	return ast.StringLiteral{Value: s, Raw: string(b)}
+}
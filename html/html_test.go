@@ -0,0 +1,262 @@
+package html
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// dump formats a document tree one node per line, indented by depth, with
+// the span of each element and text node.
+func dump(doc *Node) string {
+	var b strings.Builder
+	var walk func(n *Node, depth int)
+	walk = func(n *Node, depth int) {
+		indent := strings.Repeat("  ", depth)
+		s := n.Span
+		switch n.Type {
+		case DoctypeNode:
+			fmt.Fprintf(&b, "%s<!doctype %s>\n", indent, n.Data)
+		case ElementNode:
+			fmt.Fprintf(&b, "%s<%s> %d:%d-%d:%d\n", indent, n.Data, s.Start.Row, s.Start.Column, s.End.Row, s.End.Column)
+		case TextNode:
+			fmt.Fprintf(&b, "%s%q %d:%d-%d:%d\n", indent, n.Data, s.Start.Row, s.Start.Column, s.End.Row, s.End.Column)
+		case CommentNode:
+			fmt.Fprintf(&b, "%s<!--%s-->\n", indent, n.Data)
+		}
+		for _, c := range n.Children {
+			walk(c, depth+1)
+		}
+	}
+	for _, c := range doc.Children {
+		walk(c, 0)
+	}
+	return b.String()
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name, src, expected string
+	}{
+		{
+			name: "explicit",
+			src:  "<!DOCTYPE html><html><head><title>A &amp; B</title></head><body><p>Hi</p></body></html>",
+			expected: `<!doctype html>
+<html> 1:16-1:88
+  <head> 1:22-1:59
+    <title> 1:28-1:52
+      "A & B" 1:35-1:44
+  <body> 1:59-1:81
+    <p> 1:65-1:74
+      "Hi" 1:68-1:70
+`,
+		},
+		{
+			name: "implied",
+			src:  "<title>t</title>\n<p>hello",
+			expected: `<html> 1:1-2:9
+  <head> 1:1-2:1
+    <title> 1:1-1:17
+      "t" 1:8-1:9
+    "\n" 1:17-2:1
+  <body> 2:1-2:9
+    <p> 2:1-2:9
+      "hello" 2:4-2:9
+`,
+		},
+		{
+			name: "empty",
+			src:  "",
+			expected: `<html> 1:1-1:1
+  <head> 1:1-1:1
+  <body> 1:1-1:1
+`,
+		},
+		{
+			name: "comments",
+			src:  "<!-- a --> <html><!--b--><body></body></html><!--c-->",
+			expected: `<!-- a -->
+<html> 1:12-1:46
+  <!--b-->
+  <head> 1:26-1:26
+  <body> 1:26-1:39
+<!--c-->
+`,
+		},
+		{
+			name: "implied end tags",
+			src:  "<p>a<div>b</div><ul><li>1<li>2</ul><dl><dt>x<dd>y</dl><h1>c<h2>d",
+			expected: `<html> 1:1-1:65
+  <head> 1:1-1:1
+  <body> 1:1-1:65
+    <p> 1:1-1:5
+      "a" 1:4-1:5
+    <div> 1:5-1:17
+      "b" 1:10-1:11
+    <ul> 1:17-1:36
+      <li> 1:21-1:26
+        "1" 1:25-1:26
+      <li> 1:26-1:31
+        "2" 1:30-1:31
+    <dl> 1:36-1:55
+      <dt> 1:40-1:45
+        "x" 1:44-1:45
+      <dd> 1:45-1:50
+        "y" 1:49-1:50
+    <h1> 1:55-1:60
+      "c" 1:59-1:60
+    <h2> 1:60-1:65
+      "d" 1:64-1:65
+`,
+		},
+		{
+			name: "tables",
+			src:  "<table><tr><td>1<td>2<tr><td>3</table>",
+			expected: `<html> 1:1-1:39
+  <head> 1:1-1:1
+  <body> 1:1-1:39
+    <table> 1:1-1:39
+      <tr> 1:8-1:22
+        <td> 1:12-1:17
+          "1" 1:16-1:17
+        <td> 1:17-1:22
+          "2" 1:21-1:22
+      <tr> 1:22-1:31
+        <td> 1:26-1:31
+          "3" 1:30-1:31
+`,
+		},
+		{
+			name: "void and foreign",
+			src:  "<svg><circle/><rect></rect></svg><br/></br><img src=x>",
+			expected: `<html> 1:1-1:55
+  <head> 1:1-1:1
+  <body> 1:1-1:55
+    <svg> 1:1-1:34
+      <circle> 1:6-1:15
+      <rect> 1:15-1:28
+    <br> 1:34-1:39
+    <br> 1:39-1:44
+    <img> 1:44-1:55
+`,
+		},
+		{
+			name: "stray end tags",
+			src:  "</p><div><span>a</div>b</i>",
+			expected: `<html> 1:5-1:24
+  <head> 1:5-1:5
+  <body> 1:5-1:24
+    <div> 1:5-1:23
+      <span> 1:10-1:17
+        "a" 1:16-1:17
+    "b" 1:23-1:24
+`,
+		},
+		{
+			name: "script content",
+			src:  "<script><!--<script>x</script>y--></script>\r\n<p>&lt;</p>",
+			expected: `<html> 1:1-3:12
+  <head> 1:1-3:1
+    <script> 1:1-1:44
+      "<!--<script>x</script>y-->" 1:9-1:35
+    "\r\n" 1:44-3:1
+  <body> 3:1-3:12
+    <p> 3:1-3:12
+      "<" 3:4-3:8
+`,
+		},
+		{
+			name: "content after body",
+			src:  "<body></body><!--c-->x",
+			expected: `<html> 1:1-1:23
+  <head> 1:1-1:1
+  <body> 1:1-1:23
+    "x" 1:22-1:23
+  <!--c-->
+`,
+		},
+		{
+			name: "head element after head",
+			src:  "<head></head> <style>s</style><p>",
+			expected: `<html> 1:1-1:34
+  <head> 1:1-1:31
+    <style> 1:15-1:31
+      "s" 1:22-1:23
+  " " 1:14-1:15
+  <body> 1:31-1:34
+    <p> 1:31-1:34
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(strings.Split(test.expected, "\n"), strings.Split(dump(Parse(test.src, nil)), "\n")); diff != "" {
+				t.Errorf("unexpected tree (-expected +actual):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTokenizer(t *testing.T) {
+	uri := &url.URL{Scheme: "https", Host: "example.com", Path: "/"}
+	at := func(row, column int) ast.Location { return ast.Location{URI: uri, Row: row, Column: column} }
+	span := func(r1, c1, r2, c2 int) ast.Span { return ast.Span{Start: at(r1, c1), End: at(r2, c2)} }
+	src := "<A Href='x&amp;y' hidden\n  href=z data-x=1/>é<?php ?><!---->\n</a\n>< b</ >"
+	expected := []Token{
+		{Type: StartTagToken, Data: "a", Attr: []Attribute{
+			{Name: "href", Value: "x&y", Span: span(1, 4, 1, 18)},
+			{Name: "hidden", Span: span(1, 19, 1, 25)},
+			{Name: "data-x", Value: "1/", Span: span(2, 10, 2, 19)},
+		}, Span: span(1, 1, 2, 20)},
+		{Type: TextToken, Data: "é", Span: span(2, 20, 2, 21)},
+		{Type: CommentToken, Data: "?php ?", Span: span(2, 21, 2, 29)},
+		{Type: CommentToken, Span: span(2, 29, 2, 36)},
+		{Type: TextToken, Data: "\n", Span: span(2, 36, 3, 1)},
+		{Type: EndTagToken, Data: "a", Span: span(3, 1, 4, 2)},
+		{Type: TextToken, Data: "< b", Span: span(4, 2, 4, 5)},
+		{Type: CommentToken, Data: " ", Span: span(4, 5, 4, 9)},
+		{Type: EOFToken, Span: span(4, 9, 4, 9)},
+	}
+	z := NewTokenizer(src, uri)
+	var actual []Token
+	for {
+		tok := z.Next()
+		actual = append(actual, tok)
+		if tok.Type == EOFToken {
+			break
+		}
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("unexpected tokens (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestNode(t *testing.T) {
+	doc := Parse(`<div id="a" class=b><p>x<!--y--><b>z</b></div>`, nil)
+	var div *Node
+	Inspect(doc, func(n *Node) bool {
+		if n.Type == ElementNode && n.Data == "div" {
+			div = n
+		}
+		return div == nil
+	})
+	if div == nil {
+		t.Fatal("div not found")
+	}
+	if v, ok := div.Attribute("class"); !ok || v != "b" {
+		t.Errorf("expected class b, got %q, %v", v, ok)
+	}
+	if _, ok := div.Attribute("title"); ok {
+		t.Error("expected no title attribute")
+	}
+	if text := div.Text(); text != "xz" {
+		t.Errorf("expected text %q, got %q", "xz", text)
+	}
+	if div.Parent.Data != "body" || div.StartTag.End.Column != 21 || div.EndTag.Start.Column != 41 {
+		t.Errorf("unexpected div %+v", div)
+	}
+}
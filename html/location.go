@@ -0,0 +1,33 @@
+package html
+
+import (
+	"net/url"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// locator finds the locations of byte offsets in a document. Offsets must be
+// given in increasing order. Locations are counted as the ECMAScript lexer
+// counts them, so that those of a script in a page are in step with the
+// locations the lexer reports for it.
+type locator struct {
+	src    string
+	offset int
+	loc    ast.Location
+}
+
+func newLocator(src string, uri *url.URL) locator {
+	return locator{src: src, loc: ast.Location{URI: uri, Row: 1, Column: 1}}
+}
+
+// locate returns the location of offset.
+func (l *locator) locate(offset int) ast.Location {
+	l.loc = l.loc.Advance(l.src[l.offset:offset])
+	l.offset = offset
+	return l.loc
+}
+
+// span returns the span from start to end.
+func (l *locator) span(start, end int) ast.Span {
+	return ast.Span{Start: l.locate(start), End: l.locate(end)}
+}
@@ -0,0 +1,85 @@
+package html
+
+import (
+	"strings"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// NodeType is the type of a node in a document tree.
+type NodeType int
+
+// These are all of the possible node types.
+const (
+	DocumentNode NodeType = iota
+	DoctypeNode
+	ElementNode
+	TextNode
+	CommentNode
+)
+
+// Node is a node in a document tree.
+type Node struct {
+	Type NodeType
+
+	// Data is the tag name of an element, in lowercase, the name of a
+	// doctype, or the text of a text or comment node.
+	Data string
+
+	// Attr are the attributes of an element.
+	Attr []Attribute
+
+	Parent   *Node
+	Children []*Node
+
+	// Span runs from the start of a node to its end. An element without an
+	// end tag ends where its content does, or where its start tag does if
+	// it has no content.
+	Span ast.Span
+
+	// StartTag and EndTag are the spans of the tags of an element. Elements
+	// whose tags are implied, such as a body that is not written out, have
+	// zero spans for them.
+	StartTag, EndTag ast.Span
+}
+
+// Attribute returns the value of the named attribute of an element, and
+// whether it has the attribute at all.
+func (n *Node) Attribute(name string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Name == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// Text returns the text of a node and its descendants.
+func (n *Node) Text() string {
+	var b strings.Builder
+	Inspect(n, func(c *Node) bool {
+		if c.Type == TextNode {
+			b.WriteString(c.Data)
+		}
+		return true
+	})
+	return b.String()
+}
+
+// appendChild adds c as the last child of n.
+func (n *Node) appendChild(c *Node) {
+	c.Parent = n
+	n.Children = append(n.Children, c)
+}
+
+// Inspect traverses a document tree in depth-first order: it starts by
+// calling f(n); if f returns true, Inspect is called recursively for each of
+// the children of n.
+func Inspect(n *Node, f func(*Node) bool) {
+	if n == nil || !f(n) {
+		return
+	}
+	for _, c := range n.Children {
+		Inspect(c, f)
+	}
+}
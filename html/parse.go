@@ -0,0 +1,604 @@
+// Package html parses HTML documents into trees of nodes with spans, so that
+// the scripts and other content of a page can be found and reported on in
+// terms of where they are in the page.
+//
+// Tokenization follows the WHATWG HTML standard. Tree construction follows it
+// for the common cases: the html, head and body elements are implied where
+// they are left out, void elements have no content, and the end tags that
+// may be left out, such as those of p, li and td, are implied by what
+// follows. Misnested formatting elements, foster parenting in tables and the
+// insertion modes of select and frameset are not handled; content that
+// needs them is still kept, but may end up elsewhere in the tree than in a
+// browser.
+package html
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// insertionMode is the state of tree construction, which decides how each
+// token is handled.
+type insertionMode int
+
+const (
+	beforeHTMLMode insertionMode = iota
+	beforeHeadMode
+	inHeadMode
+	afterHeadMode
+	inBodyMode
+	afterBodyMode
+	afterAfterBodyMode
+)
+
+// set is a set of element names.
+type set map[string]bool
+
+func newSet(names ...string) set {
+	s := set{}
+	for _, name := range names {
+		s[name] = true
+	}
+	return s
+}
+
+var (
+	// voidElements have no content or end tag.
+	voidElements = newSet("area", "base", "basefont", "bgsound", "br", "col", "embed", "frame", "hr", "img", "input", "keygen", "link", "meta", "param", "source", "track", "wbr")
+
+	// headElements are the elements that go in the head.
+	headElements = newSet("base", "basefont", "bgsound", "link", "meta", "noframes", "noscript", "script", "style", "template", "title")
+
+	// headings are the heading elements.
+	headings = newSet("h1", "h2", "h3", "h4", "h5", "h6")
+
+	// closesParagraph are the elements whose start tag ends an open p.
+	closesParagraph = newSet("address", "article", "aside", "blockquote", "center", "details", "dialog", "dir", "div", "dl", "fieldset", "figcaption", "figure", "footer", "form", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hgroup", "hr", "listing", "main", "menu", "nav", "ol", "p", "plaintext", "pre", "search", "section", "summary", "table", "ul", "xmp")
+
+	// special are the elements that end tags of other elements do not close.
+	special = newSet("address", "applet", "area", "article", "aside", "base", "basefont", "bgsound", "blockquote", "body", "br", "button", "caption", "center", "col", "colgroup", "dd", "details", "dir", "div", "dl", "dt", "embed", "fieldset", "figcaption", "figure", "footer", "form", "frame", "frameset", "h1", "h2", "h3", "h4", "h5", "h6", "head", "header", "hgroup", "hr", "html", "iframe", "img", "input", "keygen", "li", "link", "listing", "main", "marquee", "menu", "meta", "nav", "noembed", "noframes", "noscript", "object", "ol", "p", "param", "plaintext", "pre", "script", "search", "section", "select", "source", "style", "summary", "table", "tbody", "td", "template", "textarea", "tfoot", "th", "thead", "title", "tr", "track", "ul", "wbr", "xmp")
+
+	// defaultScope are the elements that limit the search for an open
+	// element to close.
+	defaultScope = newSet("applet", "caption", "html", "marquee", "object", "table", "td", "template", "th")
+
+	// tableScope are the elements that limit the search for an open part of
+	// a table to close.
+	tableScope = newSet("html", "table", "template")
+
+	// tableParts are the elements that are closed within table scope.
+	tableParts = newSet("caption", "table", "tbody", "td", "tfoot", "th", "thead", "tr")
+)
+
+// parser builds a document tree from tokens.
+type parser struct {
+	z    *Tokenizer
+	doc  *Node
+	head *Node
+	mode insertionMode
+
+	// stack is the open elements, innermost last.
+	stack []*Node
+}
+
+// Parse parses an HTML document, with locations against uri. Like a
+// browser, it accepts any input, so it never fails.
+func Parse(src string, uri *url.URL) *Node {
+	p := &parser{z: NewTokenizer(src, uri), doc: &Node{Type: DocumentNode}}
+	for {
+		t := p.z.Next()
+		for !p.step(&t) {
+		}
+		if t.Type == EOFToken {
+			p.doc.Span = ast.Span{Start: ast.Location{URI: uri, Row: 1, Column: 1}, End: t.Span.End}
+			break
+		}
+	}
+	finishSpans(p.doc)
+	return p.doc
+}
+
+// step handles t in the current insertion mode. It returns false if the mode
+// changed and t must be handled again in the new mode.
+func (p *parser) step(t *Token) bool {
+	if cur := p.current(); cur != nil && elementContent[cur.Data] != markupContent {
+		return p.inText(t)
+	}
+	switch p.mode {
+	case beforeHTMLMode:
+		return p.beforeHTML(t)
+	case beforeHeadMode:
+		return p.beforeHead(t)
+	case inHeadMode:
+		return p.inHead(t)
+	case afterHeadMode:
+		return p.afterHead(t)
+	case inBodyMode:
+		return p.inBody(t)
+	case afterBodyMode:
+		return p.afterBody(t)
+	default:
+		return p.afterAfterBody(t)
+	}
+}
+
+// inText handles the content of an element such as script or title, which
+// the tokenizer reads as text.
+func (p *parser) inText(t *Token) bool {
+	switch t.Type {
+	case TextToken:
+		p.insertText(*t)
+	case EndTagToken:
+		p.popTo(len(p.stack)-1, t.Span)
+	default:
+		p.popTo(len(p.stack)-1, ast.Span{})
+		return false
+	}
+	return true
+}
+
+func (p *parser) beforeHTML(t *Token) bool {
+	switch t.Type {
+	case DoctypeToken:
+		p.doc.appendChild(&Node{Type: DoctypeNode, Data: t.Data, Span: t.Span})
+		return true
+	case CommentToken:
+		p.insertComment(*t)
+		return true
+	case TextToken:
+		if _, *t = splitSpace(*t); t.Data == "" {
+			return true
+		}
+	case StartTagToken:
+		if t.Data == "html" {
+			p.insert(*t)
+			p.mode = beforeHeadMode
+			return true
+		}
+	case EndTagToken:
+		if !impliesBody(t.Data) {
+			return true
+		}
+	}
+	p.insertImplied("html", t.Span.Start)
+	p.mode = beforeHeadMode
+	return false
+}
+
+// impliesBody returns whether an end tag is handled before the body as if it
+// were content of the body, rather than ignored.
+func impliesBody(name string) bool {
+	return name == "head" || name == "body" || name == "html" || name == "br"
+}
+
+func (p *parser) beforeHead(t *Token) bool {
+	switch t.Type {
+	case DoctypeToken:
+		return true
+	case CommentToken:
+		p.insertComment(*t)
+		return true
+	case TextToken:
+		if _, *t = splitSpace(*t); t.Data == "" {
+			return true
+		}
+	case StartTagToken:
+		switch t.Data {
+		case "html":
+			return true
+		case "head":
+			p.head = p.insert(*t)
+			p.mode = inHeadMode
+			return true
+		}
+	case EndTagToken:
+		if !impliesBody(t.Data) {
+			return true
+		}
+	}
+	p.head = p.insertImplied("head", t.Span.Start)
+	p.mode = inHeadMode
+	return false
+}
+
+func (p *parser) inHead(t *Token) bool {
+	if p.current() != p.head {
+		// Inside a template.
+		return p.inBody(t)
+	}
+	switch t.Type {
+	case DoctypeToken:
+		return true
+	case CommentToken:
+		p.insertComment(*t)
+		return true
+	case TextToken:
+		var space Token
+		if space, *t = splitSpace(*t); space.Data != "" {
+			p.insertText(space)
+		}
+		if t.Data == "" {
+			return true
+		}
+	case StartTagToken:
+		switch {
+		case t.Data == "html", t.Data == "head":
+			return true
+		case headElements[t.Data]:
+			p.insert(*t)
+			return true
+		}
+	case EndTagToken:
+		switch {
+		case t.Data == "head":
+			p.popTo(len(p.stack)-1, t.Span)
+			p.mode = afterHeadMode
+			return true
+		case !impliesBody(t.Data):
+			return true
+		}
+	}
+	p.popTo(len(p.stack)-1, ast.Span{})
+	p.mode = afterHeadMode
+	return false
+}
+
+func (p *parser) afterHead(t *Token) bool {
+	switch t.Type {
+	case DoctypeToken:
+		return true
+	case CommentToken:
+		p.insertComment(*t)
+		return true
+	case TextToken:
+		var space Token
+		if space, *t = splitSpace(*t); space.Data != "" {
+			p.insertText(space)
+		}
+		if t.Data == "" {
+			return true
+		}
+	case StartTagToken:
+		switch {
+		case t.Data == "html", t.Data == "head":
+			return true
+		case t.Data == "body":
+			p.insert(*t)
+			p.mode = inBodyMode
+			return true
+		case headElements[t.Data] && p.head != nil:
+			// Out of place, but still put in the head.
+			p.insertInto(p.head, *t)
+			return true
+		}
+	case EndTagToken:
+		if !impliesBody(t.Data) {
+			return true
+		}
+	}
+	p.insertImplied("body", t.Span.Start)
+	p.mode = inBodyMode
+	return false
+}
+
+func (p *parser) inBody(t *Token) bool {
+	switch t.Type {
+	case TextToken:
+		p.insertText(*t)
+	case CommentToken:
+		p.insertComment(*t)
+	case StartTagToken:
+		switch t.Data {
+		case "html", "head", "body":
+			return true
+		}
+		p.startTag(*t)
+	case EndTagToken:
+		switch t.Data {
+		case "body", "html":
+			i := p.inScope("body", defaultScope)
+			if i < 0 {
+				return true
+			}
+			p.stack[i].EndTag = t.Span
+			p.mode = afterBodyMode
+			return t.Data == "body"
+		case "p":
+			i := p.inScope("p", defaultScope, "button")
+			if i < 0 {
+				// A stray </p> makes an empty paragraph.
+				p.insertImplied("p", t.Span.Start)
+				i = len(p.stack) - 1
+			}
+			p.popTo(i, t.Span)
+		case "br":
+			t.Type = StartTagToken
+			p.insert(*t)
+		default:
+			p.endTag(*t)
+		}
+	}
+	return true
+}
+
+func (p *parser) afterBody(t *Token) bool {
+	switch t.Type {
+	case DoctypeToken, EOFToken:
+		return true
+	case CommentToken:
+		p.stack[0].appendChild(&Node{Type: CommentNode, Data: t.Data, Span: t.Span})
+		return true
+	case TextToken:
+		if isAllSpace(t.Data) {
+			p.insertText(*t)
+			return true
+		}
+	case EndTagToken:
+		if t.Data == "html" {
+			p.stack[0].EndTag = t.Span
+			p.mode = afterAfterBodyMode
+			return true
+		}
+	}
+	p.mode = inBodyMode
+	return false
+}
+
+func (p *parser) afterAfterBody(t *Token) bool {
+	switch t.Type {
+	case DoctypeToken, EOFToken:
+		return true
+	case CommentToken:
+		p.doc.appendChild(&Node{Type: CommentNode, Data: t.Data, Span: t.Span})
+		return true
+	case TextToken:
+		if isAllSpace(t.Data) {
+			p.insertText(*t)
+			return true
+		}
+	}
+	p.mode = inBodyMode
+	return false
+}
+
+// startTag handles a start tag in the body, closing the elements whose end
+// tags it implies.
+func (p *parser) startTag(t Token) {
+	name := t.Data
+	switch {
+	case closesParagraph[name]:
+		if i := p.inScope("p", defaultScope, "button"); i >= 0 {
+			p.popTo(i, ast.Span{})
+		}
+		if headings[name] && headings[p.current().Data] {
+			p.popTo(len(p.stack)-1, ast.Span{})
+		}
+	case name == "li":
+		p.closeListItem(newSet("li"))
+	case name == "dd", name == "dt":
+		p.closeListItem(newSet("dd", "dt"))
+	case name == "option", name == "optgroup":
+		if p.current().Data == "option" {
+			p.popTo(len(p.stack)-1, ast.Span{})
+		}
+	case name == "td", name == "th":
+		p.closeTablePart("td", "th")
+	case name == "tr":
+		p.closeTablePart("tr")
+	case name == "tbody", name == "thead", name == "tfoot":
+		p.closeTablePart("tbody", "thead", "tfoot")
+	}
+	p.insert(t)
+}
+
+// closeListItem closes an open list item with one of the given names, along
+// with an open p.
+func (p *parser) closeListItem(names set) {
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		n := p.stack[i].Data
+		if names[n] {
+			p.popTo(i, ast.Span{})
+			break
+		}
+		if special[n] && n != "address" && n != "div" && n != "p" {
+			break
+		}
+	}
+	if i := p.inScope("p", defaultScope, "button"); i >= 0 {
+		p.popTo(i, ast.Span{})
+	}
+}
+
+// closeTablePart closes the innermost open table part with one of the given
+// names, if it is in the current table.
+func (p *parser) closeTablePart(names ...string) {
+	for _, name := range names {
+		if i := p.inScope(name, tableScope); i >= 0 {
+			p.popTo(i, ast.Span{})
+			return
+		}
+	}
+}
+
+// endTag handles an end tag in the body, closing the element it ends.
+func (p *parser) endTag(t Token) {
+	name := t.Data
+	switch {
+	case headings[name]:
+		for i := len(p.stack) - 1; i >= 0 && !defaultScope[p.stack[i].Data]; i-- {
+			if headings[p.stack[i].Data] {
+				p.popTo(i, t.Span)
+				return
+			}
+		}
+	case tableParts[name]:
+		if i := p.inScope(name, tableScope); i >= 0 {
+			p.popTo(i, t.Span)
+		}
+	case name == "li":
+		if i := p.inScope(name, defaultScope, "ol", "ul"); i >= 0 {
+			p.popTo(i, t.Span)
+		}
+	case special[name]:
+		if i := p.inScope(name, defaultScope); i >= 0 {
+			p.popTo(i, t.Span)
+		}
+	default:
+		for i := len(p.stack) - 1; i >= 0; i-- {
+			if p.stack[i].Data == name {
+				p.popTo(i, t.Span)
+				return
+			}
+			if special[p.stack[i].Data] {
+				return
+			}
+		}
+	}
+}
+
+// inScope returns the index of the innermost open element with the given
+// name, or -1 if there is none before an element in scope or extra.
+func (p *parser) inScope(name string, scope set, extra ...string) int {
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		n := p.stack[i].Data
+		if n == name {
+			return i
+		}
+		if scope[n] {
+			return -1
+		}
+		for _, e := range extra {
+			if n == e {
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+// current returns the innermost open element, if any.
+func (p *parser) current() *Node {
+	if len(p.stack) == 0 {
+		return nil
+	}
+	return p.stack[len(p.stack)-1]
+}
+
+// parent returns the node that new nodes are added to.
+func (p *parser) parent() *Node {
+	if cur := p.current(); cur != nil {
+		return cur
+	}
+	return p.doc
+}
+
+// foreign returns whether an element named name, added to the current node,
+// is in SVG or MathML content, where a start tag ending with /> has no end
+// tag.
+func (p *parser) foreign(name string) bool {
+	if name == "svg" || name == "math" {
+		return true
+	}
+	for _, n := range p.stack {
+		if n.Data == "svg" || n.Data == "math" {
+			return true
+		}
+	}
+	return false
+}
+
+// insert adds an element for a start tag to the current node, and opens it
+// unless it can not have content.
+func (p *parser) insert(t Token) *Node {
+	return p.insertInto(p.parent(), t)
+}
+
+// insertInto is like insert, but adds the element to parent.
+func (p *parser) insertInto(parent *Node, t Token) *Node {
+	n := &Node{Type: ElementNode, Data: t.Data, Attr: t.Attr, Span: t.Span, StartTag: t.Span}
+	parent.appendChild(n)
+	if !voidElements[t.Data] && !(t.SelfClosing && p.foreign(t.Data)) {
+		p.stack = append(p.stack, n)
+	}
+	return n
+}
+
+// insertImplied adds and opens an element whose start tag was left out, where
+// the token at loc implies it.
+func (p *parser) insertImplied(name string, loc ast.Location) *Node {
+	n := &Node{Type: ElementNode, Data: name, Span: ast.Span{Start: loc, End: loc}}
+	p.parent().appendChild(n)
+	p.stack = append(p.stack, n)
+	return n
+}
+
+// insertText adds text to the current node, joining it to the text before it
+// if there is any.
+func (p *parser) insertText(t Token) {
+	parent := p.parent()
+	if k := len(parent.Children); k > 0 && parent.Children[k-1].Type == TextNode {
+		last := parent.Children[k-1]
+		last.Data += t.Data
+		last.Span.End = t.Span.End
+		return
+	}
+	parent.appendChild(&Node{Type: TextNode, Data: t.Data, Span: t.Span})
+}
+
+// insertComment adds a comment to the current node.
+func (p *parser) insertComment(t Token) {
+	p.parent().appendChild(&Node{Type: CommentNode, Data: t.Data, Span: t.Span})
+}
+
+// popTo closes the open element at index i, and every element inside it. end
+// is the span of its end tag, if it has one.
+func (p *parser) popTo(i int, end ast.Span) {
+	if i < 0 {
+		return
+	}
+	p.stack[i].EndTag = end
+	p.stack = p.stack[:i]
+}
+
+// isAllSpace returns whether s is only HTML whitespace.
+func isAllSpace(s string) bool {
+	return strings.Trim(s, " \t\n\f\r") == ""
+}
+
+// splitSpace splits a text token into its leading whitespace and the rest.
+func splitSpace(t Token) (Token, Token) {
+	rest := strings.TrimLeft(t.Data, " \t\n\f\r")
+	mid := t.Span.Start.Advance(t.Data[:len(t.Data)-len(rest)])
+	space, text := t, t
+	space.Data, space.Span.End = t.Data[:len(t.Data)-len(rest)], mid
+	text.Data, text.Span.Start = rest, mid
+	return space, text
+}
+
+// later returns the later of two locations.
+func later(a, b ast.Location) ast.Location {
+	if b.Row > a.Row || b.Row == a.Row && b.Column > a.Column {
+		return b
+	}
+	return a
+}
+
+// finishSpans sets the end of the span of each element under n to the end
+// of its end tag or of its children, whichever is later. The last child does
+// not always end last: content after </body> still goes in the body.
+func finishSpans(n *Node) {
+	for _, c := range n.Children {
+		finishSpans(c)
+	}
+	if n.Type != ElementNode {
+		return
+	}
+	if n.EndTag.End.Row != 0 {
+		n.Span.End = later(n.Span.End, n.EndTag.End)
+	}
+	for _, c := range n.Children {
+		n.Span.End = later(n.Span.End, c.Span.End)
+	}
+}
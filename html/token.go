@@ -0,0 +1,437 @@
+package html
+
+import (
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// TokenType is the type of an HTML token.
+type TokenType int
+
+// These are all of the possible token types.
+const (
+	// EOFToken ends the input.
+	EOFToken TokenType = iota
+
+	TextToken
+	StartTagToken
+	EndTagToken
+	CommentToken
+	DoctypeToken
+)
+
+// Attribute is an attribute of a tag.
+type Attribute struct {
+	// Name is the name of the attribute, in lowercase.
+	Name string
+
+	// Value is the value of the attribute, with character references
+	// replaced.
+	Value string
+
+	// Span runs from the start of the name to the end of the value,
+	// including any quotes.
+	Span ast.Span
+}
+
+// Token is an HTML token.
+type Token struct {
+	Type TokenType
+
+	// Data is the text of a text or comment token, the name of a tag in
+	// lowercase, or the name of a doctype in lowercase. Character
+	// references in text are replaced, except in the content of elements
+	// such as script and style, which is kept as written.
+	Data string
+
+	// Attr are the attributes of a tag. Only the first of duplicate
+	// attributes is kept, as in a browser.
+	Attr []Attribute
+
+	// SelfClosing is whether a start tag ends with />.
+	SelfClosing bool
+
+	Span ast.Span
+}
+
+// contentKind is how the content of an element is tokenized.
+type contentKind int
+
+const (
+	// markupContent is tokenized as tags, text and comments.
+	markupContent contentKind = iota
+
+	// rawTextContent is text, which ends at the end tag of the element.
+	rawTextContent
+
+	// rcDataContent is like rawTextContent, but character references are
+	// replaced.
+	rcDataContent
+
+	// scriptContent is like rawTextContent, but the end tag is hidden by
+	// the escapes browsers accept in scripts, such as <!--<script>.
+	scriptContent
+
+	// plainTextContent is text up to the end of the input.
+	plainTextContent
+)
+
+// elementContent is how the content of elements that do not contain markup
+// is tokenized.
+var elementContent = map[string]contentKind{
+	"iframe":    rawTextContent,
+	"noembed":   rawTextContent,
+	"noframes":  rawTextContent,
+	"noscript":  rawTextContent,
+	"plaintext": plainTextContent,
+	"script":    scriptContent,
+	"style":     rawTextContent,
+	"textarea":  rcDataContent,
+	"title":     rcDataContent,
+	"xmp":       rawTextContent,
+}
+
+// Tokenizer splits an HTML document into tokens, following the WHATWG
+// tokenization algorithm. After the start tag of an element such as script
+// or style, it reads the content of the element as text, as a browser with
+// scripting enabled does.
+//
+// Unlike a browser, the tokenizer does not normalize newlines, so that the
+// text of a token is exactly as written and its span can be used to find it.
+type Tokenizer struct {
+	src string
+	pos int
+	loc locator
+
+	// raw is the name of the element whose content is read next as text,
+	// if any.
+	raw string
+}
+
+// NewTokenizer creates a new tokenizer for src, with locations against uri.
+func NewTokenizer(src string, uri *url.URL) *Tokenizer {
+	return &Tokenizer{src: src, loc: newLocator(src, uri)}
+}
+
+// isSpace returns whether c is HTML whitespace.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
+
+// isLetter returns whether c is an ASCII letter.
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// Next returns the next token. At the end of the input, it returns an
+// EOFToken, and keeps doing so.
+func (z *Tokenizer) Next() Token {
+	if z.raw != "" {
+		name := z.raw
+		z.raw = ""
+		if t, ok := z.content(name); ok {
+			return t
+		}
+	}
+	for z.pos < len(z.src) {
+		start := z.pos
+		end := z.textEnd(start)
+		if end > start {
+			z.pos = end
+			return Token{Type: TextToken, Data: html.UnescapeString(z.src[start:end]), Span: z.loc.span(start, end)}
+		}
+		if t, ok := z.markup(); ok {
+			return t
+		}
+	}
+	loc := z.loc.locate(len(z.src))
+	return Token{Type: EOFToken, Span: ast.Span{Start: loc, End: loc}}
+}
+
+// textEnd returns the offset of the first markup at or after i, or the end of
+// the input. A < which does not begin markup is text.
+func (z *Tokenizer) textEnd(i int) int {
+	for {
+		j := strings.IndexByte(z.src[i:], '<')
+		if j < 0 {
+			return len(z.src)
+		}
+		i += j
+		if i+1 < len(z.src) {
+			switch c := z.src[i+1]; {
+			case isLetter(c), c == '!', c == '?':
+				return i
+			case c == '/' && i+2 < len(z.src):
+				return i
+			}
+		}
+		i++
+	}
+}
+
+// markup consumes the markup at the current position, and returns its
+// token. Markup that does not produce a token, such as a start tag cut off
+// by the end of the input, is skipped and returns false.
+func (z *Tokenizer) markup() (Token, bool) {
+	start := z.pos
+	rest := z.src[start:]
+	switch {
+	case strings.HasPrefix(rest, "<!--"):
+		return z.comment(start), true
+	case len(rest) >= 9 && rest[1] == '!' && strings.EqualFold(rest[2:9], "doctype"):
+		return z.doctype(start), true
+	case rest[1] == '/' && isLetter(rest[2]):
+		return z.tag(EndTagToken, start+2)
+	case rest[1] == '/' && rest[2] == '>':
+		// </> is dropped entirely.
+		z.pos = start + 3
+		return Token{}, false
+	case rest[1] == '!', rest[1] == '/':
+		return z.bogusComment(start, start+2), true
+	case rest[1] == '?':
+		return z.bogusComment(start, start+1), true
+	}
+	return z.tag(StartTagToken, start+1)
+}
+
+// comment consumes a comment starting at start.
+func (z *Tokenizer) comment(start int) Token {
+	i := start + 4
+	var data string
+	switch {
+	case strings.HasPrefix(z.src[i:], ">"):
+		z.pos = i + 1
+	case strings.HasPrefix(z.src[i:], "->"):
+		z.pos = i + 2
+	default:
+		end := len(z.src)
+		z.pos = end
+		for j := i; j < len(z.src); j++ {
+			if strings.HasPrefix(z.src[j:], "-->") {
+				end, z.pos = j, j+3
+				break
+			}
+			if strings.HasPrefix(z.src[j:], "--!>") {
+				end, z.pos = j, j+4
+				break
+			}
+		}
+		data = z.src[i:end]
+	}
+	return Token{Type: CommentToken, Data: data, Span: z.loc.span(start, z.pos)}
+}
+
+// bogusComment consumes markup starting at start that browsers treat as a
+// comment, such as <?xml ...>, whose text starts at i and runs to the next >.
+func (z *Tokenizer) bogusComment(start, i int) Token {
+	end := strings.IndexByte(z.src[i:], '>')
+	if end < 0 {
+		end = len(z.src)
+		z.pos = end
+	} else {
+		end += i
+		z.pos = end + 1
+	}
+	return Token{Type: CommentToken, Data: z.src[i:end], Span: z.loc.span(start, z.pos)}
+}
+
+// doctype consumes a doctype starting at start. Only its name is kept.
+func (z *Tokenizer) doctype(start int) Token {
+	i := start + 9
+	for i < len(z.src) && isSpace(z.src[i]) {
+		i++
+	}
+	nameStart := i
+	for i < len(z.src) && !isSpace(z.src[i]) && z.src[i] != '>' {
+		i++
+	}
+	name := strings.ToLower(z.src[nameStart:i])
+	if end := strings.IndexByte(z.src[i:], '>'); end < 0 {
+		z.pos = len(z.src)
+	} else {
+		z.pos = i + end + 1
+	}
+	return Token{Type: DoctypeToken, Data: name, Span: z.loc.span(start, z.pos)}
+}
+
+// tag consumes a tag starting at start, whose name begins at i. A tag cut off
+// by the end of the input is dropped, and returns false.
+func (z *Tokenizer) tag(typ TokenType, i int) (Token, bool) {
+	start := i - 1
+	if typ == EndTagToken {
+		start--
+	}
+	nameStart := i
+	for i < len(z.src) && !isSpace(z.src[i]) && z.src[i] != '/' && z.src[i] != '>' {
+		i++
+	}
+	t := Token{Type: typ, Data: strings.ToLower(z.src[nameStart:i])}
+	type attr struct{ nameStart, nameEnd, valueStart, end int }
+	var attrs []attr
+	for {
+		for i < len(z.src) && isSpace(z.src[i]) {
+			i++
+		}
+		if i >= len(z.src) {
+			z.pos = len(z.src)
+			return Token{}, false
+		}
+		if z.src[i] == '>' {
+			i++
+			break
+		}
+		if z.src[i] == '/' {
+			i++
+			if i < len(z.src) && z.src[i] == '>' {
+				t.SelfClosing = true
+				i++
+				break
+			}
+			continue
+		}
+
+		// The first character is part of the name even if it is =.
+		a := attr{nameStart: i, valueStart: -1}
+		i++
+		for i < len(z.src) && !isSpace(z.src[i]) && z.src[i] != '/' && z.src[i] != '>' && z.src[i] != '=' {
+			i++
+		}
+		a.nameEnd, a.end = i, i
+		j := i
+		for j < len(z.src) && isSpace(z.src[j]) {
+			j++
+		}
+		if j < len(z.src) && z.src[j] == '=' {
+			j++
+			for j < len(z.src) && isSpace(z.src[j]) {
+				j++
+			}
+			a.valueStart = j
+			if j < len(z.src) && (z.src[j] == '"' || z.src[j] == '\'') {
+				end := strings.IndexByte(z.src[j+1:], z.src[j])
+				if end < 0 {
+					z.pos = len(z.src)
+					return Token{}, false
+				}
+				j += end + 2
+			} else {
+				for j < len(z.src) && !isSpace(z.src[j]) && z.src[j] != '>' {
+					j++
+				}
+			}
+			i, a.end = j, j
+		}
+		attrs = append(attrs, a)
+	}
+	z.pos = i
+
+	// Spans are found in order, as the locator requires.
+	tagStart := z.loc.locate(start)
+	seen := map[string]bool{}
+	for _, a := range attrs {
+		name := strings.ToLower(z.src[a.nameStart:a.nameEnd])
+		value := ""
+		if a.valueStart >= 0 {
+			value = z.src[a.valueStart:a.end]
+			if value != "" && (value[0] == '"' || value[0] == '\'') {
+				value = value[1 : len(value)-1]
+			}
+			value = html.UnescapeString(value)
+		}
+		span := z.loc.span(a.nameStart, a.end)
+		if !seen[name] {
+			seen[name] = true
+			t.Attr = append(t.Attr, Attribute{Name: name, Value: value, Span: span})
+		}
+	}
+	t.Span = ast.Span{Start: tagStart, End: z.loc.locate(z.pos)}
+	if typ == StartTagToken {
+		if _, ok := elementContent[t.Data]; ok {
+			z.raw = t.Data
+		}
+	}
+	return t, true
+}
+
+// content consumes the content of the named element, which is not markup,
+// returning it as a text token unless it is empty.
+func (z *Tokenizer) content(name string) (Token, bool) {
+	start := z.pos
+	var end int
+	switch elementContent[name] {
+	case plainTextContent:
+		end = len(z.src)
+	case scriptContent:
+		end = scriptEnd(z.src, start)
+	default:
+		end = endTag(z.src, start, name)
+	}
+	if end == start {
+		return Token{}, false
+	}
+	z.pos = end
+	data := z.src[start:end]
+	if elementContent[name] == rcDataContent {
+		data = html.UnescapeString(data)
+	}
+	return Token{Type: TextToken, Data: data, Span: z.loc.span(start, end)}, true
+}
+
+// tagAt returns whether s begins with the given tag opening, such as
+// "</style", in any case, followed by the end of the tag name.
+func tagAt(s, open string) bool {
+	return len(s) > len(open) && strings.EqualFold(s[:len(open)], open) && (isSpace(s[len(open)]) || s[len(open)] == '/' || s[len(open)] == '>')
+}
+
+// endTag returns the offset of the end tag of the named element in src,
+// starting at i, or the end of the input if there is none.
+func endTag(src string, i int, name string) int {
+	for {
+		j := strings.Index(src[i:], "</")
+		if j < 0 {
+			return len(src)
+		}
+		i += j
+		if tagAt(src[i:], "</"+name) {
+			return i
+		}
+		i += 2
+	}
+}
+
+// scriptEnd returns the offset of the end tag of a script in src, starting
+// at i, or the end of the input if there is none. Inside <!-- and -->, a
+// <script> tag hides the next </script> tag, as it does in a browser.
+func scriptEnd(src string, i int) int {
+	const (
+		data = iota
+		escaped
+		doubleEscaped
+	)
+	state := data
+	for ; i < len(src); i++ {
+		rest := src[i:]
+		switch {
+		case state == data && strings.HasPrefix(rest, "<!--"):
+			// Continue from the first -, so that <!--> ends the escape.
+			state = escaped
+			i++
+		case state != data && strings.HasPrefix(rest, "-->"):
+			state = data
+			i += 2
+		case state == escaped && tagAt(rest, "<script"):
+			state = doubleEscaped
+			i += len("<script") - 1
+		case tagAt(rest, "</script"):
+			if state != doubleEscaped {
+				return i
+			}
+			state = escaped
+			i += len("</script") - 1
+		}
+	}
+	return len(src)
+}
@@ -1,4 +1,13 @@
 #!/bin/sh
+# Builds the web demos into ./dist.
+#
+# Set WASM_BUILD=tiny to build the WASM modules with TinyGo instead, which
+# gives much smaller modules at the cost of a slower build. TinyGo must be
+# installed, and its own wasm_exec.js is used, since the two are not
+# interchangeable. Panics use -panic print rather than -panic trap: a trap
+# aborts the module before deferred calls run, but the parser recovers from
+# panics to report them as internal errors, which is worth the few kilobytes
+# that printing panic messages costs.
 set -e
 
 mkdir -p ./dist
@@ -6,6 +15,11 @@ cp ./web/index.html ./dist
 
 # Parser demo
 mkdir -p ./dist/parser
-GOOS=js GOARCH=wasm go build -o ./dist/parser/parser.wasm ./web/parser
+if [ "$WASM_BUILD" = tiny ]; then
+	tinygo build -o ./dist/parser/parser.wasm -target wasm -opt z -no-debug -panic print ./web/parser
+	cp "$(tinygo env TINYGOROOT)/targets/wasm_exec.js" ./dist/parser/wasm_exec.js
+else
+	GOOS=js GOARCH=wasm go build -trimpath -ldflags "-s -w" -o ./dist/parser/parser.wasm ./web/parser
+	cp ./web/parser/wasm_exec.js ./dist/parser/wasm_exec.js
+fi
 cp ./web/parser/index.html ./dist/parser/index.html
-cp ./web/parser/wasm_exec.js ./dist/parser/wasm_exec.js
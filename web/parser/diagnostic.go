@@ -0,0 +1,61 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
+)
+
+// offsets converts locations in a text to offsets in UTF-16 code units, which
+// is how JS strings, and so editors in the browser, index text.
+type offsets struct {
+	text string
+}
+
+// offset returns the offset of loc in UTF-16 code units, counting rows and
+// columns the way the lexer does. Locations past the end of the text are at
+// its end.
+func (o offsets) offset(loc ast.Location) int {
+	l, off := ast.Location{Row: 1, Column: 1}, 0
+	for _, r := range o.text {
+		if l.Row > loc.Row || l.Row == loc.Row && l.Column >= loc.Column {
+			break
+		}
+		l = l.Advance(string(r))
+		if r >= 0x10000 {
+			off += 2
+		} else {
+			off++
+		}
+	}
+	return off
+}
+
+func (o offsets) position(loc ast.Location) map[string]interface{} {
+	return map[string]interface{}{
+		"line":   loc.Row,
+		"column": loc.Column,
+		"offset": o.offset(loc),
+	}
+}
+
+// diagnosticObjects returns the diagnostics as plain objects, with the start
+// of each span inline and its end in `end`, so that an editor can mark it.
+func diagnosticObjects(text string, ds []errs.Diagnostic) []interface{} {
+	o := offsets{text}
+	objs := []interface{}{}
+	for _, d := range ds {
+		objs = append(objs, map[string]interface{}{
+			"message":  d.Message,
+			"code":     d.Code.String(),
+			"severity": d.Severity.String(),
+			"line":     d.Span.Start.Row,
+			"column":   d.Span.Start.Column,
+			"offset":   o.offset(d.Span.Start),
+			"end":      o.position(d.Span.End),
+		})
+	}
+	return objs
+}
@@ -0,0 +1,605 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"strconv"
+	"syscall/js"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+)
+
+// estreeNode returns a new ESTree node object of the type typ.
+func estreeNode(typ string) js.Value {
+	o := jsObject.New()
+	o.Set("type", typ)
+	return o
+}
+
+// nodeToJS returns the JS object for the ESTree representation of n, the same
+// as toJS(n.ESTree()), but built directly from the node, without reflection.
+// Node types it does not know of are still converted through ESTree.
+func nodeToJS(n ast.Node) js.Value {
+	switch n := n.(type) {
+	case nil:
+		return js.Null()
+
+	// Programs
+	case ast.ScriptNode:
+		return programToJS("script", n.Body, n.Comments)
+	case ast.ModuleNode:
+		return programToJS("module", n.Body, n.Comments)
+
+	// Statements
+	case ast.BlockStatement:
+		o := estreeNode("BlockStatement")
+		o.Set("body", nodesToJS(n.Body))
+		return o
+	case ast.EmptyStatement:
+		return estreeNode("EmptyStatement")
+	case ast.ExpressionStatement:
+		o := estreeNode("ExpressionStatement")
+		o.Set("expression", nodeToJS(n.Expression))
+		if n.Directive != "" {
+			o.Set("directive", n.Directive)
+		}
+		return o
+	case ast.VariableDeclaration:
+		o := estreeNode("VariableDeclaration")
+		decls := js.Null()
+		if len(n.Declarations) > 0 {
+			decls = jsArray.New(len(n.Declarations))
+			for i, d := range n.Declarations {
+				decl := estreeNode("VariableDeclarator")
+				decl.Set("id", bindingPatternToJS(d.ID))
+				decl.Set("init", nodeToJS(d.Init))
+				decls.SetIndex(i, decl)
+			}
+		}
+		o.Set("declarations", decls)
+		o.Set("kind", n.Kind.ESTree())
+		return o
+	case ast.ContinueStatement:
+		o := estreeNode("ContinueStatement")
+		o.Set("label", identToJS(n.Label))
+		return o
+	case ast.BreakStatement:
+		o := estreeNode("BreakStatement")
+		o.Set("label", identToJS(n.Label))
+		return o
+	case ast.ReturnStatement:
+		o := estreeNode("ReturnStatement")
+		o.Set("argument", nodeToJS(n.Argument))
+		return o
+	case ast.ThrowStatement:
+		o := estreeNode("ThrowStatement")
+		o.Set("argument", nodeToJS(n.Argument))
+		return o
+	case ast.WithStatement:
+		o := estreeNode("WithStatement")
+		o.Set("object", nodeToJS(n.Object))
+		o.Set("body", nodeToJS(n.Body))
+		return o
+	case ast.IfStatement:
+		o := estreeNode("IfStatement")
+		o.Set("test", nodeToJS(n.Test))
+		o.Set("consequent", nodeToJS(n.Consequent))
+		o.Set("alternate", nodeToJS(n.Alternate))
+		return o
+	case ast.WhileStatement:
+		o := estreeNode("WhileStatement")
+		o.Set("test", nodeToJS(n.Test))
+		o.Set("body", nodeToJS(n.Body))
+		return o
+	case ast.DoWhileStatement:
+		o := estreeNode("DoWhileStatement")
+		o.Set("test", nodeToJS(n.Test))
+		o.Set("body", nodeToJS(n.Body))
+		return o
+	case ast.ForStatement:
+		o := estreeNode("ForStatement")
+		o.Set("init", nodeToJS(n.Init))
+		o.Set("test", nodeToJS(n.Test))
+		o.Set("update", nodeToJS(n.Update))
+		o.Set("body", nodeToJS(n.Body))
+		return o
+	case ast.ForInStatement:
+		o := estreeNode("ForInStatement")
+		o.Set("each", false)
+		o.Set("left", nodeToJS(n.Left))
+		o.Set("right", nodeToJS(n.Right))
+		o.Set("body", nodeToJS(n.Body))
+		return o
+	case ast.ForOfStatement:
+		o := estreeNode("ForOfStatement")
+		o.Set("left", nodeToJS(n.Left))
+		o.Set("right", nodeToJS(n.Right))
+		o.Set("body", nodeToJS(n.Body))
+		return o
+	case ast.SwitchStatement:
+		o := estreeNode("SwitchStatement")
+		o.Set("discriminant", nodeToJS(n.Discriminant))
+		cases := jsArray.New(len(n.Cases))
+		for i, c := range n.Cases {
+			sc := estreeNode("SwitchCase")
+			sc.Set("test", nodeToJS(c.Test))
+			sc.Set("consequent", nodesToJS(c.Consequent))
+			cases.SetIndex(i, sc)
+		}
+		o.Set("cases", cases)
+		return o
+	case ast.LabeledStatement:
+		o := estreeNode("LabeledStatement")
+		o.Set("label", identToJS(n.Label))
+		o.Set("body", nodeToJS(n.Body))
+		return o
+	case ast.TryStatement:
+		o := estreeNode("TryStatement")
+		o.Set("block", nodeToJS(n.Block))
+		o.Set("handler", nodeToJS(n.Handler))
+		o.Set("finalizer", nodeToJS(n.Finalizer))
+		return o
+	case ast.CatchClause:
+		o := estreeNode("CatchClause")
+		o.Set("param", bindingPatternToJS(n.Param))
+		o.Set("body", nodeToJS(n.Body))
+		return o
+
+	// Declarations
+	case ast.FunctionDeclaration:
+		return functionToJS("FunctionDeclaration", n.ID, n.Params, n.Body, n.Generator, n.Expression, n.Async)
+	case ast.ClassDeclaration:
+		return classToJS("ClassDeclaration", n.ID, n.SuperClass, n.Body)
+	case ast.MethodDefinition:
+		o := estreeNode("MethodDefinition")
+		o.Set("key", nodeToJS(n.Key))
+		o.Set("computed", n.Computed)
+		o.Set("value", nodeToJS(n.Value))
+		o.Set("kind", n.Kind.ESTree())
+		o.Set("static", n.Static)
+		return o
+	case ast.AccessorProperty:
+		o := estreeNode("AccessorProperty")
+		o.Set("key", nodeToJS(n.Key))
+		o.Set("computed", n.Computed)
+		o.Set("value", nodeToJS(n.Value))
+		o.Set("static", n.Static)
+		return o
+
+	// Modules
+	case ast.ImportDeclNode:
+		o := estreeNode("ImportDeclaration")
+		specs := jsArray.New()
+		if n.DefaultBinding != nil {
+			s := estreeNode("ImportDefaultSpecifier")
+			s.Set("local", identToJS(n.DefaultBinding.Identifier))
+			specs.Call("push", s)
+		}
+		if n.NameSpace != nil {
+			s := estreeNode("ImportNamespaceSpecifier")
+			s.Set("local", identToJS(n.NameSpace.Identifier))
+			specs.Call("push", s)
+		}
+		for _, i := range n.NamedImports {
+			s := estreeNode("ImportSpecifier")
+			s.Set("local", identToJS(i.Binding()))
+			s.Set("imported", moduleNameToJS(i.Identifier, i.Quoted))
+			specs.Call("push", s)
+		}
+		o.Set("specifiers", specs)
+		o.Set("source", stringToJS(n.Module))
+		if n.Source {
+			o.Set("phase", "source")
+		}
+		return o
+	case ast.ExportDeclNode:
+		source := js.Null()
+		if n.Module != "" {
+			source = stringToJS(n.Module)
+		}
+		switch {
+		case n.Default != nil:
+			o := estreeNode("ExportDefaultDeclaration")
+			o.Set("declaration", nodeToJS(n.Default))
+			return o
+		case n.NameSpace != nil:
+			o := estreeNode("ExportAllDeclaration")
+			o.Set("source", source)
+			o.Set("exported", moduleNameToJS(n.NameSpace.Identifier, n.NameSpace.Quoted))
+			return o
+		}
+		o := estreeNode("ExportNamedDeclaration")
+		o.Set("declaration", nodeToJS(n.Declaration))
+		specs := jsArray.New(len(n.NamedExports))
+		for i, x := range n.NamedExports {
+			s := estreeNode("ExportSpecifier")
+			s.Set("local", moduleNameToJS(x.Identifier, x.Quoted))
+			quoted := x.Quoted
+			if x.AsBinding != "" {
+				quoted = x.AsQuoted
+			}
+			s.Set("exported", moduleNameToJS(x.ExportedName(), quoted))
+			specs.SetIndex(i, s)
+		}
+		o.Set("specifiers", specs)
+		o.Set("source", source)
+		return o
+	case ast.ModuleExpression:
+		o := estreeNode("ModuleExpression")
+		o.Set("body", programToJS("module", n.Body, nil))
+		return o
+
+	// Expressions
+	case ast.Identifier:
+		return identToJS(n.Name)
+	case ast.ThisExpression:
+		return estreeNode("ThisExpression")
+	case ast.Super:
+		return estreeNode("Super")
+	case ast.MetaProperty:
+		o := estreeNode("MetaProperty")
+		o.Set("meta", identToJS(n.Meta))
+		o.Set("property", identToJS(n.Property))
+		return o
+	case ast.ArrayExpression:
+		o := estreeNode("ArrayExpression")
+		o.Set("elements", nodesToJS(n.Elements))
+		return o
+	case ast.ObjectExpression:
+		o := estreeNode("ObjectExpression")
+		props := jsArray.New(len(n.Properties))
+		for i, p := range n.Properties {
+			props.SetIndex(i, propertyToJS(p))
+		}
+		o.Set("properties", props)
+		return o
+	case ast.FunctionExpression:
+		typ := "FunctionExpression"
+		if n.Arrow {
+			typ = "ArrowFunctionExpression"
+		}
+		return functionToJS(typ, n.ID, n.Params, n.Body, n.Generator, n.Expression, n.Async)
+	case ast.ClassExpression:
+		return classToJS("ClassExpression", n.ID, n.SuperClass, n.Body)
+	case ast.TemplateLiteral:
+		return templateToJS(n)
+	case ast.TaggedTemplateExpression:
+		o := estreeNode("TaggedTemplateExpression")
+		o.Set("tag", nodeToJS(n.Tag))
+		o.Set("quasi", templateToJS(n.Quasi))
+		return o
+	case ast.MemberExpression:
+		o := estreeNode("MemberExpression")
+		o.Set("computed", n.Computed)
+		o.Set("object", nodeToJS(n.Object))
+		o.Set("property", nodeToJS(n.Property))
+		if n.Optional {
+			o.Set("optional", true)
+		}
+		return o
+	case ast.CallExpression:
+		o := estreeNode("CallExpression")
+		o.Set("callee", nodeToJS(n.Callee))
+		if n.Optional {
+			o.Set("optional", true)
+		}
+		o.Set("arguments", nodesToJS(n.Arguments))
+		return o
+	case ast.NewExpression:
+		o := estreeNode("NewExpression")
+		o.Set("callee", nodeToJS(n.Callee))
+		o.Set("arguments", nodesToJS(n.Arguments))
+		return o
+	case ast.ParenthesizedExpression:
+		return nodeToJS(n.Expression)
+	case ast.SpreadElement:
+		o := estreeNode("SpreadElement")
+		o.Set("argument", nodeToJS(n.Argument))
+		return o
+	case ast.SequenceExpression:
+		o := estreeNode("SequenceExpression")
+		o.Set("expressions", nodesToJS(n.Expressions))
+		return o
+	case ast.ConditionalExpression:
+		o := estreeNode("ConditionalExpression")
+		o.Set("test", nodeToJS(n.Test))
+		o.Set("alternate", nodeToJS(n.Alternate))
+		o.Set("consequent", nodeToJS(n.Consequent))
+		return o
+	case ast.UpdateExpression:
+		o := estreeNode("UpdateExpression")
+		o.Set("operator", n.Operator.ESTree())
+		o.Set("argument", nodeToJS(n.Argument))
+		o.Set("prefix", n.Operator.Prefix())
+		return o
+	case ast.UnaryExpression:
+		o := estreeNode("UnaryExpression")
+		o.Set("operator", n.Operator.ESTree())
+		o.Set("argument", nodeToJS(n.Argument))
+		o.Set("prefix", true)
+		return o
+	case ast.AwaitExpression:
+		o := estreeNode("AwaitExpression")
+		o.Set("argument", nodeToJS(n.Argument))
+		return o
+	case ast.YieldExpression:
+		o := estreeNode("YieldExpression")
+		o.Set("argument", nodeToJS(n.Argument))
+		o.Set("delegate", n.Delegate)
+		return o
+	case ast.BinaryExpression:
+		typ := "BinaryExpression"
+		if n.Operator == ast.BinaryLogicalAndOp || n.Operator == ast.BinaryLogicalOrOp || n.Operator == ast.BinaryCoalesceOp {
+			typ = "LogicalExpression"
+		}
+		o := estreeNode(typ)
+		o.Set("operator", n.Operator.ESTree())
+		o.Set("left", nodeToJS(n.Left))
+		o.Set("right", nodeToJS(n.Right))
+		return o
+	case ast.AssignmentExpression:
+		o := estreeNode("AssignmentExpression")
+		o.Set("operator", n.Operator.ESTree())
+		o.Set("left", nodeToJS(n.Left))
+		o.Set("right", nodeToJS(n.Right))
+		return o
+
+	// Literals
+	case ast.NullLiteral:
+		return literalToJS(nil, "null")
+	case ast.BooleanLiteral:
+		return literalToJS(n.Value, n.Raw)
+	case ast.StringLiteral:
+		return literalToJS(n.Value, n.Raw)
+	case ast.NumberLiteral:
+		return literalToJS(n.Value, n.Raw)
+	case ast.RegExpLiteral:
+		o := literalToJS(n.Raw, n.Raw)
+		re := jsObject.New()
+		re.Set("pattern", n.Pattern)
+		re.Set("flags", n.Flags)
+		o.Set("regex", re)
+		return o
+	}
+	return toJS(n.ESTree())
+}
+
+// nodesToJS returns an array of the ESTree objects of ns, with null for each
+// nil node, such as the holes of an array.
+func nodesToJS(ns []ast.Node) js.Value {
+	a := jsArray.New(len(ns))
+	for i, n := range ns {
+		a.SetIndex(i, nodeToJS(n))
+	}
+	return a
+}
+
+// programToJS returns a Program node with the source type sourceType.
+func programToJS(sourceType string, body []ast.Node, comments []ast.Comment) js.Value {
+	o := estreeNode("Program")
+	o.Set("body", nodesToJS(body))
+	o.Set("sourceType", sourceType)
+	if len(comments) > 0 {
+		a := jsArray.New(len(comments))
+		for i, c := range comments {
+			a.SetIndex(i, commentToJS(c))
+		}
+		o.Set("comments", a)
+	}
+	return o
+}
+
+// commentToJS returns the object for a comment, as Comment.ESTree does.
+func commentToJS(c ast.Comment) js.Value {
+	typ := "Line"
+	if c.Block {
+		typ = "Block"
+	}
+	o := estreeNode(typ)
+	o.Set("value", c.Value)
+	rng := jsArray.New(2)
+	rng.SetIndex(0, c.Range[0])
+	rng.SetIndex(1, c.Range[1])
+	o.Set("range", rng)
+	loc := jsObject.New()
+	loc.Set("start", positionToJS(c.Span.Start))
+	loc.Set("end", positionToJS(c.Span.End))
+	o.Set("loc", loc)
+	return o
+}
+
+// positionToJS returns an ESTree Position, whose columns count from 0.
+func positionToJS(l ast.Location) js.Value {
+	o := jsObject.New()
+	o.Set("line", l.Row)
+	o.Set("column", l.Column-1)
+	return o
+}
+
+// identToJS returns an Identifier node, or null if name is empty, as for the
+// names that the AST stores as strings.
+func identToJS(name string) js.Value {
+	if name == "" {
+		return js.Null()
+	}
+	o := estreeNode("Identifier")
+	o.Set("name", name)
+	return o
+}
+
+// literalToJS returns a Literal node.
+func literalToJS(value interface{}, raw string) js.Value {
+	o := estreeNode("Literal")
+	o.Set("value", value)
+	o.Set("raw", raw)
+	return o
+}
+
+// stringToJS returns a string Literal node for a module specifier, which the
+// AST stores as a plain string.
+func stringToJS(value string) js.Value {
+	return literalToJS(value, strconv.Quote(value))
+}
+
+// moduleNameToJS returns a string Literal node if the name was quoted, or an
+// Identifier node otherwise.
+func moduleNameToJS(name string, quoted bool) js.Value {
+	if quoted {
+		return stringToJS(name)
+	}
+	return identToJS(name)
+}
+
+// functionToJS returns a function node of the type typ.
+func functionToJS(typ, id string, params ast.FormalParameters, body ast.Node, generator, expression, async bool) js.Value {
+	o := estreeNode(typ)
+	o.Set("id", identToJS(id))
+	o.Set("params", paramsToJS(params))
+	o.Set("body", nodeToJS(body))
+	o.Set("generator", generator)
+	o.Set("expression", expression)
+	o.Set("async", async)
+	return o
+}
+
+// paramsToJS returns the array of patterns of a function's parameters.
+func paramsToJS(params ast.FormalParameters) js.Value {
+	a := jsArray.New()
+	for _, p := range params.Parameters {
+		a.Call("push", bindingElementToJS(p))
+	}
+	if params.RestParameter != "" {
+		a.Call("push", restToJS(identToJS(params.RestParameter)))
+	}
+	return a
+}
+
+// classToJS returns a class node of the type typ.
+func classToJS(typ, id string, superClass ast.Node, body []ast.Node) js.Value {
+	o := estreeNode(typ)
+	o.Set("id", identToJS(id))
+	o.Set("superClass", nodeToJS(superClass))
+	b := estreeNode("ClassBody")
+	b.Set("body", nodesToJS(body))
+	o.Set("body", b)
+	return o
+}
+
+// propertyToJS returns the node for a property of an object expression.
+func propertyToJS(p ast.Property) js.Value {
+	if p.Kind == ast.SpreadProperty {
+		o := estreeNode("SpreadElement")
+		o.Set("argument", nodeToJS(p.Value))
+		return o
+	}
+	k := nodeToJS(p.Key)
+	v, shorthand := nodeToJS(p.Value), false
+	if v.IsNull() {
+		v, shorthand = k, true
+	}
+	o := estreeNode("Property")
+	o.Set("key", k)
+	o.Set("computed", p.Computed)
+	o.Set("value", v)
+	o.Set("kind", p.Kind.ESTree())
+	o.Set("method", p.Method)
+	o.Set("shorthand", shorthand)
+	return o
+}
+
+// templateToJS returns a TemplateLiteral node. The cooked value of a quasi
+// with an invalid escape sequence is null.
+func templateToJS(n ast.TemplateLiteral) js.Value {
+	o := estreeNode("TemplateLiteral")
+	quasis := jsArray.New(len(n.Quasis))
+	for i, q := range n.Quasis {
+		v := jsObject.New()
+		v.Set("raw", q.Raw)
+		if q.Invalid {
+			v.Set("cooked", js.Null())
+		} else {
+			v.Set("cooked", q.Cooked)
+		}
+		e := estreeNode("TemplateElement")
+		e.Set("value", v)
+		e.Set("tail", i == len(n.Quasis)-1)
+		quasis.SetIndex(i, e)
+	}
+	o.Set("quasis", quasis)
+	o.Set("expressions", nodesToJS(n.Expressions))
+	return o
+}
+
+// bindingPatternToJS returns the node for a binding pattern, or null if it is
+// empty.
+func bindingPatternToJS(p ast.BindingPattern) js.Value {
+	switch {
+	case p.Identifier != "":
+		return identToJS(p.Identifier)
+	case p.ObjectPattern != nil:
+		o := estreeNode("ObjectPattern")
+		props := jsArray.New()
+		for _, prop := range p.ObjectPattern.Properties {
+			props.Call("push", bindingPropertyToJS(prop))
+		}
+		if p.ObjectPattern.RestElement != "" {
+			props.Call("push", restToJS(identToJS(p.ObjectPattern.RestElement)))
+		}
+		o.Set("properties", props)
+		return o
+	case p.ArrayPattern != nil:
+		o := estreeNode("ArrayPattern")
+		elems := jsArray.New()
+		for _, e := range p.ArrayPattern.Elements {
+			elems.Call("push", bindingElementToJS(e))
+		}
+		if rest := bindingPatternToJS(p.ArrayPattern.RestElement); !rest.IsNull() {
+			elems.Call("push", restToJS(rest))
+		}
+		o.Set("elements", elems)
+		return o
+	}
+	return js.Null()
+}
+
+// bindingElementToJS returns the node for a binding element: its pattern, in
+// an AssignmentPattern if it has a default value.
+func bindingElementToJS(e ast.BindingElement) js.Value {
+	v := bindingPatternToJS(e.Value)
+	if e.Init == nil {
+		return v
+	}
+	o := estreeNode("AssignmentPattern")
+	o.Set("left", v)
+	o.Set("right", nodeToJS(e.Init))
+	return o
+}
+
+// bindingPropertyToJS returns the Property node for a property of an object
+// binding pattern.
+func bindingPropertyToJS(p ast.BindingProperty) js.Value {
+	k := identToJS(p.PropertyName)
+	if p.Key != nil {
+		k = nodeToJS(p.Key)
+	}
+	value, shorthand := p.Value, false
+	if bindingPatternToJS(value).IsNull() {
+		value, shorthand = ast.BindingPattern{Identifier: p.PropertyName}, true
+	}
+	o := estreeNode("Property")
+	o.Set("key", k)
+	o.Set("computed", p.Computed)
+	o.Set("value", bindingElementToJS(ast.BindingElement{Value: value, Init: p.Init}))
+	o.Set("kind", "init")
+	o.Set("method", false)
+	o.Set("shorthand", shorthand)
+	return o
+}
+
+// restToJS returns a RestElement node with the argument arg.
+func restToJS(arg js.Value) js.Value {
+	o := estreeNode("RestElement")
+	o.Set("argument", arg)
+	return o
+}
@@ -0,0 +1,66 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall/js"
+	"testing"
+
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+// TestNodeToJS checks that nodeToJS builds the same objects as the ESTree
+// methods do, over the parser's test inputs. It needs Node.js, through the
+// go_js_wasm_exec script in the misc/wasm directory of the Go distribution
+// (lib/wasm since Go 1.24):
+//
+//	GOOS=js GOARCH=wasm go test -exec "$(go env GOROOT)/misc/wasm/go_js_wasm_exec" ./web/parser
+func TestNodeToJS(t *testing.T) {
+	files, err := filepath.Glob("../../ecmascript/parser/testdata/golden/*.*js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files,
+		"../../ecmascript/parser/testdata/lodash-v4.17.15.min.js",
+		"../../ecmascript/parser/testdata/react-v17.0.2.js",
+	)
+	stringify := js.Global().Get("JSON").Get("stringify")
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opt := parser.ParseOptions{Mode: parser.ScriptMode, Comments: true}
+			if strings.HasSuffix(file, ".mjs") {
+				opt.Mode = parser.ModuleMode
+			}
+			n, err := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(string(src)), nil))).Parse(opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := json.Marshal(n.ESTree())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want, got interface{}
+			if err := json.Unmarshal(data, &want); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(stringify.Invoke(nodeToJS(n)).String()), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("nodeToJS differs from ESTree")
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"net/url"
+	"strings"
+	"syscall/js"
+
+	"github.com/jchv/cleansheets/ecmascript/ast"
+	"github.com/jchv/cleansheets/ecmascript/errs"
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+// incrementalParser parses a text as it is edited. It keeps the tree of the
+// last successful parse, which parser.Reparse uses to parse again only the
+// top-level statements around the text that changed, along with the ESTree
+// object of each of its statements, so that those that are reused are not
+// converted again.
+type incrementalParser struct {
+	opt parser.ParseOptions
+	uri *url.URL
+
+	// tree is the result of the last successful parse of text, or nil if
+	// there is none, and stmts are the ESTree objects of its statements.
+	tree  ast.Node
+	text  string
+	stmts []js.Value
+}
+
+// edit returns the edit that turns old into text, replacing the text between
+// the longest prefix and suffix that they have in common.
+func edit(old, text string) parser.Edit {
+	p := 0
+	for p < len(old) && p < len(text) && old[p] == text[p] {
+		p++
+	}
+	q := 0
+	for q < len(old)-p && q < len(text)-p && old[len(old)-1-q] == text[len(text)-1-q] {
+		q++
+	}
+	return parser.Edit{Start: p, End: len(old) - q, Text: text[p : len(text)-q]}
+}
+
+// parse parses text, reusing what it can of the last parse. It returns the
+// same object as ParseES, with the number of top-level statements that were
+// reused in `reused`.
+func (ip *incrementalParser) parse(text string) map[string]interface{} {
+	var (
+		n          ast.Node
+		head, tail int
+		err        error
+	)
+	if ip.tree != nil {
+		n, head, tail, err = parser.Reparse(ip.tree, ip.text, edit(ip.text, text), ip.uri, ip.opt)
+	} else {
+		n, err = parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(text), ip.uri))).Parse(ip.opt)
+	}
+	if err != nil {
+		ip.tree, ip.text, ip.stmts = nil, "", nil
+		src := errs.NewSource(text)
+		ds := errs.Diagnostics(err)
+		var msgs []string
+		for _, d := range ds {
+			msgs = append(msgs, d.Format(src))
+		}
+		return map[string]interface{}{
+			"error":  strings.Join(msgs, "\n\n"),
+			"errors": toJS(diagnosticObjects(text, ds)),
+		}
+	}
+
+	// The program object is made without its body, which is filled in from
+	// the statements, reused or converted.
+	tree := n
+	var body []ast.Node
+	switch t := n.(type) {
+	case ast.ScriptNode:
+		body, t.Body = t.Body, nil
+		n = t
+	case ast.ModuleNode:
+		body, t.Body = t.Body, nil
+		n = t
+	default:
+		ip.tree, ip.text, ip.stmts = nil, "", nil
+		return map[string]interface{}{"result": nodeToJS(n), "reused": 0}
+	}
+	stmts := make([]js.Value, 0, len(body))
+	stmts = append(stmts, ip.stmts[:head]...)
+	for _, s := range body[head : len(body)-tail] {
+		stmts = append(stmts, nodeToJS(s))
+	}
+	stmts = append(stmts, ip.stmts[len(ip.stmts)-tail:]...)
+
+	o := nodeToJS(n)
+	a := jsArray.New(len(stmts))
+	for i, s := range stmts {
+		a.SetIndex(i, s)
+	}
+	o.Set("body", a)
+
+	ip.tree, ip.text, ip.stmts = tree, text, stmts
+	return map[string]interface{}{"result": o, "reused": head + tail}
+}
+
+// CreateParser returns a parser for a text that is edited over time, such as
+// the contents of an editor, with the options object in its first argument,
+// as for ParseES. The parser has the methods:
+//
+//	parse(text): parses the current text, returning the same object as
+//	             ParseES with the number of top-level statements reused
+//	             from the last parse in `reused`
+//	release():   frees the parser, after which it must not be used
+//
+// Statement objects in the result may be shared with earlier results, so they
+// should not be modified.
+func CreateParser(this js.Value, p []js.Value) interface{} {
+	options := js.Undefined()
+	if len(p) > 0 {
+		options = p[0]
+	}
+	opt, uri, err := parseOptions(options)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	ip := &incrementalParser{opt: opt, uri: uri}
+
+	var parse, release js.Func
+	parse = js.FuncOf(func(this js.Value, p []js.Value) interface{} {
+		return ip.parse(p[0].String())
+	})
+	release = js.FuncOf(func(this js.Value, p []js.Value) interface{} {
+		ip.tree, ip.stmts = nil, nil
+		parse.Release()
+		release.Release()
+		return nil
+	})
+	return map[string]interface{}{"parse": parse, "release": release}
+}
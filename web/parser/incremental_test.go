@@ -0,0 +1,46 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"strings"
+	"syscall/js"
+	"testing"
+
+	"github.com/jchv/cleansheets/ecmascript/lexer"
+	"github.com/jchv/cleansheets/ecmascript/parser"
+)
+
+func TestIncrementalParser(t *testing.T) {
+	texts := []struct {
+		text   string
+		reused int
+	}{
+		{"f(a);\ng(b);\nh(c);\n", 0},
+		{"f(a);\ng(bb);\nh(c);\n", 2},
+		{"f(a);\ng(bb);\nh(c);\ni(d);\n", 2},
+		{"f(a);\ng(bb", 0},
+		{"f(a);\ng(bb);\n", 0},
+		{"f(a);\ng(bb);\nlet x = `${y}`;\n", 1},
+	}
+	stringify := js.Global().Get("JSON").Get("stringify")
+	ip := &incrementalParser{opt: parser.ParseOptions{Mode: parser.ScriptMode}}
+	for _, test := range texts {
+		result := ip.parse(test.text)
+		n, err := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(test.text), nil))).Parse(ip.opt)
+		if err != nil {
+			if _, ok := result["error"]; !ok {
+				t.Errorf("%q: expected an error", test.text)
+			}
+			continue
+		}
+		if result["reused"] != test.reused {
+			t.Errorf("%q: expected %d statements reused, got %v", test.text, test.reused, result["reused"])
+		}
+		got, want := stringify.Invoke(result["result"]).String(), stringify.Invoke(nodeToJS(n)).String()
+		if got != want {
+			t.Errorf("%q: expected %s, got %s", test.text, want, got)
+		}
+	}
+}
@@ -4,32 +4,183 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
 	"strings"
 	"syscall/js"
 
+	"github.com/jchv/cleansheets/ecmascript/errs"
 	"github.com/jchv/cleansheets/ecmascript/lexer"
 	"github.com/jchv/cleansheets/ecmascript/parser"
 )
 
 func main() {
 	c := make(chan struct{}, 0)
+	js.Global().Set("tokenizeES", js.FuncOf(TokenizeES))
+	js.Global().Set("createParser", js.FuncOf(CreateParser))
 	js.Global().Call("parserLoaded", js.FuncOf(ParseES))
 	<-c
 }
 
+// parseModes are the values of the mode option.
+var parseModes = map[string]parser.ParseMode{
+	"script":     parser.ScriptMode,
+	"module":     parser.ModuleMode,
+	"expression": parser.ExpressionMode,
+	"detect":     parser.DetectMode,
+}
+
+// parseOptions reads the options object passed to ParseES, which may be
+// undefined. It has the properties:
+//
+//	mode:      "script" (the default), "module", "expression" or "detect"
+//	filename:  the name or URL of the input, used in error messages
+//	locations: whether to include locations in the ESTree output
+//	jsx:       whether to parse JSX
+//	comment:   whether to include the comments in the input as a
+//	           `comments` array on the Program node
+//
+//	allowReturnOutsideFunction, allowNewTargetOutsideFunction,
+//	allowSuperOutsideMethod: whether to accept return statements,
+//	new.target and super outside of functions and methods, as the
+//	ParseOptions fields of the same names do
+//
+// Locations and JSX are not supported yet, and are an error if set.
+func parseOptions(v js.Value) (parser.ParseOptions, *url.URL, error) {
+	opt := parser.ParseOptions{Mode: parser.ScriptMode}
+	if v.IsUndefined() || v.IsNull() {
+		return opt, nil, nil
+	}
+	if v.Type() != js.TypeObject {
+		return opt, nil, errors.New("options must be an object")
+	}
+
+	if m := v.Get("mode"); !m.IsUndefined() {
+		mode, ok := parseModes[m.String()]
+		if !ok {
+			return opt, nil, fmt.Errorf("unknown mode %q", m.String())
+		}
+		opt.Mode = mode
+	}
+
+	opt.AllowReturnOutsideFunction = v.Get("allowReturnOutsideFunction").Truthy()
+	opt.AllowNewTargetOutsideFunction = v.Get("allowNewTargetOutsideFunction").Truthy()
+	opt.AllowSuperOutsideMethod = v.Get("allowSuperOutsideMethod").Truthy()
+	opt.Comments = v.Get("comment").Truthy()
+
+	var uri *url.URL
+	if f := v.Get("filename"); !f.IsUndefined() && f.String() != "" {
+		var err error
+		if uri, err = url.Parse(f.String()); err != nil {
+			return opt, nil, fmt.Errorf("invalid filename: %w", err)
+		}
+	}
+
+	if v.Get("locations").Truthy() {
+		return opt, nil, errors.New("the locations option is not supported yet")
+	}
+	if v.Get("jsx").Truthy() {
+		return opt, nil, errors.New("the jsx option is not supported yet")
+	}
+	return opt, uri, nil
+}
+
+// ParseES parses its first argument, with the options object in its second
+// argument, if any. It returns an object with either the ESTree AST as a JS
+// object in `result`, or the formatted diagnostics in `error` and the
+// diagnostics as objects with their code, message and span in `errors`.
 func ParseES(this js.Value, p []js.Value) interface{} {
-	n, err := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(p[0].String()), nil))).Parse(parser.ParseOptions{Mode: parser.ScriptMode})
+	text := p[0].String()
+	options := js.Undefined()
+	if len(p) > 1 {
+		options = p[1]
+	}
+	opt, uri, err := parseOptions(options)
 	if err != nil {
 		return map[string]interface{}{"error": err.Error()}
 	}
-	w := &strings.Builder{}
-	e := json.NewEncoder(w)
-	e.SetEscapeHTML(false)
-	e.SetIndent("", "  ")
-	err = e.Encode(n.ESTree())
+	n, err := parser.NewParser(lexer.NewLexer(lexer.NewScanner(strings.NewReader(text), uri))).Parse(opt)
 	if err != nil {
-		return map[string]interface{}{"error": err.Error()}
+		src := errs.NewSource(text)
+		ds := errs.Diagnostics(err)
+		var msgs []string
+		for _, d := range ds {
+			msgs = append(msgs, d.Format(src))
+		}
+		return map[string]interface{}{
+			"error":  strings.Join(msgs, "\n\n"),
+			"errors": toJS(diagnosticObjects(text, ds)),
+		}
+	}
+	return map[string]interface{}{"result": nodeToJS(n)}
+}
+
+// tokenCategory returns the broad kind of a token, for highlighting.
+func tokenCategory(t lexer.TokenType) string {
+	name := t.String()
+	switch {
+	case strings.HasPrefix(name, "TokenKeyword"):
+		return "keyword"
+	case strings.HasPrefix(name, "TokenPunctuator"):
+		return "punctuator"
+	}
+	switch t {
+	case lexer.TokenIdentifier:
+		return "identifier"
+	case lexer.TokenPrivateIdentifier:
+		return "privateIdentifier"
+	case lexer.TokenLiteralNumber:
+		return "number"
+	case lexer.TokenLiteralString:
+		return "string"
+	case lexer.TokenLiteralRegExp:
+		return "regexp"
+	case lexer.TokenLiteralTemplate:
+		return "template"
+	}
+	return "unknown"
+}
+
+// TokenizeES lexes its first argument. It returns an object with the tokens
+// in `tokens`, each with its type, category, source text and span. If lexing
+// stopped early, the diagnostic is in `error` and `errors`, as for ParseES.
+// Whether a `/` begins a regular expression is guessed from the token before
+// it.
+func TokenizeES(this js.Value, p []js.Value) interface{} {
+	text := p[0].String()
+	l := lexer.NewLexer(lexer.NewScanner(strings.NewReader(text), nil))
+	tokens := []interface{}{}
+	prev := lexer.TokenNone
+	for {
+		t, err := l.Lex()
+		if err == nil && prev.RegexAllowed() &&
+			(t.Type == lexer.TokenPunctuatorDiv || t.Type == lexer.TokenPunctuatorDivAssign) {
+			var re lexer.ReToken
+			re, err = l.ReLex()
+			t = re.Token
+		}
+		if err != nil {
+			ds := errs.Diagnostics(err)
+			return map[string]interface{}{
+				"tokens": tokens,
+				"error":  ds[0].Format(errs.NewSource(text)),
+				"errors": toJS(diagnosticObjects(text, ds)),
+			}
+		}
+		if t.Type == lexer.TokenNone {
+			break
+		}
+		prev = t.Type
+		start, end := l.Start(), l.Location()
+		tokens = append(tokens, map[string]interface{}{
+			"type":     strings.TrimPrefix(t.Type.String(), "Token"),
+			"category": tokenCategory(t.Type),
+			"value":    t.Source(),
+			"newLine":  t.NewLine,
+			"start":    map[string]interface{}{"line": start.Row, "column": start.Column},
+			"end":      map[string]interface{}{"line": end.Row, "column": end.Column},
+		})
 	}
-	return map[string]interface{}{"result": w.String()}
+	return map[string]interface{}{"tokens": tokens}
 }
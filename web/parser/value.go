@@ -0,0 +1,158 @@
+//go:build js
+// +build js
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"syscall/js"
+)
+
+var (
+	jsObject = js.Global().Get("Object")
+	jsArray  = js.Global().Get("Array")
+)
+
+// toJS builds the JS value that v would decode to if it was encoded as JSON,
+// following the same json struct tags, without going through a string.
+//
+// The types built by this package are converted without reflection, which is
+// slow and, under TinyGo, only partly supported. Nodes are converted by
+// nodeToJS instead, so reflection is only needed for the ESTree of a node type
+// that it does not know of.
+func toJS(v interface{}) js.Value {
+	switch v := v.(type) {
+	case nil:
+		return js.Null()
+	case js.Value:
+		return v
+	case string:
+		return js.ValueOf(v)
+	case bool:
+		return js.ValueOf(v)
+	case int:
+		return js.ValueOf(v)
+	case float64:
+		return js.ValueOf(v)
+	case []interface{}:
+		if v == nil {
+			return js.Null()
+		}
+		a := jsArray.New(len(v))
+		for i, e := range v {
+			a.SetIndex(i, toJS(e))
+		}
+		return a
+	case map[string]interface{}:
+		if v == nil {
+			return js.Null()
+		}
+		o := jsObject.New()
+		for k, e := range v {
+			o.Set(k, toJS(e))
+		}
+		return o
+	}
+	return valueToJS(reflect.ValueOf(v))
+}
+
+func valueToJS(v reflect.Value) js.Value {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return js.Null()
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return js.Null()
+		}
+		if e := v.Elem(); v.Kind() == reflect.Interface && e.CanInterface() {
+			return toJS(e.Interface())
+		}
+		return valueToJS(v.Elem())
+	case reflect.Bool:
+		return js.ValueOf(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return js.ValueOf(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return js.ValueOf(float64(v.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return js.ValueOf(v.Float())
+	case reflect.String:
+		return js.ValueOf(v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return js.Null()
+		}
+		a := jsArray.New(v.Len())
+		for i := 0; i < v.Len(); i++ {
+			a.SetIndex(i, valueToJS(v.Index(i)))
+		}
+		return a
+	case reflect.Map:
+		if v.IsNil() {
+			return js.Null()
+		}
+		o := jsObject.New()
+		iter := v.MapRange()
+		for iter.Next() {
+			o.Set(iter.Key().String(), valueToJS(iter.Value()))
+		}
+		return o
+	case reflect.Struct:
+		o := jsObject.New()
+		structToJS(o, v)
+		return o
+	}
+	return js.Undefined()
+}
+
+// structToJS sets the properties of o from the fields of the struct v,
+// including the fields of embedded structs without a tag.
+func structToJS(o js.Value, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+		fv := v.Field(i)
+		if f.Anonymous && name == "" && fv.Kind() == reflect.Struct {
+			structToJS(o, fv)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if strings.Contains(opts, "omitempty") && isEmpty(fv) {
+			continue
+		}
+		o.Set(name, valueToJS(fv))
+	}
+}
+
+// isEmpty returns whether a field is left out by omitempty.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}